@@ -3,15 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // Project timezones must resolve on hosts without zoneinfo
 
 	"github.com/spf13/cobra"
 
 	"github.com/levskiy0/m3m/internal/app"
 	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/oidc"
 	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/runtime/modules"
 	"github.com/levskiy0/m3m/internal/service"
+	"github.com/levskiy0/m3m/internal/storage"
 	"github.com/levskiy0/m3m/pkg/schema"
 )
 
@@ -57,8 +65,8 @@ var newAdminCmd = &cobra.Command{
 
 		// Create repositories and services
 		userRepo := repository.NewUserRepository(db)
-		authService := service.NewAuthService(userRepo, cfg)
-		userService := service.NewUserService(userRepo, authService)
+		authService := service.NewAuthService(userRepo, repository.NewSessionRepository(db), service.NewTwoFactorService(userRepo, cfg), nil, cfg)
+		userService := service.NewUserService(userRepo, repository.NewAccessTokenRepository(db), authService)
 
 		// Create root user
 		ctx := context.Background()
@@ -74,6 +82,333 @@ var newAdminCmd = &cobra.Command{
 	},
 }
 
+var reset2FACmd = &cobra.Command{
+	Use:   "reset-2fa [email]",
+	Short: "Remove the two-factor authentication of a user",
+	Long: `Remove the two-factor authentication of a user who lost their device and
+recovery codes. If 2FA is required for them they enroll again on their next login.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		db, err := repository.NewMongoDB(cfg)
+		if err != nil {
+			fmt.Printf("Error connecting to MongoDB: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		ctx := context.Background()
+		userRepo := repository.NewUserRepository(db)
+		user, err := userRepo.FindByEmail(ctx, args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := service.NewTwoFactorService(userRepo, cfg).Reset(ctx, user); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Two-factor authentication removed for %s\n", user.Email)
+	},
+}
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock [email]",
+	Short: "Lift the login lockout of a user",
+	Long: `Lift the lockout of a user locked out after failed logins and forget their
+failed logins. Use --ip to also unlock the client IP they log in from.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ip, _ := cmd.Flags().GetString("ip")
+
+		cfg, db := connectDatabase()
+		defer db.Close()
+
+		ctx := context.Background()
+		protection := service.NewLoginProtectionService(repository.NewLoginAttemptRepository(db), repository.NewUserRepository(db), cfg, slog.Default())
+		if err := protection.Unlock(ctx, args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ip != "" {
+			if err := protection.UnlockIP(ctx, ip); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Login unlocked for %s\n", args[0])
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [project-slug]",
+	Short: "Export a project to a bundle archive",
+	Long:  `Export project settings, code, models, env keys, goals, actions, widgets and storage files to a zip bundle`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		includeData, _ := cmd.Flags().GetBool("data")
+		noFiles, _ := cmd.Flags().GetBool("no-files")
+		passphrase, _ := cmd.Flags().GetString("passphrase")
+
+		cfg, db := connectDatabase()
+		defer db.Close()
+
+		ctx := context.Background()
+		projectService, bundleService := newBundleServices(cfg, db)
+
+		project, err := projectService.GetBySlug(ctx, args[0])
+		if err != nil {
+			fmt.Printf("Error finding project: %v\n", err)
+			os.Exit(1)
+		}
+
+		if output == "" {
+			output = fmt.Sprintf("%s-%s.zip", project.Slug, time.Now().Format("20060102-150405"))
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Printf("Error creating file: %v\n", err)
+			os.Exit(1)
+		}
+
+		manifest, err := bundleService.Export(ctx, project.ID, &domain.ExportProjectRequest{
+			IncludeData:  includeData,
+			ExcludeFiles: noFiles,
+			Passphrase:   passphrase,
+		}, file)
+		file.Close()
+		if err != nil {
+			os.Remove(output)
+			fmt.Printf("Error exporting project: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Project exported: %s\n", output)
+		fmt.Printf("Branches: %d, releases: %d, models: %d, files: %d\n",
+			manifest.BranchesCount, manifest.ReleasesCount, manifest.ModelsCount, manifest.StorageFiles)
+		fmt.Printf("Env values: %s\n", manifest.EnvMode)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [bundle-file]",
+	Short: "Import a project from a bundle archive",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		slug, _ := cmd.Flags().GetString("slug")
+		name, _ := cmd.Flags().GetString("name")
+		ownerEmail, _ := cmd.Flags().GetString("owner")
+		passphrase, _ := cmd.Flags().GetString("passphrase")
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error opening file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			os.Exit(1)
+		}
+
+		cfg, db := connectDatabase()
+		defer db.Close()
+
+		ctx := context.Background()
+		_, bundleService := newBundleServices(cfg, db)
+
+		// Owner defaults to the root admin
+		userRepo := repository.NewUserRepository(db)
+		var owner *domain.User
+		if ownerEmail != "" {
+			owner, err = userRepo.FindByEmail(ctx, ownerEmail)
+		} else {
+			owner, err = userRepo.FindRootUser(ctx)
+		}
+		if err != nil {
+			fmt.Printf("Error finding owner: %v\n", err)
+			os.Exit(1)
+		}
+
+		result, err := bundleService.Import(ctx, file, info.Size(), &domain.ImportProjectRequest{
+			Name:       name,
+			Slug:       slug,
+			Passphrase: passphrase,
+		}, owner.ID)
+		if err != nil {
+			fmt.Printf("Error importing project: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Project imported successfully!\n")
+		fmt.Printf("Name: %s\n", result.Project.Name)
+		fmt.Printf("Slug: %s\n", result.Project.Slug)
+		fmt.Printf("ID: %s\n", result.Project.ID.Hex())
+		for _, warning := range result.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+	},
+}
+
+// connectDatabase loads the config and connects to MongoDB, exiting on failure
+func connectDatabase() (*config.Config, *repository.MongoDB) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err := repository.NewMongoDB(cfg)
+	if err != nil {
+		fmt.Printf("Error connecting to MongoDB: %v\n", err)
+		os.Exit(1)
+	}
+
+	return cfg, db
+}
+
+func newBundleServices(cfg *config.Config, db *repository.MongoDB) (*service.ProjectService, *service.BundleService) {
+	goalRepo := repository.NewGoalRepository(db)
+	widgetRepo := repository.NewWidgetRepository(db)
+	actionRepo := repository.NewActionRepository(db)
+
+	driver, err := storage.NewDriver(cfg)
+	if err != nil {
+		fmt.Printf("Error opening storage: %v\n", err)
+		os.Exit(1)
+	}
+	storageService := service.NewStorageService(cfg, driver)
+
+	projectService := service.NewProjectService(repository.NewProjectRepository(db), widgetRepo, storageService)
+	bundleService := service.NewBundleService(
+		projectService,
+		service.NewPipelineService(repository.NewPipelineRepository(db)),
+		service.NewModelService(repository.NewModelRepository(db)),
+		service.NewEnvironmentService(repository.NewEnvironmentRepository(db)),
+		service.NewGoalService(goalRepo, projectService),
+		service.NewActionService(actionRepo),
+		service.NewWidgetService(widgetRepo, goalRepo, actionRepo),
+		storageService,
+	)
+
+	return projectService, bundleService
+}
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage project file storage",
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy all project files to another storage driver",
+	Long: `Copy all project files from one storage driver to another, e.g. from local disk to S3.
+Both drivers are configured from the storage section of the config file. Cached resized
+images are skipped. Switch storage.driver in the config once the copy has finished.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if from == "" {
+			from = cfg.Storage.Driver
+		}
+		if from == to {
+			fmt.Printf("Error: source and target driver are both %q\n", to)
+			os.Exit(1)
+		}
+
+		src, err := storage.New(from, cfg.Storage)
+		if err != nil {
+			fmt.Printf("Error opening %s storage: %v\n", from, err)
+			os.Exit(1)
+		}
+		dst, err := storage.New(to, cfg.Storage)
+		if err != nil {
+			fmt.Printf("Error opening %s storage: %v\n", to, err)
+			os.Exit(1)
+		}
+
+		if dryRun {
+			var count int
+			var size int64
+			err = src.Walk("", func(info storage.ObjectInfo) error {
+				if !isResizeCacheKey(info.Key) {
+					count++
+					size += info.Size
+				}
+				return nil
+			})
+			if err != nil {
+				fmt.Printf("Error listing %s storage: %v\n", from, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Would copy %d files (%d bytes) from %s to %s\n", count, size, from, to)
+			return
+		}
+
+		count, size, err := storage.CopyAll(src, "", dst, "", isResizeCacheKey)
+		if err != nil {
+			fmt.Printf("Error after copying %d files: %v\n", count, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Copied %d files (%d bytes) from %s to %s\n", count, size, from, to)
+	},
+}
+
+// isResizeCacheKey reports whether a key belongs to the resized image cache
+// ({project-id}/tmp/resize/...), which is rebuilt on demand
+func isResizeCacheKey(key string) bool {
+	parts := strings.SplitN(key, "/", 4)
+	return len(parts) >= 3 && parts[1] == "tmp" && parts[2] == "resize"
+}
+
+var mockIdpCmd = &cobra.Command{
+	Use:   "mock-idp",
+	Short: "Run a mock OpenID Connect provider for SSO development",
+	Long: `Run an OpenID Connect provider that signs in whoever asks. Its login page
+lets you choose the email, name and groups of the user. Point oidc.issuer at
+http://<listen> and use any client ID. Never expose it to a network.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+		email, _ := cmd.Flags().GetString("email")
+		name, _ := cmd.Flags().GetString("name")
+		groups, _ := cmd.Flags().GetStringSlice("groups")
+
+		provider, err := oidc.NewMockProvider("http://"+listen, oidc.MockIdentity{
+			Subject: email,
+			Email:   email,
+			Name:    name,
+			Groups:  groups,
+		})
+		if err != nil {
+			fmt.Printf("Error creating provider: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Mock OpenID Connect provider at %s\n", provider.Issuer)
+		if err := http.ListenAndServe(listen, provider); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number",
@@ -123,10 +458,39 @@ func init() {
 
 	docsCmd.Flags().StringP("format", "f", "markdown", "Output format: markdown, typescript")
 
+	exportCmd.Flags().StringP("output", "o", "", "Output file (default: <slug>-<timestamp>.zip)")
+	exportCmd.Flags().Bool("data", false, "Include model data and goal stats")
+	exportCmd.Flags().Bool("no-files", false, "Skip storage files")
+	exportCmd.Flags().String("passphrase", "", "Encrypt env values with this passphrase (values are excluded without it)")
+
+	unlockCmd.Flags().String("ip", "", "Also unlock this client IP")
+
+	importCmd.Flags().String("slug", "", "Project slug (default: from bundle)")
+	importCmd.Flags().String("name", "", "Project name (default: from bundle)")
+	importCmd.Flags().String("owner", "", "Owner email (default: root admin)")
+	importCmd.Flags().String("passphrase", "", "Passphrase to decrypt env values")
+
+	storageMigrateCmd.Flags().String("from", "", "Source driver (default: storage.driver from the config)")
+	storageMigrateCmd.Flags().String("to", "", "Target driver: local, s3")
+	storageMigrateCmd.Flags().Bool("dry-run", false, "Only count the files that would be copied")
+	storageMigrateCmd.MarkFlagRequired("to")
+	storageCmd.AddCommand(storageMigrateCmd)
+
+	mockIdpCmd.Flags().String("listen", "127.0.0.1:9998", "Address to listen on, also the issuer")
+	mockIdpCmd.Flags().String("email", "dev@example.com", "Email prefilled on the login page")
+	mockIdpCmd.Flags().String("name", "Developer", "Name prefilled on the login page")
+	mockIdpCmd.Flags().StringSlice("groups", nil, "Groups prefilled on the login page")
+
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(newAdminCmd)
+	rootCmd.AddCommand(reset2FACmd)
+	rootCmd.AddCommand(unlockCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(docsCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(storageCmd)
+	rootCmd.AddCommand(mockIdpCmd)
 }
 
 func main() {
@@ -19,6 +19,7 @@ import (
 	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/runtime"
 	"github.com/levskiy0/m3m/internal/service"
+	"github.com/levskiy0/m3m/internal/storage"
 	"github.com/levskiy0/m3m/internal/websocket"
 	"github.com/levskiy0/m3m/web"
 )
@@ -44,16 +45,20 @@ func NewLogger(cfg *config.Config) *slog.Logger {
 	return slog.New(handler)
 }
 
-func NewGinEngine(cfg *config.Config) *gin.Engine {
+func NewGinEngine(cfg *config.Config) (*gin.Engine, error) {
 	if cfg.Logging.Level != "debug" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.New()
+	// Client IPs count login failures, X-Forwarded-For is only read from the configured proxies
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("server.trusted_proxies: %w", err)
+	}
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORS())
 
-	return r
+	return r, nil
 }
 
 func RegisterRoutes(
@@ -74,6 +79,9 @@ func RegisterRoutes(
 	wsHandler *handler.WebSocketHandler,
 	templateHandler *handler.TemplateHandler,
 	actionHandler *handler.ActionHandler,
+	alertHandler *handler.AlertHandler,
+	auditHandler *handler.AuditHandler,
+	webhookHandler *handler.WebhookHandler,
 ) {
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -107,6 +115,9 @@ func RegisterRoutes(
 	wsHandler.Register(api, authMiddleware)
 	templateHandler.Register(api, authMiddleware)
 	actionHandler.Register(api, authMiddleware)
+	alertHandler.Register(api, authMiddleware)
+	auditHandler.Register(api, authMiddleware)
+	webhookHandler.Register(api, authMiddleware)
 
 	// Public routes (at root level, not under /api)
 	runtimeHandler.RegisterPublicRoutes(r)
@@ -213,6 +224,62 @@ func StartServer(lc fx.Lifecycle, r *gin.Engine, cfg *config.Config, logger *slo
 	})
 }
 
+// StartLogJanitor periodically enforces log retention (rotation cleanup, compression, size and age limits)
+func StartLogJanitor(lc fx.Lifecycle, logger *slog.Logger, logService *service.LogService) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go logService.RunJanitor(ctx, func(err error) {
+				logger.Warn("Log retention failed", "error", err)
+			})
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
+// StartQuotas enables quota checks on storage and data writes and sends quota
+// warnings to the project's WebSocket subscribers and runtime log
+func StartQuotas(
+	quotaService *service.QuotaService,
+	storageService *service.StorageService,
+	modelService *service.ModelService,
+	logService *service.LogService,
+	broadcaster *websocket.Broadcaster,
+	runtimeManager *runtime.Manager,
+) {
+	storageService.SetQuota(quotaService)
+	modelService.SetQuota(quotaService)
+	logService.SetUsageReporter(quotaService.ReportUsage)
+
+	quotaService.OnWarning(broadcaster.BroadcastQuotaWarning)
+	quotaService.OnWarning(runtimeManager.LogQuotaWarning)
+}
+
+// StartStorageHooks forwards storage changes to the $hook.onStorage handlers of running projects
+func StartStorageHooks(storageService *service.StorageService, runtimeManager *runtime.Manager) {
+	storageService.OnChange(runtimeManager.TriggerStorageHook)
+}
+
+// StartStorageVersioning lets storage keep file versions in the directories projects mark as versioned
+func StartStorageVersioning(storageService *service.StorageService, projectService *service.ProjectService) {
+	storageService.SetVersioning(projectService)
+}
+
+// StartAlerts shows alerts sent to the toast channel to the project's WebSocket subscribers
+func StartAlerts(alertService *service.AlertService, broadcaster *websocket.Broadcaster) {
+	alertService.OnToast(broadcaster.BroadcastAlert)
+}
+
+// StartSessionRevocation closes the WebSocket connections of revoked sessions
+func StartSessionRevocation(authService *service.AuthService, hub *websocket.Hub) {
+	authService.OnRevoke(hub.CloseSessions)
+}
+
 // AutoStartRuntimes starts all projects that were running before shutdown
 // Projects that were running in debug mode (branch) are NOT auto-started
 func AutoStartRuntimes(
@@ -339,6 +406,9 @@ func New(configPath string) *fx.App {
 			// Database
 			repository.NewMongoDB,
 
+			// File storage
+			storage.NewDriver,
+
 			// Repositories
 			repository.NewUserRepository,
 			repository.NewProjectRepository,
@@ -348,6 +418,13 @@ func New(configPath string) *fx.App {
 			repository.NewModelRepository,
 			repository.NewWidgetRepository,
 			repository.NewActionRepository,
+			repository.NewAlertRepository,
+			repository.NewSessionRepository,
+			repository.NewAccessTokenRepository,
+			repository.NewLoginAttemptRepository,
+			repository.NewAuditRepository,
+			repository.NewProjectAuthRepository,
+			repository.NewWebhookRepository,
 
 			// Services
 			service.NewAuthService,
@@ -355,11 +432,23 @@ func New(configPath string) *fx.App {
 			service.NewProjectService,
 			service.NewGoalService,
 			service.NewPipelineService,
+			service.NewGitService,
 			service.NewEnvironmentService,
 			service.NewStorageService,
+			service.NewLogService,
+			service.NewQuotaService,
 			service.NewModelService,
 			service.NewWidgetService,
 			service.NewActionService,
+			service.NewBundleService,
+			service.NewAlertService,
+			service.NewAccessTokenService,
+			service.NewSSOService,
+			service.NewTwoFactorService,
+			service.NewLoginProtectionService,
+			service.NewAuditService,
+			service.NewProjectAuthService,
+			service.NewWebhookService,
 
 			// Runtime
 			runtime.NewManager,
@@ -386,7 +475,10 @@ func New(configPath string) *fx.App {
 			handler.NewWebSocketHandler,
 			handler.NewTemplateHandler,
 			handler.NewActionHandler,
+			handler.NewAlertHandler,
+			handler.NewAuditHandler,
+			handler.NewWebhookHandler,
 		),
-		fx.Invoke(RunMigrations, RegisterRoutes, StartServer, AutoStartRuntimes, StartWebSocket),
+		fx.Invoke(RunMigrations, RegisterRoutes, StartServer, AutoStartRuntimes, StartWebSocket, StartLogJanitor, StartQuotas, StartStorageHooks, StartStorageVersioning, StartAlerts, StartSessionRevocation),
 	)
 }
@@ -12,21 +12,27 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	MongoDB  MongoDBConfig  `mapstructure:"mongodb"`
-	SQLite   SQLiteConfig   `mapstructure:"sqlite"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Storage  StorageConfig  `mapstructure:"storage"`
-	Runtime  RuntimeConfig  `mapstructure:"runtime"`
-	Plugins  PluginsConfig  `mapstructure:"plugins"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	MongoDB   MongoDBConfig   `mapstructure:"mongodb"`
+	SQLite    SQLiteConfig    `mapstructure:"sqlite"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Runtime   RuntimeConfig   `mapstructure:"runtime"`
+	Plugins   PluginsConfig   `mapstructure:"plugins"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
+	OIDC      OIDCConfig      `mapstructure:"oidc"`
+	TwoFactor TwoFactorConfig `mapstructure:"two_factor"`
+	Login     LoginConfig     `mapstructure:"login"`
+	Alerts    AlertsConfig    `mapstructure:"alerts"`
 }
 
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
-	URI  string `mapstructure:"uri"`
+	Host           string   `mapstructure:"host"`
+	Port           int      `mapstructure:"port"`
+	URI            string   `mapstructure:"uri"`
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // Addresses or CIDRs of reverse proxies whose X-Forwarded-For is believed
 }
 
 type DatabaseConfig struct {
@@ -44,17 +50,35 @@ type MongoDBConfig struct {
 }
 
 type JWTConfig struct {
-	Secret     string        `mapstructure:"secret"`
-	Expiration time.Duration `mapstructure:"expiration"`
+	Secret           string        `mapstructure:"secret"`
+	Expiration       time.Duration `mapstructure:"expiration"`        // Sessions end after this long without a refresh
+	AccessExpiration time.Duration `mapstructure:"access_expiration"` // Lifetime of access tokens
 }
 
 type StorageConfig struct {
-	Path string `mapstructure:"path"`
+	Driver        string   `mapstructure:"driver"`          // "local" or "s3"
+	Path          string   `mapstructure:"path"`            // Root of the local driver
+	SigningSecret string   `mapstructure:"signing_secret"`  // HMAC key for signed CDN URLs, derived from jwt.secret when empty
+	ResizeCacheMB int      `mapstructure:"resize_cache_mb"` // Cap for transformed CDN images, least recently used are evicted, 0 = unlimited
+	S3            S3Config `mapstructure:"s3"`
+}
+
+// S3Config configures the S3-compatible storage driver (AWS S3, MinIO, R2, ...)
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://127.0.0.1:9000"
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Prefix    string `mapstructure:"prefix"`     // Key prefix inside the bucket
+	PathStyle bool   `mapstructure:"path_style"` // endpoint/bucket/key URLs instead of bucket.endpoint/key (MinIO)
 }
 
 type RuntimeConfig struct {
 	WorkerPoolSize int           `mapstructure:"worker_pool_size"`
 	Timeout        time.Duration `mapstructure:"timeout"`
+	BootTimeout    time.Duration `mapstructure:"boot_timeout"`  // Max time for a replacement runtime to boot on restart
+	DrainTimeout   time.Duration `mapstructure:"drain_timeout"` // Max time to wait for in-flight requests of the replaced runtime
 }
 
 type PluginsConfig struct {
@@ -63,8 +87,70 @@ type PluginsConfig struct {
 }
 
 type LoggingConfig struct {
-	Level string `mapstructure:"level"`
-	Path  string `mapstructure:"path"`
+	Level           string        `mapstructure:"level"`
+	Path            string        `mapstructure:"path"`
+	MaxFileSizeMB   int           `mapstructure:"max_file_size_mb"`  // Rotate a project log file above this size (0 = never)
+	MaxTotalSizeMB  int           `mapstructure:"max_total_size_mb"` // Per-project cap for all log files (0 = unlimited)
+	MaxAge          time.Duration `mapstructure:"max_age"`           // Delete log files not written for this long (0 = keep)
+	Compress        bool          `mapstructure:"compress"`          // Gzip rotated and finished log files
+	JanitorInterval time.Duration `mapstructure:"janitor_interval"`  // How often retention is enforced
+}
+
+// QuotaConfig holds the default per-project quotas, 0 means unlimited
+type QuotaConfig struct {
+	StorageMB   int   `mapstructure:"storage_mb"`   // Files in project storage
+	DataMB      int   `mapstructure:"data_mb"`      // Data collections on disk
+	Documents   int64 `mapstructure:"documents"`    // Documents across all data collections
+	LogMB       int   `mapstructure:"log_mb"`       // Current and rotated log files
+	WarnPercent int   `mapstructure:"warn_percent"` // Warn the project above this share of a quota
+}
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider
+type OIDCConfig struct {
+	Enabled              bool              `mapstructure:"enabled"`
+	Name                 string            `mapstructure:"name"` // Label of the login button
+	Issuer               string            `mapstructure:"issuer"`
+	ClientID             string            `mapstructure:"client_id"`
+	ClientSecret         string            `mapstructure:"client_secret"` // Empty for public clients, PKCE is always used
+	RedirectURI          string            `mapstructure:"redirect_uri"`  // Defaults to server.uri + /api/auth/oidc/callback
+	Scopes               []string          `mapstructure:"scopes"`
+	GroupsClaim          string            `mapstructure:"groups_claim"`           // Claim listing the groups of the user
+	AutoCreate           bool              `mapstructure:"auto_create"`            // Create users on their first login
+	DisablePasswordLogin bool              `mapstructure:"disable_password_login"` // Only root users may still log in with a password
+	Groups               []OIDCGroupConfig `mapstructure:"groups"`
+}
+
+// OIDCGroupConfig maps a group of the provider to permissions and project
+// roles, granted on every login. Users in several groups get the union.
+type OIDCGroupConfig struct {
+	Group          string            `mapstructure:"group"`
+	CreateProjects bool              `mapstructure:"create_projects"`
+	ManageUsers    bool              `mapstructure:"manage_users"`
+	Projects       map[string]string `mapstructure:"projects"` // Project slug to role
+}
+
+// TwoFactorConfig configures TOTP two-factor authentication
+type TwoFactorConfig struct {
+	Issuer   string `mapstructure:"issuer"`   // Account label in authenticator apps
+	Required string `mapstructure:"required"` // "none", "root" or "all"; admins can also require it per user
+}
+
+// LoginConfig configures brute-force protection of the login. Failures are
+// counted per account and per client IP; 0 turns a limit off.
+type LoginConfig struct {
+	DelayAfter      int           `mapstructure:"delay_after"`      // Failures before each further attempt has to wait, doubling up to max_delay
+	MaxDelay        time.Duration `mapstructure:"max_delay"`        // Longest wait between attempts
+	LockoutAfter    int           `mapstructure:"lockout_after"`    // Failures of an account before it is locked
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"` // How long accounts and IPs stay locked
+	IPLockoutAfter  int           `mapstructure:"ip_lockout_after"` // Failures from an IP before it is locked
+	Window          time.Duration `mapstructure:"window"`           // Failures older than this are forgotten
+	BlockAfter      int           `mapstructure:"block_after"`      // Lockouts in a row before an account is blocked until an admin unblocks it, never root
+	EventRetention  time.Duration `mapstructure:"event_retention"`  // How long failed login events are kept
+}
+
+// AlertsConfig restricts where alert notifications may be sent
+type AlertsConfig struct {
+	EmailAllowlist []string `mapstructure:"email_allowlist"` // Addresses or "@domain" entries allowed besides project members
 }
 
 // generateJWTSecret generates a random 32-byte hex string for JWT signing
@@ -85,6 +171,7 @@ func createDefaultConfig(path string) error {
   host: "0.0.0.0"
   port: 3000
   uri: "http://127.0.0.1:3000"
+  trusted_proxies: []  # reverse proxies whose X-Forwarded-For is believed, e.g. ["127.0.0.1"]
 
 database:
   driver: "sqlite"  # "mongodb" or "sqlite"
@@ -99,14 +186,19 @@ sqlite:
 
 jwt:
   secret: "%s"
-  expiration: 168h
+  expiration: 168h        # sessions end after this long without a refresh
+  access_expiration: 15m  # lifetime of access tokens
 
 storage:
+  driver: "local"  # "local" or "s3"
   path: "./storage"
+  resize_cache_mb: 1024
 
 runtime:
   worker_pool_size: 10
   timeout: 30s
+  boot_timeout: 60s
+  drain_timeout: 30s
 
 plugins:
   path: "./plugins"
@@ -114,6 +206,47 @@ plugins:
 logging:
   level: "info"
   path: "./logs"
+  max_file_size_mb: 10
+  max_total_size_mb: 100
+  max_age: 168h
+  compress: true
+  janitor_interval: 5m
+
+quota:
+  storage_mb: 0
+  data_mb: 0
+  documents: 0
+  log_mb: 0
+  warn_percent: 80
+
+oidc:
+  enabled: false
+  name: "SSO"
+  issuer: ""  # e.g. "https://accounts.google.com", or "http://127.0.0.1:9998" for m3m mock-idp
+  client_id: ""
+  client_secret: ""
+  scopes: ["openid", "email", "profile"]
+  groups_claim: "groups"
+  auto_create: true
+  disable_password_login: false
+  groups: []
+
+two_factor:
+  issuer: "M3M"
+  required: "none"  # "none", "root" or "all"
+
+login:
+  delay_after: 3
+  max_delay: 30s
+  lockout_after: 10
+  lockout_duration: 15m
+  ip_lockout_after: 50
+  window: 1h
+  block_after: 0  # lockouts in a row before the account is blocked, 0 = never
+  event_retention: 720h
+
+alerts:
+  email_allowlist: []  # e.g. ["ops@example.com", "@example.com"]; project members are always allowed
 `, jwtSecret)
 
 	return os.WriteFile(path, []byte(content), 0644)
@@ -139,18 +272,45 @@ func Load(path string) (*Config, error) {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 3000)
 	viper.SetDefault("server.uri", "http://127.0.0.1:3000")
+	viper.SetDefault("server.trusted_proxies", []string{})
 	viper.SetDefault("database.driver", "sqlite")
 	viper.SetDefault("mongodb.uri", "mongodb://localhost:27017")
 	viper.SetDefault("mongodb.database", "m3m")
 	viper.SetDefault("sqlite.path", "./data")
 	viper.SetDefault("sqlite.database", "m3m")
 	viper.SetDefault("jwt.expiration", "168h")
+	viper.SetDefault("jwt.access_expiration", "15m")
+	viper.SetDefault("storage.driver", "local")
 	viper.SetDefault("storage.path", "./storage")
+	viper.SetDefault("storage.resize_cache_mb", 1024)
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.s3.path_style", true)
 	viper.SetDefault("runtime.worker_pool_size", 10)
 	viper.SetDefault("runtime.timeout", "30s")
+	viper.SetDefault("runtime.boot_timeout", "60s")
+	viper.SetDefault("runtime.drain_timeout", "30s")
 	viper.SetDefault("plugins.path", "./plugins")
 	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.path", "./logs")
+	viper.SetDefault("logging.max_file_size_mb", 10)
+	viper.SetDefault("logging.max_total_size_mb", 100)
+	viper.SetDefault("logging.max_age", "168h")
+	viper.SetDefault("logging.compress", true)
+	viper.SetDefault("logging.janitor_interval", "5m")
+	viper.SetDefault("quota.warn_percent", 80)
+	viper.SetDefault("oidc.name", "SSO")
+	viper.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("oidc.groups_claim", "groups")
+	viper.SetDefault("oidc.auto_create", true)
+	viper.SetDefault("two_factor.issuer", "M3M")
+	viper.SetDefault("two_factor.required", "none")
+	viper.SetDefault("login.delay_after", 3)
+	viper.SetDefault("login.max_delay", "30s")
+	viper.SetDefault("login.lockout_after", 10)
+	viper.SetDefault("login.lockout_duration", "15m")
+	viper.SetDefault("login.ip_lockout_after", 50)
+	viper.SetDefault("login.window", "1h")
+	viper.SetDefault("login.event_retention", "720h")
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -165,5 +325,9 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.OIDC.RedirectURI == "" {
+		cfg.OIDC.RedirectURI = strings.TrimSuffix(cfg.Server.URI, "/") + "/api/auth/oidc/callback"
+	}
+
 	return &cfg, nil
 }
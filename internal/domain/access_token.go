@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessTokenPrefix starts every personal access token, telling them apart from JWTs
+const AccessTokenPrefix = "m3m_pat_"
+
+// AccessToken is a personal access token for automation. It acts as its user
+// on project routes, limited to its scopes and projects; only its hash is stored.
+type AccessToken struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Name       string               `bson:"name" json:"name"`
+	Hint       string               `bson:"hint" json:"hint"` // Start of the token, to tell tokens apart
+	Hash       string               `bson:"hash" json:"-"`
+	Scopes     []ProjectPermission  `bson:"scopes" json:"scopes"`
+	ProjectIDs []primitive.ObjectID `bson:"project_ids,omitempty" json:"project_ids"` // Empty allows every project of the user
+	ExpiresAt  *time.Time           `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time           `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string               `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"`
+}
+
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// AllowsProject reports whether the token may be used on a project
+func (t *AccessToken) AllowsProject(projectID primitive.ObjectID) bool {
+	return len(t.ProjectIDs) == 0 || slices.Contains(t.ProjectIDs, projectID)
+}
+
+// Allows reports whether the token grants a permission on a project. The
+// role of its user in the project must allow it as well.
+func (t *AccessToken) Allows(projectID primitive.ObjectID, permission ProjectPermission) bool {
+	return t.AllowsProject(projectID) && slices.Contains(t.Scopes, permission)
+}
+
+type CreateAccessTokenRequest struct {
+	Name       string              `json:"name" binding:"required"`
+	Scopes     []ProjectPermission `json:"scopes" binding:"required,min=1"`
+	ProjectIDs []string            `json:"project_ids"`
+	ExpiresAt  *time.Time          `json:"expires_at"` // Never expires when empty
+}
+
+type CreateAccessTokenResponse struct {
+	Token       string       `json:"token"` // Shown only once
+	AccessToken *AccessToken `json:"access_token"`
+}
@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AlertSource string
+
+const (
+	AlertSourceGoal    AlertSource = "goal"    // Current value of a goal
+	AlertSourceRuntime AlertSource = "runtime" // A runtime metric of the running project
+)
+
+// Runtime metrics alert rules can watch
+const (
+	AlertMetricMemoryMB       = "memory_mb"
+	AlertMetricCPUPercent     = "cpu_percent"
+	AlertMetricStorageMB      = "storage_mb"
+	AlertMetricDatabaseMB     = "database_mb"
+	AlertMetricRequestsPerMin = "requests_per_min"
+)
+
+// AlertMetrics lists the runtime metrics alert rules can watch
+var AlertMetrics = []string{AlertMetricMemoryMB, AlertMetricCPUPercent, AlertMetricStorageMB, AlertMetricDatabaseMB, AlertMetricRequestsPerMin}
+
+type AlertOperator string
+
+const (
+	AlertAbove        AlertOperator = ">"
+	AlertAboveOrEqual AlertOperator = ">="
+	AlertBelow        AlertOperator = "<"
+	AlertBelowOrEqual AlertOperator = "<="
+	AlertEqual        AlertOperator = "=="
+	AlertNotEqual     AlertOperator = "!="
+)
+
+// Compare reports whether value op threshold holds
+func (op AlertOperator) Compare(value, threshold float64) bool {
+	switch op {
+	case AlertAbove:
+		return value > threshold
+	case AlertAboveOrEqual:
+		return value >= threshold
+	case AlertBelow:
+		return value < threshold
+	case AlertBelowOrEqual:
+		return value <= threshold
+	case AlertEqual:
+		return value == threshold
+	case AlertNotEqual:
+		return value != threshold
+	}
+	return false
+}
+
+func (op AlertOperator) Valid() bool {
+	switch op {
+	case AlertAbove, AlertAboveOrEqual, AlertBelow, AlertBelowOrEqual, AlertEqual, AlertNotEqual:
+		return true
+	}
+	return false
+}
+
+type AlertChannelType string
+
+const (
+	AlertChannelEmail   AlertChannelType = "email"   // Sent with the project's SMTP_* environment settings
+	AlertChannelWebhook AlertChannelType = "webhook" // JSON POST of the alert event
+	AlertChannelToast   AlertChannelType = "toast"   // In-app toast for users viewing the project
+)
+
+// AlertChannel is where notifications of a rule go
+type AlertChannel struct {
+	Type   AlertChannelType `bson:"type" json:"type"`
+	Target string           `bson:"target,omitempty" json:"target,omitempty"` // Comma separated addresses, or the webhook URL
+	Secret string           `bson:"secret,omitempty" json:"secret,omitempty"` // Signs webhook bodies as X-M3M-Signature (hex HMAC-SHA256)
+}
+
+// AlertState tracks the evaluation of a rule between checks
+type AlertState struct {
+	Firing       bool       `bson:"firing" json:"firing"`
+	PendingSince *time.Time `bson:"pending_since,omitempty" json:"pending_since,omitempty"` // Condition holds since, waiting for For
+	LastValue    *float64   `bson:"last_value,omitempty" json:"last_value,omitempty"`
+	LastChecked  *time.Time `bson:"last_checked,omitempty" json:"last_checked,omitempty"`
+	FiredAt      *time.Time `bson:"fired_at,omitempty" json:"fired_at,omitempty"`
+	ResolvedAt   *time.Time `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
+
+// AlertRule watches a goal or runtime metric of a project, e.g.
+// "daily_counter signups < 5 after 18:00" or "memory_mb > 512 for 5m"
+type AlertRule struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ProjectID     primitive.ObjectID  `bson:"project_id" json:"project_id"`
+	Name          string              `bson:"name" json:"name"`
+	Source        AlertSource         `bson:"source" json:"source"`
+	GoalID        *primitive.ObjectID `bson:"goal_id,omitempty" json:"goal_id,omitempty"`
+	Labels        map[string]string   `bson:"labels,omitempty" json:"labels,omitempty"` // Goal label filters
+	Metric        string              `bson:"metric,omitempty" json:"metric,omitempty"` // Runtime metric
+	Operator      AlertOperator       `bson:"operator" json:"operator"`
+	Threshold     float64             `bson:"threshold" json:"threshold"`
+	ForSeconds    int                 `bson:"for_seconds" json:"for_seconds"`                     // How long the condition must hold before firing
+	CheckAfter    string              `bson:"check_after,omitempty" json:"check_after,omitempty"` // "HH:MM" in the project timezone, the condition is ignored earlier in the day
+	Channels      []AlertChannel      `bson:"channels" json:"channels"`
+	Enabled       bool                `bson:"enabled" json:"enabled"`
+	SilencedUntil *time.Time          `bson:"silenced_until,omitempty" json:"silenced_until,omitempty"`
+	State         AlertState          `bson:"state" json:"state"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+type AlertStatus string
+
+const (
+	AlertFiring   AlertStatus = "firing"
+	AlertResolved AlertStatus = "resolved"
+)
+
+// Silenced reports whether notifications of the rule are muted at now
+func (r *AlertRule) Silenced(now time.Time) bool {
+	return r.SilencedUntil != nil && now.Before(*r.SilencedUntil)
+}
+
+// Evaluate records a checked value in the rule state and returns the status
+// the rule moved to, or "" when it did not change. now is expected in the
+// project timezone.
+func (r *AlertRule) Evaluate(value float64, now time.Time) AlertStatus {
+	r.State.LastValue = &value
+	r.State.LastChecked = &now
+
+	matches := r.Operator.Compare(value, r.Threshold)
+	if r.CheckAfter != "" && now.Format("15:04") < r.CheckAfter {
+		matches = false
+	}
+
+	if !matches {
+		r.State.PendingSince = nil
+		if r.State.Firing {
+			r.State.Firing = false
+			r.State.ResolvedAt = &now
+			return AlertResolved
+		}
+		return ""
+	}
+
+	if r.State.PendingSince == nil {
+		r.State.PendingSince = &now
+	}
+	if !r.State.Firing && now.Sub(*r.State.PendingSince) >= time.Duration(r.ForSeconds)*time.Second {
+		r.State.Firing = true
+		r.State.FiredAt = &now
+		return AlertFiring
+	}
+	return ""
+}
+
+// Describe returns a one-line description of the condition, e.g. "memory_mb > 512 for 5m0s"
+func (r *AlertRule) Describe(subject string) string {
+	text := fmt.Sprintf("%s %s %g", subject, r.Operator, r.Threshold)
+	if r.ForSeconds > 0 {
+		text += fmt.Sprintf(" for %s", time.Duration(r.ForSeconds)*time.Second)
+	}
+	if r.CheckAfter != "" {
+		text += " after " + r.CheckAfter
+	}
+	return text
+}
+
+// AlertEvent is an entry of the alert history
+type AlertEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RuleID    primitive.ObjectID `bson:"rule_id" json:"rule_id"`
+	ProjectID primitive.ObjectID `bson:"project_id" json:"project_id"`
+	RuleName  string             `bson:"rule_name" json:"rule_name"`
+	Status    AlertStatus        `bson:"status" json:"status"`
+	Value     float64            `bson:"value" json:"value"`
+	Message   string             `bson:"message" json:"message"`
+	Silenced  bool               `bson:"silenced" json:"silenced"`                 // Notifications were muted
+	Notified  []AlertChannelType `bson:"notified" json:"notified"`                 // Channels that were notified
+	Errors    []string           `bson:"errors,omitempty" json:"errors,omitempty"` // Failed notifications
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type CreateAlertRuleRequest struct {
+	Name       string            `json:"name" binding:"required"`
+	Source     AlertSource       `json:"source" binding:"required,oneof=goal runtime"`
+	GoalID     string            `json:"goal_id"`
+	Labels     map[string]string `json:"labels"`
+	Metric     string            `json:"metric"`
+	Operator   AlertOperator     `json:"operator" binding:"required"`
+	Threshold  float64           `json:"threshold"`
+	ForSeconds int               `json:"for_seconds" binding:"min=0"`
+	CheckAfter string            `json:"check_after"`
+	Channels   []AlertChannel    `json:"channels"`
+	Enabled    *bool             `json:"enabled"` // Defaults to true
+}
+
+type UpdateAlertRuleRequest struct {
+	Name       *string            `json:"name"`
+	Labels     *map[string]string `json:"labels"`
+	Operator   *AlertOperator     `json:"operator"`
+	Threshold  *float64           `json:"threshold"`
+	ForSeconds *int               `json:"for_seconds" binding:"omitempty,min=0"`
+	CheckAfter *string            `json:"check_after"`
+	Channels   *[]AlertChannel    `json:"channels"`
+	Enabled    *bool              `json:"enabled"`
+}
+
+// SilenceAlertRequest mutes a rule for a number of minutes, or until a time
+type SilenceAlertRequest struct {
+	Minutes int        `json:"minutes" binding:"min=0"`
+	Until   *time.Time `json:"until"`
+}
+
+type AlertHistoryQuery struct {
+	RuleID string `form:"rule_id"`
+	Limit  int    `form:"limit"`
+}
+
+// RuntimeSample is a reading of the runtime metrics of a running project
+type RuntimeSample struct {
+	MemoryBytes   uint64
+	CPUPercent    float64
+	StorageBytes  int64
+	DatabaseBytes int64
+	TotalRequests int64
+}
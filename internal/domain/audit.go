@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions, named "<target>.<verb>"
+const (
+	AuditBranchCreate      = "branch.create"
+	AuditBranchUpdate      = "branch.update"
+	AuditBranchReset       = "branch.reset"
+	AuditBranchDelete      = "branch.delete"
+	AuditFileCreate        = "file.create"
+	AuditFileUpdate        = "file.update"
+	AuditFileDelete        = "file.delete"
+	AuditFileRename        = "file.rename"
+	AuditReleaseCreate     = "release.create"
+	AuditReleaseDelete     = "release.delete"
+	AuditReleaseActivate   = "release.activate"
+	AuditGitPush           = "git.push"
+	AuditGitPull           = "git.pull"
+	AuditEnvCreate         = "env.create"
+	AuditEnvUpdate         = "env.update"
+	AuditEnvDelete         = "env.delete"
+	AuditModelCreate       = "model.create"
+	AuditModelUpdate       = "model.update"
+	AuditModelDelete       = "model.delete"
+	AuditDataCreate        = "data.create"
+	AuditDataUpdate        = "data.update"
+	AuditDataDelete        = "data.delete"
+	AuditDataBulkDelete    = "data.bulk_delete"
+	AuditProjectCreate     = "project.create"
+	AuditProjectImport     = "project.import"
+	AuditProjectUpdate     = "project.update"
+	AuditProjectDelete     = "project.delete"
+	AuditProjectKey        = "project.regenerate_key"
+	AuditProjectExport     = "project.export"
+	AuditProjectQuota      = "project.quota"
+	AuditMemberAdd         = "member.add"
+	AuditMemberRole        = "member.role"
+	AuditMemberRemove      = "member.remove"
+	AuditUserCreate        = "user.create"
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserBlock         = "user.block"
+	AuditUserUnblock       = "user.unblock"
+	AuditUserUnlock        = "user.unlock"
+	AuditUserUnlockIP      = "user.unlock_ip"
+	AuditUser2FAReset      = "user.2fa_reset"
+	AuditUserSSOLink       = "user.sso_link"
+	AuditProfileUpdate     = "profile.update"
+	AuditPasswordChange    = "profile.password"
+	Audit2FAEnable         = "profile.2fa_enable"
+	Audit2FADisable        = "profile.2fa_disable"
+	AuditRecoveryCodes     = "profile.recovery_codes"
+	AuditTokenCreate       = "token.create"
+	AuditTokenRevoke       = "token.revoke"
+	AuditStorageMkdir      = "storage.mkdir"
+	AuditStorageUpload     = "storage.upload"
+	AuditStorageCreate     = "storage.create"
+	AuditStorageUpdate     = "storage.update"
+	AuditStorageRename     = "storage.rename"
+	AuditStorageDelete     = "storage.delete"
+	AuditStorageVisibility = "storage.visibility"
+	AuditStorageSign       = "storage.sign"
+	AuditStorageVersioning = "storage.versioning"
+	AuditStorageRestore    = "storage.restore"
+	AuditStoragePurge      = "storage.purge"
+	AuditRuntimeStart      = "runtime.start"
+	AuditRuntimeStop       = "runtime.stop"
+	AuditRuntimeRestart    = "runtime.restart"
+	AuditWebhookReplay     = "webhook.replay"
+)
+
+// AuditEntry records an administrative action. The audit log is append-only:
+// entries are never changed or deleted through the API.
+type AuditEntry struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ActorID    primitive.ObjectID  `bson:"actor_id" json:"actor_id"`
+	ActorEmail string              `bson:"actor_email" json:"actor_email"`
+	TokenID    *primitive.ObjectID `bson:"token_id,omitempty" json:"token_id,omitempty"` // Personal access token the action was made with
+	IP         string              `bson:"ip" json:"ip"`
+	ProjectID  *primitive.ObjectID `bson:"project_id,omitempty" json:"project_id,omitempty"` // Empty for actions outside projects
+	Action     string              `bson:"action" json:"action"`
+	Target     string              `bson:"target" json:"target"` // What was acted on, e.g. a release version, env key or file path
+	Changes    []AuditChange       `bson:"changes,omitempty" json:"changes,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// AuditChange is a field changed by an action, with its JSON values. Before
+// is empty for added fields, After for removed ones.
+type AuditChange struct {
+	Path   string          `bson:"path" json:"path"` // Dotted, e.g. "permissions.manage_users"
+	Before json.RawMessage `bson:"before,omitempty" json:"before,omitempty"`
+	After  json.RawMessage `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditActor is who performed an action
+type AuditActor struct {
+	UserID  primitive.ObjectID
+	Email   string
+	TokenID *primitive.ObjectID
+	IP      string
+}
+
+// AuditFilter selects audit entries, empty fields match everything
+type AuditFilter struct {
+	ProjectID *primitive.ObjectID
+	ActorID   *primitive.ObjectID
+	Action    string // An action, or a target like "release" for all its actions
+	From      *time.Time
+	To        *time.Time
+	Limit     int64
+}
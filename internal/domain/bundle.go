@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"time"
+)
+
+// BundleFormatVersion is the current project bundle format version
+const BundleFormatVersion = 1
+
+type BundleEnvMode string
+
+const (
+	BundleEnvExcluded  BundleEnvMode = "excluded"  // Only keys and types are exported
+	BundleEnvEncrypted BundleEnvMode = "encrypted" // Values are encrypted with a passphrase
+)
+
+// BundleManifest describes the contents of a project bundle archive
+type BundleManifest struct {
+	FormatVersion int           `json:"format_version"`
+	ExportedAt    time.Time     `json:"exported_at"`
+	Project       BundleProject `json:"project"`
+	IncludesData  bool          `json:"includes_data"`
+	IncludesFiles bool          `json:"includes_files"`
+	EnvMode       BundleEnvMode `json:"env_mode"`
+	ModelsCount   int           `json:"models_count"`
+	BranchesCount int           `json:"branches_count"`
+	ReleasesCount int           `json:"releases_count"`
+	StorageFiles  int           `json:"storage_files"`
+	StorageBytes  int64         `json:"storage_bytes"`
+}
+
+// BundleProject holds project settings stored in a bundle
+type BundleProject struct {
+	Name          string        `json:"name"`
+	Slug          string        `json:"slug"`
+	Color         string        `json:"color"`
+	AutoStart     bool          `json:"auto_start"`
+	ActiveRelease string        `json:"active_release"`
+	LogRetention  *LogRetention `json:"log_retention,omitempty"`
+	Timezone      string        `json:"timezone,omitempty"`
+}
+
+// BundleBranch is a branch in a bundle
+type BundleBranch struct {
+	Name  string     `json:"name"`
+	Files []CodeFile `json:"files"`
+}
+
+// BundleRelease is a release in a bundle
+type BundleRelease struct {
+	Version   string     `json:"version"`
+	Comment   string     `json:"comment"`
+	Tag       ReleaseTag `json:"tag"`
+	IsActive  bool       `json:"is_active"`
+	Files     []CodeFile `json:"files"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BundleEnvVar is an environment variable in a bundle (value omitted unless encrypted separately)
+type BundleEnvVar struct {
+	Key   string     `json:"key"`
+	Type  EnvVarType `json:"type"`
+	Order int        `json:"order"`
+}
+
+// BundleGoal is a project goal in a bundle, with the project prefix removed from the slug
+type BundleGoal struct {
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Color       string     `json:"color"`
+	Type        GoalType   `json:"type"`
+	Description string     `json:"description"`
+	GridSpan    int        `json:"gridSpan"`
+	ShowTotal   bool       `json:"showTotal"`
+	Order       int        `json:"order"`
+	Buckets     []float64  `json:"buckets,omitempty"`
+	Stats       []GoalStat `json:"stats,omitempty"`
+}
+
+// BundleWidget is a dashboard widget in a bundle, referencing goals and actions by slug
+type BundleWidget struct {
+	Type       WidgetType    `json:"type"`
+	GoalSlug   string        `json:"goal_slug,omitempty"`
+	GlobalGoal bool          `json:"global_goal,omitempty"` // GoalSlug refers to a global goal
+	ActionSlug string        `json:"action_slug,omitempty"`
+	Variant    WidgetVariant `json:"variant"`
+	GridSpan   int           `json:"gridSpan"`
+	Order      int           `json:"order"`
+}
+
+// ExportProjectRequest controls what goes into a project bundle
+type ExportProjectRequest struct {
+	IncludeData  bool   `json:"include_data"`  // Export model data collections and goal stats
+	ExcludeFiles bool   `json:"exclude_files"` // Skip storage files
+	Passphrase   string `json:"passphrase"`    // Encrypt env var values with this passphrase (excluded if empty)
+}
+
+// ImportProjectRequest controls how a bundle is imported
+type ImportProjectRequest struct {
+	Name       string `json:"name" form:"name"` // Overrides the project name from the bundle
+	Slug       string `json:"slug" form:"slug"` // Overrides the project slug from the bundle
+	Passphrase string `json:"passphrase" form:"passphrase"`
+}
+
+// ImportProjectResult is returned after a bundle import
+type ImportProjectResult struct {
+	Project  *Project `json:"project"`
+	Warnings []string `json:"warnings"`
+}
@@ -11,8 +11,27 @@ type GoalType string
 const (
 	GoalTypeCounter      GoalType = "counter"
 	GoalTypeDailyCounter GoalType = "daily_counter"
+	GoalTypeGauge        GoalType = "gauge"     // Last recorded value
+	GoalTypeSum          GoalType = "sum"       // Sum of recorded values
+	GoalTypeAverage      GoalType = "avg"       // Average of recorded values
+	GoalTypeHistogram    GoalType = "histogram" // Distribution with percentiles, e.g. latencies
 )
 
+// IsMetric reports whether goals of this type record float samples with
+// $goals.record instead of being incremented
+func (t GoalType) IsMetric() bool {
+	switch t {
+	case GoalTypeGauge, GoalTypeSum, GoalTypeAverage, GoalTypeHistogram:
+		return true
+	}
+	return false
+}
+
+// Valid reports whether t is a known goal type
+func (t GoalType) Valid() bool {
+	return t == GoalTypeCounter || t == GoalTypeDailyCounter || t.IsMetric()
+}
+
 type Goal struct {
 	ID              primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	Name            string               `bson:"name" json:"name"`
@@ -25,28 +44,37 @@ type Goal struct {
 	GridSpan        int                  `bson:"grid_span" json:"gridSpan"`
 	ShowTotal       bool                 `bson:"show_total" json:"showTotal"`
 	Order           int                  `bson:"order" json:"order"`
+	Buckets         []float64            `bson:"buckets,omitempty" json:"buckets,omitempty"` // Histogram bucket upper bounds
 	CreatedAt       time.Time            `bson:"created_at" json:"created_at"`
 	UpdatedAt       time.Time            `bson:"updated_at" json:"updated_at"`
 }
 
+// GoalStat is the value of a goal for a date ("total" for counters), an hour
+// of the date and a set of labels. Value counts increments, or samples for
+// metric goals whose aggregates are kept in Metric.
 type GoalStat struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	GoalID    primitive.ObjectID `bson:"goal_id" json:"goal_id"`
 	ProjectID primitive.ObjectID `bson:"project_id" json:"project_id"`
 	Date      string             `bson:"date" json:"date"`
+	Hour      *int               `bson:"hour,omitempty" json:"hour,omitempty"`
+	Labels    map[string]string  `bson:"labels,omitempty" json:"labels,omitempty"`
+	LabelKey  string             `bson:"label_key,omitempty" json:"-"`
 	Value     int64              `bson:"value" json:"value"`
+	Metric    *GoalMetric        `bson:"metric,omitempty" json:"metric,omitempty"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 type CreateGoalRequest struct {
-	Name            string   `json:"name" binding:"required"`
-	Slug            string   `json:"slug" binding:"required"`
-	Color           string   `json:"color"`
-	Type            GoalType `json:"type" binding:"required"`
-	Description     string   `json:"description"`
-	AllowedProjects []string `json:"allowed_projects"`
-	GridSpan        int      `json:"gridSpan"`
-	ShowTotal       bool     `json:"showTotal"`
+	Name            string    `json:"name" binding:"required"`
+	Slug            string    `json:"slug" binding:"required"`
+	Color           string    `json:"color"`
+	Type            GoalType  `json:"type" binding:"required"`
+	Description     string    `json:"description"`
+	AllowedProjects []string  `json:"allowed_projects"`
+	GridSpan        int       `json:"gridSpan"`
+	ShowTotal       bool      `json:"showTotal"`
+	Buckets         []float64 `json:"buckets"` // Histogram bucket upper bounds, default DefaultHistogramBuckets
 }
 
 type UpdateGoalRequest struct {
@@ -59,11 +87,22 @@ type UpdateGoalRequest struct {
 	Order           *int      `json:"order"`
 }
 
+// Goal stats intervals
+const (
+	GoalIntervalDay   = "day"
+	GoalIntervalHour  = "hour"
+	GoalIntervalTotal = "total"
+)
+
 type GoalStatsQuery struct {
 	GoalIDs   []string `form:"goal_ids"`
 	ProjectID string   `form:"project_id"`
 	StartDate string   `form:"start_date"`
 	EndDate   string   `form:"end_date"`
+	Interval  string   `form:"interval"` // day (default), hour or total
+	GroupBy   string   `form:"group_by"` // Label to split the stats by, empty merges all labels
+	Labels    []string `form:"labels"`   // Label filters as key=value
+	Raw       bool     `form:"-"`        // Stored stats without aggregation
 }
 
 type IncrementGoalRequest struct {
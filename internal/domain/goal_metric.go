@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHistogramBuckets are the bucket upper bounds of histogram goals,
+// suited to latencies in milliseconds. Samples above the last bound fall
+// into an overflow bucket.
+var DefaultHistogramBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// GoalPercentiles are reported for histogram goals
+var GoalPercentiles = []float64{50, 90, 95, 99}
+
+// GoalMetric aggregates the samples recorded for a metric goal
+type GoalMetric struct {
+	Count   int64            `bson:"count" json:"count"`
+	Sum     float64          `bson:"sum" json:"sum"`
+	Min     float64          `bson:"min" json:"min"`
+	Max     float64          `bson:"max" json:"max"`
+	Last    float64          `bson:"last" json:"last"`
+	LastAt  time.Time        `bson:"last_at" json:"last_at"`
+	Buckets map[string]int64 `bson:"buckets,omitempty" json:"buckets,omitempty"` // Histogram sample counts by bucket index
+
+	// Computed for results
+	Avg         float64            `bson:"-" json:"avg"`
+	Percentiles map[string]float64 `bson:"-" json:"percentiles,omitempty"` // p50, p90, p95 and p99 of histograms
+}
+
+// NewGoalMetric returns the metric of a single sample. bounds are the
+// histogram buckets, nil for other goal types.
+func NewGoalMetric(value float64, at time.Time, bounds []float64) *GoalMetric {
+	m := &GoalMetric{Count: 1, Sum: value, Min: value, Max: value, Last: value, LastAt: at}
+	if bounds != nil {
+		m.Buckets = map[string]int64{strconv.Itoa(HistogramBucket(bounds, value)): 1}
+	}
+	return m
+}
+
+// Merge adds the samples of o to m
+func (m *GoalMetric) Merge(o *GoalMetric) {
+	if o == nil || o.Count == 0 {
+		return
+	}
+	if m.Count == 0 {
+		m.Min, m.Max = o.Min, o.Max
+	} else {
+		m.Min = math.Min(m.Min, o.Min)
+		m.Max = math.Max(m.Max, o.Max)
+	}
+	m.Count += o.Count
+	m.Sum += o.Sum
+	if !o.LastAt.Before(m.LastAt) {
+		m.Last, m.LastAt = o.Last, o.LastAt
+	}
+	for bucket, count := range o.Buckets {
+		if m.Buckets == nil {
+			m.Buckets = make(map[string]int64)
+		}
+		m.Buckets[bucket] += count
+	}
+}
+
+// Finish computes the average and, given histogram bounds, the percentiles
+func (m *GoalMetric) Finish(bounds []float64) {
+	if m.Count > 0 {
+		m.Avg = m.Sum / float64(m.Count)
+	}
+	if bounds == nil || len(m.Buckets) == 0 {
+		return
+	}
+	m.Percentiles = make(map[string]float64, len(GoalPercentiles))
+	for _, p := range GoalPercentiles {
+		m.Percentiles[fmt.Sprintf("p%g", p)] = m.Percentile(bounds, p)
+	}
+}
+
+// Percentile estimates the p-th percentile (0-100) from the histogram
+// buckets, interpolating linearly inside the bucket it falls into
+func (m *GoalMetric) Percentile(bounds []float64, p float64) float64 {
+	if m.Count == 0 {
+		return 0
+	}
+	target := p / 100 * float64(m.Count)
+	var seen int64
+	for i := 0; i <= len(bounds); i++ {
+		count := m.Buckets[strconv.Itoa(i)]
+		if count == 0 || float64(seen+count) < target {
+			seen += count
+			continue
+		}
+
+		lower, upper := m.Min, m.Max
+		if i > 0 && bounds[i-1] > lower {
+			lower = bounds[i-1]
+		}
+		if i < len(bounds) && bounds[i] < upper {
+			upper = bounds[i]
+		}
+		if upper < lower {
+			return lower
+		}
+		return lower + (upper-lower)*(target-float64(seen))/float64(count)
+	}
+	return m.Max
+}
+
+// HistogramBucket returns the index of the bucket a value falls into;
+// len(bounds) is the overflow bucket
+func HistogramBucket(bounds []float64, value float64) int {
+	return sort.SearchFloat64s(bounds, value)
+}
+
+// HistogramBounds returns the bucket upper bounds of a histogram goal, nil
+// for other goal types
+func (g *Goal) HistogramBounds() []float64 {
+	if g.Type != GoalTypeHistogram {
+		return nil
+	}
+	if len(g.Buckets) > 0 {
+		return g.Buckets
+	}
+	return DefaultHistogramBuckets
+}
+
+// ValueFor returns the headline value of a stat: the count of counters, the
+// last value of gauges, the sum, the average, or the median of histograms
+func (s *GoalStat) ValueFor(t GoalType) float64 {
+	if s.Metric == nil {
+		return float64(s.Value)
+	}
+	switch t {
+	case GoalTypeGauge:
+		return s.Metric.Last
+	case GoalTypeSum:
+		return s.Metric.Sum
+	case GoalTypeHistogram:
+		if p, ok := s.Metric.Percentiles["p50"]; ok {
+			return p
+		}
+	}
+	return s.Metric.Avg
+}
+
+// GoalLabelKey returns the canonical form of a label set, empty for no labels
+func GoalLabelKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	values := make(url.Values, len(labels))
+	for k, v := range labels {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// ParseGoalLabels parses key=value label filters
+func ParseGoalLabels(filters []string) (map[string]string, error) {
+	labels := make(map[string]string, len(filters))
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label filter %q, expected key=value", filter)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
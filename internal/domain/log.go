@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"time"
+)
+
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+// LogEntry is a structured runtime log record, stored as one JSON line per record
+type LogEntry struct {
+	Timestamp string                 `json:"timestamp"`            // RFC3339 UTC
+	Level     LogLevel               `json:"level"`                // debug, info, warn, error
+	Message   string                 `json:"message"`              // Formatted message
+	Source    string                 `json:"source,omitempty"`     // "route:GET /users/:id", "job:job_1", "action:sync", "hook:orders.insert"
+	RequestID string                 `json:"request_id,omitempty"` // Set for records written while handling a route
+	Fields    map[string]interface{} `json:"fields,omitempty"`     // Arbitrary structured fields
+}
+
+// LogQuery filters runtime logs
+type LogQuery struct {
+	Levels    []LogLevel        // Match any of these levels (all if empty)
+	From      *time.Time        // Records at or after this time
+	To        *time.Time        // Records at or before this time
+	Text      string            // Case-insensitive search in message and field values
+	Source    string            // Source prefix, e.g. "route:" or "job:job_1"
+	RequestID string            // Exact request id
+	Fields    map[string]string // Exact field matches (values compared as strings)
+	Limit     int               // Max records to return (newest are kept)
+	File      string            // Log file to search, the latest one if empty
+}
+
+// LogLevelRank orders levels for comparisons (unknown levels rank as info)
+func LogLevelRank(level LogLevel) int {
+	switch level {
+	case LogLevelDebug:
+		return 0
+	case LogLevelWarn:
+		return 2
+	case LogLevelError:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// LogRetention overrides the configured log retention of a project.
+// Unset (null) values inherit the server defaults from the logging config,
+// 0 disables the limit for the project.
+type LogRetention struct {
+	MaxFileSizeMB  *int `bson:"max_file_size_mb,omitempty" json:"max_file_size_mb" binding:"omitempty,min=0"`   // Rotate the current log file above this size
+	MaxTotalSizeMB *int `bson:"max_total_size_mb,omitempty" json:"max_total_size_mb" binding:"omitempty,min=0"` // Cap for all log files of the project
+	MaxAgeHours    *int `bson:"max_age_hours,omitempty" json:"max_age_hours" binding:"omitempty,min=0"`         // Delete log files not written for this long
+}
+
+// LogRetentionPolicy is the effective retention applied to a project's log directory
+type LogRetentionPolicy struct {
+	MaxFileSize  int64         // Bytes, 0 disables rotation
+	MaxTotalSize int64         // Bytes, 0 disables the cap
+	MaxAge       time.Duration // 0 keeps files regardless of age
+	Compress     bool          // Gzip log files that are no longer written
+}
+
+// LogFile describes a current or historical log file of a project
+type LogFile struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Compressed bool      `json:"compressed"`
+	Active     bool      `json:"active"` // Still being written by a runtime
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrLoginThrottled matches every *LoginThrottleError via errors.Is
+var ErrLoginThrottled = errors.New("too many failed logins")
+
+// Reasons of login events
+const (
+	LoginFailedCredentials = "invalid_credentials"
+	LoginFailedTwoFactor   = "invalid_2fa_code"
+	LoginFailedBlocked     = "blocked"
+	LoginLockedOut         = "locked_out"
+	LoginAutoBlocked       = "auto_blocked"
+)
+
+// LoginEvent records a failed login, or a lockout it caused
+type LoginEvent struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Email     string              `bson:"email" json:"email"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"` // Empty for unknown emails
+	IP        string              `bson:"ip" json:"ip"`
+	UserAgent string              `bson:"user_agent" json:"user_agent"`
+	Reason    string              `bson:"reason" json:"reason"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// LoginEventFilter selects login events, empty fields match everything
+type LoginEventFilter struct {
+	Email  string
+	IP     string
+	UserID *primitive.ObjectID
+	Since  *time.Time
+	Limit  int64
+}
+
+// LoginThrottle counts the recent failed logins of an account or a client IP
+type LoginThrottle struct {
+	Key         string     `bson:"_id"` // "account:<email>" or "ip:<ip>"
+	Failures    int        `bson:"failures"`
+	Lockouts    int        `bson:"lockouts"` // Lockouts in a row, reset by a successful login
+	LastFailure time.Time  `bson:"last_failure"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+}
+
+// LoginThrottleError is returned while an account or IP has to wait before
+// it may try to log in again
+type LoginThrottleError struct {
+	RetryAfter time.Duration
+	Locked     bool // Locked out, rather than slowed down after a few failures
+}
+
+func (e *LoginThrottleError) Error() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked for %s", wait)
+	}
+	return fmt.Sprintf("too many failed logins, try again in %s", wait)
+}
+
+func (e *LoginThrottleError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
@@ -15,6 +15,15 @@ const (
 	ReleaseTagDevelop    ReleaseTag = "develop"
 )
 
+// Valid reports whether t is one of the known release tags
+func (t ReleaseTag) Valid() bool {
+	switch t {
+	case ReleaseTagStable, ReleaseTagHotFix, ReleaseTagNightBuild, ReleaseTagDevelop:
+		return true
+	}
+	return false
+}
+
 // CodeFile represents a single file in a branch or release
 type CodeFile struct {
 	Name string `bson:"name" json:"name"`
@@ -130,3 +139,36 @@ type CreateReleaseRequest struct {
 type ResetBranchRequest struct {
 	TargetVersion string `json:"target_version" binding:"required"`
 }
+
+// GitRemote describes a git repository used for code import/export.
+// URL is either a local (bare) repository path or an http(s) remote.
+type GitRemote struct {
+	URL      string `json:"url" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"` // Password or access token for HTTP remotes
+}
+
+type GitPushRequest struct {
+	GitRemote
+	BranchName      string `json:"branch_name" binding:"required"`
+	Message         string `json:"message"`
+	IncludeReleases bool   `json:"include_releases"` // Push releases as tags
+}
+
+type GitPullRequest struct {
+	GitRemote
+	BranchName      string `json:"branch_name" binding:"required"`
+	RemoteBranch    string `json:"remote_branch"`    // Defaults to branch_name
+	IncludeReleases bool   `json:"include_releases"` // Import tags as releases
+}
+
+// GitSyncResult describes the outcome of a push or pull
+type GitSyncResult struct {
+	Branch   string   `json:"branch"`
+	Commit   string   `json:"commit"`
+	Changed  bool     `json:"changed"`
+	Files    int      `json:"files"`
+	Tags     []string `json:"tags"`     // Tags pushed or imported as releases
+	Skipped  []string `json:"skipped"`  // Tags skipped (already present or invalid)
+	Messages []string `json:"messages"` // Additional notes
+}
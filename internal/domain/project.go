@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,19 +17,25 @@ const (
 )
 
 type Project struct {
-	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Name          string               `bson:"name" json:"name"`
-	Slug          string               `bson:"slug" json:"slug"`
-	Color         string               `bson:"color" json:"color"`
-	OwnerID       primitive.ObjectID   `bson:"owner_id" json:"owner_id"`
-	Members       []primitive.ObjectID `bson:"members" json:"members"`
-	APIKey        string               `bson:"api_key" json:"api_key"`
-	Status        ProjectStatus        `bson:"status" json:"status"`
-	AutoStart     bool                 `bson:"auto_start" json:"auto_start"`
-	ActiveRelease string               `bson:"active_release" json:"active_release"`
-	RunningSource string               `bson:"running_source" json:"runningSource"` // "release:<version>" or "debug:<branch>"
-	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Name           string                 `bson:"name" json:"name"`
+	Slug           string                 `bson:"slug" json:"slug"`
+	Color          string                 `bson:"color" json:"color"`
+	OwnerID        primitive.ObjectID     `bson:"owner_id" json:"owner_id"`
+	Members        []primitive.ObjectID   `bson:"members" json:"members"`
+	MemberRoles    map[string]ProjectRole `bson:"member_roles,omitempty" json:"member_roles,omitempty"` // User id (hex) -> role, DefaultProjectRole when missing
+	APIKey         string                 `bson:"api_key" json:"api_key"`
+	Status         ProjectStatus          `bson:"status" json:"status"`
+	AutoStart      bool                   `bson:"auto_start" json:"auto_start"`
+	ActiveRelease  string                 `bson:"active_release" json:"active_release"`
+	RunningSource  string                 `bson:"running_source" json:"runningSource"` // "release:<version>" or "debug:<branch>"
+	LogRetention   *LogRetention          `bson:"log_retention,omitempty" json:"log_retention,omitempty"`
+	PrivatePaths   []string               `bson:"private_paths" json:"private_paths"`     // Storage paths served only through signed URLs
+	VersionedPaths []StorageVersioning    `bson:"versioned_paths" json:"versioned_paths"` // Storage directories that keep file versions
+	Quota          *ProjectQuota          `bson:"quota,omitempty" json:"quota,omitempty"`
+	Timezone       string                 `bson:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, e.g. "Europe/Moscow"; empty means UTC
+	CreatedAt      time.Time              `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time              `bson:"updated_at" json:"updated_at"`
 }
 
 type CreateProjectRequest struct {
@@ -36,12 +45,64 @@ type CreateProjectRequest struct {
 }
 
 type UpdateProjectRequest struct {
-	Name      *string `json:"name"`
-	Slug      *string `json:"slug"`
-	Color     *string `json:"color"`
-	AutoStart *bool   `json:"auto_start"`
+	Name         *string       `json:"name"`
+	Slug         *string       `json:"slug"`
+	Color        *string       `json:"color"`
+	AutoStart    *bool         `json:"auto_start"`
+	LogRetention *LogRetention `json:"log_retention"`
+	Timezone     *string       `json:"timezone"`
 }
 
 type AddMemberRequest struct {
-	UserID string `json:"user_id" binding:"required"`
+	UserID string      `json:"user_id" binding:"required"`
+	Role   ProjectRole `json:"role"` // Defaults to DefaultProjectRole
+}
+
+type SetStorageVisibilityRequest struct {
+	Path    string `json:"path" binding:"required"`
+	Private bool   `json:"private"`
+}
+
+type SignStorageURLRequest struct {
+	Path      string `json:"path" binding:"required"`
+	ExpiresIn int    `json:"expires_in"` // Seconds, defaults to one hour
+	Download  bool   `json:"download"`
+}
+
+// CleanStoragePath normalizes a storage path to "/dir/file" form
+func CleanStoragePath(p string) string {
+	return path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+}
+
+// IsStoragePathPrivate reports whether a storage path equals or lies under one of the private paths
+func (p *Project) IsStoragePathPrivate(storagePath string) bool {
+	storagePath = CleanStoragePath(storagePath)
+	for _, private := range p.PrivatePaths {
+		private = CleanStoragePath(private)
+		if private == "/" || storagePath == private || strings.HasPrefix(storagePath, private+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTimezone resolves an IANA timezone name; an empty name is UTC
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// Location returns the timezone of the project, UTC when unset or unknown
+func (p *Project) Location() *time.Location {
+	loc, err := LoadTimezone(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthUser is an end user of a project service, signed up through $auth.
+// Users are separate per project and unrelated to the users of M3M itself.
+type AuthUser struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ProjectID    primitive.ObjectID     `bson:"project_id" json:"-"`
+	Email        string                 `bson:"email" json:"email"` // Lowercased
+	PasswordHash string                 `bson:"password_hash" json:"-"`
+	Profile      map[string]interface{} `bson:"profile" json:"profile"`
+	TokenVersion int                    `bson:"token_version" json:"-"` // Bumped to revoke issued tokens
+	LastLoginAt  *time.Time             `bson:"last_login_at,omitempty" json:"lastLoginAt,omitempty"`
+	CreatedAt    time.Time              `bson:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time              `bson:"updated_at" json:"updatedAt"`
+}
+
+// AuthResetToken is a single-use password reset token; only its hash is stored
+type AuthResetToken struct {
+	Hash      string             `bson:"_id"`
+	ProjectID primitive.ObjectID `bson:"project_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProjectRole is the role of a member within a project. The owner and root
+// users always act as admin.
+type ProjectRole string
+
+const (
+	ProjectRoleViewer    ProjectRole = "viewer"    // Read-only access to the dashboard, logs, data and files
+	ProjectRoleDeveloper ProjectRole = "developer" // Writes code, models and data, runs debug builds
+	ProjectRoleOperator  ProjectRole = "operator"  // Ships releases, controls the runtime and environment
+	ProjectRoleAdmin     ProjectRole = "admin"     // Everything, including settings and members
+)
+
+// DefaultProjectRole is the role of members added without one, and of
+// members that joined before roles existed
+const DefaultProjectRole = ProjectRoleDeveloper
+
+// ProjectRoles lists the roles from least to most privileged
+var ProjectRoles = []ProjectRole{ProjectRoleViewer, ProjectRoleDeveloper, ProjectRoleOperator, ProjectRoleAdmin}
+
+func (r ProjectRole) Valid() bool {
+	return slices.Contains(ProjectRoles, r)
+}
+
+// ProjectPermission is an action a project role may allow
+type ProjectPermission string
+
+const (
+	PermissionView         ProjectPermission = "view"             // Project, runtime status, logs, goals and widgets
+	PermissionCodeEdit     ProjectPermission = "code.edit"        // Branches, files and git import
+	PermissionReleases     ProjectPermission = "releases.manage"  // Create, delete and activate releases
+	PermissionRuntime      ProjectPermission = "runtime.control"  // Start, stop and restart, trigger actions
+	PermissionModels       ProjectPermission = "models.manage"    // Model schemas
+	PermissionDataRead     ProjectPermission = "data.read"        // Model data
+	PermissionDataWrite    ProjectPermission = "data.write"       // Create, update and delete model data
+	PermissionEnvRead      ProjectPermission = "env.read"         // Environment variables
+	PermissionEnvWrite     ProjectPermission = "env.write"        // Change environment variables
+	PermissionStorageRead  ProjectPermission = "storage.read"     // List and download files
+	PermissionStorageWrite ProjectPermission = "storage.write"    // Upload, change and delete files
+	PermissionDashboard    ProjectPermission = "dashboard.manage" // Goals, widgets, actions and alerts
+	PermissionSettings     ProjectPermission = "settings.manage"  // Project settings, API key and export
+	PermissionMembers      ProjectPermission = "members.manage"   // Add, remove and change members
+	PermissionAudit        ProjectPermission = "audit.read"       // Audit log of the project
+)
+
+// ProjectPermissions lists all project permissions
+var ProjectPermissions = []ProjectPermission{
+	PermissionView, PermissionCodeEdit, PermissionReleases, PermissionRuntime,
+	PermissionModels, PermissionDataRead, PermissionDataWrite, PermissionEnvRead, PermissionEnvWrite,
+	PermissionStorageRead, PermissionStorageWrite, PermissionDashboard, PermissionSettings, PermissionMembers,
+	PermissionAudit,
+}
+
+// ProjectRolePermissions is the permission matrix of project roles
+var ProjectRolePermissions = map[ProjectRole][]ProjectPermission{
+	ProjectRoleViewer: {
+		PermissionView, PermissionDataRead, PermissionStorageRead,
+	},
+	ProjectRoleDeveloper: {
+		PermissionView, PermissionCodeEdit, PermissionRuntime, PermissionModels,
+		PermissionDataRead, PermissionDataWrite, PermissionEnvRead,
+		PermissionStorageRead, PermissionStorageWrite, PermissionDashboard,
+	},
+	ProjectRoleOperator: {
+		PermissionView, PermissionReleases, PermissionRuntime,
+		PermissionDataRead, PermissionDataWrite, PermissionEnvRead, PermissionEnvWrite,
+		PermissionStorageRead, PermissionStorageWrite, PermissionDashboard,
+	},
+	ProjectRoleAdmin: ProjectPermissions,
+}
+
+// Allows reports whether the role grants a permission
+func (r ProjectRole) Allows(permission ProjectPermission) bool {
+	return slices.Contains(ProjectRolePermissions[r], permission)
+}
+
+// MemberRole returns the role of a user in the project, and false when the
+// user is neither the owner nor a member
+func (p *Project) MemberRole(userID primitive.ObjectID) (ProjectRole, bool) {
+	if p.OwnerID == userID {
+		return ProjectRoleAdmin, true
+	}
+	if !slices.Contains(p.Members, userID) {
+		return "", false
+	}
+	if role, ok := p.MemberRoles[userID.Hex()]; ok && role.Valid() {
+		return role, true
+	}
+	return DefaultProjectRole, true
+}
+
+// ProjectMember is a member of a project with their role
+type ProjectMember struct {
+	UserID primitive.ObjectID `json:"user_id"`
+	Email  string             `json:"email"`
+	Name   string             `json:"name"`
+	Avatar string             `json:"avatar"`
+	Role   ProjectRole        `json:"role"`
+	Owner  bool               `json:"owner"`
+}
+
+// ProjectAccess is the role and permissions of the current user in a project
+type ProjectAccess struct {
+	Role        ProjectRole         `json:"role"`
+	Permissions []ProjectPermission `json:"permissions"`
+}
+
+// ProjectMembersResponse lists the members of a project with the permission
+// matrix and the access of the current user
+type ProjectMembersResponse struct {
+	Members []ProjectMember                     `json:"members"`
+	Roles   map[ProjectRole][]ProjectPermission `json:"roles"`
+	Current ProjectAccess                       `json:"current"`
+}
+
+type SetMemberRoleRequest struct {
+	Role ProjectRole `json:"role" binding:"required"`
+}
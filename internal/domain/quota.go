@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+type QuotaResource string
+
+const (
+	QuotaStorage   QuotaResource = "storage"
+	QuotaData      QuotaResource = "data"
+	QuotaDocuments QuotaResource = "documents"
+	QuotaLogs      QuotaResource = "logs"
+)
+
+// ErrQuotaExceeded matches every *QuotaError via errors.Is
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ProjectQuota overrides the configured quotas of a project.
+// Zero values inherit the server defaults from the quota config.
+type ProjectQuota struct {
+	StorageMB int   `bson:"storage_mb" json:"storage_mb" binding:"min=0"` // Files in project storage
+	DataMB    int   `bson:"data_mb" json:"data_mb" binding:"min=0"`       // Data collections on disk
+	Documents int64 `bson:"documents" json:"documents" binding:"min=0"`   // Documents across all data collections
+	LogMB     int   `bson:"log_mb" json:"log_mb" binding:"min=0"`         // Current and rotated log files
+}
+
+// QuotaLimits are the effective quotas of a project in bytes and documents, 0 means unlimited
+type QuotaLimits struct {
+	StorageBytes int64
+	DataBytes    int64
+	Documents    int64
+	LogBytes     int64
+	WarnPercent  int
+}
+
+// Limit returns the limit for a resource
+func (l QuotaLimits) Limit(resource QuotaResource) int64 {
+	switch resource {
+	case QuotaStorage:
+		return l.StorageBytes
+	case QuotaData:
+		return l.DataBytes
+	case QuotaDocuments:
+		return l.Documents
+	case QuotaLogs:
+		return l.LogBytes
+	}
+	return 0
+}
+
+// QuotaUsage reports the usage of one resource against its limit
+type QuotaUsage struct {
+	Resource QuotaResource `json:"resource"`
+	Used     int64         `json:"used"`
+	Limit    int64         `json:"limit"`   // 0 = unlimited
+	Percent  float64       `json:"percent"` // 0 when unlimited
+	Warning  bool          `json:"warning"` // Usage passed the warning threshold
+}
+
+// ProjectQuotaStatus is the quota override of a project with its current usage
+type ProjectQuotaStatus struct {
+	Quota       ProjectQuota `json:"quota"`
+	WarnPercent int          `json:"warn_percent"`
+	Usage       []QuotaUsage `json:"usage"`
+}
+
+// QuotaWarning is sent to a project when its usage nears or reaches a quota
+type QuotaWarning struct {
+	ProjectID string        `json:"project_id"`
+	Resource  QuotaResource `json:"resource"`
+	Used      int64         `json:"used"`
+	Limit     int64         `json:"limit"`
+	Percent   float64       `json:"percent"`
+	Exceeded  bool          `json:"exceeded"`
+	Message   string        `json:"message"`
+}
+
+// QuotaError is returned when a write would take a project over a quota
+type QuotaError struct {
+	Resource  QuotaResource
+	Used      int64
+	Requested int64
+	Limit     int64
+}
+
+func (e *QuotaError) Error() string {
+	if e.Resource == QuotaDocuments {
+		return fmt.Sprintf("document quota exceeded: %d of %d documents used", e.Used, e.Limit)
+	}
+	return fmt.Sprintf("%s quota exceeded: %s used, %s requested, limit %s",
+		e.Resource, FormatBytes(e.Used), FormatBytes(e.Requested), FormatBytes(e.Limit))
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// FormatBytes formats a byte count for messages, e.g. "1.5 MB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
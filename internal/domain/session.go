@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a server-side login session. Access tokens carry its ID and are
+// rejected once it is revoked or expired; its refresh token rotates on every use.
+type Session struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshHash  string             `bson:"refresh_hash" json:"-"`
+	PreviousHash string             `bson:"previous_hash,omitempty" json:"-"` // Refresh token replaced by the last rotation, reusing it revokes the session
+	UserAgent    string             `bson:"user_agent" json:"user_agent"`
+	IP           string             `bson:"ip" json:"ip"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt   time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Current      bool               `bson:"-" json:"current"`
+}
+
+// Active reports whether the session is neither revoked nor expired
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SessionClient describes the client a session was opened from
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"path"
+	"strings"
+)
+
+type StorageEventType string
+
+const (
+	StorageEventWrite  StorageEventType = "write"
+	StorageEventDelete StorageEventType = "delete"
+	StorageEventRename StorageEventType = "rename"
+)
+
+// StorageOrigin tells who made a change, empty for requests and scripts
+type StorageOrigin string
+
+// StorageOriginHook marks changes made by $hook.onStorage handlers
+const StorageOriginHook StorageOrigin = "hook"
+
+// StorageEvent describes a change to a file in project storage
+type StorageEvent struct {
+	ProjectID string           `json:"-"`
+	Type      StorageEventType `json:"event"`
+	Path      string           `json:"path"`              // Relative to the storage root, e.g. "uploads/cat.png"
+	OldPath   string           `json:"oldPath,omitempty"` // Previous path of a renamed file
+	Size      int64            `json:"size"`
+	MimeType  string           `json:"mimeType"`
+	IsDir     bool             `json:"isDir"`
+	Origin    StorageOrigin    `json:"-"`
+}
+
+// MatchStoragePath reports whether a storage path matches a glob pattern.
+// Patterns use path.Match syntax per segment, and "**" matches any number of
+// segments, e.g. "uploads/**/*.jpg" or "**".
+func MatchStoragePath(pattern, storagePath string) bool {
+	pattern = strings.Trim(strings.ReplaceAll(pattern, "\\", "/"), "/")
+	storagePath = strings.TrimPrefix(CleanStoragePath(storagePath), "/")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(storagePath, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
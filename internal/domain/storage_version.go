@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// StorageVersioning turns on versioning for a storage directory. Overwritten
+// and deleted files under it keep their prior contents as versions.
+type StorageVersioning struct {
+	Path        string `bson:"path" json:"path"`
+	MaxVersions int    `bson:"max_versions" json:"max_versions"` // Versions kept per file, 0 = unlimited
+	MaxAgeDays  int    `bson:"max_age_days" json:"max_age_days"` // Versions older than this are purged, 0 = never
+}
+
+// StorageVersionReason tells why a version was kept
+type StorageVersionReason string
+
+const (
+	StorageVersionOverwrite StorageVersionReason = "overwrite"
+	StorageVersionAppend    StorageVersionReason = "append"
+	StorageVersionDelete    StorageVersionReason = "delete"
+	StorageVersionRestore   StorageVersionReason = "restore"
+)
+
+// StorageVersion is a prior content of a versioned file. The content is
+// stored once per project under its SHA-256 hash.
+type StorageVersion struct {
+	ID        string               `json:"id"`
+	Hash      string               `json:"hash"`
+	Size      int64                `json:"size"`
+	User      string               `json:"user"` // Email of the user, or "runtime" for $storage
+	Reason    StorageVersionReason `json:"reason"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// StorageVersionList is the version history of one file, newest first
+type StorageVersionList struct {
+	Path       string             `json:"path"`
+	Versioning *StorageVersioning `json:"versioning"` // nil when the path is no longer versioned
+	Versions   []StorageVersion   `json:"versions"`
+}
+
+// StorageVersionPurge reports what a purge removed
+type StorageVersionPurge struct {
+	Versions   int   `json:"versions"`    // Version entries dropped
+	FreedBytes int64 `json:"freed_bytes"` // Size of the content no version refers to anymore
+}
+
+type SetStorageVersioningRequest struct {
+	Path        string `json:"path" binding:"required"`
+	Enabled     bool   `json:"enabled"`
+	MaxVersions int    `json:"max_versions" binding:"min=0"`
+	MaxAgeDays  int    `json:"max_age_days" binding:"min=0"`
+}
+
+type RestoreStorageVersionRequest struct {
+	Path      string `json:"path" binding:"required"`
+	VersionID string `json:"version_id" binding:"required"`
+}
+
+type PurgeStorageVersionsRequest struct {
+	Path string `json:"path"` // File or directory, empty for the whole project
+	All  bool   `json:"all"`  // Drop every version instead of applying the retention policy
+}
+
+// Expired reports whether a version falls outside the retention policy.
+// index is the position of the version in the newest-first history.
+func (v *StorageVersioning) Expired(version StorageVersion, index int, now time.Time) bool {
+	if v == nil {
+		return false
+	}
+	if v.MaxVersions > 0 && index >= v.MaxVersions {
+		return true
+	}
+	return v.MaxAgeDays > 0 && now.Sub(version.CreatedAt) > time.Duration(v.MaxAgeDays)*24*time.Hour
+}
+
+// StorageVersioningFor returns the versioning settings that cover a storage
+// path, or nil when it is not versioned
+func (p *Project) StorageVersioningFor(storagePath string) *StorageVersioning {
+	return FindStorageVersioning(p.VersionedPaths, storagePath)
+}
+
+// FindStorageVersioning returns the entry of paths that covers a storage path,
+// preferring the most specific directory, or nil when none does
+func FindStorageVersioning(paths []StorageVersioning, storagePath string) *StorageVersioning {
+	storagePath = CleanStoragePath(storagePath)
+	var match *StorageVersioning
+	var matchLen int
+	for i := range paths {
+		dir := CleanStoragePath(paths[i].Path)
+		if dir == "/" || storagePath == dir || strings.HasPrefix(storagePath, dir+"/") {
+			if match == nil || len(dir) > matchLen {
+				match, matchLen = &paths[i], len(dir)
+			}
+		}
+	}
+	return match
+}
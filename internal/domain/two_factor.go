@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Second login steps, returned in LoginResponse.TwoFactor
+const (
+	TwoFactorStepVerify = "verify" // Enter a code from the authenticator app or a recovery code
+	TwoFactorStepEnroll = "enroll" // 2FA is required, set up an authenticator app first
+)
+
+// TwoFactor is the TOTP two-factor authentication of a user
+type TwoFactor struct {
+	Enabled       bool       `bson:"enabled" json:"enabled"`
+	Required      bool       `bson:"required" json:"required"` // Set by admins, the user has to enroll on the next login
+	Secret        string     `bson:"secret,omitempty" json:"-"`
+	PendingSecret string     `bson:"pending_secret,omitempty" json:"-"` // Enrollment not confirmed with a code yet
+	RecoveryCodes []string   `bson:"recovery_codes,omitempty" json:"-"` // Hashes of the unused recovery codes
+	LastStep      int64      `bson:"last_step,omitempty" json:"-"`      // Time step of the last accepted code
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty" json:"enabled_at,omitempty"`
+}
+
+// TwoFactorStatus is the 2FA state shown to its user
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Required          bool       `json:"required"` // By the user's admin or the server policy
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+}
+
+// TwoFactorEnrollment is a new TOTP secret, confirmed by entering a code from it
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`     // otpauth:// URI read by authenticator apps
+	QRCode string `json:"qr_code"` // The URI as a QR code, a PNG data URL
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorChallengeRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+}
+
+// TwoFactorLoginRequest finishes a login that needs a second step
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"` // TOTP code or recovery code
+}
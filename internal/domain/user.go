@@ -7,16 +7,19 @@ import (
 )
 
 type User struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email       string             `bson:"email" json:"email"`
-	Password    string             `bson:"password" json:"-"`
-	Name        string             `bson:"name" json:"name"`
-	Avatar      string             `bson:"avatar" json:"avatar"`
-	IsRoot      bool               `bson:"is_root" json:"is_root"`
-	IsBlocked   bool               `bson:"is_blocked" json:"is_blocked"`
-	Permissions Permissions        `bson:"permissions" json:"permissions"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email          string             `bson:"email" json:"email"`
+	Password       string             `bson:"password" json:"-"`
+	Name           string             `bson:"name" json:"name"`
+	Avatar         string             `bson:"avatar" json:"avatar"`
+	IsRoot         bool               `bson:"is_root" json:"is_root"`
+	IsBlocked      bool               `bson:"is_blocked" json:"is_blocked"`
+	Permissions    Permissions        `bson:"permissions" json:"permissions"`
+	SSOSubject     string             `bson:"sso_subject,omitempty" json:"-"`                     // Subject at the OpenID Connect provider, set on the first SSO login
+	SSOLinkAllowed bool               `bson:"sso_link_allowed,omitempty" json:"sso_link_allowed"` // An admin allowed the next SSO login with the account's email to link it
+	TwoFactor      TwoFactor          `bson:"two_factor" json:"two_factor"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 type Permissions struct {
@@ -36,6 +39,7 @@ type UpdateUserRequest struct {
 	Email       *string      `json:"email" binding:"omitempty,email"`
 	Name        *string      `json:"name"`
 	Permissions *Permissions `json:"permissions"`
+	Require2FA  *bool        `json:"require_2fa"`
 }
 
 type UpdateProfileRequest struct {
@@ -53,7 +57,14 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// LoginResponse holds the tokens of a login. Logins that need a second step
+// only return the step and its challenge, finished at /auth/2fa/verify.
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token         string    `json:"token,omitempty"`         // Short-lived access token
+	ExpiresAt     time.Time `json:"expires_at,omitzero"`     // Expiry of the access token
+	RefreshToken  string    `json:"refresh_token,omitempty"` // Exchanged at /auth/refresh for new tokens, single use
+	User          *User     `json:"user,omitempty"`
+	TwoFactor     string    `json:"two_factor,omitempty"`     // Second step: "verify" or "enroll"
+	Challenge     string    `json:"challenge,omitempty"`      // Identifies the login in its second step
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"` // Shown once, when 2FA was enrolled during the login
 }
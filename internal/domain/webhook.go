@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDelivered = "delivered" // The handler answered below 500
+	WebhookFailed    = "failed"    // The handler threw or answered 500 or above
+	WebhookRejected  = "rejected"  // The signature or timestamp did not verify
+	WebhookDuplicate = "duplicate" // The delivery id was already handled
+)
+
+// WebhookDelivery is an inbound request to a $router.webhook route, kept
+// with its payload so it can be inspected and replayed
+type WebhookDelivery struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ProjectID      primitive.ObjectID  `bson:"project_id" json:"project_id"`
+	Webhook        string              `bson:"webhook" json:"webhook"` // Route path the webhook was registered with
+	Provider       string              `bson:"provider" json:"provider"`
+	DeliveryID     string              `bson:"delivery_id,omitempty" json:"delivery_id,omitempty"` // Provider's id of the event, used against replays
+	Method         string              `bson:"method" json:"method"`
+	Path           string              `bson:"path" json:"path"`
+	Headers        map[string]string   `bson:"headers" json:"headers"` // Secrets are redacted
+	Body           []byte              `bson:"body" json:"-"`
+	BodySize       int                 `bson:"body_size" json:"body_size"`
+	BodyTruncated  bool                `bson:"body_truncated,omitempty" json:"body_truncated,omitempty"` // Too large to keep whole, cannot be replayed
+	Status         string              `bson:"status" json:"status"`
+	Error          string              `bson:"error,omitempty" json:"error,omitempty"`
+	ResponseStatus int                 `bson:"response_status" json:"response_status"`
+	DurationMs     int64               `bson:"duration_ms" json:"duration_ms"`
+	ReplayOf       *primitive.ObjectID `bson:"replay_of,omitempty" json:"replay_of,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+
+	// Set on a single delivery: the body as UTF-8 text, or base64 when binary
+	Payload         string `bson:"-" json:"payload,omitempty"`
+	PayloadEncoding string `bson:"-" json:"payload_encoding,omitempty"`
+}
+
+// WebhookDeliveryFilter selects deliveries of a project, empty fields match everything
+type WebhookDeliveryFilter struct {
+	ProjectID primitive.ObjectID
+	Webhook   string
+	Status    string
+	Limit     int64
+}
@@ -45,24 +45,12 @@ func (h *ActionHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.
 	}
 }
 
-func (h *ActionHandler) checkAccess(c *gin.Context) (primitive.ObjectID, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return primitive.NilObjectID, false
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return primitive.NilObjectID, false
-	}
-
-	return projectID, true
+func (h *ActionHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
 }
 
 func (h *ActionHandler) List(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -77,7 +65,7 @@ func (h *ActionHandler) List(c *gin.Context) {
 }
 
 func (h *ActionHandler) Create(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionDashboard)
 	if !ok {
 		return
 	}
@@ -102,7 +90,7 @@ func (h *ActionHandler) Create(c *gin.Context) {
 }
 
 func (h *ActionHandler) Update(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionDashboard)
 	if !ok {
 		return
 	}
@@ -145,7 +133,7 @@ func (h *ActionHandler) Update(c *gin.Context) {
 }
 
 func (h *ActionHandler) Delete(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionDashboard)
 	if !ok {
 		return
 	}
@@ -181,7 +169,7 @@ func (h *ActionHandler) Delete(c *gin.Context) {
 }
 
 func (h *ActionHandler) GetStates(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -201,7 +189,7 @@ type TriggerActionRequest struct {
 
 // Trigger triggers an action with session context for $ui dialogs
 func (h *ActionHandler) Trigger(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionRuntime)
 	if !ok {
 		return
 	}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
+	"github.com/levskiy0/m3m/internal/service"
+)
+
+type AlertHandler struct {
+	alertService   *service.AlertService
+	projectService *service.ProjectService
+}
+
+func NewAlertHandler(alertService *service.AlertService, projectService *service.ProjectService) *AlertHandler {
+	return &AlertHandler{
+		alertService:   alertService,
+		projectService: projectService,
+	}
+}
+
+func (h *AlertHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.AuthMiddleware) {
+	alerts := r.Group("/projects/:id/alerts")
+	alerts.Use(authMiddleware.Authenticate())
+	{
+		alerts.GET("", h.List)
+		alerts.POST("", h.Create)
+		alerts.GET("/history", h.History)
+		alerts.PUT("/:alertId", h.Update)
+		alerts.DELETE("/:alertId", h.Delete)
+		alerts.POST("/:alertId/silence", h.Silence)
+		alerts.DELETE("/:alertId/silence", h.Unsilence)
+	}
+}
+
+func (h *AlertHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
+}
+
+// projectRule loads the rule of the request, checking it belongs to the project
+func (h *AlertHandler) projectRule(c *gin.Context) (*domain.AlertRule, bool) {
+	projectID, ok := h.checkAccess(c, domain.PermissionDashboard)
+	if !ok {
+		return nil, false
+	}
+
+	ruleID, err := primitive.ObjectIDFromHex(c.Param("alertId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
+		return nil, false
+	}
+
+	rule, err := h.alertService.GetByID(c.Request.Context(), ruleID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAlertRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if rule.ProjectID != projectID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "alert rule does not belong to this project"})
+		return nil, false
+	}
+	return rule, true
+}
+
+func alertErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidAlertRule) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *AlertHandler) List(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	rules, err := h.alertService.GetByProject(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *AlertHandler) Create(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionDashboard)
+	if !ok {
+		return
+	}
+
+	var req domain.CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.alertService.Create(c.Request.Context(), projectID, &req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *AlertHandler) Update(c *gin.Context) {
+	rule, ok := h.projectRule(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.alertService.Update(c.Request.Context(), rule.ID, &req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *AlertHandler) Delete(c *gin.Context) {
+	rule, ok := h.projectRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.alertService.Delete(c.Request.Context(), rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "alert rule deleted successfully"})
+}
+
+func (h *AlertHandler) Silence(c *gin.Context) {
+	rule, ok := h.projectRule(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SilenceAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.alertService.Silence(c.Request.Context(), rule.ID, &req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *AlertHandler) Unsilence(c *gin.Context) {
+	rule, ok := h.projectRule(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.alertService.Unsilence(c.Request.Context(), rule.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *AlertHandler) History(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	var query domain.AlertHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.alertService.History(c.Request.Context(), projectID, &query)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
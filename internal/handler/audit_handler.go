@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/service"
+)
+
+type AuditHandler struct {
+	auditService   *service.AuditService
+	projectService *service.ProjectService
+}
+
+func NewAuditHandler(auditService *service.AuditService, projectService *service.ProjectService) *AuditHandler {
+	return &AuditHandler{
+		auditService:   auditService,
+		projectService: projectService,
+	}
+}
+
+func (h *AuditHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.AuthMiddleware) {
+	audit := r.Group("/audit")
+	audit.Use(authMiddleware.Authenticate())
+	{
+		audit.GET("", h.List)
+		audit.GET("/export", h.Export)
+	}
+
+	projectAudit := r.Group("/projects/:id/audit")
+	projectAudit.Use(authMiddleware.Authenticate())
+	{
+		projectAudit.GET("", h.ListProject)
+		projectAudit.GET("/export", h.ExportProject)
+	}
+}
+
+// List returns the audit log of the whole server, for root users
+func (h *AuditHandler) List(c *gin.Context) {
+	filter, ok := h.rootFilter(c)
+	if !ok {
+		return
+	}
+	h.list(c, filter)
+}
+
+// Export downloads the audit log of the whole server as JSON Lines
+func (h *AuditHandler) Export(c *gin.Context) {
+	filter, ok := h.rootFilter(c)
+	if !ok {
+		return
+	}
+	h.export(c, filter, "audit")
+}
+
+func (h *AuditHandler) ListProject(c *gin.Context) {
+	filter, ok := h.projectFilter(c)
+	if !ok {
+		return
+	}
+	h.list(c, filter)
+}
+
+func (h *AuditHandler) ExportProject(c *gin.Context) {
+	filter, ok := h.projectFilter(c)
+	if !ok {
+		return
+	}
+	h.export(c, filter, "audit-"+filter.ProjectID.Hex())
+}
+
+func (h *AuditHandler) rootFilter(c *gin.Context) (domain.AuditFilter, bool) {
+	if !middleware.GetCurrentUser(c).IsRoot {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the audit log is for root users only"})
+		return domain.AuditFilter{}, false
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return domain.AuditFilter{}, false
+	}
+	if projectID := c.Query("project_id"); projectID != "" {
+		id, err := primitive.ObjectIDFromHex(projectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project_id"})
+			return domain.AuditFilter{}, false
+		}
+		filter.ProjectID = &id
+	}
+	return filter, true
+}
+
+func (h *AuditHandler) projectFilter(c *gin.Context) (domain.AuditFilter, bool) {
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionAudit)
+	if !ok {
+		return domain.AuditFilter{}, false
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return domain.AuditFilter{}, false
+	}
+	filter.ProjectID = &projectID
+	return filter, true
+}
+
+func (h *AuditHandler) list(c *gin.Context, filter domain.AuditFilter) {
+	entries, err := h.auditService.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *AuditHandler) export(c *gin.Context, filter domain.AuditFilter, name string) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.jsonl"`, name, time.Now().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	if err := h.auditService.Export(c.Request.Context(), filter, c.Writer); err != nil {
+		// The response has started, the error can only end it early
+		c.Error(err)
+	}
+}
+
+// auditFilter reads the actor_id, action, from, to (RFC 3339) and limit
+// query parameters
+func auditFilter(c *gin.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{Action: c.Query("action")}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := primitive.ObjectIDFromHex(actorID)
+		if err != nil {
+			return filter, errors.New("invalid actor_id")
+		}
+		filter.ActorID = &id
+	}
+	for param, field := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s, expected RFC 3339", param)
+		}
+		*field = &t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid limit")
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// recordAudit records an administrative action of the current user in the
+// audit log. projectID is NilObjectID for actions outside projects; before
+// and after are states of the target to diff, either may be nil.
+func recordAudit(c *gin.Context, auditService *service.AuditService, projectID primitive.ObjectID, action, target string, before, after any) {
+	user := middleware.GetCurrentUser(c)
+	actor := domain.AuditActor{UserID: user.ID, Email: user.Email, IP: c.ClientIP()}
+	if token := middleware.GetAccessToken(c); token != nil {
+		actor.TokenID = &token.ID
+	}
+	auditService.Record(c.Request.Context(), actor, projectID, action, target, before, after)
+}
@@ -1,32 +1,60 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/service"
 )
 
 type AuthHandler struct {
 	authService *service.AuthService
+	ssoService  *service.SSOService
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, ssoService *service.SSOService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		ssoService:  ssoService,
 	}
 }
 
 func (h *AuthHandler) Register(r *gin.RouterGroup) {
 	auth := r.Group("/auth")
 	{
+		auth.GET("/config", h.Config)
 		auth.POST("/login", h.Login)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/2fa/enroll", h.EnrollTwoFactor)
+		auth.POST("/2fa/verify", h.VerifyTwoFactor)
+		auth.GET("/oidc/login", h.OIDCLogin)
+		auth.GET("/oidc/callback", h.OIDCCallback)
+		auth.POST("/oidc/exchange", h.OIDCExchange)
 	}
 }
 
+// Config tells the login page which ways to log in are offered
+func (h *AuthHandler) Config(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"password_login": h.authService.PasswordLoginAllowed(nil),
+		"oidc": gin.H{
+			"enabled": h.ssoService.Enabled(),
+			"name":    h.ssoService.Name(),
+		},
+	})
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req domain.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -34,8 +62,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.authService.Login(c.Request.Context(), &req)
+	resp, err := h.authService.Login(c.Request.Context(), &req, sessionClient(c))
 	if err != nil {
+		if loginThrottled(c, err) {
+			return
+		}
 		if err == service.ErrInvalidCredentials {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
@@ -44,6 +75,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "user is blocked"})
 			return
 		}
+		if err == service.ErrPasswordLogin {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -51,16 +86,263 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// Refresh exchanges a refresh token for new tokens; the old one stops working
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrSessionRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrUserBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "user is blocked"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// EnrollTwoFactor creates the TOTP secret of a login that has to enroll in 2FA
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	var req domain.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTwoFactor(c.Request.Context(), req.Challenge)
+	if err != nil {
+		twoFactorLoginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// VerifyTwoFactor finishes a login with a TOTP or recovery code
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req domain.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.authService.VerifyTwoFactor(c.Request.Context(), req.Challenge, req.Code)
+	if err != nil {
+		twoFactorLoginError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func twoFactorLoginError(c *gin.Context, err error) {
+	if loginThrottled(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrLoginChallenge), errors.Is(err, service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUserBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "user is blocked"})
+	case errors.Is(err, service.ErrTwoFactorEnabled), errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrTwoFactorNotEnrolling):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// loginThrottled answers a login that has to wait with 429 and Retry-After
+func loginThrottled(c *gin.Context, err error) bool {
+	var throttled *domain.LoginThrottleError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int((throttled.RetryAfter + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       err.Error(),
+		"retry_after": retryAfter,
+		"locked":      throttled.Locked,
+	})
+	return true
+}
+
+// OIDCLogin sends the browser to the provider to log in
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	redirect := safeRedirect(c.Query("redirect"))
+
+	authURL, err := h.ssoService.Begin(c.Request.Context(), redirect)
+	if err != nil {
+		c.Redirect(http.StatusFound, ssoLoginURL(url.Values{"sso_error": {err.Error()}, "redirect": {redirect}}))
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCLink starts a single sign-on login that links the identity to the
+// current account. The panel sends the user to the returned URL.
+func (h *AuthHandler) OIDCLink(c *gin.Context) {
+	var req struct {
+		Redirect string `json:"redirect"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authURL, err := h.ssoService.BeginLink(c.Request.Context(), middleware.GetCurrentUser(c), safeRedirect(req.Redirect))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSSODisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrSSOTooManyLogins):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// OIDCCallback receives the provider's response and hands the login over to
+// the login page with a single-use code
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if idpError := c.Query("error"); idpError != "" {
+		message := c.Query("error_description")
+		if message == "" {
+			message = idpError
+		}
+		c.Redirect(http.StatusFound, ssoLoginURL(url.Values{"sso_error": {message}}))
+		return
+	}
+
+	handoff, redirect, err := h.ssoService.Complete(c.Request.Context(), c.Query("state"), c.Query("code"), sessionClient(c))
+	if err != nil {
+		c.Redirect(http.StatusFound, ssoLoginURL(url.Values{"sso_error": {err.Error()}, "redirect": {redirect}}))
+		return
+	}
+
+	c.Redirect(http.StatusFound, ssoLoginURL(url.Values{"sso": {handoff}, "redirect": {redirect}}))
+}
+
+// OIDCExchange trades the code from the callback for the tokens of the login
+func (h *AuthHandler) OIDCExchange(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.ssoService.Exchange(req.Code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (h *AuthHandler) RegisterProtected(r *gin.RouterGroup, authMiddleware *middleware.AuthMiddleware) {
 	auth := r.Group("/auth")
 	auth.Use(authMiddleware.Authenticate())
 	{
 		auth.POST("/logout", h.Logout)
+		auth.POST("/logout-all", h.LogoutAll)
+		auth.GET("/sessions", h.Sessions)
+		auth.DELETE("/sessions/:sessionId", h.RevokeSession)
+		auth.POST("/oidc/link", h.OIDCLink)
 	}
 }
 
+// Logout ends the session of the request
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// With JWT, logout is typically handled client-side by removing the token
-	// Server-side could implement a token blacklist if needed
+	user := middleware.GetCurrentUser(c)
+	session := middleware.GetCurrentSession(c)
+
+	if err := h.authService.RevokeSession(c.Request.Context(), user.ID, session.ID); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
 }
+
+// LogoutAll ends every session of the current user, including this one
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+
+	if err := h.authService.RevokeUserSessions(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out everywhere"})
+}
+
+func (h *AuthHandler) Sessions(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	session := middleware.GetCurrentSession(c)
+
+	sessions, err := h.authService.Sessions(c.Request.Context(), user.ID, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if err := h.authService.RevokeSession(c.Request.Context(), user.ID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// safeRedirect keeps only paths within the panel, so the login cannot be
+// used to send users elsewhere
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return ""
+	}
+	return redirect
+}
+
+func ssoLoginURL(params url.Values) string {
+	if params.Get("redirect") == "" {
+		params.Del("redirect")
+	}
+	return "/login?" + params.Encode()
+}
+
+func sessionClient(c *gin.Context) domain.SessionClient {
+	return domain.SessionClient{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
@@ -14,12 +14,14 @@ import (
 type EnvironmentHandler struct {
 	envService     *service.EnvironmentService
 	projectService *service.ProjectService
+	auditService   *service.AuditService
 }
 
-func NewEnvironmentHandler(envService *service.EnvironmentService, projectService *service.ProjectService) *EnvironmentHandler {
+func NewEnvironmentHandler(envService *service.EnvironmentService, projectService *service.ProjectService, auditService *service.AuditService) *EnvironmentHandler {
 	return &EnvironmentHandler{
 		envService:     envService,
 		projectService: projectService,
+		auditService:   auditService,
 	}
 }
 
@@ -34,24 +36,28 @@ func (h *EnvironmentHandler) Register(r *gin.RouterGroup, authMiddleware *middle
 	}
 }
 
-func (h *EnvironmentHandler) checkAccess(c *gin.Context) (primitive.ObjectID, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return primitive.NilObjectID, false
-	}
+func (h *EnvironmentHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
+}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return primitive.NilObjectID, false
+// auditState is the audited state of env vars by key. Values may be secrets,
+// they are recorded as fingerprints.
+func (h *EnvironmentHandler) auditState(envVars ...*domain.EnvVar) any {
+	state := make(map[string]any, len(envVars))
+	for _, envVar := range envVars {
+		if envVar == nil {
+			continue
+		}
+		state[envVar.Key] = gin.H{
+			"type":  envVar.Type,
+			"value": h.auditService.Redact(envVar.Value),
+		}
 	}
-
-	return projectID, true
+	return state
 }
 
 func (h *EnvironmentHandler) List(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionEnvRead)
 	if !ok {
 		return
 	}
@@ -66,7 +72,7 @@ func (h *EnvironmentHandler) List(c *gin.Context) {
 }
 
 func (h *EnvironmentHandler) Create(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionEnvWrite)
 	if !ok {
 		return
 	}
@@ -83,11 +89,13 @@ func (h *EnvironmentHandler) Create(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditEnvCreate, envVar.Key, nil, h.auditState(envVar))
+
 	c.JSON(http.StatusCreated, envVar)
 }
 
 func (h *EnvironmentHandler) BulkUpdate(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionEnvWrite)
 	if !ok {
 		return
 	}
@@ -98,26 +106,37 @@ func (h *EnvironmentHandler) BulkUpdate(c *gin.Context) {
 		return
 	}
 
+	before, err := h.envService.GetByProject(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	envVars, err := h.envService.BulkUpdate(c.Request.Context(), projectID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditEnvUpdate, "", h.auditState(before...), h.auditState(envVars...))
+
 	c.JSON(http.StatusOK, envVars)
 }
 
 func (h *EnvironmentHandler) Delete(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionEnvWrite)
 	if !ok {
 		return
 	}
 
 	key := c.Param("key")
+	before, _ := h.envService.GetByKey(c.Request.Context(), projectID, key)
 	if err := h.envService.Delete(c.Request.Context(), projectID, key); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditEnvDelete, key, h.auditState(before), nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "environment variable deleted successfully"})
 }
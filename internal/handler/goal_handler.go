@@ -1,10 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,16 +16,20 @@ import (
 
 // GoalStatsResponse represents aggregated goal statistics for frontend
 type GoalStatsResponse struct {
-	GoalID     string          `json:"goalID"`
-	Value      int64           `json:"value"`
-	TotalValue int64           `json:"totalValue,omitempty"`
-	DailyStats []DailyStatItem `json:"dailyStats,omitempty"`
+	GoalID     string             `json:"goalID"`
+	Value      int64              `json:"value"`
+	TotalValue int64              `json:"totalValue,omitempty"`
+	Metric     *domain.GoalMetric `json:"metric,omitempty"` // Current aggregates of metric goals
+	DailyStats []DailyStatItem    `json:"dailyStats,omitempty"`
 }
 
-// DailyStatItem represents a single day's statistics
+// DailyStatItem represents a single day's (or hour's) statistics
 type DailyStatItem struct {
-	Date  string `json:"date"`
-	Value int64  `json:"value"`
+	Date   string             `json:"date"`
+	Hour   *int               `json:"hour,omitempty"`
+	Labels map[string]string  `json:"labels,omitempty"`
+	Value  int64              `json:"value"`
+	Metric *domain.GoalMetric `json:"metric,omitempty"`
 }
 
 type GoalHandler struct {
@@ -82,6 +86,10 @@ func (h *GoalHandler) CreateGlobal(c *gin.Context) {
 	}
 
 	goal, err := h.goalService.CreateGlobal(c.Request.Context(), &req)
+	if errors.Is(err, service.ErrInvalidGoal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -162,7 +170,7 @@ func (h *GoalHandler) GetStats(c *gin.Context) {
 		startDate = startDateParam
 		endDate = endDateParam
 	} else {
-		now := time.Now()
+		now := h.goalService.GoalNow(c.Request.Context(), goalIds[0])
 		endDate = now.Format("2006-01-02")
 		startDate = now.AddDate(0, 0, -14).Format("2006-01-02")
 	}
@@ -172,6 +180,10 @@ func (h *GoalHandler) GetStats(c *gin.Context) {
 		StartDate: startDate,
 		EndDate:   endDate,
 	}
+	if err := bindStatsGrouping(c, query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	stats, err := h.goalService.GetStats(c.Request.Context(), query)
 	if err != nil {
@@ -186,9 +198,9 @@ func (h *GoalHandler) GetStats(c *gin.Context) {
 		return
 	}
 
-	// Aggregate stats by goal ID
+	// Aggregate stats by goal ID; "today" follows the timezone of each goal's project
 	goalStatsMap := make(map[string]*GoalStatsResponse)
-	today := time.Now().UTC().Format("2006-01-02")
+	todays := make(map[string]string)
 
 	for _, stat := range stats {
 		goalID := stat.GoalID.Hex()
@@ -197,17 +209,23 @@ func (h *GoalHandler) GetStats(c *gin.Context) {
 				GoalID:     goalID,
 				DailyStats: []DailyStatItem{},
 			}
+			todays[goalID] = h.goalService.GoalNow(c.Request.Context(), goalID).Format("2006-01-02")
 		}
+		today := todays[goalID]
 
 		gs := goalStatsMap[goalID]
 		gs.DailyStats = append(gs.DailyStats, DailyStatItem{
-			Date:  stat.Date,
-			Value: stat.Value,
+			Date:   stat.Date,
+			Hour:   stat.Hour,
+			Labels: stat.Labels,
+			Value:  stat.Value,
+			Metric: stat.Metric,
 		})
 
 		// Set current value (today's value for daily_counter, or total)
-		if stat.Date == today || stat.Date == "total" {
+		if (stat.Date == today || stat.Date == "total") && stat.Hour == nil && stat.Labels == nil {
 			gs.Value = stat.Value
+			gs.Metric = stat.Metric
 		}
 	}
 
@@ -252,7 +270,7 @@ func (h *GoalHandler) GetGoalStats(c *gin.Context) {
 	}
 
 	// Calculate date range for last 14 days
-	endDate := time.Now()
+	endDate := h.goalService.GoalNow(c.Request.Context(), goalID)
 	startDate := endDate.AddDate(0, 0, -14)
 
 	query := &domain.GoalStatsQuery{
@@ -260,6 +278,10 @@ func (h *GoalHandler) GetGoalStats(c *gin.Context) {
 		StartDate: startDate.Format("2006-01-02"),
 		EndDate:   endDate.Format("2006-01-02"),
 	}
+	if err := bindStatsGrouping(c, query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	stats, err := h.goalService.GetStats(c.Request.Context(), query)
 	if err != nil {
@@ -281,14 +303,18 @@ func (h *GoalHandler) GetGoalStats(c *gin.Context) {
 		DailyStats: []DailyStatItem{},
 	}
 
-	today := time.Now().UTC().Format("2006-01-02")
+	today := endDate.Format("2006-01-02")
 	for _, stat := range stats {
 		response.DailyStats = append(response.DailyStats, DailyStatItem{
-			Date:  stat.Date,
-			Value: stat.Value,
+			Date:   stat.Date,
+			Hour:   stat.Hour,
+			Labels: stat.Labels,
+			Value:  stat.Value,
+			Metric: stat.Metric,
 		})
-		if stat.Date == today || stat.Date == "total" {
+		if (stat.Date == today || stat.Date == "total") && stat.Hour == nil && stat.Labels == nil {
 			response.Value = stat.Value
+			response.Metric = stat.Metric
 		}
 	}
 
@@ -300,16 +326,27 @@ func (h *GoalHandler) GetGoalStats(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *GoalHandler) ListProject(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
+// bindStatsGrouping reads the interval (day, hour or total), groupBy label and
+// labels filters (comma separated key=value) of a stats request
+func bindStatsGrouping(c *gin.Context, query *domain.GoalStatsQuery) error {
+	query.Interval = c.Query("interval")
+	query.GroupBy = c.Query("groupBy")
+	if labels := c.Query("labels"); labels != "" {
+		query.Labels = strings.Split(labels, ",")
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	switch query.Interval {
+	case "", domain.GoalIntervalDay, domain.GoalIntervalHour, domain.GoalIntervalTotal:
+	default:
+		return errors.New("interval must be day, hour or total")
+	}
+	_, err := domain.ParseGoalLabels(query.Labels)
+	return err
+}
+
+func (h *GoalHandler) ListProject(c *gin.Context) {
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
 		return
 	}
 
@@ -323,15 +360,8 @@ func (h *GoalHandler) ListProject(c *gin.Context) {
 }
 
 func (h *GoalHandler) CreateProject(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
@@ -342,6 +372,10 @@ func (h *GoalHandler) CreateProject(c *gin.Context) {
 	}
 
 	goal, err := h.goalService.CreateForProject(c.Request.Context(), projectID, &req)
+	if errors.Is(err, service.ErrInvalidGoal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -351,21 +385,13 @@ func (h *GoalHandler) CreateProject(c *gin.Context) {
 }
 
 func (h *GoalHandler) UpdateProject(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	goalID, err := primitive.ObjectIDFromHex(c.Param("goalId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	goalID, ok := h.projectGoal(c, projectID)
+	if !ok {
 		return
 	}
 
@@ -385,21 +411,13 @@ func (h *GoalHandler) UpdateProject(c *gin.Context) {
 }
 
 func (h *GoalHandler) DeleteProject(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	goalID, err := primitive.ObjectIDFromHex(c.Param("goalId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	goalID, ok := h.projectGoal(c, projectID)
+	if !ok {
 		return
 	}
 
@@ -412,21 +430,13 @@ func (h *GoalHandler) DeleteProject(c *gin.Context) {
 }
 
 func (h *GoalHandler) ResetProjectGoal(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
-	goalID, err := primitive.ObjectIDFromHex(c.Param("goalId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	goalID, ok := h.projectGoal(c, projectID)
+	if !ok {
 		return
 	}
 
@@ -437,3 +447,20 @@ func (h *GoalHandler) ResetProjectGoal(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "goal stats reset successfully"})
 }
+
+// projectGoal parses the :goalId of the request, checking it belongs to the project
+func (h *GoalHandler) projectGoal(c *gin.Context, projectID primitive.ObjectID) (primitive.ObjectID, bool) {
+	goalID, err := primitive.ObjectIDFromHex(c.Param("goalId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goal id"})
+		return primitive.NilObjectID, false
+	}
+
+	goal, err := h.goalService.GetByID(c.Request.Context(), goalID)
+	if err != nil || goal.ProjectRef == nil || *goal.ProjectRef != projectID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return primitive.NilObjectID, false
+	}
+
+	return goalID, true
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/runtime"
 	"github.com/levskiy0/m3m/internal/runtime/modules"
 	"github.com/levskiy0/m3m/internal/service"
@@ -18,13 +19,15 @@ type ModelHandler struct {
 	modelService   *service.ModelService
 	projectService *service.ProjectService
 	runtimeManager *runtime.Manager
+	auditService   *service.AuditService
 }
 
-func NewModelHandler(modelService *service.ModelService, projectService *service.ProjectService, runtimeManager *runtime.Manager) *ModelHandler {
+func NewModelHandler(modelService *service.ModelService, projectService *service.ProjectService, runtimeManager *runtime.Manager, auditService *service.AuditService) *ModelHandler {
 	return &ModelHandler{
 		modelService:   modelService,
 		projectService: projectService,
 		runtimeManager: runtimeManager,
+		auditService:   auditService,
 	}
 }
 
@@ -48,24 +51,46 @@ func (h *ModelHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.A
 	}
 }
 
-func (h *ModelHandler) checkAccess(c *gin.Context) (primitive.ObjectID, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+func (h *ModelHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
+}
+
+// projectModel loads the model of the request, checking it belongs to the project
+func (h *ModelHandler) projectModel(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, *domain.Model, bool) {
+	projectID, ok := h.checkAccess(c, permission)
+	if !ok {
+		return primitive.NilObjectID, nil, false
+	}
+
+	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return primitive.NilObjectID, false
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
+		return primitive.NilObjectID, nil, false
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return primitive.NilObjectID, false
+	// Models of other projects are reported as missing, not forbidden
+	model, err := h.modelService.GetByID(c.Request.Context(), modelID)
+	if (err == nil && model.ProjectID != projectID) || errors.Is(err, repository.ErrModelNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model not found"})
+		return primitive.NilObjectID, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return primitive.NilObjectID, nil, false
 	}
+	return projectID, model, true
+}
 
-	return projectID, true
+// dataAuditTarget names a document of a model in the audit log
+func dataAuditTarget(model *domain.Model, dataID string) string {
+	if model == nil {
+		return dataID
+	}
+	return model.Slug + "/" + dataID
 }
 
 func (h *ModelHandler) List(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -80,7 +105,7 @@ func (h *ModelHandler) List(c *gin.Context) {
 }
 
 func (h *ModelHandler) Create(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionModels)
 	if !ok {
 		return
 	}
@@ -105,49 +130,33 @@ func (h *ModelHandler) Create(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditModelCreate, model.Slug, nil, model)
+
 	c.JSON(http.StatusCreated, model)
 }
 
 func (h *ModelHandler) Get(c *gin.Context) {
-	_, ok := h.checkAccess(c)
+	_, model, ok := h.projectModel(c, domain.PermissionView)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
-	model, err := h.modelService.GetByID(c.Request.Context(), modelID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "model not found"})
-		return
-	}
-
 	c.JSON(http.StatusOK, model)
 }
 
 func (h *ModelHandler) Update(c *gin.Context) {
-	_, ok := h.checkAccess(c)
+	projectID, before, ok := h.projectModel(c, domain.PermissionModels)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	var req domain.UpdateModelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	model, err := h.modelService.Update(c.Request.Context(), modelID, &req)
+	model, err := h.modelService.Update(c.Request.Context(), before.ID, &req)
 	if err != nil {
 		var validationErr service.ValidationErrors
 		if errors.As(err, &validationErr) {
@@ -161,38 +170,42 @@ func (h *ModelHandler) Update(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditModelUpdate, model.Slug, before, model)
+
 	c.JSON(http.StatusOK, model)
 }
 
 func (h *ModelHandler) Delete(c *gin.Context) {
-	_, ok := h.checkAccess(c)
+	projectID, before, ok := h.projectModel(c, domain.PermissionModels)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
-	if err := h.modelService.Delete(c.Request.Context(), modelID); err != nil {
+	if err := h.modelService.Delete(c.Request.Context(), before.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditModelDelete, before.Slug, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "model deleted successfully"})
 }
 
-func (h *ModelHandler) ListData(c *gin.Context) {
-	_, ok := h.checkAccess(c)
-	if !ok {
-		return
+// auditDataID returns the id of a model document as a string
+func auditDataID(data map[string]interface{}) string {
+	switch id := data["_id"].(type) {
+	case primitive.ObjectID:
+		return id.Hex()
+	case string:
+		return id
+	default:
+		return ""
 	}
+}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
+func (h *ModelHandler) ListData(c *gin.Context) {
+	_, model, ok := h.projectModel(c, domain.PermissionDataRead)
+	if !ok {
 		return
 	}
 
@@ -221,7 +234,7 @@ func (h *ModelHandler) ListData(c *gin.Context) {
 		query.Page = 1
 	}
 
-	data, total, err := h.modelService.GetData(c.Request.Context(), modelID, &query)
+	data, total, err := h.modelService.GetData(c.Request.Context(), model.ID, &query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -242,24 +255,18 @@ func (h *ModelHandler) ListData(c *gin.Context) {
 }
 
 func (h *ModelHandler) CreateData(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, model, ok := h.projectModel(c, domain.PermissionDataWrite)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	var data map[string]interface{}
 	if err := c.ShouldBindJSON(&data); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	result, err := h.modelService.CreateData(c.Request.Context(), modelID, data)
+	result, err := h.modelService.CreateData(c.Request.Context(), model.ID, data)
 	if err != nil {
 		var validationErr service.ValidationErrors
 		if errors.As(err, &validationErr) {
@@ -269,30 +276,24 @@ func (h *ModelHandler) CreateData(c *gin.Context) {
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	// Trigger model insert hook with full document (including system fields)
-	if model, err := h.modelService.GetByID(c.Request.Context(), modelID); err == nil {
-		h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookInsert, result)
-	}
+	h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookInsert, result)
+
+	recordAudit(c, h.auditService, projectID, domain.AuditDataCreate, dataAuditTarget(model, auditDataID(result)), nil, result)
 
 	c.JSON(http.StatusCreated, result)
 }
 
 func (h *ModelHandler) QueryData(c *gin.Context) {
-	_, ok := h.checkAccess(c)
+	_, model, ok := h.projectModel(c, domain.PermissionDataRead)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	var query domain.AdvancedDataQuery
 	if err := c.ShouldBindJSON(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -306,7 +307,7 @@ func (h *ModelHandler) QueryData(c *gin.Context) {
 		query.Page = 1
 	}
 
-	data, total, err := h.modelService.GetDataAdvanced(c.Request.Context(), modelID, &query)
+	data, total, err := h.modelService.GetDataAdvanced(c.Request.Context(), model.ID, &query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -327,24 +328,18 @@ func (h *ModelHandler) QueryData(c *gin.Context) {
 }
 
 func (h *ModelHandler) GetData(c *gin.Context) {
-	_, ok := h.checkAccess(c)
+	_, model, ok := h.projectModel(c, domain.PermissionDataRead)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	dataID, err := primitive.ObjectIDFromHex(c.Param("dataId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data id"})
 		return
 	}
 
-	data, err := h.modelService.GetDataByID(c.Request.Context(), modelID, dataID)
+	data, err := h.modelService.GetDataByID(c.Request.Context(), model.ID, dataID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "data not found"})
 		return
@@ -354,17 +349,11 @@ func (h *ModelHandler) GetData(c *gin.Context) {
 }
 
 func (h *ModelHandler) UpdateData(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, model, ok := h.projectModel(c, domain.PermissionDataWrite)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	dataID, err := primitive.ObjectIDFromHex(c.Param("dataId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data id"})
@@ -377,7 +366,8 @@ func (h *ModelHandler) UpdateData(c *gin.Context) {
 		return
 	}
 
-	if err := h.modelService.UpdateData(c.Request.Context(), modelID, dataID, data); err != nil {
+	before, _ := h.modelService.GetDataByID(c.Request.Context(), model.ID, dataID)
+	if err := h.modelService.UpdateData(c.Request.Context(), model.ID, dataID, data); err != nil {
 		var validationErr service.ValidationErrors
 		if errors.As(err, &validationErr) {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -391,27 +381,22 @@ func (h *ModelHandler) UpdateData(c *gin.Context) {
 	}
 
 	// Trigger model update hook with full document (including system fields)
-	if model, err := h.modelService.GetByID(c.Request.Context(), modelID); err == nil {
-		if fullData, err := h.modelService.GetDataByID(c.Request.Context(), modelID, dataID); err == nil {
-			h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookUpdate, fullData)
-		}
+	fullData, _ := h.modelService.GetDataByID(c.Request.Context(), model.ID, dataID)
+	if fullData != nil {
+		h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookUpdate, fullData)
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditDataUpdate, dataAuditTarget(model, dataID.Hex()), before, fullData)
+
 	c.JSON(http.StatusOK, gin.H{"message": "data updated successfully"})
 }
 
 func (h *ModelHandler) DeleteData(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, model, ok := h.projectModel(c, domain.PermissionDataWrite)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	dataID, err := primitive.ObjectIDFromHex(c.Param("dataId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data id"})
@@ -419,39 +404,33 @@ func (h *ModelHandler) DeleteData(c *gin.Context) {
 	}
 
 	// Fetch data before deleting for the hook
-	dataForHook, _ := h.modelService.GetDataByID(c.Request.Context(), modelID, dataID)
+	dataForHook, _ := h.modelService.GetDataByID(c.Request.Context(), model.ID, dataID)
 
-	if err := h.modelService.DeleteData(c.Request.Context(), modelID, dataID); err != nil {
+	if err := h.modelService.DeleteData(c.Request.Context(), model.ID, dataID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Trigger model delete hook with all fields
-	if model, err := h.modelService.GetByID(c.Request.Context(), modelID); err == nil {
-		if dataForHook != nil {
-			h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, dataForHook)
-		} else {
-			h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, map[string]interface{}{
-				"_id": dataID.Hex(),
-			})
-		}
+	if dataForHook != nil {
+		h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, dataForHook)
+	} else {
+		h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, map[string]interface{}{
+			"_id": dataID.Hex(),
+		})
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditDataDelete, dataAuditTarget(model, dataID.Hex()), dataForHook, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "data deleted successfully"})
 }
 
 func (h *ModelHandler) BulkDeleteData(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, model, ok := h.projectModel(c, domain.PermissionDataWrite)
 	if !ok {
 		return
 	}
 
-	modelID, err := primitive.ObjectIDFromHex(c.Param("modelId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid model id"})
-		return
-	}
-
 	var req struct {
 		IDs []string `json:"ids" binding:"required,min=1"`
 	}
@@ -473,7 +452,7 @@ func (h *ModelHandler) BulkDeleteData(c *gin.Context) {
 	// Fetch all data before deleting for the hooks
 	dataForHooks := make([]map[string]interface{}, 0, len(objectIDs))
 	for _, id := range objectIDs {
-		data, _ := h.modelService.GetDataByID(c.Request.Context(), modelID, id)
+		data, _ := h.modelService.GetDataByID(c.Request.Context(), model.ID, id)
 		if data != nil {
 			dataForHooks = append(dataForHooks, data)
 		} else {
@@ -481,18 +460,23 @@ func (h *ModelHandler) BulkDeleteData(c *gin.Context) {
 		}
 	}
 
-	deletedCount, err := h.modelService.DeleteManyData(c.Request.Context(), modelID, objectIDs)
+	deletedCount, err := h.modelService.DeleteManyData(c.Request.Context(), model.ID, objectIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Trigger model delete hook for each deleted item with all fields
-	if model, err := h.modelService.GetByID(c.Request.Context(), modelID); err == nil {
-		for _, data := range dataForHooks {
-			h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, data)
-		}
+	for _, data := range dataForHooks {
+		h.runtimeManager.TriggerModelHook(projectID, model.Slug, modules.ModelHookDelete, data)
+	}
+
+	// Documents are keyed by id, so the diff lists each deleted one
+	deleted := make(map[string]any, len(dataForHooks))
+	for _, data := range dataForHooks {
+		deleted[auditDataID(data)] = data
 	}
+	recordAudit(c, h.auditService, projectID, domain.AuditDataBulkDelete, model.Slug, deleted, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "data deleted successfully",
@@ -1,25 +1,34 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/service"
 )
 
 type PipelineHandler struct {
 	pipelineService *service.PipelineService
 	projectService  *service.ProjectService
+	gitService      *service.GitService
+	auditService    *service.AuditService
 }
 
-func NewPipelineHandler(pipelineService *service.PipelineService, projectService *service.ProjectService) *PipelineHandler {
+func NewPipelineHandler(pipelineService *service.PipelineService, projectService *service.ProjectService, gitService *service.GitService, auditService *service.AuditService) *PipelineHandler {
 	return &PipelineHandler{
 		pipelineService: pipelineService,
 		projectService:  projectService,
+		gitService:      gitService,
+		auditService:    auditService,
 	}
 }
 
@@ -44,27 +53,46 @@ func (h *PipelineHandler) Register(r *gin.RouterGroup, authMiddleware *middlewar
 		pipeline.POST("/releases", h.CreateRelease)
 		pipeline.DELETE("/releases/:releaseId", h.DeleteRelease)
 		pipeline.POST("/releases/:releaseId/activate", h.ActivateRelease)
+
+		// Git import/export
+		pipeline.POST("/git/push", h.GitPush)
+		pipeline.POST("/git/pull", h.GitPull)
 	}
 }
 
-func (h *PipelineHandler) checkAccess(c *gin.Context) (primitive.ObjectID, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return primitive.NilObjectID, false
-	}
+func (h *PipelineHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
+}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return primitive.NilObjectID, false
+// branchAuditState is the audited state of a branch. Files are recorded by a
+// hash of their code, so the log shows which files changed without copying them.
+func branchAuditState(branch *domain.Branch) any {
+	if branch == nil {
+		return nil
+	}
+	files := make(map[string]string, len(branch.Files))
+	for _, file := range branch.Files {
+		sum := sha256.Sum256([]byte(file.Code))
+		files[file.Name] = hex.EncodeToString(sum[:6])
 	}
+	return gin.H{
+		"name":                 branch.Name,
+		"parent_branch":        branch.ParentBranch,
+		"created_from_release": branch.CreatedFromRelease,
+		"files":                files,
+	}
+}
 
-	return projectID, true
+// branchAuditTarget names a file of a branch in the audit log
+func branchAuditTarget(branch *domain.Branch, fileName string) string {
+	if branch == nil {
+		return fileName
+	}
+	return branch.Name + "/" + fileName
 }
 
 func (h *PipelineHandler) ListBranches(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -79,7 +107,7 @@ func (h *PipelineHandler) ListBranches(c *gin.Context) {
 }
 
 func (h *PipelineHandler) CreateBranch(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -96,11 +124,13 @@ func (h *PipelineHandler) CreateBranch(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditBranchCreate, branch.Name, nil, branchAuditState(branch))
+
 	c.JSON(http.StatusCreated, branch)
 }
 
 func (h *PipelineHandler) GetBranch(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -121,7 +151,7 @@ func (h *PipelineHandler) GetBranch(c *gin.Context) {
 }
 
 func (h *PipelineHandler) UpdateBranch(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -138,17 +168,20 @@ func (h *PipelineHandler) UpdateBranch(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	branch, err := h.pipelineService.UpdateBranchByID(c.Request.Context(), projectID, branchID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditBranchUpdate, branch.Name, branchAuditState(before), branchAuditState(branch))
+
 	c.JSON(http.StatusOK, branch)
 }
 
 func (h *PipelineHandler) ResetBranch(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -165,17 +198,20 @@ func (h *PipelineHandler) ResetBranch(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	branch, err := h.pipelineService.ResetBranchByID(c.Request.Context(), projectID, branchID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditBranchReset, branch.Name, branchAuditState(before), branchAuditState(branch))
+
 	c.JSON(http.StatusOK, branch)
 }
 
 func (h *PipelineHandler) DeleteBranch(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -186,16 +222,24 @@ func (h *PipelineHandler) DeleteBranch(c *gin.Context) {
 		return
 	}
 
+	before, err := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		return
+	}
+
 	if err := h.pipelineService.DeleteBranchByID(c.Request.Context(), projectID, branchID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditBranchDelete, before.Name, branchAuditState(before), nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "branch deleted successfully"})
 }
 
 func (h *PipelineHandler) ListReleases(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -210,7 +254,7 @@ func (h *PipelineHandler) ListReleases(c *gin.Context) {
 }
 
 func (h *PipelineHandler) CreateRelease(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionReleases)
 	if !ok {
 		return
 	}
@@ -227,11 +271,17 @@ func (h *PipelineHandler) CreateRelease(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditReleaseCreate, release.Version, nil, gin.H{
+		"branch":  req.BranchName,
+		"comment": release.Comment,
+		"tag":     release.Tag,
+	})
+
 	c.JSON(http.StatusCreated, release)
 }
 
 func (h *PipelineHandler) DeleteRelease(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionReleases)
 	if !ok {
 		return
 	}
@@ -242,16 +292,27 @@ func (h *PipelineHandler) DeleteRelease(c *gin.Context) {
 		return
 	}
 
+	release, err := h.pipelineService.GetReleaseByID(c.Request.Context(), projectID, releaseID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "release not found"})
+		return
+	}
+
 	if err := h.pipelineService.DeleteReleaseByID(c.Request.Context(), projectID, releaseID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditReleaseDelete, release.Version, gin.H{
+		"comment": release.Comment,
+		"tag":     release.Tag,
+	}, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "release deleted successfully"})
 }
 
 func (h *PipelineHandler) ActivateRelease(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionReleases)
 	if !ok {
 		return
 	}
@@ -262,18 +323,30 @@ func (h *PipelineHandler) ActivateRelease(c *gin.Context) {
 		return
 	}
 
+	release, err := h.pipelineService.GetReleaseByID(c.Request.Context(), projectID, releaseID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "release not found"})
+		return
+	}
+	var previous any
+	if active, err := h.pipelineService.GetActiveRelease(c.Request.Context(), projectID); err == nil {
+		previous = gin.H{"active_version": active.Version}
+	}
+
 	if err := h.pipelineService.ActivateReleaseByID(c.Request.Context(), projectID, releaseID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditReleaseActivate, release.Version, previous, gin.H{"active_version": release.Version})
+
 	c.JSON(http.StatusOK, gin.H{"message": "release activated successfully"})
 }
 
 // File operations
 
 func (h *PipelineHandler) CreateFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -290,17 +363,20 @@ func (h *PipelineHandler) CreateFile(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	branch, err := h.pipelineService.AddFile(c.Request.Context(), projectID, branchID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditFileCreate, branchAuditTarget(branch, req.Name), branchAuditState(before), branchAuditState(branch))
+
 	c.JSON(http.StatusCreated, branch)
 }
 
 func (h *PipelineHandler) UpdateFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -323,16 +399,20 @@ func (h *PipelineHandler) UpdateFile(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	if err := h.pipelineService.UpdateFile(c.Request.Context(), projectID, branchID, fileName, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	after, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
+	recordAudit(c, h.auditService, projectID, domain.AuditFileUpdate, branchAuditTarget(after, fileName), branchAuditState(before), branchAuditState(after))
+
 	c.JSON(http.StatusOK, gin.H{"message": "file updated successfully"})
 }
 
 func (h *PipelineHandler) DeleteFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -349,17 +429,20 @@ func (h *PipelineHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	branch, err := h.pipelineService.DeleteFile(c.Request.Context(), projectID, branchID, fileName)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditFileDelete, branchAuditTarget(branch, fileName), branchAuditState(before), branchAuditState(branch))
+
 	c.JSON(http.StatusOK, branch)
 }
 
 func (h *PipelineHandler) RenameFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
 	if !ok {
 		return
 	}
@@ -382,11 +465,123 @@ func (h *PipelineHandler) RenameFile(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.pipelineService.GetBranchByID(c.Request.Context(), projectID, branchID)
 	branch, err := h.pipelineService.RenameFile(c.Request.Context(), projectID, branchID, fileName, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditFileRename, branchAuditTarget(branch, fileName), branchAuditState(before), branchAuditState(branch))
+
 	c.JSON(http.StatusOK, branch)
 }
+
+// Git import/export
+
+// checkGitRemote allows local repository paths for root users only
+func (h *PipelineHandler) checkGitRemote(c *gin.Context, remote *domain.GitRemote) bool {
+	user := middleware.GetCurrentUser(c)
+	if service.IsLocalGitRemote(remote.URL) && !user.IsRoot {
+		c.JSON(http.StatusForbidden, gin.H{"error": "local repository paths are allowed for root users only"})
+		return false
+	}
+	return true
+}
+
+// gitAuditState is the audited outcome of a push or pull, without the
+// credentials of the remote
+func gitAuditState(remote *domain.GitRemote, result *domain.GitSyncResult) any {
+	remoteURL := remote.URL
+	if u, err := url.Parse(remote.URL); err == nil && u.User != nil {
+		u.User = nil
+		remoteURL = u.String()
+	}
+	return gin.H{
+		"remote": remoteURL,
+		"commit": result.Commit,
+		"tags":   result.Tags,
+	}
+}
+
+func gitErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrGitUnsupportedRemote),
+		errors.Is(err, service.ErrGitForbiddenRemote),
+		errors.Is(err, service.ErrGitRemoteTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrGitEmptyRemote),
+		errors.Is(err, service.ErrGitBranchNotFound),
+		errors.Is(err, repository.ErrBranchNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func (h *PipelineHandler) GitPush(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
+	if !ok {
+		return
+	}
+
+	var req domain.GitPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.checkGitRemote(c, &req.GitRemote) {
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	result, err := h.gitService.Push(c.Request.Context(), projectID, &req, user)
+	if err != nil {
+		c.JSON(gitErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, h.auditService, projectID, domain.AuditGitPush, req.BranchName, nil, gitAuditState(&req.GitRemote, result))
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *PipelineHandler) GitPull(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionCodeEdit)
+	if !ok {
+		return
+	}
+
+	var req domain.GitPullRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Tags become releases
+	if req.IncludeReleases {
+		if _, ok := h.checkAccess(c, domain.PermissionReleases); !ok {
+			return
+		}
+	}
+
+	if !h.checkGitRemote(c, &req.GitRemote) {
+		return
+	}
+
+	before, _ := h.pipelineService.GetBranch(c.Request.Context(), projectID, req.BranchName)
+	result, err := h.gitService.Pull(c.Request.Context(), projectID, &req)
+	if err != nil {
+		c.JSON(gitErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	after, _ := h.pipelineService.GetBranch(c.Request.Context(), projectID, req.BranchName)
+	recordAudit(c, h.auditService, projectID, domain.AuditGitPull, req.BranchName, gin.H{"branch": branchAuditState(before)}, gin.H{
+		"branch": branchAuditState(after),
+		"git":    gitAuditState(&req.GitRemote, result),
+	})
+
+	c.JSON(http.StatusOK, result)
+}
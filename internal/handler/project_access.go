@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/service"
+)
+
+// requireProjectPermission parses the :id project of the request and checks
+// that the role of the current user in it grants permission. On failure the
+// error response is written and false returned.
+func requireProjectPermission(c *gin.Context, projectService *service.ProjectService, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return primitive.NilObjectID, false
+	}
+
+	user := middleware.GetCurrentUser(c)
+	role, ok := projectService.Role(c.Request.Context(), user.ID, projectID, user.IsRoot)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return primitive.NilObjectID, false
+	}
+	if !role.Allows(permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("the %s role does not allow %s", role, permission)})
+		return primitive.NilObjectID, false
+	}
+	if token := middleware.GetAccessToken(c); token != nil && !token.Allows(projectID, permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("the access token does not allow %s on this project", permission)})
+		return primitive.NilObjectID, false
+	}
+
+	return projectID, true
+}
+
+// rejectAccessToken refuses requests made with a personal access token, for
+// project routes outside the permission matrix
+func rejectAccessToken(c *gin.Context) bool {
+	if middleware.GetAccessToken(c) == nil {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "access tokens cannot be used on this route"})
+	return true
+}
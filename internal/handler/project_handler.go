@@ -1,25 +1,38 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/service"
 )
 
 type ProjectHandler struct {
 	projectService  *service.ProjectService
+	userService     *service.UserService
 	pipelineService *service.PipelineService
+	bundleService   *service.BundleService
+	quotaService    *service.QuotaService
+	auditService    *service.AuditService
 }
 
-func NewProjectHandler(projectService *service.ProjectService, pipelineService *service.PipelineService) *ProjectHandler {
+func NewProjectHandler(projectService *service.ProjectService, userService *service.UserService, pipelineService *service.PipelineService, bundleService *service.BundleService, quotaService *service.QuotaService, auditService *service.AuditService) *ProjectHandler {
 	return &ProjectHandler{
 		projectService:  projectService,
+		userService:     userService,
 		pipelineService: pipelineService,
+		bundleService:   bundleService,
+		quotaService:    quotaService,
+		auditService:    auditService,
 	}
 }
 
@@ -29,11 +42,17 @@ func (h *ProjectHandler) Register(r *gin.RouterGroup, authMiddleware *middleware
 	{
 		projects.GET("", h.List)
 		projects.POST("", authMiddleware.RequirePermission("create_projects"), h.Create)
+		projects.POST("/import", authMiddleware.RequirePermission("create_projects"), h.Import)
 		projects.GET("/:id", h.Get)
 		projects.PUT("/:id", h.Update)
 		projects.DELETE("/:id", h.Delete)
 		projects.POST("/:id/regenerate-key", h.RegenerateKey)
+		projects.POST("/:id/export", h.Export)
+		projects.GET("/:id/quota", h.GetQuota)
+		projects.PUT("/:id/quota", h.SetQuota)
+		projects.GET("/:id/members", h.ListMembers)
 		projects.POST("/:id/members", h.AddMember)
+		projects.PUT("/:id/members/:userId", h.SetMemberRole)
 		projects.DELETE("/:id/members/:userId", h.RemoveMember)
 	}
 }
@@ -46,6 +65,16 @@ func (h *ProjectHandler) List(c *gin.Context) {
 		return
 	}
 
+	if token := middleware.GetAccessToken(c); token != nil {
+		allowed := projects[:0]
+		for _, project := range projects {
+			if token.AllowsProject(project.ID) {
+				allowed = append(allowed, project)
+			}
+		}
+		projects = allowed
+	}
+
 	c.JSON(http.StatusOK, projects)
 }
 
@@ -65,32 +94,76 @@ func (h *ProjectHandler) Create(c *gin.Context) {
 
 	h.pipelineService.EnsureDevelopBranch(c.Request.Context(), project.ID)
 
+	recordAudit(c, h.auditService, project.ID, domain.AuditProjectCreate, project.Slug, nil, project)
+
 	c.JSON(http.StatusCreated, project)
 }
 
 func (h *ProjectHandler) Get(c *gin.Context) {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	project, err := h.projectService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, id, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) Update(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionSettings)
+	if !ok {
 		return
 	}
 
-	project, err := h.projectService.GetByID(c.Request.Context(), id)
+	var req domain.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, _ := h.projectService.GetByID(c.Request.Context(), id)
+	project, err := h.projectService.Update(c.Request.Context(), id, &req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidTimezone) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, id, domain.AuditProjectUpdate, project.Slug, before, project)
+
 	c.JSON(http.StatusOK, project)
 }
 
-func (h *ProjectHandler) Update(c *gin.Context) {
+// GetQuota returns the quotas of a project with its current usage
+func (h *ProjectHandler) GetQuota(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	status, err := h.quotaService.Status(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetQuota overrides the quotas of a project; only root may change them
+func (h *ProjectHandler) SetQuota(c *gin.Context) {
+	if rejectAccessToken(c) {
+		return
+	}
+
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
@@ -98,27 +171,44 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 	}
 
 	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, id, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !user.IsRoot {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only root can change quotas"})
 		return
 	}
 
-	var req domain.UpdateProjectRequest
+	var req domain.ProjectQuota
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	project, err := h.projectService.Update(c.Request.Context(), id, &req)
+	before, err := h.projectService.GetByID(c.Request.Context(), id)
 	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	if _, err := h.projectService.SetQuota(c.Request.Context(), id, req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, project)
+	recordAudit(c, h.auditService, id, domain.AuditProjectQuota, before.Slug, before.Quota, req)
+
+	status, err := h.quotaService.Status(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
 }
 
 func (h *ProjectHandler) Delete(c *gin.Context) {
+	if rejectAccessToken(c) {
+		return
+	}
+
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
@@ -142,74 +232,128 @@ func (h *ProjectHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.auditService, id, domain.AuditProjectDelete, project.Slug, project, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "project deleted successfully"})
 }
 
 func (h *ProjectHandler) RegenerateKey(c *gin.Context) {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, id, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionSettings)
+	if !ok {
 		return
 	}
 
+	before, _ := h.projectService.GetByID(c.Request.Context(), id)
 	project, err := h.projectService.RegenerateAPIKey(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	var beforeKey any
+	if before != nil {
+		beforeKey = gin.H{"api_key": before.APIKey}
+	}
+	recordAudit(c, h.auditService, id, domain.AuditProjectKey, project.Slug, beforeKey, gin.H{"api_key": project.APIKey})
+
 	c.JSON(http.StatusOK, project)
 }
 
 func (h *ProjectHandler) AddMember(c *gin.Context) {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionMembers)
+	if !ok {
+		return
+	}
+
+	var req domain.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	project, err := h.projectService.GetByID(c.Request.Context(), id)
+	member, err := h.userService.GetByID(c.Request.Context(), memberID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	if project.OwnerID != user.ID && !user.IsRoot {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only owner can manage members"})
+	if err := h.projectService.AddMember(c.Request.Context(), id, memberID, req.Role); err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	var req domain.AddMemberRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	recordAudit(c, h.auditService, id, domain.AuditMemberAdd, member.Email, nil, h.memberRole(c, id, memberID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "member added successfully"})
+}
+
+func (h *ProjectHandler) RemoveMember(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionMembers)
+	if !ok {
 		return
 	}
 
-	memberID, err := primitive.ObjectIDFromHex(req.UserID)
+	memberID, err := primitive.ObjectIDFromHex(c.Param("userId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
 
-	if err := h.projectService.AddMember(c.Request.Context(), id, memberID); err != nil {
+	role := h.memberRole(c, id, memberID)
+	if err := h.projectService.RemoveMember(c.Request.Context(), id, memberID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "member added successfully"})
+	recordAudit(c, h.auditService, id, domain.AuditMemberRemove, h.memberAuditTarget(c, memberID), role, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "member removed successfully"})
 }
 
-func (h *ProjectHandler) RemoveMember(c *gin.Context) {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+// ListMembers returns the owner and members of a project with their roles,
+// the permission matrix and the access of the current user
+func (h *ProjectHandler) ListMembers(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	project, err := h.projectService.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	members := make([]domain.ProjectMember, 0, len(project.Members)+1)
+	for _, userID := range append([]primitive.ObjectID{project.OwnerID}, project.Members...) {
+		role, _ := project.MemberRole(userID)
+		member := domain.ProjectMember{UserID: userID, Role: role, Owner: userID == project.OwnerID}
+		if u, err := h.userService.GetByID(c.Request.Context(), userID); err == nil {
+			member.Email, member.Name, member.Avatar = u.Email, u.Name, u.Avatar
+		}
+		members = append(members, member)
+	}
+
+	user := middleware.GetCurrentUser(c)
+	role, _ := h.projectService.Role(c.Request.Context(), user.ID, id, user.IsRoot)
+
+	c.JSON(http.StatusOK, domain.ProjectMembersResponse{
+		Members: members,
+		Roles:   domain.ProjectRolePermissions,
+		Current: domain.ProjectAccess{Role: role, Permissions: domain.ProjectRolePermissions[role]},
+	})
+}
+
+// SetMemberRole changes the role of a member; the owner always stays admin
+func (h *ProjectHandler) SetMemberRole(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionMembers)
+	if !ok {
 		return
 	}
 
@@ -219,22 +363,143 @@ func (h *ProjectHandler) RemoveMember(c *gin.Context) {
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
+	var req domain.SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := h.memberRole(c, id, memberID)
+	if err := h.projectService.SetMemberRole(c.Request.Context(), id, memberID, req.Role); err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, h.auditService, id, domain.AuditMemberRole, h.memberAuditTarget(c, memberID), before, h.memberRole(c, id, memberID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "member role updated successfully"})
+}
+
+// memberRole is the audited state of a member, nil when not a member
+func (h *ProjectHandler) memberRole(c *gin.Context, projectID, userID primitive.ObjectID) any {
+	project, err := h.projectService.GetByID(c.Request.Context(), projectID)
+	if err != nil {
+		return nil
+	}
+	role, ok := project.MemberRole(userID)
+	if !ok {
+		return nil
+	}
+	return gin.H{"role": role}
+}
+
+// memberAuditTarget names a member by email, or by id once the user is gone
+func (h *ProjectHandler) memberAuditTarget(c *gin.Context, userID primitive.ObjectID) string {
+	if user, err := h.userService.GetByID(c.Request.Context(), userID); err == nil {
+		return user.Email
+	}
+	return userID.Hex()
+}
+
+func memberErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidProjectRole):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrProjectMemberNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// Export downloads the project as a bundle archive
+func (h *ProjectHandler) Export(c *gin.Context) {
+	id, ok := requireProjectPermission(c, h.projectService, domain.PermissionSettings)
+	if !ok {
+		return
+	}
+
+	var req domain.ExportProjectRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	project, err := h.projectService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
 		return
 	}
 
-	if project.OwnerID != user.ID && !user.IsRoot {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only owner can manage members"})
+	// Build the archive in a temp file so errors can still be reported as JSON
+	tmpFile, err := os.CreateTemp("", "m3m-export-*.zip")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer os.Remove(tmpFile.Name())
 
-	if err := h.projectService.RemoveMember(c.Request.Context(), id, memberID); err != nil {
+	_, err = h.bundleService.Export(c.Request.Context(), id, &req, tmpFile)
+	tmpFile.Close()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "member removed successfully"})
+	recordAudit(c, h.auditService, id, domain.AuditProjectExport, project.Slug, nil, gin.H{
+		"include_data":  req.IncludeData,
+		"exclude_files": req.ExcludeFiles,
+		"encrypted":     req.Passphrase != "",
+	})
+
+	filename := fmt.Sprintf("%s-%s.zip", project.Slug, time.Now().Format("20060102-150405"))
+	c.FileAttachment(tmpFile.Name(), filename)
+}
+
+// Import creates a new project from an uploaded bundle archive
+func (h *ProjectHandler) Import(c *gin.Context) {
+	var req domain.ImportProjectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	userID := middleware.GetCurrentUserID(c)
+	result, err := h.bundleService.Import(c.Request.Context(), file, fileHeader.Size, &req, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBundleInvalid),
+			errors.Is(err, service.ErrBundleUnsupportedVersion),
+			errors.Is(err, service.ErrBundleBadPassphrase),
+			service.IsValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrBundleTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrQuotaExceeded):
+			c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrProjectSlugExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	recordAudit(c, h.auditService, result.Project.ID, domain.AuditProjectImport, result.Project.Slug, nil, result.Project)
+
+	c.JSON(http.StatusCreated, result)
 }
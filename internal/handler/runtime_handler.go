@@ -1,17 +1,19 @@
 package handler
 
 import (
-	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	goruntime "runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/domain"
@@ -29,9 +31,11 @@ type RuntimeHandler struct {
 	projectService  *service.ProjectService
 	pipelineService *service.PipelineService
 	storageService  *service.StorageService
+	logService      *service.LogService
 	actionService   *service.ActionService
 	pluginLoader    *plugin.Loader
 	broadcaster     *websocket.Broadcaster
+	auditService    *service.AuditService
 }
 
 func NewRuntimeHandler(
@@ -39,18 +43,22 @@ func NewRuntimeHandler(
 	projectService *service.ProjectService,
 	pipelineService *service.PipelineService,
 	storageService *service.StorageService,
+	logService *service.LogService,
 	actionService *service.ActionService,
 	pluginLoader *plugin.Loader,
 	broadcaster *websocket.Broadcaster,
+	auditService *service.AuditService,
 ) *RuntimeHandler {
 	return &RuntimeHandler{
 		runtimeManager:  runtimeManager,
 		projectService:  projectService,
 		pipelineService: pipelineService,
 		storageService:  storageService,
+		logService:      logService,
 		actionService:   actionService,
 		pluginLoader:    pluginLoader,
 		broadcaster:     broadcaster,
+		auditService:    auditService,
 	}
 }
 
@@ -64,6 +72,7 @@ func (h *RuntimeHandler) Register(r *gin.RouterGroup, authMiddleware *middleware
 		runtime.GET("/status", h.Status)
 		runtime.GET("/monitor", h.Monitor)
 		runtime.GET("/logs", h.Logs)
+		runtime.GET("/logs/files", h.LogFiles)
 		runtime.GET("/logs/download", h.DownloadLogs)
 		runtime.GET("/state", h.State)
 	}
@@ -83,24 +92,12 @@ func (h *RuntimeHandler) RegisterPublicRoutes(r *gin.Engine) {
 	r.Any("/r/:projectSlug/*route", h.HandleRoute)
 }
 
-func (h *RuntimeHandler) checkAccess(c *gin.Context) (primitive.ObjectID, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return primitive.NilObjectID, false
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return primitive.NilObjectID, false
-	}
-
-	return projectID, true
+func (h *RuntimeHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (primitive.ObjectID, bool) {
+	return requireProjectPermission(c, h.projectService, permission)
 }
 
 func (h *RuntimeHandler) Start(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionRuntime)
 	if !ok {
 		return
 	}
@@ -155,9 +152,6 @@ func (h *RuntimeHandler) Start(c *gin.Context) {
 		}
 	}
 
-	// Clear old logs
-	h.storageService.ClearLogs(projectID.Hex())
-
 	// Start runtime with background context (runtime should outlive HTTP request)
 	if err := h.runtimeManager.Start(context.Background(), projectID, files); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -175,20 +169,31 @@ func (h *RuntimeHandler) Start(c *gin.Context) {
 		h.broadcaster.BroadcastMonitorNow(projectID)
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditRuntimeStart, runningSource, nil, gin.H{"running_source": runningSource})
+
 	c.JSON(http.StatusOK, gin.H{"message": "project started", "runningSource": runningSource})
 }
 
 func (h *RuntimeHandler) Stop(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionRuntime)
 	if !ok {
 		return
 	}
 
+	var previous any
+	target := ""
+	if project, err := h.projectService.GetByID(c.Request.Context(), projectID); err == nil {
+		previous = gin.H{"running_source": project.RunningSource}
+		target = project.RunningSource
+	}
+
 	if err := h.runtimeManager.Stop(projectID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditRuntimeStop, target, previous, nil)
+
 	// Update project status and clear running source
 	h.projectService.UpdateStatus(c.Request.Context(), projectID, domain.ProjectStatusStopped)
 	h.projectService.SetRunningSource(c.Request.Context(), projectID, "")
@@ -202,7 +207,7 @@ func (h *RuntimeHandler) Stop(c *gin.Context) {
 }
 
 func (h *RuntimeHandler) Restart(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionRuntime)
 	if !ok {
 		return
 	}
@@ -214,16 +219,14 @@ func (h *RuntimeHandler) Restart(c *gin.Context) {
 	// Body is optional - ignore EOF errors
 	c.ShouldBindJSON(&req)
 
-	// Get current project to check running source BEFORE stopping
+	// Get current project to check running source
 	project, err := h.projectService.GetByID(c.Request.Context(), projectID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
 		return
 	}
 
-	// Stop the runtime (ignore error if not running)
-	h.runtimeManager.Stop(projectID)
-
+	// The running runtime keeps serving until the new one has booted (blue/green)
 	var files []domain.CodeFile
 	var runningSource string
 
@@ -284,13 +287,12 @@ func (h *RuntimeHandler) Restart(c *gin.Context) {
 		}
 	}
 
-	// Clear old logs
-	h.storageService.ClearLogs(projectID.Hex())
-
 	// Start runtime with background context (runtime should outlive HTTP request)
 	if err := h.runtimeManager.Start(context.Background(), projectID, files); err != nil {
-		// Update status to stopped on failure
-		h.projectService.UpdateStatus(c.Request.Context(), projectID, domain.ProjectStatusStopped)
+		// Previous runtime stays active if the new one failed to boot
+		if !h.runtimeManager.IsRunning(projectID) {
+			h.projectService.UpdateStatus(c.Request.Context(), projectID, domain.ProjectStatusStopped)
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -313,11 +315,14 @@ func (h *RuntimeHandler) Restart(c *gin.Context) {
 		h.broadcaster.BroadcastMonitorNow(projectID)
 	}
 
+	recordAudit(c, h.auditService, projectID, domain.AuditRuntimeRestart, runningSource,
+		gin.H{"running_source": project.RunningSource}, gin.H{"running_source": runningSource})
+
 	c.JSON(http.StatusOK, gin.H{"message": "project restarted", "runningSource": runningSource})
 }
 
 func (h *RuntimeHandler) Status(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -341,7 +346,7 @@ func (h *RuntimeHandler) Status(c *gin.Context) {
 }
 
 func (h *RuntimeHandler) Monitor(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -357,123 +362,153 @@ func (h *RuntimeHandler) Monitor(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-type LogEntry struct {
-	Timestamp string `json:"timestamp"`
-	Level     string `json:"level"`
-	Message   string `json:"message"`
-}
-
+// Logs returns structured log records of the current run.
+// Query params: level (comma separated), min_level, from, to (RFC3339),
+// q (text), source (prefix), request_id, field (key:value, repeatable), limit
 func (h *RuntimeHandler) Logs(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
 
-	logsPath := h.storageService.GetLogsPath(projectID.Hex())
-	entries, err := os.ReadDir(logsPath)
+	query, err := parseLogQuery(c)
 	if err != nil {
-		c.JSON(http.StatusOK, []LogEntry{})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Find latest log file
-	var latestLog string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
-			latestLog = filepath.Join(logsPath, entry.Name())
-		}
+	logs, err := h.logService.Query(projectID.Hex(), query)
+	if err != nil {
+		h.logFileError(c, err)
+		return
 	}
 
-	if latestLog == "" {
-		c.JSON(http.StatusOK, []LogEntry{})
-		return
+	c.JSON(http.StatusOK, logs)
+}
+
+func parseLogQuery(c *gin.Context) (*domain.LogQuery, error) {
+	query := &domain.LogQuery{
+		Text:      c.Query("q"),
+		Source:    c.Query("source"),
+		RequestID: c.Query("request_id"),
+		File:      c.Query("file"),
 	}
 
-	// Read log file
-	file, err := os.Open(latestLog)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if levels := c.Query("level"); levels != "" {
+		for _, level := range strings.Split(levels, ",") {
+			query.Levels = append(query.Levels, domain.LogLevel(strings.ToLower(strings.TrimSpace(level))))
+		}
+	} else if minLevel := c.Query("min_level"); minLevel != "" {
+		rank := domain.LogLevelRank(domain.LogLevel(strings.ToLower(minLevel)))
+		for _, level := range []domain.LogLevel{domain.LogLevelDebug, domain.LogLevelInfo, domain.LogLevelWarn, domain.LogLevelError} {
+			if domain.LogLevelRank(level) >= rank {
+				query.Levels = append(query.Levels, level)
+			}
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, errors.New("invalid from: expected RFC3339 time")
+		}
+		query.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, errors.New("invalid to: expected RFC3339 time")
+		}
+		query.To = &t
 	}
-	defer file.Close()
 
-	logs := make([]LogEntry, 0)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry := parseLogLine(line)
-		logs = append(logs, entry)
+	for _, field := range c.QueryArray("field") {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || key == "" {
+			return nil, errors.New("invalid field filter: expected key:value")
+		}
+		if query.Fields == nil {
+			query.Fields = make(map[string]string)
+		}
+		query.Fields[key] = value
 	}
 
-	// Return last 1000 entries max
-	if len(logs) > 1000 {
-		logs = logs[len(logs)-1000:]
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, errors.New("invalid limit")
+		}
+		query.Limit = n
 	}
 
-	c.JSON(http.StatusOK, logs)
+	return query, nil
 }
 
-// parseLogLine parses log line in format: [2006-01-02 15:04:05] [LEVEL] message
-func parseLogLine(line string) LogEntry {
-	entry := LogEntry{
-		Timestamp: "",
-		Level:     "info",
-		Message:   line,
-	}
-
-	// Try to parse timestamp: [2006-01-02 15:04:05]
-	if len(line) > 21 && line[0] == '[' {
-		closeBracket := strings.Index(line, "]")
-		if closeBracket > 0 {
-			// Convert "2006-01-02 15:04:05" to ISO format "2006-01-02T15:04:05Z" (Z indicates UTC)
-			ts := line[1:closeBracket]
-			entry.Timestamp = strings.Replace(ts, " ", "T", 1) + "Z"
-			line = strings.TrimPrefix(line[closeBracket+1:], " ")
-
-			// Try to parse level: [LEVEL]
-			if len(line) > 2 && line[0] == '[' {
-				levelEnd := strings.Index(line, "]")
-				if levelEnd > 0 {
-					level := strings.ToLower(line[1:levelEnd])
-					if level == "debug" || level == "info" || level == "warn" || level == "error" {
-						entry.Level = level
-					}
-					entry.Message = strings.TrimPrefix(line[levelEnd+1:], " ")
-				}
-			}
-		}
+// LogFiles lists the current and rotated log files of a project
+func (h *RuntimeHandler) LogFiles(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	files, err := h.logService.ListFiles(projectID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	return entry
+	c.JSON(http.StatusOK, files)
 }
 
+// DownloadLogs downloads the current log file, or a historical one with file=<name>,
+// as text, or as raw JSON lines with format=jsonl
 func (h *RuntimeHandler) DownloadLogs(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
 
-	logsPath := h.storageService.GetLogsPath(projectID.Hex())
-	entries, err := os.ReadDir(logsPath)
+	path, err := h.logService.LogFilePath(projectID.Hex(), c.Query("file"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no logs found"})
+		h.logFileError(c, err)
 		return
 	}
 
-	// Find latest log file
-	var latestLog string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
-			latestLog = filepath.Join(logsPath, entry.Name())
-		}
+	file, err := service.OpenLogFile(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	defer file.Close()
 
-	if latestLog == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no logs found"})
+	name := "project"
+	if c.Query("file") != "" {
+		name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".log")
+	}
+
+	if c.Query("format") == "jsonl" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl"`, name))
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		io.Copy(c.Writer, file)
 		return
 	}
 
-	c.FileAttachment(latestLog, "project.log")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.log"`, name))
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	service.WriteLogText(file, c.Writer)
+}
+
+func (h *RuntimeHandler) logFileError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrFileNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no logs found"})
+	case service.ErrInvalidPath:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid log file"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 func (h *RuntimeHandler) Types(c *gin.Context) {
@@ -553,6 +588,13 @@ func (h *RuntimeHandler) HandleRoute(c *gin.Context) {
 		return
 	}
 
+	// Correlate runtime log records with the request
+	requestID := c.GetHeader("X-Request-Id")
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	c.Header("X-Request-Id", requestID)
+
 	// Build request context
 	body, _ := io.ReadAll(c.Request.Body)
 	headers := make(map[string]string)
@@ -591,6 +633,8 @@ func (h *RuntimeHandler) HandleRoute(c *gin.Context) {
 		IP:        clientIP,
 		UserAgent: userAgent,
 		Cookies:   cookies,
+		RequestID: requestID,
+		RawBody:   body,
 	}
 
 	// Handle CORS preflight if configured
@@ -640,12 +684,12 @@ func (h *RuntimeHandler) HandleRoute(c *gin.Context) {
 		c.Redirect(resp.Status, resp.RedirectURL)
 	case modules.ResponseTypeFile:
 		// Serve file from project's storage
-		filePath, err := h.storageService.GetPath(project.ID.Hex(), resp.FilePath)
+		object, err := h.storageService.Open(project.ID.Hex(), resp.FilePath)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 			return
 		}
-		c.File(filePath)
+		serveStorageObject(c, object, "")
 	case modules.ResponseTypeRaw:
 		// Raw text/string response
 		if contentType := resp.Headers["Content-Type"]; contentType != "" {
@@ -692,7 +736,7 @@ type DashboardState struct {
 
 // State returns the initial dashboard state (status + monitor data)
 func (h *RuntimeHandler) State(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionView)
 	if !ok {
 		return
 	}
@@ -1,28 +1,35 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/imaging"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/service"
 )
 
 type StorageHandler struct {
 	storageService *service.StorageService
 	projectService *service.ProjectService
+	auditService   *service.AuditService
 }
 
-func NewStorageHandler(storageService *service.StorageService, projectService *service.ProjectService) *StorageHandler {
+func NewStorageHandler(storageService *service.StorageService, projectService *service.ProjectService, auditService *service.AuditService) *StorageHandler {
 	return &StorageHandler{
 		storageService: storageService,
 		projectService: projectService,
+		auditService:   auditService,
 	}
 }
 
@@ -39,6 +46,12 @@ func (h *StorageHandler) Register(r *gin.RouterGroup, authMiddleware *middleware
 		storage.POST("/file", h.CreateFile)
 		storage.PUT("/file/*path", h.UpdateFile)
 		storage.GET("/thumbnail/*path", h.Thumbnail)
+		storage.PUT("/visibility", h.SetVisibility)
+		storage.POST("/sign", h.Sign)
+		storage.PUT("/versioning", h.SetVersioning)
+		storage.GET("/versions/*path", h.Versions)
+		storage.POST("/versions/restore", h.RestoreVersion)
+		storage.POST("/versions/purge", h.PurgeVersions)
 	}
 }
 
@@ -48,24 +61,21 @@ func (h *StorageHandler) RegisterPublicRoutes(r *gin.Engine) {
 	r.GET("/cdn/:id/*path", h.CDN)
 }
 
-func (h *StorageHandler) checkAccess(c *gin.Context) (string, bool) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return "", false
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+func (h *StorageHandler) checkAccess(c *gin.Context, permission domain.ProjectPermission) (string, bool) {
+	projectID, ok := requireProjectPermission(c, h.projectService, permission)
+	if !ok {
 		return "", false
 	}
-
 	return projectID.Hex(), true
 }
 
+func (h *StorageHandler) audit(c *gin.Context, projectID, action, target string, before, after any) {
+	id, _ := primitive.ObjectIDFromHex(projectID)
+	recordAudit(c, h.auditService, id, action, target, before, after)
+}
+
 func (h *StorageHandler) List(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageRead)
 	if !ok {
 		return
 	}
@@ -81,11 +91,205 @@ func (h *StorageHandler) List(c *gin.Context) {
 		return
 	}
 
+	// Private files get short-lived signed URLs so the admin UI can preview them
+	id, _ := primitive.ObjectIDFromHex(projectID)
+	if project, err := h.projectService.GetByID(c.Request.Context(), id); err == nil && len(project.PrivatePaths) > 0 {
+		expiresAt := time.Now().Add(service.DefaultSignedURLTTL)
+		for i := range files {
+			if project.IsStoragePathPrivate(files[i].Path) {
+				h.storageService.SignFileInfo(projectID, &files[i], expiresAt)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, files)
 }
 
+// SetVisibility marks a storage path as private (signed URLs only) or public
+func (h *StorageHandler) SetVisibility(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
+	if !ok {
+		return
+	}
+
+	var req domain.SetStorageVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := primitive.ObjectIDFromHex(projectID)
+	before, err := h.projectService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	project, err := h.projectService.SetStorageVisibility(c.Request.Context(), id, req.Path, req.Private)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, projectID, domain.AuditStorageVisibility, req.Path,
+		gin.H{"private_paths": before.PrivatePaths}, gin.H{"private_paths": project.PrivatePaths})
+
+	c.JSON(http.StatusOK, gin.H{"private_paths": project.PrivatePaths})
+}
+
+// Sign mints a signed, expiring CDN URL for a file
+func (h *StorageHandler) Sign(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageRead)
+	if !ok {
+		return
+	}
+
+	var req domain.SignStorageURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be positive"})
+		return
+	}
+
+	if _, err := h.storageService.Stat(projectID, req.Path); err != nil {
+		if err == service.ErrFileNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := service.DefaultSignedURLTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	h.audit(c, projectID, domain.AuditStorageSign, req.Path, nil, gin.H{
+		"expires_at": expiresAt.UTC(),
+		"download":   req.Download,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        h.storageService.SignURL(projectID, req.Path, expiresAt, req.Download),
+		"expires_at": expiresAt.UTC(),
+	})
+}
+
+// SetVersioning turns file versioning on or off for a storage directory
+func (h *StorageHandler) SetVersioning(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
+	if !ok {
+		return
+	}
+
+	var req domain.SetStorageVersioningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := primitive.ObjectIDFromHex(projectID)
+	before, err := h.projectService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	project, err := h.projectService.SetStorageVersioning(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, projectID, domain.AuditStorageVersioning, req.Path,
+		gin.H{"versioned_paths": before.VersionedPaths}, gin.H{"versioned_paths": project.VersionedPaths})
+
+	c.JSON(http.StatusOK, gin.H{"versioned_paths": project.VersionedPaths})
+}
+
+// Versions lists the kept versions of a file, or downloads one with ?version=<id>
+func (h *StorageHandler) Versions(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageRead)
+	if !ok {
+		return
+	}
+
+	path := strings.TrimPrefix(c.Param("path"), "/")
+
+	if versionID := c.Query("version"); versionID != "" {
+		object, err := h.storageService.OpenVersion(projectID, path, versionID)
+		if err != nil {
+			c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		serveStorageObject(c, object, object.Name)
+		return
+	}
+
+	versions, err := h.storageService.Versions(projectID, path)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// RestoreVersion replaces a file with one of its kept versions
+func (h *StorageHandler) RestoreVersion(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
+	if !ok {
+		return
+	}
+
+	var req domain.RestoreStorageVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if err := h.storageService.RestoreVersion(projectID, req.Path, req.VersionID, user.Email); err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, projectID, domain.AuditStorageRestore, req.Path, nil, gin.H{"version_id": req.VersionID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "version restored successfully"})
+}
+
+// PurgeVersions drops the versions outside the retention policy, or all of them
+func (h *StorageHandler) PurgeVersions(c *gin.Context) {
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
+	if !ok {
+		return
+	}
+
+	var req domain.PurgeStorageVersionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.storageService.PurgeVersions(projectID, req.Path, req.All)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, projectID, domain.AuditStoragePurge, req.Path, nil, gin.H{"all": req.All, "result": result})
+
+	c.JSON(http.StatusOK, result)
+}
+
 func (h *StorageHandler) MkDir(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -103,11 +307,13 @@ func (h *StorageHandler) MkDir(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageMkdir, req.Path, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "directory created successfully"})
 }
 
 func (h *StorageHandler) Upload(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -125,11 +331,13 @@ func (h *StorageHandler) Upload(c *gin.Context) {
 
 	fullPath := filepath.Join(path, file.Filename)
 
-	if err := h.storageService.Upload(projectID, fullPath, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.storageService.Upload(projectID, fullPath, file, middleware.GetCurrentUser(c).Email); err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageUpload, fullPath, nil, gin.H{"size": file.Size})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "file uploaded successfully",
 		"path":    fullPath,
@@ -137,7 +345,7 @@ func (h *StorageHandler) Upload(c *gin.Context) {
 }
 
 func (h *StorageHandler) Download(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageRead)
 	if !ok {
 		return
 	}
@@ -145,7 +353,7 @@ func (h *StorageHandler) Download(c *gin.Context) {
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 
-	filePath, err := h.storageService.Download(projectID, path)
+	object, err := h.storageService.Open(projectID, path)
 	if err != nil {
 		if err == service.ErrFileNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
@@ -155,11 +363,11 @@ func (h *StorageHandler) Download(c *gin.Context) {
 		return
 	}
 
-	c.File(filePath)
+	serveStorageObject(c, object, "")
 }
 
 func (h *StorageHandler) Rename(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -173,16 +381,18 @@ func (h *StorageHandler) Rename(c *gin.Context) {
 		return
 	}
 
-	if err := h.storageService.Rename(projectID, req.OldPath, req.NewPath); err != nil {
+	if err := h.storageService.RenameAs(projectID, req.OldPath, req.NewPath, middleware.GetCurrentUser(c).Email); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageRename, req.OldPath, gin.H{"path": req.OldPath}, gin.H{"path": req.NewPath})
+
 	c.JSON(http.StatusOK, gin.H{"message": "renamed successfully"})
 }
 
 func (h *StorageHandler) Delete(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -190,16 +400,18 @@ func (h *StorageHandler) Delete(c *gin.Context) {
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 
-	if err := h.storageService.Delete(projectID, path); err != nil {
+	if err := h.storageService.DeleteAs(projectID, path, middleware.GetCurrentUser(c).Email); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageDelete, path, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "deleted successfully"})
 }
 
 func (h *StorageHandler) CreateFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -213,11 +425,13 @@ func (h *StorageHandler) CreateFile(c *gin.Context) {
 		return
 	}
 
-	if err := h.storageService.Write(projectID, req.Path, []byte(req.Content)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.storageService.WriteAs(projectID, req.Path, []byte(req.Content), middleware.GetCurrentUser(c).Email); err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageCreate, req.Path, nil, gin.H{"size": len(req.Content)})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "file created successfully",
 		"path":    req.Path,
@@ -225,7 +439,7 @@ func (h *StorageHandler) CreateFile(c *gin.Context) {
 }
 
 func (h *StorageHandler) UpdateFile(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageWrite)
 	if !ok {
 		return
 	}
@@ -239,16 +453,18 @@ func (h *StorageHandler) UpdateFile(c *gin.Context) {
 		return
 	}
 
-	if err := h.storageService.Write(projectID, path, body); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.storageService.WriteAs(projectID, path, body, middleware.GetCurrentUser(c).Email); err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, projectID, domain.AuditStorageUpdate, path, nil, gin.H{"size": len(body)})
+
 	c.JSON(http.StatusOK, gin.H{"message": "file updated successfully"})
 }
 
 func (h *StorageHandler) Thumbnail(c *gin.Context) {
-	projectID, ok := h.checkAccess(c)
+	projectID, ok := h.checkAccess(c, domain.PermissionStorageRead)
 	if !ok {
 		return
 	}
@@ -256,13 +472,47 @@ func (h *StorageHandler) Thumbnail(c *gin.Context) {
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 
-	data, err := h.storageService.GenerateThumbnail(projectID, path, 50, 50)
+	data, mimeType, err := h.storageService.GenerateThumbnail(projectID, path, 50, 50)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Data(http.StatusOK, "image/jpeg", data)
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// authorizeCDN allows public files, and private files only with a valid
+// signature for the endpoint, inline or download
+func (h *StorageHandler) authorizeCDN(c *gin.Context, projectID, path string, download bool) bool {
+	if c.Query("sig") != "" {
+		if err := h.storageService.VerifySignature(projectID, path, download, c.Query("expires"), c.Query("sig")); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return false
+		}
+		c.Header("Cache-Control", "private, no-store")
+		return true
+	}
+
+	id, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return false
+	}
+	// Without the project its private paths are unknown, so nothing is served
+	project, err := h.projectService.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load project"})
+		return false
+	}
+	if project.IsStoragePathPrivate(path) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "signed URL required"})
+		return false
+	}
+	return true
 }
 
 func (h *StorageHandler) CDN(c *gin.Context) {
@@ -270,7 +520,11 @@ func (h *StorageHandler) CDN(c *gin.Context) {
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 
-	filePath, err := h.storageService.Download(projectID, path)
+	if !h.authorizeCDN(c, projectID, path, false) {
+		return
+	}
+
+	object, err := h.storageService.Open(projectID, path)
 	if err != nil {
 		if err == service.ErrFileNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
@@ -280,7 +534,7 @@ func (h *StorageHandler) CDN(c *gin.Context) {
 		return
 	}
 
-	c.File(filePath)
+	serveStorageObject(c, object, "")
 }
 
 func (h *StorageHandler) PublicDownload(c *gin.Context) {
@@ -288,7 +542,11 @@ func (h *StorageHandler) PublicDownload(c *gin.Context) {
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 
-	filePath, err := h.storageService.Download(projectID, path)
+	if !h.authorizeCDN(c, projectID, path, true) {
+		return
+	}
+
+	object, err := h.storageService.Open(projectID, path)
 	if err != nil {
 		if err == service.ErrFileNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
@@ -298,9 +556,12 @@ func (h *StorageHandler) PublicDownload(c *gin.Context) {
 		return
 	}
 
-	c.FileAttachment(filePath, filepath.Base(path))
+	serveStorageObject(c, object, filepath.Base(path))
 }
 
+// CDNResize serves a transformed image. The size segment holds the transform:
+// "WxH" followed by optional fit=cover|contain|fill, fmt=webp|png|jpeg, q=1-100,
+// blur=<sigma>, gray and rot=90|180|270, e.g. /cdn/resize/800x600,fit=cover,fmt=webp/...
 func (h *StorageHandler) CDNResize(c *gin.Context) {
 	sizeParam := c.Param("size")
 	projectID := c.Param("id")
@@ -313,29 +574,70 @@ func (h *StorageHandler) CDNResize(c *gin.Context) {
 		return
 	}
 
-	// Parse size (e.g., "50x50", "100x100")
-	var width, height int
-	if _, err := fmt.Sscanf(sizeParam, "%dx%d", &width, &height); err != nil {
-		// Not a valid size format, treat as regular CDN request
-		// This shouldn't happen with proper routing, but handle gracefully
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size format, use WxH (e.g., 50x50)"})
+	// Parse the transform (e.g., "50x50", "800x600,fit=cover,fmt=webp,q=80")
+	opts, err := imaging.ParseOptions(sizeParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if width <= 0 || height <= 0 || width > 2000 || height > 2000 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dimensions"})
+	if !h.authorizeCDN(c, projectID, path, false) {
 		return
 	}
 
-	filePath, err := h.storageService.GetResizedImage(projectID, path, width, height)
+	object, err := h.storageService.OpenTransformedImage(projectID, path, opts)
 	if err != nil {
 		if err == service.ErrFileNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 			return
 		}
+		if errors.Is(err, imaging.ErrTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.File(filePath)
+	// The cached file keeps the original name, so its type may differ from the extension
+	c.Header("Content-Type", object.MimeType)
+	serveStorageObject(c, object, "")
+}
+
+// writeErrorStatus returns the HTTP status for a failed write: 507 when a
+// project quota is exhausted, 500 otherwise
+func writeErrorStatus(err error) int {
+	if errors.Is(err, domain.ErrQuotaExceeded) {
+		return http.StatusInsufficientStorage
+	}
+	return http.StatusInternalServerError
+}
+
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrVersionNotFound), errors.Is(err, service.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidPath):
+		return http.StatusBadRequest
+	}
+	return writeErrorStatus(err)
+}
+
+// serveStorageObject writes a stored file to the response and closes it.
+// Seekable readers go through http.ServeContent for range and
+// conditional request support. A non-empty attachment name makes it a download.
+func serveStorageObject(c *gin.Context, object *service.StorageObject, attachment string) {
+	defer object.Close()
+
+	if attachment != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment}))
+	}
+
+	if seeker, ok := object.ReadCloser.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, object.Name, object.ModTime, seeker)
+		return
+	}
+
+	c.Header("Last-Modified", object.ModTime.UTC().Format(http.TimeFormat))
+	c.DataFromReader(http.StatusOK, object.Size, object.MimeType, object, nil)
 }
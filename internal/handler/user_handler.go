@@ -1,23 +1,41 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
 	"github.com/levskiy0/m3m/internal/service"
 )
 
 type UserHandler struct {
-	userService *service.UserService
+	userService        *service.UserService
+	accessTokenService *service.AccessTokenService
+	twoFactorService   *service.TwoFactorService
+	loginProtection    *service.LoginProtectionService
+	auditService       *service.AuditService
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(
+	userService *service.UserService,
+	accessTokenService *service.AccessTokenService,
+	twoFactorService *service.TwoFactorService,
+	loginProtection *service.LoginProtectionService,
+	auditService *service.AuditService,
+) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:        userService,
+		accessTokenService: accessTokenService,
+		twoFactorService:   twoFactorService,
+		loginProtection:    loginProtection,
+		auditService:       auditService,
 	}
 }
 
@@ -29,6 +47,14 @@ func (h *UserHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.Au
 		users.PUT("/me", h.UpdateMe)
 		users.PUT("/me/password", h.ChangePassword)
 		users.PUT("/me/avatar", h.UpdateAvatar)
+		users.GET("/me/tokens", h.ListTokens)
+		users.POST("/me/tokens", h.CreateToken)
+		users.DELETE("/me/tokens/:tokenId", h.RevokeToken)
+		users.GET("/me/2fa", h.TwoFactorStatus)
+		users.POST("/me/2fa/enroll", h.EnrollTwoFactor)
+		users.POST("/me/2fa/verify", h.ConfirmTwoFactor)
+		users.POST("/me/2fa/disable", h.DisableTwoFactor)
+		users.POST("/me/2fa/recovery-codes", h.RegenerateRecoveryCodes)
 
 		// Admin only routes
 		admin := users.Group("")
@@ -36,15 +62,38 @@ func (h *UserHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.Au
 		{
 			admin.GET("", h.List)
 			admin.POST("", h.Create)
+			admin.GET("/login-events", h.LoginEvents)
+			admin.POST("/login-unlock", h.UnlockIP)
 			admin.GET("/:id", h.Get)
 			admin.PUT("/:id", h.Update)
 			admin.DELETE("/:id", h.Delete)
 			admin.POST("/:id/block", h.Block)
 			admin.POST("/:id/unblock", h.Unblock)
+			admin.POST("/:id/unlock", h.Unlock)
+			admin.POST("/:id/2fa/reset", h.ResetTwoFactor)
+			admin.POST("/:id/sso-link", h.AllowSSOLink)
 		}
 	}
 }
 
+// audit records an action on users, they are outside projects
+func (h *UserHandler) audit(c *gin.Context, action, target string, before, after *domain.User) {
+	recordAudit(c, h.auditService, primitive.NilObjectID, action, target, h.auditState(before), h.auditState(after))
+}
+
+// auditState is the audited state of a user. Avatars may be large data URLs,
+// they are recorded as fingerprints.
+func (h *UserHandler) auditState(user *domain.User) any {
+	if user == nil {
+		return nil
+	}
+	state := *user
+	if state.Avatar != "" {
+		state.Avatar = h.auditService.Redact(state.Avatar)
+	}
+	return &state
+}
+
 func (h *UserHandler) GetMe(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
 	c.JSON(http.StatusOK, user)
@@ -57,13 +106,15 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetCurrentUserID(c)
-	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, &req)
+	before := middleware.GetCurrentUser(c)
+	user, err := h.userService.UpdateProfile(c.Request.Context(), before.ID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	h.audit(c, domain.AuditProfileUpdate, user.Email, before, user)
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -84,6 +135,8 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, domain.AuditPasswordChange, middleware.GetCurrentUser(c).Email, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
 }
 
@@ -96,8 +149,8 @@ func (h *UserHandler) UpdateAvatar(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetCurrentUserID(c)
-	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, &domain.UpdateProfileRequest{
+	before := middleware.GetCurrentUser(c)
+	user, err := h.userService.UpdateProfile(c.Request.Context(), before.ID, &domain.UpdateProfileRequest{
 		Avatar: &req.Avatar,
 	})
 	if err != nil {
@@ -105,6 +158,8 @@ func (h *UserHandler) UpdateAvatar(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, domain.AuditProfileUpdate, user.Email, before, user)
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -131,6 +186,8 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, domain.AuditUserCreate, user.Email, nil, user)
+
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -163,6 +220,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.userService.GetByID(c.Request.Context(), id)
 	currentUser := middleware.GetCurrentUser(c)
 	user, err := h.userService.Update(c.Request.Context(), id, &req, currentUser)
 	if err != nil {
@@ -174,6 +232,8 @@ func (h *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, domain.AuditUserUpdate, user.Email, before, user)
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -184,6 +244,12 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	before, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
 	if err := h.userService.Delete(c.Request.Context(), id); err != nil {
 		if err == service.ErrCannotDeleteRoot {
 			c.JSON(http.StatusForbidden, gin.H{"error": "cannot delete root user"})
@@ -193,6 +259,8 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, domain.AuditUserDelete, before.Email, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
 
@@ -203,6 +271,12 @@ func (h *UserHandler) Block(c *gin.Context) {
 		return
 	}
 
+	before, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
 	if err := h.userService.Block(c.Request.Context(), id); err != nil {
 		if err == service.ErrCannotBlockRoot {
 			c.JSON(http.StatusForbidden, gin.H{"error": "cannot block root user"})
@@ -212,6 +286,9 @@ func (h *UserHandler) Block(c *gin.Context) {
 		return
 	}
 
+	after, _ := h.userService.GetByID(c.Request.Context(), id)
+	h.audit(c, domain.AuditUserBlock, before.Email, before, after)
+
 	c.JSON(http.StatusOK, gin.H{"message": "user blocked successfully"})
 }
 
@@ -222,10 +299,314 @@ func (h *UserHandler) Unblock(c *gin.Context) {
 		return
 	}
 
+	before, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
 	if err := h.userService.Unblock(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// A user blocked after repeated lockouts starts over
+	after, ok := h.unlockLogin(c, id)
+	if !ok {
+		return
+	}
+
+	h.audit(c, domain.AuditUserUnblock, after.Email, before, after)
+
 	c.JSON(http.StatusOK, gin.H{"message": "user unblocked successfully"})
 }
+
+// Unlock lifts the lockout of a user locked out after failed logins
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	user, ok := h.unlockLogin(c, id)
+	if !ok {
+		return
+	}
+
+	h.audit(c, domain.AuditUserUnlock, user.Email, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked successfully"})
+}
+
+func (h *UserHandler) unlockLogin(c *gin.Context, id primitive.ObjectID) (*domain.User, bool) {
+	user, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	if err := h.loginProtection.Unlock(c.Request.Context(), user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return user, true
+}
+
+// UnlockIP lifts the lockout of a client IP locked out after failed logins
+func (h *UserHandler) UnlockIP(c *gin.Context) {
+	var req struct {
+		IP string `json:"ip" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.loginProtection.UnlockIP(c.Request.Context(), req.IP); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, domain.AuditUserUnlockIP, req.IP, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "IP unlocked successfully"})
+}
+
+// LoginEvents lists failed logins and lockouts, newest first. Filters:
+// email, ip, user_id, since (RFC 3339) and limit.
+func (h *UserHandler) LoginEvents(c *gin.Context) {
+	filter := domain.LoginEventFilter{
+		Email: c.Query("email"),
+		IP:    c.Query("ip"),
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		filter.UserID = &id
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since, expected RFC 3339"})
+			return
+		}
+		filter.Since = &t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	events, err := h.loginProtection.Events(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ListTokens lists the personal access tokens of the current user
+func (h *UserHandler) ListTokens(c *gin.Context) {
+	tokens, err := h.accessTokenService.List(c.Request.Context(), middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken issues a personal access token; its secret is only returned here
+func (h *UserHandler) CreateToken(c *gin.Context) {
+	var req domain.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.accessTokenService.Create(c.Request.Context(), middleware.GetCurrentUser(c), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAccessTokenName) || errors.Is(err, service.ErrInvalidTokenScope) || errors.Is(err, service.ErrInvalidTokenExpiry) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, h.auditService, primitive.NilObjectID, domain.AuditTokenCreate, resp.AccessToken.Name, nil, resp.AccessToken)
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *UserHandler) RevokeToken(c *gin.Context) {
+	tokenID, err := primitive.ObjectIDFromHex(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token id"})
+		return
+	}
+
+	if err := h.accessTokenService.Revoke(c.Request.Context(), middleware.GetCurrentUserID(c), tokenID); err != nil {
+		if errors.Is(err, repository.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "access token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, h.auditService, primitive.NilObjectID, domain.AuditTokenRevoke, tokenID.Hex(), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "access token revoked"})
+}
+
+func (h *UserHandler) TwoFactorStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.twoFactorService.Status(middleware.GetCurrentUser(c)))
+}
+
+// EnrollTwoFactor starts setting up 2FA; the returned URI is shown as a QR code
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	enrollment, err := h.twoFactorService.Enroll(c.Request.Context(), middleware.GetCurrentUser(c))
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor enables 2FA with a code from the enrolled secret and
+// returns the recovery codes, only shown here
+func (h *UserHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req domain.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	codes, err := h.twoFactorService.Confirm(c.Request.Context(), user, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	h.audit(c, domain.Audit2FAEnable, user.Email, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var req domain.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if err := h.twoFactorService.Disable(c.Request.Context(), user, req.Code); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	h.audit(c, domain.Audit2FADisable, user.Email, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req domain.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(c.Request.Context(), user, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	h.audit(c, domain.AuditRecoveryCodes, user.Email, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetTwoFactor removes the 2FA of a user who lost their device
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	user, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.IsRoot && !middleware.GetCurrentUser(c).IsRoot {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot modify root user"})
+		return
+	}
+
+	if err := h.twoFactorService.Reset(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, domain.AuditUser2FAReset, user.Email, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset"})
+}
+
+// AllowSSOLink lets the next SSO login with the user's email link the account
+func (h *UserHandler) AllowSSOLink(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	before, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	user, err := h.userService.AllowSSOLink(c.Request.Context(), id, middleware.GetCurrentUser(c))
+	if err != nil {
+		if err == service.ErrCannotModifyRoot {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot modify root user"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, domain.AuditUserSSOLink, user.Email, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorEnabled), errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrTwoFactorNotEnrolling):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/repository"
+	"github.com/levskiy0/m3m/internal/runtime"
+	"github.com/levskiy0/m3m/internal/runtime/modules"
+	"github.com/levskiy0/m3m/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+	projectService *service.ProjectService
+	runtimeManager *runtime.Manager
+	auditService   *service.AuditService
+}
+
+func NewWebhookHandler(
+	webhookService *service.WebhookService,
+	projectService *service.ProjectService,
+	runtimeManager *runtime.Manager,
+	auditService *service.AuditService,
+) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		projectService: projectService,
+		runtimeManager: runtimeManager,
+		auditService:   auditService,
+	}
+}
+
+func (h *WebhookHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.AuthMiddleware) {
+	webhooks := r.Group("/projects/:id/webhooks")
+	webhooks.Use(authMiddleware.Authenticate())
+	{
+		webhooks.GET("/deliveries", h.List)
+		webhooks.GET("/deliveries/:deliveryId", h.Get)
+		webhooks.POST("/deliveries/:deliveryId/replay", h.Replay)
+	}
+}
+
+// List returns the newest deliveries of the project's webhooks, filtered by
+// the webhook, status and limit query parameters
+func (h *WebhookHandler) List(c *gin.Context) {
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	filter := domain.WebhookDeliveryFilter{
+		ProjectID: projectID,
+		Webhook:   c.Query("webhook"),
+		Status:    c.Query("status"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	deliveries, err := h.webhookService.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Get returns a delivery with its payload
+func (h *WebhookHandler) Get(c *gin.Context) {
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	delivery, ok := h.delivery(c, projectID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Replay runs a delivery through the webhook handler of the running project
+// again and returns the delivery recorded for the replay
+func (h *WebhookHandler) Replay(c *gin.Context) {
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionRuntime)
+	if !ok {
+		return
+	}
+
+	delivery, ok := h.delivery(c, projectID)
+	if !ok {
+		return
+	}
+
+	if !h.runtimeManager.IsRunning(projectID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "project not running"})
+		return
+	}
+
+	replay, err := h.runtimeManager.ReplayWebhook(projectID, delivery)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, modules.ErrWebhookNotRegistered) || errors.Is(err, modules.ErrWebhookTruncated) ||
+			errors.Is(err, modules.ErrWebhookRejected) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(c, h.auditService, projectID, domain.AuditWebhookReplay, delivery.Webhook, nil, gin.H{
+		"delivery":    delivery.ID.Hex(),
+		"delivery_id": delivery.DeliveryID,
+		"status":      replay.Status,
+	})
+
+	c.JSON(http.StatusOK, replay)
+}
+
+func (h *WebhookHandler) delivery(c *gin.Context, projectID primitive.ObjectID) (*domain.WebhookDelivery, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return nil, false
+	}
+
+	delivery, err := h.webhookService.Get(c.Request.Context(), projectID, id)
+	if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return delivery, true
+}
@@ -5,11 +5,10 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
 
-	"github.com/levskiy0/m3m/internal/config"
 	"github.com/levskiy0/m3m/internal/middleware"
+	"github.com/levskiy0/m3m/internal/service"
 	ws "github.com/levskiy0/m3m/internal/websocket"
 )
 
@@ -22,14 +21,14 @@ var upgrader = websocket.Upgrader{
 }
 
 type WebSocketHandler struct {
-	hub    *ws.Hub
-	config *config.Config
+	hub         *ws.Hub
+	authService *service.AuthService
 }
 
-func NewWebSocketHandler(hub *ws.Hub, cfg *config.Config) *WebSocketHandler {
+func NewWebSocketHandler(hub *ws.Hub, authService *service.AuthService) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:    hub,
-		config: cfg,
+		hub:         hub,
+		authService: authService,
 	}
 }
 
@@ -54,10 +53,10 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// Validate token
-	userID, err := h.validateToken(token)
+	// Validate token; the connection is closed when its session is revoked
+	user, session, err := h.authService.Authenticate(c.Request.Context(), token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -66,7 +65,7 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	client := ws.NewClient(h.hub, conn, userID)
+	client := ws.NewClient(h.hub, conn, user.ID.Hex(), session.ID.Hex())
 
 	// Register client
 	h.hub.Register(client)
@@ -79,32 +78,6 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	go client.ReadPump()
 }
 
-func (h *WebSocketHandler) validateToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing algorithm to prevent algorithm substitution attacks
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(h.config.JWT.Secret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return "", err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", jwt.ErrTokenInvalidClaims
-	}
-
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return "", jwt.ErrTokenInvalidClaims
-	}
-
-	return userID, nil
-}
-
 // GetHub returns the WebSocket hub
 func (h *WebSocketHandler) GetHub() *ws.Hub {
 	return h.hub
@@ -36,15 +36,8 @@ func (h *WidgetHandler) Register(r *gin.RouterGroup, authMiddleware *middleware.
 }
 
 func (h *WidgetHandler) List(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionView)
+	if !ok {
 		return
 	}
 
@@ -58,15 +51,8 @@ func (h *WidgetHandler) List(c *gin.Context) {
 }
 
 func (h *WidgetHandler) Create(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
@@ -86,9 +72,8 @@ func (h *WidgetHandler) Create(c *gin.Context) {
 }
 
 func (h *WidgetHandler) Update(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
@@ -98,12 +83,6 @@ func (h *WidgetHandler) Update(c *gin.Context) {
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
-	}
-
 	var req domain.UpdateWidgetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -124,9 +103,8 @@ func (h *WidgetHandler) Update(c *gin.Context) {
 }
 
 func (h *WidgetHandler) Delete(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
@@ -136,12 +114,6 @@ func (h *WidgetHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
-	}
-
 	if err := h.widgetService.Delete(c.Request.Context(), projectID, widgetID); err != nil {
 		if err == service.ErrWidgetNotInProject {
 			c.JSON(http.StatusForbidden, gin.H{"error": "widget does not belong to this project"})
@@ -155,15 +127,8 @@ func (h *WidgetHandler) Delete(c *gin.Context) {
 }
 
 func (h *WidgetHandler) Reorder(c *gin.Context) {
-	projectID, err := primitive.ObjectIDFromHex(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
-		return
-	}
-
-	user := middleware.GetCurrentUser(c)
-	if !h.projectService.CanUserAccess(c.Request.Context(), user.ID, projectID, user.IsRoot) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	projectID, ok := requireProjectPermission(c, h.projectService, domain.PermissionDashboard)
+	if !ok {
 		return
 	}
 
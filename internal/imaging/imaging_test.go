@@ -0,0 +1,173 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		spec      string
+		canonical string
+	}{
+		{"64x64", "64x64"},
+		{"800x,fmt=webp", "800x,fmt=webp"},
+		{"x600,fit=cover", "x600,fit=cover"},
+		{"300x200,fit=cover,fmt=jpg,q=70,blur=1.5,gray,rot=90", "300x200,fit=cover,fmt=jpeg,q=70,blur=1.5,gray,rot=90"},
+		{"100x100,fit=contain,q=85", "100x100"},
+		{"fmt=png", "fmt=png"},
+	}
+	for _, tt := range tests {
+		opts, err := ParseOptions(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseOptions(%q): %v", tt.spec, err)
+		}
+		if got := opts.String(); got != tt.canonical {
+			t.Errorf("ParseOptions(%q).String() = %q, want %q", tt.spec, got, tt.canonical)
+		}
+	}
+
+	for _, spec := range []string{"", "0x10", "3000x10", "10x10,fit=stretch", "10x10,fmt=bmp", "10x10,q=101", "10x10,blur=99", "10x10,rot=45", "10x10,sepia", "10x10,fmt=webp,q=80", "fmt=png,q=50"} {
+		if _, err := ParseOptions(spec); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("ParseOptions(%q) error = %v, want ErrInvalidOptions", spec, err)
+		}
+	}
+}
+
+func TestResizeFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	tests := []struct {
+		width, height int
+		fit           Fit
+		want          image.Point
+	}{
+		{100, 100, FitContain, image.Pt(100, 50)},
+		{100, 100, FitCover, image.Pt(100, 100)},
+		{100, 100, FitFill, image.Pt(100, 100)},
+		{100, 0, FitCover, image.Pt(100, 50)},
+		{0, 100, FitContain, image.Pt(200, 100)},
+	}
+	for _, tt := range tests {
+		got := Resize(img, tt.width, tt.height, tt.fit).Bounds().Size()
+		if got != tt.want {
+			t.Errorf("Resize(%d, %d, %s) = %v, want %v", tt.width, tt.height, tt.fit, got, tt.want)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+
+	rotated := Transform(img, Options{Rotate: 90})
+	if size := rotated.Bounds().Size(); size != image.Pt(20, 40) {
+		t.Fatalf("rotated size = %v, want 20x40", size)
+	}
+	// The top-left pixel ends up top-right after a clockwise turn
+	if r, _, _, _ := rotated.At(19, 0).RGBA(); r>>8 != 255 {
+		t.Errorf("rotated pixel red = %d, want 255", r>>8)
+	}
+
+	gray := Transform(img, Options{Grayscale: true})
+	r, g, b, _ := gray.At(0, 0).RGBA()
+	if r != g || g != b {
+		t.Errorf("grayscale pixel = %d,%d,%d, want equal channels", r, g, b)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 != 255 {
+		t.Error("grayscale modified the source image")
+	}
+
+	blurred := Transform(img, Options{Blur: 2})
+	if r, _, _, _ := blurred.At(0, 0).RGBA(); r>>8 == 255 || r == 0 {
+		t.Errorf("blurred pixel red = %d, want spread", r>>8)
+	}
+
+	// MaxSize bounds sizeless transforms and derived sides, without upscaling
+	opts := Options{Blur: 1, MaxSize: 10}
+	if size := Transform(img, opts).Bounds().Size(); size != image.Pt(10, 5) {
+		t.Errorf("sizeless transform size = %v, want 10x5", size)
+	}
+	opts = Options{Width: 8, MaxSize: 10}
+	if size := Transform(image.NewNRGBA(image.Rect(0, 0, 10, 40)), opts).Bounds().Size(); size != image.Pt(2, 10) {
+		t.Errorf("derived height size = %v, want 2x10", size)
+	}
+	if size := Transform(img, Options{Grayscale: true, MaxSize: 100}).Bounds().Size(); size != image.Pt(40, 20) {
+		t.Errorf("small image size = %v, want 40x20", size)
+	}
+	if opts, _ := ParseOptions("blur=50"); opts.MaxSize != MaxDimension {
+		t.Errorf("parsed MaxSize = %d, want %d", opts.MaxSize, MaxDimension)
+	}
+}
+
+func TestDecodeLimited(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 30, 20)), FormatPNG, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	img, format, err := DecodeLimited(bytes.NewReader(buf.Bytes()), 600)
+	if err != nil || format != FormatPNG || img.Bounds().Size() != image.Pt(30, 20) {
+		t.Fatalf("DecodeLimited = %v, %q, %v", img.Bounds(), format, err)
+	}
+	if _, _, err := DecodeLimited(bytes.NewReader(buf.Bytes()), 599); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge over the budget, got %v", err)
+	}
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	images := map[string]*image.NRGBA{
+		"gradient": image.NewNRGBA(image.Rect(0, 0, 67, 33)),
+		"noise":    image.NewNRGBA(image.Rect(0, 0, 20, 17)),
+		"flat":     image.NewNRGBA(image.Rect(0, 0, 5, 5)),
+		"pixel":    image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+	}
+	for y := 0; y < 33; y++ {
+		for x := 0; x < 67; x++ {
+			images["gradient"].SetNRGBA(x, y, color.NRGBA{R: uint8(x * 3), G: uint8(y * 7), B: uint8(x + y), A: uint8(255 - x)})
+		}
+	}
+	rng.Read(images["noise"].Pix)
+	for i := range images["flat"].Pix {
+		images["flat"].Pix[i] = 0x80
+	}
+	images["pixel"].SetNRGBA(0, 0, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+
+	for name, img := range images {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, img); err != nil {
+			t.Fatalf("%s: EncodeWebP: %v", name, err)
+		}
+
+		decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Fatalf("%s: bounds = %v, want %v", name, decoded.Bounds(), img.Bounds())
+		}
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				want := img.NRGBAAt(x, y)
+				got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+				if want.A == 0 {
+					if got.A != 0 {
+						t.Fatalf("%s: pixel %d,%d = %v, want transparent", name, x, y, got)
+					}
+					continue
+				}
+				if got != want {
+					t.Fatalf("%s: pixel %d,%d = %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
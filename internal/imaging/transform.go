@@ -0,0 +1,494 @@
+// Package imaging implements the image pipeline shared by the CDN, the
+// $image module and storage thumbnails: resizing, cropping, blur, grayscale,
+// rotation and encoding to JPEG, PNG or WebP.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	stddraw "image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // WebP decoder
+)
+
+// Fit controls how an image is resized into the requested box
+type Fit string
+
+const (
+	FitContain Fit = "contain" // Fit inside the box, keeping the aspect ratio
+	FitCover   Fit = "cover"   // Fill the box, keeping the aspect ratio and cropping the overflow
+	FitFill    Fit = "fill"    // Stretch to exactly the box
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+const (
+	// MaxDimension is the largest width or height a CDN transform may request
+	// or produce
+	MaxDimension = 2000
+	// MaxPixels is the largest source image, in pixels, DecodeLimited accepts
+	MaxPixels = 40_000_000
+	// MaxBlur is the largest blur sigma a transform may request
+	MaxBlur = 50
+	// DefaultQuality is the JPEG quality used when none is given
+	DefaultQuality = 85
+)
+
+var (
+	// ErrInvalidOptions is wrapped by every option parsing and validation error
+	ErrInvalidOptions = errors.New("invalid image transform")
+	// ErrTooLarge is returned by DecodeLimited for images over the pixel budget
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// Options describes a transformation. Zero values keep the original:
+// a zero width or height is derived from the aspect ratio.
+type Options struct {
+	Width     int
+	Height    int
+	Fit       Fit     // Defaults to FitContain
+	Format    string  // Output format, defaults to the source format
+	Quality   int     // JPEG quality 1-100, WebP output is lossless and ignores it
+	Blur      float64 // Gaussian blur sigma in output pixels
+	Grayscale bool
+	Rotate    int // Clockwise degrees: 0, 90, 180 or 270
+	// MaxSize scales larger results down to fit a MaxSize box before blur
+	// and encoding, 0 means no limit. It is not part of String: ParseOptions
+	// always sets it to MaxDimension.
+	MaxSize int
+}
+
+// ParseOptions parses the CDN transform syntax: comma separated tokens of an
+// optional size "WxH" ("800x", "x600") followed by fit=cover|contain|fill,
+// fmt=webp|png|jpeg, q=1-100, blur=<sigma>, gray and rot=90|180|270.
+// For example "800x600,fit=cover,fmt=webp" or "64x64". Results never exceed
+// MaxDimension, with or without a size. q is refused for PNG and WebP output,
+// which are lossless.
+func ParseOptions(spec string) (Options, error) {
+	opts := Options{MaxSize: MaxDimension}
+	if strings.TrimSpace(spec) == "" {
+		return opts, fmt.Errorf("%w: empty transform", ErrInvalidOptions)
+	}
+
+	for i, token := range strings.Split(spec, ",") {
+		token = strings.TrimSpace(token)
+		key, value, hasValue := strings.Cut(token, "=")
+
+		switch {
+		case i == 0 && !hasValue && strings.Contains(token, "x"):
+			w, h, _ := strings.Cut(token, "x")
+			var err error
+			if opts.Width, err = parseDimension(w); err != nil {
+				return opts, err
+			}
+			if opts.Height, err = parseDimension(h); err != nil {
+				return opts, err
+			}
+		case !hasValue && (token == "gray" || token == "grayscale"):
+			opts.Grayscale = true
+		case key == "fit":
+			opts.Fit = Fit(value)
+		case key == "fmt" || key == "format":
+			opts.Format = NormalizeFormat(value)
+			if opts.Format == "" {
+				return opts, fmt.Errorf("%w: unsupported format %q", ErrInvalidOptions, value)
+			}
+		case key == "q" || key == "quality":
+			q, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid quality %q", ErrInvalidOptions, value)
+			}
+			opts.Quality = q
+		case key == "blur":
+			sigma, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid blur %q", ErrInvalidOptions, value)
+			}
+			opts.Blur = sigma
+		case key == "rot" || key == "rotate":
+			deg, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid rotation %q", ErrInvalidOptions, value)
+			}
+			opts.Rotate = deg
+		default:
+			return opts, fmt.Errorf("%w: unknown option %q", ErrInvalidOptions, token)
+		}
+	}
+
+	if opts.Width > MaxDimension || opts.Height > MaxDimension {
+		return opts, fmt.Errorf("%w: dimensions must be between 1 and %d", ErrInvalidOptions, MaxDimension)
+	}
+	if opts.Quality > 0 && (opts.Format == FormatPNG || opts.Format == FormatWebP) {
+		return opts, fmt.Errorf("%w: quality applies to JPEG only, %s is lossless", ErrInvalidOptions, opts.Format)
+	}
+	return opts, opts.Validate()
+}
+
+func parseDimension(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w: invalid dimension %q", ErrInvalidOptions, s)
+	}
+	return n, nil
+}
+
+// Validate checks the options against the supported ranges
+func (o Options) Validate() error {
+	if o.Width < 0 || o.Height < 0 || o.MaxSize < 0 {
+		return fmt.Errorf("%w: dimensions must be positive", ErrInvalidOptions)
+	}
+	switch o.Fit {
+	case "", FitContain, FitCover, FitFill:
+	default:
+		return fmt.Errorf("%w: unknown fit %q, use cover, contain or fill", ErrInvalidOptions, o.Fit)
+	}
+	if o.Format != "" && NormalizeFormat(o.Format) == "" {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidOptions, o.Format)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidOptions)
+	}
+	if o.Blur < 0 || o.Blur > MaxBlur || math.IsNaN(o.Blur) {
+		return fmt.Errorf("%w: blur must be between 0 and %d", ErrInvalidOptions, MaxBlur)
+	}
+	switch o.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("%w: rotation must be 90, 180 or 270", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// String returns the canonical form of the options, used in cache keys.
+// Defaults are omitted so that "64x64" stays "64x64".
+func (o Options) String() string {
+	var parts []string
+	if o.Width > 0 || o.Height > 0 {
+		size := "x"
+		if o.Width > 0 {
+			size = strconv.Itoa(o.Width) + size
+		}
+		if o.Height > 0 {
+			size += strconv.Itoa(o.Height)
+		}
+		parts = append(parts, size)
+	}
+	if o.Fit != "" && o.Fit != FitContain {
+		parts = append(parts, "fit="+string(o.Fit))
+	}
+	if o.Format != "" {
+		parts = append(parts, "fmt="+NormalizeFormat(o.Format))
+	}
+	if o.Quality > 0 && o.Quality != DefaultQuality {
+		parts = append(parts, "q="+strconv.Itoa(o.Quality))
+	}
+	if o.Blur > 0 {
+		parts = append(parts, "blur="+strconv.FormatFloat(o.Blur, 'f', -1, 64))
+	}
+	if o.Grayscale {
+		parts = append(parts, "gray")
+	}
+	if o.Rotate != 0 {
+		parts = append(parts, "rot="+strconv.Itoa(o.Rotate))
+	}
+	if len(parts) == 0 {
+		return "original"
+	}
+	return strings.Join(parts, ",")
+}
+
+// OutputFormat returns the format the image is encoded to
+func (o Options) OutputFormat(sourceFormat string) string {
+	if format := NormalizeFormat(o.Format); format != "" {
+		return format
+	}
+	switch NormalizeFormat(sourceFormat) {
+	case FormatPNG:
+		return FormatPNG
+	case FormatWebP:
+		return FormatWebP
+	}
+	return FormatJPEG
+}
+
+// NormalizeFormat maps format names to FormatJPEG, FormatPNG or FormatWebP.
+// GIF is encoded as PNG. Unknown formats return "".
+func NormalizeFormat(format string) string {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "jpeg", "jpg":
+		return FormatJPEG
+	case "png", "gif":
+		return FormatPNG
+	case "webp":
+		return FormatWebP
+	}
+	return ""
+}
+
+// FormatFromPath returns the format matching a file extension, or ""
+func FormatFromPath(filePath string) string {
+	return NormalizeFormat(path.Ext(filePath))
+}
+
+// MimeType returns the MIME type of an output format
+func MimeType(format string) string {
+	switch NormalizeFormat(format) {
+	case FormatPNG:
+		return "image/png"
+	case FormatWebP:
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// Decode reads an image in any registered format (JPEG, PNG, GIF, WebP)
+func Decode(r io.Reader) (image.Image, string, error) {
+	return image.Decode(r)
+}
+
+// DecodeLimited reads an image like Decode, but checks its dimensions from
+// the header first and returns ErrTooLarge instead of decoding more than
+// maxPixels pixels
+func DecodeLimited(r io.Reader, maxPixels int) (image.Image, string, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return nil, "", fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	return image.Decode(io.MultiReader(&header, r))
+}
+
+// Transform applies the options to an image: rotation, resizing, blur and grayscale
+func Transform(img image.Image, opts Options) image.Image {
+	if opts.Rotate != 0 {
+		img = Rotate(img, opts.Rotate)
+	}
+	if opts.Width > 0 || opts.Height > 0 {
+		fit := opts.Fit
+		if fit == "" {
+			fit = FitContain
+		}
+		img = Resize(img, opts.Width, opts.Height, fit)
+	}
+	if bounds := img.Bounds(); opts.MaxSize > 0 && (bounds.Dx() > opts.MaxSize || bounds.Dy() > opts.MaxSize) {
+		img = Resize(img, opts.MaxSize, opts.MaxSize, FitContain)
+	}
+	if opts.Blur > 0 {
+		img = Blur(img, opts.Blur)
+	}
+	if opts.Grayscale {
+		img = Grayscale(img)
+	}
+	return img
+}
+
+// Resize scales an image into a width x height box. When one dimension is 0
+// it is derived from the aspect ratio and fit does not matter.
+func Resize(img image.Image, width, height int, fit Fit) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if srcWidth == 0 || srcHeight == 0 {
+		return img
+	}
+	ratio := float64(srcWidth) / float64(srcHeight)
+
+	switch {
+	case width == 0:
+		width = max(1, int(float64(height)*ratio))
+		fit = FitFill
+	case height == 0:
+		height = max(1, int(float64(width)/ratio))
+		fit = FitFill
+	}
+
+	srcRect := bounds
+	switch fit {
+	case FitContain:
+		// Fit within bounds, the result may be smaller than the box
+		if ratio > float64(width)/float64(height) {
+			height = max(1, int(float64(width)/ratio))
+		} else {
+			width = max(1, int(float64(height)*ratio))
+		}
+	case FitCover:
+		// Crop the source to the box aspect ratio around its center
+		scale := math.Max(float64(width)/float64(srcWidth), float64(height)/float64(srcHeight))
+		cropWidth := min(srcWidth, int(math.Round(float64(width)/scale)))
+		cropHeight := min(srcHeight, int(math.Round(float64(height)/scale)))
+		x := bounds.Min.X + (srcWidth-cropWidth)/2
+		y := bounds.Min.Y + (srcHeight-cropHeight)/2
+		srcRect = image.Rect(x, y, x+cropWidth, y+cropHeight)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, srcRect, draw.Src, nil)
+	return dst
+}
+
+// Crop copies a region of an image
+func Crop(img image.Image, rect image.Rectangle) (image.Image, error) {
+	bounds := img.Bounds()
+	rect = rect.Add(bounds.Min)
+	if rect.Empty() || !rect.In(bounds) {
+		return nil, fmt.Errorf("crop bounds out of range")
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Copy(dst, image.Point{}, img, rect, draw.Src, nil)
+	return dst, nil
+}
+
+// Rotate turns an image clockwise by 90, 180 or 270 degrees
+func Rotate(img image.Image, degrees int) image.Image {
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	var dst *image.NRGBA
+	switch degrees {
+	case 90, 270:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+	case 180:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+	default:
+		return img
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// Grayscale converts an image to shades of gray, keeping transparency
+func Grayscale(img image.Image) image.Image {
+	dst := toNRGBA(img)
+	if dst == img {
+		dst = cloneNRGBA(dst)
+	}
+	for i := 0; i < len(dst.Pix); i += 4 {
+		r, g, b := float64(dst.Pix[i]), float64(dst.Pix[i+1]), float64(dst.Pix[i+2])
+		gray := uint8(math.Round(0.299*r + 0.587*g + 0.114*b))
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = gray, gray, gray
+	}
+	return dst
+}
+
+// Blur applies a gaussian blur with the given sigma
+func Blur(img image.Image, sigma float64) image.Image {
+	if sigma <= 0 {
+		return img
+	}
+
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	// Blur premultiplied colors so transparent pixels do not bleed their color
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	stddraw.Draw(src, src.Rect, img, img.Bounds().Min, stddraw.Src)
+	tmp := image.NewRGBA(src.Rect)
+	dst := image.NewRGBA(src.Rect)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	convolve := func(from, to *image.RGBA, horizontal bool) {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var acc [4]float64
+				for k, weight := range kernel {
+					sx, sy := x, y
+					if horizontal {
+						sx = min(max(x+k-radius, 0), w-1)
+					} else {
+						sy = min(max(y+k-radius, 0), h-1)
+					}
+					offset := from.PixOffset(sx, sy)
+					for c := 0; c < 4; c++ {
+						acc[c] += float64(from.Pix[offset+c]) * weight
+					}
+				}
+				offset := to.PixOffset(x, y)
+				for c := 0; c < 4; c++ {
+					to.Pix[offset+c] = uint8(min(math.Round(acc[c]), 255))
+				}
+			}
+		}
+	}
+	convolve(src, tmp, true)
+	convolve(tmp, dst, false)
+	return dst
+}
+
+// Encode writes an image in the given format. quality applies to JPEG only,
+// 0 means DefaultQuality.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	if quality <= 0 || quality > 100 {
+		quality = DefaultQuality
+	}
+
+	switch NormalizeFormat(format) {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatWebP:
+		return EncodeWebP(w, img)
+	default:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	}
+}
+
+// toNRGBA returns the image as *image.NRGBA with bounds starting at 0,0,
+// converting it when needed
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	stddraw.Draw(dst, dst.Rect, img, bounds.Min, stddraw.Src)
+	return dst
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	copy(dst.Pix, img.Pix)
+	return dst
+}
@@ -0,0 +1,434 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+)
+
+// EncodeWebP writes an image as lossless WebP (VP8L). It uses the subtract
+// green and predictor transforms with prefix coding of literals, which keeps
+// it small and dependency free at the cost of some compression.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	src := toNRGBA(img)
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return fmt.Errorf("webp: invalid image size %dx%d", width, height)
+	}
+
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := src.Pix[src.PixOffset(x, y):]
+			if p[3] != 0xff {
+				hasAlpha = true
+			}
+			argb[y*width+x] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8) // VP8L signature
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // Version
+
+	// Subtract green transform
+	bw.write(1, 1)
+	bw.write(vp8lSubtractGreen, 2)
+	for i, p := range argb {
+		green := (p >> 8) & 0xff
+		r := ((p >> 16) - green) & 0xff
+		b := (p - green) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+
+	// Predictor transform
+	bw.write(1, 1)
+	bw.write(vp8lPredictor, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	modes, residuals := predict(argb, width, height)
+	writeEntropyImage(bw, modes, false)
+
+	bw.write(0, 1) // No more transforms
+	writeEntropyImage(bw, residuals, true)
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padded := chunkSize + chunkSize&1
+
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+padded))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(chunkSize))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padded != chunkSize {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+const (
+	vp8lPredictor      = 0
+	vp8lSubtractGreen  = 2
+	vp8lPredictorBits  = 4 // 16x16 blocks
+	vp8lNumLiteral     = 256
+	vp8lNumLengthCodes = 24
+	vp8lNumDistance    = 40
+	vp8lMaxCodeLength  = 15
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Predictor modes tried for each block: left, top, average of left and top,
+// and clamped gradient
+var vp8lPredictorModes = []uint32{1, 2, 7, 12}
+
+// predict picks a predictor mode per block and returns the mode image and the residuals
+func predict(argb []uint32, width, height int) ([]uint32, []uint32) {
+	blockSize := 1 << vp8lPredictorBits
+	tilesX := (width + blockSize - 1) / blockSize
+	tilesY := (height + blockSize - 1) / blockSize
+	modes := make([]uint32, tilesX*tilesY)
+	residuals := make([]uint32, len(argb))
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			best, bestCost := vp8lPredictorModes[0], -1
+			for _, mode := range vp8lPredictorModes {
+				cost := 0
+				for y := ty * blockSize; y < min((ty+1)*blockSize, height); y++ {
+					for x := tx * blockSize; x < min((tx+1)*blockSize, width); x++ {
+						cost += residualCost(subPixels(argb[y*width+x], predictPixel(argb, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = best << 8
+
+			for y := ty * blockSize; y < min((ty+1)*blockSize, height); y++ {
+				for x := tx * blockSize; x < min((tx+1)*blockSize, width); x++ {
+					residuals[y*width+x] = subPixels(argb[y*width+x], predictPixel(argb, width, x, y, best))
+				}
+			}
+		}
+	}
+	return modes, residuals
+}
+
+func predictPixel(argb []uint32, width, x, y int, mode uint32) uint32 {
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[x-1]
+	case x == 0:
+		return argb[(y-1)*width]
+	}
+
+	left := argb[y*width+x-1]
+	top := argb[(y-1)*width+x]
+	switch mode {
+	case 1:
+		return left
+	case 2:
+		return top
+	case 7:
+		return average2(left, top)
+	default:
+		return clampAddSubtractFull(left, top, argb[(y-1)*width+x-1])
+	}
+}
+
+func average2(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+func clampAddSubtractFull(a, b, c uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(a>>shift&0xff) + int(b>>shift&0xff) - int(c>>shift&0xff)
+		out |= uint32(min(max(v, 0), 255)) << shift
+	}
+	return out
+}
+
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		out |= ((a>>shift - b>>shift) & 0xff) << shift
+	}
+	return out
+}
+
+func residualCost(p uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(int8(p >> shift))
+		if v < 0 {
+			v = -v
+		}
+		cost += v
+	}
+	return cost
+}
+
+// writeEntropyImage writes pixels as literals with one set of prefix codes
+func writeEntropyImage(bw *bitWriter, argb []uint32, main bool) {
+	bw.write(0, 1) // No color cache
+	if main {
+		bw.write(0, 1) // No meta prefix codes
+	}
+
+	green := make([]int, vp8lNumLiteral+vp8lNumLengthCodes)
+	red := make([]int, vp8lNumLiteral)
+	blue := make([]int, vp8lNumLiteral)
+	alpha := make([]int, vp8lNumLiteral)
+	for _, p := range argb {
+		green[p>>8&0xff]++
+		red[p>>16&0xff]++
+		blue[p&0xff]++
+		alpha[p>>24]++
+	}
+
+	codes := [5]prefixCode{
+		writePrefixCode(bw, green),
+		writePrefixCode(bw, red),
+		writePrefixCode(bw, blue),
+		writePrefixCode(bw, alpha),
+		writePrefixCode(bw, make([]int, vp8lNumDistance)),
+	}
+
+	for _, p := range argb {
+		codes[0].write(bw, int(p>>8&0xff))
+		codes[1].write(bw, int(p>>16&0xff))
+		codes[2].write(bw, int(p&0xff))
+		codes[3].write(bw, int(p>>24))
+	}
+}
+
+// prefixCode holds bit-reversed canonical codes ready for the LSB-first writer
+type prefixCode struct {
+	codes   []uint32
+	lengths []uint8
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode builds a prefix code for the histogram and writes its
+// description. Alphabets with at most one used symbol use a simple code,
+// whose symbol takes no bits.
+func writePrefixCode(bw *bitWriter, histogram []int) prefixCode {
+	used := []int{}
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 1 {
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+		bw.write(1, 1) // Simple code
+		bw.write(0, 1) // One symbol
+		if symbol < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbol), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbol), 8)
+		}
+		return prefixCode{codes: make([]uint32, len(histogram)), lengths: make([]uint8, len(histogram))}
+	}
+
+	lengths := huffmanLengths(histogram, vp8lMaxCodeLength)
+
+	// The code lengths are themselves prefix coded, without run lengths
+	lengthHistogram := make([]int, 19)
+	for _, length := range lengths {
+		lengthHistogram[length]++
+	}
+	lengthLengths := huffmanLengths(lengthHistogram, 7)
+	if nonZero(lengthLengths) == 1 {
+		// All symbols share one length: pair it with an unused length to form a complete code
+		for symbol, length := range lengthLengths {
+			if length == 0 {
+				lengthLengths[symbol] = 1
+				break
+			}
+		}
+	}
+	lengthCodes := canonicalCodes(lengthLengths)
+
+	numCodes := 19
+	for numCodes > 4 && lengthLengths[vp8lCodeLengthOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	bw.write(0, 1) // Normal code
+	bw.write(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		bw.write(uint32(lengthLengths[vp8lCodeLengthOrder[i]]), 3)
+	}
+	bw.write(0, 1) // Code lengths for the whole alphabet
+	for _, length := range lengths {
+		bw.write(lengthCodes[length], uint(lengthLengths[length]))
+	}
+
+	return prefixCode{codes: canonicalCodes(lengths), lengths: lengths}
+}
+
+func nonZero(lengths []uint8) int {
+	n := 0
+	for _, length := range lengths {
+		if length > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// huffmanLengths returns code lengths for a histogram with at least two used
+// symbols, limited to maxLength by flattening the histogram until they fit
+func huffmanLengths(histogram []int, maxLength int) []uint8 {
+	counts := append([]int(nil), histogram...)
+	for {
+		lengths := buildHuffmanLengths(counts)
+		longest := uint8(0)
+		for _, length := range lengths {
+			longest = max(longest, length)
+		}
+		if int(longest) <= maxLength {
+			return lengths
+		}
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = count/2 + 1
+			}
+		}
+	}
+}
+
+func buildHuffmanLengths(counts []int) []uint8 {
+	type node struct {
+		count       int
+		symbol      int // -1 for internal nodes
+		left, right int
+	}
+
+	var nodes []node
+	var queue []int
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, symbol: symbol})
+			queue = append(queue, len(nodes)-1)
+		}
+	}
+
+	lengths := make([]uint8, len(counts))
+	if len(queue) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	for len(queue) > 1 {
+		sort.SliceStable(queue, func(i, j int) bool { return nodes[queue[i]].count < nodes[queue[j]].count })
+		a, b := queue[0], queue[1]
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, symbol: -1, left: a, right: b})
+		queue = append(queue[2:], len(nodes)-1)
+	}
+
+	var walk func(index int, depth uint8)
+	walk = func(index int, depth uint8) {
+		n := nodes[index]
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(queue[0], 0)
+	return lengths
+}
+
+// canonicalCodes assigns canonical codes to lengths, bit-reversed since the
+// bit writer fills bytes from the least significant bit
+func canonicalCodes(lengths []uint8) []uint32 {
+	var count [vp8lMaxCodeLength + 2]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			count[length]++
+		}
+	}
+
+	var next [vp8lMaxCodeLength + 2]uint32
+	code := uint32(0)
+	for bits := 1; bits < len(next); bits++ {
+		code = (code + count[bits-1]) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		c := next[length]
+		next[length]++
+		var reversed uint32
+		for i := uint8(0); i < length; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -15,17 +16,19 @@ const (
 	AuthorizationHeader = "Authorization"
 	UserContextKey      = "user"
 	UserIDContextKey    = "user_id"
+	SessionContextKey   = "session"
+	AccessTokenKey      = "access_token"
 )
 
 type AuthMiddleware struct {
-	authService *service.AuthService
-	userService *service.UserService
+	authService        *service.AuthService
+	accessTokenService *service.AccessTokenService
 }
 
-func NewAuthMiddleware(authService *service.AuthService, userService *service.UserService) *AuthMiddleware {
+func NewAuthMiddleware(authService *service.AuthService, accessTokenService *service.AccessTokenService) *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: authService,
-		userService: userService,
+		authService:        authService,
+		accessTokenService: accessTokenService,
 	}
 }
 
@@ -43,30 +46,67 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := m.authService.ValidateToken(parts[1])
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		if strings.HasPrefix(parts[1], domain.AccessTokenPrefix) {
+			m.authenticateAccessToken(c, parts[1])
 			return
 		}
 
-		userID, err := primitive.ObjectIDFromHex(claims.UserID)
+		user, session, err := m.authService.Authenticate(c.Request.Context(), parts[1])
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
-			return
-		}
-
-		user, err := m.userService.GetByID(c.Request.Context(), userID)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			switch {
+			case errors.Is(err, service.ErrUserBlocked):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user is blocked"})
+			case errors.Is(err, service.ErrSessionRevoked):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired or revoked"})
+			default:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			}
 			return
 		}
 
 		c.Set(UserContextKey, user)
-		c.Set(UserIDContextKey, userID)
+		c.Set(UserIDContextKey, user.ID)
+		c.Set(SessionContextKey, session)
 		c.Next()
 	}
 }
 
+// authenticateAccessToken authenticates a request made with a personal access
+// token. Tokens reach project routes, where their scopes are checked, and read
+// the current user and the project list; everything else is refused.
+func (m *AuthMiddleware) authenticateAccessToken(c *gin.Context, secret string) {
+	if !accessTokenRoute(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access tokens cannot be used on this route"})
+		return
+	}
+
+	user, token, err := m.accessTokenService.Authenticate(c.Request.Context(), secret, c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserBlocked):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user is blocked"})
+		case errors.Is(err, service.ErrAccessTokenExpired):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "access token expired"})
+		default:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		}
+		return
+	}
+
+	c.Set(UserContextKey, user)
+	c.Set(UserIDContextKey, user.ID)
+	c.Set(AccessTokenKey, token)
+	c.Next()
+}
+
+func accessTokenRoute(c *gin.Context) bool {
+	path := c.FullPath()
+	if strings.HasPrefix(path, "/api/projects/:id/") || path == "/api/projects/:id" {
+		return true
+	}
+	return c.Request.Method == http.MethodGet && (path == "/api/projects" || path == "/api/users/me")
+}
+
 func (m *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get(UserContextKey)
@@ -115,3 +155,22 @@ func GetCurrentUserID(c *gin.Context) primitive.ObjectID {
 	}
 	return userID.(primitive.ObjectID)
 }
+
+// GetCurrentSession returns the login session of the request
+func GetCurrentSession(c *gin.Context) *domain.Session {
+	session, exists := c.Get(SessionContextKey)
+	if !exists {
+		return nil
+	}
+	return session.(*domain.Session)
+}
+
+// GetAccessToken returns the personal access token the request was made
+// with, nil for requests made with a login session
+func GetAccessToken(c *gin.Context) *domain.AccessToken {
+	token, exists := c.Get(AccessTokenKey)
+	if !exists {
+		return nil
+	}
+	return token.(*domain.AccessToken)
+}
@@ -0,0 +1,281 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MockIdentity is the user a MockProvider signs in
+type MockIdentity struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// MockProvider is an OpenID Connect provider for local development and
+// tests. Its authorize page asks for the identity to sign in as; every
+// client ID is accepted and client secrets are not checked.
+type MockProvider struct {
+	Issuer   string
+	Identity MockIdentity // Prefills the authorize page
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]*mockGrant
+	tokens map[string]*mockGrant
+}
+
+type mockGrant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	identity    MockIdentity
+	expiresAt   time.Time
+}
+
+// NewMockProvider creates a provider with a fresh signing key
+func NewMockProvider(issuer string, identity MockIdentity) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockProvider{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		Identity: identity,
+		key:      key,
+		codes:    make(map[string]*mockGrant),
+		tokens:   make(map[string]*mockGrant),
+	}, nil
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		m.discovery(w)
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	case "/jwks":
+		m.jwks(w)
+	case "/userinfo":
+		m.userinfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockProvider) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.Issuer,
+		"authorization_endpoint":                m.Issuer + "/authorize",
+		"token_endpoint":                        m.Issuer + "/token",
+		"userinfo_endpoint":                     m.Issuer + "/userinfo",
+		"jwks_uri":                              m.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+var mockAuthorizePage = template.Must(template.New("authorize").Parse(`<!doctype html>
+<html><head><title>Mock IdP</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 48px auto">
+<h2>Mock identity provider</h2>
+<p>Signing in to <b>{{.ClientID}}</b></p>
+<form method="post">
+{{range $name, $values := .Query}}<input type="hidden" name="{{$name}}" value="{{index $values 0}}">
+{{end}}<p><label>Subject<br><input name="sub" value="{{.Identity.Subject}}" style="width: 100%"></label></p>
+<p><label>Email<br><input name="email" value="{{.Identity.Email}}" style="width: 100%"></label></p>
+<p><label>Name<br><input name="name" value="{{.Identity.Name}}" style="width: 100%"></label></p>
+<p><label>Groups (comma separated)<br><input name="groups" value="{{.Groups}}" style="width: 100%"></label></p>
+<button type="submit">Sign in</button>
+</form>
+</body></html>`))
+
+// authorize shows the sign-in form on GET and issues a code on POST
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	redirectURI := r.Form.Get("redirect_uri")
+	target, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" || r.Form.Get("client_id") == "" {
+		http.Error(w, "client_id and redirect_uri are required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		query := url.Values{}
+		for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			query.Set(name, r.Form.Get(name))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		mockAuthorizePage.Execute(w, map[string]any{
+			"ClientID": r.Form.Get("client_id"),
+			"Query":    query,
+			"Identity": m.Identity,
+			"Groups":   strings.Join(m.Identity.Groups, ", "),
+		})
+		return
+	}
+
+	params := target.Query()
+	params.Set("state", r.Form.Get("state"))
+	if r.Form.Get("code_challenge_method") != "S256" || r.Form.Get("code_challenge") == "" {
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "PKCE with S256 is required")
+	} else {
+		identity := MockIdentity{
+			Subject: r.Form.Get("sub"),
+			Email:   r.Form.Get("email"),
+			Name:    r.Form.Get("name"),
+		}
+		for _, group := range strings.Split(r.Form.Get("groups"), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				identity.Groups = append(identity.Groups, group)
+			}
+		}
+		if identity.Subject == "" {
+			identity.Subject = identity.Email
+		}
+
+		code := RandomString()
+		m.mu.Lock()
+		m.codes[code] = &mockGrant{
+			clientID:    r.Form.Get("client_id"),
+			redirectURI: redirectURI,
+			challenge:   r.Form.Get("code_challenge"),
+			nonce:       r.Form.Get("nonce"),
+			identity:    identity,
+			expiresAt:   time.Now().Add(time.Minute),
+		}
+		m.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.Form.Get("code")]
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	clientID := r.Form.Get("client_id")
+	if user, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	switch {
+	case !ok || time.Now().After(grant.expiresAt):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown or expired code"})
+		return
+	case grant.clientID != clientID || grant.redirectURI != r.Form.Get("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "client or redirect_uri mismatch"})
+		return
+	case Challenge(r.Form.Get("code_verifier")) != grant.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier mismatch"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.Issuer,
+		"sub":            grant.identity.Subject,
+		"aud":            grant.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.identity.Email,
+		"email_verified": true,
+		"name":           grant.identity.Name,
+		"groups":         grant.identity.Groups,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken := RandomString()
+	m.mu.Lock()
+	m.tokens[accessToken] = grant
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		IDToken:     idToken,
+		ExpiresIn:   300,
+	})
+}
+
+func (m *MockProvider) jwks(w http.ResponseWriter) {
+	e := big.NewInt(int64(m.key.PublicKey.E)).Bytes()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(e),
+		}},
+	})
+}
+
+func (m *MockProvider) userinfo(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	grant, ok := m.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	m.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":            grant.identity.Subject,
+		"email":          grant.identity.Email,
+		"email_verified": true,
+		"name":           grant.identity.Name,
+		"groups":         grant.identity.Groups,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func startMock(t *testing.T) (*MockProvider, *Provider) {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nil)
+	mock, err := NewMockProvider("http://"+server.Listener.Addr().String(), MockIdentity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = mock
+	server.Start()
+	t.Cleanup(server.Close)
+
+	provider, err := Discover(context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock, provider
+}
+
+// authorize submits the mock's sign-in form and returns the redirect to the client
+func authorize(t *testing.T, provider *Provider, cfg Config, state, nonce, verifier string, identity url.Values) url.Values {
+	t.Helper()
+	authURL, err := url.Parse(provider.AuthCodeURL(cfg, state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := authURL.Query()
+	for name, values := range identity {
+		form[name] = values
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authURL.RawQuery = ""
+	resp, err := client.PostForm(authURL.String(), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	_, provider := startMock(t)
+	ctx := context.Background()
+	cfg := Config{ClientID: "m3m", RedirectURI: "http://localhost/callback", Scopes: []string{"openid", "email"}}
+
+	state, nonce, verifier := RandomString(), RandomString(), RandomString()
+	params := authorize(t, provider, cfg, state, nonce, verifier, url.Values{
+		"sub": {"u-1"}, "email": {"jane@example.com"}, "name": {"Jane"}, "groups": {"admins, devs"},
+	})
+	if params.Get("state") != state || params.Get("code") == "" {
+		t.Fatalf("callback params %v", params)
+	}
+
+	if _, err := provider.Exchange(ctx, cfg, params.Get("code"), RandomString()); err == nil {
+		t.Fatal("exchange with a wrong verifier succeeded")
+	}
+	// A failed exchange still consumes the code
+	if _, err := provider.Exchange(ctx, cfg, params.Get("code"), verifier); err == nil {
+		t.Fatal("code was redeemed twice")
+	}
+
+	params = authorize(t, provider, cfg, state, nonce, verifier, url.Values{
+		"sub": {"u-1"}, "email": {"jane@example.com"}, "name": {"Jane"}, "groups": {"admins, devs"},
+	})
+	token, err := provider.Exchange(ctx, cfg, params.Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, token.IDToken, RandomString()); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("wrong nonce: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, "other", token.IDToken, nonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("wrong audience: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, cfg.ClientID, token.IDToken, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.String("sub") != "u-1" || claims.String("email") != "jane@example.com" {
+		t.Errorf("claims %v", claims)
+	}
+	if groups := claims.Strings("groups"); !slices.Equal(groups, []string{"admins", "devs"}) {
+		t.Errorf("groups %v", groups)
+	}
+	if verified, ok := claims.Bool("email_verified"); !ok || !verified {
+		t.Error("email_verified missing")
+	}
+
+	info, err := provider.UserInfo(ctx, token.AccessToken)
+	if err != nil || info.String("name") != "Jane" {
+		t.Errorf("userinfo %v %v", info, err)
+	}
+}
+
+func TestVerifyIDTokenForeignKey(t *testing.T) {
+	_, provider := startMock(t)
+	ctx := context.Background()
+	cfg := Config{ClientID: "m3m", RedirectURI: "http://localhost/callback", Scopes: []string{"openid"}}
+
+	nonce, verifier := RandomString(), RandomString()
+	params := authorize(t, provider, cfg, "s", nonce, verifier, url.Values{"sub": {"u-1"}})
+	token, err := provider.Exchange(ctx, cfg, params.Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same claims and key ID, signed by another key
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token.IDToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	forged.Header["kid"] = "mock"
+	raw, err := forged.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, cfg.ClientID, raw, nonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("token signed by another key: %v", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	if got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Challenge = %s", got)
+	}
+}
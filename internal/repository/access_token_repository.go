@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var ErrAccessTokenNotFound = errors.New("access token not found")
+
+type AccessTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAccessTokenRepository(db *MongoDB) *AccessTokenRepository {
+	collection := db.Collection("access_tokens")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+
+	return &AccessTokenRepository{collection: collection}
+}
+
+func (r *AccessTokenRepository) Create(ctx context.Context, token *domain.AccessToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *AccessTokenRepository) FindByHash(ctx context.Context, hash string) (*domain.AccessToken, error) {
+	var token domain.AccessToken
+	err := r.collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAccessTokenNotFound
+	}
+	return &token, err
+}
+
+func (r *AccessTokenRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AccessToken, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := make([]*domain.AccessToken, 0)
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Touch records the use of a token
+func (r *AccessTokenRepository) Touch(ctx context.Context, id primitive.ObjectID, now time.Time, ip string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": now, "last_used_ip": ip}})
+	return err
+}
+
+// Delete deletes a token of a user
+func (r *AccessTokenRepository) Delete(ctx context.Context, userID, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
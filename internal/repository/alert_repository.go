@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+type AlertRepository struct {
+	rulesCollection  *mongo.Collection
+	eventsCollection *mongo.Collection
+}
+
+func NewAlertRepository(db *MongoDB) *AlertRepository {
+	rulesCollection := db.Collection("alert_rules")
+	eventsCollection := db.Collection("alert_events")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rulesCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "project_id", Value: 1}}},
+		{Keys: bson.D{{Key: "enabled", Value: 1}, {Key: "source", Value: 1}}},
+	})
+	eventsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "rule_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	return &AlertRepository{
+		rulesCollection:  rulesCollection,
+		eventsCollection: eventsCollection,
+	}
+}
+
+func (r *AlertRepository) Create(ctx context.Context, rule *domain.AlertRule) error {
+	rule.ID = primitive.NewObjectID()
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	_, err := r.rulesCollection.InsertOne(ctx, rule)
+	return err
+}
+
+func (r *AlertRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*domain.AlertRule, error) {
+	var rule domain.AlertRule
+	err := r.rulesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&rule)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAlertRuleNotFound
+	}
+	return &rule, err
+}
+
+func (r *AlertRepository) FindByProject(ctx context.Context, projectID primitive.ObjectID) ([]*domain.AlertRule, error) {
+	return r.find(ctx, bson.M{"project_id": projectID})
+}
+
+// FindEnabled returns the enabled rules watching a source
+func (r *AlertRepository) FindEnabled(ctx context.Context, source domain.AlertSource) ([]*domain.AlertRule, error) {
+	return r.find(ctx, bson.M{"enabled": true, "source": source})
+}
+
+func (r *AlertRepository) find(ctx context.Context, filter bson.M) ([]*domain.AlertRule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.rulesCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := make([]*domain.AlertRule, 0)
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *AlertRepository) Update(ctx context.Context, rule *domain.AlertRule) error {
+	rule.UpdatedAt = time.Now()
+	_, err := r.rulesCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": rule.ID},
+		bson.M{"$set": rule},
+	)
+	return err
+}
+
+// UpdateState stores the evaluation state of a rule without touching its settings
+func (r *AlertRepository) UpdateState(ctx context.Context, id primitive.ObjectID, state domain.AlertState) error {
+	_, err := r.rulesCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"state": state}})
+	return err
+}
+
+func (r *AlertRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.rulesCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAlertRuleNotFound
+	}
+	return nil
+}
+
+func (r *AlertRepository) InsertEvent(ctx context.Context, event *domain.AlertEvent) error {
+	event.ID = primitive.NewObjectID()
+	_, err := r.eventsCollection.InsertOne(ctx, event)
+	return err
+}
+
+// FindEvents returns the newest alert events of a project, optionally of one rule
+func (r *AlertRepository) FindEvents(ctx context.Context, projectID primitive.ObjectID, ruleID *primitive.ObjectID, limit int64) ([]*domain.AlertEvent, error) {
+	filter := bson.M{"project_id": projectID}
+	if ruleID != nil {
+		filter["rule_id"] = *ruleID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := r.eventsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]*domain.AlertEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
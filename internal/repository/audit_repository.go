@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+// AuditRepository stores the audit log. It only inserts and reads, entries
+// are never updated or deleted.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *MongoDB) *AuditRepository {
+	collection := db.Collection("audit_log")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	return &AuditRepository{collection: collection}
+}
+
+func (r *AuditRepository) Insert(ctx context.Context, entry *domain.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// Find returns the newest audit entries matching a filter
+func (r *AuditRepository) Find(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(f.Limit)
+	cursor, err := r.collection.Find(ctx, auditQuery(f), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := make([]*domain.AuditEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Each calls fn with the audit entries matching a filter, oldest first,
+// without loading them all at once
+func (r *AuditRepository) Each(ctx context.Context, f domain.AuditFilter, fn func(*domain.AuditEntry) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	if f.Limit > 0 {
+		opts.SetLimit(f.Limit)
+	}
+	cursor, err := r.collection.Find(ctx, auditQuery(f), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry domain.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func auditQuery(f domain.AuditFilter) bson.M {
+	filter := bson.M{}
+	if f.ProjectID != nil {
+		filter["project_id"] = *f.ProjectID
+	}
+	if f.ActorID != nil {
+		filter["actor_id"] = *f.ActorID
+	}
+	if f.Action != "" {
+		if strings.Contains(f.Action, ".") {
+			filter["action"] = f.Action
+		} else {
+			filter["action"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Action) + `\.`}
+		}
+	}
+	if f.From != nil || f.To != nil {
+		createdAt := bson.M{}
+		if f.From != nil {
+			createdAt["$gte"] = *f.From
+		}
+		if f.To != nil {
+			createdAt["$lt"] = *f.To
+		}
+		filter["created_at"] = createdAt
+	}
+	return filter
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -36,7 +37,7 @@ func NewGoalRepository(db *MongoDB) *GoalRepository {
 	})
 
 	statsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "goal_id", Value: 1}, {Key: "date", Value: 1}}},
+		{Keys: bson.D{{Key: "goal_id", Value: 1}, {Key: "date", Value: 1}, {Key: "hour", Value: 1}, {Key: "label_key", Value: 1}}},
 		{Keys: bson.D{{Key: "project_id", Value: 1}}},
 	})
 
@@ -125,21 +126,48 @@ func (r *GoalRepository) Delete(ctx context.Context, id primitive.ObjectID) erro
 	return nil
 }
 
-func (r *GoalRepository) IncrementStat(ctx context.Context, goalID, projectID primitive.ObjectID, date string, value int64) error {
+// MergeStat adds a stat to the stored stat of the same goal, project, date,
+// hour and labels, creating it if needed
+func (r *GoalRepository) MergeStat(ctx context.Context, stat *domain.GoalStat) error {
 	filter := bson.M{
-		"goal_id":    goalID,
-		"project_id": projectID,
-		"date":       date,
+		"goal_id":    stat.GoalID,
+		"project_id": stat.ProjectID,
+		"date":       stat.Date,
+		"hour":       bson.M{"$exists": false},
+		"label_key":  bson.M{"$exists": false},
 	}
-	update := bson.M{
-		"$inc": bson.M{"value": value},
-		"$set": bson.M{"updated_at": time.Now()},
+	set := bson.M{"updated_at": time.Now()}
+	inc := bson.M{"value": stat.Value}
+
+	if stat.Hour != nil {
+		filter["hour"] = *stat.Hour
+	}
+	if key := domain.GoalLabelKey(stat.Labels); key != "" {
+		filter["label_key"] = key
+		set["labels"] = stat.Labels
+	}
+
+	update := bson.M{"$inc": inc, "$set": set}
+	if m := stat.Metric; m != nil {
+		inc["metric.count"] = m.Count
+		inc["metric.sum"] = m.Sum
+		for bucket, count := range m.Buckets {
+			inc["metric.buckets."+bucket] = count
+		}
+		set["metric.last"] = m.Last
+		set["metric.last_at"] = m.LastAt
+		update["$min"] = bson.M{"metric.min": m.Min}
+		update["$max"] = bson.M{"metric.max": m.Max}
 	}
+
 	opts := options.Update().SetUpsert(true)
 	_, err := r.statsCollection.UpdateOne(ctx, filter, update, opts)
 	return err
 }
 
+// GetStats returns the stats of goals aggregated by goal and interval (day by
+// default), split by the query's GroupBy label. Raw queries return the stored
+// stats as they are.
 func (r *GoalRepository) GetStats(ctx context.Context, query *domain.GoalStatsQuery) ([]*domain.GoalStat, error) {
 	filter := bson.M{}
 
@@ -154,10 +182,12 @@ func (r *GoalRepository) GetStats(ctx context.Context, query *domain.GoalStatsQu
 		filter["goal_id"] = bson.M{"$in": goalOIDs}
 	}
 
+	var projectID primitive.ObjectID
 	if query.ProjectID != "" {
 		oid, err := primitive.ObjectIDFromHex(query.ProjectID)
 		if err == nil {
 			filter["project_id"] = oid
+			projectID = oid
 		}
 	}
 
@@ -172,6 +202,14 @@ func (r *GoalRepository) GetStats(ctx context.Context, query *domain.GoalStatsQu
 		filter["date"] = bson.M{"$lte": query.EndDate}
 	}
 
+	labels, err := domain.ParseGoalLabels(query.Labels)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range labels {
+		filter["labels."+key] = value
+	}
+
 	cursor, err := r.statsCollection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -182,7 +220,89 @@ func (r *GoalRepository) GetStats(ctx context.Context, query *domain.GoalStatsQu
 	if err := cursor.All(ctx, &stats); err != nil {
 		return nil, err
 	}
-	return stats, nil
+	if query.Raw {
+		return stats, nil
+	}
+	return aggregateGoalStats(stats, projectID, query.Interval, query.GroupBy), nil
+}
+
+// aggregateGoalStats merges stats that fall into the same goal, interval and
+// group label value
+func aggregateGoalStats(stats []*domain.GoalStat, projectID primitive.ObjectID, interval, groupBy string) []*domain.GoalStat {
+	type bucketKey struct {
+		goalID primitive.ObjectID
+		date   string
+		hour   int
+		label  string
+	}
+
+	buckets := make(map[bucketKey]*domain.GoalStat)
+	result := make([]*domain.GoalStat, 0)
+	for _, stat := range stats {
+		key := bucketKey{goalID: stat.GoalID, date: stat.Date, hour: -1}
+		switch interval {
+		case domain.GoalIntervalTotal:
+			key.date = "total"
+		case domain.GoalIntervalHour:
+			if stat.Hour != nil && stat.Date != "total" {
+				key.hour = *stat.Hour
+			}
+		}
+		var labels map[string]string
+		if groupBy != "" {
+			key.label = stat.Labels[groupBy]
+			labels = map[string]string{groupBy: key.label}
+		}
+
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &domain.GoalStat{
+				GoalID:    stat.GoalID,
+				ProjectID: projectID,
+				Date:      key.date,
+				Labels:    labels,
+			}
+			if key.hour >= 0 {
+				hour := key.hour
+				bucket.Hour = &hour
+			}
+			buckets[key] = bucket
+			result = append(result, bucket)
+		}
+
+		bucket.Value += stat.Value
+		if stat.Metric != nil {
+			if bucket.Metric == nil {
+				bucket.Metric = &domain.GoalMetric{}
+			}
+			bucket.Metric.Merge(stat.Metric)
+		}
+		if stat.UpdatedAt.After(bucket.UpdatedAt) {
+			bucket.UpdatedAt = stat.UpdatedAt
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.GoalID != b.GoalID {
+			return a.GoalID.Hex() < b.GoalID.Hex()
+		}
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if ah, bh := hourOf(a), hourOf(b); ah != bh {
+			return ah < bh
+		}
+		return a.Labels[groupBy] < b.Labels[groupBy]
+	})
+	return result
+}
+
+func hourOf(stat *domain.GoalStat) int {
+	if stat.Hour == nil {
+		return -1
+	}
+	return *stat.Hour
 }
 
 // ResetStats deletes all stats for a goal
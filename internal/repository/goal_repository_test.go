@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestAggregateGoalStats(t *testing.T) {
+	goalID := primitive.NewObjectID()
+	bounds := []float64{10, 100, 1000}
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	sample := func(date string, hour int, region string, value float64, offset time.Duration) *domain.GoalStat {
+		return &domain.GoalStat{
+			GoalID: goalID,
+			Date:   date,
+			Hour:   &hour,
+			Labels: map[string]string{"region": region},
+			Value:  1,
+			Metric: domain.NewGoalMetric(value, base.Add(offset), bounds),
+		}
+	}
+	stats := []*domain.GoalStat{
+		sample("2026-03-01", 9, "eu", 5, 0),
+		sample("2026-03-01", 9, "us", 50, time.Minute),
+		sample("2026-03-01", 10, "eu", 500, time.Hour),
+		sample("2026-03-02", 8, "eu", 80, 24*time.Hour),
+	}
+
+	t.Run("by day", func(t *testing.T) {
+		result := aggregateGoalStats(stats, primitive.NilObjectID, "", "")
+		if len(result) != 2 {
+			t.Fatalf("got %d days, want 2", len(result))
+		}
+		day := result[0]
+		if day.Date != "2026-03-01" || day.Hour != nil || day.Labels != nil || day.Value != 3 {
+			t.Errorf("unexpected first day %+v", day)
+		}
+		m := day.Metric
+		m.Finish(bounds)
+		if m.Count != 3 || m.Sum != 555 || m.Min != 5 || m.Max != 500 || m.Last != 500 || m.Avg != 185 {
+			t.Errorf("unexpected metric %+v", m)
+		}
+		// One sample per bucket: the median falls in the middle of (10, 100]
+		if p50 := m.Percentiles["p50"]; math.Abs(p50-55) > 1e-9 {
+			t.Errorf("p50 = %v, want 55", p50)
+		}
+		if p99 := m.Percentiles["p99"]; p99 <= 100 || p99 > 500 {
+			t.Errorf("p99 = %v, want within (100, 500]", p99)
+		}
+	})
+
+	t.Run("by hour and label", func(t *testing.T) {
+		result := aggregateGoalStats(stats, primitive.NilObjectID, domain.GoalIntervalHour, "region")
+		if len(result) != 4 {
+			t.Fatalf("got %d buckets, want 4", len(result))
+		}
+		first, second := result[0], result[1]
+		if *first.Hour != 9 || first.Labels["region"] != "eu" || *second.Hour != 9 || second.Labels["region"] != "us" {
+			t.Errorf("unexpected order: %+v, %+v", first, second)
+		}
+	})
+
+	t.Run("total by label", func(t *testing.T) {
+		result := aggregateGoalStats(stats, primitive.NilObjectID, domain.GoalIntervalTotal, "region")
+		if len(result) != 2 {
+			t.Fatalf("got %d buckets, want 2", len(result))
+		}
+		eu := result[0]
+		if eu.Date != "total" || eu.Labels["region"] != "eu" || eu.Metric.Count != 3 || eu.Metric.Last != 80 {
+			t.Errorf("unexpected eu total %+v %+v", eu, eu.Metric)
+		}
+	})
+}
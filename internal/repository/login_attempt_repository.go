@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+type LoginAttemptRepository struct {
+	throttlesCollection *mongo.Collection
+	eventsCollection    *mongo.Collection
+}
+
+func NewLoginAttemptRepository(db *MongoDB) *LoginAttemptRepository {
+	throttlesCollection := db.Collection("login_throttles")
+	eventsCollection := db.Collection("login_events")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	eventsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	return &LoginAttemptRepository{
+		throttlesCollection: throttlesCollection,
+		eventsCollection:    eventsCollection,
+	}
+}
+
+// FindThrottle returns the failure count of a key, nil when it has none
+func (r *LoginAttemptRepository) FindThrottle(ctx context.Context, key string) (*domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	err := r.throttlesCollection.FindOne(ctx, bson.M{"_id": key}).Decode(&throttle)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// AddFailure counts a failed login of a key and returns the new count.
+// Failures before since are forgotten and counting starts over.
+func (r *LoginAttemptRepository) AddFailure(ctx context.Context, key string, now, since time.Time) (*domain.LoginThrottle, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var throttle domain.LoginThrottle
+	err := r.throttlesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key, "last_failure": bson.M{"$gte": since}},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure": now}},
+		opts,
+	).Decode(&throttle)
+	if err == nil {
+		return &throttle, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	err = r.throttlesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"failures": 1, "last_failure": now}},
+		opts.SetUpsert(true),
+	).Decode(&throttle)
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Lock locks a key until the given time, starting its failure count over,
+// and returns how many lockouts in a row it had
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) (int, error) {
+	var throttle domain.LoginThrottle
+	err := r.throttlesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$inc": bson.M{"lockouts": 1}, "$set": bson.M{"failures": 0, "locked_until": until}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true),
+	).Decode(&throttle)
+	if err != nil {
+		return 0, err
+	}
+	return throttle.Lockouts, nil
+}
+
+// DeleteThrottle forgets the failures and lockouts of a key
+func (r *LoginAttemptRepository) DeleteThrottle(ctx context.Context, key string) error {
+	_, err := r.throttlesCollection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (r *LoginAttemptRepository) InsertEvent(ctx context.Context, event *domain.LoginEvent) error {
+	event.ID = primitive.NewObjectID()
+	_, err := r.eventsCollection.InsertOne(ctx, event)
+	return err
+}
+
+// FindEvents returns the newest login events matching a filter
+func (r *LoginAttemptRepository) FindEvents(ctx context.Context, f domain.LoginEventFilter) ([]*domain.LoginEvent, error) {
+	filter := bson.M{}
+	if f.Email != "" {
+		filter["email"] = f.Email
+	}
+	if f.IP != "" {
+		filter["ip"] = f.IP
+	}
+	if f.UserID != nil {
+		filter["user_id"] = *f.UserID
+	}
+	if f.Since != nil {
+		filter["created_at"] = bson.M{"$gte": *f.Since}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(f.Limit)
+	cursor, err := r.eventsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]*domain.LoginEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteEventsBefore deletes login events older than a time
+func (r *LoginAttemptRepository) DeleteEventsBefore(ctx context.Context, before time.Time) error {
+	_, err := r.eventsCollection.DeleteMany(ctx, bson.M{"created_at": bson.M{"$lt": before}})
+	return err
+}
@@ -376,6 +376,33 @@ func (r *ModelRepository) DropDataCollection(ctx context.Context, model *domain.
 	return r.db.Collection(collectionName).Drop(ctx)
 }
 
+// EachData iterates over all raw documents of a model's data collection
+func (r *ModelRepository) EachData(ctx context.Context, model *domain.Model, fn func(doc bson.Raw) error) error {
+	collectionName := r.dataCollectionName(model.ProjectID, model.Slug)
+	cursor, err := r.db.Collection(collectionName).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// InsertRawData inserts documents as-is, keeping their ids and system fields
+func (r *ModelRepository) InsertRawData(ctx context.Context, model *domain.Model, docs []interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	collectionName := r.dataCollectionName(model.ProjectID, model.Slug)
+	_, err := r.db.Collection(collectionName).InsertMany(ctx, docs)
+	return err
+}
+
 // ExistsDataByFilter checks if a document exists matching the filter
 func (r *ModelRepository) ExistsDataByFilter(ctx context.Context, model *domain.Model, filter bson.M) (bool, error) {
 	collectionName := r.dataCollectionName(model.ProjectID, model.Slug)
@@ -624,3 +651,23 @@ func (r *ModelRepository) GetProjectDataSize(ctx context.Context, projectID prim
 
 	return totalSize, nil
 }
+
+// CountProjectDocuments returns the number of documents in all data collections of a project
+func (r *ModelRepository) CountProjectDocuments(ctx context.Context, projectID primitive.ObjectID) (int64, error) {
+	models, err := r.FindByProject(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, model := range models {
+		count, err := r.db.Collection(r.dataCollectionName(model.ProjectID, model.Slug)).EstimatedDocumentCount(ctx)
+		if err != nil {
+			// Collection might not exist yet, skip
+			continue
+		}
+		total += count
+	}
+
+	return total, nil
+}
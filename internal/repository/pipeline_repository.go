@@ -254,6 +254,15 @@ func (r *PipelineRepository) DeleteRelease(ctx context.Context, id primitive.Obj
 	return nil
 }
 
+// DeleteByProject deletes all branches and releases of a project
+func (r *PipelineRepository) DeleteByProject(ctx context.Context, projectID primitive.ObjectID) error {
+	if _, err := r.branchesCollection.DeleteMany(ctx, bson.M{"project_id": projectID}); err != nil {
+		return err
+	}
+	_, err := r.releasesCollection.DeleteMany(ctx, bson.M{"project_id": projectID})
+	return err
+}
+
 // UpdateBranchFile updates a single file's code in a branch
 func (r *PipelineRepository) UpdateBranchFile(ctx context.Context, branchID primitive.ObjectID, fileName string, code string) error {
 	result, err := r.branchesCollection.UpdateOne(
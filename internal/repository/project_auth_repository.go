@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var (
+	ErrAuthUserNotFound   = errors.New("user not found")
+	ErrAuthUserExists     = errors.New("user with this email already exists")
+	ErrAuthResetTokenUsed = errors.New("reset token is invalid or expired")
+)
+
+// ProjectAuthRepository stores the end users of project services, their
+// password reset tokens and the secret each project signs tokens with
+type ProjectAuthRepository struct {
+	usersCollection   *mongo.Collection
+	resetsCollection  *mongo.Collection
+	secretsCollection *mongo.Collection
+}
+
+func NewProjectAuthRepository(db *MongoDB) *ProjectAuthRepository {
+	usersCollection := db.Collection("auth_users")
+	resetsCollection := db.Collection("auth_reset_tokens")
+	secretsCollection := db.Collection("auth_secrets")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	usersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	resetsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return &ProjectAuthRepository{
+		usersCollection:   usersCollection,
+		resetsCollection:  resetsCollection,
+		secretsCollection: secretsCollection,
+	}
+}
+
+// Secret returns the signing secret of a project, storing candidate as the
+// secret when the project has none yet
+func (r *ProjectAuthRepository) Secret(ctx context.Context, projectID primitive.ObjectID, candidate string) (string, error) {
+	var doc struct {
+		Secret string `bson:"secret"`
+	}
+	err := r.secretsCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": projectID},
+		bson.M{"$setOnInsert": bson.M{"secret": candidate, "created_at": time.Now()}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	return doc.Secret, err
+}
+
+func (r *ProjectAuthRepository) CreateUser(ctx context.Context, user *domain.AuthUser) error {
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	_, err := r.usersCollection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAuthUserExists
+	}
+	return err
+}
+
+func (r *ProjectAuthRepository) FindUser(ctx context.Context, projectID, id primitive.ObjectID) (*domain.AuthUser, error) {
+	return r.findUser(ctx, bson.M{"_id": id, "project_id": projectID})
+}
+
+func (r *ProjectAuthRepository) FindUserByEmail(ctx context.Context, projectID primitive.ObjectID, email string) (*domain.AuthUser, error) {
+	return r.findUser(ctx, bson.M{"project_id": projectID, "email": email})
+}
+
+func (r *ProjectAuthRepository) findUser(ctx context.Context, filter bson.M) (*domain.AuthUser, error) {
+	var user domain.AuthUser
+	err := r.usersCollection.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAuthUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns the users of a project, newest first, and their total
+func (r *ProjectAuthRepository) ListUsers(ctx context.Context, projectID primitive.ObjectID, skip, limit int64) ([]*domain.AuthUser, int64, error) {
+	filter := bson.M{"project_id": projectID}
+	total, err := r.usersCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := r.usersCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]*domain.AuthUser, 0)
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// UpdateUser applies a $set (and optional $inc) to a user and returns it updated
+func (r *ProjectAuthRepository) UpdateUser(ctx context.Context, projectID, id primitive.ObjectID, set, inc bson.M) (*domain.AuthUser, error) {
+	set["updated_at"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(inc) > 0 {
+		update["$inc"] = inc
+	}
+
+	var user domain.AuthUser
+	err := r.usersCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "project_id": projectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAuthUserNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrAuthUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// TouchLogin records a successful login without changing updated_at
+func (r *ProjectAuthRepository) TouchLogin(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.usersCollection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"last_login_at": at}})
+	return err
+}
+
+func (r *ProjectAuthRepository) DeleteUser(ctx context.Context, projectID, id primitive.ObjectID) error {
+	result, err := r.usersCollection.DeleteOne(ctx, bson.M{"_id": id, "project_id": projectID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAuthUserNotFound
+	}
+	_, err = r.resetsCollection.DeleteMany(ctx, bson.M{"user_id": id})
+	return err
+}
+
+func (r *ProjectAuthRepository) CreateResetToken(ctx context.Context, token *domain.AuthResetToken) error {
+	_, err := r.resetsCollection.InsertOne(ctx, token)
+	return err
+}
+
+// ConsumeResetToken deletes a reset token and returns it, once. The TTL
+// index removes expired tokens lazily, so expiry is checked here as well.
+func (r *ProjectAuthRepository) ConsumeResetToken(ctx context.Context, projectID primitive.ObjectID, hash string) (*domain.AuthResetToken, error) {
+	var token domain.AuthResetToken
+	err := r.resetsCollection.FindOneAndDelete(ctx, bson.M{
+		"_id":        hash,
+		"project_id": projectID,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAuthResetTokenUsed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteResetTokens invalidates the outstanding reset tokens of a user
+func (r *ProjectAuthRepository) DeleteResetTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.resetsCollection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -14,8 +14,9 @@ import (
 )
 
 var (
-	ErrProjectNotFound   = errors.New("project not found")
-	ErrProjectSlugExists = errors.New("project slug already exists")
+	ErrProjectNotFound       = errors.New("project not found")
+	ErrProjectSlugExists     = errors.New("project slug already exists")
+	ErrProjectMemberNotFound = errors.New("project member not found")
 )
 
 type ProjectRepository struct {
@@ -135,25 +136,42 @@ func (r *ProjectRepository) Delete(ctx context.Context, id primitive.ObjectID) e
 	return nil
 }
 
-func (r *ProjectRepository) AddMember(ctx context.Context, projectID, userID primitive.ObjectID) error {
+func (r *ProjectRepository) AddMember(ctx context.Context, projectID, userID primitive.ObjectID, role domain.ProjectRole) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": projectID},
 		bson.M{
 			"$addToSet": bson.M{"members": userID},
-			"$set":      bson.M{"updated_at": time.Now()},
+			"$set":      bson.M{"member_roles." + userID.Hex(): role, "updated_at": time.Now()},
 		},
 	)
 	return err
 }
 
+// SetMemberRole changes the role of an existing member
+func (r *ProjectRepository) SetMemberRole(ctx context.Context, projectID, userID primitive.ObjectID, role domain.ProjectRole) error {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": projectID, "members": userID},
+		bson.M{"$set": bson.M{"member_roles." + userID.Hex(): role, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrProjectMemberNotFound
+	}
+	return nil
+}
+
 func (r *ProjectRepository) RemoveMember(ctx context.Context, projectID, userID primitive.ObjectID) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": projectID},
 		bson.M{
-			"$pull": bson.M{"members": userID},
-			"$set":  bson.M{"updated_at": time.Now()},
+			"$pull":  bson.M{"members": userID},
+			"$unset": bson.M{"member_roles." + userID.Hex(): ""},
+			"$set":   bson.M{"updated_at": time.Now()},
 		},
 	)
 	return err
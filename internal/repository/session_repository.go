@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(db *MongoDB) *SessionRepository {
+	collection := db.Collection("sessions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "expires_at", Value: -1}}},
+	})
+
+	return &SessionRepository{collection: collection}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	session.ID = primitive.NewObjectID()
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *SessionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error) {
+	var session domain.Session
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, err
+}
+
+// FindActiveByUser returns the sessions of a user that are neither revoked
+// nor expired, most recently used first
+func (r *SessionRepository) FindActiveByUser(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]*domain.Session, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": nil, "expires_at": bson.M{"$gt": now}}
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := make([]*domain.Session, 0)
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Rotate replaces the refresh token of an active session, provided it still
+// is refreshHash; false means another request rotated it first
+func (r *SessionRepository) Rotate(ctx context.Context, id primitive.ObjectID, refreshHash, newHash string, now, expiresAt time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "refresh_hash": refreshHash, "revoked_at": nil}
+	update := bson.M{"$set": bson.M{
+		"refresh_hash":  newHash,
+		"previous_hash": refreshHash,
+		"last_used_at":  now,
+		"expires_at":    expiresAt,
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// Revoke revokes a session of a user; false means it was not active
+func (r *SessionRepository) Revoke(ctx context.Context, userID, id primitive.ObjectID, now time.Time) (bool, error) {
+	filter := bson.M{"_id": id, "user_id": userID, "revoked_at": nil}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// RevokeByUser revokes all active sessions of a user and returns their IDs
+func (r *SessionRepository) RevokeByUser(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]primitive.ObjectID, error) {
+	sessions, err := r.FindActiveByUser(ctx, userID, now)
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	filter := bson.M{"_id": bson.M{"$in": ids}, "revoked_at": nil}
+	if _, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": now}}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// DeleteInactive deletes the expired and revoked sessions of a user
+func (r *SessionRepository) DeleteInactive(ctx context.Context, userID primitive.ObjectID, now time.Time) error {
+	filter := bson.M{"user_id": userID, "$or": bson.A{
+		bson.M{"expires_at": bson.M{"$lte": now}},
+		bson.M{"revoked_at": bson.M{"$ne": nil}},
+	}}
+	_, err := r.collection.DeleteMany(ctx, filter)
+	return err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestSessionRepository_RotateAndRevoke(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewSessionRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+	userID := primitive.NewObjectID()
+
+	session := &domain.Session{UserID: userID, RefreshHash: "first", CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := repo.Create(ctx, session); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if rotated, err := repo.Rotate(ctx, session.ID, "first", "second", now, now.Add(2*time.Hour)); err != nil || !rotated {
+		t.Fatalf("Rotate failed: %v %v", rotated, err)
+	}
+	if rotated, _ := repo.Rotate(ctx, session.ID, "first", "third", now, now.Add(2*time.Hour)); rotated {
+		t.Error("a rotated refresh token was rotated again")
+	}
+	found, err := repo.FindByID(ctx, session.ID)
+	if err != nil || found.RefreshHash != "second" || found.PreviousHash != "first" {
+		t.Fatalf("unexpected session %+v, %v", found, err)
+	}
+
+	other := &domain.Session{UserID: userID, RefreshHash: "other", CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+	expired := &domain.Session{UserID: userID, RefreshHash: "expired", CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	repo.Create(ctx, other)
+	repo.Create(ctx, expired)
+
+	active, err := repo.FindActiveByUser(ctx, userID, now)
+	if err != nil || len(active) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d (%v)", len(active), err)
+	}
+
+	if revoked, err := repo.Revoke(ctx, userID, other.ID, now); err != nil || !revoked {
+		t.Fatalf("Revoke failed: %v %v", revoked, err)
+	}
+	if revoked, _ := repo.Revoke(ctx, primitive.NewObjectID(), session.ID, now); revoked {
+		t.Error("revoked the session of another user")
+	}
+
+	ids, err := repo.RevokeByUser(ctx, userID, now)
+	if err != nil || len(ids) != 1 || ids[0] != session.ID {
+		t.Fatalf("RevokeByUser revoked %v (%v)", ids, err)
+	}
+
+	if err := repo.DeleteInactive(ctx, userID, now); err != nil {
+		t.Fatalf("DeleteInactive failed: %v", err)
+	}
+	if _, err := repo.FindByID(ctx, expired.ID); err != ErrSessionNotFound {
+		t.Errorf("expired session was kept: %v", err)
+	}
+}
@@ -28,9 +28,9 @@ func NewUserRepository(db *MongoDB) *UserRepository {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "email", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sso_subject", Value: 1}}},
 	})
 
 	return &UserRepository{collection: collection}
@@ -66,6 +66,16 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain
 	return &user, err
 }
 
+// FindBySSOSubject finds the user linked to a subject of the OpenID Connect provider
+func (r *UserRepository) FindBySSOSubject(ctx context.Context, subject string) (*domain.User, error) {
+	var user domain.User
+	err := r.collection.FindOne(ctx, bson.M{"sso_subject": subject}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	return &user, err
+}
+
 func (r *UserRepository) FindAll(ctx context.Context) ([]*domain.User, error) {
 	cursor, err := r.collection.Find(ctx, bson.M{})
 	if err != nil {
@@ -113,3 +123,27 @@ func (r *UserRepository) FindRootUser(ctx context.Context) (*domain.User, error)
 	}
 	return &user, err
 }
+
+// UseTOTPStep records the time step of an accepted TOTP code. It fails when
+// the step is not after the last recorded one, so each code works once.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"two_factor.last_step": bson.M{"$lt": step}},
+		bson.M{"two_factor.last_step": bson.M{"$exists": false}},
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"two_factor.last_step": step}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseRecoveryCode removes a recovery code hash, reporting whether it was unused
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	filter := bson.M{"_id": id, "two_factor.recovery_codes": hash}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"two_factor.recovery_codes": hash}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
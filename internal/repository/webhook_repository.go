@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// webhookDeliveryTTL is how long deliveries are kept
+const webhookDeliveryTTL = 30 * 24 * time.Hour
+
+// WebhookRepository stores the deliveries of $router.webhook routes
+type WebhookRepository struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookRepository(db *MongoDB) *WebhookRepository {
+	collection := db.Collection("webhook_deliveries")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "webhook", Value: 1}, {Key: "delivery_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(webhookDeliveryTTL.Seconds())),
+		},
+	})
+
+	return &WebhookRepository{collection: collection}
+}
+
+func (r *WebhookRepository) Insert(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	delivery.ID = primitive.NewObjectID()
+	_, err := r.collection.InsertOne(ctx, delivery)
+	return err
+}
+
+func (r *WebhookRepository) FindByID(ctx context.Context, projectID, id primitive.ObjectID) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "project_id": projectID}).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Find returns the newest deliveries matching a filter, without their bodies
+func (r *WebhookRepository) Find(ctx context.Context, f domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	filter := bson.M{"project_id": f.ProjectID}
+	if f.Webhook != "" {
+		filter["webhook"] = f.Webhook
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(f.Limit).
+		SetProjection(bson.M{"body": 0})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Delivered reports whether a delivery id of a webhook was already handled
+func (r *WebhookRepository) Delivered(ctx context.Context, projectID primitive.ObjectID, webhook, deliveryID string) (bool, error) {
+	err := r.collection.FindOne(ctx, bson.M{
+		"project_id":  projectID,
+		"webhook":     webhook,
+		"delivery_id": deliveryID,
+		"status":      domain.WebhookDelivered,
+	}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
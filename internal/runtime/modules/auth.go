@@ -0,0 +1,980 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+	"github.com/levskiy0/m3m/internal/service"
+	"github.com/levskiy0/m3m/pkg/schema"
+)
+
+const (
+	authDefaultExpiresIn   = 24 * time.Hour
+	authDefaultResetTTL    = time.Hour
+	authDefaultMinPassword = 8
+
+	// authSessionClaim marks the tokens of logins, the only ones authenticate
+	// accepts; $auth.sign can't set it
+	authSessionClaim = "typ"
+	authSessionType  = "session"
+)
+
+var (
+	errAuthStoreUnavailable = errors.New("$auth user store is not available")
+	errAuthNoSecret         = errors.New("AUTH_SECRET is not set")
+	errAuthReservedClaim    = fmt.Errorf("the %q claim is reserved for login tokens, use $auth.issueToken", authSessionClaim)
+)
+
+// AuthUserInfo is an end user as seen by JS, without its password hash
+type AuthUserInfo struct {
+	ID          string                 `json:"id"`
+	Email       string                 `json:"email"`
+	Profile     map[string]interface{} `json:"profile"`
+	LastLoginAt string                 `json:"lastLoginAt,omitempty"`
+	CreatedAt   string                 `json:"createdAt"`
+	UpdatedAt   string                 `json:"updatedAt"`
+}
+
+// AuthConfig configures $auth.configure
+type AuthConfig struct {
+	ExpiresIn         interface{} `json:"expiresIn"` // Seconds or a duration like "15m", "7d"
+	Hash              string      `json:"hash"`      // bcrypt (default) or argon2id
+	MinPasswordLength int         `json:"minPasswordLength"`
+}
+
+// AuthSignOptions configures $auth.sign and $auth.issueToken
+type AuthSignOptions struct {
+	ExpiresIn interface{} `json:"expiresIn"` // 0 for tokens that never expire
+}
+
+// AuthUserUpdate changes a user in $auth.updateUser
+type AuthUserUpdate struct {
+	Email   *string                `json:"email"`
+	Profile map[string]interface{} `json:"profile"`
+}
+
+// AuthListOptions pages $auth.listUsers
+type AuthListOptions struct {
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+}
+
+// AuthUserList is a page of users with their total
+type AuthUserList struct {
+	Users []*AuthUserInfo `json:"users"`
+	Total int64           `json:"total"`
+}
+
+// AuthLoginResult is a successful login
+type AuthLoginResult struct {
+	Token string        `json:"token"`
+	User  *AuthUserInfo `json:"user"`
+}
+
+// AuthMiddlewareOptions configures $auth.middleware
+type AuthMiddlewareOptions struct {
+	Optional bool   `json:"optional"` // Let requests without a valid token through, without ctx.user
+	Cookie   string `json:"cookie"`   // Also read the token from this cookie
+}
+
+// AuthResetOptions configures $auth.requestPasswordReset
+type AuthResetOptions struct {
+	URL       string      `json:"url"` // Link to the reset page, {token} is replaced by the token
+	Subject   string      `json:"subject"`
+	Text      string      `json:"text"` // {url} and {token} are replaced
+	HTML      string      `json:"html"`
+	ExpiresIn interface{} `json:"expiresIn"`
+}
+
+// AuthModule gives project services their own users: signup and login with
+// hashed passwords, JWTs signed with a project secret, router middleware and
+// password resets by email
+type AuthModule struct {
+	authService *service.ProjectAuthService
+	projectID   primitive.ObjectID
+	envModule   *EnvModule
+	mailModule  *MailModule
+	vm          *goja.Runtime
+
+	mu                sync.Mutex
+	expiresIn         time.Duration
+	hashAlgo          string
+	minPasswordLength int
+	projectSecret     []byte
+	dummyHash         string
+}
+
+func NewAuthModule(authService *service.ProjectAuthService, projectID primitive.ObjectID, envModule *EnvModule, mailModule *MailModule) *AuthModule {
+	return &AuthModule{
+		authService:       authService,
+		projectID:         projectID,
+		envModule:         envModule,
+		mailModule:        mailModule,
+		expiresIn:         authDefaultExpiresIn,
+		hashAlgo:          PasswordAlgoBcrypt,
+		minPasswordLength: authDefaultMinPassword,
+	}
+}
+
+// Name returns the module name for JavaScript
+func (a *AuthModule) Name() string {
+	return "$auth"
+}
+
+// Register registers the module into the JavaScript VM
+func (a *AuthModule) Register(vm interface{}) {
+	a.vm = vm.(*goja.Runtime)
+	a.vm.Set(a.Name(), map[string]interface{}{
+		"configure":            a.Configure,
+		"register":             a.RegisterUser,
+		"login":                a.Login,
+		"authenticate":         a.Authenticate,
+		"issueToken":           a.IssueToken,
+		"sign":                 a.Sign,
+		"verify":               a.Verify,
+		"getUser":              a.GetUser,
+		"findUser":             a.FindUser,
+		"listUsers":            a.ListUsers,
+		"updateUser":           a.UpdateUser,
+		"setPassword":          a.SetPassword,
+		"deleteUser":           a.DeleteUser,
+		"revokeTokens":         a.RevokeTokens,
+		"hashPassword":         a.HashPassword,
+		"verifyPassword":       a.VerifyPassword,
+		"middleware":           a.Middleware,
+		"createResetToken":     a.CreateResetToken,
+		"requestPasswordReset": a.RequestPasswordReset,
+		"resetPassword":        a.ResetPassword,
+	})
+}
+
+// Configure changes the token lifetime, hash algorithm and minimum password
+// length for the rest of the run
+func (a *AuthModule) Configure(config AuthConfig) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if config.ExpiresIn != nil {
+		d, err := parseAuthDuration(config.ExpiresIn)
+		if err != nil {
+			return err
+		}
+		a.expiresIn = d
+	}
+	if config.Hash != "" {
+		if config.Hash != PasswordAlgoBcrypt && config.Hash != PasswordAlgoArgon2id {
+			return errUnknownPasswordAlgo
+		}
+		a.hashAlgo = config.Hash
+	}
+	if config.MinPasswordLength > 0 {
+		a.minPasswordLength = config.MinPasswordLength
+	}
+	return nil
+}
+
+// RegisterUser signs up a user; it fails when the email is taken
+func (a *AuthModule) RegisterUser(email, password string, profile map[string]interface{}) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	hash, err := a.hashNewPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.authService.CreateUser(context.Background(), a.projectID, email, hash, profile)
+	if err != nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// Login checks an email and password and issues a token, null when they do
+// not match
+func (a *AuthModule) Login(email, password string) (*AuthLoginResult, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	ctx := context.Background()
+
+	user, err := a.authService.GetUserByEmail(ctx, a.projectID, email)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		// Spend the same time as a wrong password, not to reveal which emails exist
+		verifyPassword(password, a.timingHash())
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !verifyPassword(password, user.PasswordHash) {
+		return nil, nil
+	}
+
+	token, err := a.userToken(user, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.authService.TouchLogin(ctx, user); err != nil {
+		return nil, err
+	}
+	return &AuthLoginResult{Token: token, User: authUserInfo(user)}, nil
+}
+
+// Authenticate returns the user of a token, null when the token is invalid,
+// expired or revoked
+func (a *AuthModule) Authenticate(token string) (*AuthUserInfo, error) {
+	user, _, err := a.authenticate(token)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// IssueToken issues a token for a user without a password, e.g. after signup
+func (a *AuthModule) IssueToken(userID string, options *AuthSignOptions) (string, error) {
+	if a.authService == nil {
+		return "", errAuthStoreUnavailable
+	}
+	user, err := a.findUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", repository.ErrAuthUserNotFound
+	}
+	return a.userToken(user, options)
+}
+
+// Sign signs arbitrary claims as an HS256 JWT with the project secret. They
+// never authenticate a user, so a payload can't pass for a login token.
+func (a *AuthModule) Sign(payload map[string]interface{}, options *AuthSignOptions) (string, error) {
+	if _, ok := payload[authSessionClaim]; ok {
+		return "", errAuthReservedClaim
+	}
+	claims := jwt.MapClaims{}
+	for key, value := range payload {
+		claims[key] = value
+	}
+	if err := a.setExpiry(claims, options); err != nil {
+		return "", err
+	}
+	return a.signClaims(claims)
+}
+
+// Verify returns the claims of a token signed with the project secret, null
+// when the signature is wrong or the token expired
+func (a *AuthModule) Verify(token string) (map[string]interface{}, error) {
+	secret, err := a.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+func (a *AuthModule) GetUser(id string) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	user, err := a.findUser(id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+func (a *AuthModule) FindUser(email string) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	user, err := a.authService.GetUserByEmail(context.Background(), a.projectID, email)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// ListUsers returns a page of users, newest first
+func (a *AuthModule) ListUsers(options *AuthListOptions) (*AuthUserList, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	if options == nil {
+		options = &AuthListOptions{}
+	}
+
+	users, total, err := a.authService.ListUsers(context.Background(), a.projectID, options.Page, options.Limit)
+	if err != nil {
+		return nil, err
+	}
+	list := &AuthUserList{Users: make([]*AuthUserInfo, 0, len(users)), Total: total}
+	for _, user := range users {
+		list.Users = append(list.Users, authUserInfo(user))
+	}
+	return list, nil
+}
+
+// UpdateUser changes the email or replaces the profile of a user
+func (a *AuthModule) UpdateUser(id string, update AuthUserUpdate) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, repository.ErrAuthUserNotFound
+	}
+
+	user, err := a.authService.UpdateUser(context.Background(), a.projectID, oid, update.Email, update.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// SetPassword replaces the password of a user and revokes its tokens
+func (a *AuthModule) SetPassword(id, password string) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, repository.ErrAuthUserNotFound
+	}
+	hash, err := a.hashNewPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.authService.SetPasswordHash(context.Background(), a.projectID, oid, hash)
+	if err != nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// DeleteUser deletes a user, false when there is none
+func (a *AuthModule) DeleteUser(id string) (bool, error) {
+	if a.authService == nil {
+		return false, errAuthStoreUnavailable
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	err = a.authService.DeleteUser(context.Background(), a.projectID, oid)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevokeTokens invalidates every token of a user, e.g. on logout everywhere
+func (a *AuthModule) RevokeTokens(id string) (bool, error) {
+	if a.authService == nil {
+		return false, errAuthStoreUnavailable
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+
+	_, err = a.authService.RevokeTokens(context.Background(), a.projectID, oid)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// HashPassword hashes a password with the configured algorithm, or the given one
+func (a *AuthModule) HashPassword(password, algo string) (string, error) {
+	if algo == "" {
+		a.mu.Lock()
+		algo = a.hashAlgo
+		a.mu.Unlock()
+	}
+	return hashPassword(password, algo)
+}
+
+func (a *AuthModule) VerifyPassword(password, hash string) bool {
+	return verifyPassword(password, hash)
+}
+
+// Middleware returns a $router middleware that authenticates requests by
+// their Bearer token (or cookie) and sets ctx.user and ctx.auth. Requests
+// without a valid token get 401 unless the middleware is optional.
+func (a *AuthModule) Middleware(options *AuthMiddlewareOptions) func(goja.FunctionCall) goja.Value {
+	if options == nil {
+		options = &AuthMiddlewareOptions{}
+	}
+	opts := *options
+
+	return func(call goja.FunctionCall) goja.Value {
+		ctx := call.Argument(0).ToObject(a.vm)
+
+		user, claims, err := a.authenticate(requestToken(a.vm, ctx, opts.Cookie))
+		if err != nil {
+			authRespond(a.vm, ctx, 500, "authentication failed")
+			return a.vm.ToValue(false)
+		}
+		if user == nil {
+			if opts.Optional {
+				return goja.Undefined()
+			}
+			authRespond(a.vm, ctx, 401, "unauthorized")
+			return a.vm.ToValue(false)
+		}
+
+		ctx.Set("user", authUserInfo(user))
+		ctx.Set("auth", claims)
+		return goja.Undefined()
+	}
+}
+
+// CreateResetToken issues a password reset token for an email, null when
+// there is no such user. Prefer requestPasswordReset, which mails it.
+func (a *AuthModule) CreateResetToken(email string, options *AuthResetOptions) (interface{}, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	ctx := context.Background()
+
+	user, err := a.authService.GetUserByEmail(ctx, a.projectID, email)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := authDefaultResetTTL
+	if options != nil && options.ExpiresIn != nil {
+		if ttl, err = parseAuthDuration(options.ExpiresIn); err != nil {
+			return nil, err
+		}
+	}
+	return a.authService.CreateResetToken(ctx, user, ttl)
+}
+
+// RequestPasswordReset mails a reset link to a user through $mail. Unknown
+// emails succeed without sending anything, not to reveal which emails exist.
+func (a *AuthModule) RequestPasswordReset(email string, options *AuthResetOptions) (*MailResult, error) {
+	if options == nil {
+		options = &AuthResetOptions{}
+	}
+	token, err := a.CreateResetToken(email, options)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return &MailResult{Success: true}, nil
+	}
+	if a.mailModule == nil {
+		return &MailResult{Success: false, Error: "$mail is not available"}, nil
+	}
+
+	url := token.(string)
+	if options.URL != "" {
+		url = strings.ReplaceAll(options.URL, "{token}", token.(string))
+	}
+	subject := options.Subject
+	if subject == "" {
+		subject = "Reset your password"
+	}
+	text, html := options.Text, options.HTML
+	if text == "" && html == "" {
+		text = "Use this link to reset your password:\n\n{url}\n\nIf you did not ask for it, ignore this email."
+	}
+	replacer := strings.NewReplacer("{url}", url, "{token}", token.(string))
+
+	return a.mailModule.sendEmail(strings.TrimSpace(email), subject, replacer.Replace(text), replacer.Replace(html), nil), nil
+}
+
+// ResetPassword spends a reset token to set a new password, revoking the
+// tokens of the user. It returns null when the token is invalid or expired.
+func (a *AuthModule) ResetPassword(token, password string) (*AuthUserInfo, error) {
+	if a.authService == nil {
+		return nil, errAuthStoreUnavailable
+	}
+	hash, err := a.hashNewPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+
+	user, err := a.authService.ConsumeResetToken(ctx, a.projectID, token)
+	if errors.Is(err, repository.ErrAuthResetTokenUsed) || errors.Is(err, repository.ErrAuthUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = a.authService.SetPasswordHash(ctx, a.projectID, user.ID, hash)
+	if err != nil {
+		return nil, err
+	}
+	return authUserInfo(user), nil
+}
+
+// authenticate returns the user of a token and its claims, nil when the
+// token is invalid or was issued before the tokens of the user were revoked
+func (a *AuthModule) authenticate(token string) (*domain.AuthUser, map[string]interface{}, error) {
+	if token == "" {
+		return nil, nil, nil
+	}
+	claims, err := a.Verify(token)
+	if err != nil || claims == nil {
+		return nil, nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	version, ok := claims["ver"].(float64)
+	if sub == "" || !ok || claims[authSessionClaim] != authSessionType {
+		return nil, nil, nil
+	}
+	if a.authService == nil {
+		return nil, nil, errAuthStoreUnavailable
+	}
+
+	user, err := a.findUser(sub)
+	if err != nil || user == nil || int(version) != user.TokenVersion {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+func (a *AuthModule) findUser(id string) (*domain.AuthUser, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := a.authService.GetUser(context.Background(), a.projectID, oid)
+	if errors.Is(err, repository.ErrAuthUserNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (a *AuthModule) userToken(user *domain.AuthUser, options *AuthSignOptions) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":            user.ID.Hex(),
+		"email":          user.Email,
+		"ver":            user.TokenVersion,
+		authSessionClaim: authSessionType,
+	}
+	if err := a.setExpiry(claims, options); err != nil {
+		return "", err
+	}
+	return a.signClaims(claims)
+}
+
+func (a *AuthModule) setExpiry(claims jwt.MapClaims, options *AuthSignOptions) error {
+	a.mu.Lock()
+	expiresIn := a.expiresIn
+	a.mu.Unlock()
+	if options != nil && options.ExpiresIn != nil {
+		d, err := parseAuthDuration(options.ExpiresIn)
+		if err != nil {
+			return err
+		}
+		expiresIn = d
+	}
+
+	now := time.Now()
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = now.Unix()
+	}
+	if _, ok := claims["exp"]; !ok && expiresIn > 0 {
+		claims["exp"] = now.Add(expiresIn).Unix()
+	}
+	return nil
+}
+
+func (a *AuthModule) signClaims(claims jwt.MapClaims) (string, error) {
+	secret, err := a.secret()
+	if err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// secret returns the AUTH_SECRET env var, or the secret generated for the
+// project when it is not set
+func (a *AuthModule) secret() ([]byte, error) {
+	if a.envModule != nil {
+		if secret := a.envModule.GetString("AUTH_SECRET", ""); secret != "" {
+			return []byte(secret), nil
+		}
+	}
+	if a.authService == nil {
+		return nil, errAuthNoSecret
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.projectSecret == nil {
+		secret, err := a.authService.Secret(context.Background(), a.projectID)
+		if err != nil {
+			return nil, err
+		}
+		a.projectSecret = []byte(secret)
+	}
+	return a.projectSecret, nil
+}
+
+func (a *AuthModule) hashNewPassword(password string) (string, error) {
+	a.mu.Lock()
+	algo, minLength := a.hashAlgo, a.minPasswordLength
+	a.mu.Unlock()
+
+	if len([]rune(password)) < minLength {
+		return "", fmt.Errorf("password must be at least %d characters", minLength)
+	}
+	return hashPassword(password, algo)
+}
+
+// timingHash is a hash of no password, checked against for unknown emails
+func (a *AuthModule) timingHash() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.dummyHash == "" {
+		a.dummyHash, _ = hashPassword("", a.hashAlgo)
+	}
+	return a.dummyHash
+}
+
+func authUserInfo(user *domain.AuthUser) *AuthUserInfo {
+	info := &AuthUserInfo{
+		ID:        user.ID.Hex(),
+		Email:     user.Email,
+		Profile:   user.Profile,
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: user.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if info.Profile == nil {
+		info.Profile = make(map[string]interface{})
+	}
+	if user.LastLoginAt != nil {
+		info.LastLoginAt = user.LastLoginAt.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// requestToken reads the Bearer token of a request, or its cookie
+func requestToken(vm *goja.Runtime, ctx *goja.Object, cookie string) string {
+	header := objectString(vm, ctx.Get("headers"), "Authorization")
+	if header == "" {
+		header = objectString(vm, ctx.Get("headers"), "authorization")
+	}
+	if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if cookie != "" {
+		return objectString(vm, ctx.Get("cookies"), cookie)
+	}
+	return ""
+}
+
+func objectString(vm *goja.Runtime, value goja.Value, key string) string {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return ""
+	}
+	field := value.ToObject(vm).Get(key)
+	if field == nil || goja.IsUndefined(field) || goja.IsNull(field) {
+		return ""
+	}
+	return field.String()
+}
+
+// authRespond answers a request from a middleware through ctx.response
+func authRespond(vm *goja.Runtime, ctx *goja.Object, status int, message string) {
+	if response, ok := goja.AssertFunction(ctx.Get("response")); ok {
+		response(ctx, vm.ToValue(status), vm.ToValue(map[string]interface{}{"error": message}))
+	}
+}
+
+// parseAuthDuration reads a duration given as seconds or as a string like
+// "90s", "15m", "12h" or "7d"
+func parseAuthDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if days, ok := strings.CutSuffix(v, "d"); ok {
+			n, err := strconv.ParseFloat(days, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", v)
+			}
+			return time.Duration(n * float64(24*time.Hour)), nil
+		}
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(n * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf("invalid duration %v", value)
+	}
+}
+
+// GetSchema implements JSSchemaProvider
+func (a *AuthModule) GetSchema() schema.ModuleSchema {
+	return schema.ModuleSchema{
+		Name:        "$auth",
+		Description: "End-user authentication: per-project users with hashed passwords, JWTs signed with the project secret (or AUTH_SECRET), router middleware and password resets via $mail",
+		Types: []schema.TypeSchema{
+			{
+				Name:        "AuthUser",
+				Description: "A user of the project, without its password",
+				Fields: []schema.ParamSchema{
+					{Name: "id", Type: "string", Description: "User ID"},
+					{Name: "email", Type: "string", Description: "Email, lowercased"},
+					{Name: "profile", Type: "{ [key: string]: any }", Description: "Custom profile data"},
+					{Name: "lastLoginAt", Type: "string", Description: "Last login (RFC 3339)", Optional: true},
+					{Name: "createdAt", Type: "string", Description: "Creation time (RFC 3339)"},
+					{Name: "updatedAt", Type: "string", Description: "Last update (RFC 3339)"},
+				},
+			},
+			{
+				Name:        "AuthConfig",
+				Description: "Settings of $auth.configure",
+				Fields: []schema.ParamSchema{
+					{Name: "expiresIn", Type: "number | string", Description: "Token lifetime in seconds or like '15m', '7d' (default 1d, 0 never expires)", Optional: true},
+					{Name: "hash", Type: "'bcrypt' | 'argon2id'", Description: "Password hash algorithm (default bcrypt)", Optional: true},
+					{Name: "minPasswordLength", Type: "number", Description: "Minimum password length (default 8)", Optional: true},
+				},
+			},
+			{
+				Name:        "AuthSignOptions",
+				Description: "Token signing options",
+				Fields: []schema.ParamSchema{
+					{Name: "expiresIn", Type: "number | string", Description: "Token lifetime, overrides the configured one", Optional: true},
+				},
+			},
+			{
+				Name:        "AuthLoginResult",
+				Description: "A successful login",
+				Fields: []schema.ParamSchema{
+					{Name: "token", Type: "string", Description: "JWT to send as Authorization: Bearer"},
+					{Name: "user", Type: "AuthUser", Description: "Logged in user"},
+				},
+			},
+			{
+				Name:        "AuthUserList",
+				Description: "A page of users",
+				Fields: []schema.ParamSchema{
+					{Name: "users", Type: "AuthUser[]", Description: "Users, newest first"},
+					{Name: "total", Type: "number", Description: "Total number of users"},
+				},
+			},
+			{
+				Name:        "AuthMiddlewareOptions",
+				Description: "Options of $auth.middleware",
+				Fields: []schema.ParamSchema{
+					{Name: "optional", Type: "boolean", Description: "Let requests without a valid token through, without ctx.user", Optional: true},
+					{Name: "cookie", Type: "string", Description: "Also read the token from this cookie", Optional: true},
+				},
+			},
+			{
+				Name:        "AuthResetOptions",
+				Description: "Password reset email options",
+				Fields: []schema.ParamSchema{
+					{Name: "url", Type: "string", Description: "Link to the reset page, {token} is replaced by the token", Optional: true},
+					{Name: "subject", Type: "string", Description: "Email subject", Optional: true},
+					{Name: "text", Type: "string", Description: "Plain text body, {url} and {token} are replaced", Optional: true},
+					{Name: "html", Type: "string", Description: "HTML body, {url} and {token} are replaced", Optional: true},
+					{Name: "expiresIn", Type: "number | string", Description: "Token lifetime (default 1h)", Optional: true},
+				},
+			},
+		},
+		Methods: []schema.MethodSchema{
+			{
+				Name:        "configure",
+				Description: "Change the token lifetime, hash algorithm or minimum password length",
+				Params:      []schema.ParamSchema{{Name: "config", Type: "AuthConfig", Description: "Settings"}},
+			},
+			{
+				Name:        "register",
+				Description: "Sign up a user, throws when the email is taken",
+				Params: []schema.ParamSchema{
+					{Name: "email", Type: "string", Description: "Email"},
+					{Name: "password", Type: "string", Description: "Password"},
+					{Name: "profile", Type: "{ [key: string]: any }", Description: "Custom profile data", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "AuthUser"},
+			},
+			{
+				Name:        "login",
+				Description: "Check an email and password and issue a token",
+				Params: []schema.ParamSchema{
+					{Name: "email", Type: "string", Description: "Email"},
+					{Name: "password", Type: "string", Description: "Password"},
+				},
+				Returns: &schema.ParamSchema{Type: "AuthLoginResult | null"},
+			},
+			{
+				Name:        "authenticate",
+				Description: "Get the user of a token, null when it is invalid, expired or revoked",
+				Params:      []schema.ParamSchema{{Name: "token", Type: "string", Description: "JWT"}},
+				Returns:     &schema.ParamSchema{Type: "AuthUser | null"},
+			},
+			{
+				Name:        "issueToken",
+				Description: "Issue a token for a user without a password, e.g. after signup",
+				Params: []schema.ParamSchema{
+					{Name: "userId", Type: "string", Description: "User ID"},
+					{Name: "options", Type: "AuthSignOptions", Description: "Signing options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "sign",
+				Description: "Sign custom claims as an HS256 JWT with the project secret. The typ claim is reserved: signed tokens never authenticate a user",
+				Params: []schema.ParamSchema{
+					{Name: "payload", Type: "{ [key: string]: any }", Description: "Claims"},
+					{Name: "options", Type: "AuthSignOptions", Description: "Signing options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "verify",
+				Description: "Get the claims of a token signed with the project secret, null when invalid or expired",
+				Params:      []schema.ParamSchema{{Name: "token", Type: "string", Description: "JWT"}},
+				Returns:     &schema.ParamSchema{Type: "{ [key: string]: any } | null"},
+			},
+			{
+				Name:        "getUser",
+				Description: "Get a user by ID",
+				Params:      []schema.ParamSchema{{Name: "id", Type: "string", Description: "User ID"}},
+				Returns:     &schema.ParamSchema{Type: "AuthUser | null"},
+			},
+			{
+				Name:        "findUser",
+				Description: "Get a user by email",
+				Params:      []schema.ParamSchema{{Name: "email", Type: "string", Description: "Email"}},
+				Returns:     &schema.ParamSchema{Type: "AuthUser | null"},
+			},
+			{
+				Name:        "listUsers",
+				Description: "List users, newest first",
+				Params:      []schema.ParamSchema{{Name: "options", Type: "{ page?: number, limit?: number }", Description: "Paging (default page 1, limit 50)", Optional: true}},
+				Returns:     &schema.ParamSchema{Type: "AuthUserList"},
+			},
+			{
+				Name:        "updateUser",
+				Description: "Change the email or replace the profile of a user",
+				Params: []schema.ParamSchema{
+					{Name: "id", Type: "string", Description: "User ID"},
+					{Name: "update", Type: "{ email?: string, profile?: { [key: string]: any } }", Description: "Changes"},
+				},
+				Returns: &schema.ParamSchema{Type: "AuthUser"},
+			},
+			{
+				Name:        "setPassword",
+				Description: "Replace the password of a user and revoke its tokens",
+				Params: []schema.ParamSchema{
+					{Name: "id", Type: "string", Description: "User ID"},
+					{Name: "password", Type: "string", Description: "New password"},
+				},
+				Returns: &schema.ParamSchema{Type: "AuthUser"},
+			},
+			{
+				Name:        "deleteUser",
+				Description: "Delete a user",
+				Params:      []schema.ParamSchema{{Name: "id", Type: "string", Description: "User ID"}},
+				Returns:     &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "revokeTokens",
+				Description: "Invalidate every token issued to a user",
+				Params:      []schema.ParamSchema{{Name: "id", Type: "string", Description: "User ID"}},
+				Returns:     &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "hashPassword",
+				Description: "Hash a password",
+				Params: []schema.ParamSchema{
+					{Name: "password", Type: "string", Description: "Password"},
+					{Name: "algo", Type: "'bcrypt' | 'argon2id'", Description: "Algorithm (default the configured one)", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "verifyPassword",
+				Description: "Check a password against a bcrypt or argon2id hash",
+				Params: []schema.ParamSchema{
+					{Name: "password", Type: "string", Description: "Password"},
+					{Name: "hash", Type: "string", Description: "Hash"},
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "middleware",
+				Description: "Router middleware that authenticates requests by Bearer token and sets ctx.user and ctx.auth, answering 401 otherwise",
+				Params:      []schema.ParamSchema{{Name: "options", Type: "AuthMiddlewareOptions", Description: "Middleware options", Optional: true}},
+				Returns:     &schema.ParamSchema{Type: "(ctx: RequestContext) => boolean | void"},
+			},
+			{
+				Name:        "createResetToken",
+				Description: "Issue a single-use password reset token, null for unknown emails",
+				Params: []schema.ParamSchema{
+					{Name: "email", Type: "string", Description: "Email"},
+					{Name: "options", Type: "{ expiresIn?: number | string }", Description: "Token lifetime (default 1h)", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string | null"},
+			},
+			{
+				Name:        "requestPasswordReset",
+				Description: "Mail a password reset link via $mail; unknown emails succeed without sending",
+				Params: []schema.ParamSchema{
+					{Name: "email", Type: "string", Description: "Email"},
+					{Name: "options", Type: "AuthResetOptions", Description: "Email options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "MailResult"},
+			},
+			{
+				Name:        "resetPassword",
+				Description: "Set a new password with a reset token and revoke the user's tokens, null when the token is invalid or expired",
+				Params: []schema.ParamSchema{
+					{Name: "token", Type: "string", Description: "Reset token"},
+					{Name: "password", Type: "string", Description: "New password"},
+				},
+				Returns: &schema.ParamSchema{Type: "AuthUser | null"},
+			},
+		},
+	}
+}
+
+// GetAuthSchema returns the auth schema (static version)
+func GetAuthSchema() schema.ModuleSchema {
+	return (&AuthModule{}).GetSchema()
+}
@@ -1,15 +1,111 @@
 package modules
 
 import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/levskiy0/m3m/pkg/schema"
 )
 
+// Binary data crosses into JS as strings in one of these encodings
+const (
+	EncodingUTF8      = "utf8"
+	EncodingHex       = "hex"
+	EncodingBase64    = "base64"
+	EncodingBase64URL = "base64url"
+)
+
+var errDecryptFailed = errors.New("decryption failed: wrong key or corrupted data")
+
+// CryptoOptions selects the encodings of data, keys and results. Strings
+// are UTF-8 unless told otherwise; ArrayBuffers and Uint8Arrays are raw bytes.
+type CryptoOptions struct {
+	Encoding      string `json:"encoding"`      // Of the result
+	InputEncoding string `json:"inputEncoding"` // Of string data
+	KeyEncoding   string `json:"keyEncoding"`   // Of string keys
+}
+
+// CryptoCipherOptions configures $crypto.encrypt and $crypto.decrypt
+type CryptoCipherOptions struct {
+	Encoding      string `json:"encoding"`
+	InputEncoding string `json:"inputEncoding"`
+	KeyEncoding   string `json:"keyEncoding"`
+	AAD           string `json:"aad"` // Additional authenticated data, UTF-8
+}
+
+// CryptoSignOptions configures $crypto.sign and $crypto.verify
+type CryptoSignOptions struct {
+	Encoding          string `json:"encoding"`
+	InputEncoding     string `json:"inputEncoding"`
+	KeyEncoding       string `json:"keyEncoding"`
+	Hash              string `json:"hash"`              // RSA digest: sha256 (default), sha384 or sha512
+	Padding           string `json:"padding"`           // RSA padding: pkcs1 (default) or pss
+	SignatureEncoding string `json:"signatureEncoding"` // Of the signature to verify, base64 by default
+}
+
+// CryptoKeyPairOptions configures $crypto.generateKeyPair
+type CryptoKeyPairOptions struct {
+	Bits int `json:"bits"` // RSA key size, 2048 by default
+}
+
+// CryptoKeyPair is a PEM encoded key pair: PKIX public and PKCS #8 private
+type CryptoKeyPair struct {
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// CryptoJWTOptions configures $crypto.jwtSign and $crypto.jwtVerify
+type CryptoJWTOptions struct {
+	Alg         string                 `json:"alg"`        // Signing algorithm, HS256 by default
+	Algorithms  []string               `json:"algorithms"` // Accepted when verifying, by key type by default
+	ExpiresIn   interface{}            `json:"expiresIn"`  // Seconds or a duration like "15m", "7d"
+	Header      map[string]interface{} `json:"header"`     // Extra header fields, e.g. kid
+	Issuer      string                 `json:"issuer"`
+	Audience    string                 `json:"audience"`
+	Leeway      int                    `json:"leeway"` // Seconds of clock skew tolerated
+	KeyEncoding string                 `json:"keyEncoding"`
+}
+
+// CryptoJWSOptions configures $crypto.jwsSign and $crypto.jwsVerify
+type CryptoJWSOptions struct {
+	Encoding      string                 `json:"encoding"`
+	InputEncoding string                 `json:"inputEncoding"`
+	KeyEncoding   string                 `json:"keyEncoding"`
+	Alg           string                 `json:"alg"`
+	Algorithms    []string               `json:"algorithms"`
+	Header        map[string]interface{} `json:"header"`
+}
+
+// CryptoDecodedJWT is a token read without verifying it
+type CryptoDecodedJWT struct {
+	Header  map[string]interface{} `json:"header"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
 type CryptoModule struct{}
 
 func NewCryptoModule() *CryptoModule {
@@ -24,9 +120,26 @@ func (c *CryptoModule) Name() string {
 // Register registers the module into the JavaScript VM
 func (c *CryptoModule) Register(vm interface{}) {
 	vm.(*goja.Runtime).Set(c.Name(), map[string]interface{}{
-		"md5":         c.MD5,
-		"sha256":      c.SHA256,
-		"randomBytes": c.RandomBytes,
+		"md5":             c.MD5,
+		"sha1":            c.SHA1,
+		"sha256":          c.SHA256,
+		"sha512":          c.SHA512,
+		"hash":            c.Hash,
+		"hmac":            c.HMAC,
+		"randomBytes":     c.RandomBytes,
+		"timingSafeEqual": c.TimingSafeEqual,
+		"encrypt":         c.Encrypt,
+		"decrypt":         c.Decrypt,
+		"generateKeyPair": c.GenerateKeyPair,
+		"sign":            c.Sign,
+		"verify":          c.Verify,
+		"hashPassword":    c.HashPassword,
+		"verifyPassword":  c.VerifyPassword,
+		"jwtSign":         c.JWTSign,
+		"jwtVerify":       c.JWTVerify,
+		"jwtDecode":       c.JWTDecode,
+		"jwsSign":         c.JWSSign,
+		"jwsVerify":       c.JWSVerify,
 	})
 }
 
@@ -35,25 +148,714 @@ func (c *CryptoModule) MD5(data string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+func (c *CryptoModule) SHA1(data string) string {
+	hash := sha1.Sum([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
 func (c *CryptoModule) SHA256(data string) string {
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
-func (c *CryptoModule) RandomBytes(length int) string {
+func (c *CryptoModule) SHA512(data string) string {
+	hash := sha512.Sum512([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// Hash digests data with md5, sha1, sha256, sha384 or sha512, hex by default
+func (c *CryptoModule) Hash(algo string, data goja.Value, options *CryptoOptions) (string, error) {
+	opts := cryptoOptions(options)
+	newHash, err := hashFunc(algo)
+	if err != nil {
+		return "", err
+	}
+	input, err := cryptoBytes(data, opts.InputEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	h := newHash()
+	h.Write(input)
+	return encodeBytes(h.Sum(nil), opts.Encoding, EncodingHex)
+}
+
+// HMAC authenticates data with a key, hex by default
+func (c *CryptoModule) HMAC(algo string, key, data goja.Value, options *CryptoOptions) (string, error) {
+	opts := cryptoOptions(options)
+	newHash, err := hashFunc(algo)
+	if err != nil {
+		return "", err
+	}
+	keyBytes, err := cryptoBytes(key, opts.KeyEncoding)
+	if err != nil {
+		return "", fmt.Errorf("key: %w", err)
+	}
+	input, err := cryptoBytes(data, opts.InputEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(newHash, keyBytes)
+	mac.Write(input)
+	return encodeBytes(mac.Sum(nil), opts.Encoding, EncodingHex)
+}
+
+// RandomBytes returns cryptographically secure random bytes, hex by default
+func (c *CryptoModule) RandomBytes(length int, encoding string) (string, error) {
 	if length <= 0 {
-		return ""
+		return "", nil
 	}
 	bytes := make([]byte, length)
 	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
+	return encodeBytes(bytes, encoding, EncodingHex)
+}
+
+// TimingSafeEqual compares two values in constant time, for checking
+// signatures and tokens without leaking where they differ
+func (c *CryptoModule) TimingSafeEqual(a, b goja.Value, options *CryptoOptions) (bool, error) {
+	opts := cryptoOptions(options)
+	x, err := cryptoBytes(a, opts.InputEncoding)
+	if err != nil {
+		return false, err
+	}
+	y, err := cryptoBytes(b, opts.InputEncoding)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(x, y) == 1, nil
+}
+
+// Encrypt seals data with AES-GCM under a 16, 24 or 32 byte key. The result
+// is the random nonce, the ciphertext and the tag, base64 by default.
+func (c *CryptoModule) Encrypt(key, data goja.Value, options *CryptoCipherOptions) (string, error) {
+	if options == nil {
+		options = &CryptoCipherOptions{}
+	}
+	aead, err := aesGCM(key, options.KeyEncoding)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := cryptoBytes(data, options.InputEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(options.AAD))
+	return encodeBytes(sealed, options.Encoding, EncodingBase64)
+}
+
+// Decrypt opens data sealed by Encrypt. The data is base64 and the result
+// UTF-8 by default; it throws when the key is wrong or the data was altered.
+func (c *CryptoModule) Decrypt(key, data goja.Value, options *CryptoCipherOptions) (string, error) {
+	if options == nil {
+		options = &CryptoCipherOptions{}
+	}
+	aead, err := aesGCM(key, options.KeyEncoding)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := cryptoBytes(data, defaultEncoding(options.InputEncoding, EncodingBase64))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", errDecryptFailed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(options.AAD))
+	if err != nil {
+		return "", errDecryptFailed
+	}
+	return encodeBytes(plaintext, options.Encoding, EncodingUTF8)
+}
+
+// GenerateKeyPair creates an ed25519 or rsa key pair
+func (c *CryptoModule) GenerateKeyPair(keyType string, options *CryptoKeyPairOptions) (*CryptoKeyPair, error) {
+	var public, private any
+	switch strings.ToLower(keyType) {
+	case "ed25519":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		public, private = pub, priv
+	case "rsa":
+		bits := 2048
+		if options != nil && options.Bits > 0 {
+			bits = options.Bits
+		}
+		if bits < 2048 || bits > 8192 {
+			return nil, errors.New("RSA keys must have 2048 to 8192 bits")
+		}
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		public, private = &priv.PublicKey, priv
+	default:
+		return nil, fmt.Errorf("unknown key type %q, expected ed25519 or rsa", keyType)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return &CryptoKeyPair{
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+	}, nil
+}
+
+// Sign signs data with a PEM private key, Ed25519 or RSA. The signature is
+// base64 by default.
+func (c *CryptoModule) Sign(privateKey string, data goja.Value, options *CryptoSignOptions) (string, error) {
+	if options == nil {
+		options = &CryptoSignOptions{}
+	}
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	message, err := cryptoBytes(data, options.InputEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	var signature []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, message)
+	case *rsa.PrivateKey:
+		hashType, digest, err := rsaDigest(options.Hash, message)
+		if err != nil {
+			return "", err
+		}
+		if options.Padding == "pss" {
+			signature, err = rsa.SignPSS(rand.Reader, k, hashType, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hashType, digest)
+		}
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.New("only Ed25519 and RSA keys can sign")
+	}
+	return encodeBytes(signature, options.Encoding, EncodingBase64)
+}
+
+// Verify checks a signature of data with a PEM public key (or certificate)
+func (c *CryptoModule) Verify(publicKey string, data, signature goja.Value, options *CryptoSignOptions) (bool, error) {
+	if options == nil {
+		options = &CryptoSignOptions{}
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return false, err
+	}
+	message, err := cryptoBytes(data, options.InputEncoding)
+	if err != nil {
+		return false, err
+	}
+	sig, err := cryptoBytes(signature, defaultEncoding(options.SignatureEncoding, EncodingBase64))
+	if err != nil {
+		return false, nil
+	}
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, sig), nil
+	case *rsa.PublicKey:
+		hashType, digest, err := rsaDigest(options.Hash, message)
+		if err != nil {
+			return false, err
+		}
+		if options.Padding == "pss" {
+			return rsa.VerifyPSS(k, hashType, digest, sig, nil) == nil, nil
+		}
+		return rsa.VerifyPKCS1v15(k, hashType, digest, sig) == nil, nil
+	default:
+		return false, errors.New("only Ed25519 and RSA keys can verify")
+	}
+}
+
+// HashPassword hashes a password with bcrypt (default) or argon2id
+func (c *CryptoModule) HashPassword(password, algo string) (string, error) {
+	return hashPassword(password, algo)
+}
+
+// VerifyPassword checks a password against a bcrypt or argon2id hash
+func (c *CryptoModule) VerifyPassword(password, hash string) bool {
+	return verifyPassword(password, hash)
+}
+
+// JWTSign signs claims as a JWT. HS* algorithms take a secret, the others a
+// PEM private key.
+func (c *CryptoModule) JWTSign(payload map[string]interface{}, key string, options *CryptoJWTOptions) (string, error) {
+	if options == nil {
+		options = &CryptoJWTOptions{}
+	}
+	method, signingKey, err := jwsSigner(defaultEncoding(options.Alg, jwt.SigningMethodHS256.Alg()), key, options.KeyEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{}
+	for k, v := range payload {
+		claims[k] = v
+	}
+	now := time.Now()
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = now.Unix()
+	}
+	if options.ExpiresIn != nil {
+		d, err := parseAuthDuration(options.ExpiresIn)
+		if err != nil {
+			return "", err
+		}
+		claims["exp"] = now.Add(d).Unix()
+	}
+	if options.Issuer != "" {
+		claims["iss"] = options.Issuer
+	}
+	if options.Audience != "" {
+		claims["aud"] = options.Audience
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	for k, v := range options.Header {
+		if k != "alg" {
+			token.Header[k] = v
+		}
+	}
+	return token.SignedString(signingKey)
+}
+
+// JWTVerify returns the claims of a valid JWT, null when its signature,
+// expiry, issuer or audience is wrong. Only the algorithms fitting the key
+// are accepted unless options.algorithms says otherwise.
+func (c *CryptoModule) JWTVerify(token, key string, options *CryptoJWTOptions) (map[string]interface{}, error) {
+	if options == nil {
+		options = &CryptoJWTOptions{}
+	}
+	algorithms, err := jwsAlgorithms(key, options.Algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	parserOptions := []jwt.ParserOption{jwt.WithValidMethods(algorithms), jwt.WithLeeway(time.Duration(options.Leeway) * time.Second)}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return jwsVerifier(t.Method.Alg(), key, options.KeyEncoding)
+	}, parserOptions...)
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// JWTDecode reads the header and payload of a JWT without verifying it,
+// null when it is malformed
+func (c *CryptoModule) JWTDecode(token string) *CryptoDecodedJWT {
+	claims := jwt.MapClaims{}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	if err != nil {
+		return nil
+	}
+	return &CryptoDecodedJWT{Header: parsed.Header, Payload: claims}
+}
+
+// JWSSign signs arbitrary data as a compact JWS
+func (c *CryptoModule) JWSSign(payload goja.Value, key string, options *CryptoJWSOptions) (string, error) {
+	if options == nil {
+		options = &CryptoJWSOptions{}
+	}
+	alg := defaultEncoding(options.Alg, jwt.SigningMethodHS256.Alg())
+	method, signingKey, err := jwsSigner(alg, key, options.KeyEncoding)
+	if err != nil {
+		return "", err
+	}
+	data, err := cryptoBytes(payload, options.InputEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]interface{}{}
+	for k, v := range options.Header {
+		header[k] = v
+	}
+	header["alg"] = alg
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	signingString := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(data)
+	signature, err := method.Sign(signingString, signingKey)
+	if err != nil {
+		return "", err
+	}
+	return signingString + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWSVerify returns the payload of a valid compact JWS, UTF-8 by default,
+// null when the signature is wrong
+func (c *CryptoModule) JWSVerify(token, key string, options *CryptoJWSOptions) (interface{}, error) {
+	if options == nil {
+		options = &CryptoJWSOptions{}
+	}
+	algorithms, err := jwsAlgorithms(key, options.Algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(headerJSON, &header) != nil || !slices.Contains(algorithms, header.Alg) {
+		return nil, nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil
+	}
+
+	verifyKey, err := jwsVerifier(header.Alg, key, options.KeyEncoding)
+	if err != nil {
+		return nil, nil
+	}
+	if jwt.GetSigningMethod(header.Alg).Verify(parts[0]+"."+parts[1], signature, verifyKey) != nil {
+		return nil, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil
+	}
+	return encodeBytes(payload, options.Encoding, EncodingUTF8)
+}
+
+func cryptoOptions(options *CryptoOptions) CryptoOptions {
+	if options == nil {
+		return CryptoOptions{}
+	}
+	return *options
+}
+
+func defaultEncoding(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// cryptoBytes reads data from JS: a string in the given encoding (UTF-8 by
+// default), an ArrayBuffer or a Uint8Array
+func cryptoBytes(value goja.Value, encoding string) ([]byte, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, errors.New("data is required")
+	}
+	switch v := value.Export().(type) {
+	case goja.ArrayBuffer:
+		return v.Bytes(), nil
+	case []byte:
+		return v, nil
+	case string:
+		return decodeString(v, encoding)
+	default:
+		return []byte(value.String()), nil
+	}
+}
+
+func decodeString(s, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", EncodingUTF8:
+		return []byte(s), nil
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	default:
+		return nil, fmt.Errorf("unknown encoding %q, expected utf8, hex, base64 or base64url", encoding)
+	}
+}
+
+func encodeBytes(b []byte, encoding, fallback string) (string, error) {
+	switch defaultEncoding(encoding, fallback) {
+	case EncodingUTF8:
+		return string(b), nil
+	case EncodingHex:
+		return hex.EncodeToString(b), nil
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(b), nil
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("unknown encoding %q, expected utf8, hex, base64 or base64url", encoding)
+	}
+}
+
+func hashFunc(algo string) (func() hash.Hash, error) {
+	switch strings.ToLower(strings.ReplaceAll(algo, "-", "")) {
+	case "md5":
+		return md5.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha384":
+		return sha512.New384, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unknown hash %q, expected md5, sha1, sha256, sha384 or sha512", algo)
+	}
+}
+
+func rsaDigest(algo string, message []byte) (crypto.Hash, []byte, error) {
+	var hashType crypto.Hash
+	switch defaultEncoding(algo, "sha256") {
+	case "sha256":
+		hashType = crypto.SHA256
+	case "sha384":
+		hashType = crypto.SHA384
+	case "sha512":
+		hashType = crypto.SHA512
+	default:
+		return 0, nil, fmt.Errorf("unknown RSA hash %q, expected sha256, sha384 or sha512", algo)
+	}
+	h := hashType.New()
+	h.Write(message)
+	return hashType, h.Sum(nil), nil
+}
+
+func aesGCM(key goja.Value, encoding string) (cipher.AEAD, error) {
+	keyBytes, err := cryptoBytes(key, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("AES keys must be 16, 24 or 32 bytes, got %d (set keyEncoding for hex or base64 keys)", len(keyBytes))
+	}
+	return cipher.NewGCM(block)
+}
+
+func pemBlock(key string) (*pem.Block, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(key)))
+	if block == nil {
+		return nil, errors.New("key is not PEM encoded")
+	}
+	return block, nil
+}
+
+// parsePrivateKey reads a PKCS #8, PKCS #1 or SEC 1 private key
+func parsePrivateKey(key string) (crypto.Signer, error) {
+	block, err := pemBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := k.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("unsupported private key")
+}
+
+// parsePublicKey reads a PKIX or PKCS #1 public key, a certificate, or the
+// public half of a private key
+func parsePublicKey(key string) (crypto.PublicKey, error) {
+	block, err := pemBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+	if signer, err := parsePrivateKey(key); err == nil {
+		return signer.Public(), nil
+	}
+	return nil, errors.New("unsupported public key")
+}
+
+// jwsSigner returns the signing method of an algorithm and the key to sign with
+func jwsSigner(alg, key, keyEncoding string) (jwt.SigningMethod, interface{}, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || alg == "none" {
+		return nil, nil, fmt.Errorf("unknown algorithm %q", alg)
+	}
+	if strings.HasPrefix(alg, "HS") {
+		secret, err := decodeString(key, keyEncoding)
+		return method, secret, err
+	}
+	signer, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return method, signer, nil
+}
+
+// jwsVerifier returns the key to verify an algorithm with
+func jwsVerifier(alg, key, keyEncoding string) (interface{}, error) {
+	if strings.HasPrefix(alg, "HS") {
+		return decodeString(key, keyEncoding)
+	}
+	return parsePublicKey(key)
+}
+
+// jwsAlgorithms returns the algorithms a key may verify: HMAC for secrets,
+// the family of the key for PEM keys. Never mixing the two stops tokens
+// signed with a public key as an HMAC secret.
+func jwsAlgorithms(key string, requested []string) ([]string, error) {
+	var allowed []string
+	if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+		allowed = []string{"HS256", "HS384", "HS512"}
+	} else {
+		public, err := parsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		switch public.(type) {
+		case *rsa.PublicKey:
+			allowed = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+		case *ecdsa.PublicKey:
+			allowed = []string{"ES256", "ES384", "ES512"}
+		case ed25519.PublicKey:
+			allowed = []string{"EdDSA"}
+		default:
+			return nil, errors.New("unsupported public key")
+		}
+	}
+
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+	for _, alg := range requested {
+		if !slices.Contains(allowed, alg) {
+			return nil, fmt.Errorf("algorithm %s does not fit the key", alg)
+		}
+	}
+	return requested, nil
 }
 
 // GetSchema implements JSSchemaProvider
 func (c *CryptoModule) GetSchema() schema.ModuleSchema {
+	data := func(description string) schema.ParamSchema {
+		return schema.ParamSchema{Name: "data", Type: "string | ArrayBuffer | Uint8Array", Description: description}
+	}
+	options := func(typ string) schema.ParamSchema {
+		return schema.ParamSchema{Name: "options", Type: typ, Description: "Encodings and options", Optional: true}
+	}
+
 	return schema.ModuleSchema{
 		Name:        "$crypto",
-		Description: "Cryptographic utilities for hashing and random data",
+		Description: "Cryptography: hashes, HMAC, AES-GCM, Ed25519/RSA signatures, password hashing and JWT/JWS. Binary values are strings in utf8, hex, base64 or base64url",
+		Types: []schema.TypeSchema{
+			{
+				Name:        "CryptoOptions",
+				Description: "Encodings of data, keys and results",
+				Fields: []schema.ParamSchema{
+					{Name: "encoding", Type: "'hex' | 'base64' | 'base64url' | 'utf8'", Description: "Encoding of the result", Optional: true},
+					{Name: "inputEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of string data (default utf8)", Optional: true},
+					{Name: "keyEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of string keys (default utf8)", Optional: true},
+				},
+			},
+			{
+				Name:        "CryptoCipherOptions",
+				Description: "Options of encrypt and decrypt",
+				Fields: []schema.ParamSchema{
+					{Name: "encoding", Type: "'hex' | 'base64' | 'base64url' | 'utf8'", Description: "Encoding of the result (encrypt: base64, decrypt: utf8)", Optional: true},
+					{Name: "inputEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of the data (encrypt: utf8, decrypt: base64)", Optional: true},
+					{Name: "keyEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of the key (default utf8)", Optional: true},
+					{Name: "aad", Type: "string", Description: "Additional authenticated data", Optional: true},
+				},
+			},
+			{
+				Name:        "CryptoSignOptions",
+				Description: "Options of sign and verify",
+				Fields: []schema.ParamSchema{
+					{Name: "encoding", Type: "'hex' | 'base64' | 'base64url'", Description: "Encoding of the signature (default base64)", Optional: true},
+					{Name: "inputEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of string data (default utf8)", Optional: true},
+					{Name: "signatureEncoding", Type: "'hex' | 'base64' | 'base64url'", Description: "Encoding of the signature to verify (default base64)", Optional: true},
+					{Name: "hash", Type: "'sha256' | 'sha384' | 'sha512'", Description: "RSA digest (default sha256)", Optional: true},
+					{Name: "padding", Type: "'pkcs1' | 'pss'", Description: "RSA padding (default pkcs1)", Optional: true},
+				},
+			},
+			{
+				Name:        "CryptoKeyPair",
+				Description: "PEM encoded key pair",
+				Fields: []schema.ParamSchema{
+					{Name: "publicKey", Type: "string", Description: "PKIX public key"},
+					{Name: "privateKey", Type: "string", Description: "PKCS #8 private key"},
+				},
+			},
+			{
+				Name:        "CryptoJWTOptions",
+				Description: "Options of jwtSign and jwtVerify",
+				Fields: []schema.ParamSchema{
+					{Name: "alg", Type: "string", Description: "Signing algorithm: HS256 (default), HS384, HS512, RS256, PS256, ES256, EdDSA, ...", Optional: true},
+					{Name: "algorithms", Type: "string[]", Description: "Algorithms accepted when verifying (default those fitting the key)", Optional: true},
+					{Name: "expiresIn", Type: "number | string", Description: "Lifetime in seconds or like '15m', '7d'", Optional: true},
+					{Name: "header", Type: "{ [key: string]: any }", Description: "Extra header fields, e.g. kid", Optional: true},
+					{Name: "issuer", Type: "string", Description: "Sets or requires the iss claim", Optional: true},
+					{Name: "audience", Type: "string", Description: "Sets or requires the aud claim", Optional: true},
+					{Name: "leeway", Type: "number", Description: "Seconds of clock skew tolerated", Optional: true},
+					{Name: "keyEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of HMAC secrets (default utf8)", Optional: true},
+				},
+			},
+			{
+				Name:        "CryptoJWSOptions",
+				Description: "Options of jwsSign and jwsVerify",
+				Fields: []schema.ParamSchema{
+					{Name: "alg", Type: "string", Description: "Signing algorithm (default HS256)", Optional: true},
+					{Name: "algorithms", Type: "string[]", Description: "Algorithms accepted when verifying (default those fitting the key)", Optional: true},
+					{Name: "header", Type: "{ [key: string]: any }", Description: "Extra header fields", Optional: true},
+					{Name: "encoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of the verified payload (default utf8)", Optional: true},
+					{Name: "inputEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of the payload to sign (default utf8)", Optional: true},
+					{Name: "keyEncoding", Type: "'utf8' | 'hex' | 'base64' | 'base64url'", Description: "Encoding of HMAC secrets (default utf8)", Optional: true},
+				},
+			},
+		},
 		Methods: []schema.MethodSchema{
 			{
 				Name:        "md5",
@@ -61,6 +863,12 @@ func (c *CryptoModule) GetSchema() schema.ModuleSchema {
 				Params:      []schema.ParamSchema{{Name: "data", Type: "string", Description: "Data to hash"}},
 				Returns:     &schema.ParamSchema{Type: "string"},
 			},
+			{
+				Name:        "sha1",
+				Description: "Generate SHA1 hash of data",
+				Params:      []schema.ParamSchema{{Name: "data", Type: "string", Description: "Data to hash"}},
+				Returns:     &schema.ParamSchema{Type: "string"},
+			},
 			{
 				Name:        "sha256",
 				Description: "Generate SHA256 hash of data",
@@ -68,11 +876,165 @@ func (c *CryptoModule) GetSchema() schema.ModuleSchema {
 				Returns:     &schema.ParamSchema{Type: "string"},
 			},
 			{
-				Name:        "randomBytes",
-				Description: "Generate random bytes as hex string",
-				Params:      []schema.ParamSchema{{Name: "length", Type: "number", Description: "Number of bytes to generate"}},
+				Name:        "sha512",
+				Description: "Generate SHA512 hash of data",
+				Params:      []schema.ParamSchema{{Name: "data", Type: "string", Description: "Data to hash"}},
 				Returns:     &schema.ParamSchema{Type: "string"},
 			},
+			{
+				Name:        "hash",
+				Description: "Hash data, hex by default",
+				Params: []schema.ParamSchema{
+					{Name: "algo", Type: "'md5' | 'sha1' | 'sha256' | 'sha384' | 'sha512'", Description: "Hash algorithm"},
+					data("Data to hash"),
+					options("CryptoOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "hmac",
+				Description: "Compute an HMAC, hex by default",
+				Params: []schema.ParamSchema{
+					{Name: "algo", Type: "'sha1' | 'sha256' | 'sha384' | 'sha512'", Description: "Hash algorithm"},
+					{Name: "key", Type: "string | ArrayBuffer | Uint8Array", Description: "Secret key"},
+					data("Data to authenticate"),
+					options("CryptoOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "randomBytes",
+				Description: "Generate random bytes, hex by default",
+				Params: []schema.ParamSchema{
+					{Name: "length", Type: "number", Description: "Number of bytes to generate"},
+					{Name: "encoding", Type: "'hex' | 'base64' | 'base64url'", Description: "Encoding of the result", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "timingSafeEqual",
+				Description: "Compare two values in constant time, e.g. signatures",
+				Params: []schema.ParamSchema{
+					{Name: "a", Type: "string | ArrayBuffer | Uint8Array", Description: "First value"},
+					{Name: "b", Type: "string | ArrayBuffer | Uint8Array", Description: "Second value"},
+					options("CryptoOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "encrypt",
+				Description: "Encrypt with AES-GCM under a 16, 24 or 32 byte key; returns nonce, ciphertext and tag, base64 by default",
+				Params: []schema.ParamSchema{
+					{Name: "key", Type: "string | ArrayBuffer | Uint8Array", Description: "AES key, e.g. $crypto.randomBytes(32) with keyEncoding 'hex'"},
+					data("Plaintext"),
+					options("CryptoCipherOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "decrypt",
+				Description: "Decrypt data from encrypt, UTF-8 by default; throws when the key is wrong or the data was altered",
+				Params: []schema.ParamSchema{
+					{Name: "key", Type: "string | ArrayBuffer | Uint8Array", Description: "AES key"},
+					data("Encrypted data"),
+					options("CryptoCipherOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "generateKeyPair",
+				Description: "Generate an Ed25519 or RSA key pair as PEM",
+				Params: []schema.ParamSchema{
+					{Name: "type", Type: "'ed25519' | 'rsa'", Description: "Key type"},
+					{Name: "options", Type: "{ bits?: number }", Description: "RSA key size (default 2048)", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "CryptoKeyPair"},
+			},
+			{
+				Name:        "sign",
+				Description: "Sign data with an Ed25519 or RSA PEM private key, base64 by default",
+				Params: []schema.ParamSchema{
+					{Name: "privateKey", Type: "string", Description: "PEM private key"},
+					data("Data to sign"),
+					options("CryptoSignOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "verify",
+				Description: "Verify a signature with an Ed25519 or RSA PEM public key or certificate",
+				Params: []schema.ParamSchema{
+					{Name: "publicKey", Type: "string", Description: "PEM public key or certificate"},
+					data("Signed data"),
+					{Name: "signature", Type: "string | ArrayBuffer | Uint8Array", Description: "Signature"},
+					options("CryptoSignOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "hashPassword",
+				Description: "Hash a password",
+				Params: []schema.ParamSchema{
+					{Name: "password", Type: "string", Description: "Password"},
+					{Name: "algo", Type: "'bcrypt' | 'argon2id'", Description: "Algorithm (default bcrypt)", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "verifyPassword",
+				Description: "Check a password against a bcrypt or argon2id hash",
+				Params: []schema.ParamSchema{
+					{Name: "password", Type: "string", Description: "Password"},
+					{Name: "hash", Type: "string", Description: "Hash"},
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "jwtSign",
+				Description: "Sign claims as a JWT with a secret (HS*) or a PEM private key",
+				Params: []schema.ParamSchema{
+					{Name: "payload", Type: "{ [key: string]: any }", Description: "Claims"},
+					{Name: "key", Type: "string", Description: "Secret or PEM private key"},
+					options("CryptoJWTOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "jwtVerify",
+				Description: "Get the claims of a valid JWT, null when the signature, expiry, issuer or audience is wrong",
+				Params: []schema.ParamSchema{
+					{Name: "token", Type: "string", Description: "JWT"},
+					{Name: "key", Type: "string", Description: "Secret or PEM public key"},
+					options("CryptoJWTOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "{ [key: string]: any } | null"},
+			},
+			{
+				Name:        "jwtDecode",
+				Description: "Read the header and payload of a JWT without verifying it",
+				Params:      []schema.ParamSchema{{Name: "token", Type: "string", Description: "JWT"}},
+				Returns:     &schema.ParamSchema{Type: "{ header: { [key: string]: any }, payload: { [key: string]: any } } | null"},
+			},
+			{
+				Name:        "jwsSign",
+				Description: "Sign any payload as a compact JWS",
+				Params: []schema.ParamSchema{
+					data("Payload"),
+					{Name: "key", Type: "string", Description: "Secret or PEM private key"},
+					options("CryptoJWSOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
+			{
+				Name:        "jwsVerify",
+				Description: "Get the payload of a valid compact JWS, null when the signature is wrong",
+				Params: []schema.ParamSchema{
+					{Name: "token", Type: "string", Description: "Compact JWS"},
+					{Name: "key", Type: "string", Description: "Secret or PEM public key"},
+					options("CryptoJWSOptions"),
+				},
+				Returns: &schema.ParamSchema{Type: "string | null"},
+			},
 		},
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"embed"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -90,16 +91,14 @@ func (d *DrawModule) LoadFont(name, storagePath string) error {
 		return fmt.Errorf("storage service not available")
 	}
 
-	absPath, err := d.storage.GetPath(d.projectID, storagePath)
+	absPath, err := d.storage.LocalCopy(d.projectID, storagePath)
+	if errors.Is(err, service.ErrFileNotFound) {
+		return fmt.Errorf("font file not found: %s", storagePath)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Verify file exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return fmt.Errorf("font file not found: %s", storagePath)
-	}
-
 	d.fonts[name] = absPath
 	return nil
 }
@@ -3,7 +3,6 @@ package modules
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/dop251/goja"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -25,8 +24,18 @@ type GoalInfo struct {
 
 // GoalStatInfo represents goal statistics returned to JS
 type GoalStatInfo struct {
-	Date  string `json:"date"`
-	Value int64  `json:"value"`
+	Date   string             `json:"date"`
+	Hour   *int               `json:"hour"`
+	Labels map[string]string  `json:"labels"`
+	Value  float64            `json:"value"`
+	Metric *domain.GoalMetric `json:"metric"`
+}
+
+// GoalStatsOptions configures $goals.getStats
+type GoalStatsOptions struct {
+	Interval string            `json:"interval"` // day (default), hour or total
+	GroupBy  string            `json:"groupBy"`
+	Labels   map[string]string `json:"labels"`
 }
 
 type GoalsModule struct {
@@ -50,6 +59,7 @@ func (g *GoalsModule) Name() string {
 func (g *GoalsModule) Register(vm interface{}) {
 	vm.(*goja.Runtime).Set(g.Name(), map[string]interface{}{
 		"increment": g.Increment,
+		"record":    g.Record,
 		"getValue":  g.GetValue,
 		"getStats":  g.GetStats,
 		"list":      g.List,
@@ -57,27 +67,41 @@ func (g *GoalsModule) Register(vm interface{}) {
 	})
 }
 
-// Increment increments a goal counter by the specified value (default 1)
-func (g *GoalsModule) Increment(slug string, value ...int64) (bool, error) {
+// Increment increments a goal counter by the specified value (default 1),
+// optionally under a set of labels
+func (g *GoalsModule) Increment(slug string, value *int64, labels map[string]string) (bool, error) {
 	if g.goalService == nil {
 		return false, fmt.Errorf("goal service not available")
 	}
 
 	v := int64(1)
-	if len(value) > 0 {
-		v = value[0]
+	if value != nil {
+		v = *value
 	}
 
 	ctx := context.Background()
-	err := g.goalService.Increment(ctx, slug, g.projectID, v)
+	err := g.goalService.Increment(ctx, slug, g.projectID, v, labels)
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-// GetValue returns the current value of a goal (total for counter, today's value for daily_counter)
-func (g *GoalsModule) GetValue(slug string) (int64, error) {
+// Record adds a sample to a gauge, sum, avg or histogram goal
+func (g *GoalsModule) Record(slug string, value float64, labels map[string]string) (bool, error) {
+	if g.goalService == nil {
+		return false, fmt.Errorf("goal service not available")
+	}
+
+	if err := g.goalService.Record(context.Background(), slug, g.projectID, value, labels); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetValue returns the current value of a goal: the total for counter, today's
+// value for daily_counter and metric goals
+func (g *GoalsModule) GetValue(slug string, labels map[string]string) (float64, error) {
 	if g.goalService == nil {
 		return 0, fmt.Errorf("goal service not available")
 	}
@@ -88,12 +112,12 @@ func (g *GoalsModule) GetValue(slug string) (int64, error) {
 		return 0, err
 	}
 
-	// Determine the date to query (UTC)
+	// Determine the date to query (project timezone)
 	var date string
-	if goal.Type == domain.GoalTypeDailyCounter {
-		date = time.Now().UTC().Format("2006-01-02")
-	} else {
+	if goal.Type == domain.GoalTypeCounter {
 		date = "total"
+	} else {
+		date = g.goalService.Now(ctx, g.projectID).Format("2006-01-02")
 	}
 
 	// Get stats for this goal
@@ -102,6 +126,7 @@ func (g *GoalsModule) GetValue(slug string) (int64, error) {
 		ProjectID: g.projectID.Hex(),
 		StartDate: date,
 		EndDate:   date,
+		Labels:    labelFilters(labels),
 	}
 
 	stats, err := g.goalService.GetStats(ctx, query)
@@ -112,11 +137,11 @@ func (g *GoalsModule) GetValue(slug string) (int64, error) {
 		return 0, nil
 	}
 
-	return stats[0].Value, nil
+	return stats[0].ValueFor(goal.Type), nil
 }
 
 // GetStats returns statistics for a goal over a period of days
-func (g *GoalsModule) GetStats(slug string, days int) ([]GoalStatInfo, error) {
+func (g *GoalsModule) GetStats(slug string, days int, options *GoalStatsOptions) ([]GoalStatInfo, error) {
 	if g.goalService == nil {
 		return nil, fmt.Errorf("goal service not available")
 	}
@@ -124,6 +149,9 @@ func (g *GoalsModule) GetStats(slug string, days int) ([]GoalStatInfo, error) {
 	if days <= 0 {
 		days = 7 // Default to 7 days
 	}
+	if options == nil {
+		options = &GoalStatsOptions{}
+	}
 
 	ctx := context.Background()
 	goal, err := g.goalService.GetBySlug(ctx, slug)
@@ -132,7 +160,7 @@ func (g *GoalsModule) GetStats(slug string, days int) ([]GoalStatInfo, error) {
 	}
 
 	// Calculate date range
-	endDate := time.Now()
+	endDate := g.goalService.Now(ctx, g.projectID)
 	startDate := endDate.AddDate(0, 0, -days+1)
 
 	query := &domain.GoalStatsQuery{
@@ -140,6 +168,9 @@ func (g *GoalsModule) GetStats(slug string, days int) ([]GoalStatInfo, error) {
 		ProjectID: g.projectID.Hex(),
 		StartDate: startDate.Format("2006-01-02"),
 		EndDate:   endDate.Format("2006-01-02"),
+		Interval:  options.Interval,
+		GroupBy:   options.GroupBy,
+		Labels:    labelFilters(options.Labels),
 	}
 
 	stats, err := g.goalService.GetStats(ctx, query)
@@ -150,14 +181,25 @@ func (g *GoalsModule) GetStats(slug string, days int) ([]GoalStatInfo, error) {
 	result := make([]GoalStatInfo, 0, len(stats))
 	for _, stat := range stats {
 		result = append(result, GoalStatInfo{
-			Date:  stat.Date,
-			Value: stat.Value,
+			Date:   stat.Date,
+			Hour:   stat.Hour,
+			Labels: stat.Labels,
+			Value:  stat.ValueFor(goal.Type),
+			Metric: stat.Metric,
 		})
 	}
 
 	return result, nil
 }
 
+func labelFilters(labels map[string]string) []string {
+	filters := make([]string, 0, len(labels))
+	for key, value := range labels {
+		filters = append(filters, key+"="+value)
+	}
+	return filters
+}
+
 // List returns all goals accessible by this project
 func (g *GoalsModule) List() []GoalInfo {
 	if g.goalService == nil {
@@ -269,17 +311,42 @@ func (g *GoalsModule) GetSchema() schema.ModuleSchema {
 					{Name: "id", Type: "string", Description: "Goal ID"},
 					{Name: "name", Type: "string", Description: "Goal name"},
 					{Name: "slug", Type: "string", Description: "Goal slug identifier"},
-					{Name: "type", Type: "string", Description: "Goal type (counter or daily_counter)"},
+					{Name: "type", Type: "string", Description: "Goal type (counter, daily_counter, gauge, sum, avg or histogram)"},
 					{Name: "description", Type: "string", Description: "Goal description"},
 					{Name: "color", Type: "string", Description: "Goal color"},
 				},
 			},
 			{
 				Name:        "GoalStatInfo",
-				Description: "Goal statistics for a date",
+				Description: "Goal statistics for a date or hour",
 				Fields: []schema.ParamSchema{
-					{Name: "date", Type: "string", Description: "Date in YYYY-MM-DD format"},
-					{Name: "value", Type: "number", Description: "Value for the date"},
+					{Name: "date", Type: "string", Description: "Date in YYYY-MM-DD format, or total"},
+					{Name: "hour", Type: "number | null", Description: "Hour of the date (project timezone) for the hour interval"},
+					{Name: "labels", Type: "{ [key: string]: string } | null", Description: "Value of the groupBy label"},
+					{Name: "value", Type: "number", Description: "Count, last value of gauges, sum, average, or median of histograms"},
+					{Name: "metric", Type: "GoalMetric | null", Description: "Sample aggregates of metric goals"},
+				},
+			},
+			{
+				Name:        "GoalMetric",
+				Description: "Aggregates of the samples recorded for a metric goal",
+				Fields: []schema.ParamSchema{
+					{Name: "count", Type: "number", Description: "Number of samples"},
+					{Name: "sum", Type: "number", Description: "Sum of samples"},
+					{Name: "min", Type: "number", Description: "Smallest sample"},
+					{Name: "max", Type: "number", Description: "Largest sample"},
+					{Name: "avg", Type: "number", Description: "Average of samples"},
+					{Name: "last", Type: "number", Description: "Last recorded sample"},
+					{Name: "percentiles", Type: "{ p50: number, p90: number, p95: number, p99: number } | null", Description: "Percentiles of histograms"},
+				},
+			},
+			{
+				Name:        "GoalStatsOptions",
+				Description: "Options for getStats",
+				Fields: []schema.ParamSchema{
+					{Name: "interval", Type: "'day' | 'hour' | 'total'", Description: "Bucket size (default day)", Optional: true},
+					{Name: "groupBy", Type: "string", Description: "Label to split the stats by", Optional: true},
+					{Name: "labels", Type: "{ [key: string]: string }", Description: "Only count stats with these labels", Optional: true},
 				},
 			},
 		},
@@ -290,14 +357,28 @@ func (g *GoalsModule) GetSchema() schema.ModuleSchema {
 				Params: []schema.ParamSchema{
 					{Name: "slug", Type: "string", Description: "Goal slug identifier"},
 					{Name: "value", Type: "number", Description: "Amount to increment (default 1)", Optional: true},
+					{Name: "labels", Type: "{ [key: string]: string }", Description: "Label dimensions, e.g. { region: 'eu' }", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
+			{
+				Name:        "record",
+				Description: "Record a sample for a gauge, sum, avg or histogram goal",
+				Params: []schema.ParamSchema{
+					{Name: "slug", Type: "string", Description: "Goal slug identifier"},
+					{Name: "value", Type: "number", Description: "Sample value, e.g. a latency in ms"},
+					{Name: "labels", Type: "{ [key: string]: string }", Description: "Label dimensions, e.g. { region: 'eu' }", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Type: "boolean"},
 			},
 			{
 				Name:        "getValue",
 				Description: "Get current value of a goal",
-				Params:      []schema.ParamSchema{{Name: "slug", Type: "string", Description: "Goal slug identifier"}},
-				Returns:     &schema.ParamSchema{Type: "number"},
+				Params: []schema.ParamSchema{
+					{Name: "slug", Type: "string", Description: "Goal slug identifier"},
+					{Name: "labels", Type: "{ [key: string]: string }", Description: "Only count stats with these labels", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "number"},
 			},
 			{
 				Name:        "getStats",
@@ -305,6 +386,7 @@ func (g *GoalsModule) GetSchema() schema.ModuleSchema {
 				Params: []schema.ParamSchema{
 					{Name: "slug", Type: "string", Description: "Goal slug identifier"},
 					{Name: "days", Type: "number", Description: "Number of days (default 7)"},
+					{Name: "options", Type: "GoalStatsOptions", Description: "Interval, label grouping and filters", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Type: "GoalStatInfo[]"},
 			},
@@ -1,7 +1,10 @@
 package modules
 
 import (
+	"errors"
 	"fmt"
+	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +15,13 @@ import (
 	"github.com/levskiy0/m3m/pkg/schema"
 )
 
+// storageHookQueueSize is how many file changes may wait for the storage handlers
+const storageHookQueueSize = 1024
+
+// ErrStorageHookQueueFull is returned when file changes arrive faster than
+// the storage handlers process them
+var ErrStorageHookQueueFull = errors.New("storage hook queue is full")
+
 // HookBroadcaster interface for broadcasting action state changes
 type HookBroadcaster interface {
 	BroadcastActionStates(projectID string, states []domain.ActionRuntimeState)
@@ -40,17 +50,31 @@ type modelHandler struct {
 	handler   goja.Callable
 }
 
-// HookModule manages action and model handlers
+// storageHandler stores the handler for storage hooks
+type storageHandler struct {
+	eventType domain.StorageEventType
+	pattern   string
+	handler   goja.Callable
+}
+
+// HookModule manages action, model and storage handlers
 type HookModule struct {
 	actionHandlers   map[string]*actionHandler                    // slug -> handler
 	modelHandlers    map[string]map[ModelHookType][]*modelHandler // modelName -> hookType -> handlers
-	actionStates     map[string]domain.ActionState                // slug -> state
+	storageHandlers  []*storageHandler
+	storageQueue     chan domain.StorageEvent // file changes waiting for the storage handlers
+	startWorker      sync.Once
+	stopWorker       sync.Once
+	done             chan struct{}
+	actionStates     map[string]domain.ActionState // slug -> state
 	mu               sync.RWMutex
 	vm               *goja.Runtime
 	projectID        primitive.ObjectID
 	broadcaster      HookBroadcaster
 	currentUserID    string // current user ID for action context
 	currentSessionID string // current WebSocket session ID for UI targeting
+	logger           *LoggerModule
+	storage          *StorageModule
 }
 
 // NewHookModule creates a new HookModule
@@ -59,6 +83,8 @@ func NewHookModule(vm *goja.Runtime, projectID primitive.ObjectID, broadcaster H
 		actionHandlers: make(map[string]*actionHandler),
 		modelHandlers:  make(map[string]map[ModelHookType][]*modelHandler),
 		actionStates:   make(map[string]domain.ActionState),
+		storageQueue:   make(chan domain.StorageEvent, storageHookQueueSize),
+		done:           make(chan struct{}),
 		vm:             vm,
 		projectID:      projectID,
 		broadcaster:    broadcaster,
@@ -77,6 +103,7 @@ func (m *HookModule) Register(vm interface{}) {
 		"onModelInsert": m.OnModelInsert,
 		"onModelUpdate": m.OnModelUpdate,
 		"onModelDelete": m.OnModelDelete,
+		"onStorage":     m.OnStorage,
 	})
 }
 
@@ -166,6 +193,55 @@ func (m *HookModule) registerModelHandler(call goja.FunctionCall, hookType Model
 	return goja.Undefined()
 }
 
+// OnStorage registers a handler for file changes in project storage
+// Usage: $hook.onStorage('write', 'uploads/**/*.jpg', (e, ctx) => { ... })
+func (m *HookModule) OnStorage(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 3 {
+		panic(m.vm.NewTypeError("$hook.onStorage requires event, pathGlob and handler arguments"))
+	}
+
+	eventType := domain.StorageEventType(call.Arguments[0].String())
+	switch eventType {
+	case domain.StorageEventWrite, domain.StorageEventDelete, domain.StorageEventRename:
+	default:
+		panic(m.vm.NewTypeError(fmt.Sprintf("unknown storage event %q, use 'write', 'delete' or 'rename'", eventType)))
+	}
+
+	pattern := call.Arguments[1].String()
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		panic(m.vm.NewTypeError(fmt.Sprintf("invalid path glob %q: %v", pattern, err)))
+	}
+
+	handler, ok := goja.AssertFunction(call.Arguments[2])
+	if !ok {
+		panic(m.vm.NewTypeError("third argument must be a function"))
+	}
+
+	m.mu.Lock()
+	m.storageHandlers = append(m.storageHandlers, &storageHandler{
+		eventType: eventType,
+		pattern:   pattern,
+		handler:   handler,
+	})
+	m.mu.Unlock()
+
+	m.startWorker.Do(func() {
+		go m.runStorageWorker()
+	})
+
+	return goja.Undefined()
+}
+
+// SetStorage sets the module storage handlers get their ctx.storage from
+func (m *HookModule) SetStorage(storage *StorageModule) {
+	m.storage = storage
+}
+
+// SetLogger sets the logger used to attribute records to actions and hooks
+func (m *HookModule) SetLogger(logger *LoggerModule) {
+	m.logger = logger
+}
+
 // TriggerAction executes an action handler
 func (m *HookModule) TriggerAction(slug string) error {
 	m.mu.RLock()
@@ -224,9 +300,11 @@ func (m *HookModule) TriggerModelHook(modelSlug string, hookType ModelHookType,
 		cleanData[k] = v
 	}
 
+	ctx := m.vm.ToValue(m.hookContext(fmt.Sprintf("hook:%s.%s", modelSlug, hookType)))
+
 	// Call all handlers
 	for _, h := range hookHandlers {
-		_, err := h.handler(goja.Undefined(), m.vm.ToValue(cleanData))
+		_, err := h.handler(goja.Undefined(), m.vm.ToValue(cleanData), ctx)
 		if err != nil {
 			return err
 		}
@@ -235,6 +313,105 @@ func (m *HookModule) TriggerModelHook(modelSlug string, hookType ModelHookType,
 	return nil
 }
 
+// QueueStorageEvent queues a file change for the storage handlers, which
+// run one change at a time on the hook worker instead of the goroutine that
+// made the change. Changes the handlers make through ctx.storage are
+// skipped, so they do not loop.
+func (m *HookModule) QueueStorageEvent(event domain.StorageEvent) error {
+	if event.Origin == domain.StorageOriginHook || len(m.storageHandlersFor(event)) == 0 {
+		return nil
+	}
+
+	select {
+	case <-m.done:
+		return nil
+	default:
+	}
+
+	select {
+	case m.storageQueue <- event:
+		return nil
+	default:
+		return ErrStorageHookQueueFull
+	}
+}
+
+// Stop ends the storage hook worker; queued changes are dropped
+func (m *HookModule) Stop() {
+	m.stopWorker.Do(func() {
+		close(m.done)
+	})
+}
+
+func (m *HookModule) runStorageWorker() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case event := <-m.storageQueue:
+			// The change already happened, so handler errors only go to the project log
+			if err := m.TriggerStorageHook(event); err != nil && m.logger != nil {
+				m.logger.Error(fmt.Sprintf("storage hook for %s %s failed: %v", event.Type, event.Path, err))
+			}
+		}
+	}
+}
+
+// TriggerStorageHook executes the storage handlers matching an event on the
+// calling goroutine
+func (m *HookModule) TriggerStorageHook(event domain.StorageEvent) error {
+	handlers := m.storageHandlersFor(event)
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	ctx := m.hookContext(fmt.Sprintf("hook:storage.%s", event.Type))
+	if m.storage != nil {
+		// Changes made through ctx.storage do not trigger the handlers again
+		ctx["storage"] = m.storage.WithOrigin(domain.StorageOriginHook)
+	}
+	for _, h := range handlers {
+		if _, err := h.handler(goja.Undefined(), m.vm.ToValue(event), m.vm.ToValue(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hookContext builds the second argument of model and storage handlers
+func (m *HookModule) hookContext(source string) map[string]interface{} {
+	return map[string]interface{}{
+		"logger": m.logger.Scoped(source, ""),
+	}
+}
+
+func (m *HookModule) storageHandlersFor(event domain.StorageEvent) []*storageHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var handlers []*storageHandler
+	for _, h := range m.storageHandlers {
+		if h.eventType == event.Type && (domain.MatchStoragePath(h.pattern, event.Path) ||
+			(event.Type == domain.StorageEventRename && domain.MatchStoragePath(h.pattern, event.OldPath))) {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// HasStorageHandler checks if a handler is registered for the given storage event
+func (m *HookModule) HasStorageHandler(eventType domain.StorageEventType) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, h := range m.storageHandlers {
+		if h.eventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
 // SetCurrentUser sets the current user ID for action context
 func (m *HookModule) SetCurrentUser(userID string) {
 	m.mu.Lock()
@@ -276,6 +453,7 @@ func (m *HookModule) createActionContext(name, slug string) map[string]interface
 		"slug":      slug,
 		"userId":    userID,    // Include userId for informational purposes
 		"sessionId": sessionID, // Include sessionId for async $ui calls
+		"logger":    m.logger.Scoped("action:"+slug, ""),
 		"loading": func(loading bool) {
 			if loading {
 				m.setActionState(slug, domain.ActionStateLoading)
@@ -379,7 +557,7 @@ func capitalizeFirst(s string) string {
 func (m *HookModule) GetSchema() schema.ModuleSchema {
 	return schema.ModuleSchema{
 		Name:        "$hook",
-		Description: "Universal hook system for actions and model events",
+		Description: "Universal hook system for actions, model and storage events",
 		Types: []schema.TypeSchema{
 			{
 				Name:        "ActionContext",
@@ -391,6 +569,27 @@ func (m *HookModule) GetSchema() schema.ModuleSchema {
 					{Name: "sessionId", Type: "string", Description: "WebSocket session ID for UI targeting"},
 					{Name: "loading", Type: "(loading: boolean) => void", Description: "Set loading state (true shows spinner, false enables button)"},
 					{Name: "active", Type: "(active: boolean) => void", Description: "Set active state (true enables, false disables button)"},
+					{Name: "logger", Type: "Logger", Description: "Logger attributing records to this action"},
+				},
+			},
+			{
+				Name:        "HookContext",
+				Description: "Context passed to model and storage handlers after the data",
+				Fields: []schema.ParamSchema{
+					{Name: "logger", Type: "Logger", Description: "Logger attributing records to this hook"},
+					{Name: "storage", Type: "typeof $storage", Description: "Storage whose changes do not trigger storage handlers (storage handlers only)", Optional: true},
+				},
+			},
+			{
+				Name:        "StorageEvent",
+				Description: "File change passed to storage handlers",
+				Fields: []schema.ParamSchema{
+					{Name: "event", Type: "'write' | 'delete' | 'rename'", Description: "Kind of change"},
+					{Name: "path", Type: "string", Description: "File path relative to the storage root"},
+					{Name: "oldPath", Type: "string", Description: "Previous path of a renamed file"},
+					{Name: "size", Type: "number", Description: "File size in bytes"},
+					{Name: "mimeType", Type: "string", Description: "MIME type from the file extension"},
+					{Name: "isDir", Type: "boolean", Description: "True when a whole directory was deleted or renamed"},
 				},
 			},
 		},
@@ -408,7 +607,7 @@ func (m *HookModule) GetSchema() schema.ModuleSchema {
 				Description: "Register a handler for model insert events (triggered only from frontend)",
 				Params: []schema.ParamSchema{
 					{Name: "modelName", Type: "string", Description: "Model slug name"},
-					{Name: "handler", Type: "(data: object) => void", Description: "Handler function called when a record is inserted"},
+					{Name: "handler", Type: "(data: object, ctx: HookContext) => void", Description: "Handler function called when a record is inserted"},
 				},
 			},
 			{
@@ -416,7 +615,7 @@ func (m *HookModule) GetSchema() schema.ModuleSchema {
 				Description: "Register a handler for model update events (triggered only from frontend)",
 				Params: []schema.ParamSchema{
 					{Name: "modelName", Type: "string", Description: "Model slug name"},
-					{Name: "handler", Type: "(data: object) => void", Description: "Handler function called when a record is updated"},
+					{Name: "handler", Type: "(data: object, ctx: HookContext) => void", Description: "Handler function called when a record is updated"},
 				},
 			},
 			{
@@ -424,7 +623,16 @@ func (m *HookModule) GetSchema() schema.ModuleSchema {
 				Description: "Register a handler for model delete events (triggered only from frontend)",
 				Params: []schema.ParamSchema{
 					{Name: "modelName", Type: "string", Description: "Model slug name"},
-					{Name: "handler", Type: "(data: object) => void", Description: "Handler function called when a record is deleted"},
+					{Name: "handler", Type: "(data: object, ctx: HookContext) => void", Description: "Handler function called when a record is deleted"},
+				},
+			},
+			{
+				Name:        "onStorage",
+				Description: "Register a handler for file changes from uploads, the file manager and $storage. Copies and unzips report a write per file. Handlers run one change at a time after the change, outside the request that made it. Changes made through ctx.storage do not trigger hooks again",
+				Params: []schema.ParamSchema{
+					{Name: "event", Type: "'write' | 'delete' | 'rename'", Description: "Kind of change"},
+					{Name: "pathGlob", Type: "string", Description: "Path pattern, '*' matches within a folder and '**' across folders (e.g. 'uploads/**/*.jpg')"},
+					{Name: "handler", Type: "(event: StorageEvent, ctx: HookContext) => void", Description: "Handler function called after the change"},
 				},
 			},
 		},
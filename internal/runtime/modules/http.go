@@ -10,7 +10,6 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -79,6 +78,9 @@ func (h *HTTPModule) Register(vm interface{}) {
 		"download": func(url string, storagePath string, options ...*HTTPOptions) goja.Value {
 			return createResponse(h.Download(url, storagePath, options...))
 		},
+		"upload": func(url string, storagePath string, options ...*HTTPUploadOptions) goja.Value {
+			return createResponse(h.Upload(url, storagePath, options...))
+		},
 	})
 }
 
@@ -125,6 +127,15 @@ type HTTPOptions struct {
 	SkipTLSVerify   bool              `json:"skipTLSVerify"`   // skip TLS verification
 }
 
+// HTTPUploadOptions configures $http.upload; it accepts every HTTPOptions field too
+type HTTPUploadOptions struct {
+	HTTPOptions
+	Method      string            `json:"method"`      // default: POST
+	Field       string            `json:"field"`       // multipart field for the file, raw body when empty
+	Fields      map[string]string `json:"fields"`      // extra multipart fields
+	ContentType string            `json:"contentType"` // raw body type, default: the file's MIME type
+}
+
 type FormField struct {
 	Value    string `json:"value"`    // string value
 	Filename string `json:"filename"` // filename for file uploads
@@ -255,17 +266,10 @@ func (h *HTTPModule) parseResponse(resp *http.Response) *HTTPResponse {
 		return &HTTPResponse{Status: resp.StatusCode, StatusText: err.Error()}
 	}
 
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
-	}
-
 	return &HTTPResponse{
 		Status:     resp.StatusCode,
 		StatusText: resp.Status,
-		Headers:    headers,
+		Headers:    responseHeaders(resp),
 		Body:       string(respBody),
 		bodyBytes:  respBody,
 	}
@@ -388,7 +392,7 @@ func (h *HTTPModule) PostForm(urlStr string, fields map[string]interface{}, opti
 	return h.parseResponse(resp)
 }
 
-// Download downloads a file from URL and saves it to storage
+// Download downloads a file from URL and streams it into storage
 func (h *HTTPModule) Download(urlStr string, storagePath string, options ...*HTTPOptions) *HTTPResponse {
 	var opts *HTTPOptions
 	if len(options) > 0 {
@@ -409,42 +413,121 @@ func (h *HTTPModule) Download(urlStr string, storagePath string, options ...*HTT
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	if h.storage == nil || h.projectID == "" {
+		return &HTTPResponse{Status: 0, StatusText: "storage service not available"}
+	}
+
+	// Stream the body into storage instead of holding it in memory
+	writer, err := h.storage.Create(h.projectID, storagePath, service.RuntimeVersionUser, false)
 	if err != nil {
+		return &HTTPResponse{Status: resp.StatusCode, StatusText: "download succeeded but failed to save: " + err.Error()}
+	}
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		writer.Abort()
 		return &HTTPResponse{Status: resp.StatusCode, StatusText: err.Error()}
 	}
+	if err := writer.Close(); err != nil {
+		return &HTTPResponse{Status: resp.StatusCode, StatusText: "download succeeded but failed to save: " + err.Error()}
+	}
 
-	// Save to storage if storage service is available
-	if h.storage != nil && h.projectID != "" {
-		// Ensure directory exists
-		dir := filepath.Dir(storagePath)
-		if dir != "" && dir != "." {
-			_ = h.storage.MkDir(h.projectID, dir)
-		}
+	// Return response with saved file path info
+	return &HTTPResponse{
+		Status:     resp.StatusCode,
+		StatusText: resp.Status,
+		Headers:    responseHeaders(resp),
+		Body:       storagePath, // Return the path where file was saved
+	}
+}
+
+// Upload sends a storage file as the request body, or as a multipart form
+// field, streaming it from storage without loading it into memory
+func (h *HTTPModule) Upload(urlStr string, storagePath string, options ...*HTTPUploadOptions) *HTTPResponse {
+	opts := &HTTPUploadOptions{}
+	if len(options) > 0 && options[0] != nil {
+		opts = options[0]
+	}
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = "POST"
+	}
+
+	if h.storage == nil || h.projectID == "" {
+		return &HTTPResponse{Status: 0, StatusText: "storage service not available"}
+	}
+	object, err := h.storage.Open(h.projectID, storagePath)
+	if err != nil {
+		return &HTTPResponse{Status: 0, StatusText: err.Error()}
+	}
+	defer object.Close()
 
-		err = h.storage.Write(h.projectID, storagePath, respBody)
+	var req *http.Request
+	if opts.Field == "" {
+		req, err = http.NewRequest(method, urlStr, object)
 		if err != nil {
-			return &HTTPResponse{Status: resp.StatusCode, StatusText: "download succeeded but failed to save: " + err.Error()}
+			return &HTTPResponse{Status: 0, StatusText: err.Error()}
 		}
+		req.ContentLength = object.Size
+		contentType := opts.ContentType
+		if contentType == "" {
+			contentType = object.MimeType
+		}
+		req.Header.Set("Content-Type", contentType)
 	} else {
-		return &HTTPResponse{Status: 0, StatusText: "storage service not available"}
+		body, contentType := streamMultipart(opts.Fields, opts.Field, object)
+		req, err = http.NewRequest(method, urlStr, body)
+		if err != nil {
+			body.Close()
+			return &HTTPResponse{Status: 0, StatusText: err.Error()}
+		}
+		req.Header.Set("Content-Type", contentType)
 	}
 
+	h.applyOptions(req, &opts.HTTPOptions)
+
+	client := h.buildClient(&opts.HTTPOptions)
+	resp, err := client.Do(req)
+	if err != nil {
+		return &HTTPResponse{Status: 0, StatusText: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	return h.parseResponse(resp)
+}
+
+// streamMultipart encodes form fields and a file as multipart/form-data while
+// the request body is read
+func streamMultipart(fields map[string]string, fileField string, file *service.StorageObject) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		for key, value := range fields {
+			if err := writer.WriteField(key, value); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		part, err := writer.CreateFormFile(fileField, file.Name)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, writer.FormDataContentType()
+}
+
+func responseHeaders(resp *http.Response) map[string]string {
 	headers := make(map[string]string)
 	for k, v := range resp.Header {
 		if len(v) > 0 {
 			headers[k] = v[0]
 		}
 	}
-
-	// Return response with saved file path info
-	return &HTTPResponse{
-		Status:     resp.StatusCode,
-		StatusText: resp.Status,
-		Headers:    headers,
-		Body:       storagePath, // Return the path where file was saved
-		bodyBytes:  respBody,
-	}
+	return headers
 }
 
 // PostFormURLEncoded sends an application/x-www-form-urlencoded request
@@ -518,6 +601,20 @@ func (h *HTTPModule) GetSchema() schema.ModuleSchema {
 					{Name: "skipTLSVerify", Type: "boolean", Description: "Skip TLS certificate verification (use with caution)", Optional: true},
 				},
 			},
+			{
+				Name:        "HTTPUploadOptions",
+				Description: "Options for $http.upload, in addition to every HTTPOptions field",
+				Fields: []schema.ParamSchema{
+					{Name: "method", Type: "string", Description: "HTTP method (default: POST)", Optional: true},
+					{Name: "field", Type: "string", Description: "Send the file as this multipart form field instead of the raw body", Optional: true},
+					{Name: "fields", Type: "{ [key: string]: string }", Description: "Extra multipart form fields", Optional: true},
+					{Name: "contentType", Type: "string", Description: "Content type of a raw body (default: the file's MIME type)", Optional: true},
+					{Name: "headers", Type: "{ [key: string]: string }", Description: "Request headers", Optional: true},
+					{Name: "timeout", Type: "number", Description: "Request timeout in milliseconds", Optional: true},
+					{Name: "bearerToken", Type: "string", Description: "Bearer token for authorization", Optional: true},
+					{Name: "basicAuth", Type: "BasicAuth", Description: "Basic authentication credentials", Optional: true},
+				},
+			},
 			{
 				Name:        "FormField",
 				Description: "Form field for multipart uploads",
@@ -617,7 +714,7 @@ func (h *HTTPModule) GetSchema() schema.ModuleSchema {
 			},
 			{
 				Name:        "download",
-				Description: "Download file from URL and save to storage",
+				Description: "Download file from URL and stream it into storage without buffering it in memory",
 				Params: []schema.ParamSchema{
 					{Name: "url", Type: "string", Description: "URL to download from"},
 					{Name: "storagePath", Type: "string", Description: "Path in storage where file will be saved"},
@@ -625,6 +722,16 @@ func (h *HTTPModule) GetSchema() schema.ModuleSchema {
 				},
 				Returns: &schema.ParamSchema{Type: "HTTPResponse", Description: "Response with body containing the saved file path"},
 			},
+			{
+				Name:        "upload",
+				Description: "Stream a storage file to a URL, as the raw request body or as a multipart form field",
+				Params: []schema.ParamSchema{
+					{Name: "url", Type: "string", Description: "URL to upload to"},
+					{Name: "storagePath", Type: "string", Description: "Path of the file in storage"},
+					{Name: "options", Type: "HTTPUploadOptions", Description: "Upload and request options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "HTTPResponse"},
+			},
 		},
 	}
 }
@@ -2,18 +2,13 @@ package modules
 
 import (
 	"bytes"
-	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/dop251/goja"
-	"golang.org/x/image/draw"
-	_ "golang.org/x/image/webp" // WebP decoder
 
+	"github.com/levskiy0/m3m/internal/imaging"
 	"github.com/levskiy0/m3m/internal/service"
 	"github.com/levskiy0/m3m/pkg/schema"
 )
@@ -49,6 +44,7 @@ func (m *ImageModule) Register(vm interface{}) {
 		"resizeKeepRatio": m.ResizeKeepRatio,
 		"crop":            m.Crop,
 		"thumbnail":       m.Thumbnail,
+		"transform":       m.Transform,
 		"readAsBase64":    m.ReadAsBase64,
 	})
 }
@@ -60,7 +56,7 @@ func (m *ImageModule) Info(path string) (*ImageInfo, error) {
 		return nil, err
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(data))
+	img, format, err := imaging.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -75,156 +71,87 @@ func (m *ImageModule) Info(path string) (*ImageInfo, error) {
 
 // Resize resizes an image to the specified dimensions and saves to destination
 func (m *ImageModule) Resize(src, dst string, width, height int) (bool, error) {
-	data, err := m.storage.Read(m.projectID, src)
-	if err != nil {
-		return false, err
-	}
-
-	img, format, err := image.Decode(bytes.NewReader(data))
-	if err != nil {
-		return false, err
-	}
-
-	// Create resized image
-	resized := image.NewRGBA(image.Rect(0, 0, width, height))
-	draw.CatmullRom.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Over, nil)
-
-	// Encode to bytes
-	var buf bytes.Buffer
-	if err := m.encodeImage(&buf, resized, format, dst); err != nil {
-		return false, err
-	}
-
-	// Write to storage
-	if err := m.storage.Write(m.projectID, dst, buf.Bytes()); err != nil {
-		return false, err
-	}
-	return true, nil
+	return m.Transform(src, dst, map[string]interface{}{"width": width, "height": height, "fit": string(imaging.FitFill)})
 }
 
 // ResizeKeepRatio resizes an image keeping aspect ratio (fit within bounds)
 func (m *ImageModule) ResizeKeepRatio(src, dst string, maxWidth, maxHeight int) (bool, error) {
-	info, err := m.Info(src)
-	if err != nil {
-		return false, err
-	}
-
-	// Calculate new dimensions keeping aspect ratio
-	ratio := float64(info.Width) / float64(info.Height)
-	newWidth := maxWidth
-	newHeight := int(float64(maxWidth) / ratio)
-
-	if newHeight > maxHeight {
-		newHeight = maxHeight
-		newWidth = int(float64(maxHeight) * ratio)
-	}
-
-	return m.Resize(src, dst, newWidth, newHeight)
+	return m.Transform(src, dst, map[string]interface{}{"width": maxWidth, "height": maxHeight, "fit": string(imaging.FitContain)})
 }
 
 // Crop crops an image to the specified region
 func (m *ImageModule) Crop(src, dst string, x, y, width, height int) (bool, error) {
-	data, err := m.storage.Read(m.projectID, src)
+	img, format, err := m.decode(src)
 	if err != nil {
 		return false, err
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(data))
+	cropped, err := imaging.Crop(img, image.Rect(x, y, x+width, y+height))
 	if err != nil {
 		return false, err
 	}
 
-	bounds := img.Bounds()
-	// Validate crop bounds
-	if x < 0 || y < 0 || x+width > bounds.Dx() || y+height > bounds.Dy() {
-		return false, fmt.Errorf("crop bounds out of range")
-	}
-
-	// Create cropped image
-	cropped := image.NewRGBA(image.Rect(0, 0, width, height))
-	cropRect := image.Rect(x, y, x+width, y+height)
-	draw.Copy(cropped, image.Point{}, img, cropRect, draw.Over, nil)
-
-	// Encode to bytes
-	var buf bytes.Buffer
-	if err := m.encodeImage(&buf, cropped, format, dst); err != nil {
-		return false, err
-	}
-
-	// Write to storage
-	if err := m.storage.Write(m.projectID, dst, buf.Bytes()); err != nil {
-		return false, err
-	}
-	return true, nil
+	return m.save(dst, cropped, imaging.Options{Format: imaging.FormatFromPath(dst)}.OutputFormat(format), 0)
 }
 
 // Thumbnail creates a square thumbnail
 func (m *ImageModule) Thumbnail(src, dst string, size int) (bool, error) {
-	data, err := m.storage.Read(m.projectID, src)
-	if err != nil {
+	return m.Transform(src, dst, map[string]interface{}{"width": size, "height": size, "fit": string(imaging.FitCover)})
+}
+
+// Transform applies the CDN transform pipeline and saves the result.
+// Options: width, height, fit (cover|contain|fill), format (webp|png|jpeg),
+// quality, blur, grayscale and rotate. Without a format the destination
+// extension decides, falling back to the source format.
+func (m *ImageModule) Transform(src, dst string, options map[string]interface{}) (bool, error) {
+	opts := imaging.Options{
+		Width:     toInt(options["width"]),
+		Height:    toInt(options["height"]),
+		Fit:       imaging.Fit(toString(options["fit"])),
+		Format:    toString(options["format"]),
+		Quality:   toInt(options["quality"]),
+		Grayscale: toBool(options["grayscale"]),
+		Rotate:    toInt(options["rotate"]),
+	}
+	if blur, ok := toFloat64(options["blur"]); ok {
+		opts.Blur = blur
+	}
+	if err := opts.Validate(); err != nil {
 		return false, err
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(data))
+	img, format, err := m.decode(src)
 	if err != nil {
 		return false, err
 	}
 
-	bounds := img.Bounds()
-	w, h := bounds.Dx(), bounds.Dy()
-
-	// Calculate crop region for center square
-	var cropRect image.Rectangle
-	if w > h {
-		offset := (w - h) / 2
-		cropRect = image.Rect(offset, 0, offset+h, h)
-	} else {
-		offset := (h - w) / 2
-		cropRect = image.Rect(0, offset, w, offset+w)
+	if opts.Format == "" {
+		opts.Format = imaging.FormatFromPath(dst)
 	}
+	return m.save(dst, imaging.Transform(img, opts), opts.OutputFormat(format), opts.Quality)
+}
 
-	// First crop to square
-	square := image.NewRGBA(image.Rect(0, 0, cropRect.Dx(), cropRect.Dy()))
-	draw.Copy(square, image.Point{}, img, cropRect, draw.Over, nil)
-
-	// Then resize to target size
-	thumb := image.NewRGBA(image.Rect(0, 0, size, size))
-	draw.CatmullRom.Scale(thumb, thumb.Bounds(), square, square.Bounds(), draw.Over, nil)
+func (m *ImageModule) decode(path string) (image.Image, string, error) {
+	data, err := m.storage.Read(m.projectID, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
 
-	// Encode to bytes
+// save encodes an image and writes it to storage
+func (m *ImageModule) save(dst string, img image.Image, format string, quality int) (bool, error) {
 	var buf bytes.Buffer
-	if err := m.encodeImage(&buf, thumb, format, dst); err != nil {
+	if err := imaging.Encode(&buf, img, format, quality); err != nil {
 		return false, err
 	}
 
-	// Write to storage
 	if err := m.storage.Write(m.projectID, dst, buf.Bytes()); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-// encodeImage encodes an image to the appropriate format
-func (m *ImageModule) encodeImage(buf *bytes.Buffer, img image.Image, originalFormat, dstPath string) error {
-	// Determine format from destination extension, falling back to original format
-	ext := strings.ToLower(filepath.Ext(dstPath))
-	format := originalFormat
-
-	switch ext {
-	case ".png":
-		format = "png"
-	case ".jpg", ".jpeg":
-		format = "jpeg"
-	}
-
-	switch format {
-	case "png":
-		return png.Encode(buf, img)
-	default:
-		return jpeg.Encode(buf, img, &jpeg.Options{Quality: 85})
-	}
-}
-
 // ReadAsBase64 reads an image and returns it as base64 data URI
 func (m *ImageModule) ReadAsBase64(path string) (string, error) {
 	data, err := m.storage.Read(m.projectID, path)
@@ -300,8 +227,22 @@ func init() {
 func (m *ImageModule) GetSchema() schema.ModuleSchema {
 	return schema.ModuleSchema{
 		Name:        "$image",
-		Description: "Image manipulation operations (resize, crop, thumbnail)",
+		Description: "Image manipulation operations (resize, crop, thumbnail, transform)",
 		Types: []schema.TypeSchema{
+			{
+				Name:        "ImageTransformOptions",
+				Description: "Options for $image.transform, the same pipeline the CDN uses",
+				Fields: []schema.ParamSchema{
+					{Name: "width", Type: "number", Description: "Target width, derived from the aspect ratio when omitted", Optional: true},
+					{Name: "height", Type: "number", Description: "Target height, derived from the aspect ratio when omitted", Optional: true},
+					{Name: "fit", Type: "'cover' | 'contain' | 'fill'", Description: "How the image fills the box (default: contain)", Optional: true},
+					{Name: "format", Type: "'webp' | 'png' | 'jpeg'", Description: "Output format (default: destination extension, then source format)", Optional: true},
+					{Name: "quality", Type: "number", Description: "JPEG quality 1-100 (default: 85), WebP is lossless", Optional: true},
+					{Name: "blur", Type: "number", Description: "Gaussian blur sigma in pixels", Optional: true},
+					{Name: "grayscale", Type: "boolean", Description: "Convert to grayscale", Optional: true},
+					{Name: "rotate", Type: "90 | 180 | 270", Description: "Clockwise rotation in degrees", Optional: true},
+				},
+			},
 			{
 				Name:        "ImageInfo",
 				Description: "Information about an image",
@@ -364,6 +305,16 @@ func (m *ImageModule) GetSchema() schema.ModuleSchema {
 				},
 				Returns: &schema.ParamSchema{Type: "boolean"},
 			},
+			{
+				Name:        "transform",
+				Description: "Resize, crop to fit, blur, grayscale, rotate and convert an image",
+				Params: []schema.ParamSchema{
+					{Name: "src", Type: "string", Description: "Source image path"},
+					{Name: "dst", Type: "string", Description: "Destination image path"},
+					{Name: "options", Type: "ImageTransformOptions", Description: "Transform options"},
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
 			{
 				Name:        "readAsBase64",
 				Description: "Read image as base64 data URI",
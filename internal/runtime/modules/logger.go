@@ -5,20 +5,33 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/pkg/schema"
 )
 
 // LogCallback is called when new log is written
 type LogCallback func()
 
+// LogScope identifies the route request, job run or hook call a record was
+// written by
+type LogScope struct {
+	Source    string
+	RequestID string
+}
+
 type LoggerModule struct {
 	file        *os.File
+	path        string
+	size        int64
+	maxSize     int64
 	mu          sync.Mutex
 	onLogFunc   LogCallback
+	onClose     func()
 	notifyTimer *time.Timer
 }
 
@@ -28,7 +41,16 @@ func NewLoggerModule(logPath string) *LoggerModule {
 		// Fallback to stdout if can't create file
 		return &LoggerModule{file: os.Stdout}
 	}
-	return &LoggerModule{file: file}
+	l := &LoggerModule{file: file, path: logPath}
+	if info, err := file.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	return l
+}
+
+// RotatedLogPath returns the name a log file is renamed to when it is rotated at t
+func RotatedLogPath(path string, t time.Time) string {
+	return strings.TrimSuffix(path, ".log") + "." + t.UTC().Format("20060102-150405.000") + ".log"
 }
 
 // Name returns the module name for JavaScript
@@ -39,13 +61,7 @@ func (l *LoggerModule) Name() string {
 // Register registers the module into the JavaScript VM
 func (l *LoggerModule) Register(vm interface{}) {
 	v := vm.(*goja.Runtime)
-	methods := map[string]interface{}{
-		"debug": l.Debug,
-		"info":  l.Info,
-		"warn":  l.Warn,
-		"error": l.Error,
-	}
-	v.Set(l.Name(), methods)
+	v.Set(l.Name(), l.methods(LogScope{}))
 
 	// Also register console as an alias
 	v.Set("console", map[string]interface{}{
@@ -64,15 +80,91 @@ func (l *LoggerModule) SetOnLog(callback LogCallback) {
 	l.onLogFunc = callback
 }
 
-func (l *LoggerModule) log(level string, args ...interface{}) {
+// SetMaxFileSize enables size based rotation: once the log file would grow
+// beyond maxSize bytes it is renamed and a fresh file is opened at the same path
+func (l *LoggerModule) SetMaxFileSize(maxSize int64) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.maxSize = maxSize
+}
+
+// SetOnClose sets a callback that is called once when the log file is closed
+func (l *LoggerModule) SetOnClose(callback func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onClose = callback
+}
 
-	timestamp := time.Now().UTC().Format("2006-01-02 15:04:05")
-	message := formatArgs(args...)
-	logLine := fmt.Sprintf("[%s] [%s] %s\n", timestamp, level, message)
+// Scoped returns a logger object with the $logger methods whose records are
+// attributed to source and requestID. Routes, jobs and hooks hand one to each
+// invocation as ctx.logger.
+func (l *LoggerModule) Scoped(source, requestID string) map[string]interface{} {
+	if l == nil {
+		discard := func(args ...interface{}) {}
+		return map[string]interface{}{"debug": discard, "info": discard, "warn": discard, "error": discard}
+	}
+	return l.methods(LogScope{Source: source, RequestID: requestID})
+}
+
+func (l *LoggerModule) methods(scope LogScope) map[string]interface{} {
+	return map[string]interface{}{
+		"debug": l.structured(scope, domain.LogLevelDebug),
+		"info":  l.structured(scope, domain.LogLevelInfo),
+		"warn":  l.structured(scope, domain.LogLevelWarn),
+		"error": l.structured(scope, domain.LogLevelError),
+	}
+}
 
-	l.file.WriteString(logLine)
+// structured returns a $logger method: a trailing plain object after the
+// message is stored as record fields instead of being appended to the text
+func (l *LoggerModule) structured(scope LogScope, level domain.LogLevel) func(args ...interface{}) {
+	return func(args ...interface{}) {
+		if len(args) >= 2 {
+			if fields, ok := args[len(args)-1].(map[string]interface{}); ok {
+				l.write(scope, level, formatArgs(args[:len(args)-1]...), fields)
+				return
+			}
+		}
+		l.write(scope, level, formatArgs(args...), nil)
+	}
+}
+
+func (l *LoggerModule) log(level domain.LogLevel, args ...interface{}) {
+	l.write(LogScope{}, level, formatArgs(args...), nil)
+}
+
+func (l *LoggerModule) write(scope LogScope, level domain.LogLevel, message string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := domain.LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     level,
+		Message:   message,
+		Source:    scope.Source,
+		RequestID: scope.RequestID,
+		Fields:    fields,
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// Fields that cannot be serialized are rendered into the message
+		entry.Message = message + " " + fmt.Sprint(fields)
+		entry.Fields = nil
+		line, _ = json.Marshal(entry)
+	}
+
+	if l.file == nil {
+		return
+	}
+
+	line = append(line, '\n')
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		l.rotate()
+	}
+
+	n, _ := l.file.Write(line)
+	l.size += int64(n)
 	l.file.Sync() // Flush buffer to disk immediately
 
 	if l.onLogFunc != nil {
@@ -143,59 +235,114 @@ func formatValue(v interface{}) string {
 }
 
 func (l *LoggerModule) Debug(args ...interface{}) {
-	l.log("DEBUG", args...)
+	l.log(domain.LogLevelDebug, args...)
 }
 
 func (l *LoggerModule) Info(args ...interface{}) {
-	l.log("INFO", args...)
+	l.log(domain.LogLevelInfo, args...)
 }
 
 func (l *LoggerModule) Warn(args ...interface{}) {
-	l.log("WARN", args...)
+	l.log(domain.LogLevelWarn, args...)
 }
 
 func (l *LoggerModule) Error(args ...interface{}) {
-	l.log("ERROR", args...)
+	l.log(domain.LogLevelError, args...)
+}
+
+// rotate renames the current log file and reopens an empty one at the same path.
+// Must be called with l.mu held.
+func (l *LoggerModule) rotate() {
+	if l.path == "" {
+		return
+	}
+	l.file.Close()
+
+	// Several rotations can happen within the same millisecond under heavy logging
+	now := time.Now()
+	target := RotatedLogPath(l.path, now)
+	for i := 1; fileExists(target); i++ {
+		target = strings.TrimSuffix(RotatedLogPath(l.path, now), ".log") + fmt.Sprintf("-%d.log", i)
+	}
+	os.Rename(l.path, target)
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		l.file = os.Stdout
+		l.path = ""
+		return
+	}
+	l.file = file
+	l.size = 0
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func (l *LoggerModule) Close() {
-	if l.file != nil && l.file != os.Stdout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+	if l.file != os.Stdout {
 		l.file.Close()
 	}
+	l.file = nil
+
+	if l.onClose != nil {
+		l.onClose()
+		l.onClose = nil
+	}
 }
 
 // GetSchema implements JSSchemaProvider
 func (l *LoggerModule) GetSchema() schema.ModuleSchema {
 	return schema.ModuleSchema{
 		Name:        "$logger",
-		Description: "Logging utilities for debugging and monitoring",
+		Description: "Structured logging. Records carry level, timestamp and optional fields; records written through ctx.logger in routes, jobs and hooks also carry their source and request id",
+		Types: []schema.TypeSchema{
+			{
+				Name:        "Logger",
+				Description: "Logger handed to route, job and hook handlers as ctx.logger",
+				Fields: []schema.ParamSchema{
+					{Name: "debug", Type: "(...args: any[]) => void", Description: "Log a debug message"},
+					{Name: "info", Type: "(...args: any[]) => void", Description: "Log an info message, optionally followed by a fields object"},
+					{Name: "warn", Type: "(...args: any[]) => void", Description: "Log a warning message"},
+					{Name: "error", Type: "(...args: any[]) => void", Description: "Log an error message"},
+				},
+			},
+		},
 		Methods: []schema.MethodSchema{
 			{
 				Name:        "debug",
 				Description: "Log a debug message",
 				Params: []schema.ParamSchema{
-					{Name: "args", Type: "any", Description: "Values to log", Variadic: true},
+					{Name: "args", Type: "any", Description: "Values to log, optionally followed by a fields object", Variadic: true},
 				},
 			},
 			{
 				Name:        "info",
-				Description: "Log an info message",
+				Description: "Log an info message. A trailing object after the message is stored as structured fields, e.g. $logger.info('order paid', {orderId: 1})",
 				Params: []schema.ParamSchema{
-					{Name: "args", Type: "any", Description: "Values to log", Variadic: true},
+					{Name: "args", Type: "any", Description: "Values to log, optionally followed by a fields object", Variadic: true},
 				},
 			},
 			{
 				Name:        "warn",
 				Description: "Log a warning message",
 				Params: []schema.ParamSchema{
-					{Name: "args", Type: "any", Description: "Values to log", Variadic: true},
+					{Name: "args", Type: "any", Description: "Values to log, optionally followed by a fields object", Variadic: true},
 				},
 			},
 			{
 				Name:        "error",
 				Description: "Log an error message",
 				Params: []schema.ParamSchema{
-					{Name: "args", Type: "any", Description: "Values to log", Variadic: true},
+					{Name: "args", Type: "any", Description: "Values to log, optionally followed by a fields object", Variadic: true},
 				},
 			},
 		},
@@ -0,0 +1,97 @@
+package modules
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing shared by $auth and $crypto. Hashes are self-describing,
+// so verifying needs no algorithm.
+
+const (
+	PasswordAlgoBcrypt   = "bcrypt"
+	PasswordAlgoArgon2id = "argon2id"
+)
+
+// argon2id parameters, the OWASP recommended minimum
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var errUnknownPasswordAlgo = errors.New("unknown password hash algorithm, expected bcrypt or argon2id")
+
+// hashPassword hashes a password with bcrypt (the default) or argon2id, the
+// latter in the PHC string format
+func hashPassword(password, algo string) (string, error) {
+	switch algo {
+	case "", PasswordAlgoBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		return string(hash), err
+	case PasswordAlgoArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	default:
+		return "", errUnknownPasswordAlgo
+	}
+}
+
+// verifyPassword reports whether a password matches a bcrypt or argon2id
+// hash; malformed hashes never match
+func verifyPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return verifyArgon2id(password, hash)
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func verifyArgon2id(password, hash string) bool {
+	// $argon2id$v=19$m=19456,t=2,p=1$salt$key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	// argon2 panics on zero parameters; huge ones would stall the runtime
+	if memory == 0 || memory > 1024*1024 || time == 0 || time > 16 || threads == 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
@@ -1,12 +1,15 @@
 package modules
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/dop251/goja"
+	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/pkg/schema"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 // CookieOptions defines options for setting cookies
@@ -36,6 +39,8 @@ type RequestContext struct {
 	IP        string            `json:"ip"`
 	UserAgent string            `json:"userAgent"`
 	Cookies   map[string]string `json:"cookies"`
+	RequestID string            `json:"requestId"`
+	RawBody   []byte            `json:"-"` // Body as received, for signature checks
 }
 
 // ResponseType indicates special response handling
@@ -63,10 +68,12 @@ type ResponseData struct {
 type MiddlewareFunc func(ctx map[string]interface{}) (bool, error)
 
 type routeHandler struct {
+	path    string
 	pattern *regexp.Regexp
 	params  []string
 	handler goja.Callable
 	vm      *goja.Runtime
+	webhook *webhookConfig // Set for $router.webhook routes
 }
 
 type middlewareHandler struct {
@@ -92,6 +99,9 @@ type RouterModule struct {
 	hitCount    int64
 	hitsByPath  map[string]int64
 	hitsMu      sync.RWMutex
+	logger      *LoggerModule
+	webhookLog  WebhookLog
+	claims      webhookClaims
 }
 
 func NewRouterModule() *RouterModule {
@@ -114,6 +124,16 @@ func (r *RouterModule) SetVM(vm *goja.Runtime) {
 	r.vm = vm
 }
 
+// SetLogger sets the logger used to attribute records to routes
+func (r *RouterModule) SetLogger(logger *LoggerModule) {
+	r.logger = logger
+}
+
+// SetWebhookLog sets where deliveries of webhook routes are recorded
+func (r *RouterModule) SetWebhookLog(log WebhookLog) {
+	r.webhookLog = log
+}
+
 // Name returns the module name for JavaScript
 func (r *RouterModule) Name() string {
 	return "$router"
@@ -132,6 +152,7 @@ func (r *RouterModule) Register(vm interface{}) {
 		"head":    r.Head,
 		"options": r.Options,
 		"all":     r.All,
+		"webhook": r.Webhook,
 		"use":     r.Use,
 		"group":   r.Group,
 		"cors":    r.Cors,
@@ -139,6 +160,10 @@ func (r *RouterModule) Register(vm interface{}) {
 }
 
 func (r *RouterModule) addRoute(method, path string, handler goja.Callable) {
+	r.addHandler(method, path, handler, nil)
+}
+
+func (r *RouterModule) addHandler(method, path string, handler goja.Callable, webhook *webhookConfig) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -164,10 +189,12 @@ func (r *RouterModule) addRoute(method, path string, handler goja.Callable) {
 	}
 
 	r.routes[method] = append(r.routes[method], routeHandler{
+		path:    path,
 		pattern: re,
 		params:  params,
 		handler: handler,
 		vm:      r.vm,
+		webhook: webhook,
 	})
 }
 
@@ -207,6 +234,17 @@ func (r *RouterModule) All(path string, handler goja.Callable) {
 	}
 }
 
+// Webhook registers a POST handler that only runs for deliveries with a
+// valid signature, once per delivery id
+func (r *RouterModule) Webhook(path string, options *WebhookOptions, handler goja.Callable) error {
+	webhook, err := newWebhookConfig(options)
+	if err != nil {
+		return err
+	}
+	r.addHandler("POST", path, handler, webhook)
+	return nil
+}
+
 // Use adds middleware. Can be called with just handler (global) or with path prefix and handler
 func (r *RouterModule) Use(call goja.FunctionCall) goja.Value {
 	r.mu.Lock()
@@ -296,6 +334,9 @@ func (r *RouterModule) Group(call goja.FunctionCall) goja.Value {
 			r.addRoute(method, prefix+path, handler)
 		}
 	})
+	groupRouter.Set("webhook", func(path string, options *WebhookOptions, handler goja.Callable) error {
+		return r.Webhook(prefix+path, options, handler)
+	})
 
 	// Call the callback with the group router
 	callback(goja.Undefined(), groupRouter)
@@ -386,48 +427,223 @@ func (r *RouterModule) Handle(method, path string, ctx *RequestContext) (*Respon
 			}
 		}
 
-		// Create response accumulator for ctx methods
-		respAccum := &ResponseData{
-			Status:     200,
-			Headers:    make(map[string]string),
-			Type:       ResponseTypeJSON,
-			SetCookies: []SetCookieData{},
+		if h.webhook != nil {
+			return r.serveWebhook(h, path, ctx, r.webhookDelivery(h, ctx))
 		}
 
+		// Create response accumulator for ctx methods
+		respAccum := newResponseData()
+
 		// Build context map with extended properties and methods
 		ctxMap := r.buildContextMap(ctx, respAccum, h.vm)
+		ctxMap["logger"] = r.logger.Scoped("route:"+method+" "+h.path, ctx.RequestID)
+
+		return r.serve(h, path, ctxMap, respAccum)
+	}
+
+	return nil, fmt.Errorf("route not found")
+}
+
+// ReplayWebhook runs a recorded delivery through its webhook handler again,
+// skipping the signature and duplicate checks, and returns the delivery
+// recorded for the replay
+func (r *RouterModule) ReplayWebhook(original *domain.WebhookDelivery) (*domain.WebhookDelivery, error) {
+	if original.Status == domain.WebhookRejected {
+		return nil, ErrWebhookRejected
+	}
+	if original.BodyTruncated {
+		return nil, ErrWebhookTruncated
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-		// Run middleware chain
-		if !r.runMiddleware(path, ctxMap, h.vm) {
-			// Middleware returned false - abort
-			return respAccum, nil
+	for _, h := range r.routes["POST"] {
+		if h.webhook == nil || h.path != original.Webhook {
+			continue
 		}
 
-		// Call handler with context as argument
-		var result goja.Value
-		var err error
+		ctx := &RequestContext{
+			Method:    "POST",
+			Path:      original.Path,
+			Params:    make(map[string]string),
+			Query:     make(map[string]string),
+			Headers:   original.Headers,
+			Body:      string(original.Body),
+			Cookies:   make(map[string]string),
+			RequestID: "replay-" + original.ID.Hex(),
+			RawBody:   original.Body,
+		}
+		if matches := h.pattern.FindStringSubmatch(original.Path); matches != nil {
+			for i, param := range h.params {
+				if i+1 < len(matches) {
+					ctx.Params[param] = matches[i+1]
+				}
+			}
+		}
 
-		if h.vm != nil {
-			ctxValue := h.vm.ToValue(ctxMap)
-			result, err = h.handler(goja.Undefined(), ctxValue)
-		} else {
-			result, err = h.handler(goja.Undefined())
+		delivery := r.webhookDelivery(h, ctx)
+		delivery.ReplayOf = &original.ID
+		delivery.DeliveryID = original.DeliveryID
+		r.serveWebhook(h, original.Path, ctx, delivery)
+		return delivery, nil
+	}
+
+	return nil, ErrWebhookNotRegistered
+}
+
+// serve runs the middleware chain and the handler of a route
+func (r *RouterModule) serve(h routeHandler, path string, ctxMap map[string]interface{}, respAccum *ResponseData) (*ResponseData, error) {
+	// Run middleware chain
+	if !r.runMiddleware(path, ctxMap, h.vm) {
+		// Middleware returned false - abort
+		return respAccum, nil
+	}
+
+	// Call handler with context as argument
+	var result goja.Value
+	var err error
+
+	if h.vm != nil {
+		ctxValue := h.vm.ToValue(ctxMap)
+		result, err = h.handler(goja.Undefined(), ctxValue)
+	} else {
+		result, err = h.handler(goja.Undefined())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// If respAccum was modified by ctx methods (redirect, file, etc.), use it
+	if respAccum.Type != ResponseTypeJSON || respAccum.RedirectURL != "" || respAccum.FilePath != "" {
+		return respAccum, nil
+	}
+
+	// Parse result from handler return value
+	return r.parseHandlerResult(result, respAccum)
+}
+
+// webhookDelivery starts the log record of a webhook request
+func (r *RouterModule) webhookDelivery(h routeHandler, ctx *RequestContext) *domain.WebhookDelivery {
+	body := ctx.RawBody
+	if body == nil {
+		if s, ok := ctx.Body.(string); ok {
+			body = []byte(s)
 		}
+	}
+	return &domain.WebhookDelivery{
+		Webhook:   h.path,
+		Provider:  h.webhook.provider,
+		Method:    ctx.Method,
+		Path:      ctx.Path,
+		Headers:   h.webhook.redact(ctx.Headers),
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+}
 
+// serveWebhook verifies a delivery, claims its id and runs the handler,
+// then records the outcome. Replays are neither verified nor claimed.
+func (r *RouterModule) serveWebhook(h routeHandler, path string, ctx *RequestContext, delivery *domain.WebhookDelivery) (*ResponseData, error) {
+	webhook := h.webhook
+	replay := delivery.ReplayOf != nil
+	respAccum := newResponseData()
+
+	var timestamp int64
+	if replay {
+		timestamp = webhook.timestamp(ctx.Headers)
+	} else {
+		var err error
+		timestamp, err = webhook.verify(ctx.Headers, delivery.Body, delivery.CreatedAt)
 		if err != nil {
-			return nil, err
+			delivery.Status = domain.WebhookRejected
+			delivery.Error = err.Error()
+			respAccum.Status = 401
+			respAccum.Body = map[string]interface{}{"error": "invalid signature"}
+			return r.finishWebhook(delivery, respAccum, nil)
 		}
+		delivery.DeliveryID = webhook.deliveryID(ctx.Headers, delivery.Body)
+	}
+
+	claim := h.path + "\x00" + delivery.DeliveryID
+	if delivery.DeliveryID != "" && !replay && !r.claimWebhook(h.path, delivery.DeliveryID, claim) {
+		delivery.Status = domain.WebhookDuplicate
+		respAccum.Body = map[string]interface{}{"duplicate": true}
+		return r.finishWebhook(delivery, respAccum, nil)
+	}
 
-		// If respAccum was modified by ctx methods (redirect, file, etc.), use it
-		if respAccum.Type != ResponseTypeJSON || respAccum.RedirectURL != "" || respAccum.FilePath != "" {
-			return respAccum, nil
+	var payload interface{}
+	if err := json.Unmarshal(delivery.Body, &payload); err != nil {
+		payload = nil
+	}
+	ctxMap := r.buildContextMap(ctx, respAccum, h.vm)
+	ctxMap["logger"] = r.logger.Scoped("route:POST "+h.path, ctx.RequestID)
+	ctxMap["webhook"] = map[string]interface{}{
+		"provider":  webhook.provider,
+		"id":        delivery.DeliveryID,
+		"timestamp": timestamp,
+		"replay":    replay,
+		"payload":   payload,
+	}
+	if h.vm != nil {
+		ctxMap["rawBody"] = h.vm.NewArrayBuffer(delivery.Body)
+	}
+
+	resp, err := r.serve(h, path, ctxMap, respAccum)
+	switch {
+	case err != nil:
+		delivery.Status = domain.WebhookFailed
+		delivery.Error = err.Error()
+	case resp.Status >= 500:
+		delivery.Status = domain.WebhookFailed
+	default:
+		delivery.Status = domain.WebhookDelivered
+	}
+
+	if delivery.DeliveryID != "" {
+		if delivery.Status == domain.WebhookFailed && !replay {
+			// Let the provider's retry through
+			r.claims.release(claim)
+		} else if delivery.Status == domain.WebhookDelivered && replay {
+			r.claims.claim(claim, time.Now())
 		}
+	}
+	return r.finishWebhook(delivery, resp, err)
+}
 
-		// Parse result from handler return value
-		return r.parseHandlerResult(result, respAccum)
+// claimWebhook reserves a delivery id, false when it was already handled
+// since the start or, per the delivery log, before it
+func (r *RouterModule) claimWebhook(webhook, deliveryID, claim string) bool {
+	if !r.claims.claim(claim, time.Now()) {
+		return false
+	}
+	if r.webhookLog != nil && r.webhookLog.Delivered(webhook, deliveryID) {
+		return false
 	}
+	return true
+}
 
-	return nil, fmt.Errorf("route not found")
+func (r *RouterModule) finishWebhook(delivery *domain.WebhookDelivery, resp *ResponseData, err error) (*ResponseData, error) {
+	delivery.DurationMs = time.Since(delivery.CreatedAt).Milliseconds()
+	if err != nil {
+		delivery.ResponseStatus = 500
+	} else {
+		delivery.ResponseStatus = resp.Status
+	}
+	if r.webhookLog != nil {
+		r.webhookLog.Record(delivery)
+	}
+	return resp, err
+}
+
+func newResponseData() *ResponseData {
+	return &ResponseData{
+		Status:     200,
+		Headers:    make(map[string]string),
+		Type:       ResponseTypeJSON,
+		SetCookies: []SetCookieData{},
+	}
 }
 
 // buildContextMap creates the JS context object with extended properties and methods
@@ -442,6 +658,7 @@ func (r *RouterModule) buildContextMap(ctx *RequestContext, respAccum *ResponseD
 		"ip":        ctx.IP,
 		"userAgent": ctx.UserAgent,
 		"cookies":   ctx.Cookies,
+		"requestId": ctx.RequestID,
 	}
 
 	// Add setCookie method
@@ -769,6 +986,34 @@ func (r *RouterModule) GetSchema() schema.ModuleSchema {
 					{Name: "redirect", Type: "(url: string, code?: number) => void", Description: "Redirect to URL (default code: 302)"},
 					{Name: "response", Type: "(status: number, body: any, headers?: { [key: string]: string }) => ResponseData", Description: "Create and send response"},
 					{Name: "file", Type: "(path: string) => void", Description: "Serve a file from storage"},
+					{Name: "logger", Type: "Logger", Description: "Logger attributing records to this route and request"},
+					{Name: "webhook", Type: "WebhookEvent", Description: "Verified delivery (webhook routes only)", Optional: true},
+					{Name: "rawBody", Type: "ArrayBuffer", Description: "Body as received (webhook routes only)", Optional: true},
+				},
+			},
+			{
+				Name:        "WebhookOptions",
+				Description: "Signature verification of a webhook route",
+				Fields: []schema.ParamSchema{
+					{Name: "provider", Type: "'stripe' | 'github' | 'telegram' | 'hmac'", Description: "Verification preset (default: hmac)", Optional: true},
+					{Name: "secret", Type: "string", Description: "Signing secret, or the Telegram secret token"},
+					{Name: "header", Type: "string", Description: "Signature header (default: the preset's, X-Signature for hmac)", Optional: true},
+					{Name: "algo", Type: "'sha1' | 'sha256' | 'sha512'", Description: "HMAC hash for hmac (default: sha256)", Optional: true},
+					{Name: "encoding", Type: "'hex' | 'base64'", Description: "Signature encoding for hmac (default: hex)", Optional: true},
+					{Name: "tolerance", Type: "number", Description: "Seconds a signed timestamp may be off (default: 300)", Optional: true},
+					{Name: "timestampHeader", Type: "string", Description: "For hmac: header with a Unix timestamp, signed as \"timestamp.body\"", Optional: true},
+					{Name: "idHeader", Type: "string", Description: "For hmac: header with the delivery id, handled once", Optional: true},
+				},
+			},
+			{
+				Name:        "WebhookEvent",
+				Description: "A verified webhook delivery",
+				Fields: []schema.ParamSchema{
+					{Name: "provider", Type: "string", Description: "Verification preset"},
+					{Name: "id", Type: "string", Description: "Delivery id, empty when the provider sends none"},
+					{Name: "timestamp", Type: "number", Description: "Signed Unix timestamp, 0 when there is none"},
+					{Name: "replay", Type: "boolean", Description: "Replayed from the delivery log"},
+					{Name: "payload", Type: "any", Description: "Body parsed as JSON, null otherwise"},
 				},
 			},
 			{
@@ -792,6 +1037,7 @@ func (r *RouterModule) GetSchema() schema.ModuleSchema {
 					{Name: "head", Type: "(path: string, handler: RouteHandler) => void", Description: "Register HEAD route"},
 					{Name: "options", Type: "(path: string, handler: RouteHandler) => void", Description: "Register OPTIONS route"},
 					{Name: "all", Type: "(path: string, handler: RouteHandler) => void", Description: "Register handler for all methods"},
+					{Name: "webhook", Type: "(path: string, options: WebhookOptions, handler: RouteHandler) => void", Description: "Register a verified webhook"},
 				},
 			},
 		},
@@ -860,6 +1106,15 @@ func (r *RouterModule) GetSchema() schema.ModuleSchema {
 					{Name: "handler", Type: "(ctx: RequestContext) => ResponseData | any", Description: "Route handler function"},
 				},
 			},
+			{
+				Name:        "webhook",
+				Description: "Register a POST handler for signed webhooks. Invalid signatures are answered 401, repeated delivery ids 200 without running the handler; deliveries are logged and can be replayed",
+				Params: []schema.ParamSchema{
+					{Name: "path", Type: "string", Description: "URL path pattern (supports :param)"},
+					{Name: "options", Type: "WebhookOptions", Description: "Verification preset or secret"},
+					{Name: "handler", Type: "(ctx: RequestContext & { webhook: WebhookEvent, rawBody: ArrayBuffer }) => ResponseData | any", Description: "Route handler function"},
+				},
+			},
 			{
 				Name:        "use",
 				Description: "Add middleware (global or path-based)",
@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"regexp"
@@ -29,6 +30,7 @@ type JobInfo struct {
 	ID             string        `json:"id"`
 	Type           string        `json:"type"` // "cron", "interval", "once", "delay"
 	Expression     string        `json:"expression,omitempty"`
+	Timezone       string        `json:"timezone,omitempty"` // IANA name the cron expression runs in
 	Interval       string        `json:"interval,omitempty"`
 	Status         JobStatus     `json:"status"`
 	LastRun        *time.Time    `json:"lastRun,omitempty"`
@@ -45,6 +47,7 @@ type JobOptions struct {
 	Timeout       time.Duration `json:"timeout"`
 	LockName      string        `json:"lockName"`
 	LockTimeout   time.Duration `json:"lockTimeout"`
+	Timezone      string        `json:"timezone"` // Overrides the project timezone for cron jobs
 }
 
 // internalJob wraps job information with internal state
@@ -64,17 +67,31 @@ type ScheduleModule struct {
 	mu             sync.Mutex
 	started        bool
 	logger         *slog.Logger
+	jsLogger       *LoggerModule
+	vm             *goja.Runtime
+	location       *time.Location
 	executionCount int64
 }
 
 func NewScheduleModule(logger *slog.Logger) *ScheduleModule {
 	return &ScheduleModule{
-		cron:   cron.New(cron.WithLocation(time.UTC)),
-		jobs:   make(map[string]*internalJob),
-		logger: logger,
+		cron:     cron.New(cron.WithLocation(time.UTC)),
+		jobs:     make(map[string]*internalJob),
+		logger:   logger,
+		location: time.UTC,
 	}
 }
 
+// SetLocation sets the project timezone cron jobs and presets run in
+func (s *ScheduleModule) SetLocation(loc *time.Location) {
+	s.location = loc
+}
+
+// SetLogger sets the project logger used to attribute records to jobs
+func (s *ScheduleModule) SetLogger(logger *LoggerModule) {
+	s.jsLogger = logger
+}
+
 // generateJobID generates a unique job ID
 func (s *ScheduleModule) generateJobID() string {
 	id := atomic.AddInt64(&s.jobCounter, 1)
@@ -86,6 +103,18 @@ func (s *ScheduleModule) addCronJob(spec string, handler goja.Callable, opts *Jo
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	timezone := s.location.String()
+	if opts != nil && opts.Timezone != "" {
+		if _, err := time.LoadLocation(opts.Timezone); err != nil {
+			s.logger.Error("Invalid job timezone", "timezone", opts.Timezone, "error", err)
+			return ""
+		}
+		timezone = opts.Timezone
+	}
+	if hasCronTimezone(spec) {
+		timezone = ""
+	}
+
 	jobID := s.generateJobID()
 
 	job := &internalJob{
@@ -93,6 +122,7 @@ func (s *ScheduleModule) addCronJob(spec string, handler goja.Callable, opts *Jo
 			ID:            jobID,
 			Type:          "cron",
 			Expression:    spec,
+			Timezone:      timezone,
 			Status:        JobStatusActive,
 			SkipIfRunning: opts != nil && opts.SkipIfRunning,
 			Timeout:       0,
@@ -105,7 +135,7 @@ func (s *ScheduleModule) addCronJob(spec string, handler goja.Callable, opts *Jo
 		job.info.Timeout = opts.Timeout
 	}
 
-	cronID, err := s.cron.AddFunc(spec, func() {
+	cronID, err := s.cron.AddFunc(cronSpec(job.info), func() {
 		s.executeJob(job)
 	})
 
@@ -153,13 +183,24 @@ func (s *ScheduleModule) executeJob(job *internalJob) {
 	now := time.Now().UTC()
 	job.info.LastRun = &now
 
+	ctx := goja.Undefined()
+	if s.vm != nil {
+		ctx = s.vm.ToValue(map[string]interface{}{
+			"id":     job.info.ID,
+			"logger": s.jsLogger.Scoped("job:"+job.info.ID, ""),
+		})
+	}
+	run := func() error {
+		_, err := job.handler(nil, ctx)
+		return err
+	}
+
 	// Execute with timeout if specified
 	if job.info.Timeout > 0 {
 		done := make(chan struct{})
 		go func() {
 			defer close(done)
-			_, err := job.handler(nil, nil)
-			if err != nil {
+			if err := run(); err != nil {
 				s.logger.Error("Scheduled job error", "jobID", job.info.ID, "error", err)
 			}
 		}()
@@ -171,8 +212,7 @@ func (s *ScheduleModule) executeJob(job *internalJob) {
 			s.logger.Error("Scheduled job timeout", "jobID", job.info.ID, "timeout", job.info.Timeout)
 		}
 	} else {
-		_, err := job.handler(nil, nil)
-		if err != nil {
+		if err := run(); err != nil {
 			s.logger.Error("Scheduled job error", "jobID", job.info.ID, "error", err)
 		}
 	}
@@ -187,6 +227,19 @@ func (s *ScheduleModule) executeJob(job *internalJob) {
 	}
 }
 
+// hasCronTimezone reports whether a cron expression sets its own timezone
+func hasCronTimezone(spec string) bool {
+	return strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=")
+}
+
+// cronSpec returns the expression of a cron job prefixed with its timezone
+func cronSpec(info JobInfo) string {
+	if info.Timezone == "" {
+		return info.Expression
+	}
+	return "CRON_TZ=" + info.Timezone + " " + info.Expression
+}
+
 // parseDuration parses duration strings like "5m", "2h", "1d"
 func parseDuration(s string) (time.Duration, error) {
 	re := regexp.MustCompile(`^(\d+)([smhd])$`)
@@ -241,51 +294,57 @@ func (s *ScheduleModule) parseOptions(vm *goja.Runtime, val goja.Value) *JobOpti
 		opts.LockTimeout = time.Duration(lockTimeoutVal.ToInteger()) * time.Millisecond
 	}
 
+	if timezoneVal := obj.Get("timezone"); timezoneVal != nil && !goja.IsUndefined(timezoneVal) {
+		opts.Timezone = timezoneVal.String()
+	}
+
 	return opts
 }
 
 // ================== Preset Methods ==================
 
+// Presets run in the project timezone unless opts.Timezone overrides it
+
 // Daily schedules a job to run at midnight every day
-func (s *ScheduleModule) Daily(handler goja.Callable) string {
-	return s.addCronJob("0 0 * * *", handler, nil)
+func (s *ScheduleModule) Daily(handler goja.Callable, opts *JobOptions) string {
+	return s.addCronJob("0 0 * * *", handler, opts)
 }
 
 // Hourly schedules a job to run at the start of every hour
-func (s *ScheduleModule) Hourly(handler goja.Callable) string {
-	return s.addCronJob("0 * * * *", handler, nil)
+func (s *ScheduleModule) Hourly(handler goja.Callable, opts *JobOptions) string {
+	return s.addCronJob("0 * * * *", handler, opts)
 }
 
 // Minutely schedules a job to run every minute
-func (s *ScheduleModule) Minutely(handler goja.Callable) string {
-	return s.addCronJob("* * * * *", handler, nil)
+func (s *ScheduleModule) Minutely(handler goja.Callable, opts *JobOptions) string {
+	return s.addCronJob("* * * * *", handler, opts)
 }
 
 // Weekly schedules a job to run weekly on a specific day
 // dayOfWeek: 0 = Sunday, 1 = Monday, ..., 6 = Saturday
-func (s *ScheduleModule) Weekly(dayOfWeek int, handler goja.Callable) string {
+func (s *ScheduleModule) Weekly(dayOfWeek int, handler goja.Callable, opts *JobOptions) string {
 	if dayOfWeek < 0 || dayOfWeek > 6 {
 		s.logger.Error("Invalid day of week", "dayOfWeek", dayOfWeek)
 		return ""
 	}
 	spec := fmt.Sprintf("0 0 * * %d", dayOfWeek)
-	return s.addCronJob(spec, handler, nil)
+	return s.addCronJob(spec, handler, opts)
 }
 
 // Monthly schedules a job to run monthly on a specific day
 // dayOfMonth: 1-31
-func (s *ScheduleModule) Monthly(dayOfMonth int, handler goja.Callable) string {
+func (s *ScheduleModule) Monthly(dayOfMonth int, handler goja.Callable, opts *JobOptions) string {
 	if dayOfMonth < 1 || dayOfMonth > 31 {
 		s.logger.Error("Invalid day of month", "dayOfMonth", dayOfMonth)
 		return ""
 	}
 	spec := fmt.Sprintf("0 0 %d * *", dayOfMonth)
-	return s.addCronJob(spec, handler, nil)
+	return s.addCronJob(spec, handler, opts)
 }
 
-// At schedules a job to run daily at a specific time in UTC
-// timeStr: "HH:MM" format (24-hour, UTC timezone)
-func (s *ScheduleModule) At(timeStr string, handler goja.Callable) string {
+// At schedules a job to run daily at a specific time
+// timeStr: "HH:MM" format (24-hour, project timezone)
+func (s *ScheduleModule) At(timeStr string, handler goja.Callable, opts *JobOptions) string {
 	parts := strings.Split(timeStr, ":")
 	if len(parts) != 2 {
 		s.logger.Error("Invalid time format", "time", timeStr)
@@ -301,7 +360,7 @@ func (s *ScheduleModule) At(timeStr string, handler goja.Callable) string {
 	}
 
 	spec := fmt.Sprintf("%d %d * * *", minute, hour)
-	return s.addCronJob(spec, handler, nil)
+	return s.addCronJob(spec, handler, opts)
 }
 
 // Cron schedules a job using a cron expression with optional options
@@ -364,21 +423,10 @@ func (s *ScheduleModule) Every(call goja.FunctionCall, vm *goja.Runtime) goja.Va
 		handler: handler,
 	}
 
-	// Start the interval timer
-	var runJob func()
-	runJob = func() {
-		if job.info.Status != JobStatusActive {
-			return
-		}
-		s.executeJob(job)
-		job.timer = time.AfterFunc(duration, runJob)
-		next := time.Now().UTC().Add(duration)
-		job.info.NextRun = &next
+	// Intervals registered during boot are armed by Start
+	if s.started {
+		s.armInterval(job, duration)
 	}
-
-	next := time.Now().UTC().Add(duration)
-	job.info.NextRun = &next
-	job.timer = time.AfterFunc(duration, runJob)
 	s.jobs[jobID] = job
 	s.mu.Unlock()
 
@@ -559,7 +607,7 @@ func (s *ScheduleModule) Resume(jobID string) bool {
 
 	// Re-add cron job
 	if job.info.Type == "cron" && job.info.Expression != "" {
-		cronID, err := s.cron.AddFunc(job.info.Expression, func() {
+		cronID, err := s.cron.AddFunc(cronSpec(job.info), func() {
 			s.executeJob(job)
 		})
 		if err != nil {
@@ -576,25 +624,33 @@ func (s *ScheduleModule) Resume(jobID string) bool {
 			return false
 		}
 
-		var runJob func()
-		runJob = func() {
-			if job.info.Status != JobStatusActive {
-				return
-			}
-			s.executeJob(job)
-			job.timer = time.AfterFunc(duration, runJob)
-			next := time.Now().UTC().Add(duration)
-			job.info.NextRun = &next
+		if s.started {
+			s.armInterval(job, duration)
 		}
-
-		next := time.Now().UTC().Add(duration)
-		job.info.NextRun = &next
-		job.timer = time.AfterFunc(duration, runJob)
 	}
 
 	return true
 }
 
+// armInterval schedules the next run of an interval job; the run re-arms it
+// while the scheduler is started. Must be called with s.mu held.
+func (s *ScheduleModule) armInterval(job *internalJob, duration time.Duration) {
+	next := time.Now().UTC().Add(duration)
+	job.info.NextRun = &next
+	job.timer = time.AfterFunc(duration, func() {
+		if job.info.Status != JobStatusActive {
+			return
+		}
+		s.executeJob(job)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.started && job.info.Status == JobStatusActive {
+			s.armInterval(job, duration)
+		}
+	})
+}
+
 // List returns information about all scheduled jobs
 func (s *ScheduleModule) List() []map[string]interface{} {
 	s.mu.Lock()
@@ -633,6 +689,9 @@ func (s *ScheduleModule) jobToMap(job *internalJob) map[string]interface{} {
 	if job.info.Expression != "" {
 		result["expression"] = job.info.Expression
 	}
+	if job.info.Timezone != "" {
+		result["timezone"] = job.info.Timezone
+	}
 	if job.info.Interval != "" {
 		result["interval"] = job.info.Interval
 	}
@@ -680,34 +739,59 @@ func (s *ScheduleModule) NextRun(jobID string) interface{} {
 
 // ================== Lifecycle Methods ==================
 
+// Start starts firing cron and interval jobs, including those registered
+// before it
 func (s *ScheduleModule) Start() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.started && len(s.jobs) > 0 {
-		s.cron.Start()
-		s.started = true
+	if s.started {
+		return
+	}
+	s.cron.Start()
+	s.started = true
+
+	for _, job := range s.jobs {
+		if job.info.Type != "interval" || job.info.Status != JobStatusActive {
+			continue
+		}
+		if duration, err := parseDuration(job.info.Interval); err == nil {
+			s.armInterval(job, duration)
+		}
+	}
+
+	if len(s.jobs) > 0 {
 		s.logger.Info("Scheduler started", "jobs", len(s.jobs))
 	}
 }
 
-func (s *ScheduleModule) Stop() {
+// Stop stops firing jobs. The returned context is done once the cron jobs
+// already running have finished.
+func (s *ScheduleModule) Stop() context.Context {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.started {
-		s.cron.Stop()
-		s.started = false
+	if !s.started {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+
+	done := s.cron.Stop()
+	s.started = false
 
-		// Stop all timers
-		for _, job := range s.jobs {
-			if job.timer != nil {
-				job.timer.Stop()
+	// Stop all timers
+	for _, job := range s.jobs {
+		if job.timer != nil {
+			job.timer.Stop()
+			if job.info.Type == "interval" {
+				job.timer = nil
 			}
 		}
-
-		s.logger.Info("Scheduler stopped")
 	}
+
+	s.logger.Info("Scheduler stopped")
+	return done
 }
 
 // JobsCount returns the number of scheduled jobs
@@ -737,15 +821,28 @@ func (s *ScheduleModule) Name() string {
 // Register registers the module into the JavaScript VM
 func (s *ScheduleModule) Register(vm interface{}) {
 	runtime := vm.(*goja.Runtime)
+	s.vm = runtime
 
 	runtime.Set(s.Name(), map[string]interface{}{
 		// Presets
-		"daily":    s.Daily,
-		"hourly":   s.Hourly,
-		"minutely": s.Minutely,
-		"weekly":   s.Weekly,
-		"monthly":  s.Monthly,
-		"at":       s.At,
+		"daily": func(handler goja.Callable, options goja.Value) string {
+			return s.Daily(handler, s.parseOptions(runtime, options))
+		},
+		"hourly": func(handler goja.Callable, options goja.Value) string {
+			return s.Hourly(handler, s.parseOptions(runtime, options))
+		},
+		"minutely": func(handler goja.Callable, options goja.Value) string {
+			return s.Minutely(handler, s.parseOptions(runtime, options))
+		},
+		"weekly": func(dayOfWeek int, handler goja.Callable, options goja.Value) string {
+			return s.Weekly(dayOfWeek, handler, s.parseOptions(runtime, options))
+		},
+		"monthly": func(dayOfMonth int, handler goja.Callable, options goja.Value) string {
+			return s.Monthly(dayOfMonth, handler, s.parseOptions(runtime, options))
+		},
+		"at": func(timeStr string, handler goja.Callable, options goja.Value) string {
+			return s.At(timeStr, handler, s.parseOptions(runtime, options))
+		},
 
 		// Cron
 		"cron": func(call goja.FunctionCall) goja.Value {
@@ -788,6 +885,7 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 					{Name: "type", Type: "string", Description: "Job type: 'cron', 'interval', 'once', 'delay'"},
 					{Name: "status", Type: "string", Description: "Job status: 'active', 'paused', 'one-time'"},
 					{Name: "expression", Type: "string", Description: "Cron expression (for cron jobs)", Optional: true},
+					{Name: "timezone", Type: "string", Description: "IANA timezone the cron expression runs in (for cron jobs)", Optional: true},
 					{Name: "interval", Type: "string", Description: "Interval string (for interval jobs)", Optional: true},
 					{Name: "executionCount", Type: "number", Description: "Number of times the job has executed"},
 					{Name: "lastRun", Type: "number", Description: "Unix timestamp of last execution", Optional: true},
@@ -797,12 +895,21 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 					{Name: "createdAt", Type: "number", Description: "Unix timestamp when job was created"},
 				},
 			},
+			{
+				Name:        "JobContext",
+				Description: "Context passed to job handlers",
+				Fields: []schema.ParamSchema{
+					{Name: "id", Type: "string", Description: "Job identifier"},
+					{Name: "logger", Type: "Logger", Description: "Logger attributing records to this job"},
+				},
+			},
 			{
 				Name:        "ScheduleJobOptions",
 				Description: "Options for job configuration",
 				Fields: []schema.ParamSchema{
 					{Name: "skipIfRunning", Type: "boolean", Description: "Skip execution if previous run is still in progress", Optional: true},
 					{Name: "timeout", Type: "number", Description: "Maximum execution time in milliseconds", Optional: true},
+					{Name: "timezone", Type: "string", Description: "IANA timezone for cron jobs and presets, e.g. 'Europe/Moscow' (defaults to the project timezone)", Optional: true},
 				},
 			},
 		},
@@ -810,28 +917,38 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 			// Presets
 			{
 				Name:        "daily",
-				Description: "Schedule a job to run daily at midnight",
-				Params:      []schema.ParamSchema{{Name: "handler", Type: "() => void", Description: "Function to execute"}},
-				Returns:     &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
+				Description: "Schedule a job to run daily at midnight (project timezone)",
+				Params: []schema.ParamSchema{
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
 			{
 				Name:        "hourly",
 				Description: "Schedule a job to run at the start of every hour",
-				Params:      []schema.ParamSchema{{Name: "handler", Type: "() => void", Description: "Function to execute"}},
-				Returns:     &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
+				Params: []schema.ParamSchema{
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
 			{
 				Name:        "minutely",
 				Description: "Schedule a job to run every minute",
-				Params:      []schema.ParamSchema{{Name: "handler", Type: "() => void", Description: "Function to execute"}},
-				Returns:     &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
+				Params: []schema.ParamSchema{
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
 			{
 				Name:        "weekly",
 				Description: "Schedule a job to run weekly on a specific day",
 				Params: []schema.ParamSchema{
 					{Name: "dayOfWeek", Type: "number", Description: "Day of week (0=Sunday, 1=Monday, ..., 6=Saturday)"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
@@ -840,16 +957,18 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 				Description: "Schedule a job to run monthly on a specific day",
 				Params: []schema.ParamSchema{
 					{Name: "dayOfMonth", Type: "number", Description: "Day of month (1-31)"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
 			{
 				Name:        "at",
-				Description: "Schedule a job to run daily at a specific time (UTC)",
+				Description: "Schedule a job to run daily at a specific time (project timezone)",
 				Params: []schema.ParamSchema{
-					{Name: "time", Type: "string", Description: "Time in HH:MM format (24-hour, UTC timezone)"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "time", Type: "string", Description: "Time in HH:MM format (24-hour, project timezone)"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
+					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
@@ -858,8 +977,8 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 				Name:        "cron",
 				Description: "Schedule a job using a cron expression with optional options",
 				Params: []schema.ParamSchema{
-					{Name: "expression", Type: "string", Description: "Cron expression (e.g., '0 0 * * *')"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "expression", Type: "string", Description: "Cron expression (e.g., '0 0 * * *'), evaluated in the project timezone"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
 					{Name: "options", Type: "ScheduleJobOptions", Description: "Optional job options", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
@@ -870,7 +989,7 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 				Description: "Schedule a job to run at regular intervals",
 				Params: []schema.ParamSchema{
 					{Name: "interval", Type: "string", Description: "Interval (e.g., '5s', '5m', '2h', '1d')"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
@@ -880,7 +999,7 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 				Description: "Schedule a job to run once at a specific timestamp",
 				Params: []schema.ParamSchema{
 					{Name: "timestamp", Type: "number | Date", Description: "Unix timestamp in milliseconds or Date object"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
@@ -889,7 +1008,7 @@ func (s *ScheduleModule) GetSchema() schema.ModuleSchema {
 				Description: "Schedule a job to run after a delay",
 				Params: []schema.ParamSchema{
 					{Name: "ms", Type: "number", Description: "Delay in milliseconds"},
-					{Name: "handler", Type: "() => void", Description: "Function to execute"},
+					{Name: "handler", Type: "(ctx: JobContext) => void", Description: "Function to execute"},
 				},
 				Returns: &schema.ParamSchema{Name: "jobId", Type: "string", Description: "Job ID"},
 			},
@@ -2,8 +2,11 @@ package modules
 
 import (
 	"encoding/base64"
+	"sync"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/service"
 	"github.com/levskiy0/m3m/pkg/schema"
 )
@@ -11,6 +14,11 @@ import (
 type StorageModule struct {
 	storage   *service.StorageService
 	projectID string
+	vm        *goja.Runtime
+	parent    *StorageModule // Tracks the streams of a WithOrigin instance
+
+	streamsMu sync.Mutex
+	streams   map[storageStream]struct{} // open readers and writers
 }
 
 func NewStorageModule(storage *service.StorageService, projectID string) *StorageModule {
@@ -20,6 +28,18 @@ func NewStorageModule(storage *service.StorageService, projectID string) *Storag
 	}
 }
 
+// WithOrigin returns a $storage object whose changes are marked with origin,
+// e.g. the one storage handlers get so their writes do not trigger them again
+func (s *StorageModule) WithOrigin(origin domain.StorageOrigin) map[string]interface{} {
+	instance := &StorageModule{
+		storage:   s.storage.WithOrigin(origin),
+		projectID: s.projectID,
+		vm:        s.vm,
+		parent:    s,
+	}
+	return instance.methods()
+}
+
 // Name returns the module name for JavaScript
 func (s *StorageModule) Name() string {
 	return "$storage"
@@ -27,7 +47,12 @@ func (s *StorageModule) Name() string {
 
 // Register registers the module into the JavaScript VM
 func (s *StorageModule) Register(vm interface{}) {
-	vm.(*goja.Runtime).Set(s.Name(), map[string]interface{}{
+	s.vm = vm.(*goja.Runtime)
+	s.vm.Set(s.Name(), s.methods())
+}
+
+func (s *StorageModule) methods() map[string]interface{} {
+	return map[string]interface{}{
 		"read":       s.Read,
 		"readBase64": s.ReadBase64,
 		"write":      s.Write,
@@ -45,8 +70,13 @@ func (s *StorageModule) Register(vm interface{}) {
 		"rename":     s.Rename,
 		"glob":       s.Glob,
 		"getUrl":     s.GetUrl,
+		"signedUrl":  s.SignedUrl,
 		"zip":        s.Zip,
 		"unzip":      s.Unzip,
+		"open":       s.Open,
+		"openWrite":  s.OpenWrite,
+		"versions":   s.Versions,
+		"restore":    s.Restore,
 		"tmp": map[string]interface{}{
 			"read":       s.TmpRead,
 			"readBase64": s.TmpReadBase64,
@@ -65,10 +95,13 @@ func (s *StorageModule) Register(vm interface{}) {
 			"rename":     s.TmpRename,
 			"glob":       s.TmpGlob,
 			"getUrl":     s.TmpGetUrl,
+			"signedUrl":  s.TmpSignedUrl,
 			"zip":        s.TmpZip,
 			"unzip":      s.TmpUnzip,
+			"open":       s.TmpOpen,
+			"openWrite":  s.TmpOpenWrite,
 		},
-	})
+	}
 }
 
 func (s *StorageModule) Read(path string) (string, error) {
@@ -200,6 +233,35 @@ func (s *StorageModule) Stat(path string) (map[string]interface{}, error) {
 	}, nil
 }
 
+// Versions lists the kept versions of a file in a versioned directory, newest first
+func (s *StorageModule) Versions(path string) ([]map[string]interface{}, error) {
+	list, err := s.storage.Versions(s.projectID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, len(list.Versions))
+	for i, version := range list.Versions {
+		result[i] = map[string]interface{}{
+			"id":        version.ID,
+			"hash":      version.Hash,
+			"size":      version.Size,
+			"user":      version.User,
+			"reason":    string(version.Reason),
+			"createdAt": version.CreatedAt.Unix(),
+		}
+	}
+	return result, nil
+}
+
+// Restore replaces a file with one of its versions
+func (s *StorageModule) Restore(path string, versionID string) (bool, error) {
+	if err := s.storage.RestoreVersion(s.projectID, path, versionID, service.RuntimeVersionUser); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *StorageModule) TmpStat(path string) (map[string]interface{}, error) {
 	return s.Stat("tmp/" + path)
 }
@@ -284,6 +346,24 @@ func (s *StorageModule) TmpGetUrl(path string) string {
 	return s.GetUrl("tmp/" + path)
 }
 
+// SignedUrl returns an expiring CDN URL that also works for private paths.
+// Options: expiresIn (seconds, default 3600), download (use the download endpoint).
+func (s *StorageModule) SignedUrl(path string, options map[string]interface{}) string {
+	ttl := service.DefaultSignedURLTTL
+	download := false
+	if options != nil {
+		if seconds := toInt(options["expiresIn"]); seconds > 0 {
+			ttl = time.Duration(seconds) * time.Second
+		}
+		download = toBool(options["download"])
+	}
+	return s.storage.SignURL(s.projectID, path, time.Now().Add(ttl), download)
+}
+
+func (s *StorageModule) TmpSignedUrl(path string, options map[string]interface{}) string {
+	return s.SignedUrl("tmp/"+path, options)
+}
+
 func (s *StorageModule) Zip(srcPaths []string, dstPath string) (bool, error) {
 	err := s.storage.Zip(s.projectID, srcPaths, dstPath)
 	if err != nil {
@@ -318,6 +398,66 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 	return schema.ModuleSchema{
 		Name:        "$storage",
 		Description: "File storage operations for the project",
+		Types: []schema.TypeSchema{
+			{
+				Name:        "SignedUrlOptions",
+				Description: "Options for signed URLs",
+				Fields: []schema.ParamSchema{
+					{Name: "expiresIn", Type: "number", Description: "Link lifetime in seconds (default: 3600)", Optional: true},
+					{Name: "download", Type: "boolean", Description: "Serve the file as an attachment", Optional: true},
+				},
+			},
+			{
+				Name:        "StorageOpenOptions",
+				Description: "Byte range to read",
+				Fields: []schema.ParamSchema{
+					{Name: "offset", Type: "number", Description: "First byte to read (default: 0)", Optional: true},
+					{Name: "length", Type: "number", Description: "Number of bytes to read (default: to the end of the file)", Optional: true},
+				},
+			},
+			{
+				Name:        "StorageReader",
+				Description: "Streaming reader returned by $storage.open()",
+				Fields: []schema.ParamSchema{
+					{Name: "size", Type: "number", Description: "Number of bytes in the opened range"},
+					{Name: "mimeType", Type: "string", Description: "MIME type of the file"},
+					{Name: "read", Type: "(size?: number) => string | null", Description: "Read the next chunk (default 64 KB), null at the end"},
+					{Name: "readBase64", Type: "(size?: number) => string | null", Description: "Read the next chunk base64 encoded, null at the end"},
+					{Name: "readLines", Type: "(fn: (line: string, index: number) => boolean | void) => number", Description: "Call fn for every remaining line; return false to stop. Returns the number of lines read"},
+					{Name: "close", Type: "() => void", Description: "Release the file"},
+				},
+			},
+			{
+				Name:        "StorageWriteOptions",
+				Description: "Options for streaming writers",
+				Fields: []schema.ParamSchema{
+					{Name: "append", Type: "boolean", Description: "Append to the file instead of replacing it", Optional: true},
+				},
+			},
+			{
+				Name:        "StorageWriter",
+				Description: "Streaming writer returned by $storage.openWrite(). The file changes on close()",
+				Fields: []schema.ParamSchema{
+					{Name: "write", Type: "(content: string) => number", Description: "Write a string, returns the bytes written"},
+					{Name: "writeBase64", Type: "(content: string) => number", Description: "Write base64 encoded binary content"},
+					{Name: "size", Type: "() => number", Description: "Bytes written so far"},
+					{Name: "close", Type: "() => number", Description: "Store the file and return its size"},
+					{Name: "abort", Type: "() => void", Description: "Discard the written content"},
+				},
+			},
+			{
+				Name:        "StorageVersion",
+				Description: "A prior version of a file",
+				Fields: []schema.ParamSchema{
+					{Name: "id", Type: "string", Description: "Version id"},
+					{Name: "hash", Type: "string", Description: "SHA-256 of the content"},
+					{Name: "size", Type: "number", Description: "Size in bytes"},
+					{Name: "user", Type: "string", Description: "Email of the user who replaced it, or \"runtime\""},
+					{Name: "reason", Type: "string", Description: "\"overwrite\", \"delete\" or \"restore\""},
+					{Name: "createdAt", Type: "number", Description: "Unix timestamp in seconds"},
+				},
+			},
+		},
 		Methods: []schema.MethodSchema{
 			{
 				Name:        "read",
@@ -375,7 +515,7 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 			},
 			{
 				Name:        "getPath",
-				Description: "Get absolute filesystem path for a file",
+				Description: "Get absolute filesystem path for a file (local storage driver only)",
 				Params:      []schema.ParamSchema{{Name: "path", Type: "string", Description: "File path relative to project storage"}},
 				Returns:     &schema.ParamSchema{Type: "string"},
 			},
@@ -436,6 +576,15 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 				Params:      []schema.ParamSchema{{Name: "path", Type: "string", Description: "File path relative to project storage"}},
 				Returns:     &schema.ParamSchema{Type: "string"},
 			},
+			{
+				Name:        "signedUrl",
+				Description: "Get a signed, expiring URL for a file. Required for files under private paths",
+				Params: []schema.ParamSchema{
+					{Name: "path", Type: "string", Description: "File path relative to project storage"},
+					{Name: "options", Type: "SignedUrlOptions", Description: "Expiry and download options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "string"},
+			},
 			{
 				Name:        "zip",
 				Description: "Create a zip archive from files/directories",
@@ -454,6 +603,39 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 				},
 				Returns: &schema.ParamSchema{Type: "boolean"},
 			},
+			{
+				Name:        "open",
+				Description: "Open a file, or a byte range of it, for streaming reads without loading it into memory",
+				Params: []schema.ParamSchema{
+					{Name: "path", Type: "string", Description: "File path relative to project storage"},
+					{Name: "options", Type: "StorageOpenOptions", Description: "Byte range to read", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "StorageReader"},
+			},
+			{
+				Name:        "openWrite",
+				Description: "Open a streaming writer; the file is replaced (or appended to) on close()",
+				Params: []schema.ParamSchema{
+					{Name: "path", Type: "string", Description: "File path relative to project storage"},
+					{Name: "options", Type: "StorageWriteOptions", Description: "Writer options", Optional: true},
+				},
+				Returns: &schema.ParamSchema{Type: "StorageWriter"},
+			},
+			{
+				Name:        "versions",
+				Description: "List the prior versions of a file in a versioned directory, newest first",
+				Params:      []schema.ParamSchema{{Name: "path", Type: "string", Description: "File path relative to project storage"}},
+				Returns:     &schema.ParamSchema{Type: "StorageVersion[]"},
+			},
+			{
+				Name:        "restore",
+				Description: "Replace a file with one of its versions; the current content is kept as a version",
+				Params: []schema.ParamSchema{
+					{Name: "path", Type: "string", Description: "File path relative to project storage"},
+					{Name: "versionId", Type: "string", Description: "Version id from versions()"},
+				},
+				Returns: &schema.ParamSchema{Type: "boolean"},
+			},
 		},
 		Nested: []schema.NestedModuleSchema{
 			{
@@ -516,7 +698,7 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 					},
 					{
 						Name:        "getPath",
-						Description: "Get absolute filesystem path for a file in tmp storage",
+						Description: "Get absolute filesystem path for a file in tmp storage (local storage driver only)",
 						Params:      []schema.ParamSchema{{Name: "path", Type: "string", Description: "File path relative to tmp storage"}},
 						Returns:     &schema.ParamSchema{Type: "string"},
 					},
@@ -577,6 +759,15 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 						Params:      []schema.ParamSchema{{Name: "path", Type: "string", Description: "File path relative to tmp storage"}},
 						Returns:     &schema.ParamSchema{Type: "string"},
 					},
+					{
+						Name:        "signedUrl",
+						Description: "Get a signed, expiring URL for a file in tmp storage",
+						Params: []schema.ParamSchema{
+							{Name: "path", Type: "string", Description: "File path relative to tmp storage"},
+							{Name: "options", Type: "SignedUrlOptions", Description: "Expiry and download options", Optional: true},
+						},
+						Returns: &schema.ParamSchema{Type: "string"},
+					},
 					{
 						Name:        "zip",
 						Description: "Create a zip archive from files/directories in tmp storage",
@@ -595,6 +786,24 @@ func (s *StorageModule) GetSchema() schema.ModuleSchema {
 						},
 						Returns: &schema.ParamSchema{Type: "boolean"},
 					},
+					{
+						Name:        "open",
+						Description: "Open a tmp file for streaming reads",
+						Params: []schema.ParamSchema{
+							{Name: "path", Type: "string", Description: "File path relative to tmp storage"},
+							{Name: "options", Type: "StorageOpenOptions", Description: "Byte range to read", Optional: true},
+						},
+						Returns: &schema.ParamSchema{Type: "StorageReader"},
+					},
+					{
+						Name:        "openWrite",
+						Description: "Open a streaming writer for a tmp file",
+						Params: []schema.ParamSchema{
+							{Name: "path", Type: "string", Description: "File path relative to tmp storage"},
+							{Name: "options", Type: "StorageWriteOptions", Description: "Writer options", Optional: true},
+						},
+						Returns: &schema.ParamSchema{Type: "StorageWriter"},
+					},
 				},
 			},
 		},
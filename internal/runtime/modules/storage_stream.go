@@ -0,0 +1,271 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/levskiy0/m3m/internal/service"
+)
+
+// defaultChunkSize is read by StorageReader.read() when no size is given
+const defaultChunkSize = 64 * 1024
+
+// StorageOpenOptions selects a byte range of a file to read
+type StorageOpenOptions struct {
+	Offset int64  `json:"offset"`
+	Length *int64 `json:"length"` // default: to the end of the file
+}
+
+// StorageWriteOptions configures a streaming writer
+type StorageWriteOptions struct {
+	Append bool `json:"append"`
+}
+
+// storageStream is an open reader or writer, released when the runtime stops
+type storageStream interface {
+	release()
+}
+
+// StorageReader reads a file of project storage in chunks or lines without
+// loading it into memory
+type StorageReader struct {
+	module *StorageModule
+	object *service.StorageObject
+	reader *bufio.Reader
+	closed bool
+}
+
+// StorageWriter writes a file of project storage in chunks. The file is
+// replaced (or appended to) on close(); abort() discards the content.
+type StorageWriter struct {
+	module *StorageModule
+	writer *service.StorageWriter
+}
+
+func (s *StorageModule) track(stream storageStream) {
+	if s.parent != nil {
+		s.parent.track(stream)
+		return
+	}
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if s.streams == nil {
+		s.streams = make(map[storageStream]struct{})
+	}
+	s.streams[stream] = struct{}{}
+}
+
+func (s *StorageModule) untrack(stream storageStream) {
+	if s.parent != nil {
+		s.parent.untrack(stream)
+		return
+	}
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	delete(s.streams, stream)
+}
+
+// Close releases the readers and writers a script left open. Unclosed
+// writers are discarded.
+func (s *StorageModule) Close() {
+	s.streamsMu.Lock()
+	streams := s.streams
+	s.streams = nil
+	s.streamsMu.Unlock()
+
+	for stream := range streams {
+		stream.release()
+	}
+}
+
+// Open opens a file, or a byte range of it, for streaming reads
+func (s *StorageModule) Open(path string, options ...*StorageOpenOptions) (*goja.Object, error) {
+	var offset, length int64 = 0, -1
+	if len(options) > 0 && options[0] != nil {
+		offset = options[0].Offset
+		if options[0].Length != nil {
+			length = *options[0].Length
+		}
+	}
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	object, err := s.storage.OpenRange(s.projectID, path, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &StorageReader{module: s, object: object, reader: bufio.NewReaderSize(object, defaultChunkSize)}
+	s.track(reader)
+	return reader.toJS(s.vm), nil
+}
+
+// OpenWrite opens a streaming writer for a file
+func (s *StorageModule) OpenWrite(path string, options ...*StorageWriteOptions) (*goja.Object, error) {
+	appendMode := len(options) > 0 && options[0] != nil && options[0].Append
+
+	writer, err := s.storage.Create(s.projectID, path, service.RuntimeVersionUser, appendMode)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &StorageWriter{module: s, writer: writer}
+	s.track(w)
+	return w.toJS(s.vm), nil
+}
+
+func (s *StorageModule) TmpOpen(path string, options ...*StorageOpenOptions) (*goja.Object, error) {
+	return s.Open("tmp/"+path, options...)
+}
+
+func (s *StorageModule) TmpOpenWrite(path string, options ...*StorageWriteOptions) (*goja.Object, error) {
+	return s.OpenWrite("tmp/"+path, options...)
+}
+
+func (r *StorageReader) toJS(vm *goja.Runtime) *goja.Object {
+	obj := vm.NewObject()
+	_ = obj.Set("size", r.object.Size)
+	_ = obj.Set("mimeType", r.object.MimeType)
+	_ = obj.Set("read", r.Read)
+	_ = obj.Set("readBase64", r.ReadBase64)
+	_ = obj.Set("readLines", func(fn goja.Callable) (int, error) {
+		return r.ReadLines(vm, fn)
+	})
+	_ = obj.Set("close", r.Close)
+	return obj
+}
+
+// chunk reads up to size bytes, returning nil at the end of the file
+func (r *StorageReader) chunk(size int) ([]byte, error) {
+	if r.closed {
+		return nil, errors.New("reader is closed")
+	}
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r.reader, buf)
+	if n == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+		return nil, nil
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// Read returns the next chunk as a string, or null at the end of the file
+func (r *StorageReader) Read(size ...int) (interface{}, error) {
+	data, err := r.chunk(optionalInt(size))
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// ReadBase64 returns the next chunk base64 encoded, or null at the end of the file
+func (r *StorageReader) ReadBase64(size ...int) (interface{}, error) {
+	data, err := r.chunk(optionalInt(size))
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ReadLines calls fn with every remaining line and its index, without the line
+// ending. Returning false from fn stops reading. Returns the number of lines read.
+func (r *StorageReader) ReadLines(vm *goja.Runtime, fn goja.Callable) (int, error) {
+	if r.closed {
+		return 0, errors.New("reader is closed")
+	}
+
+	count := 0
+	stop := vm.ToValue(false)
+	for {
+		line, err := r.reader.ReadString('\n')
+		if line == "" && err == io.EOF {
+			return count, nil
+		}
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		result, callErr := fn(goja.Undefined(), vm.ToValue(line), vm.ToValue(count))
+		count++
+		if callErr != nil {
+			return count, callErr
+		}
+		if err == io.EOF || (result != nil && result.StrictEquals(stop)) {
+			return count, nil
+		}
+	}
+}
+
+// Close releases the file
+func (r *StorageReader) Close() {
+	r.module.untrack(r)
+	r.release()
+}
+
+func (r *StorageReader) release() {
+	if !r.closed {
+		r.closed = true
+		r.object.Close()
+	}
+}
+
+func (w *StorageWriter) toJS(vm *goja.Runtime) *goja.Object {
+	obj := vm.NewObject()
+	_ = obj.Set("write", w.Write)
+	_ = obj.Set("writeBase64", w.WriteBase64)
+	_ = obj.Set("size", w.writer.Size)
+	_ = obj.Set("close", w.Close)
+	_ = obj.Set("abort", w.Abort)
+	return obj
+}
+
+// Write adds a string to the file and returns the number of bytes written
+func (w *StorageWriter) Write(content string) (int, error) {
+	return w.writer.Write([]byte(content))
+}
+
+// WriteBase64 adds base64 encoded binary content to the file
+func (w *StorageWriter) WriteBase64(content string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return 0, err
+	}
+	return w.writer.Write(data)
+}
+
+// Close stores the written content and returns its size in bytes
+func (w *StorageWriter) Close() (int64, error) {
+	w.module.untrack(w)
+	if err := w.writer.Close(); err != nil {
+		return 0, err
+	}
+	return w.writer.Size(), nil
+}
+
+// Abort discards the written content
+func (w *StorageWriter) Abort() {
+	w.module.untrack(w)
+	w.release()
+}
+
+func (w *StorageWriter) release() {
+	w.writer.Abort()
+}
+
+func optionalInt(values []int) int {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return 0
+}
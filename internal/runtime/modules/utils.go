@@ -12,10 +12,27 @@ import (
 	"github.com/levskiy0/m3m/pkg/schema"
 )
 
-type UtilsModule struct{}
+type UtilsModule struct {
+	location *time.Location
+}
 
 func NewUtilsModule() *UtilsModule {
-	return &UtilsModule{}
+	return &UtilsModule{location: time.UTC}
+}
+
+// SetLocation sets the project timezone dates are formatted and parsed in
+func (u *UtilsModule) SetLocation(loc *time.Location) {
+	u.location = loc
+}
+
+// timezone resolves an optional IANA timezone name, defaulting to the project timezone
+func (u *UtilsModule) timezone(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return u.location
 }
 
 // Name returns the module name for JavaScript
@@ -116,8 +133,8 @@ func (u *UtilsModule) RegexReplace(text, pattern, replacement string) string {
 	return re.ReplaceAllString(text, replacement)
 }
 
-func (u *UtilsModule) FormatDate(timestamp int64, format string) string {
-	t := time.Unix(timestamp/1000, 0)
+func (u *UtilsModule) FormatDate(timestamp int64, format string, timezone string) string {
+	t := time.Unix(timestamp/1000, 0).In(u.timezone(timezone))
 
 	// Convert common format tokens to Go format
 	format = strings.ReplaceAll(format, "YYYY", "2006")
@@ -130,7 +147,7 @@ func (u *UtilsModule) FormatDate(timestamp int64, format string) string {
 	return t.Format(format)
 }
 
-func (u *UtilsModule) ParseDate(text, format string) int64 {
+func (u *UtilsModule) ParseDate(text, format string, timezone string) int64 {
 	// Convert common format tokens to Go format
 	format = strings.ReplaceAll(format, "YYYY", "2006")
 	format = strings.ReplaceAll(format, "MM", "01")
@@ -139,7 +156,7 @@ func (u *UtilsModule) ParseDate(text, format string) int64 {
 	format = strings.ReplaceAll(format, "mm", "04")
 	format = strings.ReplaceAll(format, "ss", "05")
 
-	t, err := time.Parse(format, text)
+	t, err := time.ParseInLocation(format, text, u.timezone(timezone))
 	if err != nil {
 		return 0
 	}
@@ -226,6 +243,7 @@ func (u *UtilsModule) GetSchema() schema.ModuleSchema {
 				Params: []schema.ParamSchema{
 					{Name: "timestamp", Type: "number", Description: "Unix timestamp in milliseconds"},
 					{Name: "format", Type: "string", Description: "Format string (YYYY-MM-DD HH:mm:ss)"},
+					{Name: "timezone", Type: "string", Description: "IANA timezone, e.g. 'Europe/Moscow' (defaults to the project timezone)", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Type: "string"},
 			},
@@ -235,6 +253,7 @@ func (u *UtilsModule) GetSchema() schema.ModuleSchema {
 				Params: []schema.ParamSchema{
 					{Name: "text", Type: "string", Description: "Date string to parse"},
 					{Name: "format", Type: "string", Description: "Format string (YYYY-MM-DD HH:mm:ss)"},
+					{Name: "timezone", Type: "string", Description: "IANA timezone of the text (defaults to the project timezone)", Optional: true},
 				},
 				Returns: &schema.ParamSchema{Type: "number"},
 			},
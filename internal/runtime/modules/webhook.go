@@ -0,0 +1,340 @@
+package modules
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+// Webhook verification presets
+const (
+	WebhookProviderHMAC     = "hmac"
+	WebhookProviderStripe   = "stripe"
+	WebhookProviderGitHub   = "github"
+	WebhookProviderTelegram = "telegram"
+)
+
+const (
+	// webhookDefaultTolerance is how old a signed timestamp may be
+	webhookDefaultTolerance = 5 * time.Minute
+	// webhookClaimTTL is how long handled delivery ids are remembered in
+	// memory; older ones are still found in the delivery log
+	webhookClaimTTL = 24 * time.Hour
+	// webhookMaxClaims triggers a sweep of expired claims
+	webhookMaxClaims = 10000
+)
+
+var (
+	ErrWebhookNotRegistered = errors.New("webhook is not registered")
+	ErrWebhookTruncated     = errors.New("payload was truncated and cannot be replayed")
+	ErrWebhookRejected      = errors.New("rejected deliveries are not kept and cannot be replayed")
+
+	errWebhookSignature = errors.New("signature mismatch")
+	errWebhookTimestamp = errors.New("timestamp is outside the tolerance")
+)
+
+// webhookRedactedHeaders are never written to the delivery log
+var webhookRedactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// WebhookOptions configures $router.webhook. A provider preset fixes the
+// scheme; without one the signature is an HMAC of the raw body.
+type WebhookOptions struct {
+	Provider        string `json:"provider"` // "stripe", "github", "telegram" or "hmac"
+	Secret          string `json:"secret"`
+	Header          string `json:"header"`          // Signature header, overrides the preset
+	Algo            string `json:"algo"`            // hmac: sha1, sha256 (default) or sha512
+	Encoding        string `json:"encoding"`        // hmac: hex (default) or base64
+	Tolerance       int    `json:"tolerance"`       // Seconds a signed timestamp may be off, default 300
+	TimestampHeader string `json:"timestampHeader"` // hmac: sign "timestamp.body" with this header's value
+	IDHeader        string `json:"idHeader"`        // hmac: header with the delivery id, for replay protection
+}
+
+// WebhookLog keeps the deliveries of webhook routes
+type WebhookLog interface {
+	Record(delivery *domain.WebhookDelivery)
+	Delivered(webhook, deliveryID string) bool
+}
+
+// webhookConfig is a verified webhook route
+type webhookConfig struct {
+	provider        string
+	secret          []byte
+	header          string
+	hash            func() hash.Hash
+	algo            string
+	encoding        string
+	tolerance       time.Duration
+	timestampHeader string
+	idHeader        string
+}
+
+func newWebhookConfig(options *WebhookOptions) (*webhookConfig, error) {
+	if options == nil || options.Secret == "" {
+		return nil, errors.New("webhook secret is required")
+	}
+
+	cfg := &webhookConfig{
+		provider:  strings.ToLower(options.Provider),
+		secret:    []byte(options.Secret),
+		header:    options.Header,
+		algo:      cmp.Or(strings.ToLower(options.Algo), "sha256"),
+		encoding:  cmp.Or(options.Encoding, EncodingHex),
+		tolerance: webhookDefaultTolerance,
+	}
+	if options.Tolerance > 0 {
+		cfg.tolerance = time.Duration(options.Tolerance) * time.Second
+	}
+
+	switch cfg.provider {
+	case "", WebhookProviderHMAC:
+		cfg.provider = WebhookProviderHMAC
+		cfg.header = cmp.Or(cfg.header, "X-Signature")
+		cfg.timestampHeader = options.TimestampHeader
+		cfg.idHeader = options.IDHeader
+		if cfg.encoding != EncodingHex && cfg.encoding != EncodingBase64 {
+			return nil, fmt.Errorf("unknown signature encoding %q, expected hex or base64", cfg.encoding)
+		}
+	case WebhookProviderStripe:
+		cfg.header = cmp.Or(cfg.header, "Stripe-Signature")
+		cfg.algo = "sha256"
+	case WebhookProviderGitHub:
+		cfg.header = cmp.Or(cfg.header, "X-Hub-Signature-256")
+		cfg.idHeader = "X-GitHub-Delivery"
+		cfg.algo = "sha256"
+	case WebhookProviderTelegram:
+		cfg.header = cmp.Or(cfg.header, "X-Telegram-Bot-Api-Secret-Token")
+		return cfg, nil
+	default:
+		return nil, fmt.Errorf("unknown webhook provider %q, expected stripe, github, telegram or hmac", options.Provider)
+	}
+
+	if cfg.algo != "sha1" && cfg.algo != "sha256" && cfg.algo != "sha512" {
+		return nil, fmt.Errorf("unknown signature algorithm %q, expected sha1, sha256 or sha512", options.Algo)
+	}
+	hashFn, err := hashFunc(cfg.algo)
+	if err != nil {
+		return nil, err
+	}
+	cfg.hash = hashFn
+	return cfg, nil
+}
+
+// verify checks the signature of a delivery and, for signed timestamps, its
+// age. It returns the timestamp in Unix seconds, 0 when there is none.
+func (w *webhookConfig) verify(headers map[string]string, body []byte, now time.Time) (int64, error) {
+	signature := webhookHeader(headers, w.header)
+	if signature == "" {
+		return 0, fmt.Errorf("missing %s header", w.header)
+	}
+
+	switch w.provider {
+	case WebhookProviderTelegram:
+		if subtle.ConstantTimeCompare([]byte(signature), w.secret) != 1 {
+			return 0, errWebhookSignature
+		}
+		return 0, nil
+
+	case WebhookProviderStripe:
+		// t=1492774577,v1=5257a8...,v1=... (several while secrets roll)
+		var timestamp string
+		var signatures []string
+		for _, item := range strings.Split(signature, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signatures = append(signatures, value)
+			}
+		}
+		ts, err := w.checkTimestamp(timestamp, now)
+		if err != nil {
+			return 0, err
+		}
+		expected := w.sign([]byte(timestamp+"."), body)
+		for _, s := range signatures {
+			if actual, err := decodeString(s, EncodingHex); err == nil && hmac.Equal(actual, expected) {
+				return ts, nil
+			}
+		}
+		return 0, errWebhookSignature
+
+	case WebhookProviderGitHub:
+		actual, err := decodeString(strings.TrimPrefix(signature, "sha256="), EncodingHex)
+		if err != nil || !hmac.Equal(actual, w.sign(nil, body)) {
+			return 0, errWebhookSignature
+		}
+		return 0, nil
+	}
+
+	var prefix []byte
+	var ts int64
+	if w.timestampHeader != "" {
+		timestamp := webhookHeader(headers, w.timestampHeader)
+		var err error
+		if ts, err = w.checkTimestamp(timestamp, now); err != nil {
+			return 0, err
+		}
+		prefix = []byte(timestamp + ".")
+	}
+	actual, err := decodeString(strings.TrimPrefix(signature, w.algo+"="), w.encoding)
+	if err != nil || !hmac.Equal(actual, w.sign(prefix, body)) {
+		return 0, errWebhookSignature
+	}
+	return ts, nil
+}
+
+func (w *webhookConfig) sign(prefix, body []byte) []byte {
+	mac := hmac.New(w.hash, w.secret)
+	mac.Write(prefix)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// checkTimestamp parses a timestamp in Unix seconds (or milliseconds) and
+// refuses it when it is further from now than the tolerance
+func (w *webhookConfig) checkTimestamp(value string, now time.Time) (int64, error) {
+	ts, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, errors.New("missing or invalid timestamp")
+	}
+	if ts > 1e12 {
+		ts /= 1000
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > w.tolerance || age < -w.tolerance {
+		return 0, errWebhookTimestamp
+	}
+	return ts, nil
+}
+
+// timestamp returns the signed timestamp of a delivery without verifying it
+func (w *webhookConfig) timestamp(headers map[string]string) int64 {
+	var value string
+	switch {
+	case w.provider == WebhookProviderStripe:
+		for _, item := range strings.Split(webhookHeader(headers, w.header), ",") {
+			if key, v, _ := strings.Cut(strings.TrimSpace(item), "="); key == "t" {
+				value = v
+			}
+		}
+	case w.timestampHeader != "":
+		value = webhookHeader(headers, w.timestampHeader)
+	}
+	ts, _ := strconv.ParseInt(value, 10, 64)
+	if ts > 1e12 {
+		ts /= 1000
+	}
+	return ts
+}
+
+// deliveryID returns the provider's id of a delivery, empty when it has none
+func (w *webhookConfig) deliveryID(headers map[string]string, body []byte) string {
+	switch w.provider {
+	case WebhookProviderStripe:
+		return jsonField(body, "id")
+	case WebhookProviderTelegram:
+		return jsonField(body, "update_id")
+	}
+	if w.idHeader != "" {
+		return webhookHeader(headers, w.idHeader)
+	}
+	return ""
+}
+
+// redact copies headers for the delivery log without secrets
+func (w *webhookConfig) redact(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		redacted[key] = value
+		if w.provider == WebhookProviderTelegram && strings.EqualFold(key, w.header) {
+			redacted[key] = "[redacted]"
+		}
+		for _, name := range webhookRedactedHeaders {
+			if strings.EqualFold(key, name) {
+				redacted[key] = "[redacted]"
+			}
+		}
+	}
+	return redacted
+}
+
+// webhookHeader looks a header up regardless of the case of its name
+func webhookHeader(headers map[string]string, name string) string {
+	if value, ok := headers[http.CanonicalHeaderKey(name)]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// jsonField returns a top-level string or number field of a JSON object
+func jsonField(body []byte, key string) string {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return ""
+	}
+	raw, ok := object[key]
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// webhookClaims remembers the delivery ids being or already handled, so
+// retries and replays by an attacker run the handler only once
+type webhookClaims struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// claim reserves a delivery id, false when it is taken
+func (c *webhookClaims) claim(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ids == nil {
+		c.ids = make(map[string]time.Time)
+	}
+	if claimed, ok := c.ids[key]; ok && now.Sub(claimed) < webhookClaimTTL {
+		return false
+	}
+	if len(c.ids) >= webhookMaxClaims {
+		for k, claimed := range c.ids {
+			if now.Sub(claimed) >= webhookClaimTTL {
+				delete(c.ids, k)
+			}
+		}
+	}
+	c.ids[key] = now
+	return true
+}
+
+// release frees a delivery id whose handling failed, so a retry runs it again
+func (c *webhookClaims) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, key)
+}
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
@@ -38,6 +39,12 @@ const (
 	MaxRestartDelay       = 30 * time.Second // max delay between restarts
 )
 
+// Blue/green restart settings (used when not set in config)
+const (
+	DefaultBootTimeout  = 60 * time.Second // max time for a replacement runtime to become ready
+	DefaultDrainTimeout = 30 * time.Second // max time to wait for in-flight requests on the old runtime
+)
+
 // isCodeError checks if error is a code-level error that won't be fixed by restart
 func isCodeError(err error) bool {
 	if err == nil {
@@ -68,6 +75,7 @@ type ProjectRuntime struct {
 	Service       *modules.ServiceModule
 	Hook          *modules.HookModule
 	UI            *modules.UIModule
+	Storage       *modules.StorageModule
 	StartedAt     time.Time
 	Metrics       *MetricsHistory
 	metricsCancel context.CancelFunc
@@ -80,6 +88,22 @@ type ProjectRuntime struct {
 	restartCount  int               // number of restarts
 	lastRestartAt time.Time         // last restart time
 	stopRequested bool              // true if stop was explicitly requested (no auto-restart)
+	standby       atomic.Bool       // true while booting as a replacement, before traffic is switched
+	inflight      sync.WaitGroup    // in-flight requests, drained before shutdown on replacement
+	ready         chan error        // receives nil once started, or the boot error
+	readyOnce     sync.Once
+}
+
+// markReady reports the boot result once; later calls are ignored
+func (rt *ProjectRuntime) markReady(err error) {
+	rt.readyOnce.Do(func() {
+		rt.ready <- err
+	})
+}
+
+// release marks an in-flight request as finished
+func (rt *ProjectRuntime) release() {
+	rt.inflight.Done()
 }
 
 // LogBroadcaster interface for broadcasting log updates
@@ -110,13 +134,19 @@ type Manager struct {
 	logger          *slog.Logger
 	plugins         *plugin.Loader
 	envService      *service.EnvironmentService
+	projectService  *service.ProjectService
 	goalService     *service.GoalService
 	modelService    *service.ModelService
 	storageService  *service.StorageService
+	logService      *service.LogService
+	authService     *service.ProjectAuthService
+	webhookService  *service.WebhookService
 	logBroadcaster  LogBroadcaster
 	hookBroadcaster HookBroadcaster
 	uiBroadcaster   UIBroadcaster
 	stopHandler     RuntimeStopHandler
+	startLocks      map[string]*sync.Mutex
+	startLocksMu    sync.Mutex
 }
 
 func NewManager(
@@ -124,19 +154,28 @@ func NewManager(
 	logger *slog.Logger,
 	plugins *plugin.Loader,
 	envService *service.EnvironmentService,
+	projectService *service.ProjectService,
 	goalService *service.GoalService,
 	modelService *service.ModelService,
 	storageService *service.StorageService,
+	logService *service.LogService,
+	authService *service.ProjectAuthService,
+	webhookService *service.WebhookService,
 ) *Manager {
 	return &Manager{
 		runtimes:       make(map[string]*ProjectRuntime),
+		startLocks:     make(map[string]*sync.Mutex),
 		config:         cfg,
 		logger:         logger,
 		plugins:        plugins,
 		envService:     envService,
+		projectService: projectService,
 		goalService:    goalService,
 		modelService:   modelService,
 		storageService: storageService,
+		logService:     logService,
+		authService:    authService,
+		webhookService: webhookService,
 	}
 }
 
@@ -173,18 +212,72 @@ func (m *Manager) HandleUIResponse(projectID, requestID string, data interface{}
 	runtime.UI.HandleResponse(requestID, data)
 }
 
-// Start starts a project with the given files
-func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files []domain.CodeFile) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// LogQuotaWarning writes a quota warning to the log of a running project
+func (m *Manager) LogQuotaWarning(warning domain.QuotaWarning) {
+	m.mu.RLock()
+	runtime, ok := m.runtimes[warning.ProjectID]
+	m.mu.RUnlock()
 
+	if !ok || runtime.Logger == nil {
+		return
+	}
+
+	runtime.Logger.Warn(warning.Message)
+}
+
+// projectLock returns the mutex serializing start operations of a project
+func (m *Manager) projectLock(projectIDStr string) *sync.Mutex {
+	m.startLocksMu.Lock()
+	defer m.startLocksMu.Unlock()
+
+	lock, ok := m.startLocks[projectIDStr]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.startLocks[projectIDStr] = lock
+	}
+	return lock
+}
+
+// newLoggerModule opens the runtime log file with the project's rotation policy
+// and keeps it protected from the log janitor until the logger is closed
+func (m *Manager) newLoggerModule(projectID primitive.ObjectID, logFile string) *modules.LoggerModule {
 	projectIDStr := projectID.Hex()
+	loggerModule := modules.NewLoggerModule(logFile)
 
-	// Stop existing runtime if running
-	if existing, ok := m.runtimes[projectIDStr]; ok {
-		m.stopRuntime(existing)
+	if m.logService != nil {
+		policy := m.logService.ProjectPolicy(context.Background(), projectID)
+		loggerModule.SetMaxFileSize(policy.MaxFileSize)
+		m.logService.Acquire(logFile)
+		loggerModule.SetOnClose(func() {
+			m.logService.Release(logFile)
+		})
 	}
 
+	if m.logBroadcaster != nil {
+		loggerModule.SetOnLog(func() {
+			m.logBroadcaster.BroadcastLogUpdate(projectIDStr)
+		})
+	}
+
+	return loggerModule
+}
+
+// Start starts a project with the given files.
+// If the project is already running, the new runtime boots in standby while the
+// current one keeps serving traffic (blue/green). Traffic is switched only after
+// the new runtime has completed its boot and start phases; on failure the current
+// runtime stays active and an error is returned.
+func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files []domain.CodeFile) error {
+	projectIDStr := projectID.Hex()
+
+	lock := m.projectLock(projectIDStr)
+	lock.Lock()
+	defer lock.Unlock()
+
+	m.mu.RLock()
+	_, replacing := m.runtimes[projectIDStr]
+	m.mu.RUnlock()
+
 	// Create new runtime context - use Background() so runtime survives parent context cancellation
 	runtimeCtx, cancel := context.WithCancel(context.Background())
 
@@ -198,13 +291,7 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 		return fmt.Errorf("failed to create log file: %w", err)
 	}
 
-	loggerModule := modules.NewLoggerModule(logFile)
-
-	if m.logBroadcaster != nil {
-		loggerModule.SetOnLog(func() {
-			m.logBroadcaster.BroadcastLogUpdate(projectIDStr)
-		})
-	}
+	loggerModule := m.newLoggerModule(projectID, logFile)
 
 	routerModule := modules.NewRouterModule()
 	routerModule.SetVM(vm)
@@ -212,6 +299,7 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 	serviceModule := modules.NewServiceModule(vm, m.config.Runtime.Timeout)
 	hookModule := modules.NewHookModule(vm, projectID, m.hookBroadcaster)
 	uiModule := modules.NewUIModule(vm, projectID, m.uiBroadcaster)
+	storageModule := modules.NewStorageModule(m.storageService, projectID.Hex())
 
 	// Create env getter for lazy loading (enables hot reload of env vars)
 	envGetter := func() map[string]interface{} {
@@ -219,7 +307,7 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 		return envMap
 	}
 
-	if err := m.registerModules(vm, projectID, loggerModule, routerModule, schedulerModule, serviceModule, hookModule, uiModule, envGetter); err != nil {
+	if err := m.registerModules(vm, projectID, loggerModule, routerModule, schedulerModule, serviceModule, hookModule, uiModule, storageModule, envGetter); err != nil {
 		cancel()
 		return fmt.Errorf("failed to register modules: %w", err)
 	}
@@ -247,19 +335,27 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 		Service:       serviceModule,
 		Hook:          hookModule,
 		UI:            uiModule,
+		Storage:       storageModule,
 		StartedAt:     time.Now(),
 		Metrics:       NewMetricsHistory(),
 		metricsCancel: metricsCancel,
 		files:         files,
 		logFile:       logFile,
+		ready:         make(chan error, 1),
 	}
+	rt.standby.Store(replacing)
 
-	go rt.collectMetrics(metricsCtx)
-
-	go func() {
+	run := func() {
 		var crashReason CrashReason
 		var crashMessage string
 
+		// Report boot failure after crash handling below has finished logging
+		defer func() {
+			if crashReason != "" {
+				rt.markReady(fmt.Errorf("%s", crashMessage))
+			}
+		}()
+
 		defer func() {
 			if r := recover(); r != nil {
 				crashReason = CrashReasonPanic
@@ -296,7 +392,9 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 				)
 
 				// Check if we should auto-restart (not for code errors - they won't fix themselves)
-				if !rt.stopRequested && crashReason != CrashReasonCodeError {
+				if rt.standby.Load() {
+					loggerModule.Error("Replacement runtime failed to boot, previous runtime stays active")
+				} else if !rt.stopRequested && crashReason != CrashReasonCodeError {
 					// Reset counter if cooldown period passed
 					if time.Since(rt.lastRestartAt) > RestartCooldownPeriod {
 						rt.restartCount = 0
@@ -362,7 +460,7 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 
 				// Restart with preserved restart info
 				go func() {
-					if err := m.startWithRestartInfo(projectID, savedFiles, restartCount, lastRestartAt, savedLogFile); err != nil {
+					if err := m.startWithRestartInfo(rt, savedFiles, restartCount, lastRestartAt, savedLogFile); err != nil {
 						m.logger.Error("Auto-restart failed",
 							"project", projectIDStr,
 							"error", err,
@@ -423,9 +521,13 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 			return
 		}
 
-		schedulerModule.Start()
+		// A replacement starts its jobs once traffic has switched to it
+		if !rt.standby.Load() {
+			schedulerModule.Start()
+		}
 
 		loggerModule.Info("Service is running")
+		rt.markReady(nil)
 
 		// Wait for shutdown signal
 		<-runtimeCtx.Done()
@@ -440,14 +542,147 @@ func (m *Manager) Start(ctx context.Context, projectID primitive.ObjectID, files
 		schedulerModule.Stop()
 		loggerModule.Info("Service stopped")
 		loggerModule.Close()
-	}()
+	}
+
+	go rt.collectMetrics(metricsCtx)
+
+	if !replacing {
+		// Register before running so crash handling always sees the runtime in the map
+		m.mu.Lock()
+		m.runtimes[projectIDStr] = rt
+		go run()
+		m.mu.Unlock()
+
+		m.logger.Info("Project started", "project", projectIDStr)
+		return nil
+	}
+
+	m.logger.Info("Booting replacement runtime", "project", projectIDStr)
+	go run()
+
+	bootTimeout := m.config.Runtime.BootTimeout
+	if bootTimeout <= 0 {
+		bootTimeout = DefaultBootTimeout
+	}
+
+	var bootErr error
+	select {
+	case bootErr = <-rt.ready:
+	case <-time.After(bootTimeout):
+		vm.Interrupt("boot timeout")
+		bootErr = fmt.Errorf("boot timed out after %s", bootTimeout)
+	}
 
+	if bootErr != nil {
+		rt.stopRequested = true
+		m.discardRuntime(rt)
+		m.logger.Error("Replacement runtime failed, keeping current runtime",
+			"project", projectIDStr,
+			"error", bootErr,
+		)
+		return fmt.Errorf("new runtime failed to start, previous runtime kept: %w", bootErr)
+	}
+
+	// Switch traffic atomically: new requests go to the new runtime from here on
+	m.mu.Lock()
+	previous := m.runtimes[projectIDStr]
 	m.runtimes[projectIDStr] = rt
-	m.logger.Info("Project started", "project", projectIDStr)
+	rt.standby.Store(false)
+	m.mu.Unlock()
 
+	// Jobs move over with the traffic: the previous runtime stops firing them
+	// before the new one starts, so none runs twice
+	if previous != nil && previous.Scheduler != nil {
+		select {
+		case <-previous.Scheduler.Stop().Done():
+		case <-time.After(m.drainTimeout()):
+			m.logger.Warn("Scheduled jobs of the previous runtime still running", "project", projectIDStr)
+		}
+	}
+	schedulerModule.Start()
+
+	if previous != nil {
+		go m.retireRuntime(previous)
+	}
+
+	m.logger.Info("Project started", "project", projectIDStr, "mode", "blue-green")
 	return nil
 }
 
+// retireRuntime stops a runtime replaced by a blue/green start after its
+// in-flight requests have finished (or the drain timeout has passed)
+func (m *Manager) retireRuntime(rt *ProjectRuntime) {
+	projectIDStr := rt.ProjectID.Hex()
+	rt.stopRequested = true
+
+	// No new scheduled jobs on the old runtime while draining
+	if rt.Scheduler != nil {
+		rt.Scheduler.Stop()
+	}
+
+	drainTimeout := m.drainTimeout()
+
+	drained := make(chan struct{})
+	go func() {
+		rt.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		m.logger.Info("Previous runtime drained", "project", projectIDStr)
+	case <-time.After(drainTimeout):
+		m.logger.Warn("Drain timeout reached, stopping previous runtime with requests in flight",
+			"project", projectIDStr,
+			"timeout", drainTimeout.String(),
+		)
+	}
+
+	m.stopRuntime(rt)
+}
+
+func (m *Manager) drainTimeout() time.Duration {
+	if m.config.Runtime.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return m.config.Runtime.DrainTimeout
+}
+
+// discardRuntime releases resources of a runtime that never received traffic.
+// Shutdown callbacks are not executed since the service never started.
+func (m *Manager) discardRuntime(rt *ProjectRuntime) {
+	if rt.metricsCancel != nil {
+		rt.metricsCancel()
+	}
+	rt.Cancel()
+	if rt.Scheduler != nil {
+		rt.Scheduler.Stop()
+	}
+	if rt.Hook != nil {
+		rt.Hook.Stop()
+	}
+	if rt.UI != nil {
+		rt.UI.Cleanup()
+	}
+	if rt.Storage != nil {
+		rt.Storage.Close()
+	}
+}
+
+// acquire returns the active runtime of a project and registers an in-flight
+// request on it. Callers must call release() when done.
+func (m *Manager) acquire(projectID primitive.ObjectID) (*ProjectRuntime, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rt, ok := m.runtimes[projectID.Hex()]
+	if !ok {
+		return nil, false
+	}
+	rt.inflight.Add(1)
+	return rt, true
+}
+
 func (m *Manager) stopRuntime(rt *ProjectRuntime) {
 	// Log who is stopping the runtime with stack trace
 	buf := make([]byte, 4096)
@@ -467,9 +702,15 @@ func (m *Manager) stopRuntime(rt *ProjectRuntime) {
 	if rt.Scheduler != nil {
 		rt.Scheduler.Stop()
 	}
+	if rt.Hook != nil {
+		rt.Hook.Stop()
+	}
 	if rt.UI != nil {
 		rt.UI.Cleanup()
 	}
+	if rt.Storage != nil {
+		rt.Storage.Close()
+	}
 	if rt.Logger != nil {
 		rt.Logger.Close()
 	}
@@ -516,10 +757,16 @@ func (rt *ProjectRuntime) collectSnapshot() {
 
 // Stop stops a running project
 func (m *Manager) Stop(projectID primitive.ObjectID) error {
+	projectIDStr := projectID.Hex()
+
+	// Wait for a blue/green start in progress to settle
+	lock := m.projectLock(projectIDStr)
+	lock.Lock()
+	defer lock.Unlock()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	projectIDStr := projectID.Hex()
 	rt, ok := m.runtimes[projectIDStr]
 	if !ok {
 		return fmt.Errorf("project not running")
@@ -553,17 +800,42 @@ func (m *Manager) GetRuntime(projectID primitive.ObjectID) (*ProjectRuntime, boo
 
 // HandleRoute handles an HTTP request for a project route
 func (m *Manager) HandleRoute(projectID primitive.ObjectID, method, path string, ctx *modules.RequestContext) (*modules.ResponseData, error) {
-	m.mu.RLock()
-	runtime, ok := m.runtimes[projectID.Hex()]
-	m.mu.RUnlock()
-
+	runtime, ok := m.acquire(projectID)
 	if !ok {
 		return nil, fmt.Errorf("project not running")
 	}
+	defer runtime.release()
 
 	return runtime.Router.Handle(method, path, ctx)
 }
 
+// ReplayWebhook runs a recorded webhook delivery through the project's
+// handler again and returns the delivery recorded for the replay
+func (m *Manager) ReplayWebhook(projectID primitive.ObjectID, delivery *domain.WebhookDelivery) (*domain.WebhookDelivery, error) {
+	runtime, ok := m.acquire(projectID)
+	if !ok {
+		return nil, fmt.Errorf("project not running")
+	}
+	defer runtime.release()
+
+	return runtime.Router.ReplayWebhook(delivery)
+}
+
+// webhookLog records the webhook deliveries of a project
+type webhookLog struct {
+	service   *service.WebhookService
+	projectID primitive.ObjectID
+}
+
+func (l *webhookLog) Record(delivery *domain.WebhookDelivery) {
+	delivery.ProjectID = l.projectID
+	l.service.Record(context.Background(), delivery)
+}
+
+func (l *webhookLog) Delivered(webhook, deliveryID string) bool {
+	return l.service.Delivered(context.Background(), l.projectID, webhook, deliveryID)
+}
+
 // GetCORSConfig returns CORS configuration for a project
 func (m *Manager) GetCORSConfig(projectID primitive.ObjectID) *modules.CORSConfig {
 	m.mu.RLock()
@@ -579,13 +851,11 @@ func (m *Manager) GetCORSConfig(projectID primitive.ObjectID) *modules.CORSConfi
 
 // TriggerAction triggers an action handler in a running project
 func (m *Manager) TriggerAction(projectID primitive.ObjectID, slug string) error {
-	m.mu.RLock()
-	runtime, ok := m.runtimes[projectID.Hex()]
-	m.mu.RUnlock()
-
+	runtime, ok := m.acquire(projectID)
 	if !ok {
 		return fmt.Errorf("project not running")
 	}
+	defer runtime.release()
 
 	if runtime.Hook == nil {
 		return fmt.Errorf("hook module not initialized")
@@ -596,13 +866,11 @@ func (m *Manager) TriggerAction(projectID primitive.ObjectID, slug string) error
 
 // TriggerActionWithSession triggers an action with session context for $ui dialogs
 func (m *Manager) TriggerActionWithSession(projectID primitive.ObjectID, slug string, userID string, sessionID string) error {
-	m.mu.RLock()
-	runtime, ok := m.runtimes[projectID.Hex()]
-	m.mu.RUnlock()
-
+	runtime, ok := m.acquire(projectID)
 	if !ok {
 		return fmt.Errorf("project not running")
 	}
+	defer runtime.release()
 
 	if runtime.Hook == nil {
 		return fmt.Errorf("hook module not initialized")
@@ -651,13 +919,11 @@ func (m *Manager) GetActionState(projectID primitive.ObjectID, slug string) (dom
 
 // TriggerModelHook triggers a model hook in a running project
 func (m *Manager) TriggerModelHook(projectID primitive.ObjectID, modelSlug string, hookType modules.ModelHookType, data map[string]interface{}) error {
-	m.mu.RLock()
-	runtime, ok := m.runtimes[projectID.Hex()]
-	m.mu.RUnlock()
-
+	runtime, ok := m.acquire(projectID)
 	if !ok {
 		return nil // Project not running, skip hook
 	}
+	defer runtime.release()
 
 	if runtime.Hook == nil {
 		return nil
@@ -666,6 +932,29 @@ func (m *Manager) TriggerModelHook(projectID primitive.ObjectID, modelSlug strin
 	return runtime.Hook.TriggerModelHook(modelSlug, hookType, data)
 }
 
+// TriggerStorageHook queues a file change for the storage hooks of a running
+// project. The change already happened, so errors only go to the project log.
+func (m *Manager) TriggerStorageHook(event domain.StorageEvent) {
+	projectID, err := primitive.ObjectIDFromHex(event.ProjectID)
+	if err != nil {
+		return
+	}
+
+	runtime, ok := m.acquire(projectID)
+	if !ok {
+		return // Project not running, skip hook
+	}
+	defer runtime.release()
+
+	if runtime.Hook == nil {
+		return
+	}
+
+	if err := runtime.Hook.QueueStorageEvent(event); err != nil && runtime.Logger != nil {
+		runtime.Logger.Error(fmt.Sprintf("storage hook for %s %s failed: %v", event.Type, event.Path, err))
+	}
+}
+
 // StopAll stops all running runtimes (for graceful shutdown)
 func (m *Manager) StopAll() {
 	m.mu.Lock()
@@ -856,10 +1145,30 @@ func (m *Manager) registerModules(
 	serviceModule *modules.ServiceModule,
 	hookModule *modules.HookModule,
 	uiModule *modules.UIModule,
+	storageModule *modules.StorageModule,
 	envGetter func() map[string]interface{},
 ) error {
 	projectIDStr := projectID.Hex()
 
+	// Attribute log records to the route, job or hook being executed
+	routerModule.SetLogger(loggerModule)
+	schedulerModule.SetLogger(loggerModule)
+	hookModule.SetLogger(loggerModule)
+
+	// Storage handlers get a ctx.storage whose writes do not trigger them again
+	hookModule.SetStorage(storageModule)
+
+	if m.webhookService != nil {
+		routerModule.SetWebhookLog(&webhookLog{service: m.webhookService, projectID: projectID})
+	}
+
+	// Schedules and dates follow the project timezone, read once per start
+	location := time.UTC
+	if m.projectService != nil {
+		location = m.projectService.Location(context.Background(), projectID)
+	}
+	schedulerModule.SetLocation(location)
+
 	// Pre-initialized modules (passed as arguments)
 	serviceModule.Register(vm)
 	loggerModule.Register(vm) // Also registers console
@@ -875,8 +1184,10 @@ func (m *Manager) registerModules(
 	mailModule := modules.NewMailModule(envModule)
 	mailModule.Register(vm)
 
+	authModule := modules.NewAuthModule(m.authService, projectID, envModule, mailModule)
+	authModule.Register(vm)
+
 	// Storage-dependent modules
-	storageModule := modules.NewStorageModule(m.storageService, projectIDStr)
 	storageModule.Register(vm)
 
 	imageModule := modules.NewImageModule(m.storageService, projectIDStr)
@@ -898,7 +1209,9 @@ func (m *Manager) registerModules(
 
 	modules.NewCryptoModule().Register(vm)
 	modules.NewEncodingModule().Register(vm)
-	modules.NewUtilsModule().Register(vm)
+	utilsModule := modules.NewUtilsModule()
+	utilsModule.SetLocation(location)
+	utilsModule.Register(vm)
 	modules.NewValidatorModule().Register(vm)
 
 	delayedModule := modules.NewDelayedModule(m.config.Runtime.WorkerPoolSize)
@@ -907,17 +1220,21 @@ func (m *Manager) registerModules(
 	return nil
 }
 
-// startWithRestartInfo starts a project with preserved restart information
-func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []domain.CodeFile, restartCount int, lastRestartAt time.Time, existingLogFile string) error {
+// startWithRestartInfo replaces a crashed runtime with a fresh one, preserving restart information.
+// The restart is skipped if the crashed runtime was stopped or replaced in the meantime.
+func (m *Manager) startWithRestartInfo(crashed *ProjectRuntime, files []domain.CodeFile, restartCount int, lastRestartAt time.Time, existingLogFile string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	projectID := crashed.ProjectID
 	projectIDStr := projectID.Hex()
 
-	// Stop existing runtime if running
-	if existing, ok := m.runtimes[projectIDStr]; ok {
-		m.stopRuntime(existing)
+	existing, ok := m.runtimes[projectIDStr]
+	if !ok || existing != crashed {
+		m.logger.Info("Auto-restart skipped, runtime was stopped or replaced", "project", projectIDStr)
+		return nil
 	}
+	m.stopRuntime(existing)
 
 	// Create new runtime context - use Background() so runtime survives parent context cancellation
 	runtimeCtx, cancel := context.WithCancel(context.Background())
@@ -937,13 +1254,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 		}
 	}
 
-	loggerModule := modules.NewLoggerModule(logFile)
-
-	if m.logBroadcaster != nil {
-		loggerModule.SetOnLog(func() {
-			m.logBroadcaster.BroadcastLogUpdate(projectIDStr)
-		})
-	}
+	loggerModule := m.newLoggerModule(projectID, logFile)
 
 	routerModule := modules.NewRouterModule()
 	routerModule.SetVM(vm)
@@ -951,6 +1262,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 	serviceModule := modules.NewServiceModule(vm, m.config.Runtime.Timeout)
 	hookModule := modules.NewHookModule(vm, projectID, m.hookBroadcaster)
 	uiModule := modules.NewUIModule(vm, projectID, m.uiBroadcaster)
+	storageModule := modules.NewStorageModule(m.storageService, projectID.Hex())
 
 	// Create env getter for lazy loading (enables hot reload of env vars)
 	envGetter := func() map[string]interface{} {
@@ -958,7 +1270,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 		return envMap
 	}
 
-	if err := m.registerModules(vm, projectID, loggerModule, routerModule, schedulerModule, serviceModule, hookModule, uiModule, envGetter); err != nil {
+	if err := m.registerModules(vm, projectID, loggerModule, routerModule, schedulerModule, serviceModule, hookModule, uiModule, storageModule, envGetter); err != nil {
 		cancel()
 		return fmt.Errorf("failed to register modules: %w", err)
 	}
@@ -985,6 +1297,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 		Scheduler:     schedulerModule,
 		Service:       serviceModule,
 		Hook:          hookModule,
+		Storage:       storageModule,
 		StartedAt:     time.Now(),
 		Metrics:       NewMetricsHistory(),
 		metricsCancel: metricsCancel,
@@ -992,6 +1305,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 		logFile:       logFile,
 		restartCount:  restartCount,
 		lastRestartAt: lastRestartAt,
+		ready:         make(chan error, 1),
 	}
 
 	go rt.collectMetrics(metricsCtx)
@@ -1036,7 +1350,9 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 				)
 
 				// Check if we should auto-restart (not for code errors - they won't fix themselves)
-				if !rt.stopRequested && crashReason != CrashReasonCodeError {
+				if rt.standby.Load() {
+					loggerModule.Error("Replacement runtime failed to boot, previous runtime stays active")
+				} else if !rt.stopRequested && crashReason != CrashReasonCodeError {
 					// Reset counter if cooldown period passed
 					if time.Since(rt.lastRestartAt) > RestartCooldownPeriod {
 						rt.restartCount = 0
@@ -1102,7 +1418,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 
 				// Restart with preserved restart info
 				go func() {
-					if err := m.startWithRestartInfo(projectID, savedFiles, newRestartCount, newLastRestartAt, savedLogFile); err != nil {
+					if err := m.startWithRestartInfo(rt, savedFiles, newRestartCount, newLastRestartAt, savedLogFile); err != nil {
 						m.logger.Error("Auto-restart failed",
 							"project", projectIDStr,
 							"error", err,
@@ -1168,6 +1484,7 @@ func (m *Manager) startWithRestartInfo(projectID primitive.ObjectID, files []dom
 		schedulerModule.Start()
 
 		loggerModule.Info("Service is running")
+		rt.markReady(nil)
 
 		// Wait for shutdown signal
 		<-runtimeCtx.Done()
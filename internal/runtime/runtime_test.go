@@ -2,17 +2,28 @@ package runtime
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/runtime/modules"
 	"github.com/levskiy0/m3m/internal/service"
+	"github.com/levskiy0/m3m/internal/storage"
 )
 
+// mainFile wraps code into the single "main" file of a project
+func mainFile(code string) []domain.CodeFile {
+	return []domain.CodeFile{{Name: "main", Code: code}}
+}
+
 // These tests verify the context cancellation bug fix and runtime stability
 
 // createTestManager creates a Manager with minimal dependencies for testing
@@ -39,9 +50,13 @@ func createTestManager(t *testing.T) (*Manager, func()) {
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	storageService := service.NewStorageService(cfg)
+	driver, err := storage.NewLocalDriver(cfg.Storage.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageService := service.NewStorageService(cfg, driver)
 
-	manager := NewManager(cfg, logger, nil, nil, nil, nil, storageService)
+	manager := NewManager(cfg, logger, nil, nil, nil, nil, nil, storageService, service.NewLogService(cfg, storageService, nil), nil, nil)
 
 	cleanup := func() {
 		manager.StopAll()
@@ -71,7 +86,7 @@ func TestContextCancellationBug(t *testing.T) {
 	parentCtx, parentCancel := context.WithCancel(context.Background())
 
 	// Start runtime with parent context
-	err := manager.Start(parentCtx, projectID, code)
+	err := manager.Start(parentCtx, projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -118,7 +133,7 @@ func TestRuntimeSurvivesShortLivedContext(t *testing.T) {
 	cancel() // Cancel immediately!
 
 	// Start with already-cancelled context
-	err := manager.Start(ctx, projectID, code)
+	err := manager.Start(ctx, projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -148,7 +163,7 @@ func TestRuntimeStopsOnExplicitStop(t *testing.T) {
 		});
 	`
 
-	err := manager.Start(context.Background(), projectID, code)
+	err := manager.Start(context.Background(), projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -187,8 +202,8 @@ func TestMultipleRuntimesIsolation(t *testing.T) {
 	ctx2, cancel2 := context.WithCancel(context.Background())
 	defer cancel2()
 
-	manager.Start(ctx1, project1, code)
-	manager.Start(ctx2, project2, code)
+	manager.Start(ctx1, project1, mainFile(code))
+	manager.Start(ctx2, project2, mainFile(code))
 
 	time.Sleep(100 * time.Millisecond)
 
@@ -228,9 +243,9 @@ func TestStopAllStopsAllRuntimes(t *testing.T) {
 
 	code := `$service.start(function() {});`
 
-	manager.Start(context.Background(), project1, code)
-	manager.Start(context.Background(), project2, code)
-	manager.Start(context.Background(), project3, code)
+	manager.Start(context.Background(), project1, mainFile(code))
+	manager.Start(context.Background(), project2, mainFile(code))
+	manager.Start(context.Background(), project3, mainFile(code))
 
 	time.Sleep(100 * time.Millisecond)
 
@@ -264,7 +279,7 @@ func TestAutoRestartOnCrash(t *testing.T) {
 		throw new Error("Simulated crash for testing");
 	`
 
-	err := manager.Start(context.Background(), projectID, code)
+	err := manager.Start(context.Background(), projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -288,7 +303,7 @@ func TestNoAutoRestartOnExplicitStop(t *testing.T) {
 
 	code := `$service.start(function() {});`
 
-	err := manager.Start(context.Background(), projectID, code)
+	err := manager.Start(context.Background(), projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -325,7 +340,7 @@ func TestAutoRestartLimit(t *testing.T) {
 	// Code that always crashes
 	code := `throw new Error("Always crash");`
 
-	err := manager.Start(context.Background(), projectID, code)
+	err := manager.Start(context.Background(), projectID, mainFile(code))
 	if err != nil {
 		t.Fatalf("Failed to start runtime: %v", err)
 	}
@@ -339,3 +354,235 @@ func TestAutoRestartLimit(t *testing.T) {
 	// The log should show "Auto-restart disabled: exceeded max restarts"
 	t.Log("Auto-restart limit test completed - check logs for limit exceeded message")
 }
+
+// TestBlueGreenRestartSwitchesTraffic verifies that a restart switches routes to the new code
+func TestBlueGreenRestartSwitchesTraffic(t *testing.T) {
+	manager, cleanup := createTestManager(t)
+	defer cleanup()
+
+	projectID := primitive.NewObjectID()
+
+	v1 := `$router.get("/version", function(ctx) { return ctx.response(200, "v1"); });`
+	v2 := `$router.get("/version", function(ctx) { return ctx.response(200, "v2"); });`
+
+	if err := manager.Start(context.Background(), projectID, mainFile(v1)); err != nil {
+		t.Fatalf("Failed to start runtime: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := manager.Start(context.Background(), projectID, mainFile(v2)); err != nil {
+		t.Fatalf("Failed to restart runtime: %v", err)
+	}
+
+	// Traffic is switched by the time Start returns
+	resp, err := manager.HandleRoute(projectID, "GET", "/version", &modules.RequestContext{Method: "GET", Path: "/version"})
+	if err != nil {
+		t.Fatalf("Request failed after restart: %v", err)
+	}
+	if resp.Body != "v2" {
+		t.Fatalf("Expected response from new runtime, got %v", resp.Body)
+	}
+}
+
+// TestBlueGreenBootFailureKeepsOldRuntime verifies that a failed boot leaves the old runtime serving
+func TestBlueGreenBootFailureKeepsOldRuntime(t *testing.T) {
+	manager, cleanup := createTestManager(t)
+	defer cleanup()
+
+	projectID := primitive.NewObjectID()
+
+	v1 := `$router.get("/version", function(ctx) { return ctx.response(200, "v1"); });`
+	broken := `$service.boot(function() { throw new Error("boot failed"); });`
+
+	if err := manager.Start(context.Background(), projectID, mainFile(v1)); err != nil {
+		t.Fatalf("Failed to start runtime: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := manager.Start(context.Background(), projectID, mainFile(broken)); err == nil {
+		t.Fatal("Expected error when replacement runtime fails to boot")
+	}
+
+	if !manager.IsRunning(projectID) {
+		t.Fatal("Old runtime should keep running after failed restart")
+	}
+
+	resp, err := manager.HandleRoute(projectID, "GET", "/version", &modules.RequestContext{Method: "GET", Path: "/version"})
+	if err != nil {
+		t.Fatalf("Request failed after failed restart: %v", err)
+	}
+	if resp.Body != "v1" {
+		t.Fatalf("Expected response from old runtime, got %v", resp.Body)
+	}
+
+	// The failed runtime must not auto-restart and replace the old one
+	time.Sleep(1500 * time.Millisecond)
+	resp, err = manager.HandleRoute(projectID, "GET", "/version", &modules.RequestContext{Method: "GET", Path: "/version"})
+	if err != nil || resp.Body != "v1" {
+		t.Fatalf("Old runtime should still serve traffic, got %v, err %v", resp, err)
+	}
+}
+
+// TestBlueGreenDrainsInFlightRequests verifies the old runtime finishes in-flight requests before shutdown
+func TestBlueGreenDrainsInFlightRequests(t *testing.T) {
+	manager, cleanup := createTestManager(t)
+	defer cleanup()
+
+	projectID := primitive.NewObjectID()
+
+	v1 := `
+		$router.get("/slow", function(ctx) {
+			var start = Date.now();
+			while (Date.now() - start < 300) {}
+			return ctx.response(200, "v1");
+		});
+	`
+	v2 := `$router.get("/slow", function(ctx) { return ctx.response(200, "v2"); });`
+
+	if err := manager.Start(context.Background(), projectID, mainFile(v1)); err != nil {
+		t.Fatalf("Failed to start runtime: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	type result struct {
+		body interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := manager.HandleRoute(projectID, "GET", "/slow", &modules.RequestContext{Method: "GET", Path: "/slow"})
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{body: resp.Body}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := manager.Start(context.Background(), projectID, mainFile(v2)); err != nil {
+		t.Fatalf("Failed to restart runtime: %v", err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("In-flight request failed during restart: %v", r.err)
+	}
+	if r.body != "v1" {
+		t.Fatalf("In-flight request should complete on old runtime, got %v", r.body)
+	}
+}
+
+// TestBlueGreenDefersScheduledJobs verifies a replacement runtime does not
+// fire jobs while it boots next to the runtime still serving
+func TestBlueGreenDefersScheduledJobs(t *testing.T) {
+	manager, cleanup := createTestManager(t)
+	defer cleanup()
+
+	projectID := primitive.NewObjectID()
+
+	code := `
+		var fired = 0;
+		$schedule.every("1s", function() { fired++; });
+		$router.get("/fired", function(ctx) { return ctx.response(200, String(fired)); });
+		$service.boot(function() {
+			var start = Date.now();
+			while (Date.now() - start < %d) {}
+		});
+	`
+
+	if err := manager.Start(context.Background(), projectID, mainFile(fmt.Sprintf(code, 0))); err != nil {
+		t.Fatalf("Failed to start runtime: %v", err)
+	}
+	if err := manager.Start(context.Background(), projectID, mainFile(fmt.Sprintf(code, 1500))); err != nil {
+		t.Fatalf("Failed to restart runtime: %v", err)
+	}
+
+	resp, err := manager.HandleRoute(projectID, "GET", "/fired", &modules.RequestContext{Method: "GET", Path: "/fired"})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.Body != "0" {
+		t.Fatalf("Replacement fired %v jobs while booting in standby", resp.Body)
+	}
+}
+
+// TestStorageHooks tests that $hook.onStorage handlers see storage changes,
+// including concurrent ones, and that writes made through ctx.storage do not
+// trigger hooks again
+func TestStorageHooks(t *testing.T) {
+	manager, cleanup := createTestManager(t)
+	defer cleanup()
+
+	storageService := manager.storageService
+	storageService.OnChange(manager.TriggerStorageHook)
+
+	projectID := primitive.NewObjectID()
+	code := `
+		$hook.onStorage('write', 'uploads/**/*.txt', (e, ctx) => {
+			ctx.storage.write('seen/' + e.path.split('/').pop(), e.event + ' ' + e.mimeType + ' ' + e.size);
+		});
+		$hook.onStorage('write', '**', (e, ctx) => {
+			ctx.storage.append('all.log', e.path + '\n');
+		});
+		$hook.onStorage('rename', 'uploads/*', (e, ctx) => {
+			ctx.storage.write('renamed', e.oldPath + ' -> ' + e.path);
+		});
+		$service.start(function() {});
+	`
+	if err := manager.Start(context.Background(), projectID, mainFile(code)); err != nil {
+		t.Fatalf("Failed to start runtime: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	pid := projectID.Hex()
+	if err := storageService.Write(pid, "uploads/docs/a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageService.Move(pid, "uploads/docs", "uploads/texts"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent changes are all handled
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			storageService.Write(pid, fmt.Sprintf("in/%d.bin", i), []byte("x"))
+		}()
+	}
+	wg.Wait()
+
+	// Handlers run after the change, on the hook worker
+	read := func(path string, done func(string) bool) string {
+		t.Helper()
+		var content string
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			data, err := storageService.Read(pid, path)
+			if content = string(data); err == nil && done(content) {
+				break
+			}
+		}
+		return content
+	}
+	lines := func(n int) func(string) bool {
+		return func(s string) bool { return strings.Count(s, "\n") >= n }
+	}
+	found := func(string) bool { return true }
+
+	if got := read("seen/a.txt", found); got != "write text/plain 5" {
+		t.Errorf("write hook got %q", got)
+	}
+	if got := read("renamed", found); got != "uploads/docs -> uploads/texts" {
+		t.Errorf("rename hook got %q", got)
+	}
+	log := read("all.log", lines(6))
+	if !strings.HasPrefix(log, "uploads/docs/a.txt\n") || strings.Count(log, "\n") != 6 {
+		t.Errorf("catch-all hook got %q, want only the 6 outside writes", log)
+	}
+	for i := range 5 {
+		if !strings.Contains(log, fmt.Sprintf("in/%d.bin\n", i)) {
+			t.Errorf("catch-all hook missed in/%d.bin: %q", i, log)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/runtime/modules"
+)
+
+// setupAuth registers $auth without a user store, signing with AUTH_SECRET
+func setupAuth(h *JSTestHelper, secret string) {
+	env := modules.NewEnvModule(func() map[string]interface{} {
+		return map[string]interface{}{"AUTH_SECRET": secret}
+	})
+	modules.NewAuthModule(nil, primitive.NewObjectID(), env, nil).Register(h.VM)
+}
+
+func TestJS_Auth_Passwords(t *testing.T) {
+	h := NewJSTestHelper(t)
+	setupAuth(h, "secret")
+
+	result := h.MustRun(t, `
+		const bcrypt = $auth.hashPassword("correct horse");
+		const argon = $auth.hashPassword("correct horse", "argon2id");
+		[
+			bcrypt.startsWith("$2a$"),
+			argon.startsWith("$argon2id$v=19$m=19456,t=2,p=1$"),
+			$auth.verifyPassword("correct horse", bcrypt),
+			$auth.verifyPassword("correct horse", argon),
+			$auth.verifyPassword("wrong", bcrypt),
+			$auth.verifyPassword("wrong", argon),
+			$auth.verifyPassword("correct horse", "$argon2id$v=19$m=0,t=0,p=0$AA$AA"),
+			$auth.verifyPassword("correct horse", "plain"),
+		]
+	`)
+
+	want := []bool{true, true, true, true, false, false, false, false}
+	got := result.Export().([]interface{})
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("check %d: got %v, want %v", i, got[i], w)
+		}
+	}
+
+	if _, err := h.Run(`$auth.configure({hash: "md5"})`); err == nil {
+		t.Error("configure should reject unknown hash algorithms")
+	}
+}
+
+func TestJS_Auth_SignVerify(t *testing.T) {
+	h := NewJSTestHelper(t)
+	setupAuth(h, "secret")
+
+	result := h.MustRun(t, `
+		const token = $auth.sign({purpose: "verify-email", email: "ann@example.com"}, {expiresIn: "1h"});
+		const claims = $auth.verify(token);
+		const expired = $auth.sign({exp: Math.floor(Date.now() / 1000) - 10});
+		const parts = token.split(".");
+		({
+			purpose: claims.purpose,
+			lifetime: claims.exp - claims.iat,
+			tampered: $auth.verify(parts[0] + "." + parts[1] + "." + parts[2].slice(0, -2) + "xx"),
+			expired: $auth.verify(expired),
+			garbage: $auth.verify("not a token"),
+		})
+	`).Export().(map[string]interface{})
+
+	if result["purpose"] != "verify-email" {
+		t.Errorf("purpose: %v", result["purpose"])
+	}
+	if result["lifetime"] != int64(3600) {
+		t.Errorf("lifetime: %v", result["lifetime"])
+	}
+	for _, key := range []string{"tampered", "expired", "garbage"} {
+		if result[key] != nil {
+			t.Errorf("%s token verified: %v", key, result[key])
+		}
+	}
+
+	// Another secret does not accept the token
+	token := h.MustRun(t, `$auth.sign({sub: "x"})`).String()
+	other := NewJSTestHelper(t)
+	setupAuth(other, "other")
+	other.VM.Set("token", token)
+	if v := other.MustRun(t, `$auth.verify(token)`); v.Export() != nil {
+		t.Errorf("token of another secret verified: %v", v.Export())
+	}
+}
+
+func TestJS_Auth_NoSecret(t *testing.T) {
+	h := NewJSTestHelper(t)
+	setupAuth(h, "")
+
+	if _, err := h.Run(`$auth.sign({a: 1})`); err == nil {
+		t.Error("signing without a secret or user store should fail")
+	}
+}
+
+func TestJS_Auth_Middleware(t *testing.T) {
+	h := NewJSTestHelper(t)
+	routerModule := h.SetupRouter()
+	setupAuth(h, "secret")
+
+	h.MustRun(t, `
+		$router.use("/api", $auth.middleware());
+		$router.use("/public", $auth.middleware({optional: true}));
+		$router.get("/api/me", (ctx) => ({user: ctx.user}));
+		$router.get("/public/me", (ctx) => ({signedIn: !!ctx.user}));
+	`)
+	// Signed with the secret, but not a user token
+	token := h.MustRun(t, `$auth.sign({purpose: "other"})`).String()
+
+	for _, header := range []string{"", "Bearer " + token, "Bearer broken", "Basic dXNlcg=="} {
+		ctx := &modules.RequestContext{Method: "GET", Path: "/api/me", Headers: map[string]string{"Authorization": header}}
+		resp, err := routerModule.Handle("GET", "/api/me", ctx)
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if resp.Status != 401 {
+			t.Errorf("%q: status %d, want 401", header, resp.Status)
+		}
+		if body, ok := resp.Body.(map[string]interface{}); !ok || body["error"] != "unauthorized" {
+			t.Errorf("%q: body %v", header, resp.Body)
+		}
+	}
+
+	ctx := &modules.RequestContext{Method: "GET", Path: "/public/me", Headers: map[string]string{}}
+	resp, err := routerModule.Handle("GET", "/public/me", ctx)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if resp.Status != 200 || resp.Body.(map[string]interface{})["signedIn"] != false {
+		t.Errorf("optional middleware: %d %v", resp.Status, resp.Body)
+	}
+}
+
+func TestJS_Auth_SignCannotLogIn(t *testing.T) {
+	h := NewJSTestHelper(t)
+	setupAuth(h, "secret")
+
+	if _, err := h.Run(`$auth.sign({sub: "` + primitive.NewObjectID().Hex() + `", ver: 0, typ: "session"})`); err == nil {
+		t.Error("sign accepted the reserved typ claim")
+	}
+
+	// Without typ the claims are not looked up as a user at all
+	v := h.MustRun(t, `$auth.authenticate($auth.sign({sub: "`+primitive.NewObjectID().Hex()+`", ver: 0}))`)
+	if v.Export() != nil {
+		t.Errorf("signed claims authenticated: %v", v.Export())
+	}
+}
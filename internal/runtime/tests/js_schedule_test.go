@@ -205,6 +205,48 @@ func TestSchedule_At_UsesUTCTime(t *testing.T) {
 	}
 }
 
+// TestSchedule_UsesProjectTimezone verifies that presets run in the project
+// timezone and that a job can override it
+func TestSchedule_UsesProjectTimezone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	h := NewTestScheduleHelper(t)
+	h.Schedule.SetLocation(moscow)
+
+	tests := []struct {
+		name     string
+		code     string
+		timezone string
+	}{
+		{"project timezone", `$schedule.at("09:00", function() {})`, "Europe/Moscow"},
+		{"daily override", `$schedule.daily(function() {}, { timezone: "America/New_York" })`, "America/New_York"},
+		{"cron override", `$schedule.cron("0 9 * * *", function() {}, { timezone: "UTC" })`, "UTC"},
+		{"explicit CRON_TZ", `$schedule.cron("CRON_TZ=Asia/Tokyo 0 9 * * *", function() {})`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobID := h.MustRun(t, tt.code).String()
+			if jobID == "" {
+				t.Fatal("Failed to create job")
+			}
+			defer h.Schedule.Cancel(jobID)
+
+			info := h.Schedule.Get(jobID)
+			if got, _ := info["timezone"].(string); got != tt.timezone {
+				t.Errorf("timezone = %q, want %q", got, tt.timezone)
+			}
+		})
+	}
+
+	if jobID := h.MustRun(t, `$schedule.daily(function() {}, { timezone: "Mars/Olympus" })`).String(); jobID != "" {
+		t.Errorf("daily with an unknown timezone returned job %q", jobID)
+	}
+}
+
 // ============== CRON EXPRESSION TESTS ==============
 
 func TestSchedule_Cron_ParsesCorrectly(t *testing.T) {
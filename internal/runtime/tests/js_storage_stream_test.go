@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/runtime/modules"
+	"github.com/levskiy0/m3m/internal/service"
+	"github.com/levskiy0/m3m/internal/storage"
+)
+
+// ============== STORAGE STREAMING TESTS ==============
+
+func newStreamingTestHelper(t *testing.T) (*JSTestHelper, *service.StorageService, *modules.StorageModule) {
+	t.Helper()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageService := service.NewStorageService(&config.Config{}, driver)
+
+	h := NewJSTestHelper(t)
+	storageModule := modules.NewStorageModule(storageService, "p1")
+	storageModule.Register(h.VM)
+	modules.NewHTTPModule(30*time.Second, storageService, "p1").Register(h.VM)
+	return h, storageService, storageModule
+}
+
+func TestJS_Storage_StreamLinesAndRanges(t *testing.T) {
+	h, storageService, _ := newStreamingTestHelper(t)
+
+	result := h.MustRun(t, `
+		var out = $storage.openWrite("data/rows.csv");
+		out.write("id,name\n");
+		for (var i = 1; i <= 1000; i++) {
+			out.write(i + ",row" + i + "\r\n");
+		}
+		var written = out.close();
+
+		var file = $storage.open("data/rows.csv");
+		var sum = 0;
+		var lines = file.readLines(function(line, index) {
+			if (index > 0) sum += parseInt(line.split(",")[0], 10);
+		});
+		file.close();
+
+		var partial = $storage.open("data/rows.csv");
+		var first = [];
+		partial.readLines(function(line) {
+			first.push(line);
+			return first.length < 2 ? undefined : false;
+		});
+		var next = partial.read(6);
+		partial.close();
+
+		var range = $storage.open("data/rows.csv", { offset: 3, length: 4 });
+		var chunk = range.read();
+		var end = range.read();
+		range.close();
+
+		JSON.stringify([written > 0, lines, sum, first, next, range.size, chunk, end]);
+	`)
+	want := `[true,1001,500500,["id,name","1,row1"],"2,row2",4,"name",null]`
+	if got := result.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	h.MustRun(t, `
+		var log = $storage.openWrite("data/log.txt", { append: true });
+		log.write("a");
+		log.close();
+		log = $storage.openWrite("data/log.txt", { append: true });
+		log.writeBase64("Yg==");
+		log.close();
+
+		var aborted = $storage.openWrite("data/rows.csv");
+		aborted.write("lost");
+		aborted.abort();
+	`)
+	if data, _ := storageService.Read("p1", "data/log.txt"); string(data) != "ab" {
+		t.Errorf("appended content = %q, want ab", data)
+	}
+	if info, _ := storageService.Stat("p1", "data/rows.csv"); info == nil || info.Size < 1000 {
+		t.Errorf("aborted writer replaced the file: %+v", info)
+	}
+}
+
+func TestJS_Storage_StreamsClosedOnStop(t *testing.T) {
+	h, storageService, storageModule := newStreamingTestHelper(t)
+
+	h.MustRun(t, `
+		var pending = $storage.openWrite("pending.txt");
+		pending.write("never stored");
+		$storage.tmp.openWrite("x.txt").write("x");
+	`)
+	storageModule.Close()
+
+	if storageService.Exists("p1", "pending.txt") {
+		t.Error("unclosed writer was stored after Close")
+	}
+	if _, err := h.Run(`pending.write("more")`); err == nil {
+		t.Error("expected writes after Close to fail")
+	}
+}
+
+func TestJS_HTTP_StreamDownloadAndUpload(t *testing.T) {
+	payload := strings.Repeat("0123456789", 10000)
+	var uploaded, uploadedType, formField, formFile string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			w.Write([]byte(payload))
+		case "/raw":
+			data, _ := io.ReadAll(r.Body)
+			uploaded, uploadedType = string(data), r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusCreated)
+		case "/form":
+			file, header, err := r.FormFile("upload")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			formFile = header.Filename + ":" + string(data[:10])
+			formField = r.FormValue("kind")
+		}
+	}))
+	defer server.Close()
+
+	h, storageService, _ := newStreamingTestHelper(t)
+	h.VM.Set("BASE", server.URL)
+
+	result := h.MustRun(t, `
+		var download = $http.download(BASE + "/file", "downloads/data.txt");
+		var raw = $http.upload(BASE + "/raw", "downloads/data.txt", { method: "PUT", contentType: "text/csv" });
+		var form = $http.upload(BASE + "/form", "downloads/data.txt", { field: "upload", fields: { kind: "digits" } });
+		JSON.stringify([download.status, download.body, raw.status, form.status]);
+	`)
+	if got, want := result.String(), `[200,"downloads/data.txt",201,200]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if data, _ := storageService.Read("p1", "downloads/data.txt"); string(data) != payload {
+		t.Errorf("downloaded %d bytes, want %d", len(data), len(payload))
+	}
+	if uploaded != payload || uploadedType != "text/csv" {
+		t.Errorf("raw upload got %d bytes of %q", len(uploaded), uploadedType)
+	}
+	if formFile != "data.txt:0123456789" || formField != "digits" {
+		t.Errorf("form upload got file %q and field %q", formFile, formField)
+	}
+}
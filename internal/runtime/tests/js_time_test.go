@@ -2,18 +2,47 @@ package tests
 
 import (
 	"bufio"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dop251/goja"
+
+	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/runtime/modules"
 )
 
 // ============== TIME FORMAT TESTS ==============
 
-// TestLogger_TimestampFormat verifies that logger uses UTC time format
+// readLogEntries reads structured log records written by the logger
+func readLogEntries(t *testing.T, logPath string) []domain.LogEntry {
+	t.Helper()
+
+	file, err := os.Open(logPath)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []domain.LogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry domain.LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Log line is not a JSON record: %s", scanner.Text())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// TestLogger_TimestampFormat verifies that logger uses UTC RFC3339 timestamps
 func TestLogger_TimestampFormat(t *testing.T) {
 	// Create temp log file
 	tmpDir := t.TempDir()
@@ -27,52 +56,36 @@ func TestLogger_TimestampFormat(t *testing.T) {
 	logger.Info("test message")
 	after := time.Now().UTC()
 
-	// Read the log file
-	content, err := os.ReadFile(logPath)
-	if err != nil {
-		t.Fatalf("Failed to read log file: %v", err)
+	entries := readLogEntries(t, logPath)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 log record, got %d", len(entries))
 	}
+	entry := entries[0]
 
-	logLine := string(content)
-	t.Logf("Log line: %s", logLine)
-
-	// Expected format: [2006-01-02 15:04:05] [INFO] test message
-	// This is UTC time without timezone suffix
-	pattern := `^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] \[INFO\] test message`
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(logLine)
+	if entry.Level != domain.LogLevelInfo || entry.Message != "test message" {
+		t.Errorf("Unexpected record: %+v", entry)
+	}
 
-	if len(matches) < 2 {
-		t.Fatalf("Log line doesn't match expected format: %s", logLine)
+	// Timestamp must be UTC ("Z" suffix)
+	if !strings.HasSuffix(entry.Timestamp, "Z") {
+		t.Errorf("Timestamp %s is not UTC", entry.Timestamp)
 	}
 
-	// Parse the timestamp as UTC
-	timestampStr := matches[1]
-	timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", timestampStr, time.UTC)
+	timestamp, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
 	if err != nil {
-		t.Fatalf("Failed to parse timestamp %s: %v", timestampStr, err)
+		t.Fatalf("Failed to parse timestamp %s: %v", entry.Timestamp, err)
 	}
 
-	t.Logf("Parsed timestamp (UTC): %s", timestamp.Format("2006-01-02 15:04:05 MST"))
-	t.Logf("Before (UTC): %s", before.Format("2006-01-02 15:04:05 MST"))
-	t.Logf("After (UTC): %s", after.Format("2006-01-02 15:04:05 MST"))
-
-	// Verify timestamp is within expected range (UTC time)
+	// Verify timestamp is within expected range
 	if timestamp.Before(before.Add(-time.Second)) || timestamp.After(after.Add(time.Second)) {
 		t.Errorf("Timestamp %s is not within expected range [%s, %s]",
 			timestamp.Format("15:04:05"),
 			before.Format("15:04:05"),
 			after.Format("15:04:05"))
 	}
-
-	// Verify format uses UTC time (hour should match UTC hour)
-	if timestamp.Hour() != before.Hour() {
-		t.Errorf("Log timestamp hour %d doesn't match UTC hour %d",
-			timestamp.Hour(), before.Hour())
-	}
 }
 
-// TestLogger_MultipleMessages tests multiple log messages have consistent timestamps
+// TestLogger_MultipleMessages tests multiple log messages are written as separate records
 func TestLogger_MultipleMessages(t *testing.T) {
 	tmpDir := t.TempDir()
 	logPath := filepath.Join(tmpDir, "test.log")
@@ -86,72 +99,95 @@ func TestLogger_MultipleMessages(t *testing.T) {
 	logger.Warn("warn message")
 	logger.Error("error message")
 
-	// Read and verify all timestamps are in local format
-	file, err := os.Open(logPath)
-	if err != nil {
-		t.Fatalf("Failed to open log file: %v", err)
+	entries := readLogEntries(t, logPath)
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 log records, got %d", len(entries))
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	pattern := regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] \[(DEBUG|INFO|WARN|ERROR)\]`)
-
-	lineNum := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNum++
 
-		if !pattern.MatchString(line) {
-			t.Errorf("Line %d doesn't match timestamp format: %s", lineNum, line)
+	levels := []domain.LogLevel{domain.LogLevelDebug, domain.LogLevelInfo, domain.LogLevelWarn, domain.LogLevelError}
+	for i, entry := range entries {
+		if entry.Level != levels[i] {
+			t.Errorf("Record %d: expected level %s, got %s", i, levels[i], entry.Level)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err != nil {
+			t.Errorf("Record %d has invalid timestamp %s", i, entry.Timestamp)
 		}
-	}
-
-	if lineNum != 4 {
-		t.Errorf("Expected 4 log lines, got %d", lineNum)
 	}
 }
 
-// TestLogger_TimestampIsUTC verifies timestamp IS in UTC
-func TestLogger_TimestampIsUTC(t *testing.T) {
-	// Skip if server is in UTC (can't distinguish)
-	_, offset := time.Now().Zone()
-	if offset == 0 {
-		t.Skip("Server is in UTC timezone, cannot verify UTC vs local")
-	}
-
+// TestLogger_FieldsAndScope verifies $logger fields and route/job attribution
+func TestLogger_FieldsAndScope(t *testing.T) {
 	tmpDir := t.TempDir()
 	logPath := filepath.Join(tmpDir, "test.log")
 
 	logger := modules.NewLoggerModule(logPath)
 	defer logger.Close()
 
-	now := time.Now()
-	logger.Info("test")
+	vm := goja.New()
+	logger.Register(vm)
 
-	content, err := os.ReadFile(logPath)
-	if err != nil {
-		t.Fatalf("Failed to read log file: %v", err)
+	vm.Set("ctx", map[string]interface{}{"logger": logger.Scoped("route:GET /orders/:id", "req-1")})
+	if _, err := vm.RunString(`ctx.logger.info("order paid", {orderId: 42, region: "eu"})`); err != nil {
+		t.Fatalf("Failed to run script: %v", err)
+	}
+
+	if _, err := vm.RunString(`$logger.warn("user", "logged in"); console.log("plain", {a: 1})`); err != nil {
+		t.Fatalf("Failed to run script: %v", err)
+	}
+
+	entries := readLogEntries(t, logPath)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 log records, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Message != "order paid" || first.Source != "route:GET /orders/:id" || first.RequestID != "req-1" {
+		t.Errorf("Unexpected first record: %+v", first)
+	}
+	if first.Fields["region"] != "eu" || first.Fields["orderId"] != float64(42) {
+		t.Errorf("Unexpected fields: %v", first.Fields)
+	}
+
+	if entries[1].Message != "user logged in" || entries[1].Source != "" || entries[1].Fields != nil {
+		t.Errorf("Unexpected second record: %+v", entries[1])
 	}
 
-	// Extract timestamp
-	pattern := regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\]`)
-	matches := pattern.FindStringSubmatch(string(content))
-	if len(matches) < 2 {
-		t.Fatal("Could not extract timestamp")
+	// console keeps the old behavior and renders objects into the message
+	if entries[2].Message != `plain {"a":1}` || entries[2].Fields != nil {
+		t.Errorf("Unexpected console record: %+v", entries[2])
 	}
+}
 
-	// Parse as UTC time
-	utcTs, _ := time.ParseInLocation("2006-01-02 15:04:05", matches[1], time.UTC)
+// TestLogger_ConcurrentScopes verifies that concurrent invocations keep
+// their own source and request id
+func TestLogger_ConcurrentScopes(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+	logger := modules.NewLoggerModule(logPath)
+	defer logger.Close()
 
-	// The hour should match UTC hour, not local hour
-	t.Logf("Server local time: %s", now.Format("15:04:05 MST"))
-	t.Logf("Server UTC time: %s", now.UTC().Format("15:04:05 MST"))
-	t.Logf("Log timestamp: %s", matches[1])
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requestID := fmt.Sprintf("req-%d", i)
+			info := logger.Scoped("route:GET /"+requestID, requestID)["info"].(func(...interface{}))
+			for j := 0; j < 10; j++ {
+				info(requestID)
+				runtime.Gosched()
+			}
+		}()
+	}
+	wg.Wait()
 
-	// Log hour should match UTC hour
-	if utcTs.Hour() != now.UTC().Hour() {
-		t.Errorf("Log hour %d doesn't match UTC hour %d (local would be %d)",
-			utcTs.Hour(), now.UTC().Hour(), now.Hour())
+	entries := readLogEntries(t, logPath)
+	if len(entries) != 200 {
+		t.Fatalf("Expected 200 log records, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.RequestID != entry.Message || entry.Source != "route:GET /"+entry.Message {
+			t.Fatalf("Record attributed to the wrong scope: %+v", entry)
+		}
 	}
 }
 
@@ -174,6 +210,44 @@ func TestUtils_Timestamp_ReturnsLocalTime(t *testing.T) {
 	t.Logf("Timestamp as local time: %s", tsTime.Local().Format("2006-01-02 15:04:05 MST"))
 }
 
+// TestUtils_FormatDate_UsesProjectTimezone verifies that formatDate and parseDate
+// default to the project timezone and accept an override
+func TestUtils_FormatDate_UsesProjectTimezone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	vm := goja.New()
+	utils := modules.NewUtilsModule()
+	utils.SetLocation(moscow)
+	utils.Register(vm)
+
+	ts := time.Date(2025, 12, 12, 21, 30, 0, 0, time.UTC).UnixMilli()
+	vm.Set("ts", ts)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`$utils.formatDate(ts, "YYYY-MM-DD HH:mm")`, "2025-12-13 00:30"},
+		{`$utils.formatDate(ts, "YYYY-MM-DD HH:mm", "UTC")`, "2025-12-12 21:30"},
+		{`$utils.formatDate(ts, "HH:mm", "America/New_York")`, "16:30"},
+		{`String($utils.parseDate("2025-12-13 00:30", "YYYY-MM-DD HH:mm") === ts)`, "true"},
+		{`String($utils.parseDate("2025-12-12 21:30", "YYYY-MM-DD HH:mm", "UTC") === ts)`, "true"},
+	}
+
+	for _, tt := range tests {
+		result, err := vm.RunString(tt.code)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.code, err)
+		}
+		if got := result.String(); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
 // ============== DATE PARSING TESTS ==============
 
 // TestDateParsing_ISO8601 tests various date format parsing
@@ -223,3 +297,49 @@ func TestDateParsing_ServerTime(t *testing.T) {
 		t.Log("Warning: time.Now() might be using UTC instead of local time")
 	}
 }
+
+// TestLogger_Rotation verifies the log file is rotated once it exceeds the max size
+func TestLogger_Rotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "test.log")
+
+	logger := modules.NewLoggerModule(logPath)
+	logger.SetMaxFileSize(200)
+
+	closed := 0
+	logger.SetOnClose(func() { closed++ })
+
+	for i := 0; i < 5; i++ {
+		logger.Info("a message that takes some room in the log file")
+	}
+	logger.Close()
+	logger.Close()
+
+	if closed != 1 {
+		t.Errorf("Expected close callback to run once, got %d", closed)
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(tmpDir, "test.*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) == 0 {
+		t.Fatal("Expected rotated log files")
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Expected current log file to exist: %v", err)
+	}
+	if info.Size() > 200 {
+		t.Errorf("Expected current log file below max size, got %d bytes", info.Size())
+	}
+
+	total := len(readLogEntries(t, logPath))
+	for _, path := range rotated {
+		total += len(readLogEntries(t, path))
+	}
+	if total != 5 {
+		t.Errorf("Expected 5 records across rotated files, got %d", total)
+	}
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/runtime/modules"
+)
+
+// memoryWebhookLog keeps deliveries in memory
+type memoryWebhookLog struct {
+	mu         sync.Mutex
+	deliveries []*domain.WebhookDelivery
+}
+
+func (l *memoryWebhookLog) Record(delivery *domain.WebhookDelivery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delivery.ID = primitive.NewObjectID()
+	l.deliveries = append(l.deliveries, delivery)
+}
+
+func (l *memoryWebhookLog) Delivered(webhook, deliveryID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, d := range l.deliveries {
+		if d.Webhook == webhook && d.DeliveryID == deliveryID && d.Status == domain.WebhookDelivered {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *memoryWebhookLog) last() *domain.WebhookDelivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.deliveries[len(l.deliveries)-1]
+}
+
+func hmacHex(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func postWebhook(t *testing.T, router *modules.RouterModule, path, body string, headers map[string]string) *modules.ResponseData {
+	t.Helper()
+	ctx := &modules.RequestContext{Method: "POST", Path: path, Headers: headers, Body: body, RawBody: []byte(body)}
+	resp, err := router.Handle("POST", path, ctx)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	return resp
+}
+
+func setupWebhooks(t *testing.T) (*JSTestHelper, *modules.RouterModule, *memoryWebhookLog) {
+	h := NewJSTestHelper(t)
+	router := h.SetupRouter()
+	log := &memoryWebhookLog{}
+	router.SetWebhookLog(log)
+	return h, router, log
+}
+
+func TestJS_Webhook_Stripe(t *testing.T) {
+	h, router, log := setupWebhooks(t)
+	h.MustRun(t, `
+		var handled = [];
+		$router.webhook("/stripe", {provider: "stripe", secret: "whsec_test"}, (ctx) => {
+			handled.push(ctx.webhook.id);
+			return {received: ctx.webhook.payload.type, size: ctx.rawBody.byteLength};
+		});
+	`)
+
+	body := `{"id":"evt_1","type":"charge.succeeded"}`
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	valid := map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + hmacHex("whsec_test", ts+"."+body)}
+
+	resp := postWebhook(t, router, "/stripe", body, valid)
+	if resp.Status != 200 || resp.Body.(map[string]interface{})["received"] != "charge.succeeded" {
+		t.Fatalf("valid delivery: %d %v", resp.Status, resp.Body)
+	}
+	if d := log.last(); d.Status != domain.WebhookDelivered || d.DeliveryID != "evt_1" || d.Provider != "stripe" {
+		t.Errorf("recorded %+v", d)
+	}
+
+	// The same event again is acknowledged without running the handler
+	resp = postWebhook(t, router, "/stripe", body, valid)
+	if resp.Status != 200 || resp.Body.(map[string]interface{})["duplicate"] != true {
+		t.Errorf("duplicate: %d %v", resp.Status, resp.Body)
+	}
+	if log.last().Status != domain.WebhookDuplicate {
+		t.Errorf("duplicate recorded as %s", log.last().Status)
+	}
+
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	for name, headers := range map[string]map[string]string{
+		"missing":  {},
+		"tampered": {"Stripe-Signature": "t=" + ts + ",v1=" + hmacHex("whsec_test", ts+"."+body+" ")},
+		"secret":   {"Stripe-Signature": "t=" + ts + ",v1=" + hmacHex("other", ts+"."+body)},
+		"expired":  {"Stripe-Signature": "t=" + old + ",v1=" + hmacHex("whsec_test", old+"."+body)},
+	} {
+		resp := postWebhook(t, router, "/stripe", body, headers)
+		if resp.Status != 401 {
+			t.Errorf("%s: status %d, want 401", name, resp.Status)
+		}
+		if log.last().Status != domain.WebhookRejected {
+			t.Errorf("%s: recorded as %s", name, log.last().Status)
+		}
+	}
+
+	if got := h.MustRun(t, `handled.length`).ToInteger(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+}
+
+func TestJS_Webhook_GitHub(t *testing.T) {
+	h, router, log := setupWebhooks(t)
+	h.MustRun(t, `$router.webhook("/github", {provider: "github", secret: "s3cret"}, (ctx) => ({ok: true}));`)
+
+	body := `{"action":"opened"}`
+	resp := postWebhook(t, router, "/github", body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + hmacHex("s3cret", body),
+		"X-Github-Delivery":   "72d3162e",
+		"Authorization":       "Bearer leaked",
+	})
+	if resp.Status != 200 {
+		t.Fatalf("status %d: %v", resp.Status, resp.Body)
+	}
+	d := log.last()
+	if d.DeliveryID != "72d3162e" || d.Headers["Authorization"] != "[redacted]" {
+		t.Errorf("recorded %+v", d)
+	}
+
+	resp = postWebhook(t, router, "/github", body, map[string]string{"X-Hub-Signature-256": "sha256=00"})
+	if resp.Status != 401 {
+		t.Errorf("bad signature: status %d", resp.Status)
+	}
+}
+
+func TestJS_Webhook_Telegram(t *testing.T) {
+	h, router, log := setupWebhooks(t)
+	h.MustRun(t, `$router.webhook("/telegram", {provider: "telegram", secret: "tg-token"}, (ctx) => ({update: ctx.webhook.payload.update_id}));`)
+
+	body := `{"update_id":123456789,"message":{"text":"hi"}}`
+	resp := postWebhook(t, router, "/telegram", body, map[string]string{"X-Telegram-Bot-Api-Secret-Token": "tg-token"})
+	if resp.Status != 200 {
+		t.Fatalf("status %d: %v", resp.Status, resp.Body)
+	}
+	d := log.last()
+	if d.DeliveryID != "123456789" || d.Headers["X-Telegram-Bot-Api-Secret-Token"] != "[redacted]" {
+		t.Errorf("recorded %+v", d)
+	}
+
+	resp = postWebhook(t, router, "/telegram", body, map[string]string{"X-Telegram-Bot-Api-Secret-Token": "wrong"})
+	if resp.Status != 401 {
+		t.Errorf("wrong token: status %d", resp.Status)
+	}
+}
+
+func TestJS_Webhook_HMAC(t *testing.T) {
+	h, router, _ := setupWebhooks(t)
+	h.MustRun(t, `
+		$router.webhook("/hooks/:source", {
+			secret: "key",
+			header: "X-Sig",
+			timestampHeader: "X-Timestamp",
+			idHeader: "X-Id",
+		}, (ctx) => ({source: ctx.params.source, id: ctx.webhook.id}));
+	`)
+
+	body := "raw\x00bytes"
+	ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	resp := postWebhook(t, router, "/hooks/crm", body, map[string]string{
+		"X-Sig":       "sha256=" + hmacHex("key", ts+"."+body),
+		"X-Timestamp": ts,
+		"X-Id":        "1",
+	})
+	if resp.Status != 200 || resp.Body.(map[string]interface{})["source"] != "crm" {
+		t.Errorf("valid delivery: %d %v", resp.Status, resp.Body)
+	}
+
+	// Unsigned timestamp
+	resp = postWebhook(t, router, "/hooks/crm", body, map[string]string{"X-Sig": hmacHex("key", body), "X-Timestamp": ts, "X-Id": "2"})
+	if resp.Status != 401 {
+		t.Errorf("unsigned timestamp: status %d", resp.Status)
+	}
+
+	for name, code := range map[string]string{
+		"no secret":        `$router.webhook("/a", {provider: "github"}, () => {})`,
+		"unknown provider": `$router.webhook("/a", {provider: "paypal", secret: "x"}, () => {})`,
+		"unknown algo":     `$router.webhook("/a", {secret: "x", algo: "md5"}, () => {})`,
+	} {
+		if _, err := h.Run(code); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestJS_Webhook_FailureAndReplay(t *testing.T) {
+	h, router, log := setupWebhooks(t)
+	h.MustRun(t, `
+		var fail = true;
+		var runs = [];
+		$router.webhook("/hook", {secret: "key", idHeader: "X-Id"}, (ctx) => {
+			runs.push(ctx.webhook.replay);
+			if (fail) throw new Error("database down");
+			return {ok: true};
+		});
+	`)
+
+	body := `{"n":1}`
+	headers := map[string]string{"X-Signature": hmacHex("key", body), "X-Id": "d1"}
+	ctx := &modules.RequestContext{Method: "POST", Path: "/hook", Headers: headers, Body: body, RawBody: []byte(body)}
+	if _, err := router.Handle("POST", "/hook", ctx); err == nil {
+		t.Fatal("expected the handler error")
+	}
+	failed := log.last()
+	if failed.Status != domain.WebhookFailed || failed.ResponseStatus != 500 {
+		t.Errorf("recorded %+v", failed)
+	}
+
+	// A failed delivery does not block the provider's retry
+	h.MustRun(t, `fail = false`)
+	if resp := postWebhook(t, router, "/hook", body, headers); resp.Status != 200 {
+		t.Errorf("retry: status %d %v", resp.Status, resp.Body)
+	}
+
+	// Replays skip verification and duplicate checks
+	replay, err := router.ReplayWebhook(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Status != domain.WebhookDelivered || *replay.ReplayOf != failed.ID || replay.DeliveryID != "d1" {
+		t.Errorf("replay %+v", replay)
+	}
+	if got := fmt.Sprint(h.MustRun(t, `runs`).Export()); got != "[false false true]" {
+		t.Errorf("runs %s", got)
+	}
+
+	if _, err := router.ReplayWebhook(&domain.WebhookDelivery{Webhook: "/gone"}); err != modules.ErrWebhookNotRegistered {
+		t.Errorf("unknown webhook: %v", err)
+	}
+	if _, err := router.ReplayWebhook(&domain.WebhookDelivery{Webhook: "/hook", BodyTruncated: true}); err != modules.ErrWebhookTruncated {
+		t.Errorf("truncated: %v", err)
+	}
+	if _, err := router.ReplayWebhook(&domain.WebhookDelivery{Webhook: "/hook", Status: domain.WebhookRejected}); err != modules.ErrWebhookRejected {
+		t.Errorf("rejected: %v", err)
+	}
+}
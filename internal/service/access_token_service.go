@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+var (
+	ErrAccessTokenName    = errors.New("name is required")
+	ErrInvalidTokenScope  = errors.New("invalid token scope")
+	ErrInvalidTokenExpiry = errors.New("expires_at must be in the future")
+	ErrAccessTokenExpired = errors.New("access token expired")
+)
+
+// accessTokenTouchInterval limits how often the last use of a token is written
+const accessTokenTouchInterval = time.Minute
+
+type AccessTokenService struct {
+	tokenRepo      *repository.AccessTokenRepository
+	userRepo       *repository.UserRepository
+	projectService *ProjectService
+}
+
+func NewAccessTokenService(tokenRepo *repository.AccessTokenRepository, userRepo *repository.UserRepository, projectService *ProjectService) *AccessTokenService {
+	return &AccessTokenService{
+		tokenRepo:      tokenRepo,
+		userRepo:       userRepo,
+		projectService: projectService,
+	}
+}
+
+// Create issues a token for a user. The returned token is the only copy of
+// its secret; projects are limited to the ones the user can access.
+func (s *AccessTokenService) Create(ctx context.Context, user *domain.User, req *domain.CreateAccessTokenRequest) (*domain.CreateAccessTokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrAccessTokenName
+	}
+
+	scopes := make([]domain.ProjectPermission, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(domain.ProjectPermissions, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTokenScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	projectIDs := make([]primitive.ObjectID, 0, len(req.ProjectIDs))
+	for _, id := range req.ProjectIDs {
+		projectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil || !s.projectService.CanUserAccess(ctx, user.ID, projectID, user.IsRoot) {
+			return nil, fmt.Errorf("%w: no access to project %s", ErrInvalidTokenScope, id)
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidTokenExpiry
+	}
+
+	secret, err := newAccessTokenSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &domain.AccessToken{
+		UserID:     user.ID,
+		Name:       name,
+		Hint:       secret[:len(domain.AccessTokenPrefix)+4],
+		Hash:       hashAccessToken(secret),
+		Scopes:     scopes,
+		ProjectIDs: projectIDs,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreateAccessTokenResponse{Token: secret, AccessToken: token}, nil
+}
+
+func (s *AccessTokenService) List(ctx context.Context, userID primitive.ObjectID) ([]*domain.AccessToken, error) {
+	return s.tokenRepo.FindByUser(ctx, userID)
+}
+
+func (s *AccessTokenService) Revoke(ctx context.Context, userID, tokenID primitive.ObjectID) error {
+	return s.tokenRepo.Delete(ctx, userID, tokenID)
+}
+
+// Authenticate resolves a personal access token to its token and user, and
+// records its use
+func (s *AccessTokenService) Authenticate(ctx context.Context, secret, ip string) (*domain.User, *domain.AccessToken, error) {
+	token, err := s.tokenRepo.FindByHash(ctx, hashAccessToken(secret))
+	if err != nil {
+		if errors.Is(err, repository.ErrAccessTokenNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		return nil, nil, ErrAccessTokenExpired
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if user.IsBlocked {
+		return nil, nil, ErrUserBlocked
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= accessTokenTouchInterval || token.LastUsedIP != ip {
+		if err := s.tokenRepo.Touch(ctx, token.ID, now, ip); err != nil {
+			return nil, nil, err
+		}
+		token.LastUsedAt, token.LastUsedIP = &now, ip
+	}
+
+	return user, token, nil
+}
+
+func newAccessTokenSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return domain.AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashAccessToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
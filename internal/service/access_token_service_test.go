@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestAccessTokenAllows(t *testing.T) {
+	allowed, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+	token := &domain.AccessToken{Scopes: []domain.ProjectPermission{domain.PermissionReleases, domain.PermissionCodeEdit}}
+	if !token.Allows(other, domain.PermissionReleases) {
+		t.Error("a token without projects should allow every project")
+	}
+	if token.Allows(other, domain.PermissionRuntime) {
+		t.Error("a token allowed a permission outside its scopes")
+	}
+
+	token.ProjectIDs = []primitive.ObjectID{allowed}
+	if !token.Allows(allowed, domain.PermissionCodeEdit) || token.Allows(other, domain.PermissionCodeEdit) {
+		t.Error("the project restriction was not applied")
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		t.Error("a token without expiry expired")
+	}
+	past := now.Add(-time.Second)
+	token.ExpiresAt = &past
+	if !token.Expired(now) {
+		t.Error("an expired token was accepted")
+	}
+}
+
+func TestAccessTokenSecret(t *testing.T) {
+	secret, err := newAccessTokenSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, domain.AccessTokenPrefix) || len(secret) < len(domain.AccessTokenPrefix)+40 {
+		t.Errorf("unexpected secret %q", secret)
+	}
+	if hashAccessToken(secret) == hashAccessToken(secret+"x") || strings.Contains(hashAccessToken(secret), secret) {
+		t.Error("the hash does not identify the secret")
+	}
+}
+
+func TestAccessTokenCreateValidation(t *testing.T) {
+	s := NewAccessTokenService(nil, nil, nil)
+	user := &domain.User{ID: primitive.NewObjectID()}
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		req  domain.CreateAccessTokenRequest
+		want error
+	}{
+		{domain.CreateAccessTokenRequest{Name: "  ", Scopes: []domain.ProjectPermission{domain.PermissionView}}, ErrAccessTokenName},
+		{domain.CreateAccessTokenRequest{Name: "ci", Scopes: []domain.ProjectPermission{"deploy"}}, ErrInvalidTokenScope},
+		{domain.CreateAccessTokenRequest{Name: "ci", Scopes: []domain.ProjectPermission{domain.PermissionView}, ExpiresAt: &past}, ErrInvalidTokenExpiry},
+	}
+	for _, tc := range cases {
+		if _, err := s.Create(context.Background(), user, &tc.req); !errors.Is(err, tc.want) {
+			t.Errorf("%+v: got %v, want %v", tc.req, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,662 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordan-wright/email"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+const (
+	// alertWebhookTimeout limits webhook deliveries
+	alertWebhookTimeout = 10 * time.Second
+	// alertHistoryLimit is the default and maximum number of history entries returned
+	alertHistoryLimit = 100
+	alertHistoryMax   = 1000
+)
+
+// ErrInvalidAlertRule is returned for rules with an unknown source, metric,
+// operator or channel
+var ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+var (
+	ErrAlertForbiddenWebhook = errors.New("webhook resolves to a loopback, private or link-local address")
+	ErrAlertRecipient        = errors.New("email recipient is not a project member or in alerts.email_allowlist")
+)
+
+// AlertToastHandler shows an alert event to the users viewing its project
+type AlertToastHandler func(event domain.AlertEvent)
+
+type requestSample struct {
+	total int64
+	at    time.Time
+}
+
+// AlertService manages alert rules on goals and runtime metrics. Rules are
+// evaluated by the WebSocket broadcaster loops; state changes are kept in the
+// alert history and sent to the rule's channels.
+type AlertService struct {
+	alertRepo      *repository.AlertRepository
+	goalService    *GoalService
+	envService     *EnvironmentService
+	projectService *ProjectService
+	userRepo       *repository.UserRepository
+	emailAllowlist []string
+	logger         *slog.Logger
+	client         *http.Client
+
+	mu       sync.Mutex
+	toasts   []AlertToastHandler
+	requests map[primitive.ObjectID]requestSample // last request count per project, for rates
+}
+
+func NewAlertService(
+	alertRepo *repository.AlertRepository,
+	goalService *GoalService,
+	envService *EnvironmentService,
+	projectService *ProjectService,
+	userRepo *repository.UserRepository,
+	config *config.Config,
+	logger *slog.Logger,
+) *AlertService {
+	return &AlertService{
+		alertRepo:      alertRepo,
+		goalService:    goalService,
+		envService:     envService,
+		projectService: projectService,
+		userRepo:       userRepo,
+		emailAllowlist: config.Alerts.EmailAllowlist,
+		logger:         logger,
+		client: &http.Client{
+			Timeout: alertWebhookTimeout,
+			Transport: &http.Transport{
+				DialContext: publicDialer(alertWebhookTimeout, ErrAlertForbiddenWebhook).DialContext,
+			},
+		},
+		requests: make(map[primitive.ObjectID]requestSample),
+	}
+}
+
+// OnToast registers a handler for alerts sent to the toast channel
+func (s *AlertService) OnToast(handler AlertToastHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toasts = append(s.toasts, handler)
+}
+
+func (s *AlertService) Create(ctx context.Context, projectID primitive.ObjectID, req *domain.CreateAlertRuleRequest) (*domain.AlertRule, error) {
+	rule := &domain.AlertRule{
+		ProjectID:  projectID,
+		Name:       req.Name,
+		Source:     req.Source,
+		Labels:     req.Labels,
+		Metric:     req.Metric,
+		Operator:   req.Operator,
+		Threshold:  req.Threshold,
+		ForSeconds: req.ForSeconds,
+		CheckAfter: req.CheckAfter,
+		Channels:   req.Channels,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if req.Source == domain.AlertSourceGoal {
+		goalID, err := primitive.ObjectIDFromHex(req.GoalID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid goal id", ErrInvalidAlertRule)
+		}
+		rule.GoalID = &goalID
+	}
+
+	if err := s.validate(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.alertRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *AlertService) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.AlertRule, error) {
+	return s.alertRepo.FindByID(ctx, id)
+}
+
+func (s *AlertService) GetByProject(ctx context.Context, projectID primitive.ObjectID) ([]*domain.AlertRule, error) {
+	return s.alertRepo.FindByProject(ctx, projectID)
+}
+
+// Update changes the settings of a rule. A changed condition starts the rule
+// over, resolving it silently if it was firing.
+func (s *AlertService) Update(ctx context.Context, id primitive.ObjectID, req *domain.UpdateAlertRuleRequest) (*domain.AlertRule, error) {
+	rule, err := s.alertRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	conditionChanged := req.Labels != nil || req.Operator != nil || req.Threshold != nil || req.ForSeconds != nil || req.CheckAfter != nil
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Labels != nil {
+		rule.Labels = *req.Labels
+	}
+	if req.Operator != nil {
+		rule.Operator = *req.Operator
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.ForSeconds != nil {
+		rule.ForSeconds = *req.ForSeconds
+	}
+	if req.CheckAfter != nil {
+		rule.CheckAfter = *req.CheckAfter
+	}
+	if req.Channels != nil {
+		rule.Channels = *req.Channels
+	}
+	if req.Enabled != nil {
+		conditionChanged = conditionChanged || rule.Enabled != *req.Enabled
+		rule.Enabled = *req.Enabled
+	}
+	if conditionChanged {
+		rule.State = domain.AlertState{}
+	}
+
+	if err := s.validate(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.alertRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *AlertService) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.alertRepo.Delete(ctx, id)
+}
+
+// Silence mutes the notifications of a rule. It is still evaluated and its
+// state changes are kept in the history.
+func (s *AlertService) Silence(ctx context.Context, id primitive.ObjectID, req *domain.SilenceAlertRequest) (*domain.AlertRule, error) {
+	rule, err := s.alertRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	if req.Until != nil {
+		until = *req.Until
+	} else if req.Minutes == 0 {
+		return nil, fmt.Errorf("%w: minutes or until is required", ErrInvalidAlertRule)
+	}
+	rule.SilencedUntil = &until
+
+	if err := s.alertRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Unsilence turns the notifications of a rule back on
+func (s *AlertService) Unsilence(ctx context.Context, id primitive.ObjectID) (*domain.AlertRule, error) {
+	rule, err := s.alertRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.SilencedUntil = nil
+	if err := s.alertRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// History returns the newest alert events of a project
+func (s *AlertService) History(ctx context.Context, projectID primitive.ObjectID, query *domain.AlertHistoryQuery) ([]*domain.AlertEvent, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = alertHistoryLimit
+	}
+	if limit > alertHistoryMax {
+		limit = alertHistoryMax
+	}
+
+	var ruleID *primitive.ObjectID
+	if query.RuleID != "" {
+		oid, err := primitive.ObjectIDFromHex(query.RuleID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid rule id", ErrInvalidAlertRule)
+		}
+		ruleID = &oid
+	}
+	return s.alertRepo.FindEvents(ctx, projectID, ruleID, int64(limit))
+}
+
+func (s *AlertService) validate(ctx context.Context, rule *domain.AlertRule) error {
+	switch rule.Source {
+	case domain.AlertSourceGoal:
+		if rule.GoalID == nil {
+			return fmt.Errorf("%w: goal_id is required", ErrInvalidAlertRule)
+		}
+		goal, err := s.goalService.GetByID(ctx, *rule.GoalID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+		}
+		if !goalAllowsProject(goal, rule.ProjectID) {
+			return fmt.Errorf("%w: project does not have access to goal %s", ErrInvalidAlertRule, goal.Slug)
+		}
+		if err := validateGoalLabels(rule.Labels); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+		}
+	case domain.AlertSourceRuntime:
+		if !slices.Contains(domain.AlertMetrics, rule.Metric) {
+			return fmt.Errorf("%w: metric must be one of %s", ErrInvalidAlertRule, strings.Join(domain.AlertMetrics, ", "))
+		}
+	default:
+		return fmt.Errorf("%w: unknown source %q", ErrInvalidAlertRule, rule.Source)
+	}
+
+	if !rule.Operator.Valid() {
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidAlertRule, rule.Operator)
+	}
+	if rule.CheckAfter != "" {
+		if _, err := time.Parse("15:04", rule.CheckAfter); err != nil {
+			return fmt.Errorf("%w: check_after must be HH:MM", ErrInvalidAlertRule)
+		}
+	}
+
+	for _, channel := range rule.Channels {
+		switch channel.Type {
+		case domain.AlertChannelToast:
+		case domain.AlertChannelEmail:
+			if strings.TrimSpace(channel.Target) == "" {
+				return fmt.Errorf("%w: email channel needs a target address", ErrInvalidAlertRule)
+			}
+			if _, err := s.recipients(ctx, rule.ProjectID, channel.Target); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+			}
+		case domain.AlertChannelWebhook:
+			u, err := url.Parse(channel.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+				return fmt.Errorf("%w: webhook channel needs an http(s) URL", ErrInvalidAlertRule)
+			}
+			if err := checkRemoteHost(ctx, u.Hostname(), ErrAlertForbiddenWebhook); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+			}
+		default:
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidAlertRule, channel.Type)
+		}
+	}
+	return nil
+}
+
+func goalAllowsProject(goal *domain.Goal, projectID primitive.ObjectID) bool {
+	if goal.ProjectRef != nil && *goal.ProjectRef == projectID {
+		return true
+	}
+	return slices.Contains(goal.AllowedProjects, projectID)
+}
+
+// EvaluateGoals checks the enabled goal rules of all projects against the
+// current goal values: the total of counters, today's value otherwise
+func (s *AlertService) EvaluateGoals(ctx context.Context, now time.Time) {
+	rules, err := s.alertRepo.FindEnabled(ctx, domain.AlertSourceGoal)
+	if err != nil {
+		s.logger.Warn("Failed to load goal alert rules", "error", err)
+		return
+	}
+
+	goals := make(map[primitive.ObjectID]*domain.Goal)
+	for _, rule := range rules {
+		if rule.GoalID == nil {
+			continue
+		}
+		goal, ok := goals[*rule.GoalID]
+		if !ok {
+			goal, _ = s.goalService.GetByID(ctx, *rule.GoalID)
+			goals[*rule.GoalID] = goal
+		}
+		if goal == nil {
+			continue
+		}
+
+		local := s.localTime(ctx, rule, now)
+		value, err := s.goalValue(ctx, goal, rule, local)
+		if err != nil {
+			s.logger.Warn("Failed to read goal for alert", "rule", rule.ID.Hex(), "error", err)
+			continue
+		}
+		s.apply(ctx, rule, goal.Slug, value, local)
+	}
+}
+
+func (s *AlertService) goalValue(ctx context.Context, goal *domain.Goal, rule *domain.AlertRule, now time.Time) (float64, error) {
+	date := now.Format("2006-01-02")
+	if goal.Type == domain.GoalTypeCounter {
+		date = "total"
+	}
+
+	labels := make([]string, 0, len(rule.Labels))
+	for key, value := range rule.Labels {
+		labels = append(labels, key+"="+value)
+	}
+
+	stats, err := s.goalService.GetStats(ctx, &domain.GoalStatsQuery{
+		GoalIDs:   []string{goal.ID.Hex()},
+		ProjectID: rule.ProjectID.Hex(),
+		StartDate: date,
+		EndDate:   date,
+		Labels:    labels,
+	})
+	if err != nil || len(stats) == 0 {
+		return 0, err
+	}
+	return stats[0].ValueFor(goal.Type), nil
+}
+
+// EvaluateRuntime checks the enabled runtime rules of the projects sampled.
+// Rules of projects that are not running keep their state.
+func (s *AlertService) EvaluateRuntime(ctx context.Context, samples map[primitive.ObjectID]domain.RuntimeSample, now time.Time) {
+	rates := s.requestRates(samples, now)
+
+	rules, err := s.alertRepo.FindEnabled(ctx, domain.AlertSourceRuntime)
+	if err != nil {
+		s.logger.Warn("Failed to load runtime alert rules", "error", err)
+		return
+	}
+
+	for _, rule := range rules {
+		sample, ok := samples[rule.ProjectID]
+		if !ok {
+			continue
+		}
+
+		var value float64
+		switch rule.Metric {
+		case domain.AlertMetricMemoryMB:
+			value = float64(sample.MemoryBytes) / (1 << 20)
+		case domain.AlertMetricCPUPercent:
+			value = sample.CPUPercent
+		case domain.AlertMetricStorageMB:
+			value = float64(sample.StorageBytes) / (1 << 20)
+		case domain.AlertMetricDatabaseMB:
+			value = float64(sample.DatabaseBytes) / (1 << 20)
+		case domain.AlertMetricRequestsPerMin:
+			if value, ok = rates[rule.ProjectID]; !ok {
+				continue // Needs a previous sample
+			}
+		default:
+			continue
+		}
+		s.apply(ctx, rule, rule.Metric, value, s.localTime(ctx, rule, now))
+	}
+}
+
+// localTime converts now to the timezone of the rule's project, which
+// check_after and goal days follow
+func (s *AlertService) localTime(ctx context.Context, rule *domain.AlertRule, now time.Time) time.Time {
+	return now.In(s.projectService.Location(ctx, rule.ProjectID))
+}
+
+// requestRates turns request counts into requests per minute since the
+// previous sample of each project
+func (s *AlertService) requestRates(samples map[primitive.ObjectID]domain.RuntimeSample, now time.Time) map[primitive.ObjectID]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rates := make(map[primitive.ObjectID]float64, len(samples))
+	for projectID, sample := range samples {
+		prev, ok := s.requests[projectID]
+		elapsed := now.Sub(prev.at).Minutes()
+		if ok && elapsed > 0 && sample.TotalRequests >= prev.total {
+			rates[projectID] = float64(sample.TotalRequests-prev.total) / elapsed
+		}
+		s.requests[projectID] = requestSample{total: sample.TotalRequests, at: now}
+	}
+	for projectID := range s.requests {
+		if _, ok := samples[projectID]; !ok {
+			delete(s.requests, projectID)
+		}
+	}
+	return rates
+}
+
+// apply evaluates a rule with a value and notifies its channels when it
+// starts or stops firing
+func (s *AlertService) apply(ctx context.Context, rule *domain.AlertRule, subject string, value float64, now time.Time) {
+	status := rule.Evaluate(value, now)
+	if err := s.alertRepo.UpdateState(ctx, rule.ID, rule.State); err != nil {
+		s.logger.Warn("Failed to store alert state", "rule", rule.ID.Hex(), "error", err)
+		return
+	}
+	if status == "" {
+		return
+	}
+
+	event := &domain.AlertEvent{
+		RuleID:    rule.ID,
+		ProjectID: rule.ProjectID,
+		RuleName:  rule.Name,
+		Status:    status,
+		Value:     value,
+		Message:   s.message(ctx, rule, subject, status, value),
+		Silenced:  rule.Silenced(now),
+		Notified:  []domain.AlertChannelType{},
+		CreatedAt: now,
+	}
+
+	// Deliveries may take a while, keep them out of the broadcaster loops
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*alertWebhookTimeout)
+		defer cancel()
+
+		s.deliver(ctx, rule, event)
+		if err := s.alertRepo.InsertEvent(ctx, event); err != nil {
+			s.logger.Warn("Failed to store alert event", "rule", rule.ID.Hex(), "error", err)
+		}
+	}()
+}
+
+func (s *AlertService) message(ctx context.Context, rule *domain.AlertRule, subject string, status domain.AlertStatus, value float64) string {
+	project := rule.ProjectID.Hex()
+	if p, err := s.projectService.GetByID(ctx, rule.ProjectID); err == nil {
+		project = p.Name
+	}
+
+	state := "FIRING"
+	if status == domain.AlertResolved {
+		state = "RESOLVED"
+	}
+	return fmt.Sprintf("[%s] %s: %s - %s (value %g)", project, state, rule.Name, rule.Describe(subject), value)
+}
+
+// deliver sends an event to the channels of its rule, unless the rule is
+// silenced, recording the channels notified and the failures
+func (s *AlertService) deliver(ctx context.Context, rule *domain.AlertRule, event *domain.AlertEvent) {
+	if event.Silenced {
+		return
+	}
+
+	for _, channel := range rule.Channels {
+		var err error
+		switch channel.Type {
+		case domain.AlertChannelToast:
+			s.mu.Lock()
+			handlers := append([]AlertToastHandler(nil), s.toasts...)
+			s.mu.Unlock()
+			for _, handler := range handlers {
+				handler(*event)
+			}
+		case domain.AlertChannelEmail:
+			err = s.sendEmail(ctx, rule.ProjectID, channel.Target, event)
+		case domain.AlertChannelWebhook:
+			err = s.postWebhook(ctx, channel, event)
+		default:
+			continue
+		}
+
+		if err != nil {
+			event.Errors = append(event.Errors, fmt.Sprintf("%s: %v", channel.Type, err))
+			continue
+		}
+		event.Notified = append(event.Notified, channel.Type)
+	}
+}
+
+// postWebhook posts the event as JSON, signed with the channel secret if set
+func (s *AlertService) postWebhook(ctx context.Context, channel domain.AlertChannel, event *domain.AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-M3M-Event", "alert")
+	if channel.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(channel.Secret))
+		mac.Write(body)
+		req.Header.Set("X-M3M-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// recipients parses the comma separated addresses of an email channel. Each
+// one has to belong to a member of the project or match alerts.email_allowlist.
+func (s *AlertService) recipients(ctx context.Context, projectID primitive.ObjectID, to string) ([]string, error) {
+	var members map[string]bool
+	var addresses []string
+	for _, entry := range strings.Split(to, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddress(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q", entry)
+		}
+		address := strings.ToLower(parsed.Address)
+
+		if !s.allowlisted(address) {
+			if members == nil {
+				if members, err = s.memberEmails(ctx, projectID); err != nil {
+					return nil, err
+				}
+			}
+			if !members[address] {
+				return nil, fmt.Errorf("%w: %s", ErrAlertRecipient, parsed.Address)
+			}
+		}
+		addresses = append(addresses, parsed.Address)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("email channel needs a target address")
+	}
+	return addresses, nil
+}
+
+// allowlisted reports whether address matches alerts.email_allowlist, either
+// exactly or by an "@domain" entry
+func (s *AlertService) allowlisted(address string) bool {
+	for _, entry := range s.emailAllowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == address || (strings.HasPrefix(entry, "@") && strings.HasSuffix(address, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// memberEmails returns the lower-cased emails of the owner and members of a project
+func (s *AlertService) memberEmails(ctx context.Context, projectID primitive.ObjectID) (map[string]bool, error) {
+	project, err := s.projectService.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	emails := make(map[string]bool, len(project.Members)+1)
+	for _, id := range append([]primitive.ObjectID{project.OwnerID}, project.Members...) {
+		user, err := s.userRepo.FindByID(ctx, id)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		emails[strings.ToLower(user.Email)] = true
+	}
+	return emails, nil
+}
+
+// sendEmail mails the event with the SMTP_* environment variables of the
+// project, the same settings $mail uses. Recipients are checked again, members
+// may have left the project since the rule was saved.
+func (s *AlertService) sendEmail(ctx context.Context, projectID primitive.ObjectID, to string, event *domain.AlertEvent) error {
+	addresses, err := s.recipients(ctx, projectID, to)
+	if err != nil {
+		return err
+	}
+	env, err := s.envService.GetEnvMap(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	setting := func(key string) string {
+		if value, ok := env[key]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+		return ""
+	}
+
+	host, port := setting("SMTP_HOST"), setting("SMTP_PORT")
+	user, pass, from := setting("SMTP_USER"), setting("SMTP_PASS"), setting("SMTP_FROM")
+	if host == "" || port == "" || from == "" {
+		return errors.New("SMTP_HOST, SMTP_PORT and SMTP_FROM environment variables are required")
+	}
+
+	e := email.NewEmail()
+	e.From = from
+	e.To = addresses
+	e.Subject = event.Message
+	e.Text = []byte(fmt.Sprintf("%s\n\nRule: %s\nStatus: %s\nValue: %g\nTime: %s\n",
+		event.Message, event.RuleName, event.Status, event.Value, event.CreatedAt.UTC().Format(time.RFC1123)))
+
+	addr := host + ":" + port
+	var auth smtp.Auth
+	if user != "" && pass != "" {
+		auth = smtp.PlainAuth("", user, pass, host)
+	}
+	if port == "465" {
+		return e.SendWithTLS(addr, auth, &tls.Config{ServerName: host})
+	}
+	return e.Send(addr, auth)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestAlertRuleEvaluate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("fires after the condition held for the duration", func(t *testing.T) {
+		rule := &domain.AlertRule{Operator: domain.AlertAbove, Threshold: 512, ForSeconds: 300}
+
+		steps := []struct {
+			value  float64
+			offset time.Duration
+			want   domain.AlertStatus
+		}{
+			{600, 0, ""},
+			{600, 4 * time.Minute, ""},
+			{100, 4*time.Minute + 30*time.Second, ""}, // Dips below: starts over
+			{600, 5 * time.Minute, ""},
+			{600, 10 * time.Minute, domain.AlertFiring},
+			{700, 11 * time.Minute, ""},
+			{100, 12 * time.Minute, domain.AlertResolved},
+		}
+		for i, step := range steps {
+			if got := rule.Evaluate(step.value, start.Add(step.offset)); got != step.want {
+				t.Fatalf("step %d: got %q, want %q", i, got, step.want)
+			}
+		}
+		if rule.State.Firing || rule.State.FiredAt == nil || rule.State.ResolvedAt == nil {
+			t.Errorf("unexpected state %+v", rule.State)
+		}
+	})
+
+	t.Run("ignores the condition before check_after", func(t *testing.T) {
+		rule := &domain.AlertRule{Operator: domain.AlertBelow, Threshold: 5, CheckAfter: "18:00"}
+
+		if got := rule.Evaluate(2, start.Add(5*time.Hour)); got != "" {
+			t.Errorf("at 17:00 got %q, want no change", got)
+		}
+		if got := rule.Evaluate(2, start.Add(6*time.Hour)); got != domain.AlertFiring {
+			t.Errorf("at 18:00 got %q, want firing", got)
+		}
+		// The next day the counter starts over; before 18:00 the alert resolves
+		if got := rule.Evaluate(0, start.Add(13*time.Hour)); got != domain.AlertResolved {
+			t.Errorf("next day got %q, want resolved", got)
+		}
+	})
+}
+
+func TestAlertServiceRequestRates(t *testing.T) {
+	s := NewAlertService(nil, nil, nil, nil, nil, &config.Config{}, slog.Default())
+	projectID := primitive.NewObjectID()
+	now := time.Now()
+
+	if rates := s.requestRates(map[primitive.ObjectID]domain.RuntimeSample{projectID: {TotalRequests: 100}}, now); len(rates) != 0 {
+		t.Errorf("first sample gave rates %v", rates)
+	}
+	rates := s.requestRates(map[primitive.ObjectID]domain.RuntimeSample{projectID: {TotalRequests: 160}}, now.Add(30*time.Second))
+	if rates[projectID] != 120 {
+		t.Errorf("rate = %v, want 120 per minute", rates[projectID])
+	}
+
+	s.requestRates(map[primitive.ObjectID]domain.RuntimeSample{}, now.Add(time.Minute))
+	if len(s.requests) != 0 {
+		t.Error("samples of stopped projects were kept")
+	}
+}
+
+func TestAlertServiceDeliver(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-M3M-Signature")
+	}))
+	defer server.Close()
+
+	s := NewAlertService(nil, nil, nil, nil, nil, &config.Config{}, slog.Default())
+	s.client = server.Client() // The test server listens on loopback
+	var toasts []domain.AlertEvent
+	s.OnToast(func(event domain.AlertEvent) { toasts = append(toasts, event) })
+
+	rule := &domain.AlertRule{
+		Channels: []domain.AlertChannel{
+			{Type: domain.AlertChannelToast},
+			{Type: domain.AlertChannelWebhook, Target: server.URL, Secret: "s3cret"},
+			{Type: domain.AlertChannelWebhook, Target: server.URL + "/missing", Secret: "s3cret"},
+		},
+	}
+
+	event := &domain.AlertEvent{RuleName: "memory", Status: domain.AlertFiring, Value: 600}
+	s.deliver(context.Background(), rule, event)
+
+	if len(toasts) != 1 || toasts[0].RuleName != "memory" {
+		t.Errorf("toasts = %+v", toasts)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	if len(event.Notified) != 2 || len(event.Errors) != 1 {
+		t.Errorf("notified %v, errors %v", event.Notified, event.Errors)
+	}
+
+	silenced := &domain.AlertEvent{Silenced: true}
+	s.deliver(context.Background(), rule, silenced)
+	if len(toasts) != 1 || len(silenced.Notified) != 0 {
+		t.Error("silenced event was delivered")
+	}
+}
+
+func TestAlertServiceWebhookTargets(t *testing.T) {
+	s := NewAlertService(nil, nil, nil, nil, nil, &config.Config{}, slog.Default())
+	ctx := context.Background()
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"https://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		rule := &domain.AlertRule{
+			Source:   domain.AlertSourceRuntime,
+			Metric:   domain.AlertMetrics[0],
+			Operator: domain.AlertAbove,
+			Channels: []domain.AlertChannel{{Type: domain.AlertChannelWebhook, Target: target}},
+		}
+		if err := s.validate(ctx, rule); !errors.Is(err, ErrInvalidAlertRule) {
+			t.Errorf("%s: validate = %v, want invalid rule", target, err)
+		}
+	}
+
+	// Redirects and DNS rebinding are refused when connecting
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	err := s.postWebhook(ctx, domain.AlertChannel{Target: server.URL}, &domain.AlertEvent{})
+	if !errors.Is(err, ErrAlertForbiddenWebhook) {
+		t.Errorf("postWebhook to loopback = %v, want %v", err, ErrAlertForbiddenWebhook)
+	}
+}
+
+func TestAlertServiceEmailAllowlist(t *testing.T) {
+	s := NewAlertService(nil, nil, nil, nil, nil, &config.Config{
+		Alerts: config.AlertsConfig{EmailAllowlist: []string{"ops@example.com", "@alerts.example.org"}},
+	}, slog.Default())
+
+	addresses, err := s.recipients(context.Background(), primitive.NewObjectID(), "Ops <OPS@example.com>, pager@alerts.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 || addresses[0] != "OPS@example.com" || addresses[1] != "pager@alerts.example.org" {
+		t.Errorf("addresses = %v", addresses)
+	}
+
+	for _, address := range []string{"someone@example.com", "x@evilalerts.example.org", "x@alerts.example.org.evil"} {
+		if s.allowlisted(address) {
+			t.Errorf("%s is allowlisted", address)
+		}
+	}
+	if _, err := s.recipients(context.Background(), primitive.NewObjectID(), "not an address"); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
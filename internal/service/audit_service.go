@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+const (
+	// auditDefaultLimit is how many audit entries are listed without a limit
+	auditDefaultLimit = 100
+	// auditMaxLimit caps the audit entries listed at once; exports are not capped
+	auditMaxLimit = 1000
+)
+
+// auditIgnoredFields are left out of diffs, the entry has its own time
+var auditIgnoredFields = []string{"created_at", "updated_at", "_created_at", "_updated_at"}
+
+// auditRedactedFields are secrets whose values are replaced by a fingerprint,
+// which still shows whether they changed
+var auditRedactedFields = []string{"api_key"}
+
+// AuditService keeps the append-only audit log of administrative actions:
+// who did what to which project, and what changed
+type AuditService struct {
+	repo   *repository.AuditRepository
+	config *config.Config
+	logger *slog.Logger
+}
+
+func NewAuditService(repo *repository.AuditRepository, config *config.Config, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		config: config,
+		logger: logger,
+	}
+}
+
+// Record appends an action to the audit log. projectID is NilObjectID for
+// actions outside projects. before and after are states of the target,
+// either may be nil; only the fields that differ are stored. The action
+// already happened, so failures are logged rather than returned.
+func (s *AuditService) Record(ctx context.Context, actor domain.AuditActor, projectID primitive.ObjectID, action, target string, before, after any) {
+	changes, err := s.diff(before, after)
+	if err != nil {
+		s.logger.Warn("Failed to diff audited change", "action", action, "error", err)
+	}
+
+	entry := &domain.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorEmail: actor.Email,
+		TokenID:    actor.TokenID,
+		IP:         actor.IP,
+		Action:     action,
+		Target:     target,
+		Changes:    changes,
+		CreatedAt:  time.Now(),
+	}
+	if !projectID.IsZero() {
+		entry.ProjectID = &projectID
+	}
+
+	// The request may be canceled once it answered, the entry must still be written
+	if err := s.repo.Insert(context.WithoutCancel(ctx), entry); err != nil {
+		s.logger.Error("Failed to write audit entry", "action", action, "target", target, "actor", actor.Email, "error", err)
+	}
+}
+
+// List returns the newest audit entries matching a filter
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = auditDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, auditMaxLimit)
+	return s.repo.Find(ctx, filter)
+}
+
+// Export writes the audit entries matching a filter as JSON Lines, oldest first
+func (s *AuditService) Export(ctx context.Context, filter domain.AuditFilter, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return s.repo.Each(ctx, filter, func(entry *domain.AuditEntry) error {
+		return encoder.Encode(entry)
+	})
+}
+
+// Redact replaces a secret with a keyed fingerprint, so audit entries show
+// that it changed without revealing it
+func (s *AuditService) Redact(value any) string {
+	data, _ := json.Marshal(value)
+	mac := hmac.New(sha256.New, derivedKey(s.config.JWT.Secret, "m3m-audit-redact"))
+	mac.Write(data)
+	return "redacted:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// diff compares two states by their JSON form and returns the changed
+// fields, sorted by path
+func (s *AuditService) diff(before, after any) ([]domain.AuditChange, error) {
+	b, err := flattenAuditState(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := flattenAuditState(after)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(b)+len(a))
+	for path := range b {
+		paths = append(paths, path)
+	}
+	for path := range a {
+		if _, ok := b[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []domain.AuditChange
+	for _, path := range paths {
+		bv, inBefore := b[path]
+		av, inAfter := a[path]
+		if inBefore && inAfter && reflect.DeepEqual(bv, av) {
+			continue
+		}
+
+		redact := slices.Contains(auditRedactedFields, lastAuditSegment(path))
+		change := domain.AuditChange{Path: path}
+		if inBefore {
+			change.Before = s.auditValue(bv, redact)
+		}
+		if inAfter {
+			change.After = s.auditValue(av, redact)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (s *AuditService) auditValue(value any, redact bool) json.RawMessage {
+	if redact {
+		value = s.Redact(value)
+	}
+	data, _ := json.Marshal(value)
+	return data
+}
+
+// flattenAuditState turns a state into its JSON fields by dotted path.
+// Arrays and scalars are leaves; a scalar state is the field "value".
+func flattenAuditState(state any) (map[string]any, error) {
+	fields := make(map[string]any)
+	if state == nil {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case map[string]any:
+		flattenAuditObject(fields, "", v)
+	default:
+		fields["value"] = v
+	}
+	return fields, nil
+}
+
+func flattenAuditObject(fields map[string]any, prefix string, object map[string]any) {
+	for key, value := range object {
+		if slices.Contains(auditIgnoredFields, key) {
+			continue
+		}
+		path := prefix + key
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			flattenAuditObject(fields, path+".", nested)
+			continue
+		}
+		fields[path] = value
+	}
+}
+
+func lastAuditSegment(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
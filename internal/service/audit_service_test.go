@@ -0,0 +1,96 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestAuditDiff(t *testing.T) {
+	s := NewAuditService(nil, &config.Config{JWT: config.JWTConfig{Secret: "secret"}}, nil)
+
+	a, b := primitive.ObjectID{1}, primitive.ObjectID{2}
+	before := &domain.User{
+		Name:        "Ann",
+		Permissions: domain.Permissions{CreateProjects: true, ProjectAccess: []primitive.ObjectID{a}},
+		UpdatedAt:   time.Now(),
+	}
+	after := &domain.User{
+		Name:        "Ann",
+		Permissions: domain.Permissions{ManageUsers: true, ProjectAccess: []primitive.ObjectID{a, b}},
+		IsBlocked:   true,
+		UpdatedAt:   time.Now().Add(time.Hour),
+	}
+
+	changes, err := s.diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, change := range changes {
+		got[change.Path] = string(change.Before) + " -> " + string(change.After)
+	}
+	want := map[string]string{
+		"is_blocked":                  "false -> true",
+		"permissions.create_projects": "true -> false",
+		"permissions.manage_users":    "false -> true",
+		"permissions.project_access":  `["` + a.Hex() + `"] -> ["` + a.Hex() + `","` + b.Hex() + `"]`,
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes %v, want %v", got, want)
+	}
+	for path, change := range want {
+		if got[path] != change {
+			t.Errorf("%s: %q, want %q", path, got[path], change)
+		}
+	}
+}
+
+func TestAuditDiffCreateAndDelete(t *testing.T) {
+	s := NewAuditService(nil, &config.Config{JWT: config.JWTConfig{Secret: "secret"}}, nil)
+
+	changes, err := s.diff(nil, map[string]any{"key": "API_URL", "nested": map[string]any{"a": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Path != "key" || changes[0].Before != nil || string(changes[1].After) != "1" {
+		t.Errorf("create: %+v", changes)
+	}
+
+	changes, err = s.diff("v1.0.0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "value" || string(changes[0].Before) != `"v1.0.0"` || changes[0].After != nil {
+		t.Errorf("delete: %+v", changes)
+	}
+
+	if changes, _ := s.diff(nil, nil); len(changes) != 0 {
+		t.Errorf("no states: %+v", changes)
+	}
+}
+
+func TestAuditRedact(t *testing.T) {
+	s := NewAuditService(nil, &config.Config{JWT: config.JWTConfig{Secret: "secret"}}, nil)
+
+	changes, err := s.diff(map[string]any{"api_key": "old-key"}, map[string]any{"api_key": "new-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("changes %+v", changes)
+	}
+	before, after := string(changes[0].Before), string(changes[0].After)
+	if strings.Contains(before+after, "key\"") || !strings.HasPrefix(before, `"redacted:`) || before == after {
+		t.Errorf("api_key: %s -> %s", before, after)
+	}
+
+	if s.Redact("value") != s.Redact("value") || s.Redact("value") == s.Redact("other") {
+		t.Error("fingerprints must be stable and differ by value")
+	}
+}
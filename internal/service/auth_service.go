@@ -2,10 +2,18 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/levskiy0/m3m/internal/config"
@@ -17,62 +25,385 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrUserBlocked        = errors.New("user is blocked")
+	ErrSessionRevoked     = errors.New("session expired or revoked")
+	ErrPasswordLogin      = errors.New("password login is disabled, use single sign-on")
+	ErrLoginChallenge     = errors.New("login expired, log in again")
 )
 
+// defaultAccessExpiration is used when jwt.access_expiration is not configured
+const defaultAccessExpiration = 15 * time.Minute
+
+const (
+	// loginChallengeTTL is how long a login waits for its second step
+	loginChallengeTTL = 5 * time.Minute
+	// loginChallengeAttempts is how many wrong codes end a login
+	loginChallengeAttempts = 5
+)
+
+// loginChallenge is a login waiting for its second step, keyed by its challenge
+type loginChallenge struct {
+	userID    primitive.ObjectID
+	step      string
+	client    domain.SessionClient
+	attempts  int
+	expiresAt time.Time
+}
+
 type AuthService struct {
-	userRepo *repository.UserRepository
-	config   *config.Config
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	twoFactor   *TwoFactorService
+	protection  *LoginProtectionService
+	config      *config.Config
+
+	revokeMu        sync.RWMutex
+	revokeListeners []func(sessionIDs []string)
+
+	challengeMu sync.Mutex
+	challenges  map[string]*loginChallenge
 }
 
-func NewAuthService(userRepo *repository.UserRepository, config *config.Config) *AuthService {
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	twoFactor *TwoFactorService,
+	protection *LoginProtectionService,
+	config *config.Config,
+) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   config,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		twoFactor:   twoFactor,
+		protection:  protection,
+		config:      config,
+		challenges:  make(map[string]*loginChallenge),
 	}
 }
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func (s *AuthService) Login(ctx context.Context, req *domain.LoginRequest) (*domain.LoginResponse, error) {
+// OnRevoke registers a listener called with the IDs of revoked sessions, so
+// long-lived connections opened with them can be closed
+func (s *AuthService) OnRevoke(listener func(sessionIDs []string)) {
+	s.revokeMu.Lock()
+	defer s.revokeMu.Unlock()
+	s.revokeListeners = append(s.revokeListeners, listener)
+}
+
+// Login checks a password. Failed logins slow down and then lock out the
+// account and the client, see LoginProtectionService.
+func (s *AuthService) Login(ctx context.Context, req *domain.LoginRequest, client domain.SessionClient) (*domain.LoginResponse, error) {
+	release := s.protection.Acquire(req.Email, client.IP)
+	defer release()
+
+	if err := s.protection.Check(ctx, req.Email, client.IP); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			return nil, ErrInvalidCredentials
+			// Hash anyway, so the response time doesn't tell which emails exist
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+			return nil, s.loginFailed(ctx, req.Email, nil, client, domain.LoginFailedCredentials, ErrInvalidCredentials)
+		}
+		return nil, err
+	}
+
+	hash := []byte(user.Password)
+	if len(hash) == 0 {
+		// Accounts created by single sign-on have no password
+		hash = dummyPasswordHash()
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil || user.Password == "" {
+		return nil, s.loginFailed(ctx, req.Email, user, client, domain.LoginFailedCredentials, ErrInvalidCredentials)
+	}
+
+	if user.IsBlocked {
+		return nil, s.loginFailed(ctx, req.Email, user, client, domain.LoginFailedBlocked, ErrUserBlocked)
+	}
+	if !s.PasswordLoginAllowed(user) {
+		return nil, ErrPasswordLogin
+	}
+
+	return s.beginSession(ctx, user, client)
+}
+
+// dummyPasswordHash is compared against when there is no password to check,
+// it costs as much as the hashes of real passwords
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("m3m-no-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// loginFailed records a failed login and returns err, or the error of
+// recording it
+func (s *AuthService) loginFailed(ctx context.Context, email string, user *domain.User, client domain.SessionClient, reason string, err error) error {
+	if recordErr := s.protection.Failed(ctx, email, user, client, reason); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// PasswordLoginAllowed reports whether a user may log in with a password.
+// Root users always can, so the panel stays reachable without the provider;
+// pass nil to ask about other users.
+func (s *AuthService) PasswordLoginAllowed(user *domain.User) bool {
+	oidc := s.config.OIDC
+	return !oidc.Enabled || !oidc.DisablePasswordLogin || (user != nil && user.IsRoot)
+}
+
+// beginSession opens a session for an authenticated user, unless the login
+// needs a second step: a code for users with 2FA, or enrolling in 2FA for
+// users who are required to use it
+func (s *AuthService) beginSession(ctx context.Context, user *domain.User, client domain.SessionClient) (*domain.LoginResponse, error) {
+	var step string
+	switch {
+	case user.TwoFactor.Enabled:
+		step = domain.TwoFactorStepVerify
+	case s.twoFactor.Required(user):
+		step = domain.TwoFactorStepEnroll
+	default:
+		return s.startSession(ctx, user, client)
+	}
+
+	secret, _, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.challengeMu.Lock()
+	for key, c := range s.challenges {
+		if now.After(c.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[secret] = &loginChallenge{
+		userID:    user.ID,
+		step:      step,
+		client:    client,
+		expiresAt: now.Add(loginChallengeTTL),
+	}
+	s.challengeMu.Unlock()
+
+	return &domain.LoginResponse{TwoFactor: step, Challenge: secret}, nil
+}
+
+// EnrollTwoFactor creates the TOTP secret of a login that has to enroll in 2FA
+func (s *AuthService) EnrollTwoFactor(ctx context.Context, challenge string) (*domain.TwoFactorEnrollment, error) {
+	c, user, err := s.challengeUser(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	if c.step != domain.TwoFactorStepEnroll {
+		return nil, ErrTwoFactorEnabled
+	}
+	return s.twoFactor.Enroll(ctx, user)
+}
+
+// VerifyTwoFactor finishes a login with a TOTP or recovery code. Logins that
+// enrolled confirm the new secret with it and get their recovery codes.
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, challenge, code string) (*domain.LoginResponse, error) {
+	c, user, err := s.challengeUser(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+	release := s.protection.Acquire(user.Email, c.client.IP)
+	defer release()
+
+	if err := s.protection.Check(ctx, user.Email, c.client.IP); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if c.step == domain.TwoFactorStepEnroll {
+		recoveryCodes, err = s.twoFactor.Confirm(ctx, user, code)
+	} else {
+		err = s.twoFactor.Verify(ctx, user, code)
+	}
+
+	s.challengeMu.Lock()
+	switch {
+	case err == nil:
+		delete(s.challenges, challenge)
+	case errors.Is(err, ErrInvalidTwoFactorCode):
+		c.attempts++
+		if c.attempts >= loginChallengeAttempts {
+			delete(s.challenges, challenge)
 		}
+	}
+	s.challengeMu.Unlock()
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return nil, s.loginFailed(ctx, user.Email, user, c.client, domain.LoginFailedTwoFactor, err)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, ErrInvalidCredentials
+	resp, err := s.startSession(ctx, user, c.client)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+// challengeUser looks up a login waiting for its second step and its user
+func (s *AuthService) challengeUser(ctx context.Context, challenge string) (*loginChallenge, *domain.User, error) {
+	s.challengeMu.Lock()
+	c, ok := s.challenges[challenge]
+	s.challengeMu.Unlock()
+	if !ok || time.Now().After(c.expiresAt) {
+		return nil, nil, ErrLoginChallenge
 	}
 
+	user, err := s.userRepo.FindByID(ctx, c.userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, nil, ErrLoginChallenge
+		}
+		return nil, nil, err
+	}
+	if user.IsBlocked {
+		return nil, nil, ErrUserBlocked
+	}
+	return c, user, nil
+}
+
+// startSession opens a session for a user and issues its first tokens. The
+// login is complete, so the failed logins of the account are forgotten.
+func (s *AuthService) startSession(ctx context.Context, user *domain.User, client domain.SessionClient) (*domain.LoginResponse, error) {
+	if err := s.protection.Succeeded(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.sessionRepo.DeleteInactive(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{
+		UserID:      user.ID,
+		RefreshHash: hash,
+		UserAgent:   client.UserAgent,
+		IP:          client.IP,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(s.config.JWT.Expiration),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session, secret, now)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Presenting a refresh token that was already exchanged revokes the
+// session, as it means the token leaked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*domain.LoginResponse, error) {
+	sessionID, secret, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !session.Active(now) {
+		return nil, ErrSessionRevoked
+	}
+
+	hash := hashRefreshSecret(secret)
+	if session.PreviousHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(session.PreviousHash)) == 1 {
+		if err := s.RevokeSession(ctx, session.UserID, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionRevoked
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshHash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
 	if user.IsBlocked {
 		return nil, ErrUserBlocked
 	}
 
-	token, err := s.generateToken(user)
+	newSecret, newHash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	session.ExpiresAt = now.Add(s.config.JWT.Expiration)
+	rotated, err := s.sessionRepo.Rotate(ctx, session.ID, session.RefreshHash, newHash, now, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, ErrInvalidToken
+	}
+
+	return s.issueTokens(user, session, newSecret, now)
+}
+
+func (s *AuthService) issueTokens(user *domain.User, session *domain.Session, refreshSecret string, now time.Time) (*domain.LoginResponse, error) {
+	accessExpiration := s.config.JWT.AccessExpiration
+	if accessExpiration <= 0 {
+		accessExpiration = defaultAccessExpiration
+	}
+	expiresAt := now.Add(accessExpiration)
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	token, err := s.generateToken(user, session.ID, now, expiresAt)
 	if err != nil {
 		return nil, err
 	}
 
 	return &domain.LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		RefreshToken: session.ID.Hex() + "." + refreshSecret,
+		User:         user,
 	}, nil
 }
 
-func (s *AuthService) generateToken(user *domain.User) (string, error) {
+func (s *AuthService) generateToken(user *domain.User, sessionID primitive.ObjectID, now, expiresAt time.Time) (string, error) {
 	claims := &Claims{
-		UserID: user.ID.Hex(),
-		Email:  user.Email,
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		SessionID: sessionID.Hex(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.JWT.Expiration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
@@ -99,6 +430,100 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, ErrInvalidToken
 }
 
+// Authenticate validates an access token and returns its user and session.
+// Tokens of revoked or expired sessions and of blocked users are rejected.
+func (s *AuthService) Authenticate(ctx context.Context, tokenString string) (*domain.User, *domain.Session, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		// Tokens issued before sessions existed
+		return nil, nil, ErrSessionRevoked
+	}
+
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, nil, ErrSessionRevoked
+		}
+		return nil, nil, err
+	}
+	if session.UserID != userID || !session.Active(time.Now()) {
+		return nil, nil, ErrSessionRevoked
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if user.IsBlocked {
+		return nil, nil, ErrUserBlocked
+	}
+
+	return user, session, nil
+}
+
+// Sessions returns the active sessions of a user, marking the current one
+func (s *AuthService) Sessions(ctx context.Context, userID, currentID primitive.ObjectID) ([]*domain.Session, error) {
+	sessions, err := s.sessionRepo.FindActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends a session of a user
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	revoked, err := s.sessionRepo.Revoke(ctx, userID, sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return repository.ErrSessionNotFound
+	}
+	s.notifyRevoked([]primitive.ObjectID{sessionID})
+	return nil
+}
+
+// RevokeUserSessions ends all sessions of a user, logging them out everywhere
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	ids, err := s.sessionRepo.RevokeByUser(ctx, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	s.notifyRevoked(ids)
+	return nil
+}
+
+func (s *AuthService) notifyRevoked(ids []primitive.ObjectID) {
+	if len(ids) == 0 {
+		return
+	}
+	sessionIDs := make([]string, len(ids))
+	for i, id := range ids {
+		sessionIDs[i] = id.Hex()
+	}
+
+	s.revokeMu.RLock()
+	defer s.revokeMu.RUnlock()
+	for _, listener := range s.revokeListeners {
+		listener(sessionIDs)
+	}
+}
+
 func (s *AuthService) HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -108,3 +533,32 @@ func (s *AuthService) CheckPassword(hashedPassword, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// newRefreshSecret returns a random refresh token secret and its hash; only
+// the hash is stored
+func newRefreshSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseRefreshToken splits a "<session id>.<secret>" refresh token
+func parseRefreshToken(token string) (primitive.ObjectID, string, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return primitive.NilObjectID, "", ErrInvalidToken
+	}
+	sessionID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, "", ErrInvalidToken
+	}
+	return sessionID, secret, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestRefreshToken(t *testing.T) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != hashRefreshSecret(secret) || hash == secret {
+		t.Error("the stored hash does not match the secret")
+	}
+
+	sessionID := primitive.NewObjectID()
+	id, parsed, err := parseRefreshToken(sessionID.Hex() + "." + secret)
+	if err != nil || id != sessionID || parsed != secret {
+		t.Errorf("parse gave %s %q %v", id.Hex(), parsed, err)
+	}
+
+	for _, token := range []string{"", secret, sessionID.Hex() + ".", "nothex." + secret} {
+		if _, _, err := parseRefreshToken(token); err == nil {
+			t.Errorf("%q parsed", token)
+		}
+	}
+}
+
+func TestIssueTokens(t *testing.T) {
+	s := NewAuthService(nil, nil, nil, nil, &config.Config{JWT: config.JWTConfig{Secret: "secret", AccessExpiration: time.Minute}})
+	user := &domain.User{ID: primitive.NewObjectID(), Email: "dev@example.com"}
+	now := time.Now().Truncate(time.Second)
+
+	session := &domain.Session{ID: primitive.NewObjectID(), ExpiresAt: now.Add(time.Hour)}
+	resp, err := s.issueTokens(user, session, "refresh", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.ExpiresAt.Equal(now.Add(time.Minute)) || resp.RefreshToken != session.ID.Hex()+".refresh" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	claims, err := s.ValidateToken(resp.Token)
+	if err != nil || claims.SessionID != session.ID.Hex() || claims.UserID != user.ID.Hex() {
+		t.Errorf("claims %+v, %v", claims, err)
+	}
+
+	// Access tokens do not outlive their session
+	session.ExpiresAt = now.Add(30 * time.Second)
+	if resp, _ := s.issueTokens(user, session, "refresh", now); !resp.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("access token expires at %v, after the session", resp.ExpiresAt)
+	}
+}
@@ -0,0 +1,860 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+var (
+	ErrBundleInvalid            = errors.New("invalid project bundle")
+	ErrBundleUnsupportedVersion = errors.New("unsupported project bundle version")
+	ErrBundleBadPassphrase      = errors.New("invalid bundle passphrase")
+	ErrBundleTooLarge           = errors.New("project bundle exceeds the import limits")
+)
+
+const (
+	bundleManifestFile   = "manifest.json"
+	bundleBranchesFile   = "branches.json"
+	bundleReleasesFile   = "releases.json"
+	bundleModelsFile     = "models.json"
+	bundleEnvFile        = "env.json"
+	bundleEnvSecretsFile = "env.secrets"
+	bundleGoalsFile      = "goals.json"
+	bundleActionsFile    = "actions.json"
+	bundleWidgetsFile    = "widgets.json"
+	bundleDataDir        = "data/"
+	bundleStorageDir     = "storage/"
+
+	bundleDataBatchSize = 500
+	bundleMaxDocSize    = 16 * 1024 * 1024
+
+	// Import limits, checked before anything is written
+	bundleMaxSize         = 4 << 30  // Archive size
+	bundleMaxEntries      = 100000   // Files in the archive
+	bundleMaxUnpackedSize = 16 << 30 // Sum of the uncompressed file sizes
+	bundleMaxJSONSize     = 64 << 20 // Each metadata file
+
+	// bundleRollbackTimeout bounds the cleanup of a failed import
+	bundleRollbackTimeout = 5 * time.Minute
+)
+
+// bundleSecrets is the encrypted env values envelope (AES-256-GCM, key derived with scrypt)
+type bundleSecrets struct {
+	KDF   string `json:"kdf"`
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// bundleContents holds the parsed parts of a bundle archive
+type bundleContents struct {
+	manifest domain.BundleManifest
+	branches []domain.BundleBranch
+	releases []domain.BundleRelease
+	models   []domain.CreateModelRequest
+	env      []domain.BundleEnvVar
+	secrets  map[string]interface{}
+	goals    []domain.BundleGoal
+	actions  []domain.CreateActionRequest
+	widgets  []domain.BundleWidget
+	data     map[string]*zip.File
+	storage  []*zip.File
+}
+
+type BundleService struct {
+	projectService     *ProjectService
+	pipelineService    *PipelineService
+	modelService       *ModelService
+	environmentService *EnvironmentService
+	goalService        *GoalService
+	actionService      *ActionService
+	widgetService      *WidgetService
+	storageService     *StorageService
+}
+
+func NewBundleService(
+	projectService *ProjectService,
+	pipelineService *PipelineService,
+	modelService *ModelService,
+	environmentService *EnvironmentService,
+	goalService *GoalService,
+	actionService *ActionService,
+	widgetService *WidgetService,
+	storageService *StorageService,
+) *BundleService {
+	return &BundleService{
+		projectService:     projectService,
+		pipelineService:    pipelineService,
+		modelService:       modelService,
+		environmentService: environmentService,
+		goalService:        goalService,
+		actionService:      actionService,
+		widgetService:      widgetService,
+		storageService:     storageService,
+	}
+}
+
+// Export writes a project bundle zip archive to w
+func (s *BundleService) Export(ctx context.Context, projectID primitive.ObjectID, req *domain.ExportProjectRequest, w io.Writer) (*domain.BundleManifest, error) {
+	project, err := s.projectService.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &domain.BundleManifest{
+		FormatVersion: domain.BundleFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Project: domain.BundleProject{
+			Name:          project.Name,
+			Slug:          project.Slug,
+			Color:         project.Color,
+			AutoStart:     project.AutoStart,
+			ActiveRelease: project.ActiveRelease,
+			LogRetention:  project.LogRetention,
+			Timezone:      project.Timezone,
+		},
+		IncludesData:  req.IncludeData,
+		IncludesFiles: !req.ExcludeFiles,
+		EnvMode:       domain.BundleEnvExcluded,
+	}
+
+	zw := zip.NewWriter(w)
+
+	// Branches
+	branches, err := s.pipelineService.GetBranches(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundleBranches := make([]domain.BundleBranch, len(branches))
+	for i, b := range branches {
+		bundleBranches[i] = domain.BundleBranch{Name: b.Name, Files: b.Files}
+	}
+	if err := writeBundleJSON(zw, bundleBranchesFile, bundleBranches); err != nil {
+		return nil, err
+	}
+	manifest.BranchesCount = len(bundleBranches)
+
+	// Releases
+	releases, err := s.pipelineService.GetReleases(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundleReleases := make([]domain.BundleRelease, len(releases))
+	for i, r := range releases {
+		bundleReleases[i] = domain.BundleRelease{
+			Version:   r.Version,
+			Comment:   r.Comment,
+			Tag:       r.Tag,
+			IsActive:  r.IsActive,
+			Files:     r.Files,
+			CreatedAt: r.CreatedAt,
+		}
+	}
+	if err := writeBundleJSON(zw, bundleReleasesFile, bundleReleases); err != nil {
+		return nil, err
+	}
+	manifest.ReleasesCount = len(bundleReleases)
+
+	// Models (schemas, optionally data)
+	models, err := s.modelService.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundleModels := make([]domain.CreateModelRequest, len(models))
+	for i, m := range models {
+		tableConfig, formConfig := m.TableConfig, m.FormConfig
+		bundleModels[i] = domain.CreateModelRequest{
+			Name:        m.Name,
+			Slug:        m.Slug,
+			Fields:      m.Fields,
+			TableConfig: &tableConfig,
+			FormConfig:  &formConfig,
+		}
+		if req.IncludeData {
+			if err := s.exportModelData(ctx, zw, m); err != nil {
+				return nil, fmt.Errorf("failed to export data of model %s: %w", m.Slug, err)
+			}
+		}
+	}
+	if err := writeBundleJSON(zw, bundleModelsFile, bundleModels); err != nil {
+		return nil, err
+	}
+	manifest.ModelsCount = len(bundleModels)
+
+	// Environment variables: keys always, values only encrypted
+	envVars, err := s.environmentService.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundleEnv := make([]domain.BundleEnvVar, len(envVars))
+	values := make(map[string]interface{}, len(envVars))
+	for i, v := range envVars {
+		bundleEnv[i] = domain.BundleEnvVar{Key: v.Key, Type: v.Type, Order: v.Order}
+		values[v.Key] = v.Value
+	}
+	if err := writeBundleJSON(zw, bundleEnvFile, bundleEnv); err != nil {
+		return nil, err
+	}
+	if req.Passphrase != "" && len(envVars) > 0 {
+		plaintext, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		secrets, err := encryptBundleSecrets(plaintext, req.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBundleJSON(zw, bundleEnvSecretsFile, secrets); err != nil {
+			return nil, err
+		}
+		manifest.EnvMode = domain.BundleEnvEncrypted
+	}
+
+	// Goals (project-owned only)
+	goals, err := s.goalService.GetProjectGoals(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	goalSlugs := make(map[primitive.ObjectID]string, len(goals))
+	bundleGoals := make([]domain.BundleGoal, 0, len(goals))
+	for _, g := range goals {
+		slug := strings.TrimPrefix(g.Slug, projectID.Hex()[:8]+"-")
+		goalSlugs[g.ID] = slug
+		bg := domain.BundleGoal{
+			Name:        g.Name,
+			Slug:        slug,
+			Color:       g.Color,
+			Type:        g.Type,
+			Description: g.Description,
+			GridSpan:    g.GridSpan,
+			ShowTotal:   g.ShowTotal,
+			Order:       g.Order,
+			Buckets:     g.Buckets,
+		}
+		if req.IncludeData {
+			stats, err := s.goalService.GetStats(ctx, &domain.GoalStatsQuery{
+				GoalIDs:   []string{g.ID.Hex()},
+				ProjectID: projectID.Hex(),
+				Raw:       true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, stat := range stats {
+				bg.Stats = append(bg.Stats, domain.GoalStat{
+					Date:   stat.Date,
+					Hour:   stat.Hour,
+					Labels: stat.Labels,
+					Value:  stat.Value,
+					Metric: stat.Metric,
+				})
+			}
+		}
+		bundleGoals = append(bundleGoals, bg)
+	}
+	if err := writeBundleJSON(zw, bundleGoalsFile, bundleGoals); err != nil {
+		return nil, err
+	}
+
+	// Actions
+	actions, err := s.actionService.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	actionSlugs := make(map[primitive.ObjectID]string, len(actions))
+	bundleActions := make([]domain.CreateActionRequest, len(actions))
+	for i, a := range actions {
+		actionSlugs[a.ID] = a.Slug
+		showInMenu := a.ShowInMenu
+		bundleActions[i] = domain.CreateActionRequest{
+			Name:       a.Name,
+			Slug:       a.Slug,
+			Group:      a.Group,
+			ShowInMenu: &showInMenu,
+			Color:      a.Color,
+		}
+	}
+	if err := writeBundleJSON(zw, bundleActionsFile, bundleActions); err != nil {
+		return nil, err
+	}
+
+	// Widgets, with goal and action references resolved to slugs
+	widgets, err := s.widgetService.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundleWidgets := make([]domain.BundleWidget, 0, len(widgets))
+	for _, wd := range widgets {
+		bw := domain.BundleWidget{
+			Type:     wd.Type,
+			Variant:  wd.Variant,
+			GridSpan: wd.GridSpan,
+			Order:    wd.Order,
+		}
+		if wd.GoalID != nil {
+			slug, ok := goalSlugs[*wd.GoalID]
+			if !ok {
+				// Global goal: reference it by its full slug
+				goal, err := s.goalService.GetByID(ctx, *wd.GoalID)
+				if err != nil {
+					continue
+				}
+				slug = goal.Slug
+				bw.GlobalGoal = true
+			}
+			bw.GoalSlug = slug
+		}
+		if wd.ActionID != nil {
+			slug, ok := actionSlugs[*wd.ActionID]
+			if !ok {
+				continue
+			}
+			bw.ActionSlug = slug
+		}
+		bundleWidgets = append(bundleWidgets, bw)
+	}
+	if err := writeBundleJSON(zw, bundleWidgetsFile, bundleWidgets); err != nil {
+		return nil, err
+	}
+
+	// Storage files
+	if !req.ExcludeFiles {
+		count, size, err := s.storageService.ExportToZip(projectID.Hex(), zw, bundleStorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export storage: %w", err)
+		}
+		manifest.StorageFiles = count
+		manifest.StorageBytes = size
+	}
+
+	if err := writeBundleJSON(zw, bundleManifestFile, manifest); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// exportModelData writes all documents of a model as canonical extended JSON lines
+func (s *BundleService) exportModelData(ctx context.Context, zw *zip.Writer, model *domain.Model) error {
+	writer, err := zw.Create(bundleDataDir + model.Slug + ".jsonl")
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(writer)
+	err = s.modelService.EachData(ctx, model, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return err
+		}
+		if _, err := buf.Write(line); err != nil {
+			return err
+		}
+		return buf.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// Import creates a new project from a bundle archive. It is all or nothing:
+// if any item cannot be restored, the project is removed again.
+// References to goals and actions that don't exist are skipped with a warning.
+func (s *BundleService) Import(ctx context.Context, r io.ReaderAt, size int64, req *domain.ImportProjectRequest, ownerID primitive.ObjectID) (*domain.ImportProjectResult, error) {
+	contents, warnings, err := readBundle(r, size, req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	createReq := &domain.CreateProjectRequest{
+		Name:  contents.manifest.Project.Name,
+		Slug:  contents.manifest.Project.Slug,
+		Color: contents.manifest.Project.Color,
+	}
+	if req.Name != "" {
+		createReq.Name = req.Name
+	}
+	if req.Slug != "" {
+		createReq.Slug = req.Slug
+	}
+	if createReq.Name == "" || createReq.Slug == "" {
+		return nil, fmt.Errorf("%w: project name and slug are required", ErrBundleInvalid)
+	}
+
+	project, err := s.projectService.Create(ctx, createReq, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ImportProjectResult{Project: project, Warnings: warnings}
+	if err := s.restore(ctx, result, contents); err != nil {
+		if rollbackErr := s.rollback(project.ID); rollbackErr != nil {
+			return nil, fmt.Errorf("%w (rollback incomplete: %v)", err, rollbackErr)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// restore writes the contents of a bundle into the newly created project
+func (s *BundleService) restore(ctx context.Context, result *domain.ImportProjectResult, contents *bundleContents) error {
+	project := result.Project
+	warn := func(format string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	// Branches and releases
+	hasDevelop := false
+	for _, b := range contents.branches {
+		if _, err := s.pipelineService.ImportBranch(ctx, project.ID, b.Name, b.Files); err != nil {
+			return fmt.Errorf("branch %s: %w", b.Name, err)
+		}
+		if b.Name == "develop" {
+			hasDevelop = true
+		}
+	}
+	if !hasDevelop {
+		if _, err := s.pipelineService.EnsureDevelopBranch(ctx, project.ID); err != nil {
+			return fmt.Errorf("branch develop: %w", err)
+		}
+	}
+
+	sort.SliceStable(contents.releases, func(i, j int) bool {
+		return contents.releases[i].CreatedAt.Before(contents.releases[j].CreatedAt)
+	})
+	for _, r := range contents.releases {
+		if _, err := s.pipelineService.ImportRelease(ctx, project.ID, r.Version, r.Files, r.Comment, r.Tag); err != nil {
+			return fmt.Errorf("release %s: %w", r.Version, err)
+		}
+		if r.IsActive {
+			if err := s.pipelineService.ActivateRelease(ctx, project.ID, r.Version); err != nil {
+				return fmt.Errorf("release %s: failed to activate: %w", r.Version, err)
+			}
+		}
+	}
+	if active := contents.manifest.Project.ActiveRelease; active != "" {
+		if err := s.projectService.SetActiveRelease(ctx, project.ID, active); err != nil {
+			return fmt.Errorf("active release %s: %w", active, err)
+		}
+		project.ActiveRelease = active
+	}
+	if settings := contents.manifest.Project; settings.AutoStart || settings.LogRetention != nil || settings.Timezone != "" {
+		update := &domain.UpdateProjectRequest{LogRetention: settings.LogRetention}
+		if settings.AutoStart {
+			autoStart := true
+			update.AutoStart = &autoStart
+		}
+		if settings.Timezone != "" {
+			update.Timezone = &settings.Timezone
+		}
+		updated, err := s.projectService.Update(ctx, project.ID, update)
+		if err != nil {
+			return fmt.Errorf("project settings: %w", err)
+		}
+		result.Project = updated
+	}
+
+	// Models and data
+	for i := range contents.models {
+		m := &contents.models[i]
+		model, err := s.modelService.Create(ctx, project.ID, m)
+		if err != nil {
+			return fmt.Errorf("model %s: %w", m.Slug, err)
+		}
+		if file, ok := contents.data[m.Slug]; ok {
+			if err := s.importModelData(ctx, model, file); err != nil {
+				return fmt.Errorf("model %s: failed to import data: %w", m.Slug, err)
+			}
+		}
+	}
+
+	// Environment variables
+	if len(contents.env) > 0 {
+		items := make([]domain.BulkEnvVarItem, len(contents.env))
+		for i, v := range contents.env {
+			value, ok := contents.secrets[v.Key]
+			if !ok {
+				value = zeroEnvValue(v.Type)
+			}
+			items[i] = domain.BulkEnvVarItem{Key: v.Key, Type: v.Type, Value: value, Order: v.Order}
+		}
+		if _, err := s.environmentService.BulkUpdate(ctx, project.ID, &domain.BulkUpdateEnvVarRequest{Items: items}); err != nil {
+			return fmt.Errorf("environment: %w", err)
+		}
+	}
+
+	// Goals
+	goalIDs := make(map[string]string, len(contents.goals))
+	for _, g := range contents.goals {
+		goal, err := s.goalService.CreateForProject(ctx, project.ID, &domain.CreateGoalRequest{
+			Name:        g.Name,
+			Slug:        g.Slug,
+			Color:       g.Color,
+			Type:        g.Type,
+			Description: g.Description,
+			GridSpan:    g.GridSpan,
+			ShowTotal:   g.ShowTotal,
+			Buckets:     g.Buckets,
+		})
+		if err != nil {
+			return fmt.Errorf("goal %s: %w", g.Slug, err)
+		}
+		goalIDs[g.Slug] = goal.ID.Hex()
+		if g.Order != 0 {
+			order := g.Order
+			if _, err := s.goalService.Update(ctx, goal.ID, &domain.UpdateGoalRequest{Order: &order}); err != nil {
+				return fmt.Errorf("goal %s: %w", g.Slug, err)
+			}
+		}
+		if err := s.goalService.ImportStats(ctx, goal.ID, project.ID, g.Stats); err != nil {
+			return fmt.Errorf("goal %s: failed to import stats: %w", g.Slug, err)
+		}
+	}
+
+	// Actions
+	actionIDs := make(map[string]string, len(contents.actions))
+	for i := range contents.actions {
+		a := &contents.actions[i]
+		action, err := s.actionService.Create(ctx, project.ID, a)
+		if err != nil {
+			return fmt.Errorf("action %s: %w", a.Slug, err)
+		}
+		actionIDs[a.Slug] = action.ID.Hex()
+	}
+
+	// Widgets replace the defaults created with the project
+	if contents.widgets != nil {
+		if err := s.widgetService.DeleteByProject(ctx, project.ID); err != nil {
+			return fmt.Errorf("widgets: %w", err)
+		}
+		sort.SliceStable(contents.widgets, func(i, j int) bool {
+			return contents.widgets[i].Order < contents.widgets[j].Order
+		})
+		for _, wd := range contents.widgets {
+			createReq := &domain.CreateWidgetRequest{
+				Type:     wd.Type,
+				Variant:  wd.Variant,
+				GridSpan: wd.GridSpan,
+			}
+			if wd.GoalSlug != "" {
+				if wd.GlobalGoal {
+					goal, err := s.goalService.GetBySlug(ctx, wd.GoalSlug)
+					if err != nil {
+						warn("widget: global goal %s not found", wd.GoalSlug)
+						continue
+					}
+					createReq.GoalID = goal.ID.Hex()
+				} else if id, ok := goalIDs[wd.GoalSlug]; ok {
+					createReq.GoalID = id
+				} else {
+					warn("widget: goal %s not found", wd.GoalSlug)
+					continue
+				}
+			}
+			if wd.ActionSlug != "" {
+				id, ok := actionIDs[wd.ActionSlug]
+				if !ok {
+					warn("widget: action %s not found", wd.ActionSlug)
+					continue
+				}
+				createReq.ActionID = id
+			}
+			widget, err := s.widgetService.Create(ctx, project.ID, createReq)
+			if err != nil {
+				return fmt.Errorf("widget %s: %w", wd.Type, err)
+			}
+			order := wd.Order
+			if _, err := s.widgetService.Update(ctx, project.ID, widget.ID, &domain.UpdateWidgetRequest{Order: &order}); err != nil {
+				return fmt.Errorf("widget %s: %w", wd.Type, err)
+			}
+		}
+	}
+
+	// Storage files
+	for _, file := range contents.storage {
+		relPath := strings.TrimPrefix(file.Name, bundleStorageDir)
+		if err := s.storageService.ImportZipFile(project.ID.Hex(), relPath, file); err != nil {
+			return fmt.Errorf("storage %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// rollback removes a project whose import failed, with everything restored
+// into it so far. It runs detached from the request, which may be cancelled.
+func (s *BundleService) rollback(projectID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bundleRollbackTimeout)
+	defer cancel()
+
+	var errs []error
+	models, err := s.modelService.GetByProject(ctx, projectID)
+	errs = append(errs, err)
+	for _, model := range models {
+		errs = append(errs, s.modelService.Delete(ctx, model.ID))
+	}
+	goals, err := s.goalService.GetProjectGoals(ctx, projectID)
+	errs = append(errs, err)
+	for _, goal := range goals {
+		errs = append(errs, s.goalService.ResetStats(ctx, goal.ID), s.goalService.Delete(ctx, goal.ID))
+	}
+	errs = append(errs,
+		s.actionService.DeleteByProject(ctx, projectID),
+		s.widgetService.DeleteByProject(ctx, projectID),
+		s.environmentService.DeleteByProject(ctx, projectID),
+		s.pipelineService.DeleteByProject(ctx, projectID),
+		s.projectService.Delete(ctx, projectID), // Storage files too
+	)
+	return errors.Join(errs...)
+}
+
+// importModelData inserts extended JSON lines from a bundle into a model collection in batches
+func (s *BundleService) importModelData(ctx context.Context, model *domain.Model, file *zip.File) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), bundleMaxDocSize)
+
+	batch := make([]bson.D, 0, bundleDataBatchSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(line, true, &doc); err != nil {
+			return err
+		}
+		batch = append(batch, doc)
+		if len(batch) == bundleDataBatchSize {
+			if err := s.modelService.ImportData(ctx, model, batch); err != nil {
+				return err
+			}
+			batch = make([]bson.D, 0, bundleDataBatchSize)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return s.modelService.ImportData(ctx, model, batch)
+}
+
+// readBundle parses and validates a bundle archive before anything is written
+func readBundle(r io.ReaderAt, size int64, passphrase string) (*bundleContents, []string, error) {
+	if size > bundleMaxSize {
+		return nil, nil, fmt.Errorf("%w: archive is larger than %d bytes", ErrBundleTooLarge, int64(bundleMaxSize))
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+	}
+	if len(zr.File) > bundleMaxEntries {
+		return nil, nil, fmt.Errorf("%w: more than %d files", ErrBundleTooLarge, bundleMaxEntries)
+	}
+	// The zip reader fails on entries larger than their header claims
+	var unpacked uint64
+	for _, f := range zr.File {
+		unpacked += f.UncompressedSize64
+		if unpacked > bundleMaxUnpackedSize {
+			return nil, nil, fmt.Errorf("%w: unpacks to more than %d bytes", ErrBundleTooLarge, int64(bundleMaxUnpackedSize))
+		}
+	}
+
+	contents := &bundleContents{data: make(map[string]*zip.File)}
+	warnings := []string{}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		switch {
+		case strings.HasSuffix(f.Name, "/"):
+			// Directory entry
+		case strings.HasPrefix(f.Name, bundleStorageDir):
+			contents.storage = append(contents.storage, f)
+		case strings.HasPrefix(f.Name, bundleDataDir) && strings.HasSuffix(f.Name, ".jsonl"):
+			slug := strings.TrimSuffix(strings.TrimPrefix(f.Name, bundleDataDir), ".jsonl")
+			contents.data[slug] = f
+		default:
+			files[f.Name] = f
+		}
+	}
+
+	manifestFile, ok := files[bundleManifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing %s", ErrBundleInvalid, bundleManifestFile)
+	}
+	if err := readBundleJSON(manifestFile, &contents.manifest); err != nil {
+		return nil, nil, err
+	}
+	if contents.manifest.FormatVersion < 1 || contents.manifest.FormatVersion > domain.BundleFormatVersion {
+		return nil, nil, fmt.Errorf("%w: %d", ErrBundleUnsupportedVersion, contents.manifest.FormatVersion)
+	}
+
+	parts := []struct {
+		name   string
+		target interface{}
+	}{
+		{bundleBranchesFile, &contents.branches},
+		{bundleReleasesFile, &contents.releases},
+		{bundleModelsFile, &contents.models},
+		{bundleEnvFile, &contents.env},
+		{bundleGoalsFile, &contents.goals},
+		{bundleActionsFile, &contents.actions},
+		{bundleWidgetsFile, &contents.widgets},
+	}
+	for _, part := range parts {
+		f, ok := files[part.name]
+		if !ok {
+			continue
+		}
+		if err := readBundleJSON(f, part.target); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if f, ok := files[bundleEnvSecretsFile]; ok {
+		if passphrase == "" {
+			warnings = append(warnings, "environment values are encrypted and no passphrase was given, values were left empty")
+		} else {
+			var secrets bundleSecrets
+			if err := readBundleJSON(f, &secrets); err != nil {
+				return nil, nil, err
+			}
+			plaintext, err := decryptBundleSecrets(&secrets, passphrase)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := json.Unmarshal(plaintext, &contents.secrets); err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", ErrBundleInvalid, err)
+			}
+		}
+	} else if len(contents.env) > 0 {
+		warnings = append(warnings, "environment values were not included in the bundle, values were left empty")
+	}
+
+	return contents, warnings, nil
+}
+
+func writeBundleJSON(zw *zip.Writer, name string, v interface{}) error {
+	writer, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func readBundleJSON(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > bundleMaxJSONSize {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrBundleTooLarge, f.Name, bundleMaxJSONSize)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBundleInvalid, f.Name, err)
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBundleInvalid, f.Name, err)
+	}
+	return nil
+}
+
+func deriveBundleKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func encryptBundleSecrets(plaintext []byte, passphrase string) (*bundleSecrets, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveBundleKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &bundleSecrets{
+		KDF:   "scrypt",
+		Salt:  salt,
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+func decryptBundleSecrets(secrets *bundleSecrets, passphrase string) ([]byte, error) {
+	if secrets.KDF != "scrypt" {
+		return nil, fmt.Errorf("%w: unsupported key derivation %q", ErrBundleInvalid, secrets.KDF)
+	}
+	key, err := deriveBundleKey(passphrase, secrets.Salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets.Nonce) != gcm.NonceSize() {
+		return nil, ErrBundleBadPassphrase
+	}
+	plaintext, err := gcm.Open(nil, secrets.Nonce, secrets.Data, nil)
+	if err != nil {
+		return nil, ErrBundleBadPassphrase
+	}
+	return plaintext, nil
+}
+
+// zeroEnvValue returns an empty value for env vars imported without their values
+func zeroEnvValue(t domain.EnvVarType) interface{} {
+	switch t {
+	case domain.EnvVarTypeInteger:
+		return 0
+	case domain.EnvVarTypeFloat:
+		return 0.0
+	case domain.EnvVarTypeBoolean:
+		return false
+	default:
+		return ""
+	}
+}
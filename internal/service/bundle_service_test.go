@@ -0,0 +1,197 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func buildTestBundle(t *testing.T, version int, passphrase string) *bytes.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := domain.BundleManifest{
+		FormatVersion: version,
+		ExportedAt:    time.Now(),
+		Project:       domain.BundleProject{Name: "Shop", Slug: "shop"},
+		EnvMode:       domain.BundleEnvExcluded,
+	}
+	env := []domain.BundleEnvVar{
+		{Key: "API_TOKEN", Type: domain.EnvVarTypeString, Order: 0},
+		{Key: "LIMIT", Type: domain.EnvVarTypeInteger, Order: 1},
+	}
+	if passphrase != "" {
+		secrets, err := encryptBundleSecrets([]byte(`{"API_TOKEN":"secret","LIMIT":10}`), passphrase)
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		if err := writeBundleJSON(zw, bundleEnvSecretsFile, secrets); err != nil {
+			t.Fatal(err)
+		}
+		manifest.EnvMode = domain.BundleEnvEncrypted
+	}
+
+	if err := writeBundleJSON(zw, bundleManifestFile, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBundleJSON(zw, bundleEnvFile, env); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBundleJSON(zw, bundleBranchesFile, []domain.BundleBranch{
+		{Name: "develop", Files: []domain.CodeFile{{Name: "main", Code: "$logger.info('hi')"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		bundleDataDir + "orders.jsonl":    `{"_id":{"$oid":"65a000000000000000000001"},"total":{"$numberInt":"5"}}` + "\n",
+		bundleStorageDir + "img/logo.txt": "logo",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadBundle(t *testing.T) {
+	r := buildTestBundle(t, domain.BundleFormatVersion, "correct horse")
+
+	contents, warnings, err := readBundle(r, r.Size(), "correct horse")
+	if err != nil {
+		t.Fatalf("readBundle failed: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	if contents.manifest.Project.Slug != "shop" {
+		t.Errorf("unexpected manifest: %+v", contents.manifest)
+	}
+	if len(contents.branches) != 1 || contents.branches[0].Files[0].Name != "main" {
+		t.Errorf("unexpected branches: %+v", contents.branches)
+	}
+	if contents.secrets["API_TOKEN"] != "secret" {
+		t.Errorf("expected decrypted env values, got %v", contents.secrets)
+	}
+	if _, ok := contents.data["orders"]; !ok {
+		t.Error("expected data file for model orders")
+	}
+	if len(contents.storage) != 1 || contents.storage[0].Name != "storage/img/logo.txt" {
+		t.Errorf("unexpected storage entries: %d", len(contents.storage))
+	}
+}
+
+func TestReadBundleEnvWithoutPassphrase(t *testing.T) {
+	r := buildTestBundle(t, domain.BundleFormatVersion, "correct horse")
+
+	contents, warnings, err := readBundle(r, r.Size(), "")
+	if err != nil {
+		t.Fatalf("readBundle failed: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning about env values, got %v", warnings)
+	}
+	if contents.secrets != nil {
+		t.Errorf("expected no env values, got %v", contents.secrets)
+	}
+
+	plain := buildTestBundle(t, domain.BundleFormatVersion, "")
+	if _, warnings, _ := readBundle(plain, plain.Size(), ""); len(warnings) != 1 {
+		t.Errorf("expected a warning about excluded env values, got %v", warnings)
+	}
+}
+
+func TestReadBundleErrors(t *testing.T) {
+	r := buildTestBundle(t, domain.BundleFormatVersion, "correct horse")
+	if _, _, err := readBundle(r, r.Size(), "wrong"); !errors.Is(err, ErrBundleBadPassphrase) {
+		t.Errorf("expected ErrBundleBadPassphrase, got %v", err)
+	}
+
+	future := buildTestBundle(t, domain.BundleFormatVersion+1, "")
+	if _, _, err := readBundle(future, future.Size(), ""); !errors.Is(err, ErrBundleUnsupportedVersion) {
+		t.Errorf("expected ErrBundleUnsupportedVersion, got %v", err)
+	}
+
+	garbage := bytes.NewReader([]byte("not a zip"))
+	if _, _, err := readBundle(garbage, garbage.Size(), ""); !errors.Is(err, ErrBundleInvalid) {
+		t.Errorf("expected ErrBundleInvalid, got %v", err)
+	}
+}
+
+func TestReadBundleLimits(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeBundleJSON(zw, bundleManifestFile, domain.BundleManifest{FormatVersion: domain.BundleFormatVersion}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < bundleMaxEntries; i++ {
+		if _, err := zw.Create(fmt.Sprintf("storage/%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zw.Close()
+	r := bytes.NewReader(buf.Bytes())
+	if _, _, err := readBundle(r, r.Size(), ""); !errors.Is(err, ErrBundleTooLarge) {
+		t.Errorf("expected ErrBundleTooLarge for too many entries, got %v", err)
+	}
+
+	small := buildTestBundle(t, domain.BundleFormatVersion, "")
+	if _, _, err := readBundle(small, bundleMaxSize+1, ""); !errors.Is(err, ErrBundleTooLarge) {
+		t.Errorf("expected ErrBundleTooLarge for a large archive, got %v", err)
+	}
+}
+
+func TestImportDataValidation(t *testing.T) {
+	model := &domain.Model{
+		ID: primitive.NewObjectID(),
+		Fields: []domain.ModelField{
+			{Key: "total", Type: domain.FieldTypeNumber, Required: true},
+			{Key: "meta", Type: domain.FieldTypeDocument},
+			{Key: "paid_at", Type: domain.FieldTypeDateTime},
+		},
+	}
+	s := &ModelService{}
+
+	for name, line := range map[string]string{
+		"wrong type":    `{"_id":{"$oid":"65a000000000000000000001"},"total":"many"}`,
+		"unknown field": `{"_id":{"$oid":"65a000000000000000000002"},"total":{"$numberInt":"5"},"admin":true}`,
+		"missing field": `{"_id":{"$oid":"65a000000000000000000003"}}`,
+	} {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON([]byte(line), true, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ImportData(context.Background(), model, []bson.D{doc}); !IsValidationError(err) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+
+	var doc bson.D
+	line := `{"total":{"$numberInt":"5"},"meta":{"a":[{"$numberInt":"1"}]},"paid_at":{"$date":{"$numberLong":"1704164645000"}}}`
+	if err := bson.UnmarshalExtJSON([]byte(line), true, &doc); err != nil {
+		t.Fatal(err)
+	}
+	data := make(map[string]interface{})
+	for _, e := range doc {
+		data[e.Key] = importedValue(e.Value)
+	}
+	if err := NewDataValidator(model).Validate(data); err != nil {
+		t.Errorf("expected exported values to validate, got %v", err)
+	}
+}
@@ -66,6 +66,10 @@ func (s *EnvironmentService) Delete(ctx context.Context, projectID primitive.Obj
 	return s.envRepo.DeleteByKey(ctx, projectID, key)
 }
 
+func (s *EnvironmentService) DeleteByProject(ctx context.Context, projectID primitive.ObjectID) error {
+	return s.envRepo.DeleteByProject(ctx, projectID)
+}
+
 func (s *EnvironmentService) GetEnvMap(ctx context.Context, projectID primitive.ObjectID) (map[string]interface{}, error) {
 	envVars, err := s.envRepo.FindByProject(ctx, projectID)
 	if err != nil {
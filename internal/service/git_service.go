@@ -0,0 +1,842 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+// gitFileExt is appended to code file names when stored in a git repository
+const gitFileExt = ".js"
+
+// gitRemoteName is the remote name used in the temporary in-memory clone
+const gitRemoteName = "origin"
+
+// gitReleaseTagTrailer records the release tag (stable, hot-fix, ...) in the
+// message of a git tag
+const gitReleaseTagTrailer = "Release-Tag:"
+
+// releaseTagPattern matches release tags: v1.0, 1.0
+var releaseTagPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)$`)
+
+// legacyReleaseMessagePattern matches the default tag message of earlier
+// pushes: "Release 1.0 (hot-fix)"
+var legacyReleaseMessagePattern = regexp.MustCompile(`^Release \S+ \(([a-z-]+)\)$`)
+
+var (
+	ErrGitUnsupportedRemote = errors.New("unsupported git remote: use a local repository path or an http(s) URL")
+	ErrGitForbiddenRemote   = errors.New("git remote resolves to a loopback, private or link-local address")
+	ErrGitEmptyRemote       = errors.New("remote repository is empty")
+	ErrGitBranchNotFound    = errors.New("branch not found in remote repository")
+	ErrGitRemoteTooLarge    = fmt.Errorf("remote repository is too large: the branch and release tags may take at most %d MB and %d objects", gitMaxPackSize>>20, gitMaxObjects)
+)
+
+const (
+	// gitMaxPackSize and gitMaxObjects cap what is fetched from a remote, the
+	// clone is kept in memory
+	gitMaxPackSize = 100 << 20
+	gitMaxObjects  = 100_000
+)
+
+// gitHTTPClient talks to http(s) remotes, never to internal addresses
+var gitHTTPClient = githttp.NewClient(&http.Client{
+	Transport: &http.Transport{
+		DialContext:           publicDialer(30*time.Second, ErrGitForbiddenRemote).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+})
+
+// GitService imports and exports project code to plain git repositories.
+// Branches map to git branches, releases map to tags (v<version>).
+type GitService struct {
+	pipelineService *PipelineService
+}
+
+func NewGitService(pipelineService *PipelineService) *GitService {
+	return &GitService{
+		pipelineService: pipelineService,
+	}
+}
+
+// gitRemoteProtocol returns the transport protocol of a supported remote URL
+func gitRemoteProtocol(url string) (string, error) {
+	if url == "" {
+		return "", ErrGitUnsupportedRemote
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGitUnsupportedRemote, err)
+	}
+	switch endpoint.Protocol {
+	case "file", "http", "https":
+		return endpoint.Protocol, nil
+	default:
+		return "", ErrGitUnsupportedRemote
+	}
+}
+
+// gitTransport returns the transport for a remote: local paths are served
+// in-process, so no git binary is required, http(s) remotes go through
+// gitHTTPClient after their host is checked
+func gitTransport(ctx context.Context, url string) (transport.Transport, *transport.Endpoint, error) {
+	protocol, err := gitRemoteProtocol(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if protocol == "file" {
+		return server.DefaultServer, endpoint, nil
+	}
+
+	if err := checkRemoteHost(ctx, endpoint.Host, ErrGitForbiddenRemote); err != nil {
+		return nil, nil, err
+	}
+	return gitHTTPClient, endpoint, nil
+}
+
+// IsLocalGitRemote reports whether the remote points to a path on the server filesystem
+func IsLocalGitRemote(url string) bool {
+	protocol, err := gitRemoteProtocol(url)
+	return err == nil && protocol == "file"
+}
+
+// Push exports a branch (and optionally all releases as tags) to the remote repository
+func (s *GitService) Push(ctx context.Context, projectID primitive.ObjectID, req *domain.GitPushRequest, author *domain.User) (*domain.GitSyncResult, error) {
+	branch, err := s.pipelineService.GetBranch(ctx, projectID, req.BranchName)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []*domain.Release
+	if req.IncludeReleases {
+		releases, err = s.pipelineService.GetReleases(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	message := req.Message
+	if message == "" {
+		message = fmt.Sprintf("Update %s from m3m", branch.Name)
+	}
+
+	signature := object.Signature{
+		Name:  author.Name,
+		Email: author.Email,
+		When:  time.Now(),
+	}
+
+	return pushToGit(ctx, &req.GitRemote, branch.Name, branch.Files, releases, signature, message)
+}
+
+// Pull imports a branch (and optionally tags as new releases) from the remote repository
+func (s *GitService) Pull(ctx context.Context, projectID primitive.ObjectID, req *domain.GitPullRequest) (*domain.GitSyncResult, error) {
+	remoteBranch := req.RemoteBranch
+	if remoteBranch == "" {
+		remoteBranch = req.BranchName
+	}
+
+	snapshot, err := fetchFromGit(ctx, &req.GitRemote, remoteBranch, req.IncludeReleases)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.GitSyncResult{
+		Branch:  req.BranchName,
+		Commit:  snapshot.commit,
+		Files:   len(snapshot.files),
+		Tags:    []string{},
+		Skipped: []string{},
+	}
+
+	current, err := s.pipelineService.GetBranch(ctx, projectID, req.BranchName)
+	if err != nil || !sameFiles(current.Files, snapshot.files) {
+		if _, err := s.pipelineService.ImportBranch(ctx, projectID, req.BranchName, snapshot.files); err != nil {
+			return nil, fmt.Errorf("import branch: %w", err)
+		}
+		result.Changed = true
+	}
+
+	for _, tag := range snapshot.tags {
+		if _, err := s.pipelineService.GetRelease(ctx, projectID, tag.version); err == nil {
+			result.Skipped = append(result.Skipped, tag.name)
+			continue
+		}
+		if _, err := s.pipelineService.ImportRelease(ctx, projectID, tag.version, tag.files, tag.message, tag.tag); err != nil {
+			result.Skipped = append(result.Skipped, tag.name)
+			result.Messages = append(result.Messages, fmt.Sprintf("%s: %v", tag.name, err))
+			continue
+		}
+		result.Tags = append(result.Tags, tag.name)
+	}
+
+	return result, nil
+}
+
+// gitTagSnapshot is a release tag read from a remote repository
+type gitTagSnapshot struct {
+	name    string
+	version string
+	tag     domain.ReleaseTag
+	message string
+	files   []domain.CodeFile
+}
+
+// gitSnapshot is the content of a remote branch and its release tags
+type gitSnapshot struct {
+	commit string
+	files  []domain.CodeFile
+	tags   []gitTagSnapshot
+}
+
+// gitAuth returns HTTP basic auth for the remote, if credentials are set
+func gitAuth(remote *domain.GitRemote) transport.AuthMethod {
+	if remote.Username == "" && remote.Password == "" {
+		return nil
+	}
+	username := remote.Username
+	if username == "" {
+		// Token-only auth: most git hosts accept any non-empty username
+		username = "m3m"
+	}
+	return &githttp.BasicAuth{Username: username, Password: remote.Password}
+}
+
+// cloneGitRemote creates an in-memory repository with one branch (as
+// refs/remotes/origin/<branch>) and, with tags, the release tags of the remote
+// fetched, without their history. The pack is capped at gitMaxPackSize and
+// gitMaxObjects. The returned flag is false if the remote has no refs yet.
+func cloneGitRemote(ctx context.Context, remote *domain.GitRemote, branch string, tags bool) (*git.Repository, bool, error) {
+	t, endpoint, err := gitTransport(ctx, remote.URL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	session, err := t.NewUploadPackSession(endpoint, gitAuth(remote))
+	if err != nil {
+		return nil, false, fmt.Errorf("fetch %s: %w", remote.URL, err)
+	}
+	defer session.Close()
+
+	advertised, err := session.AdvertisedReferencesContext(ctx)
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return repo, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("fetch %s: %w", remote.URL, err)
+	}
+	remoteRefs, err := advertised.AllReferences()
+	if err != nil {
+		return nil, false, err
+	}
+
+	req := packp.NewUploadPackRequestFromCapabilities(advertised.Capabilities)
+	if advertised.Capabilities.Supports(capability.NoProgress) {
+		req.Capabilities.Set(capability.NoProgress)
+	}
+	// Only the tips are read, history is not needed
+	if advertised.Capabilities.Supports(capability.Shallow) {
+		req.Capabilities.Set(capability.Shallow)
+		req.Depth = packp.DepthCommits(1)
+	}
+
+	hasRefs := false
+	var refs []*plumbing.Reference
+	wanted := make(map[plumbing.Hash]bool)
+	for _, ref := range remoteRefs {
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		name := ref.Name()
+		switch {
+		case name.IsBranch():
+			hasRefs = true
+			if name.Short() != branch {
+				continue
+			}
+			name = plumbing.NewRemoteReferenceName(gitRemoteName, name.Short())
+		case name.IsTag():
+			hasRefs = true
+			if !tags || !releaseTagPattern.MatchString(name.Short()) {
+				continue
+			}
+		default:
+			continue
+		}
+		refs = append(refs, plumbing.NewHashReference(name, ref.Hash()))
+		if !wanted[ref.Hash()] {
+			wanted[ref.Hash()] = true
+			req.Wants = append(req.Wants, ref.Hash())
+		}
+	}
+	if len(refs) == 0 {
+		return repo, hasRefs, nil
+	}
+
+	response, err := session.UploadPack(ctx, req)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetch %s: %w", remote.URL, err)
+	}
+	defer response.Close()
+
+	pack, err := gitLimitPack(gitSideband(req.Capabilities, response))
+	if err != nil {
+		return nil, false, fmt.Errorf("fetch %s: %w", remote.URL, err)
+	}
+	if err := packfile.UpdateObjectStorage(repo.Storer, pack); err != nil {
+		return nil, false, fmt.Errorf("fetch %s: %w", remote.URL, err)
+	}
+	if len(response.Shallows) > 0 {
+		if err := repo.Storer.SetShallow(response.Shallows); err != nil {
+			return nil, false, err
+		}
+	}
+	for _, ref := range refs {
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return nil, false, err
+		}
+	}
+	return repo, true, nil
+}
+
+// gitLimitPack checks the object count in the header of a packfile and caps
+// the bytes read from it, failing with ErrGitRemoteTooLarge
+func gitLimitPack(r io.Reader) (io.Reader, error) {
+	header := make([]byte, 12) // "PACK", version, object count
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) == "PACK" && binary.BigEndian.Uint32(header[8:]) > gitMaxObjects {
+		return nil, ErrGitRemoteTooLarge
+	}
+	return &gitLimitReader{r: io.MultiReader(bytes.NewReader(header), r), left: gitMaxPackSize}, nil
+}
+
+// gitLimitReader fails with ErrGitRemoteTooLarge once more than left bytes are read
+type gitLimitReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *gitLimitReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// A pack of exactly the limit still ends
+		if n, err := l.r.Read(make([]byte, 1)); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, ErrGitRemoteTooLarge
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// gitSideband demultiplexes the packfile from progress messages, if the
+// server was asked to use a sideband
+func gitSideband(capabilities *capability.List, r io.Reader) io.Reader {
+	switch {
+	case capabilities.Supports(capability.Sideband64k):
+		return sideband.NewDemuxer(sideband.Sideband64k, r)
+	case capabilities.Supports(capability.Sideband):
+		return sideband.NewDemuxer(sideband.Sideband, r)
+	default:
+		return r
+	}
+}
+
+// sendToGit runs the ref updates on the remote, sending the objects it lacks
+func sendToGit(ctx context.Context, remote *domain.GitRemote, repo *git.Repository, commands []*packp.Command) error {
+	t, endpoint, err := gitTransport(ctx, remote.URL)
+	if err != nil {
+		return err
+	}
+
+	session, err := t.NewReceivePackSession(endpoint, gitAuth(remote))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	advertised, err := session.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return err
+	}
+	remoteRefs, err := advertised.AllReferences()
+	if err != nil {
+		return err
+	}
+
+	// Objects reachable from refs the remote already has are not sent
+	var haves []plumbing.Hash
+	for _, ref := range remoteRefs {
+		if ref.Type() == plumbing.HashReference && repo.Storer.HasEncodedObject(ref.Hash()) == nil {
+			haves = append(haves, ref.Hash())
+		}
+	}
+	var wants []plumbing.Hash
+	for _, cmd := range commands {
+		wants = append(wants, cmd.New)
+	}
+	hashes, err := revlist.Objects(repo.Storer, wants, haves)
+	if err != nil {
+		return err
+	}
+
+	var pack bytes.Buffer
+	if _, err := packfile.NewEncoder(&pack, repo.Storer, false).Encode(hashes, 10); err != nil {
+		return err
+	}
+
+	req := packp.NewReferenceUpdateRequestFromCapabilities(advertised.Capabilities)
+	req.Commands = commands
+	req.Packfile = io.NopCloser(&pack)
+
+	status, err := session.ReceivePack(ctx, req)
+	if err != nil {
+		return err
+	}
+	if status != nil {
+		return status.Error()
+	}
+	return nil
+}
+
+// pushToGit commits files on top of the remote branch and pushes it, along with release tags
+func pushToGit(ctx context.Context, remote *domain.GitRemote, branchName string, files []domain.CodeFile, releases []*domain.Release, signature object.Signature, message string) (*domain.GitSyncResult, error) {
+	repo, _, err := cloneGitRemote(ctx, remote, branchName, len(releases) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.GitSyncResult{
+		Branch:  branchName,
+		Files:   len(files),
+		Tags:    []string{},
+		Skipped: []string{},
+	}
+
+	var commands []*packp.Command
+
+	// Branch commit on top of the remote head
+	treeHash, err := writeGitTree(repo, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []plumbing.Hash
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName(gitRemoteName, branchName), true)
+	if err == nil {
+		parents = append(parents, remoteRef.Hash())
+	}
+
+	headHash := plumbing.ZeroHash
+	if len(parents) > 0 {
+		parent, err := repo.CommitObject(parents[0])
+		if err != nil {
+			return nil, err
+		}
+		if parent.TreeHash == treeHash {
+			headHash = parent.Hash
+		}
+	}
+
+	if headHash.IsZero() {
+		headHash, err = writeGitCommit(repo, treeHash, parents, signature, message)
+		if err != nil {
+			return nil, err
+		}
+		command := &packp.Command{Name: plumbing.NewBranchReferenceName(branchName), New: headHash}
+		if len(parents) > 0 {
+			command.Old = parents[0]
+		}
+		commands = append(commands, command)
+		result.Changed = true
+	}
+	result.Commit = headHash.String()
+
+	// Releases as annotated tags, oldest first, each commit parented on the previous release
+	sorted := make([]*domain.Release, len(releases))
+	copy(sorted, releases)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	var previous []plumbing.Hash
+	for _, release := range sorted {
+		tagName := "v" + release.Version
+		tagRefName := plumbing.NewTagReferenceName(tagName)
+
+		if existing, err := repo.Reference(tagRefName, true); err == nil {
+			result.Skipped = append(result.Skipped, tagName)
+			if commit, err := resolveGitTagCommit(repo, existing); err == nil {
+				previous = []plumbing.Hash{commit.Hash}
+			}
+			continue
+		}
+
+		releaseTree, err := writeGitTree(repo, release.Files)
+		if err != nil {
+			return nil, err
+		}
+
+		releaseSignature := signature
+		releaseSignature.When = release.CreatedAt
+
+		commitMessage := fmt.Sprintf("Release %s", release.Version)
+		if release.Comment != "" {
+			commitMessage += "\n\n" + release.Comment
+		}
+
+		commitHash, err := writeGitCommit(repo, releaseTree, previous, releaseSignature, commitMessage)
+		if err != nil {
+			return nil, err
+		}
+		previous = []plumbing.Hash{commitHash}
+
+		tagHash, err := writeGitTag(repo, tagName, commitHash, releaseSignature, release)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, &packp.Command{Name: tagRefName, New: tagHash})
+		result.Tags = append(result.Tags, tagName)
+	}
+
+	if len(commands) == 0 {
+		result.Messages = append(result.Messages, "remote is up to date")
+		return result, nil
+	}
+
+	if err := sendToGit(ctx, remote, repo, commands); err != nil {
+		return nil, fmt.Errorf("push %s: %w", remote.URL, err)
+	}
+
+	return result, nil
+}
+
+// fetchFromGit reads the files of a remote branch and, optionally, of all release tags
+func fetchFromGit(ctx context.Context, remote *domain.GitRemote, branchName string, includeTags bool) (*gitSnapshot, error) {
+	repo, hasRefs, err := cloneGitRemote(ctx, remote, branchName, includeTags)
+	if err != nil {
+		return nil, err
+	}
+	if !hasRefs {
+		return nil, ErrGitEmptyRemote
+	}
+
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName(gitRemoteName, branchName), true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrGitBranchNotFound, branchName)
+	}
+
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := readGitFiles(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &gitSnapshot{
+		commit: commit.Hash.String(),
+		files:  files,
+	}
+
+	if !includeTags {
+		return snapshot, nil
+	}
+
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	err = refs.ForEach(func(tagRef *plumbing.Reference) error {
+		name := tagRef.Name().Short()
+		match := releaseTagPattern.FindStringSubmatch(name)
+		if match == nil {
+			return nil
+		}
+
+		tagCommit, err := resolveGitTagCommit(repo, tagRef)
+		if err != nil {
+			return nil // Tag doesn't point to a commit, skip
+		}
+
+		tagFiles, err := readGitFiles(tagCommit)
+		if err != nil || len(tagFiles) == 0 {
+			return nil
+		}
+
+		message, releaseTag := "", domain.ReleaseTagStable
+		if tagObject, err := repo.TagObject(tagRef.Hash()); err == nil {
+			message, releaseTag = parseGitTagMessage(tagObject.Message)
+		}
+
+		snapshot.tags = append(snapshot.tags, gitTagSnapshot{
+			name:    name,
+			version: match[1] + "." + match[2],
+			tag:     releaseTag,
+			message: message,
+			files:   tagFiles,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snapshot.tags, func(i, j int) bool {
+		return compareVersions(snapshot.tags[i].version, snapshot.tags[j].version) < 0
+	})
+
+	return snapshot, nil
+}
+
+// resolveGitTagCommit returns the commit a tag (annotated or lightweight) points to
+func resolveGitTagCommit(repo *git.Repository, ref *plumbing.Reference) (*object.Commit, error) {
+	if tagObject, err := repo.TagObject(ref.Hash()); err == nil {
+		return tagObject.Commit()
+	}
+	return repo.CommitObject(ref.Hash())
+}
+
+// readGitFiles converts .js files of a commit into code files (extension stripped)
+func readGitFiles(commit *object.Commit) ([]domain.CodeFile, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []domain.CodeFile
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !strings.HasSuffix(f.Name, gitFileExt) {
+			return nil
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		files = append(files, domain.CodeFile{
+			Name: strings.TrimSuffix(f.Name, gitFileExt),
+			Code: content,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep main first, others sorted by name
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Name == "main" || files[j].Name == "main" {
+			return files[i].Name == "main"
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	return files, nil
+}
+
+// writeGitTree stores code files as <name>.js blobs and returns the root tree hash
+func writeGitTree(repo *git.Repository, files []domain.CodeFile) (plumbing.Hash, error) {
+	blobs := make(map[string]plumbing.Hash, len(files))
+	for _, f := range files {
+		hash, err := writeGitObject(repo, plumbing.BlobObject, func(w io.Writer) error {
+			_, err := w.Write([]byte(f.Code))
+			return err
+		})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		blobs[f.Name+gitFileExt] = hash
+	}
+	return writeGitTreeLevel(repo, "", blobs)
+}
+
+// writeGitTreeLevel writes the tree for dir, recursing into subdirectories of file paths
+func writeGitTreeLevel(repo *git.Repository, dir string, blobs map[string]plumbing.Hash) (plumbing.Hash, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	tree := &object.Tree{}
+	subdirs := make(map[string]bool)
+
+	for filePath, hash := range blobs {
+		if !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(filePath, prefix)
+		if idx := strings.Index(rest, "/"); idx >= 0 {
+			subdirs[rest[:idx]] = true
+			continue
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: rest, Mode: filemode.Regular, Hash: hash})
+	}
+
+	for name := range subdirs {
+		hash, err := writeGitTreeLevel(repo, path.Join(dir, name), blobs)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// Git orders tree entries by name, with directories compared as "name/"
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
+	})
+
+	obj := repo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+func writeGitCommit(repo *git.Repository, treeHash plumbing.Hash, parents []plumbing.Hash, signature object.Signature, message string) (plumbing.Hash, error) {
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// writeGitTag writes an annotated tag for a release. The release comment is
+// the message, the release tag is kept in a trailer.
+func writeGitTag(repo *git.Repository, name string, target plumbing.Hash, signature object.Signature, release *domain.Release) (plumbing.Hash, error) {
+	message := release.Comment
+	if message == "" {
+		message = "Release " + release.Version
+	}
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     signature,
+		Message:    fmt.Sprintf("%s\n\n%s %s\n", message, gitReleaseTagTrailer, release.Tag),
+		TargetType: plumbing.CommitObject,
+		Target:     target,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// parseGitTagMessage splits a tag message into the release comment and the
+// release tag. Tags not pushed by m3m are stable releases.
+func parseGitTagMessage(message string) (string, domain.ReleaseTag) {
+	message = strings.TrimSpace(message)
+	lines := strings.Split(message, "\n")
+	if value, ok := strings.CutPrefix(lines[len(lines)-1], gitReleaseTagTrailer); ok {
+		if tag := domain.ReleaseTag(strings.TrimSpace(value)); tag.Valid() {
+			return strings.TrimSpace(strings.Join(lines[:len(lines)-1], "\n")), tag
+		}
+	}
+	if match := legacyReleaseMessagePattern.FindStringSubmatch(message); match != nil {
+		if tag := domain.ReleaseTag(match[1]); tag.Valid() {
+			return message, tag
+		}
+	}
+	return message, domain.ReleaseTagStable
+}
+
+func writeGitObject(repo *git.Repository, objectType plumbing.ObjectType, write func(w io.Writer) error) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(objectType)
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := write(w); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// sameFiles reports whether two file sets have identical names and contents
+func sameFiles(a, b []domain.CodeFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	contents := make(map[string]string, len(a))
+	for _, f := range a {
+		contents[f.Name] = f.Code
+	}
+	for _, f := range b {
+		code, ok := contents[f.Name]
+		if !ok || code != f.Code {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersions compares "major.minor" versions numerically
+func compareVersions(a, b string) int {
+	var aMajor, aMinor, bMajor, bMinor int
+	fmt.Sscanf(a, "%d.%d", &aMajor, &aMinor)
+	fmt.Sscanf(b, "%d.%d", &bMajor, &bMinor)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
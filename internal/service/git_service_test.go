@@ -0,0 +1,248 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatalf("failed to init bare repo: %v", err)
+	}
+	return dir
+}
+
+func testSignature() object.Signature {
+	return object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+}
+
+func TestGitPushAndFetchBranch(t *testing.T) {
+	ctx := context.Background()
+	remote := &domain.GitRemote{URL: newBareRepo(t)}
+
+	files := []domain.CodeFile{
+		{Name: "main", Code: "const u = $require('lib/utils');\n"},
+		{Name: "lib/utils", Code: "$exports.x = 1;\n"},
+		{Name: "helpers", Code: "$exports.y = 2;\n"},
+	}
+
+	result, err := pushToGit(ctx, remote, "develop", files, nil, testSignature(), "initial")
+	if err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if !result.Changed || result.Commit == "" {
+		t.Fatalf("expected a new commit, got %+v", result)
+	}
+
+	snapshot, err := fetchFromGit(ctx, remote, "develop", false)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if snapshot.commit != result.Commit {
+		t.Errorf("expected commit %s, got %s", result.Commit, snapshot.commit)
+	}
+	if !sameFiles(files, snapshot.files) {
+		t.Errorf("files differ after round trip: %+v", snapshot.files)
+	}
+	if snapshot.files[0].Name != "main" {
+		t.Errorf("expected main file first, got %s", snapshot.files[0].Name)
+	}
+
+	// Pushing identical content does not create a commit
+	again, err := pushToGit(ctx, remote, "develop", files, nil, testSignature(), "no-op")
+	if err != nil {
+		t.Fatalf("second push failed: %v", err)
+	}
+	if again.Changed || again.Commit != result.Commit {
+		t.Errorf("expected unchanged branch, got %+v", again)
+	}
+
+	// Changed content is committed on top of the remote head
+	files[0].Code = "// changed\n"
+	updated, err := pushToGit(ctx, remote, "develop", files, nil, testSignature(), "update")
+	if err != nil {
+		t.Fatalf("third push failed: %v", err)
+	}
+
+	repo, err := git.PlainOpen(remote.URL)
+	if err != nil {
+		t.Fatalf("failed to open bare repo: %v", err)
+	}
+	head, err := repo.CommitObject(plumbing.NewHash(updated.Commit))
+	if err != nil {
+		t.Fatalf("commit not found in remote: %v", err)
+	}
+	if len(head.ParentHashes) != 1 || head.ParentHashes[0].String() != result.Commit {
+		t.Errorf("expected parent %s, got %v", result.Commit, head.ParentHashes)
+	}
+}
+
+func TestGitPushReleasesAsTags(t *testing.T) {
+	ctx := context.Background()
+	remote := &domain.GitRemote{URL: newBareRepo(t)}
+
+	now := time.Now()
+	releases := []*domain.Release{
+		{Version: "1.1", Comment: "second", Tag: domain.ReleaseTagHotFix, Files: []domain.CodeFile{{Name: "main", Code: "v11"}}, CreatedAt: now},
+		{Version: "1.0", Comment: "first", Tag: domain.ReleaseTagStable, Files: []domain.CodeFile{{Name: "main", Code: "v10"}}, CreatedAt: now.Add(-time.Hour)},
+	}
+	files := []domain.CodeFile{{Name: "main", Code: "develop"}}
+
+	result, err := pushToGit(ctx, remote, "develop", files, releases, testSignature(), "initial")
+	if err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if len(result.Tags) != 2 || result.Tags[0] != "v1.0" || result.Tags[1] != "v1.1" {
+		t.Fatalf("expected tags [v1.0 v1.1], got %v", result.Tags)
+	}
+
+	// Existing tags are skipped on the next push
+	again, err := pushToGit(ctx, remote, "develop", files, releases, testSignature(), "again")
+	if err != nil {
+		t.Fatalf("second push failed: %v", err)
+	}
+	if len(again.Tags) != 0 || len(again.Skipped) != 2 {
+		t.Errorf("expected all tags skipped, got %+v", again)
+	}
+
+	snapshot, err := fetchFromGit(ctx, remote, "develop", true)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(snapshot.tags) != 2 {
+		t.Fatalf("expected 2 tags, got %d", len(snapshot.tags))
+	}
+	if snapshot.tags[0].version != "1.0" || snapshot.tags[0].files[0].Code != "v10" || snapshot.tags[0].message != "first" {
+		t.Errorf("unexpected first tag: %+v", snapshot.tags[0])
+	}
+	if snapshot.tags[0].tag != domain.ReleaseTagStable {
+		t.Errorf("expected first tag to be stable, got %s", snapshot.tags[0].tag)
+	}
+	if snapshot.tags[1].version != "1.1" || snapshot.tags[1].files[0].Code != "v11" || snapshot.tags[1].tag != domain.ReleaseTagHotFix {
+		t.Errorf("unexpected second tag: %+v", snapshot.tags[1])
+	}
+}
+
+func TestGitFetchErrors(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := fetchFromGit(ctx, &domain.GitRemote{URL: newBareRepo(t)}, "develop", false); err != ErrGitEmptyRemote {
+		t.Errorf("expected ErrGitEmptyRemote, got %v", err)
+	}
+
+	remote := &domain.GitRemote{URL: newBareRepo(t)}
+	if _, err := pushToGit(ctx, remote, "develop", []domain.CodeFile{{Name: "main"}}, nil, testSignature(), "init"); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if _, err := fetchFromGit(ctx, remote, "missing", false); err == nil {
+		t.Error("expected error for missing branch")
+	}
+
+	for _, url := range []string{"", "ssh://git@example.com/repo.git", "git@example.com:repo.git"} {
+		if _, err := gitRemoteProtocol(url); err == nil {
+			t.Errorf("expected %q to be rejected", url)
+		}
+	}
+	if !IsLocalGitRemote("/srv/git/repo.git") || IsLocalGitRemote("https://example.com/repo.git") {
+		t.Error("IsLocalGitRemote misclassified remotes")
+	}
+
+	for _, url := range []string{
+		"http://127.0.0.1/repo.git",
+		"http://localhost:8080/repo.git",
+		"https://10.0.0.5/repo.git",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/repo.git",
+	} {
+		if _, err := fetchFromGit(ctx, &domain.GitRemote{URL: url}, "develop", false); !errors.Is(err, ErrGitForbiddenRemote) {
+			t.Errorf("expected %q to be forbidden, got %v", url, err)
+		}
+	}
+}
+
+func TestParseGitTagMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		comment string
+		tag     domain.ReleaseTag
+	}{
+		{"first\n\nRelease-Tag: night-build\n", "first", domain.ReleaseTagNightBuild},
+		{"Release 1.0\n\nRelease-Tag: develop", "Release 1.0", domain.ReleaseTagDevelop},
+		{"Release 1.0 (hot-fix)\n", "Release 1.0 (hot-fix)", domain.ReleaseTagHotFix},
+		{"Release-Tag: bogus", "Release-Tag: bogus", domain.ReleaseTagStable},
+		{"tagged by hand\n", "tagged by hand", domain.ReleaseTagStable},
+	}
+
+	for _, tt := range tests {
+		comment, tag := parseGitTagMessage(tt.message)
+		if comment != tt.comment || tag != tt.tag {
+			t.Errorf("%q: got %q (%s), want %q (%s)", tt.message, comment, tag, tt.comment, tt.tag)
+		}
+	}
+}
+
+func TestGitCloneSingleBranch(t *testing.T) {
+	ctx := context.Background()
+	remote := &domain.GitRemote{URL: newBareRepo(t)}
+	for _, branch := range []string{"develop", "main"} {
+		if _, err := pushToGit(ctx, remote, branch, []domain.CodeFile{{Name: "main", Code: branch}}, nil, testSignature(), "init"); err != nil {
+			t.Fatalf("push %s failed: %v", branch, err)
+		}
+	}
+
+	repo, hasRefs, err := cloneGitRemote(ctx, remote, "develop", false)
+	if err != nil || !hasRefs {
+		t.Fatalf("clone: %v %v", hasRefs, err)
+	}
+	if _, err := repo.Reference(plumbing.NewRemoteReferenceName(gitRemoteName, "develop"), true); err != nil {
+		t.Errorf("develop was not fetched: %v", err)
+	}
+	if _, err := repo.Reference(plumbing.NewRemoteReferenceName(gitRemoteName, "main"), true); err == nil {
+		t.Error("main was fetched too")
+	}
+}
+
+func TestGitLimitPack(t *testing.T) {
+	header := func(objects uint32) []byte {
+		h := []byte("PACK\x00\x00\x00\x02\x00\x00\x00\x00")
+		binary.BigEndian.PutUint32(h[8:], objects)
+		return h
+	}
+
+	if _, err := gitLimitPack(bytes.NewReader(header(gitMaxObjects + 1))); !errors.Is(err, ErrGitRemoteTooLarge) {
+		t.Errorf("too many objects: %v", err)
+	}
+
+	r, err := gitLimitPack(io.MultiReader(bytes.NewReader(header(1)), io.LimitReader(zeroReader{}, gitMaxPackSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, r); !errors.Is(err, ErrGitRemoteTooLarge) {
+		t.Errorf("oversized pack: %v", err)
+	}
+
+	r, _ = gitLimitPack(bytes.NewReader(header(1)))
+	if data, err := io.ReadAll(r); err != nil || len(data) != 12 {
+		t.Errorf("small pack: %d bytes, %v", len(data), err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
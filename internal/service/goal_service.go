@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,17 +14,48 @@ import (
 	"github.com/levskiy0/m3m/internal/repository"
 )
 
+// ErrInvalidGoal is returned for goals with an unknown type or invalid buckets
+var ErrInvalidGoal = errors.New("invalid goal")
+
+// maxGoalLabels limits the label dimensions of a stat
+const maxGoalLabels = 8
+
+var goalLabelPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
 type GoalService struct {
-	goalRepo *repository.GoalRepository
+	goalRepo       *repository.GoalRepository
+	projectService *ProjectService
 }
 
-func NewGoalService(goalRepo *repository.GoalRepository) *GoalService {
+func NewGoalService(goalRepo *repository.GoalRepository, projectService *ProjectService) *GoalService {
 	return &GoalService{
-		goalRepo: goalRepo,
+		goalRepo:       goalRepo,
+		projectService: projectService,
 	}
 }
 
+// Now returns the current time in the timezone of a project. Daily and hourly
+// stats of the project are bucketed by it.
+func (s *GoalService) Now(ctx context.Context, projectID primitive.ObjectID) time.Time {
+	return time.Now().In(s.projectService.Location(ctx, projectID))
+}
+
+// GoalNow returns the current time in the timezone of the project owning a
+// goal, UTC for global goals
+func (s *GoalService) GoalNow(ctx context.Context, goalID string) time.Time {
+	if oid, err := primitive.ObjectIDFromHex(goalID); err == nil {
+		if goal, err := s.goalRepo.FindByID(ctx, oid); err == nil && goal.ProjectRef != nil {
+			return s.Now(ctx, *goal.ProjectRef)
+		}
+	}
+	return time.Now().UTC()
+}
+
 func (s *GoalService) CreateGlobal(ctx context.Context, req *domain.CreateGoalRequest) (*domain.Goal, error) {
+	if err := validateGoalRequest(req); err != nil {
+		return nil, err
+	}
+
 	allowedProjects := make([]primitive.ObjectID, 0)
 	for _, idStr := range req.AllowedProjects {
 		oid, err := primitive.ObjectIDFromHex(idStr)
@@ -36,6 +70,7 @@ func (s *GoalService) CreateGlobal(ctx context.Context, req *domain.CreateGoalRe
 		Color:           req.Color,
 		Type:            req.Type,
 		Description:     req.Description,
+		Buckets:         histogramBuckets(req),
 		ProjectRef:      nil,
 		AllowedProjects: allowedProjects,
 	}
@@ -48,6 +83,10 @@ func (s *GoalService) CreateGlobal(ctx context.Context, req *domain.CreateGoalRe
 }
 
 func (s *GoalService) CreateForProject(ctx context.Context, projectID primitive.ObjectID, req *domain.CreateGoalRequest) (*domain.Goal, error) {
+	if err := validateGoalRequest(req); err != nil {
+		return nil, err
+	}
+
 	gridSpan := req.GridSpan
 	if gridSpan == 0 {
 		gridSpan = 1
@@ -59,6 +98,7 @@ func (s *GoalService) CreateForProject(ctx context.Context, projectID primitive.
 		Color:           req.Color,
 		Type:            req.Type,
 		Description:     req.Description,
+		Buckets:         histogramBuckets(req),
 		ProjectRef:      &projectID,
 		AllowedProjects: []primitive.ObjectID{projectID},
 		GridSpan:        gridSpan,
@@ -134,41 +174,128 @@ func (s *GoalService) Delete(ctx context.Context, id primitive.ObjectID) error {
 	return s.goalRepo.Delete(ctx, id)
 }
 
-func (s *GoalService) Increment(ctx context.Context, goalSlug string, projectID primitive.ObjectID, value int64) error {
-	goal, err := s.goalRepo.FindBySlug(ctx, goalSlug)
+// Increment adds value to a counter goal. labels optionally split the
+// counter into dimensions.
+func (s *GoalService) Increment(ctx context.Context, goalSlug string, projectID primitive.ObjectID, value int64, labels map[string]string) error {
+	goal, err := s.accessibleGoal(ctx, goalSlug, projectID)
 	if err != nil {
 		return err
 	}
+	if goal.Type.IsMetric() {
+		return fmt.Errorf("goal %s is a %s goal, use record", goalSlug, goal.Type)
+	}
+	if err := validateGoalLabels(labels); err != nil {
+		return err
+	}
 
-	// Check if project has access to this goal
-	hasAccess := false
-	if goal.ProjectRef != nil && *goal.ProjectRef == projectID {
-		hasAccess = true
-	} else {
-		for _, allowed := range goal.AllowedProjects {
-			if allowed == projectID {
-				hasAccess = true
-				break
-			}
-		}
+	stat := &domain.GoalStat{GoalID: goal.ID, ProjectID: projectID, Date: "total", Labels: labels, Value: value}
+	if goal.Type == domain.GoalTypeDailyCounter {
+		now := s.Now(ctx, projectID)
+		hour := now.Hour()
+		stat.Date, stat.Hour = now.Format("2006-01-02"), &hour
 	}
+	return s.goalRepo.MergeStat(ctx, stat)
+}
 
-	if !hasAccess {
-		return fmt.Errorf("project does not have access to goal %s", goalSlug)
+// Record adds a sample to a gauge, sum, avg or histogram goal. Samples are
+// kept in hourly buckets per label set.
+func (s *GoalService) Record(ctx context.Context, goalSlug string, projectID primitive.ObjectID, value float64, labels map[string]string) error {
+	goal, err := s.accessibleGoal(ctx, goalSlug, projectID)
+	if err != nil {
+		return err
+	}
+	if !goal.Type.IsMetric() {
+		return fmt.Errorf("goal %s is a %s goal, use increment", goalSlug, goal.Type)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid value for goal %s", goalSlug)
+	}
+	if err := validateGoalLabels(labels); err != nil {
+		return err
 	}
 
-	var date string
-	if goal.Type == domain.GoalTypeDailyCounter {
-		date = time.Now().UTC().Format("2006-01-02")
-	} else {
-		date = "total"
+	now := s.Now(ctx, projectID)
+	hour := now.Hour()
+	return s.goalRepo.MergeStat(ctx, &domain.GoalStat{
+		GoalID:    goal.ID,
+		ProjectID: projectID,
+		Date:      now.Format("2006-01-02"),
+		Hour:      &hour,
+		Labels:    labels,
+		Value:     1,
+		Metric:    domain.NewGoalMetric(value, now, goal.HistogramBounds()),
+	})
+}
+
+// accessibleGoal finds a goal by slug, checking that the project may use it
+func (s *GoalService) accessibleGoal(ctx context.Context, goalSlug string, projectID primitive.ObjectID) (*domain.Goal, error) {
+	goal, err := s.goalRepo.FindBySlug(ctx, goalSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	if !goalAllowsProject(goal, projectID) {
+		return nil, fmt.Errorf("project does not have access to goal %s", goalSlug)
+	}
+	return goal, nil
+}
+
+// validateGoalRequest checks the type and histogram buckets of a new goal.
+// Buckets can't change later, as stored stats refer to them by index.
+func validateGoalRequest(req *domain.CreateGoalRequest) error {
+	if !req.Type.Valid() {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidGoal, req.Type)
+	}
+	for i, bound := range req.Buckets {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) || (i > 0 && bound <= req.Buckets[i-1]) {
+			return fmt.Errorf("%w: buckets must be finite and ascending", ErrInvalidGoal)
+		}
+	}
+	return nil
+}
+
+func histogramBuckets(req *domain.CreateGoalRequest) []float64 {
+	if req.Type != domain.GoalTypeHistogram {
+		return nil
 	}
+	return req.Buckets
+}
 
-	return s.goalRepo.IncrementStat(ctx, goal.ID, projectID, date, value)
+func validateGoalLabels(labels map[string]string) error {
+	if len(labels) > maxGoalLabels {
+		return fmt.Errorf("too many labels, at most %d are allowed", maxGoalLabels)
+	}
+	for key := range labels {
+		if !goalLabelPattern.MatchString(key) {
+			return fmt.Errorf("invalid label name %q", key)
+		}
+	}
+	return nil
 }
 
+// GetStats returns aggregated goal stats; metric stats get their average and
+// histogram percentiles computed
 func (s *GoalService) GetStats(ctx context.Context, query *domain.GoalStatsQuery) ([]*domain.GoalStat, error) {
-	return s.goalRepo.GetStats(ctx, query)
+	stats, err := s.goalRepo.GetStats(ctx, query)
+	if err != nil || query.Raw {
+		return stats, err
+	}
+
+	bounds := make(map[primitive.ObjectID][]float64)
+	for _, stat := range stats {
+		if stat.Metric == nil {
+			continue
+		}
+		b, ok := bounds[stat.GoalID]
+		if !ok {
+			if goal, err := s.goalRepo.FindByID(ctx, stat.GoalID); err == nil {
+				b = goal.HistogramBounds()
+			}
+			bounds[stat.GoalID] = b
+		}
+		stat.Metric.Finish(b)
+	}
+	return stats, nil
 }
 
 func (s *GoalService) GetTotalValues(ctx context.Context, goalIDs []string) (map[string]int64, error) {
@@ -178,3 +305,14 @@ func (s *GoalService) GetTotalValues(ctx context.Context, goalIDs []string) (map
 func (s *GoalService) ResetStats(ctx context.Context, id primitive.ObjectID) error {
 	return s.goalRepo.ResetStats(ctx, id)
 }
+
+// ImportStats adds previously exported stats to a goal
+func (s *GoalService) ImportStats(ctx context.Context, goalID, projectID primitive.ObjectID, stats []domain.GoalStat) error {
+	for _, stat := range stats {
+		stat.GoalID, stat.ProjectID = goalID, projectID
+		if err := s.goalRepo.MergeStat(ctx, &stat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,636 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+const (
+	DefaultLogQueryLimit = 1000
+	MaxLogQueryLimit     = 10000
+
+	maxLogLineSize = 1024 * 1024
+
+	logFileExt           = ".log"
+	compressedLogFileExt = ".log.gz"
+)
+
+// LogService reads and searches structured runtime logs and enforces log retention
+type LogService struct {
+	config         *config.Config
+	storageService *StorageService
+	projectService *ProjectService
+
+	// Log files currently held open by runtimes, never rotated away or deleted by the janitor
+	active   map[string]int
+	activeMu sync.Mutex
+
+	// Receives the log size of each project after the janitor ran
+	usageReporter func(projectID string, resource domain.QuotaResource, used int64)
+}
+
+func NewLogService(cfg *config.Config, storageService *StorageService, projectService *ProjectService) *LogService {
+	return &LogService{
+		config:         cfg,
+		storageService: storageService,
+		projectService: projectService,
+		active:         make(map[string]int),
+	}
+}
+
+// Acquire marks a log file as being written by a runtime
+func (s *LogService) Acquire(path string) {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	s.active[path]++
+}
+
+// Release marks a log file acquired with Acquire as no longer written
+func (s *LogService) Release(path string) {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	if s.active[path] <= 1 {
+		delete(s.active, path)
+		return
+	}
+	s.active[path]--
+}
+
+func (s *LogService) isActive(path string) bool {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	return s.active[path] > 0
+}
+
+// Policy returns the retention policy of a project: config defaults with the
+// project overrides applied. A nil project gets the defaults.
+func (s *LogService) Policy(project *domain.Project) domain.LogRetentionPolicy {
+	cfg := s.config.Logging
+	policy := domain.LogRetentionPolicy{
+		MaxFileSize:  int64(cfg.MaxFileSizeMB) << 20,
+		MaxTotalSize: int64(cfg.MaxTotalSizeMB) << 20,
+		MaxAge:       cfg.MaxAge,
+		Compress:     cfg.Compress,
+	}
+
+	if project != nil && project.LogRetention != nil {
+		override := project.LogRetention
+		if override.MaxFileSizeMB != nil {
+			policy.MaxFileSize = int64(*override.MaxFileSizeMB) << 20
+		}
+		if override.MaxTotalSizeMB != nil {
+			policy.MaxTotalSize = int64(*override.MaxTotalSizeMB) << 20
+		}
+		if override.MaxAgeHours != nil {
+			policy.MaxAge = time.Duration(*override.MaxAgeHours) * time.Hour
+		}
+	}
+
+	// The log quota caps the retained size
+	if quota := QuotaLimitsFor(s.config.Quota, project).LogBytes; quota > 0 && (policy.MaxTotalSize == 0 || quota < policy.MaxTotalSize) {
+		policy.MaxTotalSize = quota
+	}
+
+	return policy
+}
+
+// SetUsageReporter sets the receiver of log sizes measured by the janitor
+func (s *LogService) SetUsageReporter(reporter func(projectID string, resource domain.QuotaResource, used int64)) {
+	s.usageReporter = reporter
+}
+
+// ProjectPolicy loads the project and returns its retention policy,
+// falling back to the defaults if the project cannot be loaded
+func (s *LogService) ProjectPolicy(ctx context.Context, projectID primitive.ObjectID) domain.LogRetentionPolicy {
+	if s.projectService == nil {
+		return s.Policy(nil)
+	}
+	project, err := s.projectService.GetByID(ctx, projectID)
+	if err != nil {
+		return s.Policy(nil)
+	}
+	return s.Policy(project)
+}
+
+// ListFiles returns the current and historical log files of a project, newest first
+func (s *LogService) ListFiles(projectID string) ([]domain.LogFile, error) {
+	logsPath := s.storageService.GetLogsPath(projectID)
+	entries, err := os.ReadDir(logsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []domain.LogFile{}, nil
+		}
+		return nil, err
+	}
+
+	files := make([]domain.LogFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isLogFileName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, domain.LogFile{
+			Name:       entry.Name(),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+			Compressed: strings.HasSuffix(entry.Name(), compressedLogFileExt),
+			Active:     s.isActive(filepath.Join(logsPath, entry.Name())),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModifiedAt.After(files[j].ModifiedAt)
+	})
+	return files, nil
+}
+
+// LatestLogFile returns the path of the most recently written log file of a project
+func (s *LogService) LatestLogFile(projectID string) (string, error) {
+	files, err := s.ListFiles(projectID)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", ErrFileNotFound
+	}
+	return filepath.Join(s.storageService.GetLogsPath(projectID), files[0].Name), nil
+}
+
+// LogFilePath resolves a log file name of a project, the latest file if name is empty
+func (s *LogService) LogFilePath(projectID, name string) (string, error) {
+	if name == "" {
+		return s.LatestLogFile(projectID)
+	}
+	if name != filepath.Base(name) || !isLogFileName(name) {
+		return "", ErrInvalidPath
+	}
+
+	path := filepath.Join(s.storageService.GetLogsPath(projectID), name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrFileNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// OpenLogFile opens a log file for reading, transparently decompressing rotated files
+func OpenLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressedLogFileExt) {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipLogReader{Reader: gz, file: file}, nil
+}
+
+type gzipLogReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipLogReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+func isLogFileName(name string) bool {
+	return strings.HasSuffix(name, logFileExt) || strings.HasSuffix(name, compressedLogFileExt)
+}
+
+// Enforce applies a retention policy to the log directory of a project:
+// finished files are compressed, files older than MaxAge are removed and the
+// oldest files are removed until the directory fits into MaxTotalSize.
+// Files held open by a runtime are never touched.
+func (s *LogService) Enforce(projectID string, policy domain.LogRetentionPolicy) error {
+	logsPath := s.storageService.GetLogsPath(projectID)
+	files, err := s.ListFiles(projectID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	now := time.Now()
+	kept := make([]domain.LogFile, 0, len(files))
+	var total int64
+
+	for _, file := range files {
+		path := filepath.Join(logsPath, file.Name)
+		if !file.Active {
+			if policy.MaxAge > 0 && now.Sub(file.ModifiedAt) > policy.MaxAge {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+
+			if policy.Compress && !file.Compressed {
+				compressed, err := compressLogFile(path)
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					file = compressed
+				}
+			}
+		}
+
+		kept = append(kept, file)
+		total += file.Size
+	}
+
+	if policy.MaxTotalSize > 0 {
+		// kept is newest first: drop from the end
+		for i := len(kept) - 1; i >= 0 && total > policy.MaxTotalSize; i-- {
+			if kept[i].Active {
+				continue
+			}
+			if err := os.Remove(filepath.Join(logsPath, kept[i].Name)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+				continue
+			}
+			total -= kept[i].Size
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// EnforceAll applies retention to the log directories of all projects,
+// including directories left behind by deleted projects
+func (s *LogService) EnforceAll(ctx context.Context) error {
+	entries, err := os.ReadDir(s.config.Logging.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		policy := s.Policy(nil)
+		if projectID, err := primitive.ObjectIDFromHex(entry.Name()); err == nil {
+			policy = s.ProjectPolicy(ctx, projectID)
+		}
+		if err := s.Enforce(entry.Name(), policy); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+		}
+
+		if s.usageReporter != nil {
+			if files, err := s.ListFiles(entry.Name()); err == nil {
+				var total int64
+				for _, file := range files {
+					total += file.Size
+				}
+				s.usageReporter(entry.Name(), domain.QuotaLogs, total)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunJanitor enforces retention every logging.janitor_interval until ctx is done
+func (s *LogService) RunJanitor(ctx context.Context, onError func(error)) {
+	interval := s.config.Logging.JanitorInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.EnforceAll(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compressLogFile gzips a log file next to the original, keeping its
+// modification time, and removes the original
+func compressLogFile(path string) (domain.LogFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return domain.LogFile{}, err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return domain.LogFile{}, err
+	}
+	defer src.Close()
+
+	target := strings.TrimSuffix(path, logFileExt) + compressedLogFileExt
+	dst, err := os.Create(target)
+	if err != nil {
+		return domain.LogFile{}, err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return domain.LogFile{}, err
+	}
+
+	os.Chtimes(target, info.ModTime(), info.ModTime())
+	if err := os.Remove(path); err != nil {
+		return domain.LogFile{}, err
+	}
+
+	compressed, err := os.Stat(target)
+	if err != nil {
+		return domain.LogFile{}, err
+	}
+	return domain.LogFile{
+		Name:       filepath.Base(target),
+		Size:       compressed.Size(),
+		ModifiedAt: info.ModTime(),
+		Compressed: true,
+	}, nil
+}
+
+// Query returns log records of a log file (the latest by default) matching the query, oldest first
+func (s *LogService) Query(projectID string, query *domain.LogQuery) ([]domain.LogEntry, error) {
+	logs := make([]domain.LogEntry, 0)
+
+	path, err := s.LogFilePath(projectID, query.File)
+	if err == ErrFileNotFound && query.File == "" {
+		return logs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := OpenLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultLogQueryLimit
+	}
+	if limit > MaxLogQueryLimit {
+		limit = MaxLogQueryLimit
+	}
+
+	err = EachLogEntry(file, func(entry domain.LogEntry) error {
+		if !MatchLogEntry(&entry, query) {
+			return nil
+		}
+		logs = append(logs, entry)
+		// Keep only the newest records
+		if len(logs) > limit*2 {
+			logs = append(logs[:0], logs[len(logs)-limit:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(logs) > limit {
+		logs = logs[len(logs)-limit:]
+	}
+	return logs, nil
+}
+
+// WriteLogText renders log records from r as human readable text lines
+func WriteLogText(r io.Reader, w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	err := EachLogEntry(r, func(entry domain.LogEntry) error {
+		_, err := buf.WriteString(FormatLogText(&entry) + "\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// EachLogEntry parses log lines from r, accepting both JSON records and legacy text lines
+func EachLogEntry(r io.Reader, fn func(entry domain.LogEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if err := fn(ParseLogLine(line)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ParseLogLine parses a JSON log record, falling back to the legacy
+// "[2006-01-02 15:04:05] [LEVEL] message" text format
+func ParseLogLine(line string) domain.LogEntry {
+	if strings.HasPrefix(line, "{") {
+		var entry domain.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			if entry.Level == "" {
+				entry.Level = domain.LogLevelInfo
+			}
+			return entry
+		}
+	}
+
+	entry := domain.LogEntry{
+		Timestamp: "",
+		Level:     domain.LogLevelInfo,
+		Message:   line,
+	}
+
+	// Try to parse timestamp: [2006-01-02 15:04:05]
+	if len(line) > 21 && line[0] == '[' {
+		closeBracket := strings.Index(line, "]")
+		if closeBracket > 0 {
+			// Convert "2006-01-02 15:04:05" to ISO format "2006-01-02T15:04:05Z" (Z indicates UTC)
+			ts := line[1:closeBracket]
+			entry.Timestamp = strings.Replace(ts, " ", "T", 1) + "Z"
+			line = strings.TrimPrefix(line[closeBracket+1:], " ")
+
+			// Try to parse level: [LEVEL]
+			if len(line) > 2 && line[0] == '[' {
+				levelEnd := strings.Index(line, "]")
+				if levelEnd > 0 {
+					level := domain.LogLevel(strings.ToLower(line[1:levelEnd]))
+					switch level {
+					case domain.LogLevelDebug, domain.LogLevelInfo, domain.LogLevelWarn, domain.LogLevelError:
+						entry.Level = level
+					}
+					entry.Message = strings.TrimPrefix(line[levelEnd+1:], " ")
+				}
+			}
+		}
+	}
+
+	return entry
+}
+
+// FormatLogText renders a record as "[2006-01-02 15:04:05] [LEVEL] message key=value ..."
+func FormatLogText(entry *domain.LogEntry) string {
+	timestamp := entry.Timestamp
+	if t, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+		timestamp = t.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] [%s] %s", timestamp, strings.ToUpper(string(entry.Level)), entry.Message)
+
+	if len(entry.Fields) > 0 {
+		keys := make([]string, 0, len(entry.Fields))
+		for k := range entry.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := logFieldString(entry.Fields[k])
+			if strings.ContainsAny(value, " \t\"") {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(&sb, " %s=%s", k, value)
+		}
+	}
+	if entry.Source != "" {
+		fmt.Fprintf(&sb, " source=%q", entry.Source)
+	}
+	if entry.RequestID != "" {
+		fmt.Fprintf(&sb, " request_id=%s", entry.RequestID)
+	}
+
+	return sb.String()
+}
+
+// MatchLogEntry reports whether a record matches all query conditions
+func MatchLogEntry(entry *domain.LogEntry, query *domain.LogQuery) bool {
+	if len(query.Levels) > 0 {
+		matched := false
+		for _, level := range query.Levels {
+			if entry.Level == level {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if query.From != nil || query.To != nil {
+		t, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return false
+		}
+		if query.From != nil && t.Before(*query.From) {
+			return false
+		}
+		if query.To != nil && t.After(*query.To) {
+			return false
+		}
+	}
+
+	if query.Source != "" && !strings.HasPrefix(entry.Source, query.Source) {
+		return false
+	}
+
+	if query.RequestID != "" && entry.RequestID != query.RequestID {
+		return false
+	}
+
+	for key, value := range query.Fields {
+		fieldValue, ok := entry.Fields[key]
+		if !ok || logFieldString(fieldValue) != value {
+			return false
+		}
+	}
+
+	if query.Text != "" {
+		text := strings.ToLower(query.Text)
+		if !strings.Contains(strings.ToLower(entry.Message), text) {
+			found := false
+			for _, v := range entry.Fields {
+				if strings.Contains(strings.ToLower(logFieldString(v)), text) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// logFieldString renders a field value for text output and comparisons
+func logFieldString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
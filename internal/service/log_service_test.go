@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestParseLogLine(t *testing.T) {
+	entry := ParseLogLine(`{"timestamp":"2025-01-02T03:04:05.123Z","level":"warn","message":"slow","source":"job:job_1","fields":{"ms":1500}}`)
+	if entry.Level != domain.LogLevelWarn || entry.Message != "slow" || entry.Source != "job:job_1" {
+		t.Errorf("unexpected JSON record: %+v", entry)
+	}
+	if entry.Fields["ms"] != float64(1500) {
+		t.Errorf("unexpected fields: %v", entry.Fields)
+	}
+
+	legacy := ParseLogLine("[2025-01-02 03:04:05] [ERROR] boom")
+	if legacy.Level != domain.LogLevelError || legacy.Message != "boom" || legacy.Timestamp != "2025-01-02T03:04:05Z" {
+		t.Errorf("unexpected legacy record: %+v", legacy)
+	}
+
+	plain := ParseLogLine("something else")
+	if plain.Level != domain.LogLevelInfo || plain.Message != "something else" {
+		t.Errorf("unexpected plain record: %+v", plain)
+	}
+}
+
+func TestMatchLogEntry(t *testing.T) {
+	entry := &domain.LogEntry{
+		Timestamp: "2025-01-02T10:00:00Z",
+		Level:     domain.LogLevelError,
+		Message:   "Payment failed",
+		Source:    "route:POST /pay",
+		RequestID: "req-1",
+		Fields:    map[string]interface{}{"region": "eu", "amount": float64(12.5)},
+	}
+
+	from := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 2, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query domain.LogQuery
+		want  bool
+	}{
+		{"empty", domain.LogQuery{}, true},
+		{"level", domain.LogQuery{Levels: []domain.LogLevel{domain.LogLevelWarn, domain.LogLevelError}}, true},
+		{"other level", domain.LogQuery{Levels: []domain.LogLevel{domain.LogLevelInfo}}, false},
+		{"after from", domain.LogQuery{From: &from}, true},
+		{"after to", domain.LogQuery{To: &to}, false},
+		{"text in message", domain.LogQuery{Text: "payment"}, true},
+		{"text in field", domain.LogQuery{Text: "EU"}, true},
+		{"text missing", domain.LogQuery{Text: "refund"}, false},
+		{"source prefix", domain.LogQuery{Source: "route:"}, true},
+		{"other source", domain.LogQuery{Source: "job:"}, false},
+		{"request id", domain.LogQuery{RequestID: "req-2"}, false},
+		{"field", domain.LogQuery{Fields: map[string]string{"region": "eu", "amount": "12.5"}}, true},
+		{"field mismatch", domain.LogQuery{Fields: map[string]string{"region": "us"}}, false},
+		{"field missing", domain.LogQuery{Fields: map[string]string{"user": "1"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchLogEntry(entry, &tt.query); got != tt.want {
+				t.Errorf("MatchLogEntry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteLogText(t *testing.T) {
+	input := strings.Join([]string{
+		`{"timestamp":"2025-01-02T03:04:05.5Z","level":"info","message":"order paid","request_id":"r1","fields":{"id":7,"note":"two words"}}`,
+		"[2025-01-02 03:04:06] [WARN] legacy line",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := WriteLogText(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("WriteLogText failed: %v", err)
+	}
+
+	want := `[2025-01-02 03:04:05] [INFO] order paid id=7 note="two words" request_id=r1` + "\n" +
+		"[2025-01-02 03:04:06] [WARN] legacy line\n"
+	if out.String() != want {
+		t.Errorf("unexpected text output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestLogServiceQueryLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging.Path = t.TempDir()
+	logService := NewLogService(cfg, newTestStorageService(t, cfg), nil)
+
+	logsPath := filepath.Join(cfg.Logging.Path, "project")
+	if err := os.MkdirAll(logsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for i := 0; i < 50; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "error"
+		}
+		lines = append(lines, `{"timestamp":"2025-01-02T03:04:05Z","level":"`+level+`","message":"m`+string(rune('a'+i%26))+`"}`)
+	}
+	if err := os.WriteFile(filepath.Join(logsPath, "run.log"), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := logService.Query("project", &domain.LogQuery{Levels: []domain.LogLevel{domain.LogLevelError}, Limit: 3})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(logs))
+	}
+	// Newest matching records are kept: indexes 44, 46, 48
+	if logs[2].Message != "m"+string(rune('a'+48%26)) {
+		t.Errorf("expected newest record last, got %+v", logs)
+	}
+
+	empty, err := logService.Query("missing", &domain.LogQuery{})
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected no records for missing project, got %v, %v", empty, err)
+	}
+}
+
+func TestLogServicePolicy(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging.MaxFileSizeMB = 10
+	cfg.Logging.MaxTotalSizeMB = 100
+	cfg.Logging.MaxAge = 24 * time.Hour
+	cfg.Logging.Compress = true
+	logService := NewLogService(cfg, nil, nil)
+
+	defaults := logService.Policy(nil)
+	if defaults.MaxFileSize != 10<<20 || defaults.MaxTotalSize != 100<<20 || defaults.MaxAge != 24*time.Hour || !defaults.Compress {
+		t.Errorf("unexpected default policy: %+v", defaults)
+	}
+
+	five, two := 5, 2
+	project := &domain.Project{LogRetention: &domain.LogRetention{MaxTotalSizeMB: &five, MaxAgeHours: &two}}
+	policy := logService.Policy(project)
+	if policy.MaxFileSize != 10<<20 || policy.MaxTotalSize != 5<<20 || policy.MaxAge != 2*time.Hour {
+		t.Errorf("expected project overrides to apply, got %+v", policy)
+	}
+
+	// 0 disables a limit for the project
+	zero := 0
+	project = &domain.Project{LogRetention: &domain.LogRetention{MaxFileSizeMB: &zero, MaxAgeHours: &zero}}
+	policy = logService.Policy(project)
+	if policy.MaxFileSize != 0 || policy.MaxTotalSize != 100<<20 || policy.MaxAge != 0 {
+		t.Errorf("expected disabled limits, got %+v", policy)
+	}
+}
+
+func writeTestLogFile(t *testing.T, path string, size int, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogServiceEnforce(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging.Path = t.TempDir()
+	logService := NewLogService(cfg, newTestStorageService(t, cfg), nil)
+
+	logsPath := filepath.Join(cfg.Logging.Path, "project")
+	if err := os.MkdirAll(logsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	writeTestLogFile(t, filepath.Join(logsPath, "expired.log"), 100, now.Add(-48*time.Hour))
+	writeTestLogFile(t, filepath.Join(logsPath, "old.log.gz"), 400, now.Add(-3*time.Hour))
+	writeTestLogFile(t, filepath.Join(logsPath, "rotated.log"), 300, now.Add(-2*time.Hour))
+	writeTestLogFile(t, filepath.Join(logsPath, "current.log"), 600, now.Add(-72*time.Hour))
+
+	// The current file is held open by a runtime and must survive every rule
+	current := filepath.Join(logsPath, "current.log")
+	logService.Acquire(current)
+
+	err := logService.Enforce("project", domain.LogRetentionPolicy{
+		MaxTotalSize: 700,
+		MaxAge:       24 * time.Hour,
+		Compress:     true,
+	})
+	if err != nil {
+		t.Fatalf("Enforce failed: %v", err)
+	}
+
+	files, err := logService.ListFiles("project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]domain.LogFile)
+	for _, f := range files {
+		names[f.Name] = f
+	}
+
+	if _, ok := names["expired.log"]; ok {
+		t.Error("expected expired file to be removed")
+	}
+	if _, ok := names["old.log.gz"]; ok {
+		t.Error("expected oldest file to be removed to fit the total size")
+	}
+	if f, ok := names["current.log"]; !ok || !f.Active || f.Compressed {
+		t.Errorf("expected active file to be kept as is, got %+v", names)
+	}
+	if f, ok := names["rotated.log.gz"]; !ok || !f.Compressed {
+		t.Errorf("expected rotated file to be compressed, got %+v", names)
+	}
+
+	// Once released, the file is compressed on the next run
+	logService.Release(current)
+	if err := logService.Enforce("project", domain.LogRetentionPolicy{Compress: true}); err != nil {
+		t.Fatalf("Enforce failed: %v", err)
+	}
+	if _, err := logService.LogFilePath("project", "current.log.gz"); err != nil {
+		t.Errorf("expected released file to be compressed: %v", err)
+	}
+}
+
+func TestLogServiceReadsCompressedFiles(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging.Path = t.TempDir()
+	logService := NewLogService(cfg, newTestStorageService(t, cfg), nil)
+
+	logsPath := filepath.Join(cfg.Logging.Path, "project")
+	if err := os.MkdirAll(logsPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Create(filepath.Join(logsPath, "run.20250102-030405.000.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	io.WriteString(gz, `{"timestamp":"2025-01-02T03:04:05Z","level":"info","message":"archived"}`+"\n")
+	gz.Close()
+	file.Close()
+
+	logs, err := logService.Query("project", &domain.LogQuery{File: "run.20250102-030405.000.log.gz"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Message != "archived" {
+		t.Errorf("unexpected records: %+v", logs)
+	}
+
+	// The latest file is used when no file is given, compressed or not
+	if logs, err := logService.Query("project", &domain.LogQuery{}); err != nil || len(logs) != 1 {
+		t.Errorf("expected latest compressed file to be read, got %v, %v", logs, err)
+	}
+
+	if _, err := logService.LogFilePath("project", "../other/run.log"); err != ErrInvalidPath {
+		t.Errorf("expected ErrInvalidPath, got %v", err)
+	}
+	if _, err := logService.LogFilePath("project", "missing.log"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
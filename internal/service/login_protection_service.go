@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+const (
+	// loginEventLimit caps the login events returned at once
+	loginEventLimit = 500
+	// loginEventPruneInterval is how often old login events are deleted
+	loginEventPruneInterval = time.Hour
+)
+
+// LoginProtectionService protects the login against brute force. Failed
+// logins are counted per account and per client IP: after a few, each
+// attempt has to wait longer, then the account or IP is locked for a while.
+// Accounts locked out too often in a row are blocked until an admin
+// unblocks them.
+type LoginProtectionService struct {
+	repo     *repository.LoginAttemptRepository
+	userRepo *repository.UserRepository
+	config   *config.Config
+	logger   *slog.Logger
+
+	pruneMu   sync.Mutex
+	lastPrune time.Time
+
+	keysMu sync.Mutex
+	keys   map[string]*loginKeyLock
+}
+
+// loginKeyLock serialises the logins of one throttle key, refs counts the
+// logins holding or waiting for it
+type loginKeyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewLoginProtectionService(
+	repo *repository.LoginAttemptRepository,
+	userRepo *repository.UserRepository,
+	config *config.Config,
+	logger *slog.Logger,
+) *LoginProtectionService {
+	return &LoginProtectionService{
+		repo:     repo,
+		userRepo: userRepo,
+		config:   config,
+		logger:   logger,
+		keys:     make(map[string]*loginKeyLock),
+	}
+}
+
+// Acquire serialises the logins of an account and of a client IP until the
+// returned func is called. Held from Check to Failed, it keeps concurrent
+// attempts from all passing Check before their failures are counted.
+func (s *LoginProtectionService) Acquire(email, ip string) (release func()) {
+	// Always account first, then IP, so two logins can't wait on each other
+	account := s.lockKey(accountThrottleKey(email))
+	address := s.lockKey(ipThrottleKey(ip))
+	return func() {
+		s.unlockKey(ipThrottleKey(ip), address)
+		s.unlockKey(accountThrottleKey(email), account)
+	}
+}
+
+func (s *LoginProtectionService) lockKey(key string) *loginKeyLock {
+	s.keysMu.Lock()
+	lock, ok := s.keys[key]
+	if !ok {
+		lock = &loginKeyLock{}
+		s.keys[key] = lock
+	}
+	lock.refs++
+	s.keysMu.Unlock()
+
+	lock.mu.Lock()
+	return lock
+}
+
+func (s *LoginProtectionService) unlockKey(key string, lock *loginKeyLock) {
+	lock.mu.Unlock()
+
+	s.keysMu.Lock()
+	if lock.refs--; lock.refs == 0 {
+		delete(s.keys, key)
+	}
+	s.keysMu.Unlock()
+}
+
+// Check returns a *domain.LoginThrottleError while the account or the IP of a
+// login has to wait. It is called before the password is checked, so locked
+// accounts cannot be probed.
+func (s *LoginProtectionService) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	var longest *domain.LoginThrottleError
+	for _, key := range []string{accountThrottleKey(email), ipThrottleKey(ip)} {
+		throttle, err := s.repo.FindThrottle(ctx, key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil {
+			continue
+		}
+		if wait := s.wait(throttle, now); wait != nil && (longest == nil || wait.RetryAfter > longest.RetryAfter) {
+			longest = wait
+		}
+	}
+	if longest != nil {
+		return longest
+	}
+	return nil
+}
+
+// wait returns how long a key has to wait before its next attempt, nil if it
+// may try now
+func (s *LoginProtectionService) wait(throttle *domain.LoginThrottle, now time.Time) *domain.LoginThrottleError {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return &domain.LoginThrottleError{RetryAfter: throttle.LockedUntil.Sub(now), Locked: true}
+	}
+	if delay := loginDelay(s.config.Login, throttle.Failures); delay > 0 {
+		if next := throttle.LastFailure.Add(delay); now.Before(next) {
+			return &domain.LoginThrottleError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// loginDelay is the wait after a number of failures: one second after
+// delay_after failures, doubling with each further failure up to max_delay
+func loginDelay(cfg config.LoginConfig, failures int) time.Duration {
+	if cfg.DelayAfter <= 0 || failures < cfg.DelayAfter {
+		return 0
+	}
+	delay := time.Second << min(failures-cfg.DelayAfter, 20)
+	if cfg.MaxDelay > 0 && delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	return delay
+}
+
+// Failed records a failed login and counts it against the account and the
+// IP, locking them once they reach their limits. user is nil for unknown
+// emails.
+func (s *LoginProtectionService) Failed(ctx context.Context, email string, user *domain.User, client domain.SessionClient, reason string) error {
+	cfg := s.config.Login
+	now := time.Now()
+	s.prune(ctx, now)
+
+	if err := s.record(ctx, email, user, client, reason, now); err != nil {
+		return err
+	}
+	if reason == domain.LoginFailedBlocked {
+		return nil
+	}
+
+	account, err := s.repo.AddFailure(ctx, accountThrottleKey(email), now, now.Add(-cfg.Window))
+	if err != nil {
+		return err
+	}
+	if cfg.LockoutAfter > 0 && account.Failures >= cfg.LockoutAfter {
+		if err := s.lockAccount(ctx, email, user, client, now); err != nil {
+			return err
+		}
+	}
+
+	ip, err := s.repo.AddFailure(ctx, ipThrottleKey(client.IP), now, now.Add(-cfg.Window))
+	if err != nil {
+		return err
+	}
+	if cfg.IPLockoutAfter > 0 && ip.Failures >= cfg.IPLockoutAfter {
+		if _, err := s.repo.Lock(ctx, ipThrottleKey(client.IP), now.Add(cfg.LockoutDuration)); err != nil {
+			return err
+		}
+		s.logger.Warn("IP locked out after failed logins", "ip", client.IP, "failures", ip.Failures)
+	}
+	return nil
+}
+
+// lockAccount locks an account out, and blocks it when it was locked out too
+// often in a row. Root users are never blocked, so the panel stays reachable.
+func (s *LoginProtectionService) lockAccount(ctx context.Context, email string, user *domain.User, client domain.SessionClient, now time.Time) error {
+	cfg := s.config.Login
+	lockouts, err := s.repo.Lock(ctx, accountThrottleKey(email), now.Add(cfg.LockoutDuration))
+	if err != nil {
+		return err
+	}
+	s.logger.Warn("Account locked out after failed logins", "email", email, "ip", client.IP, "lockouts", lockouts)
+	if err := s.record(ctx, email, user, client, domain.LoginLockedOut, now); err != nil {
+		return err
+	}
+
+	if cfg.BlockAfter <= 0 || lockouts < cfg.BlockAfter || user == nil || user.IsRoot || user.IsBlocked {
+		return nil
+	}
+	user.IsBlocked = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.logger.Warn("Account blocked after repeated lockouts", "email", email, "lockouts", lockouts)
+	return s.record(ctx, email, user, client, domain.LoginAutoBlocked, now)
+}
+
+// Succeeded forgets the failures and lockouts of an account after a
+// complete login. Failures of the IP still count, so one valid account does
+// not let a client guess others.
+func (s *LoginProtectionService) Succeeded(ctx context.Context, email string) error {
+	return s.repo.DeleteThrottle(ctx, accountThrottleKey(email))
+}
+
+// Unlock lifts the lockout of an account and forgets its failures
+func (s *LoginProtectionService) Unlock(ctx context.Context, email string) error {
+	return s.repo.DeleteThrottle(ctx, accountThrottleKey(email))
+}
+
+// UnlockIP lifts the lockout of a client IP and forgets its failures
+func (s *LoginProtectionService) UnlockIP(ctx context.Context, ip string) error {
+	return s.repo.DeleteThrottle(ctx, ipThrottleKey(ip))
+}
+
+// Events returns the newest failed login events matching a filter
+func (s *LoginProtectionService) Events(ctx context.Context, filter domain.LoginEventFilter) ([]*domain.LoginEvent, error) {
+	filter.Email = normalizeLoginEmail(filter.Email)
+	if filter.Limit <= 0 || filter.Limit > loginEventLimit {
+		filter.Limit = loginEventLimit
+	}
+	return s.repo.FindEvents(ctx, filter)
+}
+
+func (s *LoginProtectionService) record(ctx context.Context, email string, user *domain.User, client domain.SessionClient, reason string, now time.Time) error {
+	event := &domain.LoginEvent{
+		Email:     normalizeLoginEmail(email),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+	return s.repo.InsertEvent(ctx, event)
+}
+
+// prune deletes login events past their retention, at most once an interval
+func (s *LoginProtectionService) prune(ctx context.Context, now time.Time) {
+	retention := s.config.Login.EventRetention
+	if retention <= 0 {
+		return
+	}
+
+	s.pruneMu.Lock()
+	if now.Sub(s.lastPrune) < loginEventPruneInterval {
+		s.pruneMu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.pruneMu.Unlock()
+
+	if err := s.repo.DeleteEventsBefore(ctx, now.Add(-retention)); err != nil {
+		s.logger.Warn("Failed to delete old login events", "error", err)
+	}
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + normalizeLoginEmail(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
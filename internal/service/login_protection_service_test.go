@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestLoginDelay(t *testing.T) {
+	cfg := config.LoginConfig{DelayAfter: 3, MaxDelay: 30 * time.Second}
+	cases := map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		6:  8 * time.Second,
+		8:  30 * time.Second,
+		90: 30 * time.Second,
+	}
+	for failures, want := range cases {
+		if got := loginDelay(cfg, failures); got != want {
+			t.Errorf("%d failures: %s, want %s", failures, got, want)
+		}
+	}
+
+	if got := loginDelay(config.LoginConfig{}, 100); got != 0 {
+		t.Errorf("delay off: %s", got)
+	}
+}
+
+func TestLoginWait(t *testing.T) {
+	s := NewLoginProtectionService(nil, nil, &config.Config{Login: config.LoginConfig{DelayAfter: 3, MaxDelay: time.Minute}}, nil)
+	now := time.Now()
+
+	if wait := s.wait(&domain.LoginThrottle{Failures: 2, LastFailure: now}, now); wait != nil {
+		t.Errorf("below delay_after: %v", wait)
+	}
+	if wait := s.wait(&domain.LoginThrottle{Failures: 5, LastFailure: now.Add(-time.Second)}, now); wait == nil || wait.Locked || wait.RetryAfter != 3*time.Second {
+		t.Errorf("delayed: %+v", wait)
+	}
+	if wait := s.wait(&domain.LoginThrottle{Failures: 5, LastFailure: now.Add(-5 * time.Second)}, now); wait != nil {
+		t.Errorf("delay passed: %v", wait)
+	}
+
+	until := now.Add(10 * time.Minute)
+	wait := s.wait(&domain.LoginThrottle{LockedUntil: &until}, now)
+	if wait == nil || !wait.Locked || wait.RetryAfter != 10*time.Minute {
+		t.Fatalf("locked: %+v", wait)
+	}
+	if !errors.Is(wait, domain.ErrLoginThrottled) || wait.Error() != "too many failed logins, locked for 10m0s" {
+		t.Errorf("error: %v", wait)
+	}
+
+	expired := now.Add(-time.Second)
+	if wait := s.wait(&domain.LoginThrottle{LockedUntil: &expired}, now); wait != nil {
+		t.Errorf("lock expired: %v", wait)
+	}
+}
+
+func TestLoginAcquire(t *testing.T) {
+	s := NewLoginProtectionService(nil, nil, &config.Config{}, nil)
+
+	release := s.Acquire("Dev@example.com", "10.0.0.1")
+	acquired := make(chan struct{})
+	released := make(chan struct{})
+	go func() {
+		// Same account with other casing and another IP
+		release := s.Acquire("dev@example.com ", "10.0.0.2")
+		close(acquired)
+		release()
+		close(released)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second login of the account was not held back")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Other accounts from other IPs are not held back
+	s.Acquire("ops@example.com", "10.0.0.3")()
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second login still waits after the first was released")
+	}
+	<-released
+
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	if len(s.keys) != 0 {
+		t.Errorf("locks of finished logins kept: %v", s.keys)
+	}
+}
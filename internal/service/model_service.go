@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,6 +14,7 @@ import (
 
 type ModelService struct {
 	modelRepo *repository.ModelRepository
+	quota     QuotaReserver
 }
 
 func NewModelService(modelRepo *repository.ModelRepository) *ModelService {
@@ -21,6 +23,11 @@ func NewModelService(modelRepo *repository.ModelRepository) *ModelService {
 	}
 }
 
+// SetQuota enables data quota checks for inserted documents
+func (s *ModelService) SetQuota(quota QuotaReserver) {
+	s.quota = quota
+}
+
 func (s *ModelService) Create(ctx context.Context, projectID primitive.ObjectID, req *domain.CreateModelRequest) (*domain.Model, error) {
 	// Validate model schema
 	schemaValidator := NewModelSchemaValidator()
@@ -136,6 +143,63 @@ func (s *ModelService) Delete(ctx context.Context, id primitive.ObjectID) error
 	return s.modelRepo.Delete(ctx, id)
 }
 
+// EachData iterates over all raw documents of a model (used for exports)
+func (s *ModelService) EachData(ctx context.Context, model *domain.Model, fn func(doc bson.Raw) error) error {
+	return s.modelRepo.EachData(ctx, model, fn)
+}
+
+// ImportData inserts exported documents into a model (used for imports).
+// System fields are kept, the data fields are validated against the schema.
+func (s *ModelService) ImportData(ctx context.Context, model *domain.Model, docs []bson.D) error {
+	validator := NewDataValidator(model)
+	raw := make([]interface{}, len(docs))
+	for n, doc := range docs {
+		id := fmt.Sprint(n + 1)
+		data := make(map[string]interface{}, len(doc))
+		for i := range doc {
+			switch doc[i].Key {
+			case "_model_id":
+				doc[i].Value = model.ID
+			case "_id":
+				if oid, ok := doc[i].Value.(primitive.ObjectID); ok {
+					id = oid.Hex()
+				}
+			case "_created_at", "_updated_at":
+			default:
+				data[doc[i].Key] = importedValue(doc[i].Value)
+			}
+		}
+		if err := validator.Validate(data); err != nil {
+			return fmt.Errorf("document %s: %w", id, err)
+		}
+		raw[n] = doc
+	}
+	return s.modelRepo.InsertRawData(ctx, model, raw)
+}
+
+// importedValue converts a value decoded from extended JSON to the types the
+// validator expects of request data
+func importedValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = importedValue(e.Value)
+		}
+		return m
+	case bson.A:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = importedValue(item)
+		}
+		return items
+	case primitive.DateTime:
+		return v.Time()
+	default:
+		return value
+	}
+}
+
 // Data methods
 func (s *ModelService) CreateData(ctx context.Context, modelID primitive.ObjectID, data map[string]interface{}) (map[string]interface{}, error) {
 	model, err := s.modelRepo.FindByID(ctx, modelID)
@@ -153,6 +217,10 @@ func (s *ModelService) CreateData(ctx context.Context, modelID primitive.ObjectI
 		return nil, err
 	}
 
+	if err := s.reserveDocument(model.ProjectID, validatedData); err != nil {
+		return nil, err
+	}
+
 	result, err := s.modelRepo.CreateData(ctx, model, validatedData)
 	if err != nil {
 		return nil, err
@@ -170,6 +238,24 @@ func (s *ModelService) CreateData(ctx context.Context, modelID primitive.ObjectI
 	return doc, nil
 }
 
+// reserveDocument checks the document and data quotas of a project before an insert
+func (s *ModelService) reserveDocument(projectID primitive.ObjectID, data map[string]interface{}) error {
+	if s.quota == nil {
+		return nil
+	}
+
+	if err := s.quota.Reserve(projectID.Hex(), domain.QuotaDocuments, 1); err != nil {
+		return err
+	}
+
+	// The encoded document approximates the bytes the insert adds
+	var size int64
+	if raw, err := bson.Marshal(data); err == nil {
+		size = int64(len(raw))
+	}
+	return s.quota.Reserve(projectID.Hex(), domain.QuotaData, size)
+}
+
 func (s *ModelService) GetData(ctx context.Context, modelID primitive.ObjectID, query *domain.DataQuery) ([]bson.M, int64, error) {
 	model, err := s.modelRepo.FindByID(ctx, modelID)
 	if err != nil {
@@ -329,6 +415,11 @@ func (s *ModelService) resolveDefaultValue(field domain.ModelField) interface{}
 	return field.DefaultValue
 }
 
+// CountProjectDocuments returns the number of documents in all data collections of a project
+func (s *ModelService) CountProjectDocuments(ctx context.Context, projectID primitive.ObjectID) (int64, error) {
+	return s.modelRepo.CountProjectDocuments(ctx, projectID)
+}
+
 // GetProjectDataSize returns total size of all data collections for a project in bytes
 func (s *ModelService) GetProjectDataSize(ctx context.Context, projectID primitive.ObjectID) (int64, error) {
 	return s.modelRepo.GetProjectDataSize(ctx, projectID)
@@ -250,6 +250,17 @@ func (s *PipelineService) GetRelease(ctx context.Context, projectID primitive.Ob
 	return s.pipelineRepo.FindReleaseByVersion(ctx, projectID, version)
 }
 
+func (s *PipelineService) GetReleaseByID(ctx context.Context, projectID primitive.ObjectID, releaseID primitive.ObjectID) (*domain.Release, error) {
+	release, err := s.pipelineRepo.FindReleaseByID(ctx, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	if release.ProjectID != projectID {
+		return nil, repository.ErrReleaseNotFound
+	}
+	return release, nil
+}
+
 func (s *PipelineService) GetActiveRelease(ctx context.Context, projectID primitive.ObjectID) (*domain.Release, error) {
 	return s.pipelineRepo.FindActiveRelease(ctx, projectID)
 }
@@ -296,6 +307,11 @@ func (s *PipelineService) DeleteReleaseByID(ctx context.Context, projectID primi
 	return s.pipelineRepo.DeleteRelease(ctx, releaseID)
 }
 
+// DeleteByProject deletes all branches and releases of a project, the active one included
+func (s *PipelineService) DeleteByProject(ctx context.Context, projectID primitive.ObjectID) error {
+	return s.pipelineRepo.DeleteByProject(ctx, projectID)
+}
+
 func (s *PipelineService) getNextVersion(ctx context.Context, projectID primitive.ObjectID, bumpType string) (string, error) {
 	latest, err := s.pipelineRepo.FindLatestRelease(ctx, projectID)
 	if err != nil {
@@ -417,6 +433,58 @@ func (s *PipelineService) RenameFile(ctx context.Context, projectID, branchID pr
 	return s.GetBranchByID(ctx, projectID, branchID)
 }
 
+// ImportBranch replaces files of a branch, creating the branch if it doesn't exist
+func (s *PipelineService) ImportBranch(ctx context.Context, projectID primitive.ObjectID, name string, files []domain.CodeFile) (*domain.Branch, error) {
+	if err := s.validateFiles(files); err != nil {
+		return nil, err
+	}
+
+	branch, err := s.pipelineRepo.FindBranchByName(ctx, projectID, name)
+	if err == repository.ErrBranchNotFound {
+		branch = &domain.Branch{
+			ProjectID: projectID,
+			Name:      name,
+			Files:     files,
+		}
+		if err := s.pipelineRepo.CreateBranch(ctx, branch); err != nil {
+			return nil, err
+		}
+		return branch, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	branch.Files = files
+	if err := s.pipelineRepo.UpdateBranch(ctx, branch); err != nil {
+		return nil, err
+	}
+
+	return branch, nil
+}
+
+// ImportRelease creates a release with an explicit version (used for imports)
+func (s *PipelineService) ImportRelease(ctx context.Context, projectID primitive.ObjectID, version string, files []domain.CodeFile, comment string, tag domain.ReleaseTag) (*domain.Release, error) {
+	if err := s.validateFiles(files); err != nil {
+		return nil, err
+	}
+
+	release := &domain.Release{
+		ProjectID: projectID,
+		Version:   version,
+		Files:     files,
+		Comment:   comment,
+		Tag:       tag,
+		IsActive:  false,
+	}
+
+	if err := s.pipelineRepo.CreateRelease(ctx, release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
 // EnsureDevelopBranch creates develop branch if it doesn't exist
 func (s *PipelineService) EnsureDevelopBranch(ctx context.Context, projectID primitive.ObjectID) (*domain.Branch, error) {
 	branch, err := s.pipelineRepo.FindBranchByName(ctx, projectID, "develop")
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+var ErrAuthInvalidEmail = errors.New("invalid email")
+
+// projectAuthMaxUsers caps the users listed at once
+const projectAuthMaxUsers = 1000
+
+// ProjectAuthService keeps the end users of project services for $auth.
+// Passwords arrive hashed; tokens are signed by the runtime module.
+type ProjectAuthService struct {
+	repo *repository.ProjectAuthRepository
+}
+
+func NewProjectAuthService(repo *repository.ProjectAuthRepository) *ProjectAuthService {
+	return &ProjectAuthService{repo: repo}
+}
+
+// Secret returns the token signing secret of a project, generating it on
+// first use
+func (s *ProjectAuthService) Secret(ctx context.Context, projectID primitive.ObjectID) (string, error) {
+	candidate, err := randomAuthToken()
+	if err != nil {
+		return "", err
+	}
+	return s.repo.Secret(ctx, projectID, candidate)
+}
+
+func (s *ProjectAuthService) CreateUser(ctx context.Context, projectID primitive.ObjectID, email, passwordHash string, profile map[string]interface{}) (*domain.AuthUser, error) {
+	email, err := normalizeAuthEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = make(map[string]interface{})
+	}
+
+	user := &domain.AuthUser{
+		ProjectID:    projectID,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Profile:      profile,
+	}
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *ProjectAuthService) GetUser(ctx context.Context, projectID, id primitive.ObjectID) (*domain.AuthUser, error) {
+	return s.repo.FindUser(ctx, projectID, id)
+}
+
+func (s *ProjectAuthService) GetUserByEmail(ctx context.Context, projectID primitive.ObjectID, email string) (*domain.AuthUser, error) {
+	email, err := normalizeAuthEmail(email)
+	if err != nil {
+		return nil, repository.ErrAuthUserNotFound
+	}
+	return s.repo.FindUserByEmail(ctx, projectID, email)
+}
+
+// ListUsers returns a page of the users of a project, newest first, and their total
+func (s *ProjectAuthService) ListUsers(ctx context.Context, projectID primitive.ObjectID, page, limit int) ([]*domain.AuthUser, int64, error) {
+	page = max(page, 1)
+	if limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, projectAuthMaxUsers)
+	return s.repo.ListUsers(ctx, projectID, int64((page-1)*limit), int64(limit))
+}
+
+// UpdateUser changes the email and profile of a user; nil leaves them as they are
+func (s *ProjectAuthService) UpdateUser(ctx context.Context, projectID, id primitive.ObjectID, email *string, profile map[string]interface{}) (*domain.AuthUser, error) {
+	set := bson.M{}
+	if email != nil {
+		normalized, err := normalizeAuthEmail(*email)
+		if err != nil {
+			return nil, err
+		}
+		set["email"] = normalized
+	}
+	if profile != nil {
+		set["profile"] = profile
+	}
+	return s.repo.UpdateUser(ctx, projectID, id, set, nil)
+}
+
+// SetPasswordHash replaces the password of a user. Tokens issued before and
+// outstanding reset tokens stop working.
+func (s *ProjectAuthService) SetPasswordHash(ctx context.Context, projectID, id primitive.ObjectID, passwordHash string) (*domain.AuthUser, error) {
+	user, err := s.repo.UpdateUser(ctx, projectID, id, bson.M{"password_hash": passwordHash}, bson.M{"token_version": 1})
+	if err != nil {
+		return nil, err
+	}
+	return user, s.repo.DeleteResetTokens(ctx, id)
+}
+
+// RevokeTokens invalidates every token issued to a user so far
+func (s *ProjectAuthService) RevokeTokens(ctx context.Context, projectID, id primitive.ObjectID) (*domain.AuthUser, error) {
+	return s.repo.UpdateUser(ctx, projectID, id, bson.M{}, bson.M{"token_version": 1})
+}
+
+func (s *ProjectAuthService) DeleteUser(ctx context.Context, projectID, id primitive.ObjectID) error {
+	return s.repo.DeleteUser(ctx, projectID, id)
+}
+
+func (s *ProjectAuthService) TouchLogin(ctx context.Context, user *domain.AuthUser) error {
+	now := time.Now()
+	user.LastLoginAt = &now
+	return s.repo.TouchLogin(ctx, user.ID, now)
+}
+
+// CreateResetToken issues a single-use password reset token for a user,
+// valid for ttl. The returned token is the only copy of it.
+func (s *ProjectAuthService) CreateResetToken(ctx context.Context, user *domain.AuthUser, ttl time.Duration) (string, error) {
+	token, err := randomAuthToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.CreateResetToken(ctx, &domain.AuthResetToken{
+		Hash:      hashAuthToken(token),
+		ProjectID: user.ProjectID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeResetToken checks a reset token and spends it, returning its user
+func (s *ProjectAuthService) ConsumeResetToken(ctx context.Context, projectID primitive.ObjectID, token string) (*domain.AuthUser, error) {
+	reset, err := s.repo.ConsumeResetToken(ctx, projectID, hashAuthToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindUser(ctx, projectID, reset.UserID)
+}
+
+func normalizeAuthEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return "", ErrAuthInvalidEmail
+	}
+	return email, nil
+}
+
+func randomAuthToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeAuthEmail(t *testing.T) {
+	for input, want := range map[string]string{
+		"ann@example.com":      "ann@example.com",
+		"  Ann@Example.COM \n": "ann@example.com",
+	} {
+		got, err := normalizeAuthEmail(input)
+		if err != nil || got != want {
+			t.Errorf("%q: %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "ann", "Ann <ann@example.com>", "ann@example.com, bob@example.com"} {
+		if _, err := normalizeAuthEmail(input); !errors.Is(err, ErrAuthInvalidEmail) {
+			t.Errorf("%q: %v, want ErrAuthInvalidEmail", input, err)
+		}
+	}
+}
+
+func TestHashAuthToken(t *testing.T) {
+	token, err := randomAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 64 || hashAuthToken(token) == token || hashAuthToken(token) != hashAuthToken(token) {
+		t.Errorf("token %q hashes to %q", token, hashAuthToken(token))
+	}
+}
@@ -2,28 +2,37 @@ package service
 
 import (
 	"context"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
-	"github.com/levskiy0/m3m/internal/config"
 	"github.com/levskiy0/m3m/internal/domain"
 	"github.com/levskiy0/m3m/internal/repository"
 )
 
+var (
+	// ErrInvalidTimezone is returned for project timezones that are not IANA names
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	// ErrInvalidProjectRole is returned for unknown project member roles
+	ErrInvalidProjectRole = errors.New("invalid project role")
+)
+
 type ProjectService struct {
-	projectRepo *repository.ProjectRepository
-	widgetRepo  *repository.WidgetRepository
-	config      *config.Config
+	projectRepo    *repository.ProjectRepository
+	widgetRepo     *repository.WidgetRepository
+	storageService *StorageService
+	locations      sync.Map // project id -> *time.Location
 }
 
-func NewProjectService(projectRepo *repository.ProjectRepository, widgetRepo *repository.WidgetRepository, config *config.Config) *ProjectService {
+func NewProjectService(projectRepo *repository.ProjectRepository, widgetRepo *repository.WidgetRepository, storageService *StorageService) *ProjectService {
 	return &ProjectService{
-		projectRepo: projectRepo,
-		widgetRepo:  widgetRepo,
-		config:      config,
+		projectRepo:    projectRepo,
+		widgetRepo:     widgetRepo,
+		storageService: storageService,
 	}
 }
 
@@ -42,8 +51,7 @@ func (s *ProjectService) Create(ctx context.Context, req *domain.CreateProjectRe
 		return nil, err
 	}
 
-	storagePath := filepath.Join(s.config.Storage.Path, project.ID.Hex(), "storage")
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
+	if err := s.storageService.MkDir(project.ID.Hex(), ""); err != nil {
 		s.projectRepo.Delete(ctx, project.ID)
 		return nil, err
 	}
@@ -104,6 +112,118 @@ func (s *ProjectService) Update(ctx context.Context, id primitive.ObjectID, req
 	if req.AutoStart != nil {
 		project.AutoStart = *req.AutoStart
 	}
+	if req.LogRetention != nil {
+		project.LogRetention = req.LogRetention
+	}
+	if req.Timezone != nil {
+		if _, err := domain.LoadTimezone(*req.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTimezone, err)
+		}
+		project.Timezone = *req.Timezone
+	}
+
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		return nil, err
+	}
+	s.locations.Store(project.ID, project.Location())
+
+	return project, nil
+}
+
+// Location returns the timezone of a project, which goal buckets, date
+// formatting and schedules follow. Unknown projects are UTC.
+func (s *ProjectService) Location(ctx context.Context, id primitive.ObjectID) *time.Location {
+	if loc, ok := s.locations.Load(id); ok {
+		return loc.(*time.Location)
+	}
+
+	project, err := s.projectRepo.FindByID(ctx, id)
+	if err != nil {
+		return time.UTC
+	}
+	loc := project.Location()
+	s.locations.Store(id, loc)
+	return loc
+}
+
+// SetStorageVisibility marks a storage path (and everything under it) as private or public
+func (s *ProjectService) SetStorageVisibility(ctx context.Context, id primitive.ObjectID, storagePath string, private bool) (*domain.Project, error) {
+	project, err := s.projectRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	storagePath = domain.CleanStoragePath(storagePath)
+	paths := make([]string, 0, len(project.PrivatePaths)+1)
+	for _, p := range project.PrivatePaths {
+		if domain.CleanStoragePath(p) != storagePath {
+			paths = append(paths, p)
+		}
+	}
+	if private {
+		paths = append(paths, storagePath)
+	}
+	project.PrivatePaths = paths
+
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// SetStorageVersioning enables or disables versioning for a storage directory
+func (s *ProjectService) SetStorageVersioning(ctx context.Context, id primitive.ObjectID, req domain.SetStorageVersioningRequest) (*domain.Project, error) {
+	project, err := s.projectRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	storagePath := domain.CleanStoragePath(req.Path)
+	paths := make([]domain.StorageVersioning, 0, len(project.VersionedPaths)+1)
+	for _, p := range project.VersionedPaths {
+		if domain.CleanStoragePath(p.Path) != storagePath {
+			paths = append(paths, p)
+		}
+	}
+	if req.Enabled {
+		paths = append(paths, domain.StorageVersioning{
+			Path:        storagePath,
+			MaxVersions: req.MaxVersions,
+			MaxAgeDays:  req.MaxAgeDays,
+		})
+	}
+	project.VersionedPaths = paths
+
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// StorageVersionedPaths returns the versioned storage directories of a
+// project. StorageService uses it to decide whether a change keeps the prior content.
+func (s *ProjectService) StorageVersionedPaths(projectID string) []domain.StorageVersioning {
+	id, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		return nil
+	}
+	project, err := s.projectRepo.FindByID(context.Background(), id)
+	if err != nil {
+		return nil
+	}
+	return project.VersionedPaths
+}
+
+// SetQuota replaces the quota override of a project; zero fields inherit the defaults
+func (s *ProjectService) SetQuota(ctx context.Context, id primitive.ObjectID, quota domain.ProjectQuota) (*domain.Project, error) {
+	project, err := s.projectRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Quota = &quota
 
 	if err := s.projectRepo.Update(ctx, project); err != nil {
 		return nil, err
@@ -122,8 +242,7 @@ func (s *ProjectService) Delete(ctx context.Context, id primitive.ObjectID) erro
 		return err
 	}
 
-	storagePath := filepath.Join(s.config.Storage.Path, project.ID.Hex())
-	os.RemoveAll(storagePath)
+	s.storageService.DeleteProject(project.ID.Hex())
 
 	return nil
 }
@@ -143,35 +262,53 @@ func (s *ProjectService) RegenerateAPIKey(ctx context.Context, id primitive.Obje
 	return project, nil
 }
 
-func (s *ProjectService) AddMember(ctx context.Context, projectID, userID primitive.ObjectID) error {
-	return s.projectRepo.AddMember(ctx, projectID, userID)
+// AddMember adds a user to the project with a role, DefaultProjectRole when empty
+func (s *ProjectService) AddMember(ctx context.Context, projectID, userID primitive.ObjectID, role domain.ProjectRole) error {
+	if role == "" {
+		role = domain.DefaultProjectRole
+	}
+	if !role.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidProjectRole, role)
+	}
+	return s.projectRepo.AddMember(ctx, projectID, userID, role)
+}
+
+// SetMemberRole changes the role of a member
+func (s *ProjectService) SetMemberRole(ctx context.Context, projectID, userID primitive.ObjectID, role domain.ProjectRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidProjectRole, role)
+	}
+	return s.projectRepo.SetMemberRole(ctx, projectID, userID, role)
 }
 
 func (s *ProjectService) RemoveMember(ctx context.Context, projectID, userID primitive.ObjectID) error {
 	return s.projectRepo.RemoveMember(ctx, projectID, userID)
 }
 
-func (s *ProjectService) CanUserAccess(ctx context.Context, userID primitive.ObjectID, projectID primitive.ObjectID, isRoot bool) bool {
+// Role returns the role of a user in a project; root users and the owner are
+// admins. ok is false for users without access.
+func (s *ProjectService) Role(ctx context.Context, userID primitive.ObjectID, projectID primitive.ObjectID, isRoot bool) (domain.ProjectRole, bool) {
 	if isRoot {
-		return true
+		return domain.ProjectRoleAdmin, true
 	}
 
 	project, err := s.projectRepo.FindByID(ctx, projectID)
 	if err != nil {
-		return false
-	}
-
-	if project.OwnerID == userID {
-		return true
+		return "", false
 	}
+	return project.MemberRole(userID)
+}
 
-	for _, member := range project.Members {
-		if member == userID {
-			return true
-		}
-	}
+// HasPermission reports whether the role of a user in a project grants a permission
+func (s *ProjectService) HasPermission(ctx context.Context, userID primitive.ObjectID, projectID primitive.ObjectID, isRoot bool, permission domain.ProjectPermission) bool {
+	role, ok := s.Role(ctx, userID, projectID, isRoot)
+	return ok && role.Allows(permission)
+}
 
-	return false
+// CanUserAccess reports whether a user is the owner or a member of a project
+func (s *ProjectService) CanUserAccess(ctx context.Context, userID primitive.ObjectID, projectID primitive.ObjectID, isRoot bool) bool {
+	_, ok := s.Role(ctx, userID, projectID, isRoot)
+	return ok
 }
 
 func (s *ProjectService) generateAPIKey() string {
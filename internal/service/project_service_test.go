@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestProjectMemberRole(t *testing.T) {
+	owner, viewer, legacy, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	project := &domain.Project{
+		OwnerID:     owner,
+		Members:     []primitive.ObjectID{viewer, legacy},
+		MemberRoles: map[string]domain.ProjectRole{viewer.Hex(): domain.ProjectRoleViewer},
+	}
+
+	cases := []struct {
+		name   string
+		userID primitive.ObjectID
+		role   domain.ProjectRole
+		ok     bool
+	}{
+		{"owner", owner, domain.ProjectRoleAdmin, true},
+		{"member with role", viewer, domain.ProjectRoleViewer, true},
+		{"member without role", legacy, domain.DefaultProjectRole, true},
+		{"not a member", stranger, "", false},
+	}
+	for _, tc := range cases {
+		role, ok := project.MemberRole(tc.userID)
+		if role != tc.role || ok != tc.ok {
+			t.Errorf("%s: got %q %v, want %q %v", tc.name, role, ok, tc.role, tc.ok)
+		}
+	}
+}
+
+func TestProjectRolePermissions(t *testing.T) {
+	for _, permission := range domain.ProjectPermissions {
+		if !domain.ProjectRoleAdmin.Allows(permission) {
+			t.Errorf("admin does not allow %s", permission)
+		}
+	}
+
+	checks := []struct {
+		role       domain.ProjectRole
+		permission domain.ProjectPermission
+		allowed    bool
+	}{
+		{domain.ProjectRoleViewer, domain.PermissionDataRead, true},
+		{domain.ProjectRoleViewer, domain.PermissionDataWrite, false},
+		{domain.ProjectRoleViewer, domain.PermissionEnvRead, false},
+		{domain.ProjectRoleDeveloper, domain.PermissionCodeEdit, true},
+		{domain.ProjectRoleDeveloper, domain.PermissionReleases, false},
+		{domain.ProjectRoleDeveloper, domain.PermissionEnvWrite, false},
+		{domain.ProjectRoleOperator, domain.PermissionReleases, true},
+		{domain.ProjectRoleOperator, domain.PermissionCodeEdit, false},
+		{domain.ProjectRoleOperator, domain.PermissionMembers, false},
+		{domain.ProjectRole("guest"), domain.PermissionView, false},
+	}
+	for _, check := range checks {
+		if got := check.role.Allows(check.permission); got != check.allowed {
+			t.Errorf("%s allows %s = %v, want %v", check.role, check.permission, got, check.allowed)
+		}
+	}
+}
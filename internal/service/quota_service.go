@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+const (
+	// quotaUsageTTL is how long measured usage is reused before it is measured again
+	quotaUsageTTL = 30 * time.Second
+	// quotaWarningInterval limits how often a project is warned about the same resource
+	quotaWarningInterval = time.Hour
+	// quotaInFlightWindow is how long a reservation is added to a new
+	// measurement, since its write may not have landed when usage is measured
+	quotaInFlightWindow = 10 * time.Second
+)
+
+// QuotaResources lists the resources in the order they are reported
+var QuotaResources = []domain.QuotaResource{domain.QuotaStorage, domain.QuotaData, domain.QuotaDocuments, domain.QuotaLogs}
+
+// QuotaReserver checks a write against the project quotas. StorageService and
+// ModelService receive it after construction, since QuotaService depends on them.
+type QuotaReserver interface {
+	Reserve(projectID string, resource domain.QuotaResource, amount int64) error
+	Release(projectID string, resource domain.QuotaResource, amount int64)
+}
+
+// QuotaWarningHandler receives warnings when a project nears or reaches a quota
+type QuotaWarningHandler func(warning domain.QuotaWarning)
+
+// QuotaLimitsFor returns the effective quotas of a project: the configured
+// defaults, overridden by the non-zero fields of the project quota
+func QuotaLimitsFor(cfg config.QuotaConfig, project *domain.Project) domain.QuotaLimits {
+	limits := domain.QuotaLimits{
+		StorageBytes: int64(cfg.StorageMB) << 20,
+		DataBytes:    int64(cfg.DataMB) << 20,
+		Documents:    cfg.Documents,
+		LogBytes:     int64(cfg.LogMB) << 20,
+		WarnPercent:  cfg.WarnPercent,
+	}
+
+	if project != nil && project.Quota != nil {
+		override := project.Quota
+		if override.StorageMB > 0 {
+			limits.StorageBytes = int64(override.StorageMB) << 20
+		}
+		if override.DataMB > 0 {
+			limits.DataBytes = int64(override.DataMB) << 20
+		}
+		if override.Documents > 0 {
+			limits.Documents = override.Documents
+		}
+		if override.LogMB > 0 {
+			limits.LogBytes = int64(override.LogMB) << 20
+		}
+	}
+
+	if limits.WarnPercent <= 0 || limits.WarnPercent > 100 {
+		limits.WarnPercent = 100
+	}
+	return limits
+}
+
+type quotaUsageEntry struct {
+	used       int64
+	measuredAt time.Time
+	reserved   []quotaReservation // Recent reservations, their writes may be in flight
+}
+
+type quotaReservation struct {
+	amount int64
+	at     time.Time
+}
+
+// inFlight returns the reservations of the last quotaInFlightWindow and their total
+func (e *quotaUsageEntry) inFlight(now time.Time) ([]quotaReservation, int64) {
+	if e == nil {
+		return nil, 0
+	}
+	var recent []quotaReservation
+	var total int64
+	for _, r := range e.reserved {
+		if now.Sub(r.at) < quotaInFlightWindow {
+			recent = append(recent, r)
+			total += r.amount
+		}
+	}
+	return recent, total
+}
+
+// QuotaService enforces per-project quotas for storage, data collections,
+// documents and logs, and warns projects that are nearing them
+type QuotaService struct {
+	config         *config.Config
+	projectService *ProjectService
+	storageService *StorageService
+	modelService   *ModelService
+	logService     *LogService
+
+	mu       sync.Mutex
+	usage    map[string]*quotaUsageEntry // "<projectID>/<resource>"
+	reserves map[string]*sync.Mutex      // Serialises reservations per "<projectID>/<resource>"
+	warned   map[string]time.Time        // last warning per "<projectID>/<resource>"
+	handlers []QuotaWarningHandler
+}
+
+func NewQuotaService(
+	config *config.Config,
+	projectService *ProjectService,
+	storageService *StorageService,
+	modelService *ModelService,
+	logService *LogService,
+) *QuotaService {
+	return &QuotaService{
+		config:         config,
+		projectService: projectService,
+		storageService: storageService,
+		modelService:   modelService,
+		logService:     logService,
+		usage:          make(map[string]*quotaUsageEntry),
+		reserves:       make(map[string]*sync.Mutex),
+		warned:         make(map[string]time.Time),
+	}
+}
+
+// OnWarning registers a handler for quota warnings
+func (s *QuotaService) OnWarning(handler QuotaWarningHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// Limits returns the effective quotas of a project. It fails when the project
+// cannot be loaded, since its override may be lower than the defaults.
+func (s *QuotaService) Limits(ctx context.Context, projectID string) (domain.QuotaLimits, error) {
+	if s.projectService == nil {
+		return QuotaLimitsFor(s.config.Quota, nil), nil
+	}
+	id, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		return domain.QuotaLimits{}, fmt.Errorf("invalid project id %q: %w", projectID, err)
+	}
+	project, err := s.projectService.GetByID(ctx, id)
+	if err != nil {
+		return domain.QuotaLimits{}, fmt.Errorf("failed to load quotas of project %s: %w", projectID, err)
+	}
+	return QuotaLimitsFor(s.config.Quota, project), nil
+}
+
+// Reserve checks that amount more of a resource fits into the project quota
+// and counts it as used. It returns a *domain.QuotaError when it does not fit
+// and fails closed when the quotas or usage cannot be determined. Reservations of a project
+// resource are serialised, so concurrent writes cannot overshoot the quota.
+func (s *QuotaService) Reserve(projectID string, resource domain.QuotaResource, amount int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	limits, err := s.Limits(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	limit := limits.Limit(resource)
+	if limit <= 0 {
+		return nil
+	}
+
+	key := quotaKey(projectID, resource)
+	lock := s.reserveLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	used, err := s.Usage(ctx, projectID, resource)
+	if err != nil {
+		return fmt.Errorf("failed to measure %s usage: %w", resource, err)
+	}
+
+	if used+amount > limit {
+		s.warn(projectID, resource, used, limit, limits.WarnPercent, true)
+		return &domain.QuotaError{Resource: resource, Used: used, Requested: amount, Limit: limit}
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if entry, ok := s.usage[key]; ok {
+		entry.used += amount
+		entry.reserved, _ = entry.inFlight(now)
+		entry.reserved = append(entry.reserved, quotaReservation{amount: amount, at: now})
+	}
+	s.mu.Unlock()
+
+	s.warn(projectID, resource, used+amount, limit, limits.WarnPercent, false)
+	return nil
+}
+
+// Release gives back part of a reservation that was not used, e.g. by an
+// aborted write
+func (s *QuotaService) Release(projectID string, resource domain.QuotaResource, amount int64) {
+	if amount <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.usage[quotaKey(projectID, resource)]; ok {
+		entry.used = max(entry.used-amount, 0)
+	}
+}
+
+func (s *QuotaService) reserveLock(key string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.reserves[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.reserves[key] = lock
+	}
+	return lock
+}
+
+// ReportUsage records a measured usage, e.g. the log size after retention
+// ran, and warns the project if it is near its quota
+func (s *QuotaService) ReportUsage(projectID string, resource domain.QuotaResource, used int64) {
+	used = s.record(quotaKey(projectID, resource), used)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	limits, err := s.Limits(ctx, projectID)
+	if err != nil {
+		return
+	}
+	if limit := limits.Limit(resource); limit > 0 {
+		s.warn(projectID, resource, used, limit, limits.WarnPercent, used > limit)
+	}
+}
+
+// Usage returns the usage of a resource, measuring it at most every
+// quotaUsageTTL. Between measurements reservations are added to it.
+func (s *QuotaService) Usage(ctx context.Context, projectID string, resource domain.QuotaResource) (int64, error) {
+	key := quotaKey(projectID, resource)
+
+	s.mu.Lock()
+	if entry, ok := s.usage[key]; ok && time.Since(entry.measuredAt) < quotaUsageTTL {
+		used := entry.used
+		s.mu.Unlock()
+		return used, nil
+	}
+	s.mu.Unlock()
+
+	used, err := s.measure(ctx, projectID, resource)
+	if err != nil {
+		return 0, err
+	}
+	return s.record(key, used), nil
+}
+
+// record stores a measured usage plus the recent reservations the
+// measurement may have missed, and returns that total
+func (s *QuotaService) record(key string, measured int64) int64 {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reserved, inFlight := s.usage[key].inFlight(now)
+	s.usage[key] = &quotaUsageEntry{used: measured + inFlight, measuredAt: now, reserved: reserved}
+	return measured + inFlight
+}
+
+// Status returns the quota override of a project with freshly measured usage
+func (s *QuotaService) Status(ctx context.Context, projectID primitive.ObjectID) (*domain.ProjectQuotaStatus, error) {
+	project, err := s.projectService.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	limits := QuotaLimitsFor(s.config.Quota, project)
+
+	status := &domain.ProjectQuotaStatus{
+		WarnPercent: limits.WarnPercent,
+		Usage:       make([]domain.QuotaUsage, 0, len(QuotaResources)),
+	}
+	if project.Quota != nil {
+		status.Quota = *project.Quota
+	}
+
+	s.Invalidate(projectID.Hex())
+	for _, resource := range QuotaResources {
+		used, err := s.Usage(ctx, projectID.Hex(), resource)
+		if err != nil {
+			return nil, err
+		}
+		usage := domain.QuotaUsage{Resource: resource, Used: used, Limit: limits.Limit(resource)}
+		if usage.Limit > 0 {
+			usage.Percent = float64(used) * 100 / float64(usage.Limit)
+			usage.Warning = usage.Percent >= float64(limits.WarnPercent)
+		}
+		status.Usage = append(status.Usage, usage)
+	}
+
+	return status, nil
+}
+
+// Invalidate makes the next usage check of a project measure again, e.g.
+// after its quota changed. Recent reservations are kept.
+func (s *QuotaService) Invalidate(projectID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, resource := range QuotaResources {
+		if entry, ok := s.usage[quotaKey(projectID, resource)]; ok {
+			entry.measuredAt = time.Time{}
+		}
+	}
+}
+
+func (s *QuotaService) measure(ctx context.Context, projectID string, resource domain.QuotaResource) (int64, error) {
+	switch resource {
+	case domain.QuotaStorage:
+		return s.storageService.GetStorageSize(projectID)
+	case domain.QuotaLogs:
+		files, err := s.logService.ListFiles(projectID)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		for _, file := range files {
+			total += file.Size
+		}
+		return total, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		return 0, err
+	}
+	switch resource {
+	case domain.QuotaData:
+		return s.modelService.GetProjectDataSize(ctx, id)
+	case domain.QuotaDocuments:
+		return s.modelService.CountProjectDocuments(ctx, id)
+	}
+	return 0, fmt.Errorf("unknown quota resource %q", resource)
+}
+
+// warn notifies the warning handlers when usage passed the warning threshold
+// or a write was rejected, at most once per quotaWarningInterval and kind
+func (s *QuotaService) warn(projectID string, resource domain.QuotaResource, used, limit int64, warnPercent int, exceeded bool) {
+	percent := float64(used) * 100 / float64(limit)
+	if percent < float64(warnPercent) && !exceeded {
+		return
+	}
+
+	key := quotaKey(projectID, resource)
+	if exceeded {
+		key += "/exceeded"
+	}
+	s.mu.Lock()
+	if last, ok := s.warned[key]; ok && time.Since(last) < quotaWarningInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.warned[key] = time.Now()
+	handlers := append([]QuotaWarningHandler(nil), s.handlers...)
+	s.mu.Unlock()
+
+	warning := domain.QuotaWarning{
+		ProjectID: projectID,
+		Resource:  resource,
+		Used:      used,
+		Limit:     limit,
+		Percent:   percent,
+		Exceeded:  exceeded,
+	}
+	if resource == domain.QuotaDocuments {
+		warning.Message = fmt.Sprintf("%s quota at %.0f%%: %d of %d documents used", resource, percent, used, limit)
+	} else {
+		warning.Message = fmt.Sprintf("%s quota at %.0f%%: %s of %s used", resource, percent, domain.FormatBytes(used), domain.FormatBytes(limit))
+	}
+	if exceeded {
+		warning.Message += ", writes are rejected"
+	}
+
+	for _, handler := range handlers {
+		handler(warning)
+	}
+}
+
+func quotaKey(projectID string, resource domain.QuotaResource) string {
+	return projectID + "/" + string(resource)
+}
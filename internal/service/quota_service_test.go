@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestQuotaLimitsFor(t *testing.T) {
+	cfg := config.QuotaConfig{StorageMB: 100, Documents: 1000, WarnPercent: 80}
+
+	limits := QuotaLimitsFor(cfg, nil)
+	if limits.StorageBytes != 100<<20 || limits.DataBytes != 0 || limits.Documents != 1000 || limits.WarnPercent != 80 {
+		t.Errorf("unexpected default limits %+v", limits)
+	}
+
+	project := &domain.Project{Quota: &domain.ProjectQuota{StorageMB: 5, LogMB: 2}}
+	limits = QuotaLimitsFor(cfg, project)
+	if limits.StorageBytes != 5<<20 || limits.LogBytes != 2<<20 || limits.Documents != 1000 {
+		t.Errorf("expected project overrides with inherited defaults, got %+v", limits)
+	}
+
+	if limits := QuotaLimitsFor(config.QuotaConfig{}, nil); limits.WarnPercent != 100 {
+		t.Errorf("expected warnings at 100%% without warn_percent, got %d", limits.WarnPercent)
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Quota = config.QuotaConfig{StorageMB: 1, WarnPercent: 50}
+	storageService := newTestStorageService(t, cfg)
+
+	quotaService := NewQuotaService(cfg, nil, storageService, nil, nil)
+	storageService.SetQuota(quotaService)
+
+	var warnings []domain.QuotaWarning
+	quotaService.OnWarning(func(w domain.QuotaWarning) {
+		warnings = append(warnings, w)
+	})
+
+	// Without a project service the configured defaults apply
+	chunk := make([]byte, 600<<10)
+	if err := storageService.Write("p1", "a.bin", chunk); err != nil {
+		t.Fatalf("expected first write to fit, got %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Resource != domain.QuotaStorage || warnings[0].Exceeded {
+		t.Fatalf("expected one storage warning, got %+v", warnings)
+	}
+
+	// Overwriting a file only counts the growth
+	if err := storageService.Write("p1", "a.bin", chunk); err != nil {
+		t.Fatalf("expected overwrite to fit, got %v", err)
+	}
+
+	err := storageService.Write("p1", "b.bin", chunk)
+	var quotaErr *domain.QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	if quotaErr.Resource != domain.QuotaStorage || quotaErr.Limit != 1<<20 {
+		t.Errorf("unexpected quota error %+v", quotaErr)
+	}
+	if storageService.Exists("p1", "b.bin") {
+		t.Error("rejected file was written")
+	}
+	if len(warnings) != 2 || !warnings[1].Exceeded {
+		t.Errorf("expected an exceeded warning, got %+v", warnings)
+	}
+
+	if err := storageService.Append("p1", "a.bin", make([]byte, 500<<10)); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("expected append to be rejected, got %v", err)
+	}
+}
+
+func TestQuotaReserveConcurrent(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Quota = config.QuotaConfig{StorageMB: 1}
+	quotaService := NewQuotaService(cfg, nil, newTestStorageService(t, cfg), nil, nil)
+
+	// Nothing is written, so every measurement sees an empty project: only
+	// the running total of reservations stops the sixth one
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if quotaService.Reserve("p1", domain.QuotaStorage, 200<<10) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := accepted.Load(); got != 5 {
+		t.Errorf("accepted %d reservations of 200 KB into 1 MB, want 5", got)
+	}
+
+	// A new measurement still counts the reservations whose writes may be in flight
+	quotaService.Invalidate("p1")
+	if err := quotaService.Reserve("p1", domain.QuotaStorage, 200<<10); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Errorf("expected a quota error after measuring again, got %v", err)
+	}
+}
+
+func TestQuotaReserveFailsClosed(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Quota = config.QuotaConfig{DataMB: 1}
+	quotaService := NewQuotaService(cfg, nil, nil, nil, nil)
+
+	// Data usage of a project id that is not an ObjectID cannot be measured
+	if err := quotaService.Reserve("p1", domain.QuotaData, 1); err == nil {
+		t.Error("expected the write to be refused when usage cannot be measured")
+	}
+
+	// Nor can the quotas of a project that cannot be loaded
+	quotaService = NewQuotaService(cfg, &ProjectService{}, nil, nil, nil)
+	if err := quotaService.Reserve("p1", domain.QuotaData, 1); err == nil {
+		t.Error("expected the write to be refused when the project cannot be loaded")
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// remoteAddressAllowed reports whether an outgoing request may connect to ip.
+// Loopback, private and link-local addresses belong to the server's network.
+func remoteAddressAllowed(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// publicDialer refuses to connect to internal addresses with forbidden. The
+// check runs at dial time, so redirects and DNS rebinding can't reach them either.
+func publicDialer(timeout time.Duration, forbidden error) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !remoteAddressAllowed(ip) {
+				return forbidden
+			}
+			return nil
+		},
+	}
+}
+
+// checkRemoteHost resolves host and returns forbidden if any of its addresses
+// is internal. It gives a clear error up front, publicDialer enforces it.
+func checkRemoteHost(ctx context.Context, host string, forbidden error) error {
+	host = strings.Trim(host, "[]")
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !remoteAddressAllowed(addr.IP) {
+			return forbidden
+		}
+	}
+	return nil
+}
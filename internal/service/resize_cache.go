@@ -0,0 +1,134 @@
+package service
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/levskiy0/m3m/internal/storage"
+)
+
+// resizeCache tracks the transformed images cached under {project-id}/tmp/resize
+// and evicts the least recently used ones once their total size passes the limit
+type resizeCache struct {
+	driver storage.Driver
+	limit  int64 // bytes, 0 = unlimited
+
+	mu      sync.Mutex
+	loaded  bool
+	total   int64
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type resizeCacheEntry struct {
+	key  string
+	size int64
+}
+
+func newResizeCache(driver storage.Driver, limitMB int) *resizeCache {
+	return &resizeCache{
+		driver:  driver,
+		limit:   int64(limitMB) << 20,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// load indexes the cache files already in storage, oldest first
+func (c *resizeCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	projects, err := c.driver.List("")
+	if err != nil {
+		return
+	}
+
+	var objects []storage.ObjectInfo
+	for _, project := range projects {
+		if !project.IsDir {
+			continue
+		}
+		c.driver.Walk(storage.JoinKey(project.Key, "tmp", "resize"), func(info storage.ObjectInfo) error {
+			objects = append(objects, info)
+			return nil
+		})
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ModTime.Before(objects[j].ModTime) })
+	for _, object := range objects {
+		c.entries[object.Key] = c.order.PushFront(&resizeCacheEntry{key: object.Key, size: object.Size})
+		c.total += object.Size
+	}
+}
+
+// touch marks a cached image as used
+func (c *resizeCache) touch(key string) {
+	if c.limit <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+	}
+}
+
+// add records a newly cached image and evicts the least recently used
+// images until the cache fits its limit again
+func (c *resizeCache) add(key string, size int64) {
+	if c.limit <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.load()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*resizeCacheEntry)
+		c.total += size - entry.size
+		entry.size = size
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&resizeCacheEntry{key: key, size: size})
+		c.total += size
+	}
+
+	var evicted []string
+	for c.total > c.limit && c.order.Len() > 1 {
+		element := c.order.Back()
+		entry := element.Value.(*resizeCacheEntry)
+		c.order.Remove(element)
+		delete(c.entries, entry.key)
+		c.total -= entry.size
+		evicted = append(evicted, entry.key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range evicted {
+		c.driver.Delete(key)
+	}
+}
+
+// forget drops the entries of a deleted cache file or directory
+func (c *resizeCache) forget(prefix string) {
+	if c.limit <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		return
+	}
+	for key, element := range c.entries {
+		if key == prefix || strings.HasPrefix(key, prefix+"/") {
+			c.total -= element.Value.(*resizeCacheEntry).size
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/oidc"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+var (
+	ErrSSODisabled      = errors.New("single sign-on is not enabled")
+	ErrSSORequest       = errors.New("login request expired or unknown, try again")
+	ErrSSONoEmail       = errors.New("the provider did not return an email address")
+	ErrSSOUnverified    = errors.New("the email address is not verified by the provider")
+	ErrSSOLinked        = errors.New("this email belongs to an account linked to another identity")
+	ErrSSOLinkRequired  = errors.New("this email belongs to an existing account, log in with its password and link the identity from your profile")
+	ErrSSONoAccount     = errors.New("no account exists for this user")
+	ErrSSOTooManyLogins = errors.New("too many pending logins, try again later")
+)
+
+const (
+	// ssoRequestTTL is how long the user has to finish logging in at the provider
+	ssoRequestTTL = 10 * time.Minute
+	// ssoHandoffTTL is how long the login page has to pick up the tokens after the callback
+	ssoHandoffTTL = time.Minute
+	// ssoMaxPending caps logins started but not finished, the login route is public
+	ssoMaxPending = 10000
+)
+
+// ssoRequest is a login started at the provider, keyed by its state
+type ssoRequest struct {
+	nonce     string
+	verifier  string
+	redirect  string
+	linkUser  *primitive.ObjectID // Set when a logged in user links their identity
+	expiresAt time.Time
+}
+
+// ssoHandoff holds the tokens of a finished login until the login page exchanges its code
+type ssoHandoff struct {
+	resp      *domain.LoginResponse
+	expiresAt time.Time
+}
+
+// SSOService logs users in through an OpenID Connect provider. Users are
+// created on their first login and get the permissions and project roles
+// mapped from their groups on every login.
+type SSOService struct {
+	config         *config.Config
+	userRepo       *repository.UserRepository
+	projectService *ProjectService
+	authService    *AuthService
+	logger         *slog.Logger
+	client         *http.Client
+
+	providerMu sync.Mutex
+	provider   *oidc.Provider
+
+	mu       sync.Mutex
+	requests map[string]*ssoRequest
+	handoffs map[string]*ssoHandoff
+}
+
+func NewSSOService(
+	config *config.Config,
+	userRepo *repository.UserRepository,
+	projectService *ProjectService,
+	authService *AuthService,
+	logger *slog.Logger,
+) *SSOService {
+	return &SSOService{
+		config:         config,
+		userRepo:       userRepo,
+		projectService: projectService,
+		authService:    authService,
+		logger:         logger,
+		client:         &http.Client{Timeout: 10 * time.Second},
+		requests:       make(map[string]*ssoRequest),
+		handoffs:       make(map[string]*ssoHandoff),
+	}
+}
+
+func (s *SSOService) Enabled() bool {
+	return s.config.OIDC.Enabled
+}
+
+// Name is the label of the login button
+func (s *SSOService) Name() string {
+	return s.config.OIDC.Name
+}
+
+// Begin starts a login and returns the URL of the provider to send the user
+// to. redirect is where the panel goes after logging in.
+func (s *SSOService) Begin(ctx context.Context, redirect string) (string, error) {
+	return s.begin(ctx, redirect, nil)
+}
+
+// BeginLink starts a login that links the identity to the account of user,
+// whatever its email. It is the only way to link root and accounts with a password.
+func (s *SSOService) BeginLink(ctx context.Context, user *domain.User, redirect string) (string, error) {
+	return s.begin(ctx, redirect, &user.ID)
+}
+
+func (s *SSOService) begin(ctx context.Context, redirect string, linkUser *primitive.ObjectID) (string, error) {
+	if !s.Enabled() {
+		return "", ErrSSODisabled
+	}
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state := oidc.RandomString()
+	req := &ssoRequest{
+		nonce:     oidc.RandomString(),
+		verifier:  oidc.RandomString(),
+		redirect:  redirect,
+		linkUser:  linkUser,
+		expiresAt: time.Now().Add(ssoRequestTTL),
+	}
+
+	s.mu.Lock()
+	s.sweep(time.Now())
+	if len(s.requests) >= ssoMaxPending {
+		s.mu.Unlock()
+		return "", ErrSSOTooManyLogins
+	}
+	s.requests[state] = req
+	s.mu.Unlock()
+
+	return provider.AuthCodeURL(s.clientConfig(), state, req.nonce, req.verifier), nil
+}
+
+// Complete finishes a login on the callback from the provider. It returns a
+// single-use code the login page exchanges for the tokens, so they never
+// appear in a URL, and the redirect given to Begin.
+func (s *SSOService) Complete(ctx context.Context, state, code string, client domain.SessionClient) (handoff, redirect string, err error) {
+	s.mu.Lock()
+	req, ok := s.requests[state]
+	delete(s.requests, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(req.expiresAt) {
+		return "", "", ErrSSORequest
+	}
+	redirect = req.redirect
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", redirect, err
+	}
+	cfg := s.clientConfig()
+	token, err := provider.Exchange(ctx, cfg, code, req.verifier)
+	if err != nil {
+		return "", redirect, err
+	}
+	claims, err := provider.VerifyIDToken(ctx, cfg.ClientID, token.IDToken, req.nonce)
+	if err != nil {
+		return "", redirect, err
+	}
+
+	// Providers may leave the profile out of the ID token
+	if _, hasGroups := claims[s.config.OIDC.GroupsClaim]; (claims.String("email") == "" || !hasGroups) && provider.UserinfoEndpoint != "" && token.AccessToken != "" {
+		info, err := provider.UserInfo(ctx, token.AccessToken)
+		if err != nil {
+			s.logger.Warn("Failed to fetch SSO userinfo", "error", err)
+		} else if info.String("sub") == claims.String("sub") {
+			for name, value := range info {
+				if _, ok := claims[name]; !ok {
+					claims[name] = value
+				}
+			}
+		}
+	}
+
+	var user *domain.User
+	if req.linkUser != nil {
+		user, err = s.linkAccount(ctx, *req.linkUser, claims)
+	} else {
+		user, err = s.provision(ctx, claims)
+	}
+	if err != nil {
+		return "", redirect, err
+	}
+
+	resp, err := s.authService.beginSession(ctx, user, client)
+	if err != nil {
+		return "", redirect, err
+	}
+
+	handoff = oidc.RandomString()
+	s.mu.Lock()
+	s.handoffs[handoff] = &ssoHandoff{resp: resp, expiresAt: time.Now().Add(ssoHandoffTTL)}
+	s.mu.Unlock()
+
+	return handoff, redirect, nil
+}
+
+// Exchange returns the tokens of a finished login, once
+func (s *SSOService) Exchange(handoff string) (*domain.LoginResponse, error) {
+	s.mu.Lock()
+	h, ok := s.handoffs[handoff]
+	delete(s.handoffs, handoff)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(h.expiresAt) {
+		return nil, ErrSSORequest
+	}
+	return h.resp, nil
+}
+
+// provision finds or creates the user of an identity and applies its group mappings
+func (s *SSOService) provision(ctx context.Context, claims oidc.Claims) (*domain.User, error) {
+	subject := claims.String("sub")
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", oidc.ErrInvalidIDToken)
+	}
+
+	user, err := s.userRepo.FindBySSOSubject(ctx, subject)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		user, err = s.link(ctx, subject, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.admit(ctx, user, claims)
+}
+
+// linkAccount links an identity to the account of the user who started the
+// login, unless it already belongs to another account
+func (s *SSOService) linkAccount(ctx context.Context, userID primitive.ObjectID, claims oidc.Claims) (*domain.User, error) {
+	subject := claims.String("sub")
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", oidc.ErrInvalidIDToken)
+	}
+
+	linked, err := s.userRepo.FindBySSOSubject(ctx, subject)
+	if err == nil && linked.ID != userID {
+		return nil, ErrSSOLinked
+	}
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.SSOSubject != "" && user.SSOSubject != subject {
+		return nil, ErrSSOLinked
+	}
+	user.SSOSubject = subject
+	user.SSOLinkAllowed = false
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Linked SSO identity", "email", user.Email)
+	return s.admit(ctx, user, claims)
+}
+
+// admit lets a user with a linked identity in, applying its group mappings
+func (s *SSOService) admit(ctx context.Context, user *domain.User, claims oidc.Claims) (*domain.User, error) {
+	if user.IsBlocked {
+		return nil, ErrUserBlocked
+	}
+	if err := s.applyGroups(ctx, user, claims.Strings(s.config.OIDC.GroupsClaim)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link connects an identity to the account with its email, or creates one.
+// Root and accounts with a password are only linked once an admin allowed it.
+func (s *SSOService) link(ctx context.Context, subject string, claims oidc.Claims) (*domain.User, error) {
+	email := strings.TrimSpace(claims.String("email"))
+	if email == "" {
+		return nil, ErrSSONoEmail
+	}
+	// A missing claim is not a verification
+	if verified, _ := claims.Bool("email_verified"); !verified {
+		return nil, ErrSSOUnverified
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err == nil {
+		if user.SSOSubject != "" {
+			return nil, ErrSSOLinked
+		}
+		if !ssoAutoLinkAllowed(user) {
+			return nil, ErrSSOLinkRequired
+		}
+		user.SSOSubject = subject
+		user.SSOLinkAllowed = false
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	if !s.config.OIDC.AutoCreate {
+		return nil, ErrSSONoAccount
+	}
+
+	name := strings.TrimSpace(claims.String("name"))
+	if name == "" {
+		name = email
+	}
+	// Without a password the account can only log in through the provider
+	user = &domain.User{
+		Email:       email,
+		Name:        name,
+		SSOSubject:  subject,
+		Permissions: domain.Permissions{ProjectAccess: []primitive.ObjectID{}},
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Created user from SSO login", "email", email)
+	return user, nil
+}
+
+// ssoAutoLinkAllowed reports whether an account may be linked to an identity
+// with its email on login, without its owner taking part
+func ssoAutoLinkAllowed(user *domain.User) bool {
+	return user.SSOLinkAllowed || (!user.IsRoot && user.Password == "")
+}
+
+// applyGroups grants the permissions and project roles mapped from the
+// groups of a user. Permissions follow the groups, project roles are only
+// raised, so roles given by hand survive.
+func (s *SSOService) applyGroups(ctx context.Context, user *domain.User, groups []string) error {
+	if user.IsRoot || len(s.config.OIDC.Groups) == 0 {
+		return nil
+	}
+
+	grants := ssoGroupGrants(s.config.OIDC.Groups, groups)
+
+	if user.Permissions.CreateProjects != grants.CreateProjects || user.Permissions.ManageUsers != grants.ManageUsers {
+		user.Permissions.CreateProjects = grants.CreateProjects
+		user.Permissions.ManageUsers = grants.ManageUsers
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	for slug, role := range grants.Projects {
+		project, err := s.projectService.GetBySlug(ctx, slug)
+		if err != nil {
+			s.logger.Warn("SSO group maps to an unknown project", "project", slug, "error", err)
+			continue
+		}
+
+		current, member := project.MemberRole(user.ID)
+		switch {
+		case !member:
+			err = s.projectService.AddMember(ctx, project.ID, user.ID, role)
+		case projectRoleRank(role) > projectRoleRank(current):
+			err = s.projectService.SetMemberRole(ctx, project.ID, user.ID, role)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ssoGrants are the permissions and project roles of a set of groups
+type ssoGrants struct {
+	CreateProjects bool
+	ManageUsers    bool
+	Projects       map[string]domain.ProjectRole // Project slug to role
+}
+
+// ssoGroupGrants merges the mappings of the groups a user is in. Unknown
+// roles in the mappings are ignored.
+func ssoGroupGrants(mappings []config.OIDCGroupConfig, groups []string) ssoGrants {
+	grants := ssoGrants{Projects: make(map[string]domain.ProjectRole)}
+	for _, mapping := range mappings {
+		if !slices.Contains(groups, mapping.Group) {
+			continue
+		}
+		grants.CreateProjects = grants.CreateProjects || mapping.CreateProjects
+		grants.ManageUsers = grants.ManageUsers || mapping.ManageUsers
+		for slug, name := range mapping.Projects {
+			role := domain.ProjectRole(strings.ToLower(name))
+			if role.Valid() && projectRoleRank(role) > projectRoleRank(grants.Projects[slug]) {
+				grants.Projects[slug] = role
+			}
+		}
+	}
+	return grants
+}
+
+// projectRoleRank orders roles by privilege, -1 for none
+func projectRoleRank(role domain.ProjectRole) int {
+	return slices.Index(domain.ProjectRoles, role)
+}
+
+// discover loads the provider metadata on first use, so a provider that is
+// down does not keep the server from starting
+func (s *SSOService) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+
+	if s.provider == nil {
+		provider, err := oidc.Discover(ctx, s.client, s.config.OIDC.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		s.provider = provider
+	}
+	return s.provider, nil
+}
+
+func (s *SSOService) clientConfig() oidc.Config {
+	cfg := s.config.OIDC
+	scopes := cfg.Scopes
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	return oidc.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURI:  cfg.RedirectURI,
+		Scopes:       scopes,
+	}
+}
+
+// sweep drops expired requests and handoffs; s.mu must be held
+func (s *SSOService) sweep(now time.Time) {
+	for state, req := range s.requests {
+		if now.After(req.expiresAt) {
+			delete(s.requests, state)
+		}
+	}
+	for code, h := range s.handoffs {
+		if now.After(h.expiresAt) {
+			delete(s.handoffs, code)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/oidc"
+)
+
+func TestSSOGroupGrants(t *testing.T) {
+	mappings := []config.OIDCGroupConfig{
+		{Group: "platform", CreateProjects: true, Projects: map[string]string{"billing": "Admin", "shop": "developer"}},
+		{Group: "support", Projects: map[string]string{"billing": "viewer", "shop": "operator", "crm": "owner"}},
+		{Group: "admins", ManageUsers: true},
+	}
+
+	grants := ssoGroupGrants(mappings, []string{"platform", "support"})
+	if !grants.CreateProjects || grants.ManageUsers {
+		t.Errorf("permissions %+v", grants)
+	}
+	want := map[string]domain.ProjectRole{"billing": domain.ProjectRoleAdmin, "shop": domain.ProjectRoleOperator}
+	if len(grants.Projects) != len(want) {
+		t.Errorf("projects %v, want %v", grants.Projects, want)
+	}
+	for slug, role := range want {
+		if grants.Projects[slug] != role {
+			t.Errorf("%s: role %q, want %q", slug, grants.Projects[slug], role)
+		}
+	}
+
+	if grants := ssoGroupGrants(mappings, nil); grants.CreateProjects || grants.ManageUsers || len(grants.Projects) != 0 {
+		t.Errorf("no groups granted %+v", grants)
+	}
+}
+
+func TestPasswordLoginAllowed(t *testing.T) {
+	root := &domain.User{IsRoot: true}
+	user := &domain.User{}
+
+	s := NewAuthService(nil, nil, nil, nil, &config.Config{OIDC: config.OIDCConfig{Enabled: true, DisablePasswordLogin: true}})
+	if s.PasswordLoginAllowed(user) || s.PasswordLoginAllowed(nil) {
+		t.Error("password login allowed for a regular user")
+	}
+	if !s.PasswordLoginAllowed(root) {
+		t.Error("password login denied for root")
+	}
+
+	s = NewAuthService(nil, nil, nil, nil, &config.Config{OIDC: config.OIDCConfig{Enabled: false, DisablePasswordLogin: true}})
+	if !s.PasswordLoginAllowed(user) {
+		t.Error("password login denied with SSO disabled")
+	}
+}
+
+func TestSSOLinkByEmail(t *testing.T) {
+	s := NewSSOService(&config.Config{}, nil, nil, nil, nil)
+	for _, claims := range []oidc.Claims{
+		{"sub": "1", "email": "dev@example.com"},
+		{"sub": "1", "email": "dev@example.com", "email_verified": false},
+		{"sub": "1", "email": "dev@example.com", "email_verified": "true"},
+	} {
+		if _, err := s.link(context.Background(), "1", claims); !errors.Is(err, ErrSSOUnverified) {
+			t.Errorf("%v: err = %v, want %v", claims, err, ErrSSOUnverified)
+		}
+	}
+
+	cases := []struct {
+		name string
+		user *domain.User
+		want bool
+	}{
+		{"without password", &domain.User{}, true},
+		{"with password", &domain.User{Password: "hash"}, false},
+		{"root", &domain.User{IsRoot: true}, false},
+		{"allowed by an admin", &domain.User{Password: "hash", SSOLinkAllowed: true}, true},
+		{"root allowed by root", &domain.User{IsRoot: true, Password: "hash", SSOLinkAllowed: true}, true},
+	}
+	for _, tc := range cases {
+		if got := ssoAutoLinkAllowed(tc.user); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
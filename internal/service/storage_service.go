@@ -2,30 +2,44 @@ package service
 
 import (
 	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"mime/multipart"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/image/draw"
 
 	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/imaging"
+	"github.com/levskiy0/m3m/internal/storage"
 )
 
 var (
 	ErrFileNotFound      = errors.New("file not found")
 	ErrDirectoryNotFound = errors.New("directory not found")
 	ErrInvalidPath       = errors.New("invalid path")
+	ErrSignatureInvalid  = errors.New("invalid signature")
+	ErrSignatureExpired  = errors.New("link expired")
+	ErrNoLocalPath       = errors.New("storage driver does not keep files on local disk")
 )
 
+// DefaultSignedURLTTL is used for signed URLs when no expiry is given
+const DefaultSignedURLTTL = time.Hour
+
 type FileInfo struct {
 	Name        string    `json:"name"`
 	Path        string    `json:"path"`
@@ -36,111 +50,272 @@ type FileInfo struct {
 	URL         string    `json:"url,omitempty"`
 	DownloadURL string    `json:"download_url,omitempty"`
 	ThumbURL    string    `json:"thumb_url,omitempty"`
+	Private     bool      `json:"private,omitempty"`
+}
+
+// StorageObject is a file opened for reading. The reader is an io.ReadSeeker
+// when the storage driver supports it.
+type StorageObject struct {
+	io.ReadCloser
+	Name     string
+	Size     int64
+	MimeType string
+	ModTime  time.Time
 }
 
+// StorageEventHandler receives writes, deletes and renames in project storage
+type StorageEventHandler func(event domain.StorageEvent)
+
 type StorageService struct {
-	config *config.Config
+	config      *config.Config
+	driver      storage.Driver
+	quota       QuotaReserver
+	versioning  VersioningPolicy
+	resizeCache *resizeCache
+	origin      domain.StorageOrigin // Set on the events of a WithOrigin view
+
+	mu       *sync.RWMutex
+	handlers *[]StorageEventHandler
+
+	versionMu *sync.Mutex // Guards version histories and blob references
 }
 
-func NewStorageService(config *config.Config) *StorageService {
+func NewStorageService(config *config.Config, driver storage.Driver) *StorageService {
 	return &StorageService{
-		config: config,
+		config:      config,
+		driver:      driver,
+		resizeCache: newResizeCache(driver, config.Storage.ResizeCacheMB),
+		mu:          &sync.RWMutex{},
+		handlers:    &[]StorageEventHandler{},
+		versionMu:   &sync.Mutex{},
 	}
 }
 
-func (s *StorageService) getProjectStoragePath(projectID string) string {
-	return filepath.Join(s.config.Storage.Path, projectID, "storage")
+// WithOrigin returns a view of the service that marks the change events of
+// its writes with origin. Handlers and state are shared with the service.
+func (s *StorageService) WithOrigin(origin domain.StorageOrigin) *StorageService {
+	view := *s
+	view.origin = origin
+	return &view
 }
 
-func (s *StorageService) resolvePath(projectID, relativePath string) (string, error) {
-	basePath := s.getProjectStoragePath(projectID)
-	fullPath := filepath.Join(basePath, relativePath)
+// SetQuota enables storage quota checks for writes
+func (s *StorageService) SetQuota(quota QuotaReserver) {
+	s.quota = quota
+}
 
-	// Prevent directory traversal
-	if !strings.HasPrefix(fullPath, basePath) {
-		return "", ErrInvalidPath
+// reserve checks the storage quota of a project before size more bytes are stored
+func (s *StorageService) reserve(projectID string, size int64) error {
+	if s.quota == nil || size <= 0 {
+		return nil
 	}
+	return s.quota.Reserve(projectID, domain.QuotaStorage, size)
+}
 
-	// Resolve symlinks to prevent symlink-based path traversal
-	// Only check if the path exists (for reads/deletes)
-	if realPath, err := filepath.EvalSymlinks(fullPath); err == nil {
-		realBasePath, _ := filepath.EvalSymlinks(basePath)
-		if realBasePath == "" {
-			realBasePath = basePath
-		}
-		if !strings.HasPrefix(realPath, realBasePath) {
-			return "", ErrInvalidPath
+// release gives back storage quota reserved for bytes that were not stored
+func (s *StorageService) release(projectID string, size int64) {
+	if s.quota != nil && size > 0 {
+		s.quota.Release(projectID, domain.QuotaStorage, size)
+	}
+}
+
+// reserveReplace reserves the growth of a file that is overwritten with size bytes
+func (s *StorageService) reserveReplace(projectID, key string, size int64) error {
+	if s.quota == nil {
+		return nil
+	}
+	if info, err := s.driver.Stat(key); err == nil && !info.IsDir {
+		size -= info.Size
+	}
+	return s.reserve(projectID, size)
+}
+
+// OnChange registers a handler for file changes. It runs synchronously after
+// each write, delete or rename, whether made through the API or $storage.
+func (s *StorageService) OnChange(handler StorageEventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.handlers = append(*s.handlers, handler)
+}
+
+func (s *StorageService) hasChangeHandlers() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(*s.handlers) > 0
+}
+
+// emit sends a change event to the registered handlers
+func (s *StorageService) emit(projectID string, event domain.StorageEvent) {
+	s.mu.RLock()
+	handlers := *s.handlers
+	s.mu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+
+	event.ProjectID = projectID
+	event.Origin = s.origin
+	event.Path, _ = cleanRelativePath(event.Path)
+	if event.OldPath != "" {
+		event.OldPath, _ = cleanRelativePath(event.OldPath)
+	}
+	if !event.IsDir {
+		event.MimeType = getMimeType(event.Path)
+	}
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// emitStat emits an event for a stored file or directory, taking its size from the driver
+func (s *StorageService) emitStat(projectID string, eventType domain.StorageEventType, key, relativePath, oldPath string) {
+	if !s.hasChangeHandlers() {
+		return
+	}
+	event := domain.StorageEvent{Type: eventType, Path: relativePath, OldPath: oldPath}
+	if info, err := s.driver.Stat(key); err == nil {
+		event.IsDir = info.IsDir
+		if !info.IsDir {
+			event.Size = info.Size
 		}
 	}
+	s.emit(projectID, event)
+}
 
-	return fullPath, nil
+// Driver returns the storage driver behind the service
+func (s *StorageService) Driver() storage.Driver {
+	return s.driver
 }
 
-func (s *StorageService) List(projectID, relativePath string) ([]FileInfo, error) {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// projectKey returns the root key of a project
+func projectKey(projectID string) (string, error) {
+	if projectID == "" || projectID == "." || projectID == ".." || strings.ContainsAny(projectID, "/\\") {
+		return "", ErrInvalidPath
+	}
+	return projectID, nil
+}
+
+// cleanRelativePath normalizes a path inside project storage, refusing paths
+// that climb above the storage root
+func cleanRelativePath(relativePath string) (string, error) {
+	rel := strings.TrimLeft(strings.ReplaceAll(relativePath, "\\", "/"), "/")
+	rel = path.Clean(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", ErrInvalidPath
+	}
+	if rel == "." {
+		rel = ""
+	}
+	return rel, nil
+}
+
+// objectKey maps a path in project storage to a driver key
+func (s *StorageService) objectKey(projectID, relativePath string) (string, error) {
+	root, err := projectKey(projectID)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil {
+		return "", err
 	}
+	return storage.JoinKey(root, "storage", rel), nil
+}
 
-	entries, err := os.ReadDir(fullPath)
+// resizeCacheKey returns the key of a cached resized image
+func (s *StorageService) resizeCacheKey(projectID, relativePath, sizeDir string) (string, error) {
+	root, err := projectKey(projectID)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrDirectoryNotFound
-		}
-		return nil, err
+		return "", err
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil {
+		return "", err
 	}
+	return storage.JoinKey(root, "tmp", "resize", sizeDir, rel), nil
+}
 
-	files := make([]FileInfo, 0)
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
+// mapDriverError translates driver errors to the service errors handlers know about
+func mapDriverError(err error, notFound error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrNotExist):
+		return notFound
+	case errors.Is(err, storage.ErrInvalidKey):
+		return ErrInvalidPath
+	default:
+		return err
+	}
+}
 
-		filePath := filepath.Join(relativePath, entry.Name())
-		fileInfo := FileInfo{
-			Name:      entry.Name(),
-			Path:      filePath,
-			IsDir:     entry.IsDir(),
-			Size:      info.Size(),
-			UpdatedAt: info.ModTime(),
+func (s *StorageService) fileInfo(projectID, filePath string, object storage.ObjectInfo) FileInfo {
+	fileInfo := FileInfo{
+		Name:      object.Name(),
+		Path:      filePath,
+		IsDir:     object.IsDir,
+		Size:      object.Size,
+		UpdatedAt: object.ModTime,
+	}
+
+	if !object.IsDir {
+		fileInfo.MimeType = getMimeType(object.Name())
+		// Generate public URLs (without /api prefix)
+		fileInfo.URL = fmt.Sprintf("%s/cdn/%s%s", s.config.Server.URI, projectID, filePath)
+		fileInfo.DownloadURL = fmt.Sprintf("%s/cdn/download/%s%s", s.config.Server.URI, projectID, filePath)
+		// Add thumb_url for images
+		if strings.HasPrefix(fileInfo.MimeType, "image/") {
+			fileInfo.ThumbURL = fmt.Sprintf("%s/cdn/resize/64x64/%s%s", s.config.Server.URI, projectID, filePath)
 		}
+	}
 
-		if !entry.IsDir() {
-			fileInfo.MimeType = getMimeType(entry.Name())
-			// Generate public URLs (without /api prefix)
-			fileInfo.URL = fmt.Sprintf("%s/cdn/%s%s", s.config.Server.URI, projectID, filePath)
-			fileInfo.DownloadURL = fmt.Sprintf("%s/cdn/download/%s%s", s.config.Server.URI, projectID, filePath)
-			// Add thumb_url for images
-			if strings.HasPrefix(fileInfo.MimeType, "image/") {
-				fileInfo.ThumbURL = fmt.Sprintf("%s/cdn/resize/64x64/%s%s", s.config.Server.URI, projectID, filePath)
-			}
-		}
+	return fileInfo
+}
 
-		files = append(files, fileInfo)
+func (s *StorageService) List(projectID, relativePath string) ([]FileInfo, error) {
+	key, err := s.objectKey(projectID, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := s.driver.List(key)
+	if err != nil {
+		return nil, mapDriverError(err, ErrDirectoryNotFound)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Name() < objects[j].Name()
+	})
+
+	files := make([]FileInfo, 0, len(objects))
+	for _, object := range objects {
+		files = append(files, s.fileInfo(projectID, filepath.Join(relativePath, object.Name()), object))
 	}
 
 	return files, nil
 }
 
 func (s *StorageService) MkDir(projectID, relativePath string) error {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return err
 	}
 
-	return os.MkdirAll(fullPath, 0755)
+	return mapDriverError(s.driver.MkDir(key), ErrDirectoryNotFound)
 }
 
-func (s *StorageService) Upload(projectID, relativePath string, file *multipart.FileHeader) error {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// Upload stores an uploaded file; user is recorded on the version it replaces
+func (s *StorageService) Upload(projectID, relativePath string, file *multipart.FileHeader, user string) error {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return err
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := s.reserveReplace(projectID, key, file.Size); err != nil {
+		return err
+	}
+
+	if err := s.keepVersions(projectID, relativePath, user, domain.StorageVersionOverwrite); err != nil {
 		return err
 	}
 
@@ -150,339 +325,488 @@ func (s *StorageService) Upload(projectID, relativePath string, file *multipart.
 	}
 	defer src.Close()
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return err
+	if err := s.driver.Put(key, src, file.Size); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, src)
-	return err
+	s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: relativePath, Size: file.Size})
+	return nil
 }
 
-func (s *StorageService) Download(projectID, relativePath string) (string, error) {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// Open opens a file of project storage for reading
+func (s *StorageService) Open(projectID, relativePath string) (*StorageObject, error) {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return s.openObject(key)
+}
 
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return "", ErrFileNotFound
+func (s *StorageService) openObject(key string) (*StorageObject, error) {
+	info, err := s.driver.Stat(key)
+	if err != nil {
+		return nil, mapDriverError(err, ErrFileNotFound)
+	}
+	if info.IsDir {
+		return nil, ErrFileNotFound
 	}
 
-	return fullPath, nil
+	reader, err := s.driver.Open(key)
+	if err != nil {
+		return nil, mapDriverError(err, ErrFileNotFound)
+	}
+
+	return &StorageObject{
+		ReadCloser: reader,
+		Name:       info.Name(),
+		Size:       info.Size,
+		MimeType:   getMimeType(info.Name()),
+		ModTime:    info.ModTime,
+	}, nil
 }
 
 func (s *StorageService) Read(projectID, relativePath string) ([]byte, error) {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return nil, err
 	}
 
-	return os.ReadFile(fullPath)
+	reader, err := s.driver.Open(key)
+	if err != nil {
+		return nil, mapDriverError(err, ErrFileNotFound)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
 }
 
 func (s *StorageService) Write(projectID, relativePath string, content []byte) error {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+	return s.WriteAs(projectID, relativePath, content, RuntimeVersionUser)
+}
+
+// WriteAs writes a file; user is recorded on the version it replaces
+func (s *StorageService) WriteAs(projectID, relativePath string, content []byte, user string) error {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return err
 	}
 
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := s.reserveReplace(projectID, key, int64(len(content))); err != nil {
+		return err
+	}
+
+	if err := s.keepVersions(projectID, relativePath, user, domain.StorageVersionOverwrite); err != nil {
 		return err
 	}
 
-	return os.WriteFile(fullPath, content, 0644)
+	if err := s.driver.Put(key, bytes.NewReader(content), int64(len(content))); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
+	}
+
+	s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: relativePath, Size: int64(len(content))})
+	return nil
 }
 
 func (s *StorageService) Rename(projectID, oldPath, newPath string) error {
-	oldFullPath, err := s.resolvePath(projectID, oldPath)
+	return s.RenameAs(projectID, oldPath, newPath, RuntimeVersionUser)
+}
+
+// RenameAs renames a file or directory; user is recorded on the versions of
+// the files it replaces
+func (s *StorageService) RenameAs(projectID, oldPath, newPath, user string) error {
+	oldKey, err := s.objectKey(projectID, oldPath)
 	if err != nil {
 		return err
 	}
 
-	newFullPath, err := s.resolvePath(projectID, newPath)
+	newKey, err := s.objectKey(projectID, newPath)
 	if err != nil {
 		return err
 	}
 
+	if err := s.keepVersions(projectID, newPath, user, domain.StorageVersionOverwrite); err != nil {
+		return err
+	}
+
 	// Clean up resize cache for old path
 	s.cleanupResizeCache(projectID, oldPath)
 
-	return os.Rename(oldFullPath, newFullPath)
+	if err := s.driver.Rename(oldKey, newKey); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
+	}
+
+	s.emitStat(projectID, domain.StorageEventRename, newKey, newPath, oldPath)
+	return nil
 }
 
 func (s *StorageService) Delete(projectID, relativePath string) error {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+	return s.DeleteAs(projectID, relativePath, RuntimeVersionUser)
+}
+
+// DeleteAs deletes a file or directory; user is recorded on the versions of
+// the deleted files
+func (s *StorageService) DeleteAs(projectID, relativePath, user string) error {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return err
 	}
 
+	if err := s.keepVersions(projectID, relativePath, user, domain.StorageVersionDelete); err != nil {
+		return err
+	}
+
 	// Clean up resize cache
 	s.cleanupResizeCache(projectID, relativePath)
 
-	return os.RemoveAll(fullPath)
+	// Describe the file before it is gone
+	var event *domain.StorageEvent
+	if s.hasChangeHandlers() {
+		if info, err := s.driver.Stat(key); err == nil {
+			event = &domain.StorageEvent{Type: domain.StorageEventDelete, Path: relativePath, IsDir: info.IsDir}
+			if !info.IsDir {
+				event.Size = info.Size
+			}
+		}
+	}
+
+	if err := s.driver.Delete(key); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
+	}
+
+	if event != nil {
+		s.emit(projectID, *event)
+	}
+	return nil
+}
+
+// DeleteProject removes all files of a project, including caches
+func (s *StorageService) DeleteProject(projectID string) error {
+	key, err := projectKey(projectID)
+	if err != nil {
+		return err
+	}
+	return s.driver.Delete(key)
 }
 
 func (s *StorageService) Exists(projectID, relativePath string) bool {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return false
 	}
 
-	_, err = os.Stat(fullPath)
+	_, err = s.driver.Stat(key)
 	return err == nil
 }
 
-// GetPath returns the absolute filesystem path for a file in project storage
+// GetPath returns the filesystem path of a file in project storage. Only the
+// local driver has one: writes to a copy of a remote object would be lost.
 func (s *StorageService) GetPath(projectID, relativePath string) (string, error) {
-	return s.resolvePath(projectID, relativePath)
+	key, err := s.objectKey(projectID, relativePath)
+	if err != nil {
+		return "", err
+	}
+
+	localPath, ok := s.driver.LocalPath(key)
+	if !ok {
+		return "", ErrNoLocalPath
+	}
+	return localPath, nil
 }
 
-// Stat returns detailed file information
-func (s *StorageService) Stat(projectID, relativePath string) (*FileInfo, error) {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// LocalCopy returns a filesystem path to read a file from. Remote drivers
+// download the file to a local cache, which is never synced back.
+func (s *StorageService) LocalCopy(projectID, relativePath string) (string, error) {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	info, err := os.Stat(fullPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrFileNotFound
+	if localPath, ok := s.driver.LocalPath(key); ok {
+		if _, err := os.Stat(localPath); err != nil {
+			return "", ErrFileNotFound
 		}
-		return nil, err
+		return localPath, nil
 	}
 
-	fileInfo := &FileInfo{
-		Name:      info.Name(),
-		Path:      relativePath,
-		IsDir:     info.IsDir(),
-		Size:      info.Size(),
-		UpdatedAt: info.ModTime(),
+	reader, err := s.driver.Open(key)
+	if err != nil {
+		return "", mapDriverError(err, ErrFileNotFound)
 	}
+	defer reader.Close()
 
-	if !info.IsDir() {
-		fileInfo.MimeType = getMimeType(info.Name())
-		fileInfo.URL = fmt.Sprintf("%s/cdn/%s%s", s.config.Server.URI, projectID, relativePath)
-		fileInfo.DownloadURL = fmt.Sprintf("%s/cdn/download/%s%s", s.config.Server.URI, projectID, relativePath)
-		if strings.HasPrefix(fileInfo.MimeType, "image/") {
-			fileInfo.ThumbURL = fmt.Sprintf("%s/cdn/resize/64x64/%s%s", s.config.Server.URI, projectID, relativePath)
-		}
+	cachePath := filepath.Join(os.TempDir(), "m3m-storage", filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return "", err
+	}
+	// Download next to the cache path and rename, so concurrent readers never
+	// see a partial file
+	cacheFile, err := os.CreateTemp(filepath.Dir(cachePath), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(cacheFile, reader)
+	if closeErr := cacheFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(cacheFile.Name(), cachePath)
+	}
+	if err != nil {
+		os.Remove(cacheFile.Name())
+		return "", err
 	}
 
-	return fileInfo, nil
+	return cachePath, nil
 }
 
-// Append adds content to the end of a file
-func (s *StorageService) Append(projectID, relativePath string, content []byte) error {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// Stat returns detailed file information
+func (s *StorageService) Stat(projectID, relativePath string) (*FileInfo, error) {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
-		return err
-	}
-
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+		return nil, err
 	}
 
-	f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	object, err := s.driver.Stat(key)
 	if err != nil {
-		return err
+		return nil, mapDriverError(err, ErrFileNotFound)
 	}
-	defer f.Close()
 
-	_, err = f.Write(content)
-	return err
+	fileInfo := s.fileInfo(projectID, relativePath, *object)
+	return &fileInfo, nil
 }
 
-// Copy copies a file or directory
-func (s *StorageService) Copy(projectID, srcPath, dstPath string) error {
-	srcFullPath, err := s.resolvePath(projectID, srcPath)
+// Append adds content to the end of a file
+func (s *StorageService) Append(projectID, relativePath string, content []byte) error {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
 		return err
 	}
 
-	dstFullPath, err := s.resolvePath(projectID, dstPath)
-	if err != nil {
+	if err := s.reserve(projectID, int64(len(content))); err != nil {
 		return err
 	}
 
-	srcInfo, err := os.Stat(srcFullPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return ErrFileNotFound
-		}
+	if err := s.keepVersions(projectID, relativePath, RuntimeVersionUser, domain.StorageVersionAppend); err != nil {
 		return err
 	}
 
-	if srcInfo.IsDir() {
-		return s.copyDir(srcFullPath, dstFullPath)
+	if err := s.driver.Append(key, content); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
 	}
-	return s.copyFile(srcFullPath, dstFullPath)
-}
 
-func (s *StorageService) copyFile(src, dst string) error {
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return err
-	}
+	s.emitStat(projectID, domain.StorageEventWrite, key, relativePath, "")
+	return nil
+}
 
-	srcFile, err := os.Open(src)
+// Copy copies a file or directory
+func (s *StorageService) Copy(projectID, srcPath, dstPath string) error {
+	srcKey, err := s.objectKey(projectID, srcPath)
 	if err != nil {
 		return err
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	dstKey, err := s.objectKey(projectID, dstPath)
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
-}
-
-func (s *StorageService) copyDir(src, dst string) error {
-	if err := os.MkdirAll(dst, 0755); err != nil {
-		return err
+	if s.quota != nil {
+		var size int64
+		err := s.driver.Walk(srcKey, func(info storage.ObjectInfo) error {
+			size += info.Size
+			return nil
+		})
+		if err != nil {
+			return mapDriverError(err, ErrFileNotFound)
+		}
+		if err := s.reserve(projectID, size); err != nil {
+			return err
+		}
 	}
 
-	entries, err := os.ReadDir(src)
-	if err != nil {
+	if err := s.keepVersions(projectID, dstPath, RuntimeVersionUser, domain.StorageVersionOverwrite); err != nil {
 		return err
 	}
 
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			if err := s.copyDir(srcPath, dstPath); err != nil {
-				return err
-			}
-		} else {
-			if err := s.copyFile(srcPath, dstPath); err != nil {
-				return err
-			}
-		}
+	if err := s.driver.Copy(srcKey, dstKey); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
 	}
 
+	// Copied directories report a write for every file
+	if s.hasChangeHandlers() {
+		s.driver.Walk(dstKey, func(info storage.ObjectInfo) error {
+			rel, _ := storage.RelKey(dstKey, info.Key)
+			s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: path.Join(dstPath, rel), Size: info.Size})
+			return nil
+		})
+	}
 	return nil
 }
 
 // Move moves a file or directory
 func (s *StorageService) Move(projectID, srcPath, dstPath string) error {
-	srcFullPath, err := s.resolvePath(projectID, srcPath)
+	srcKey, err := s.objectKey(projectID, srcPath)
 	if err != nil {
 		return err
 	}
 
-	dstFullPath, err := s.resolvePath(projectID, dstPath)
+	dstKey, err := s.objectKey(projectID, dstPath)
 	if err != nil {
 		return err
 	}
 
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(dstFullPath), 0755); err != nil {
+	if err := s.keepVersions(projectID, dstPath, RuntimeVersionUser, domain.StorageVersionOverwrite); err != nil {
 		return err
 	}
 
 	// Clean up resize cache for source path
 	s.cleanupResizeCache(projectID, srcPath)
 
-	return os.Rename(srcFullPath, dstFullPath)
+	if err := s.driver.Rename(srcKey, dstKey); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
+	}
+
+	s.emitStat(projectID, domain.StorageEventRename, dstKey, dstPath, srcPath)
+	return nil
 }
 
-// Glob returns files matching a glob pattern
+// Glob returns files and directories matching a glob pattern, with the
+// semantics of filepath.Glob
 func (s *StorageService) Glob(projectID, pattern string) ([]string, error) {
-	basePath := s.getProjectStoragePath(projectID)
-	fullPattern := filepath.Join(basePath, pattern)
-
-	matches, err := filepath.Glob(fullPattern)
+	baseKey, err := s.objectKey(projectID, "")
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to relative paths
+	pattern = strings.Trim(strings.ReplaceAll(pattern, "\\", "/"), "/")
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		return []string{}, nil
+	}
+
+	// Expand the pattern one path element at a time
+	segments := strings.Split(pattern, "/")
+	matches := []string{""}
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		var next []string
+
+		for _, dir := range matches {
+			if !hasGlobMeta(segment) {
+				candidate := path.Join(dir, segment)
+				if last {
+					if _, err := s.driver.Stat(storage.JoinKey(baseKey, candidate)); err != nil {
+						continue
+					}
+				}
+				next = append(next, candidate)
+				continue
+			}
+
+			objects, err := s.driver.List(storage.JoinKey(baseKey, dir))
+			if err != nil {
+				continue
+			}
+			for _, object := range objects {
+				if ok, _ := path.Match(segment, object.Name()); ok {
+					next = append(next, path.Join(dir, object.Name()))
+				}
+			}
+		}
+
+		matches = next
+	}
+
 	result := make([]string, 0, len(matches))
 	for _, match := range matches {
-		relPath, err := filepath.Rel(basePath, match)
-		if err != nil {
-			continue
-		}
-		result = append(result, relPath)
+		result = append(result, filepath.FromSlash(match))
 	}
+	sort.Strings(result)
 
 	return result, nil
 }
 
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
+
 // Zip creates a zip archive from source paths
 func (s *StorageService) Zip(projectID string, srcPaths []string, dstPath string) error {
-	dstFullPath, err := s.resolvePath(projectID, dstPath)
+	dstKey, err := s.objectKey(projectID, dstPath)
 	if err != nil {
 		return err
 	}
 
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(dstFullPath), 0755); err != nil {
-		return err
-	}
-
-	zipFile, err := os.Create(dstFullPath)
-	if err != nil {
-		return err
+	type source struct {
+		key, archivePath string
+		isDir            bool
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
+	sources := make([]source, 0, len(srcPaths))
 	for _, srcPath := range srcPaths {
-		srcFullPath, err := s.resolvePath(projectID, srcPath)
+		srcKey, err := s.objectKey(projectID, srcPath)
 		if err != nil {
 			return err
 		}
 
-		info, err := os.Stat(srcFullPath)
+		info, err := s.driver.Stat(srcKey)
 		if err != nil {
-			return err
+			return mapDriverError(err, ErrFileNotFound)
 		}
-
-		if info.IsDir() {
-			if err := s.addDirToZip(zipWriter, srcFullPath, srcPath); err != nil {
-				return err
+		sources = append(sources, source{key: srcKey, archivePath: srcPath, isDir: info.IsDir})
+	}
+
+	// Stream the archive into the driver while it is written
+	pr, pw := io.Pipe()
+	go func() {
+		zipWriter := zip.NewWriter(pw)
+		var err error
+		for _, src := range sources {
+			if src.isDir {
+				err = s.driver.Walk(src.key, func(info storage.ObjectInfo) error {
+					rel, _ := storage.RelKey(src.key, info.Key)
+					return s.addFileToZip(zipWriter, info, filepath.ToSlash(filepath.Join(src.archivePath, rel)))
+				})
+			} else {
+				var info *storage.ObjectInfo
+				if info, err = s.driver.Stat(src.key); err == nil {
+					err = s.addFileToZip(zipWriter, *info, src.archivePath)
+				}
 			}
-		} else {
-			if err := s.addFileToZip(zipWriter, srcFullPath, srcPath); err != nil {
-				return err
+			if err != nil {
+				break
 			}
 		}
+		if err == nil {
+			err = zipWriter.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err = s.driver.Put(dstKey, pr, -1)
+	pr.CloseWithError(err)
+	if err != nil {
+		return mapDriverError(err, ErrFileNotFound)
 	}
 
+	s.emitStat(projectID, domain.StorageEventWrite, dstKey, dstPath, "")
 	return nil
 }
 
-func (s *StorageService) addFileToZip(zipWriter *zip.Writer, filePath, archivePath string) error {
-	file, err := os.Open(filePath)
+func (s *StorageService) addFileToZip(zipWriter *zip.Writer, object storage.ObjectInfo, archivePath string) error {
+	file, err := s.driver.Open(object.Key)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	info, err := file.Stat()
-	if err != nil {
-		return err
-	}
-
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return err
+	header := &zip.FileHeader{
+		Name:     archivePath,
+		Method:   zip.Deflate,
+		Modified: object.ModTime,
 	}
-	header.Name = archivePath
-	header.Method = zip.Deflate
+	header.SetMode(0644)
 
 	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
@@ -493,87 +817,174 @@ func (s *StorageService) addFileToZip(zipWriter *zip.Writer, filePath, archivePa
 	return err
 }
 
-func (s *StorageService) addDirToZip(zipWriter *zip.Writer, dirPath, archivePath string) error {
-	entries, err := os.ReadDir(dirPath)
+// openZip opens a stored zip archive. Files of remote drivers are copied to
+// a temporary file first, since reading an archive needs random access.
+func (s *StorageService) openZip(key string) (*zip.Reader, func(), error) {
+	if localPath, ok := s.driver.LocalPath(key); ok {
+		reader, err := zip.OpenReader(localPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil, ErrFileNotFound
+			}
+			return nil, nil, err
+		}
+		return &reader.Reader, func() { reader.Close() }, nil
+	}
+
+	src, err := s.driver.Open(key)
 	if err != nil {
-		return err
+		return nil, nil, mapDriverError(err, ErrFileNotFound)
 	}
+	defer src.Close()
 
-	for _, entry := range entries {
-		entryPath := filepath.Join(dirPath, entry.Name())
-		entryArchivePath := filepath.Join(archivePath, entry.Name())
+	tmp, err := os.CreateTemp("", "m3m-unzip-*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
 
-		if entry.IsDir() {
-			if err := s.addDirToZip(zipWriter, entryPath, entryArchivePath); err != nil {
-				return err
-			}
-		} else {
-			if err := s.addFileToZip(zipWriter, entryPath, entryArchivePath); err != nil {
-				return err
-			}
-		}
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
 	}
 
-	return nil
+	reader, err := zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return reader, cleanup, nil
 }
 
 // Unzip extracts a zip archive
 func (s *StorageService) Unzip(projectID, srcPath, dstPath string) error {
-	srcFullPath, err := s.resolvePath(projectID, srcPath)
+	srcKey, err := s.objectKey(projectID, srcPath)
 	if err != nil {
 		return err
 	}
 
-	dstFullPath, err := s.resolvePath(projectID, dstPath)
+	dstKey, err := s.objectKey(projectID, dstPath)
 	if err != nil {
 		return err
 	}
 
-	reader, err := zip.OpenReader(srcFullPath)
+	reader, closeReader, err := s.openZip(srcKey)
 	if err != nil {
 		return err
 	}
-	defer reader.Close()
+	defer closeReader()
 
+	var size int64
 	for _, file := range reader.File {
-		filePath := filepath.Join(dstFullPath, file.Name)
+		size += int64(file.UncompressedSize64)
+	}
+	if err := s.reserve(projectID, size); err != nil {
+		return err
+	}
+
+	for _, file := range reader.File {
+		key := storage.JoinKey(dstKey, file.Name)
 
 		// Check for path traversal
-		if !strings.HasPrefix(filePath, filepath.Clean(dstFullPath)+string(os.PathSeparator)) {
+		if rel, ok := storage.RelKey(dstKey, path.Join(dstKey, file.Name)); !ok || rel == "" {
 			return ErrInvalidPath
 		}
 
 		if file.FileInfo().IsDir() {
-			if err := os.MkdirAll(filePath, 0755); err != nil {
+			if err := s.driver.MkDir(key); err != nil {
 				return err
 			}
 			continue
 		}
 
-		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		if err := s.keepVersions(projectID, path.Join(dstPath, file.Name), RuntimeVersionUser, domain.StorageVersionOverwrite); err != nil {
 			return err
 		}
 
-		dstFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+		srcFile, err := file.Open()
 		if err != nil {
 			return err
 		}
 
-		srcFile, err := file.Open()
+		err = s.driver.Put(key, srcFile, int64(file.UncompressedSize64))
+		srcFile.Close()
+
 		if err != nil {
-			dstFile.Close()
 			return err
 		}
 
-		_, err = io.Copy(dstFile, srcFile)
-		srcFile.Close()
-		dstFile.Close()
+		s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: path.Join(dstPath, file.Name), Size: int64(file.UncompressedSize64)})
+	}
 
-		if err != nil {
+	return nil
+}
+
+// ExportToZip adds all project storage files to zipWriter under prefix
+// and returns the number of files and their total size
+func (s *StorageService) ExportToZip(projectID string, zipWriter *zip.Writer, prefix string) (int, int64, error) {
+	baseKey, err := s.objectKey(projectID, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count int
+	var size int64
+	err = s.driver.Walk(baseKey, func(info storage.ObjectInfo) error {
+		rel, _ := storage.RelKey(baseKey, info.Key)
+		if err := s.addFileToZip(zipWriter, info, prefix+rel); err != nil {
 			return err
 		}
+		count++
+		size += info.Size
+		return nil
+	})
+	return count, size, err
+}
+
+// ImportZipFile extracts a single archive entry into project storage. Its
+// size counts against the storage quota, the reader of the archive fails on
+// entries longer than their header claims.
+func (s *StorageService) ImportZipFile(projectID, relativePath string, file *zip.File) error {
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil || rel == "" {
+		return ErrInvalidPath
 	}
 
+	key, err := s.objectKey(projectID, rel)
+	if err != nil {
+		return err
+	}
+
+	size := int64(file.UncompressedSize64)
+	if size < 0 {
+		return fmt.Errorf("%w: entry is too large", domain.ErrQuotaExceeded)
+	}
+	if err := s.reserveReplace(projectID, key, size); err != nil {
+		return err
+	}
+
+	if err := s.keepVersions(projectID, rel, RuntimeVersionUser, domain.StorageVersionOverwrite); err != nil {
+		s.release(projectID, size)
+		return err
+	}
+
+	srcFile, err := file.Open()
+	if err != nil {
+		s.release(projectID, size)
+		return err
+	}
+	defer srcFile.Close()
+
+	if err := s.driver.Put(key, srcFile, size); err != nil {
+		s.release(projectID, size)
+		return err
+	}
+
+	s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: rel, Size: size})
 	return nil
 }
 
@@ -585,165 +996,206 @@ func (s *StorageService) GetURL(projectID, relativePath string) string {
 	return fmt.Sprintf("%s/cdn/%s%s", s.config.Server.URI, projectID, relativePath)
 }
 
+// SignURL returns a CDN URL for a file that is valid until expiresAt,
+// pointing to the download endpoint if download is set
+func (s *StorageService) SignURL(projectID, relativePath string, expiresAt time.Time, download bool) string {
+	relativePath = domain.CleanStoragePath(relativePath)
+	endpoint := "cdn"
+	if download {
+		endpoint = "cdn/download"
+	}
+	return fmt.Sprintf("%s/%s/%s%s?%s", s.config.Server.URI, endpoint, projectID, relativePath, s.signatureQuery(projectID, relativePath, expiresAt, download))
+}
+
+// SignFileInfo replaces the CDN URLs of a file with signed ones and marks it private
+func (s *StorageService) SignFileInfo(projectID string, info *FileInfo, expiresAt time.Time) {
+	info.Private = true
+	if info.IsDir {
+		return
+	}
+	query := "?" + s.signatureQuery(projectID, info.Path, expiresAt, false)
+	if info.URL != "" {
+		info.URL += query
+	}
+	if info.DownloadURL != "" {
+		info.DownloadURL += "?" + s.signatureQuery(projectID, info.Path, expiresAt, true)
+	}
+	if info.ThumbURL != "" {
+		info.ThumbURL += query
+	}
+}
+
+// VerifySignature checks the expires and sig query values of a signed CDN URL.
+// download tells whether the URL is for the download endpoint.
+func (s *StorageService) VerifySignature(projectID, relativePath string, download bool, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return ErrSignatureInvalid
+	}
+
+	expected := s.sign(projectID, domain.CleanStoragePath(relativePath), download, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func (s *StorageService) signatureQuery(projectID, relativePath string, expiresAt time.Time, download bool) string {
+	expires := expiresAt.Unix()
+	values := url.Values{}
+	values.Set("expires", strconv.FormatInt(expires, 10))
+	values.Set("sig", s.sign(projectID, domain.CleanStoragePath(relativePath), download, expires))
+	return values.Encode()
+}
+
+// sign computes the signature of a file path; it covers the project, the
+// cleaned path, whether it is a download and the expiry. An inline link works
+// for every inline CDN variant of the file (original and resized).
+func (s *StorageService) sign(projectID, cleanPath string, download bool, expires int64) string {
+	key := []byte(s.config.Storage.SigningSecret)
+	if len(key) == 0 {
+		key = derivedKey(s.config.JWT.Secret, "m3m-storage-url")
+	}
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", projectID, cleanPath, disposition, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// derivedKey derives a key for one purpose from a shared secret, so a
+// signature made with it can't be replayed as a token signed by the secret
+func derivedKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 // cleanupResizeCache removes all cached resized versions of a file or directory
 func (s *StorageService) cleanupResizeCache(projectID, relativePath string) {
-	tmpDir := filepath.Join(s.config.Storage.Path, projectID, "tmp", "resize")
+	root, err := projectKey(projectID)
+	if err != nil {
+		return
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil {
+		return
+	}
 
-	// Read all size directories (e.g., 64x64, 100x100)
-	entries, err := os.ReadDir(tmpDir)
+	// Read all transform directories (e.g., 64x64, 100x100,fit=cover)
+	sizeDirs, err := s.driver.List(storage.JoinKey(root, "tmp", "resize"))
 	if err != nil {
 		return // No cache directory exists
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	for _, sizeDir := range sizeDirs {
+		if !sizeDir.IsDir {
 			continue
 		}
-		cachePath := filepath.Join(tmpDir, entry.Name(), relativePath)
-		os.RemoveAll(cachePath) // Remove file or directory
+		key := storage.JoinKey(sizeDir.Key, rel)
+		s.driver.Delete(key) // Remove file or directory
+		s.resizeCache.forget(key)
 	}
 }
 
-// GetResizedImage returns path to resized image, creating it if needed
-// Images are cached in /storage/{project-id}/tmp/resize/{WxH}/...
-func (s *StorageService) GetResizedImage(projectID, relativePath string, width, height int) (string, error) {
-	// Build cache path
-	sizeDir := fmt.Sprintf("%dx%d", width, height)
-	cacheDir := filepath.Join(s.config.Storage.Path, projectID, "tmp", "resize", sizeDir)
-	cachePath := filepath.Join(cacheDir, relativePath)
-
-	// Check if cached version exists
-	if _, err := os.Stat(cachePath); err == nil {
-		return cachePath, nil
+// OpenTransformedImage opens a transformed version of an image, creating it if needed.
+// Images are cached under {project-id}/tmp/resize/{options}/... where options is
+// the canonical form of the transform, e.g. "64x64" or "800x600,fit=cover,fmt=webp".
+func (s *StorageService) OpenTransformedImage(projectID, relativePath string, opts imaging.Options) (*StorageObject, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Get original file
-	originalPath, err := s.resolvePath(projectID, relativePath)
+	// The output format follows the extension, so cached images keep their type
+	format := opts.OutputFormat(imaging.FormatFromPath(relativePath))
+
+	cacheKey, err := s.resizeCacheKey(projectID, relativePath, opts.String())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if _, err := os.Stat(originalPath); os.IsNotExist(err) {
-		return "", ErrFileNotFound
+	// Check if cached version exists
+	if object, err := s.openObject(cacheKey); err == nil {
+		s.resizeCache.touch(cacheKey)
+		object.MimeType = imaging.MimeType(format)
+		return object, nil
 	}
 
-	// Open and decode original image
-	file, err := os.Open(originalPath)
+	// Get original file
+	originalKey, err := s.objectKey(projectID, relativePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer file.Close()
 
-	img, format, err := image.Decode(file)
+	// Open and decode original image
+	file, err := s.driver.Open(originalKey)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	// Calculate new dimensions (object-contain - fit within bounds, preserve aspect ratio)
-	origBounds := img.Bounds()
-	origWidth := origBounds.Dx()
-	origHeight := origBounds.Dy()
-
-	ratio := float64(origWidth) / float64(origHeight)
-	targetRatio := float64(width) / float64(height)
-
-	var newWidth, newHeight int
-	if ratio > targetRatio {
-		// Image is wider - fit by width
-		newWidth = width
-		newHeight = int(float64(width) / ratio)
-	} else {
-		// Image is taller - fit by height
-		newHeight = height
-		newWidth = int(float64(height) * ratio)
+		return nil, mapDriverError(err, ErrFileNotFound)
 	}
+	defer file.Close()
 
-	if newWidth < 1 {
-		newWidth = 1
+	img, _, err := imaging.DecodeLimited(file, imaging.MaxPixels)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return nil, err
 	}
-	if newHeight < 1 {
-		newHeight = 1
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	// Create resized image
-	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-	draw.CatmullRom.Scale(resized, resized.Bounds(), img, origBounds, draw.Over, nil)
-
-	// Create cache directory
-	cacheFileDir := filepath.Dir(cachePath)
-	if err := os.MkdirAll(cacheFileDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.Transform(img, opts), format, opts.Quality); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
 	}
 
 	// Save to cache
-	outFile, err := os.Create(cachePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cache file: %w", err)
-	}
-	defer outFile.Close()
-
-	switch format {
-	case "png":
-		err = png.Encode(outFile, resized)
-	case "gif":
-		err = png.Encode(outFile, resized) // Convert GIF to PNG for simplicity
-	default:
-		err = jpeg.Encode(outFile, resized, &jpeg.Options{Quality: 85})
+	if err := s.driver.Put(cacheKey, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
 	}
+	s.resizeCache.add(cacheKey, int64(buf.Len()))
 
+	object, err := s.openObject(cacheKey)
 	if err != nil {
-		os.Remove(cachePath)
-		return "", fmt.Errorf("failed to encode image: %w", err)
+		return nil, err
 	}
-
-	return cachePath, nil
+	object.MimeType = imaging.MimeType(format)
+	return object, nil
 }
 
-func (s *StorageService) GenerateThumbnail(projectID, relativePath string, width, height int) ([]byte, error) {
-	fullPath, err := s.resolvePath(projectID, relativePath)
+// GenerateThumbnail stretches an image to width x height and returns it
+// encoded as PNG for PNG sources and JPEG otherwise
+func (s *StorageService) GenerateThumbnail(projectID, relativePath string, width, height int) ([]byte, string, error) {
+	key, err := s.objectKey(projectID, relativePath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	file, err := os.Open(fullPath)
+	file, err := s.driver.Open(key)
 	if err != nil {
-		return nil, err
+		return nil, "", mapDriverError(err, ErrFileNotFound)
 	}
 	defer file.Close()
 
-	img, format, err := image.Decode(file)
+	img, format, err := imaging.DecodeLimited(file, imaging.MaxPixels)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// Create thumbnail
-	thumb := image.NewRGBA(image.Rect(0, 0, width, height))
-	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, img.Bounds(), draw.Over, nil)
-
-	// Encode to bytes
-	var buf strings.Builder
-	writer := &writerAdapter{&buf}
-
-	switch format {
-	case "png":
-		err = png.Encode(writer, thumb)
-	default:
-		err = jpeg.Encode(writer, thumb, &jpeg.Options{Quality: 80})
+	thumb := imaging.Resize(img, width, height, imaging.FitFill)
+	if format != imaging.FormatPNG {
+		format = imaging.FormatJPEG
 	}
 
-	if err != nil {
-		return nil, err
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, thumb, format, 80); err != nil {
+		return nil, "", err
 	}
 
-	return []byte(buf.String()), nil
-}
-
-type writerAdapter struct {
-	builder *strings.Builder
-}
-
-func (w *writerAdapter) Write(p []byte) (n int, err error) {
-	return w.builder.Write(p)
+	return buf.Bytes(), imaging.MimeType(format), nil
 }
 
 func (s *StorageService) GetLogsPath(projectID string) string {
@@ -765,21 +1217,16 @@ func (s *StorageService) ClearLogs(projectID string) error {
 	return os.RemoveAll(logsPath)
 }
 
-// GetStorageSize calculates total size of project storage folder in bytes
+// GetStorageSize calculates total size of project storage in bytes
 func (s *StorageService) GetStorageSize(projectID string) (int64, error) {
-	storagePath := s.getProjectStoragePath(projectID)
+	baseKey, err := s.objectKey(projectID, "")
+	if err != nil {
+		return 0, err
+	}
 
 	var totalSize int64
-	err := filepath.Walk(storagePath, func(_ string, info os.FileInfo, err error) error {
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil // Directory doesn't exist yet, return 0
-			}
-			return err
-		}
-		if !info.IsDir() {
-			totalSize += info.Size()
-		}
+	err = s.driver.Walk(baseKey, func(info storage.ObjectInfo) error {
+		totalSize += info.Size
 		return nil
 	})
 
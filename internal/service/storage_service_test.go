@@ -0,0 +1,469 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/imaging"
+	"github.com/levskiy0/m3m/internal/storage"
+)
+
+func newTestStorageService(t *testing.T, cfg *config.Config) *StorageService {
+	t.Helper()
+	if cfg.Storage.Path == "" {
+		cfg.Storage.Path = t.TempDir()
+	}
+	driver, err := storage.NewLocalDriver(cfg.Storage.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStorageService(cfg, driver)
+}
+
+func TestStorageSignURL(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.URI = "http://localhost:3000"
+	cfg.JWT.Secret = "secret"
+	storageService := newTestStorageService(t, cfg)
+
+	signed := storageService.SignURL("p1", "docs/report.pdf", time.Now().Add(time.Minute), true)
+	if !strings.HasPrefix(signed, "http://localhost:3000/cdn/download/p1/docs/report.pdf?") {
+		t.Fatalf("unexpected signed URL: %s", signed)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, sig := u.Query().Get("expires"), u.Query().Get("sig")
+
+	// The CDN handlers receive the path without the leading slash
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", true, expires, sig); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+	if err := storageService.VerifySignature("p1", "docs/other.pdf", true, expires, sig); err != ErrSignatureInvalid {
+		t.Errorf("expected ErrSignatureInvalid for another path, got %v", err)
+	}
+	if err := storageService.VerifySignature("p2", "docs/report.pdf", true, expires, sig); err != ErrSignatureInvalid {
+		t.Errorf("expected ErrSignatureInvalid for another project, got %v", err)
+	}
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", true, "1", sig); err != ErrSignatureInvalid {
+		t.Errorf("expected ErrSignatureInvalid for a modified expiry, got %v", err)
+	}
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", false, expires, sig); err != ErrSignatureInvalid {
+		t.Errorf("expected ErrSignatureInvalid for an inline request of a download URL, got %v", err)
+	}
+
+	expired := storageService.SignURL("p1", "/docs/report.pdf", time.Now().Add(-time.Minute), false)
+	u, _ = url.Parse(expired)
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", false, u.Query().Get("expires"), u.Query().Get("sig")); err != ErrSignatureExpired {
+		t.Errorf("expected ErrSignatureExpired, got %v", err)
+	}
+
+	cfg.Storage.SigningSecret = cfg.JWT.Secret
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", true, expires, sig); err != ErrSignatureInvalid {
+		t.Errorf("expected the fallback key to be derived from the JWT secret, not to be it, got %v", err)
+	}
+
+	cfg.Storage.SigningSecret = "rotated"
+	if err := storageService.VerifySignature("p1", "docs/report.pdf", true, expires, sig); err != ErrSignatureInvalid {
+		t.Errorf("expected signatures to depend on the signing secret, got %v", err)
+	}
+}
+
+func TestProjectIsStoragePathPrivate(t *testing.T) {
+	project := &domain.Project{PrivatePaths: []string{"/invoices", "exports/report.csv"}}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"invoices", true},
+		{"/invoices/2025/01.pdf", true},
+		{"invoices-old/01.pdf", false},
+		{"exports/report.csv", true},
+		{"exports/other.csv", false},
+		{"../invoices/01.pdf", true},
+		{"public/logo.png", false},
+	}
+	for _, tt := range tests {
+		if got := project.IsStoragePathPrivate(tt.path); got != tt.want {
+			t.Errorf("IsStoragePathPrivate(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	everything := &domain.Project{PrivatePaths: []string{"/"}}
+	if !everything.IsStoragePathPrivate("logo.png") {
+		t.Error("expected root private path to cover all files")
+	}
+}
+
+func TestStorageGlobAndZip(t *testing.T) {
+	storageService := newTestStorageService(t, &config.Config{})
+	for _, p := range []string{"a.txt", "b.log", "docs/c.txt", "docs/sub/d.txt"} {
+		if err := storageService.Write("p1", p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := storageService.Glob("p1", "*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(matches, ","); got != "a.txt" {
+		t.Errorf("unexpected matches for *.txt: %q", got)
+	}
+	matches, _ = storageService.Glob("p1", "*/*")
+	if got := strings.Join(matches, ","); got != "docs/c.txt,docs/sub" {
+		t.Errorf("unexpected matches for */*: %q", got)
+	}
+
+	if err := storageService.Zip("p1", []string{"docs", "a.txt"}, "out/archive.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageService.Unzip("p1", "out/archive.zip", "restored"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := storageService.Read("p1", "restored/docs/sub/d.txt")
+	if err != nil || string(data) != "docs/sub/d.txt" {
+		t.Errorf("unexpected restored content %q, %v", data, err)
+	}
+
+	if _, err := storageService.Read("p1", "../p2/storage/a.txt"); err != ErrInvalidPath {
+		t.Errorf("expected ErrInvalidPath for a path outside the project, got %v", err)
+	}
+	if _, err := storageService.Read("p1", "missing.txt"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestStorageTransformedImageCache(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.ResizeCacheMB = 1
+	storageService := newTestStorageService(t, cfg)
+	cache := storageService.resizeCache
+	cached := func(key string) bool {
+		_, err := storageService.driver.Stat(key)
+		return err == nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageService.Write("p1", "photo.png", buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func(spec string) *StorageObject {
+		t.Helper()
+		opts, err := imaging.ParseOptions(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		object, err := storageService.OpenTransformedImage("p1", "photo.png", opts)
+		if err != nil {
+			t.Fatalf("OpenTransformedImage(%q): %v", spec, err)
+		}
+		defer object.Close()
+		return object
+	}
+
+	if object := open("16x16,fit=cover,fmt=webp"); object.MimeType != "image/webp" {
+		t.Errorf("mime type = %q, want image/webp", object.MimeType)
+	}
+	if object := open("16x16"); object.MimeType != "image/png" {
+		t.Errorf("mime type = %q, want image/png", object.MimeType)
+	}
+	if !cached("p1/tmp/resize/16x16,fit=cover,fmt=webp/photo.png") || !cached("p1/tmp/resize/16x16/photo.png") {
+		t.Fatal("transformed images were not cached")
+	}
+
+	// Shrink the cache to the two images, then touch the older one and add a third
+	cache.limit = cache.total
+	open("16x16,fit=cover,fmt=webp")
+	open("32x32,gray")
+	if cached("p1/tmp/resize/16x16/photo.png") {
+		t.Error("least recently used image was not evicted")
+	}
+	if cache.total > cache.limit {
+		t.Errorf("cache size %d exceeds limit %d", cache.total, cache.limit)
+	}
+
+	// Deleting the original drops its cached versions
+	if err := storageService.Delete("p1", "photo.png"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 0 || cache.total != 0 {
+		t.Errorf("cache index not cleared: %d entries, %d bytes", len(cache.entries), cache.total)
+	}
+}
+
+func TestStorageChangeEvents(t *testing.T) {
+	storageService := newTestStorageService(t, &config.Config{})
+
+	var events []domain.StorageEvent
+	storageService.OnChange(func(event domain.StorageEvent) {
+		events = append(events, event)
+	})
+
+	steps := []func() error{
+		func() error { return storageService.Write("p1", "/docs/a.txt", []byte("hello")) },
+		func() error { return storageService.Append("p1", "docs/a.txt", []byte("!")) },
+		func() error { return storageService.Copy("p1", "docs", "backup") },
+		func() error { return storageService.Rename("p1", "docs/a.txt", "docs/b.txt") },
+		func() error { return storageService.Delete("p1", "backup") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	want := []domain.StorageEvent{
+		{ProjectID: "p1", Type: domain.StorageEventWrite, Path: "docs/a.txt", Size: 5, MimeType: "text/plain"},
+		{ProjectID: "p1", Type: domain.StorageEventWrite, Path: "docs/a.txt", Size: 6, MimeType: "text/plain"},
+		{ProjectID: "p1", Type: domain.StorageEventWrite, Path: "backup/a.txt", Size: 6, MimeType: "text/plain"},
+		{ProjectID: "p1", Type: domain.StorageEventRename, Path: "docs/b.txt", OldPath: "docs/a.txt", Size: 6, MimeType: "text/plain"},
+		{ProjectID: "p1", Type: domain.StorageEventDelete, Path: "backup", IsDir: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"uploads/*.jpg", "uploads/cat.jpg", true},
+		{"uploads/*.jpg", "uploads/2024/cat.jpg", false},
+		{"uploads/**/*.jpg", "uploads/cat.jpg", true},
+		{"uploads/**/*.jpg", "uploads/2024/05/cat.jpg", true},
+		{"/uploads/**", "uploads/a/b", true},
+		{"**", "any/file.txt", true},
+		{"*.txt", "docs/a.txt", false},
+	} {
+		if got := domain.MatchStoragePath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchStoragePath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+type staticVersioning []domain.StorageVersioning
+
+func (v staticVersioning) StorageVersionedPaths(string) []domain.StorageVersioning {
+	return v
+}
+
+func TestStorageVersions(t *testing.T) {
+	storageService := newTestStorageService(t, &config.Config{})
+	storageService.SetVersioning(staticVersioning{{Path: "/docs", MaxVersions: 3}})
+
+	for _, content := range []string{"v1", "v2", "v2", "v3"} {
+		if err := storageService.WriteAs("p1", "docs/a.txt", []byte(content), "dev@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Files outside versioned directories are overwritten in place
+	storageService.Write("p1", "other.txt", []byte("x"))
+	storageService.Write("p1", "other.txt", []byte("y"))
+	if list, _ := storageService.Versions("p1", "other.txt"); len(list.Versions) != 0 {
+		t.Errorf("unversioned file has %d versions", len(list.Versions))
+	}
+
+	list, err := storageService.Versions("p1", "/docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Versioning == nil || list.Versioning.Path != "/docs" {
+		t.Errorf("versioning = %+v, want /docs", list.Versioning)
+	}
+	var contents []string
+	for _, version := range list.Versions {
+		object, err := storageService.OpenVersion("p1", "docs/a.txt", version.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(object)
+		object.Close()
+		contents = append(contents, string(data))
+		if version.User != "dev@example.com" || version.Reason != domain.StorageVersionOverwrite {
+			t.Errorf("version = %+v", version)
+		}
+	}
+	if strings.Join(contents, ",") != "v2,v1" {
+		t.Errorf("versions = %v, want [v2 v1]", contents)
+	}
+
+	// Deleting keeps the content; identical content shares one blob
+	if err := storageService.Write("p1", "docs/copy.txt", []byte("v3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageService.DeleteAs("p1", "docs", "dev@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	deleted, _ := storageService.Versions("p1", "docs/copy.txt")
+	if len(deleted.Versions) != 1 || deleted.Versions[0].Reason != domain.StorageVersionDelete {
+		t.Fatalf("deleted versions = %+v", deleted.Versions)
+	}
+	blobs := 0
+	storageService.driver.Walk(versionsKey("p1", "blobs"), func(storage.ObjectInfo) error {
+		blobs++
+		return nil
+	})
+	if blobs != 3 {
+		t.Errorf("blobs = %d, want 3 (v1, v2, v3)", blobs)
+	}
+
+	// Restoring brings the file back; the retention policy trims the history
+	list, _ = storageService.Versions("p1", "docs/a.txt")
+	if err := storageService.RestoreVersion("p1", "docs/a.txt", list.Versions[len(list.Versions)-1].ID, "ops@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := storageService.Read("p1", "docs/a.txt"); string(data) != "v1" {
+		t.Errorf("restored content = %q, want v1", data)
+	}
+	if err := storageService.RestoreVersion("p1", "docs/a.txt", "missing", ""); err != ErrVersionNotFound {
+		t.Errorf("restore of unknown version: %v", err)
+	}
+	// Restoring over an existing file keeps it as a version first
+	if err := storageService.RestoreVersion("p1", "docs/a.txt", list.Versions[1].ID, "ops@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = storageService.Versions("p1", "docs/a.txt")
+	if len(list.Versions) != 3 || list.Versions[0].Reason != domain.StorageVersionRestore || list.Versions[0].User != "ops@example.com" {
+		t.Errorf("versions after restore = %+v", list.Versions)
+	}
+	if data, _ := storageService.Read("p1", "docs/a.txt"); string(data) != "v2" {
+		t.Errorf("restored content = %q, want v2", data)
+	}
+
+	purge, err := storageService.PurgeVersions("p1", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purge.Versions != 4 || purge.FreedBytes != 6 {
+		t.Errorf("purge = %+v, want 4 versions and 6 bytes", purge)
+	}
+	if list, _ := storageService.Versions("p1", "docs/a.txt"); len(list.Versions) != 0 {
+		t.Errorf("versions after purge = %+v", list.Versions)
+	}
+}
+
+func TestStorageAppendKeepsVersions(t *testing.T) {
+	storageService := newTestStorageService(t, &config.Config{})
+	storageService.SetVersioning(staticVersioning{{Path: "/logs"}})
+
+	storageService.Write("p1", "logs/app.log", []byte("a"))
+	if err := storageService.Append("p1", "logs/app.log", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	writer, err := storageService.Create("p1", "logs/app.log", "dev@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("c"))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	list, _ := storageService.Versions("p1", "logs/app.log")
+	if len(list.Versions) != 2 || list.Versions[0].Reason != domain.StorageVersionAppend || list.Versions[0].User != "dev@example.com" {
+		t.Fatalf("versions = %+v", list.Versions)
+	}
+	object, _ := storageService.OpenVersion("p1", "logs/app.log", list.Versions[0].ID)
+	data, _ := io.ReadAll(object)
+	object.Close()
+	if string(data) != "ab" {
+		t.Errorf("version before the streamed append = %q, want ab", data)
+	}
+}
+
+func TestStorageWriterQuota(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Quota = config.QuotaConfig{StorageMB: 2}
+	storageService := newTestStorageService(t, cfg)
+	quotaService := NewQuotaService(cfg, nil, storageService, nil, nil)
+	storageService.SetQuota(quotaService)
+
+	if err := storageService.Write("p1", "a.bin", make([]byte, 1<<20)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwriting reuses the replaced file's size, the rest fails in Write
+	writer, err := storageService.Create("p1", "a.bin", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 512<<10)
+	for i := range 4 {
+		if _, err := writer.Write(chunk); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	if _, err := writer.Write(chunk); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected the write past the quota to fail, got %v", err)
+	}
+	if writer.Size() != 2<<20 {
+		t.Errorf("spooled %d bytes, want %d", writer.Size(), 2<<20)
+	}
+	writer.Abort()
+
+	// An aborted writer gives its reservation back
+	if err := storageService.Write("p1", "b.bin", make([]byte, 1<<20)); err != nil {
+		t.Errorf("expected the write to fit after the abort, got %v", err)
+	}
+}
+
+func TestStorageImportZipFileQuota(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Quota = config.QuotaConfig{StorageMB: 1}
+	storageService := newTestStorageService(t, cfg)
+	storageService.SetQuota(NewQuotaService(cfg, nil, storageService, nil, nil))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.bin", "b.bin"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(make([]byte, 600<<10))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storageService.ImportZipFile("p1", "a.bin", zr.File[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := storageService.ImportZipFile("p1", "b.bin", zr.File[1]); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected the import past the quota to fail, got %v", err)
+	}
+	if storageService.Exists("p1", "b.bin") {
+		t.Error("the file past the quota was written")
+	}
+}
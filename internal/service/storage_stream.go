@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/storage"
+)
+
+// ErrWriterClosed is returned by writes to a closed StorageWriter
+var ErrWriterClosed = errors.New("writer is closed")
+
+// storageWriterReserveChunk is how much storage quota a StorageWriter
+// reserves at a time, so small writes do not each check the quota
+const storageWriterReserveChunk = 1 << 20
+
+// OpenRange opens length bytes of a file from offset for reading; a negative
+// length reads to the end. Ranges past the end of the file are cut short.
+func (s *StorageService) OpenRange(projectID, relativePath string, offset, length int64) (*StorageObject, error) {
+	key, err := s.objectKey(projectID, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, ErrInvalidPath
+	}
+
+	info, err := s.driver.Stat(key)
+	if err != nil {
+		return nil, mapDriverError(err, ErrFileNotFound)
+	}
+	if info.IsDir {
+		return nil, ErrFileNotFound
+	}
+
+	if offset > info.Size {
+		offset = info.Size
+	}
+	if length < 0 || offset+length > info.Size {
+		length = info.Size - offset
+	}
+
+	reader, err := storage.OpenRange(s.driver, key, offset, length)
+	if err != nil {
+		return nil, mapDriverError(err, ErrFileNotFound)
+	}
+
+	return &StorageObject{
+		ReadCloser: reader,
+		Name:       info.Name(),
+		Size:       length,
+		MimeType:   getMimeType(info.Name()),
+		ModTime:    info.ModTime,
+	}, nil
+}
+
+// StorageWriter streams content into a file of project storage. Content is
+// spooled to a temporary file and stored on Close, so readers never see a
+// partial file and versioning and change events apply once per file. Quota
+// is reserved while writing, so the spool never outgrows it.
+type StorageWriter struct {
+	service   *StorageService
+	projectID string
+	path      string
+	key       string
+	user      string
+	append    bool
+	tmp       *os.File
+	size      int64
+	replaced  int64 // Size of the file an overwrite replaces, it needs no new quota
+	reserved  int64 // Quota reserved so far
+	closed    bool
+}
+
+// Create opens a writer for a file; user is recorded on the version it replaces.
+// With appendMode set the content is added to the end of the file instead.
+func (s *StorageService) Create(projectID, relativePath, user string, appendMode bool) (*StorageWriter, error) {
+	key, err := s.objectKey(projectID, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	var replaced int64
+	if info, err := s.driver.Stat(key); err == nil {
+		if info.IsDir {
+			return nil, ErrInvalidPath
+		}
+		if !appendMode {
+			replaced = info.Size
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "m3m-write-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageWriter{
+		service:   s,
+		projectID: projectID,
+		path:      relativePath,
+		key:       key,
+		user:      user,
+		append:    appendMode,
+		tmp:       tmp,
+		replaced:  replaced,
+	}, nil
+}
+
+// Write adds p to the pending content. It fails without writing once the
+// content no longer fits into the storage quota.
+func (w *StorageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if err := w.reserve(int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.tmp.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// reserve reserves quota for n more bytes past the size of the replaced file
+func (w *StorageWriter) reserve(n int64) error {
+	needed := w.size + n - w.replaced - w.reserved
+	if needed <= 0 {
+		return nil
+	}
+
+	s := w.service
+	chunk := max(needed, storageWriterReserveChunk)
+	err := s.reserve(w.projectID, chunk)
+	if err != nil && chunk > needed && errors.Is(err, domain.ErrQuotaExceeded) {
+		// The last bytes may still fit without the chunk's headroom
+		chunk = needed
+		err = s.reserve(w.projectID, chunk)
+	}
+	if err != nil {
+		return err
+	}
+	w.reserved += chunk
+	return nil
+}
+
+// Size returns the number of bytes written so far
+func (w *StorageWriter) Size() int64 {
+	return w.size
+}
+
+// Close stores the written content in the file
+func (w *StorageWriter) Close() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	defer w.Abort()
+
+	s := w.service
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if w.append {
+		if err := s.keepVersions(w.projectID, w.path, w.user, domain.StorageVersionAppend); err != nil {
+			return err
+		}
+		if err := w.appendTo(); err != nil {
+			return mapDriverError(err, ErrFileNotFound)
+		}
+		w.keepReserved()
+		s.emitStat(w.projectID, domain.StorageEventWrite, w.key, w.path, "")
+		return nil
+	}
+
+	if err := s.keepVersions(w.projectID, w.path, w.user, domain.StorageVersionOverwrite); err != nil {
+		return err
+	}
+	s.cleanupResizeCache(w.projectID, w.path)
+	if err := s.driver.Put(w.key, w.tmp, w.size); err != nil {
+		return mapDriverError(err, ErrFileNotFound)
+	}
+	w.keepReserved()
+
+	s.emit(w.projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: w.path, Size: w.size})
+	return nil
+}
+
+// keepReserved marks the quota of the stored content as used, leaving only
+// the unused headroom of the last chunk for Abort to release
+func (w *StorageWriter) keepReserved() {
+	w.reserved -= min(w.reserved, max(w.size-w.replaced, 0))
+}
+
+// appendTo adds the spooled content to the end of the file. Local files are
+// appended in place; other drivers rewrite the object from both parts.
+func (w *StorageWriter) appendTo() error {
+	driver := w.service.driver
+
+	if localPath, ok := driver.LocalPath(w.key); ok {
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(localPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, w.tmp)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	info, err := driver.Stat(w.key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return driver.Put(w.key, w.tmp, w.size)
+		}
+		return err
+	}
+	existing, err := driver.Open(w.key)
+	if err != nil {
+		return err
+	}
+	defer existing.Close()
+	return driver.Put(w.key, io.MultiReader(existing, w.tmp), info.Size+w.size)
+}
+
+// Abort discards the written content without touching the file and gives
+// back the quota reserved for it
+func (w *StorageWriter) Abort() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.service.release(w.projectID, w.reserved)
+	w.tmp.Close()
+	return os.Remove(w.tmp.Name())
+}
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/storage"
+)
+
+// ErrVersionNotFound is returned for an unknown version of a file
+var ErrVersionNotFound = errors.New("version not found")
+
+// RuntimeVersionUser is recorded as the user of versions kept by $storage
+const RuntimeVersionUser = "runtime"
+
+// VersioningPolicy returns the versioned storage directories of a project.
+// StorageService receives it after construction, since ProjectService depends on it.
+type VersioningPolicy interface {
+	StorageVersionedPaths(projectID string) []domain.StorageVersioning
+}
+
+// Versions are kept under {project-id}/versions:
+//
+//	blobs/{hash[:2]}/{hash}  file contents, stored once per SHA-256
+//	index/{path}.json        version history of a file, newest first
+//	refs.json                number of versions referring to each blob
+
+// SetVersioning enables file versioning for the directories the policy reports
+func (s *StorageService) SetVersioning(policy VersioningPolicy) {
+	s.versioning = policy
+}
+
+// versionedPaths returns the versioned directories of a project, or nil when versioning is off
+func (s *StorageService) versionedPaths(projectID string) []domain.StorageVersioning {
+	if s.versioning == nil {
+		return nil
+	}
+	return s.versioning.StorageVersionedPaths(projectID)
+}
+
+func versionsKey(projectID string, elem ...string) string {
+	return storage.JoinKey(append([]string{projectID, "versions"}, elem...)...)
+}
+
+func versionBlobKey(projectID, hash string) string {
+	return versionsKey(projectID, "blobs", hash[:2], hash)
+}
+
+func versionIndexKey(projectID, rel string) string {
+	return versionsKey(projectID, "index", rel+".json")
+}
+
+// keepVersions stores the current content of a file, or of every file under a
+// directory, as a version before it is overwritten or deleted
+func (s *StorageService) keepVersions(projectID, relativePath, user string, reason domain.StorageVersionReason) error {
+	paths := s.versionedPaths(projectID)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil {
+		return err
+	}
+	key, err := s.objectKey(projectID, rel)
+	if err != nil {
+		return err
+	}
+
+	info, err := s.driver.Stat(key)
+	if err != nil {
+		return nil // Nothing to keep
+	}
+	if !info.IsDir {
+		return s.keepVersion(projectID, rel, key, domain.FindStorageVersioning(paths, rel), user, reason)
+	}
+
+	var files []storage.ObjectInfo
+	if err := s.driver.Walk(key, func(info storage.ObjectInfo) error {
+		files = append(files, info)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, file := range files {
+		fileRel, _ := storage.RelKey(key, file.Key)
+		fileRel = path.Join(rel, fileRel)
+		if err := s.keepVersion(projectID, fileRel, file.Key, domain.FindStorageVersioning(paths, fileRel), user, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepVersion adds the current content of a file to its version history
+func (s *StorageService) keepVersion(projectID, rel, key string, policy *domain.StorageVersioning, user string, reason domain.StorageVersionReason) error {
+	if policy == nil {
+		return nil
+	}
+
+	info, err := s.driver.Stat(key)
+	if err != nil || info.IsDir {
+		return nil
+	}
+	hash, err := s.hashObject(key)
+	if err != nil {
+		return err
+	}
+
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+
+	versions, err := s.loadVersions(projectID, rel)
+	if err != nil {
+		return err
+	}
+	// Writing the same content again adds nothing to the history
+	if len(versions) > 0 && versions[0].Hash == hash {
+		return nil
+	}
+
+	refs, err := s.loadVersionRefs(projectID)
+	if err != nil {
+		return err
+	}
+
+	blobKey := versionBlobKey(projectID, hash)
+	if _, err := s.driver.Stat(blobKey); err != nil {
+		if err := s.driver.Copy(key, blobKey); err != nil {
+			return err
+		}
+	}
+	refs[hash]++
+
+	versions = append([]domain.StorageVersion{{
+		ID:        uuid.NewString(),
+		Hash:      hash,
+		Size:      info.Size,
+		User:      user,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}}, versions...)
+
+	versions, _ = s.applyRetention(versions, policy, refs, false)
+	if err := s.saveVersions(projectID, rel, versions); err != nil {
+		return err
+	}
+	_, err = s.saveVersionRefs(projectID, refs)
+	return err
+}
+
+// applyRetention drops the versions the policy no longer keeps, or all of
+// them, and releases their blobs in refs
+func (s *StorageService) applyRetention(versions []domain.StorageVersion, policy *domain.StorageVersioning, refs map[string]int, all bool) ([]domain.StorageVersion, int) {
+	now := time.Now()
+	kept := versions[:0]
+	dropped := 0
+	for i, version := range versions {
+		if all || policy.Expired(version, i, now) {
+			refs[version.Hash]--
+			dropped++
+			continue
+		}
+		kept = append(kept, version)
+	}
+	return kept, dropped
+}
+
+func (s *StorageService) hashObject(key string) (string, error) {
+	reader, err := s.driver.Open(key)
+	if err != nil {
+		return "", mapDriverError(err, ErrFileNotFound)
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *StorageService) loadVersions(projectID, rel string) ([]domain.StorageVersion, error) {
+	var versions []domain.StorageVersion
+	if err := s.readVersionJSON(versionIndexKey(projectID, rel), &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (s *StorageService) saveVersions(projectID, rel string, versions []domain.StorageVersion) error {
+	key := versionIndexKey(projectID, rel)
+	if len(versions) == 0 {
+		if err := s.driver.Delete(key); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return s.writeVersionJSON(key, versions)
+}
+
+// loadVersionRefs reads the blob reference counts, rebuilding them from the
+// version histories when they are missing
+func (s *StorageService) loadVersionRefs(projectID string) (map[string]int, error) {
+	refs := make(map[string]int)
+	key := versionsKey(projectID, "refs.json")
+	if _, err := s.driver.Stat(key); err == nil {
+		return refs, s.readVersionJSON(key, &refs)
+	}
+
+	indexKey := versionsKey(projectID, "index")
+	err := s.driver.Walk(indexKey, func(info storage.ObjectInfo) error {
+		var versions []domain.StorageVersion
+		if err := s.readVersionJSON(info.Key, &versions); err != nil {
+			return err
+		}
+		for _, version := range versions {
+			refs[version.Hash]++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// saveVersionRefs stores the reference counts and deletes the blobs no
+// version refers to anymore, returning the bytes freed
+func (s *StorageService) saveVersionRefs(projectID string, refs map[string]int) (int64, error) {
+	var freed int64
+	for hash, count := range refs {
+		if count > 0 {
+			continue
+		}
+		blobKey := versionBlobKey(projectID, hash)
+		if info, err := s.driver.Stat(blobKey); err == nil {
+			freed += info.Size
+		}
+		if err := s.driver.Delete(blobKey); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			return freed, err
+		}
+		delete(refs, hash)
+	}
+	return freed, s.writeVersionJSON(versionsKey(projectID, "refs.json"), refs)
+}
+
+func (s *StorageService) readVersionJSON(key string, v interface{}) error {
+	reader, err := s.driver.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+	return json.NewDecoder(reader).Decode(v)
+}
+
+func (s *StorageService) writeVersionJSON(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.driver.Put(key, bytes.NewReader(data), int64(len(data)))
+}
+
+// Versions lists the kept versions of a file, newest first
+func (s *StorageService) Versions(projectID, relativePath string) (*domain.StorageVersionList, error) {
+	if _, err := projectKey(projectID); err != nil {
+		return nil, err
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil || rel == "" {
+		return nil, ErrInvalidPath
+	}
+
+	s.versionMu.Lock()
+	versions, err := s.loadVersions(projectID, rel)
+	s.versionMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil {
+		versions = []domain.StorageVersion{}
+	}
+
+	return &domain.StorageVersionList{
+		Path:       rel,
+		Versioning: domain.FindStorageVersioning(s.versionedPaths(projectID), rel),
+		Versions:   versions,
+	}, nil
+}
+
+func (s *StorageService) findVersion(projectID, rel, versionID string) (*domain.StorageVersion, error) {
+	s.versionMu.Lock()
+	versions, err := s.loadVersions(projectID, rel)
+	s.versionMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].ID == versionID {
+			return &versions[i], nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+// OpenVersion opens a kept version of a file for reading
+func (s *StorageService) OpenVersion(projectID, relativePath, versionID string) (*StorageObject, error) {
+	if _, err := projectKey(projectID); err != nil {
+		return nil, err
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil || rel == "" {
+		return nil, ErrInvalidPath
+	}
+
+	version, err := s.findVersion(projectID, rel, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := s.openObject(versionBlobKey(projectID, version.Hash))
+	if err != nil {
+		return nil, err
+	}
+	object.Name = path.Base(rel)
+	object.MimeType = getMimeType(rel)
+	object.ModTime = version.CreatedAt
+	return object, nil
+}
+
+// RestoreVersion replaces a file with one of its versions. The content it
+// replaces, if any, is kept as a new version first.
+func (s *StorageService) RestoreVersion(projectID, relativePath, versionID, user string) error {
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil || rel == "" {
+		return ErrInvalidPath
+	}
+	key, err := s.objectKey(projectID, rel)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.findVersion(projectID, rel, versionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.reserveReplace(projectID, key, version.Size); err != nil {
+		return err
+	}
+	if err := s.keepVersions(projectID, rel, user, domain.StorageVersionRestore); err != nil {
+		return err
+	}
+
+	s.cleanupResizeCache(projectID, rel)
+	if err := s.driver.Copy(versionBlobKey(projectID, version.Hash), key); err != nil {
+		return mapDriverError(err, ErrVersionNotFound)
+	}
+
+	s.emit(projectID, domain.StorageEvent{Type: domain.StorageEventWrite, Path: rel, Size: version.Size})
+	return nil
+}
+
+// PurgeVersions applies the retention policy to the versions of a file or of
+// every file under a directory ("" for the whole project). With all set every
+// version is dropped, including those of paths that are no longer versioned.
+func (s *StorageService) PurgeVersions(projectID, relativePath string, all bool) (*domain.StorageVersionPurge, error) {
+	if _, err := projectKey(projectID); err != nil {
+		return nil, err
+	}
+	rel, err := cleanRelativePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := s.versionedPaths(projectID)
+	indexKey := versionsKey(projectID, "index")
+
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+
+	// A path may name a file, a directory or both
+	var histories []string
+	if rel != "" {
+		if _, err := s.driver.Stat(versionIndexKey(projectID, rel)); err == nil {
+			histories = append(histories, rel)
+		}
+	}
+	err = s.driver.Walk(storage.JoinKey(indexKey, rel), func(info storage.ObjectInfo) error {
+		if historyRel, ok := storage.RelKey(indexKey, info.Key); ok && strings.HasSuffix(historyRel, ".json") {
+			histories = append(histories, strings.TrimSuffix(historyRel, ".json"))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return nil, err
+	}
+
+	result := &domain.StorageVersionPurge{}
+	if len(histories) == 0 {
+		return result, nil
+	}
+
+	refs, err := s.loadVersionRefs(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, historyRel := range histories {
+		versions, err := s.loadVersions(projectID, historyRel)
+		if err != nil {
+			return nil, err
+		}
+		policy := domain.FindStorageVersioning(paths, historyRel)
+		kept, dropped := s.applyRetention(versions, policy, refs, all)
+		if dropped == 0 {
+			continue
+		}
+		if err := s.saveVersions(projectID, historyRel, kept); err != nil {
+			return nil, err
+		}
+		result.Versions += dropped
+	}
+
+	result.FreedBytes, err = s.saveVersionRefs(projectID, refs)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
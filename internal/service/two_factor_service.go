@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"rsc.io/qr"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+	"github.com/levskiy0/m3m/internal/totp"
+)
+
+var (
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolling = errors.New("no two-factor enrollment in progress")
+	ErrTwoFactorRequired     = errors.New("two-factor authentication is required for this account")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+)
+
+// recoveryCodeCount is how many recovery codes a user gets
+const recoveryCodeCount = 10
+
+// recoveryCodeAlphabet leaves out characters that are easily confused
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// TwoFactorService manages TOTP two-factor authentication: enrollment,
+// recovery codes and checking codes on login
+type TwoFactorService struct {
+	userRepo *repository.UserRepository
+	config   *config.Config
+}
+
+func NewTwoFactorService(userRepo *repository.UserRepository, config *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo: userRepo,
+		config:   config,
+	}
+}
+
+// Required reports whether a user has to use 2FA, by the server policy or
+// because an admin required it
+func (s *TwoFactorService) Required(user *domain.User) bool {
+	if user.TwoFactor.Required {
+		return true
+	}
+	switch s.config.TwoFactor.Required {
+	case "all":
+		return true
+	case "root":
+		return user.IsRoot
+	}
+	return false
+}
+
+func (s *TwoFactorService) Status(user *domain.User) *domain.TwoFactorStatus {
+	return &domain.TwoFactorStatus{
+		Enabled:           user.TwoFactor.Enabled,
+		Required:          s.Required(user),
+		RecoveryCodesLeft: len(user.TwoFactor.RecoveryCodes),
+		EnabledAt:         user.TwoFactor.EnabledAt,
+	}
+}
+
+// Enroll starts setting up 2FA with a new secret. It takes effect once
+// Confirm gets a code generated from it.
+func (s *TwoFactorService) Enroll(ctx context.Context, user *domain.User) (*domain.TwoFactorEnrollment, error) {
+	if user.TwoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor.PendingSecret = secret
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	uri := totp.URI(s.config.TwoFactor.Issuer, user.Email, secret)
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()),
+	}, nil
+}
+
+// Confirm enables 2FA when the code matches the pending secret and returns
+// the recovery codes, which are not stored in plain text
+func (s *TwoFactorService) Confirm(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if user.TwoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TwoFactor.PendingSecret == "" {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+
+	now := time.Now()
+	step, ok := totp.Validate(user.TwoFactor.PendingSecret, code, now)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor = domain.TwoFactor{
+		Enabled:       true,
+		Required:      user.TwoFactor.Required,
+		Secret:        user.TwoFactor.PendingSecret,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+		EnabledAt:     &now,
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or a recovery code of a user with 2FA enabled.
+// Each code works once.
+func (s *TwoFactorService) Verify(ctx context.Context, user *domain.User, code string) error {
+	if !user.TwoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := totp.Validate(user.TwoFactor.Secret, code, time.Now()); ok {
+		used, err := s.userRepo.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		user.TwoFactor.LastStep = step
+		return nil
+	}
+
+	hash := hashRecoveryCode(code)
+	used, err := s.userRepo.UseRecoveryCode(ctx, user.ID, hash)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TwoFactor.RecoveryCodes = slices.DeleteFunc(user.TwoFactor.RecoveryCodes, func(h string) bool { return h == hash })
+	return nil
+}
+
+// Disable turns off 2FA after checking a code; users who are required to
+// use 2FA cannot turn it off
+func (s *TwoFactorService) Disable(ctx context.Context, user *domain.User, code string) error {
+	if s.Required(user) {
+		return ErrTwoFactorRequired
+	}
+	if err := s.Verify(ctx, user, code); err != nil {
+		return err
+	}
+
+	user.TwoFactor = domain.TwoFactor{Required: user.TwoFactor.Required}
+	return s.userRepo.Update(ctx, user)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if err := s.Verify(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor.RecoveryCodes = hashes
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset removes the 2FA of a user who lost their device. If 2FA is
+// required for them they enroll again on their next login.
+func (s *TwoFactorService) Reset(ctx context.Context, user *domain.User) error {
+	user.TwoFactor = domain.TwoFactor{Required: user.TwoFactor.Required}
+	return s.userRepo.Update(ctx, user)
+}
+
+// newRecoveryCodes returns recovery codes like "k7mx-p2qa-9vhe" and their hashes
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, recoveryCodeCount)
+	hashes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		var code strings.Builder
+		for j, c := range b {
+			if j > 0 && j%4 == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = code.String()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
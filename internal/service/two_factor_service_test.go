@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/config"
+	"github.com/levskiy0/m3m/internal/domain"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("%d codes, %d hashes", len(codes), len(hashes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if len(code) != 14 || strings.Count(code, "-") != 2 {
+			t.Errorf("code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+		if hashes[i] == code || hashes[i] != hashRecoveryCode(code) {
+			t.Errorf("hash of %q does not match", code)
+		}
+	}
+
+	// Codes are accepted however they are typed
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if hashRecoveryCode(typed) != hashes[0] {
+		t.Errorf("%q does not match %q", typed, codes[0])
+	}
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	root := &domain.User{IsRoot: true}
+	user := &domain.User{}
+	required := &domain.User{TwoFactor: domain.TwoFactor{Required: true}}
+
+	cases := map[string][3]bool{
+		"none": {false, false, true},
+		"root": {true, false, true},
+		"all":  {true, true, true},
+	}
+	for policy, want := range cases {
+		s := NewTwoFactorService(nil, &config.Config{TwoFactor: config.TwoFactorConfig{Required: policy}})
+		got := [3]bool{s.Required(root), s.Required(user), s.Required(required)}
+		if got != want {
+			t.Errorf("policy %s: root, user, required by admin = %v, want %v", policy, got, want)
+		}
+	}
+}
+
+func TestLoginSecondStep(t *testing.T) {
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{Required: "root"}}
+	s := NewAuthService(nil, nil, NewTwoFactorService(nil, cfg), nil, cfg)
+	ctx := context.Background()
+
+	enrolled := &domain.User{ID: primitive.NewObjectID(), TwoFactor: domain.TwoFactor{Enabled: true}}
+	resp, err := s.beginSession(ctx, enrolled, domain.SessionClient{})
+	if err != nil || resp.TwoFactor != domain.TwoFactorStepVerify || resp.Challenge == "" || resp.Token != "" {
+		t.Errorf("user with 2FA: %+v %v", resp, err)
+	}
+
+	root := &domain.User{ID: primitive.NewObjectID(), IsRoot: true}
+	resp, err = s.beginSession(ctx, root, domain.SessionClient{})
+	if err != nil || resp.TwoFactor != domain.TwoFactorStepEnroll || resp.Token != "" {
+		t.Errorf("root without 2FA: %+v %v", resp, err)
+	}
+
+	if _, err := s.VerifyTwoFactor(ctx, "unknown", "123456"); err != ErrLoginChallenge {
+		t.Errorf("unknown challenge: %v", err)
+	}
+}
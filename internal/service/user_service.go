@@ -18,12 +18,14 @@ var (
 
 type UserService struct {
 	userRepo    *repository.UserRepository
+	tokenRepo   *repository.AccessTokenRepository
 	authService *AuthService
 }
 
-func NewUserService(userRepo *repository.UserRepository, authService *AuthService) *UserService {
+func NewUserService(userRepo *repository.UserRepository, tokenRepo *repository.AccessTokenRepository, authService *AuthService) *UserService {
 	return &UserService{
 		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
 		authService: authService,
 	}
 }
@@ -114,6 +116,9 @@ func (s *UserService) Update(ctx context.Context, id primitive.ObjectID, req *do
 	if req.Permissions != nil && !user.IsRoot {
 		user.Permissions = *req.Permissions
 	}
+	if req.Require2FA != nil {
+		user.TwoFactor.Required = *req.Require2FA
+	}
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return nil, err
@@ -171,7 +176,13 @@ func (s *UserService) Delete(ctx context.Context, id primitive.ObjectID) error {
 		return ErrCannotDeleteRoot
 	}
 
-	return s.userRepo.Delete(ctx, id)
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	return s.authService.RevokeUserSessions(ctx, id)
 }
 
 func (s *UserService) Block(ctx context.Context, id primitive.ObjectID) error {
@@ -185,7 +196,10 @@ func (s *UserService) Block(ctx context.Context, id primitive.ObjectID) error {
 	}
 
 	user.IsBlocked = true
-	return s.userRepo.Update(ctx, user)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	return s.authService.RevokeUserSessions(ctx, id)
 }
 
 func (s *UserService) Unblock(ctx context.Context, id primitive.ObjectID) error {
@@ -198,6 +212,24 @@ func (s *UserService) Unblock(ctx context.Context, id primitive.ObjectID) error
 	return s.userRepo.Update(ctx, user)
 }
 
+// AllowSSOLink lets the next SSO login with the user's email link the account,
+// which is otherwise refused for root and accounts with a password
+func (s *UserService) AllowSSOLink(ctx context.Context, id primitive.ObjectID, currentUser *domain.User) (*domain.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsRoot && !currentUser.IsRoot {
+		return nil, ErrCannotModifyRoot
+	}
+
+	user.SSOLinkAllowed = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 func (s *UserService) IsBlocked(ctx context.Context, id primitive.ObjectID) (bool, error) {
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/levskiy0/m3m/internal/domain"
+	"github.com/levskiy0/m3m/internal/repository"
+)
+
+const (
+	// webhookMaxBody is the largest payload kept whole; larger ones are cut
+	// and cannot be replayed
+	webhookMaxBody = 1 << 20
+	// webhookDefaultLimit is how many deliveries are listed without a limit
+	webhookDefaultLimit = 50
+	// webhookMaxLimit caps the deliveries listed at once
+	webhookMaxLimit = 500
+	// webhookRejectedPerMinute caps the rejected deliveries recorded per
+	// webhook, so unsigned requests cannot flood the log
+	webhookRejectedPerMinute = 20
+)
+
+// WebhookService keeps the delivery log of $router.webhook routes
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	rejected map[string]*webhookRejectWindow // "<projectID>/<webhook>"
+}
+
+type webhookRejectWindow struct {
+	start time.Time
+	count int
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, logger *slog.Logger) *WebhookService {
+	return &WebhookService{
+		repo:     repo,
+		logger:   logger,
+		rejected: make(map[string]*webhookRejectWindow),
+	}
+}
+
+// Record appends a delivery to the log. The request was already answered,
+// so failures are logged rather than returned. Rejected deliveries are
+// unverified, so only their metadata and error are kept, and at most
+// webhookRejectedPerMinute of them per webhook.
+func (s *WebhookService) Record(ctx context.Context, delivery *domain.WebhookDelivery) {
+	delivery.BodySize = len(delivery.Body)
+	if delivery.Status == domain.WebhookRejected {
+		if !s.allowRejected(delivery.ProjectID.Hex()+"/"+delivery.Webhook, time.Now()) {
+			return
+		}
+		delivery.Body = nil
+	}
+	if len(delivery.Body) > webhookMaxBody {
+		delivery.Body = delivery.Body[:webhookMaxBody]
+		delivery.BodyTruncated = true
+	}
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	if err := s.repo.Insert(context.WithoutCancel(ctx), delivery); err != nil {
+		s.logger.Error("Failed to record webhook delivery", "project_id", delivery.ProjectID.Hex(), "webhook", delivery.Webhook, "error", err)
+	}
+}
+
+// allowRejected counts a rejected delivery of a webhook and reports whether
+// it is within the per minute limit
+func (s *WebhookService) allowRejected(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window, ok := s.rejected[key]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &webhookRejectWindow{start: now}
+		s.rejected[key] = window
+	}
+	window.count++
+	return window.count <= webhookRejectedPerMinute
+}
+
+// Delivered reports whether a delivery id was already handled successfully.
+// When the log cannot be read the delivery is let through: a duplicate is
+// safer than a lost event.
+func (s *WebhookService) Delivered(ctx context.Context, projectID primitive.ObjectID, webhook, deliveryID string) bool {
+	delivered, err := s.repo.Delivered(ctx, projectID, webhook, deliveryID)
+	if err != nil {
+		s.logger.Warn("Failed to check webhook delivery", "project_id", projectID.Hex(), "webhook", webhook, "error", err)
+	}
+	return delivered
+}
+
+// List returns the newest deliveries matching a filter, without payloads
+func (s *WebhookService) List(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = webhookDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, webhookMaxLimit)
+	return s.repo.Find(ctx, filter)
+}
+
+// Get returns a delivery with its payload
+func (s *WebhookService) Get(ctx context.Context, projectID, id primitive.ObjectID) (*domain.WebhookDelivery, error) {
+	delivery, err := s.repo.FindByID(ctx, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload, delivery.PayloadEncoding = webhookPayload(delivery.Body)
+	return delivery, nil
+}
+
+// webhookPayload returns a body as text, or as base64 when it is binary
+func webhookPayload(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), "utf8"
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
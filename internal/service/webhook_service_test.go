@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	tests := []struct {
+		body     []byte
+		payload  string
+		encoding string
+	}{
+		{[]byte(`{"id":"evt_1"}`), `{"id":"evt_1"}`, "utf8"},
+		{[]byte("привет"), "привет", "utf8"},
+		{[]byte{0xff, 0x00, 0x01}, "/wAB", "base64"},
+		{nil, "", "utf8"},
+	}
+
+	for _, tt := range tests {
+		payload, encoding := webhookPayload(tt.body)
+		if payload != tt.payload || encoding != tt.encoding {
+			t.Errorf("%v: %q (%s), want %q (%s)", tt.body, payload, encoding, tt.payload, tt.encoding)
+		}
+	}
+}
+
+func TestWebhookAllowRejected(t *testing.T) {
+	s := NewWebhookService(nil, nil)
+	now := time.Now()
+
+	for i := 0; i < webhookRejectedPerMinute; i++ {
+		if !s.allowRejected("p1/stripe", now) {
+			t.Fatalf("rejected delivery %d was not recorded", i+1)
+		}
+	}
+	if s.allowRejected("p1/stripe", now.Add(time.Second)) {
+		t.Error("expected rejected deliveries over the limit to be dropped")
+	}
+	if !s.allowRejected("p1/github", now) {
+		t.Error("expected the limit to be per webhook")
+	}
+	if !s.allowRejected("p1/stripe", now.Add(time.Minute)) {
+		t.Error("expected the limit to reset after a minute")
+	}
+}
@@ -153,3 +153,8 @@ func (s *WidgetService) Reorder(ctx context.Context, projectID primitive.ObjectI
 
 	return s.widgetRepo.Reorder(ctx, projectID, widgetIDs)
 }
+
+// DeleteByProject removes all widgets of a project
+func (s *WidgetService) DeleteByProject(ctx context.Context, projectID primitive.ObjectID) error {
+	return s.widgetRepo.DeleteByProject(ctx, projectID)
+}
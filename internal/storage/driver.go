@@ -0,0 +1,195 @@
+// Package storage provides the object storage drivers behind project file storage.
+//
+// Objects are addressed by slash separated keys such as
+// "<projectID>/storage/images/logo.png". Directories are implicit: a key is a
+// directory when other keys live under it (or when it was created by MkDir).
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/levskiy0/m3m/internal/config"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var (
+	ErrNotExist   = errors.New("object does not exist")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// ObjectInfo describes a stored file or directory
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Name returns the last element of the key
+func (o ObjectInfo) Name() string {
+	return path.Base(o.Key)
+}
+
+// Driver stores project files. Directory operations (List, Copy, Rename,
+// Delete) work on whole trees, like their filesystem counterparts.
+type Driver interface {
+	// Name returns the driver name from the config, e.g. "local" or "s3"
+	Name() string
+
+	// Stat returns information about a file or directory, ErrNotExist if missing
+	Stat(key string) (*ObjectInfo, error)
+
+	// Open opens a file for reading. Readers of the local driver are seekable.
+	Open(key string) (io.ReadCloser, error)
+
+	// Put stores a file, creating parent directories. size is -1 when unknown.
+	Put(key string, r io.Reader, size int64) error
+
+	// Append adds data to the end of a file, creating it if needed
+	Append(key string, data []byte) error
+
+	// MkDir creates a directory (and its parents)
+	MkDir(key string) error
+
+	// List returns the direct children of a directory, ErrNotExist if it is missing
+	List(key string) ([]ObjectInfo, error)
+
+	// Walk calls fn for every file under key (recursively, directories excluded)
+	Walk(key string, fn func(info ObjectInfo) error) error
+
+	// Copy copies a file or directory tree
+	Copy(src, dst string) error
+
+	// Rename moves a file or directory tree
+	Rename(src, dst string) error
+
+	// Delete removes a file or directory tree. Missing keys are not an error.
+	Delete(key string) error
+
+	// LocalPath returns the filesystem path of a key when the driver keeps files on local disk
+	LocalPath(key string) (string, bool)
+}
+
+// RangeOpener is implemented by drivers that can read part of a file without
+// fetching what comes before it
+type RangeOpener interface {
+	// OpenRange opens length bytes of a file from offset; a negative length reads to the end
+	OpenRange(key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// OpenRange opens part of a file. Drivers without range support skip to the
+// offset by seeking, or by reading and discarding the bytes before it.
+func OpenRange(d Driver, key string, offset, length int64) (io.ReadCloser, error) {
+	if ranger, ok := d.(RangeOpener); ok {
+		return ranger.OpenRange(key, offset, length)
+	}
+
+	r, err := d.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if seeker, ok := r.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, r, offset)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return r, nil
+	}
+	return limitedReadCloser{io.LimitReader(r, length), r}, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewDriver creates the driver configured in storage.driver
+func NewDriver(cfg *config.Config) (Driver, error) {
+	return New(cfg.Storage.Driver, cfg.Storage)
+}
+
+// New creates a driver by name, using the matching section of the storage config
+func New(name string, cfg config.StorageConfig) (Driver, error) {
+	switch name {
+	case "", DriverLocal:
+		return NewLocalDriver(cfg.Path)
+	case DriverS3:
+		return NewS3Driver(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", name)
+	}
+}
+
+// CleanKey normalizes a key: slash separated, no leading or trailing slash,
+// no "." or ".." elements. The root key is "".
+func CleanKey(key string) string {
+	key = strings.ReplaceAll(key, "\\", "/")
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// JoinKey joins key elements into a clean key
+func JoinKey(elem ...string) string {
+	return CleanKey(path.Join(elem...))
+}
+
+// RelKey returns key relative to the base key, or false if key is not under base
+func RelKey(base, key string) (string, bool) {
+	base, key = CleanKey(base), CleanKey(key)
+	if base == "" {
+		return key, true
+	}
+	if key == base {
+		return "", true
+	}
+	if strings.HasPrefix(key, base+"/") {
+		return key[len(base)+1:], true
+	}
+	return "", false
+}
+
+// CopyAll copies every file under src in one driver to dst in another and
+// returns the number of files and bytes copied. skip may exclude keys.
+func CopyAll(from Driver, src string, to Driver, dst string, skip func(key string) bool) (int, int64, error) {
+	var count int
+	var size int64
+	err := from.Walk(src, func(info ObjectInfo) error {
+		if skip != nil && skip(info.Key) {
+			return nil
+		}
+		rel, _ := RelKey(src, info.Key)
+
+		r, err := from.Open(info.Key)
+		if err != nil {
+			return err
+		}
+		err = to.Put(JoinKey(dst, rel), r, info.Size)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", info.Key, err)
+		}
+
+		count++
+		size += info.Size
+		return nil
+	})
+	return count, size, err
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDriver keeps files in a directory on the local disk
+type LocalDriver struct {
+	root string
+}
+
+func NewLocalDriver(root string) (*LocalDriver, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalDriver{root: abs}, nil
+}
+
+func (d *LocalDriver) Name() string {
+	return DriverLocal
+}
+
+// path maps a key to a filesystem path, refusing symlinks that point outside the root
+func (d *LocalDriver) path(key string) (string, error) {
+	fullPath := filepath.Join(d.root, filepath.FromSlash(CleanKey(key)))
+
+	if realPath, err := filepath.EvalSymlinks(fullPath); err == nil {
+		realRoot, err := filepath.EvalSymlinks(d.root)
+		if err != nil {
+			realRoot = d.root
+		}
+		if realPath != realRoot && !strings.HasPrefix(realPath, realRoot+string(os.PathSeparator)) {
+			return "", ErrInvalidKey
+		}
+	}
+
+	return fullPath, nil
+}
+
+func (d *LocalDriver) Stat(key string) (*ObjectInfo, error) {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, mapLocalError(err)
+	}
+
+	return &ObjectInfo{
+		Key:     CleanKey(key),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}, nil
+}
+
+func (d *LocalDriver) Open(key string) (io.ReadCloser, error) {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, mapLocalError(err)
+	}
+	return file, nil
+}
+
+func (d *LocalDriver) Put(key string, r io.Reader, size int64) error {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(fullPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *LocalDriver) Append(key string, data []byte) error {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
+
+func (d *LocalDriver) MkDir(key string) error {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(fullPath, 0755)
+}
+
+func (d *LocalDriver) List(key string) ([]ObjectInfo, error) {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil, mapLocalError(err)
+	}
+
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, ObjectInfo{
+			Key:     JoinKey(key, entry.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   entry.IsDir(),
+		})
+	}
+	return objects, nil
+}
+
+func (d *LocalDriver) Walk(key string, fn func(info ObjectInfo) error) error {
+	basePath, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(basePath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == basePath {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(basePath, p)
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{
+			Key:     JoinKey(key, filepath.ToSlash(rel)),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	})
+	return err
+}
+
+func (d *LocalDriver) Copy(src, dst string) error {
+	srcPath, err := d.path(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := d.path(dst)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return mapLocalError(err)
+	}
+
+	if info.IsDir() {
+		return copyLocalDir(srcPath, dstPath)
+	}
+	return copyLocalFile(srcPath, dstPath)
+}
+
+func (d *LocalDriver) Rename(src, dst string) error {
+	srcPath, err := d.path(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := d.path(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+	return mapLocalError(os.Rename(srcPath, dstPath))
+}
+
+func (d *LocalDriver) Delete(key string) error {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(fullPath)
+}
+
+func (d *LocalDriver) LocalPath(key string) (string, bool) {
+	fullPath, err := d.path(key)
+	if err != nil {
+		return "", false
+	}
+	return fullPath, true
+}
+
+func copyLocalFile(src, dst string) error {
+	// Ensure destination directory exists
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
+
+func copyLocalDir(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		if entry.IsDir() {
+			if err := copyLocalDir(srcPath, dstPath); err != nil {
+				return err
+			}
+		} else {
+			if err := copyLocalFile(srcPath, dstPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func mapLocalError(err error) error {
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return err
+}
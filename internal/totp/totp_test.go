@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("T=%d: code %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+
+	code, _ := Code(secret, Step(now)-1)
+	step, ok := Validate(secret, code[:3]+" "+code[3:], now)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("code of the previous step: %d %v", step, ok)
+	}
+
+	code, _ = Code(secret, Step(now)+2)
+	if _, ok := Validate(secret, code, now); ok {
+		t.Error("code two steps ahead accepted")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+	if _, ok := Validate("not base32!", "123456", now); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("M3M", "dev@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || !strings.HasSuffix(uri.Path, "M3M:dev@example.com") {
+		t.Errorf("uri %s", uri)
+	}
+	if q := uri.Query(); q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "M3M" || q.Get("digits") != "6" {
+		t.Errorf("query %v", q)
+	}
+}
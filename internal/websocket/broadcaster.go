@@ -19,16 +19,18 @@ var _ runtime.RuntimeStopHandler = (*Broadcaster)(nil)
 
 // GoalStatsResponse represents aggregated goal statistics (same as handler)
 type GoalStatsResponse struct {
-	GoalID     string          `json:"goalID"`
-	Value      int64           `json:"value"`
-	TotalValue int64           `json:"totalValue,omitempty"`
-	DailyStats []DailyStatItem `json:"dailyStats,omitempty"`
+	GoalID     string             `json:"goalID"`
+	Value      int64              `json:"value"`
+	TotalValue int64              `json:"totalValue,omitempty"`
+	Metric     *domain.GoalMetric `json:"metric,omitempty"` // Current aggregates of metric goals
+	DailyStats []DailyStatItem    `json:"dailyStats,omitempty"`
 }
 
 // DailyStatItem represents a single day's statistics
 type DailyStatItem struct {
-	Date  string `json:"date"`
-	Value int64  `json:"value"`
+	Date   string             `json:"date"`
+	Value  int64              `json:"value"`
+	Metric *domain.GoalMetric `json:"metric,omitempty"`
 }
 
 // RuntimeManager interface for getting runtime stats
@@ -50,6 +52,7 @@ type Broadcaster struct {
 	logger         *slog.Logger
 	runtimeManager RuntimeManager
 	goalService    *service.GoalService
+	alertService   *service.AlertService
 	projectService ProjectService
 
 	// Track last log update times per project
@@ -64,11 +67,13 @@ func NewBroadcaster(
 	hub *Hub,
 	logger *slog.Logger,
 	goalService *service.GoalService,
+	alertService *service.AlertService,
 ) *Broadcaster {
 	return &Broadcaster{
 		hub:           hub,
 		logger:        logger,
 		goalService:   goalService,
+		alertService:  alertService,
 		lastLogUpdate: make(map[string]time.Time),
 	}
 }
@@ -89,10 +94,10 @@ func (b *Broadcaster) Start(_ context.Context) {
 	bgCtx, cancel := context.WithCancel(context.Background())
 	b.cancel = cancel
 
-	// Start monitor broadcast (every 10 seconds)
+	// Start monitor broadcast and runtime alerts (every 10 seconds)
 	go b.monitorLoop(bgCtx)
 
-	// Start goals broadcast (every 30 seconds)
+	// Start goals broadcast and goal alerts (every 30 seconds)
 	go b.goalsLoop(bgCtx)
 
 	// Start time broadcast (every 1 second)
@@ -120,6 +125,7 @@ func (b *Broadcaster) monitorLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			b.broadcastMonitorData()
+			b.evaluateRuntimeAlerts()
 		}
 	}
 }
@@ -135,6 +141,7 @@ func (b *Broadcaster) goalsLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			b.broadcastGoalsData()
+			b.evaluateGoalAlerts()
 		}
 	}
 }
@@ -197,6 +204,44 @@ func (b *Broadcaster) broadcastMonitorData() {
 	}
 }
 
+// evaluateRuntimeAlerts checks runtime alert rules against all running
+// projects, whether or not anyone is watching them
+func (b *Broadcaster) evaluateRuntimeAlerts() {
+	if b.alertService == nil || b.runtimeManager == nil {
+		return
+	}
+
+	samples := make(map[primitive.ObjectID]domain.RuntimeSample)
+	for _, projectID := range b.runtimeManager.GetRunningProjects() {
+		stats, err := b.runtimeManager.GetStats(projectID)
+		if err != nil {
+			continue
+		}
+		samples[projectID] = domain.RuntimeSample{
+			MemoryBytes:   stats.Memory.Alloc,
+			CPUPercent:    stats.CPUPercent,
+			StorageBytes:  stats.StorageBytes,
+			DatabaseBytes: stats.DatabaseBytes,
+			TotalRequests: stats.TotalRequests,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b.alertService.EvaluateRuntime(ctx, samples, time.Now())
+}
+
+// evaluateGoalAlerts checks goal alert rules of all projects
+func (b *Broadcaster) evaluateGoalAlerts() {
+	if b.alertService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	b.alertService.EvaluateGoals(ctx, time.Now())
+}
+
 // broadcastGoalsData sends aggregated goals stats to all project subscribers
 func (b *Broadcaster) broadcastGoalsData() {
 	if b.goalService == nil {
@@ -236,8 +281,8 @@ func (b *Broadcaster) broadcastGoalsData() {
 			goalIDs[i] = g.ID.Hex()
 		}
 
-		// Get stats for last 7 days (UTC)
-		now := time.Now().UTC()
+		// Get stats for last 7 days (project timezone)
+		now := b.goalService.Now(ctx, projectID)
 		startDate := now.AddDate(0, 0, -7)
 		today := now.Format("2006-01-02")
 
@@ -273,13 +318,15 @@ func (b *Broadcaster) broadcastGoalsData() {
 
 			gs := goalStatsMap[goalID]
 			gs.DailyStats = append(gs.DailyStats, DailyStatItem{
-				Date:  stat.Date,
-				Value: stat.Value,
+				Date:   stat.Date,
+				Value:  stat.Value,
+				Metric: stat.Metric,
 			})
 
 			// Set current value (today's value)
 			if stat.Date == today || stat.Date == "total" {
 				gs.Value = stat.Value
+				gs.Metric = stat.Metric
 			}
 		}
 
@@ -367,6 +414,16 @@ func (b *Broadcaster) BroadcastActionStates(projectID string, states []domain.Ac
 	b.hub.BroadcastToProject(projectID, EventActions, states)
 }
 
+// BroadcastQuotaWarning notifies project subscribers that a quota is nearly or fully used
+func (b *Broadcaster) BroadcastQuotaWarning(warning domain.QuotaWarning) {
+	b.hub.BroadcastToProject(warning.ProjectID, EventQuota, warning)
+}
+
+// BroadcastAlert shows an alert that started or stopped firing to project subscribers
+func (b *Broadcaster) BroadcastAlert(event domain.AlertEvent) {
+	b.hub.BroadcastToProject(event.ProjectID.Hex(), EventAlert, event)
+}
+
 // SendUIRequest sends a UI request to a specific session
 // Implements modules.UIBroadcaster interface
 func (b *Broadcaster) SendUIRequest(projectID, sessionID string, data interface{}) {
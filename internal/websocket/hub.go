@@ -21,6 +21,8 @@ const (
 	EventActions   EventType = "actions"
 	EventUIRequest EventType = "ui_request"
 	EventTime      EventType = "time"
+	EventQuota     EventType = "quota"
+	EventAlert     EventType = "alert"
 )
 
 // Event represents a WebSocket event message
@@ -49,6 +51,7 @@ type Client struct {
 	mu         sync.RWMutex
 	userID     string
 	sessionID  string // unique session ID for this connection
+	authID     string // login session the connection was opened with
 }
 
 // Hub manages WebSocket connections and broadcasts
@@ -244,6 +247,23 @@ func (h *Hub) Register(client *Client) {
 	h.register <- client
 }
 
+// CloseSessions disconnects the clients opened with revoked login sessions
+func (h *Hub) CloseSessions(authIDs []string) {
+	revoked := make(map[string]bool, len(authIDs))
+	for _, id := range authIDs {
+		revoked[id] = true
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if revoked[client.authID] {
+			// ReadPump fails on the closed connection and unregisters the client
+			client.conn.Close()
+		}
+	}
+}
+
 // BroadcastToProject sends an event to all clients subscribed to a project
 func (h *Hub) BroadcastToProject(projectID string, eventType EventType, data interface{}) {
 	h.broadcast <- &Broadcast{
@@ -383,8 +403,9 @@ const (
 	maxMessageSize = 8192
 )
 
-// NewClient creates a new WebSocket client with a unique session ID
-func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+// NewClient creates a new WebSocket client with a unique session ID for a
+// user authenticated by the authID login session
+func NewClient(hub *Hub, conn *websocket.Conn, userID, authID string) *Client {
 	return &Client{
 		hub:        hub,
 		conn:       conn,
@@ -392,6 +413,7 @@ func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
 		projectIDs: make(map[string]bool),
 		userID:     userID,
 		sessionID:  uuid.New().String(),
+		authID:     authID,
 	}
 }
 
@@ -0,0 +1,59 @@
+import { api } from './client';
+import type {
+  AlertRule,
+  AlertEvent,
+  CreateAlertRuleRequest,
+  UpdateAlertRuleRequest,
+  SilenceAlertRequest,
+} from '@/types';
+
+interface GetHistoryParams {
+  ruleId?: string;
+  limit?: number;
+}
+
+export const alertsApi = {
+  list: async (projectId: string): Promise<AlertRule[]> => {
+    return api.get<AlertRule[]>(`/api/projects/${projectId}/alerts`);
+  },
+
+  create: async (projectId: string, data: CreateAlertRuleRequest): Promise<AlertRule> => {
+    return api.post<AlertRule>(`/api/projects/${projectId}/alerts`, data);
+  },
+
+  update: async (
+    projectId: string,
+    alertId: string,
+    data: UpdateAlertRuleRequest
+  ): Promise<AlertRule> => {
+    return api.put<AlertRule>(`/api/projects/${projectId}/alerts/${alertId}`, data);
+  },
+
+  delete: async (projectId: string, alertId: string): Promise<void> => {
+    return api.delete(`/api/projects/${projectId}/alerts/${alertId}`);
+  },
+
+  silence: async (
+    projectId: string,
+    alertId: string,
+    data: SilenceAlertRequest
+  ): Promise<AlertRule> => {
+    return api.post<AlertRule>(`/api/projects/${projectId}/alerts/${alertId}/silence`, data);
+  },
+
+  unsilence: async (projectId: string, alertId: string): Promise<AlertRule> => {
+    return api.delete<AlertRule>(`/api/projects/${projectId}/alerts/${alertId}/silence`);
+  },
+
+  history: async (projectId: string, params?: GetHistoryParams): Promise<AlertEvent[]> => {
+    const searchParams = new URLSearchParams();
+    if (params?.ruleId) {
+      searchParams.set('rule_id', params.ruleId);
+    }
+    if (params?.limit) {
+      searchParams.set('limit', String(params.limit));
+    }
+    const query = searchParams.toString();
+    return api.get<AlertEvent[]>(`/api/projects/${projectId}/alerts/history${query ? `?${query}` : ''}`);
+  },
+};
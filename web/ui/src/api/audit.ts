@@ -0,0 +1,45 @@
+import { api } from './client';
+import type { AuditEntry, AuditFilter } from '@/types';
+
+function auditQuery(filter?: AuditFilter, withProject = false): string {
+  const searchParams = new URLSearchParams();
+  if (withProject && filter?.projectId) {
+    searchParams.set('project_id', filter.projectId);
+  }
+  if (filter?.actorId) {
+    searchParams.set('actor_id', filter.actorId);
+  }
+  if (filter?.action) {
+    searchParams.set('action', filter.action);
+  }
+  if (filter?.from) {
+    searchParams.set('from', filter.from);
+  }
+  if (filter?.to) {
+    searchParams.set('to', filter.to);
+  }
+  if (filter?.limit) {
+    searchParams.set('limit', String(filter.limit));
+  }
+  const query = searchParams.toString();
+  return query ? `?${query}` : '';
+}
+
+// The audit log of a project (audit.read), or of the whole server for root
+// users when no project is given
+export const auditApi = {
+  list: async (filter?: AuditFilter): Promise<AuditEntry[]> => {
+    if (filter?.projectId) {
+      return api.get<AuditEntry[]>(`/api/projects/${filter.projectId}/audit${auditQuery(filter)}`);
+    }
+    return api.get<AuditEntry[]>(`/api/audit${auditQuery(filter, true)}`);
+  },
+
+  // export downloads the matching entries as JSON Lines, oldest first
+  export: async (filter?: AuditFilter): Promise<Blob> => {
+    if (filter?.projectId) {
+      return api.download(`/api/projects/${filter.projectId}/audit/export${auditQuery(filter)}`);
+    }
+    return api.download(`/api/audit/export${auditQuery(filter, true)}`);
+  },
+};
@@ -1,10 +1,46 @@
-import { api, setToken, removeToken } from './client';
-import type { LoginRequest, LoginResponse, User } from '@/types';
+import { api, setSession, removeToken } from './client';
+import { config } from '@/lib/config';
+import type { AuthConfig, LoginRequest, TwoFactorEnrollment, LoginResponse, Session, User } from '@/types';
 
 export const authApi = {
   login: async (data: LoginRequest): Promise<LoginResponse> => {
     const response = await api.post<LoginResponse>('/api/auth/login', data);
-    setToken(response.token);
+    setSession(response);
+    return response;
+  },
+
+  // enrollTwoFactor creates the TOTP secret of a login that has to enroll in 2FA
+  enrollTwoFactor: async (challenge: string): Promise<TwoFactorEnrollment> => {
+    return api.post<TwoFactorEnrollment>('/api/auth/2fa/enroll', { challenge });
+  },
+
+  // verifyTwoFactor finishes a login with a TOTP or recovery code
+  verifyTwoFactor: async (challenge: string, code: string): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>('/api/auth/2fa/verify', { challenge, code });
+    setSession(response);
+    return response;
+  },
+
+  config: async (): Promise<AuthConfig> => {
+    return api.get<AuthConfig>('/api/auth/config');
+  },
+
+  // oidcLoginURL starts a single sign-on login at the provider
+  oidcLoginURL: (redirect?: string): string => {
+    const query = redirect ? `?redirect=${encodeURIComponent(redirect)}` : '';
+    return `${config.apiURL}/api/auth/oidc/login${query}`;
+  },
+
+  // oidcLink starts a single sign-on login that links the identity to the
+  // current account, the browser is sent to the returned URL
+  oidcLink: async (redirect?: string): Promise<{ url: string }> => {
+    return api.post<{ url: string }>('/api/auth/oidc/link', { redirect });
+  },
+
+  // oidcExchange trades the code of a finished single sign-on login for its tokens
+  oidcExchange: async (code: string): Promise<LoginResponse> => {
+    const response = await api.post<LoginResponse>('/api/auth/oidc/exchange', { code });
+    setSession(response);
     return response;
   },
 
@@ -16,6 +52,22 @@ export const authApi = {
     }
   },
 
+  logoutAll: async (): Promise<void> => {
+    try {
+      await api.post('/api/auth/logout-all');
+    } finally {
+      removeToken();
+    }
+  },
+
+  sessions: async (): Promise<Session[]> => {
+    return api.get<Session[]>('/api/auth/sessions');
+  },
+
+  revokeSession: async (id: string): Promise<void> => {
+    return api.delete(`/api/auth/sessions/${id}`);
+  },
+
   me: async (): Promise<User> => {
     return api.get<User>('/api/users/me');
   },
@@ -1,7 +1,12 @@
 import { config } from '@/lib/config';
-import type { ApiError } from '@/types';
+import type { ApiError, LoginResponse } from '@/types';
 
 const TOKEN_KEY = 'm3m_token';
+const REFRESH_TOKEN_KEY = 'm3m_refresh_token';
+const TOKEN_EXPIRES_KEY = 'm3m_token_expires';
+
+// Access tokens are refreshed this long before they expire
+const REFRESH_MARGIN_MS = 30_000;
 
 // Custom error class to preserve validation details
 export interface ValidationDetail {
@@ -29,6 +34,67 @@ export function setToken(token: string): void {
 
 export function removeToken(): void {
   localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+  localStorage.removeItem(TOKEN_EXPIRES_KEY);
+}
+
+// setSession stores the tokens of a login or refresh response; logins that
+// need a second step carry none yet
+export function setSession(response: LoginResponse): void {
+  if (!response.token || !response.refresh_token || !response.expires_at) return;
+  setToken(response.token);
+  localStorage.setItem(REFRESH_TOKEN_KEY, response.refresh_token);
+  localStorage.setItem(TOKEN_EXPIRES_KEY, String(new Date(response.expires_at).getTime()));
+}
+
+function tokenExpiresSoon(): boolean {
+  const expires = Number(localStorage.getItem(TOKEN_EXPIRES_KEY));
+  return !!expires && expires - Date.now() < REFRESH_MARGIN_MS;
+}
+
+let refreshing: Promise<boolean> | null = null;
+
+// refreshSession exchanges the refresh token for new tokens. Refresh tokens are
+// single use, so tabs take turns: a tab that waited finds the tokens already
+// refreshed by another one.
+export function refreshSession(force = false): Promise<boolean> {
+  if (!refreshing) {
+    const staleToken = getToken();
+    const run = async (): Promise<boolean> => {
+      if (getToken() !== staleToken || (!force && !tokenExpiresSoon())) {
+        return !!getToken();
+      }
+      const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+      if (!refreshToken) {
+        return false;
+      }
+      const response = await fetch(`${config.apiURL}/api/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+      if (!response.ok) {
+        return false;
+      }
+      setSession(await response.json());
+      return true;
+    };
+    const locked = navigator.locks
+      ? navigator.locks.request('m3m_refresh', run)
+      : run();
+    refreshing = locked.catch(() => false).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
+// getFreshToken returns the access token, refreshing it first when it is about to expire
+export async function getFreshToken(): Promise<string | null> {
+  if (getToken() && tokenExpiresSoon()) {
+    await refreshSession();
+  }
+  return getToken();
 }
 
 export class ApiClient {
@@ -38,8 +104,8 @@ export class ApiClient {
     this.baseURL = baseURL;
   }
 
-  private getAuthHeaders(): HeadersInit {
-    const token = getToken();
+  private async getAuthHeaders(): Promise<HeadersInit> {
+    const token = await getFreshToken();
     const headers: Record<string, string> = {};
     if (token) {
       headers['Authorization'] = `Bearer ${token}`;
@@ -71,11 +137,12 @@ export class ApiClient {
 
   private async request<T>(
     endpoint: string,
-    options: RequestInit = {}
+    options: RequestInit = {},
+    retry = true
   ): Promise<T> {
     const headers: HeadersInit = {
       'Content-Type': 'application/json',
-      ...this.getAuthHeaders(),
+      ...(await this.getAuthHeaders()),
       ...options.headers,
     };
 
@@ -84,6 +151,11 @@ export class ApiClient {
       headers,
     });
 
+    // The access token may have been revoked or expired early: refresh once and retry
+    if (response.status === 401 && retry && getToken() && (await refreshSession(true))) {
+      return this.request<T>(endpoint, options, false);
+    }
+
     if (!response.ok) {
       return this.handleErrorResponse(response);
     }
@@ -102,7 +174,7 @@ export class ApiClient {
   async getText(endpoint: string): Promise<string> {
     const response = await fetch(`${this.baseURL}${endpoint}`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
+      headers: await this.getAuthHeaders(),
     });
 
     if (!response.ok) {
@@ -131,7 +203,7 @@ export class ApiClient {
       method: 'PUT',
       headers: {
         'Content-Type': 'text/plain',
-        ...this.getAuthHeaders(),
+        ...(await this.getAuthHeaders()),
       },
       body: text,
     });
@@ -151,7 +223,21 @@ export class ApiClient {
 
     const response = await fetch(`${this.baseURL}${endpoint}`, {
       method: 'POST',
-      headers: this.getAuthHeaders(),
+      headers: await this.getAuthHeaders(),
+      body: formData,
+    });
+
+    if (!response.ok) {
+      return this.handleErrorResponse(response);
+    }
+
+    return response.json();
+  }
+
+  async uploadForm<T>(endpoint: string, formData: FormData): Promise<T> {
+    const response = await fetch(`${this.baseURL}${endpoint}`, {
+      method: 'POST',
+      headers: await this.getAuthHeaders(),
       body: formData,
     });
 
@@ -162,10 +248,27 @@ export class ApiClient {
     return response.json();
   }
 
+  async postDownload(endpoint: string, data?: unknown): Promise<Blob> {
+    const response = await fetch(`${this.baseURL}${endpoint}`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...(await this.getAuthHeaders()),
+      },
+      body: data ? JSON.stringify(data) : undefined,
+    });
+
+    if (!response.ok) {
+      return this.handleErrorResponse(response);
+    }
+
+    return response.blob();
+  }
+
   async download(endpoint: string): Promise<Blob> {
     const response = await fetch(`${this.baseURL}${endpoint}`, {
       method: 'GET',
-      headers: this.getAuthHeaders(),
+      headers: await this.getAuthHeaders(),
       cache: 'no-store',
     });
 
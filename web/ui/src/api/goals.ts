@@ -5,6 +5,9 @@ interface GetStatsParams {
   goalIds?: string[];
   startDate?: string;
   endDate?: string;
+  interval?: 'day' | 'hour' | 'total';
+  groupBy?: string;
+  labels?: Record<string, string>;
 }
 
 export const goalsApi = {
@@ -40,6 +43,16 @@ export const goalsApi = {
     if (params?.endDate) {
       searchParams.set('endDate', params.endDate);
     }
+    if (params?.interval) {
+      searchParams.set('interval', params.interval);
+    }
+    if (params?.groupBy) {
+      searchParams.set('groupBy', params.groupBy);
+    }
+    if (params?.labels) {
+      const labels = Object.entries(params.labels).map(([k, v]) => `${k}=${v}`);
+      if (labels.length) searchParams.set('labels', labels.join(','));
+    }
     const query = searchParams.toString();
     return api.get<GoalStats[]>(`/api/goals/stats${query ? `?${query}` : ''}`);
   },
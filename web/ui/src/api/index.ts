@@ -1,4 +1,4 @@
-export { api, getToken, setToken, removeToken } from './client';
+export { api, getToken, setToken, setSession, removeToken, refreshSession, getFreshToken } from './client';
 export { authApi } from './auth';
 export { usersApi } from './users';
 export { projectsApi } from './projects';
@@ -12,3 +12,6 @@ export { runtimeApi } from './runtime';
 export { versionApi } from './version';
 export { templatesApi } from './templates';
 export { actionsApi } from './actions';
+export { alertsApi } from './alerts';
+export { auditApi } from './audit';
+export { webhooksApi } from './webhooks';
@@ -11,6 +11,9 @@ import type {
   CreatePipelineFileRequest,
   UpdatePipelineFileRequest,
   RenamePipelineFileRequest,
+  GitPushRequest,
+  GitPullRequest,
+  GitSyncResult,
 } from '@/types';
 
 export const pipelineApi = {
@@ -114,4 +117,13 @@ export const pipelineApi = {
       `/api/projects/${projectId}/pipeline/releases/${releaseId}/activate`
     );
   },
+
+  // Git import/export
+  gitPush: async (projectId: string, data: GitPushRequest): Promise<GitSyncResult> => {
+    return api.post<GitSyncResult>(`/api/projects/${projectId}/pipeline/git/push`, data);
+  },
+
+  gitPull: async (projectId: string, data: GitPullRequest): Promise<GitSyncResult> => {
+    return api.post<GitSyncResult>(`/api/projects/${projectId}/pipeline/git/pull`, data);
+  },
 };
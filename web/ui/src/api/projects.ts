@@ -1,5 +1,16 @@
 import { api } from './client';
-import type { Project, CreateProjectRequest, UpdateProjectRequest } from '@/types';
+import type {
+  Project,
+  CreateProjectRequest,
+  UpdateProjectRequest,
+  ExportProjectRequest,
+  ImportProjectRequest,
+  ImportProjectResult,
+  ProjectQuota,
+  ProjectQuotaStatus,
+  ProjectRole,
+  ProjectMembersResponse,
+} from '@/types';
 
 export const projectsApi = {
   list: async (): Promise<Project[]> => {
@@ -26,11 +37,40 @@ export const projectsApi = {
     return api.post<Project>(`/api/projects/${id}/regenerate-key`);
   },
 
-  addMember: async (id: string, userId: string): Promise<Project> => {
-    return api.post<Project>(`/api/projects/${id}/members`, { userId });
+  listMembers: async (id: string): Promise<ProjectMembersResponse> => {
+    return api.get<ProjectMembersResponse>(`/api/projects/${id}/members`);
+  },
+
+  addMember: async (id: string, userId: string, role?: ProjectRole): Promise<Project> => {
+    return api.post<Project>(`/api/projects/${id}/members`, { user_id: userId, role });
+  },
+
+  setMemberRole: async (id: string, userId: string, role: ProjectRole): Promise<void> => {
+    return api.put(`/api/projects/${id}/members/${userId}`, { role });
   },
 
   removeMember: async (id: string, userId: string): Promise<Project> => {
     return api.delete<Project>(`/api/projects/${id}/members/${userId}`);
   },
+
+  getQuota: async (id: string): Promise<ProjectQuotaStatus> => {
+    return api.get<ProjectQuotaStatus>(`/api/projects/${id}/quota`);
+  },
+
+  setQuota: async (id: string, data: ProjectQuota): Promise<ProjectQuotaStatus> => {
+    return api.put<ProjectQuotaStatus>(`/api/projects/${id}/quota`, data);
+  },
+
+  exportBundle: async (id: string, data: ExportProjectRequest = {}): Promise<Blob> => {
+    return api.postDownload(`/api/projects/${id}/export`, data);
+  },
+
+  importBundle: async ({ file, name, slug, passphrase }: ImportProjectRequest): Promise<ImportProjectResult> => {
+    const formData = new FormData();
+    formData.append('file', file);
+    if (name) formData.append('name', name);
+    if (slug) formData.append('slug', slug);
+    if (passphrase) formData.append('passphrase', passphrase);
+    return api.uploadForm<ImportProjectResult>('/api/projects/import', formData);
+  },
 };
@@ -1,5 +1,5 @@
 import { api } from './client';
-import type { RuntimeStatus, RuntimeStats, LogEntry } from '@/types';
+import type { RuntimeStatus, RuntimeStats, LogEntry, LogQuery, LogFile } from '@/types';
 
 export interface Plugin {
   name: string;
@@ -92,12 +92,24 @@ export const runtimeApi = {
     return api.get<RuntimeStats>(`/api/projects/${projectId}/monitor`);
   },
 
-  logs: async (projectId: string): Promise<LogEntry[]> => {
-    return api.get<LogEntry[]>(`/api/projects/${projectId}/logs`);
+  logs: async (projectId: string, query: LogQuery = {}): Promise<LogEntry[]> => {
+    const params = new URLSearchParams();
+    const { fields, ...rest } = query;
+    Object.entries(rest).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') params.append(key, String(value));
+    });
+    Object.entries(fields ?? {}).forEach(([key, value]) => params.append('field', `${key}:${value}`));
+    const qs = params.toString();
+    return api.get<LogEntry[]>(`/api/projects/${projectId}/logs${qs ? `?${qs}` : ''}`);
   },
 
-  downloadLogs: async (projectId: string): Promise<Blob> => {
-    return api.download(`/api/projects/${projectId}/logs/download`);
+  logFiles: async (projectId: string): Promise<LogFile[]> => {
+    return api.get<LogFile[]>(`/api/projects/${projectId}/logs/files`);
+  },
+
+  downloadLogs: async (projectId: string, file?: string): Promise<Blob> => {
+    const qs = file ? `?file=${encodeURIComponent(file)}` : '';
+    return api.download(`/api/projects/${projectId}/logs/download${qs}`);
   },
 
   getTypes: async (): Promise<string> => {
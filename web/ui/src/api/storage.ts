@@ -4,6 +4,12 @@ import type {
   CreateDirRequest,
   RenameRequest,
   CreateFileRequest,
+  SignStorageUrlRequest,
+  SignedStorageUrl,
+  SetStorageVersioningRequest,
+  StorageVersioning,
+  StorageVersionList,
+  StorageVersionPurge,
 } from '@/types';
 
 export const storageApi = {
@@ -104,6 +110,42 @@ export const storageApi = {
     return api.putText(`/api/projects/${projectId}/storage/file/${path}`, content);
   },
 
+  setVisibility: async (projectId: string, path: string, isPrivate: boolean): Promise<{ private_paths: string[] }> => {
+    return api.put(`/api/projects/${projectId}/storage/visibility`, { path, private: isPrivate });
+  },
+
+  sign: async (projectId: string, data: SignStorageUrlRequest): Promise<SignedStorageUrl> => {
+    return api.post<SignedStorageUrl>(`/api/projects/${projectId}/storage/sign`, data);
+  },
+
+  setVersioning: async (
+    projectId: string,
+    data: SetStorageVersioningRequest
+  ): Promise<{ versioned_paths: StorageVersioning[] }> => {
+    return api.put(`/api/projects/${projectId}/storage/versioning`, data);
+  },
+
+  versions: async (projectId: string, path: string): Promise<StorageVersionList> => {
+    return api.get<StorageVersionList>(`/api/projects/${projectId}/storage/versions/${path}`);
+  },
+
+  downloadVersion: async (projectId: string, path: string, versionId: string): Promise<Blob> => {
+    return api.download(
+      `/api/projects/${projectId}/storage/versions/${path}?version=${encodeURIComponent(versionId)}`
+    );
+  },
+
+  restoreVersion: async (projectId: string, path: string, versionId: string): Promise<void> => {
+    return api.post(`/api/projects/${projectId}/storage/versions/restore`, {
+      path,
+      version_id: versionId,
+    });
+  },
+
+  purgeVersions: async (projectId: string, path: string = '', all: boolean = false): Promise<StorageVersionPurge> => {
+    return api.post<StorageVersionPurge>(`/api/projects/${projectId}/storage/versions/purge`, { path, all });
+  },
+
   getThumbnail: async (projectId: string, path: string): Promise<Blob> => {
     return api.download(`/api/projects/${projectId}/storage/thumbnail/${path}`);
   },
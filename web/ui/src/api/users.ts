@@ -5,9 +5,56 @@ import type {
   UpdateUserRequest,
   UpdateMeRequest,
   ChangePasswordRequest,
+  AccessToken,
+  CreateAccessTokenRequest,
+  CreateAccessTokenResponse,
+  TwoFactorStatus,
+  TwoFactorEnrollment,
+  LoginEvent,
 } from '@/types';
 
 export const usersApi = {
+  listTokens: async (): Promise<AccessToken[]> => {
+    return api.get<AccessToken[]>('/api/users/me/tokens');
+  },
+
+  createToken: async (data: CreateAccessTokenRequest): Promise<CreateAccessTokenResponse> => {
+    return api.post<CreateAccessTokenResponse>('/api/users/me/tokens', data);
+  },
+
+  revokeToken: async (id: string): Promise<void> => {
+    return api.delete(`/api/users/me/tokens/${id}`);
+  },
+
+  twoFactorStatus: async (): Promise<TwoFactorStatus> => {
+    return api.get<TwoFactorStatus>('/api/users/me/2fa');
+  },
+
+  enrollTwoFactor: async (): Promise<TwoFactorEnrollment> => {
+    return api.post<TwoFactorEnrollment>('/api/users/me/2fa/enroll');
+  },
+
+  confirmTwoFactor: async (code: string): Promise<{ recovery_codes: string[] }> => {
+    return api.post<{ recovery_codes: string[] }>('/api/users/me/2fa/verify', { code });
+  },
+
+  disableTwoFactor: async (code: string): Promise<void> => {
+    return api.post('/api/users/me/2fa/disable', { code });
+  },
+
+  regenerateRecoveryCodes: async (code: string): Promise<{ recovery_codes: string[] }> => {
+    return api.post<{ recovery_codes: string[] }>('/api/users/me/2fa/recovery-codes', { code });
+  },
+
+  resetTwoFactor: async (id: string): Promise<void> => {
+    return api.post(`/api/users/${id}/2fa/reset`);
+  },
+
+  // allowSSOLink lets the next single sign-on login with the user's email link the account
+  allowSSOLink: async (id: string): Promise<User> => {
+    return api.post<User>(`/api/users/${id}/sso-link`);
+  },
+
   list: async (): Promise<User[]> => {
     return api.get<User[]>('/api/users');
   },
@@ -36,6 +83,26 @@ export const usersApi = {
     return api.post<User>(`/api/users/${id}/unblock`);
   },
 
+  unlock: async (id: string): Promise<void> => {
+    return api.post(`/api/users/${id}/unlock`);
+  },
+
+  unlockIP: async (ip: string): Promise<void> => {
+    return api.post('/api/users/login-unlock', { ip });
+  },
+
+  loginEvents: async (params?: { email?: string; ip?: string }): Promise<LoginEvent[]> => {
+    const searchParams = new URLSearchParams();
+    if (params?.email) {
+      searchParams.set('email', params.email);
+    }
+    if (params?.ip) {
+      searchParams.set('ip', params.ip);
+    }
+    const query = searchParams.toString();
+    return api.get<LoginEvent[]>(`/api/users/login-events${query ? `?${query}` : ''}`);
+  },
+
   updateMe: async (data: UpdateMeRequest): Promise<User> => {
     return api.put<User>('/api/users/me', data);
   },
@@ -0,0 +1,30 @@
+import { api } from './client';
+import type { WebhookDelivery, WebhookDeliveryFilter } from '@/types';
+
+// The delivery log of a project's $router.webhook routes
+export const webhooksApi = {
+  listDeliveries: async (projectId: string, filter?: WebhookDeliveryFilter): Promise<WebhookDelivery[]> => {
+    const searchParams = new URLSearchParams();
+    if (filter?.webhook) {
+      searchParams.set('webhook', filter.webhook);
+    }
+    if (filter?.status) {
+      searchParams.set('status', filter.status);
+    }
+    if (filter?.limit) {
+      searchParams.set('limit', String(filter.limit));
+    }
+    const query = searchParams.toString();
+    return api.get<WebhookDelivery[]>(`/api/projects/${projectId}/webhooks/deliveries${query ? `?${query}` : ''}`);
+  },
+
+  getDelivery: async (projectId: string, deliveryId: string): Promise<WebhookDelivery> => {
+    return api.get<WebhookDelivery>(`/api/projects/${projectId}/webhooks/deliveries/${deliveryId}`);
+  },
+
+  // replay runs the delivery through the running handler again and returns
+  // the delivery recorded for the replay
+  replay: async (projectId: string, deliveryId: string): Promise<WebhookDelivery> => {
+    return api.post<WebhookDelivery>(`/api/projects/${projectId}/webhooks/deliveries/${deliveryId}/replay`);
+  },
+};
@@ -130,7 +130,19 @@ export function LogsViewer({
                 >
                   [{log.level}]
                 </span>
-                <span className="text-gray-200 break-all">{log.message}</span>
+                <span className="text-gray-200 break-all">
+                  {log.message}
+                  {log.fields &&
+                    Object.entries(log.fields).map(([key, value]) => (
+                      <span key={key} className="ml-2 text-gray-500">
+                        {key}=
+                        <span className="text-gray-400">
+                          {typeof value === 'string' ? value : JSON.stringify(value)}
+                        </span>
+                      </span>
+                    ))}
+                  {log.source && <span className="ml-2 text-gray-600">({log.source})</span>}
+                </span>
               </div>
             ))}
           </div>
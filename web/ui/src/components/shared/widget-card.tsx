@@ -31,6 +31,7 @@ import { Badge } from '@/components/ui/badge';
 import { Sparkline } from '@/components/shared/sparkline';
 import { cn } from '@/lib/utils';
 import { formatBytes } from '@/lib/format';
+import { goalStatValue, isMetricGoal } from '@/features/goals/constants';
 import type { Goal, GoalStats, Widget, WidgetType, RuntimeStats, Action, ActionState } from '@/types';
 
 // Format duration from seconds to human readable string
@@ -175,8 +176,9 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
   isDragging?: boolean;
   style?: React.CSSProperties;
 }>(({ widget, goal, stats, onEdit, onDelete, dragHandleProps, isDragging, style }, ref) => {
-  const sparklineData = stats?.dailyStats?.slice(-14).map((d) => d.value) || [];
+  const sparklineData = stats?.dailyStats?.slice(-14).map((d) => goalStatValue(goal.type, d)) || [];
   const isDailyCounter = goal.type === 'daily_counter';
+  const hasSeries = isDailyCounter || isMetricGoal(goal.type);
 
   // Mini variant
   if (widget.variant === 'mini') {
@@ -211,7 +213,7 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
           <Target className="size-4 text-muted-foreground" />
         </CardHeader>
         <CardContent>
-          {isDailyCounter && sparklineData.length > 0 ? (
+          {hasSeries && sparklineData.length > 0 ? (
             <div className="flex items-end justify-between gap-4">
               <div>
                 <div className="flex items-baseline gap-2">
@@ -221,12 +223,12 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
                         {stats.totalValue.toLocaleString()}
                       </span>
                       <span className="text-sm text-muted-foreground">
-                        / {(stats?.value ?? 0).toLocaleString()} today
+                        / {goalStatValue(goal.type, stats).toLocaleString()} today
                       </span>
                     </>
                   ) : (
                     <span className="text-2xl font-bold">
-                      {stats?.value?.toLocaleString() ?? 0}
+                      {goalStatValue(goal.type, stats).toLocaleString()}
                     </span>
                   )}
                 </div>
@@ -246,7 +248,7 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
           ) : (
             <div>
               <div className="text-2xl font-bold">
-                {stats?.value?.toLocaleString() ?? 0}
+                {goalStatValue(goal.type, stats).toLocaleString()}
               </div>
               <p className="text-xs text-muted-foreground mt-1">
                 {goal.description || 'Total count'}
@@ -290,7 +292,7 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
               <CardTitle className="text-sm font-medium">{goal.name}</CardTitle>
             </div>
             <Badge variant="secondary" className="text-xs">
-              {isDailyCounter ? 'Daily' : 'Counter'}
+              {isMetricGoal(goal.type) ? goal.type : isDailyCounter ? 'Daily' : 'Counter'}
             </Badge>
           </div>
         </CardHeader>
@@ -304,12 +306,12 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
                       {stats.totalValue.toLocaleString()}
                     </span>
                     <span className="text-sm text-muted-foreground">
-                      / {(stats?.value ?? 0).toLocaleString()} today
+                      / {goalStatValue(goal.type, stats).toLocaleString()} today
                     </span>
                   </>
                 ) : (
                   <span className="text-2xl font-bold">
-                    {stats?.value?.toLocaleString() ?? 0}
+                    {goalStatValue(goal.type, stats).toLocaleString()}
                   </span>
                 )}
               </div>
@@ -317,7 +319,7 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
                 {goal.description || (isDailyCounter ? 'Daily counter' : 'Total count')}
               </p>
             </div>
-            {isDailyCounter && sparklineData.length > 0 && (
+            {hasSeries && sparklineData.length > 0 && (
               <Sparkline
                 data={sparklineData}
                 width={80}
@@ -372,12 +374,12 @@ const GoalWidgetCard = forwardRef<HTMLDivElement, {
                 {stats.totalValue.toLocaleString()}
               </span>
               <span className="text-sm text-muted-foreground">
-                / {(stats?.value ?? 0).toLocaleString()} today
+                / {goalStatValue(goal.type, stats).toLocaleString()} today
               </span>
             </>
           ) : (
             <span className="text-2xl font-bold">
-              {stats?.value?.toLocaleString() ?? 0}
+              {goalStatValue(goal.type, stats).toLocaleString()}
             </span>
           )}
         </div>
@@ -1,7 +1,9 @@
-import { useState } from 'react';
-import { useNavigate } from 'react-router-dom';
+import { useEffect, useRef, useState } from 'react';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { useQuery } from '@tanstack/react-query';
 import { FolderCode } from 'lucide-react';
 
+import { authApi } from '@/api';
 import { useAuth } from '@/providers/auth-provider';
 import { useTitle } from '@/hooks';
 import { Button } from '@/components/ui/button';
@@ -18,17 +20,59 @@ import {
   FieldError,
   FieldGroup,
   FieldLabel,
+  FieldSeparator,
 } from '@/components/ui/field';
 import { Input } from '@/components/ui/input';
+import type { LoginResponse } from '@/types';
+import { TwoFactorStep } from './two-factor-step';
 
 export function LoginPage() {
   useTitle('Login');
   const navigate = useNavigate();
-  const { login } = useAuth();
+  const [searchParams, setSearchParams] = useSearchParams();
+  const { login, loginWithSSO, refresh } = useAuth();
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
-  const [error, setError] = useState('');
+  const [error, setError] = useState(searchParams.get('sso_error') || '');
   const [isLoading, setIsLoading] = useState(false);
+  const [secondStep, setSecondStep] = useState<LoginResponse | null>(null);
+  const ssoCode = useRef(searchParams.get('sso'));
+  const redirect = searchParams.get('redirect') || '/projects';
+
+  const { data: authConfig } = useQuery({
+    queryKey: ['auth-config'],
+    queryFn: authApi.config,
+  });
+
+  // Logins of users with 2FA continue with a second step
+  const handleLoginResponse = (response: LoginResponse) => {
+    if (response.two_factor && response.challenge) {
+      setSecondStep(response);
+    } else {
+      navigate(redirect);
+    }
+  };
+
+  const finishSecondStep = async () => {
+    await refresh();
+    navigate(redirect);
+  };
+
+  // The SSO callback sends the browser back here with a single-use code
+  useEffect(() => {
+    const code = ssoCode.current;
+    if (!code) return;
+    ssoCode.current = null;
+    setSearchParams({}, { replace: true });
+
+    // eslint-disable-next-line react-hooks/set-state-in-effect -- intentional: exchange runs once on mount
+    setIsLoading(true);
+    loginWithSSO(code)
+      .then(handleLoginResponse)
+      .catch((err) => setError(err instanceof Error ? err.message : 'Login failed'))
+      .finally(() => setIsLoading(false));
+    // eslint-disable-next-line react-hooks/exhaustive-deps -- handleLoginResponse only navigates
+  }, [loginWithSSO, navigate, redirect, setSearchParams]);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -36,8 +80,7 @@ export function LoginPage() {
     setIsLoading(true);
 
     try {
-      await login(email, password);
-      navigate('/projects');
+      handleLoginResponse(await login(email, password));
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Login failed');
     } finally {
@@ -62,41 +105,70 @@ export function LoginPage() {
             </CardDescription>
           </CardHeader>
           <CardContent>
-            <form onSubmit={handleSubmit}>
-              <FieldGroup>
-                <Field>
-                  <FieldLabel htmlFor="email">Email</FieldLabel>
-                  <Input
-                    id="email"
-                    type="email"
-                    placeholder="admin@example.com"
-                    value={email}
-                    onChange={(e) => setEmail(e.target.value)}
-                    required
-                    disabled={isLoading}
-                  />
-                </Field>
-                <Field>
-                  <FieldLabel htmlFor="password">Password</FieldLabel>
-                  <Input
-                    id="password"
-                    type="password"
-                    value={password}
-                    onChange={(e) => setPassword(e.target.value)}
-                    required
-                    disabled={isLoading}
-                  />
-                </Field>
-                {error && (
-                  <FieldError>{error}</FieldError>
-                )}
-                <Field>
-                  <Button type="submit" className="w-full" disabled={isLoading}>
-                    {isLoading ? 'Signing in...' : 'Sign in'}
-                  </Button>
-                </Field>
-              </FieldGroup>
-            </form>
+            {secondStep?.two_factor && secondStep.challenge ? (
+              <TwoFactorStep
+                step={secondStep.two_factor}
+                challenge={secondStep.challenge}
+                onDone={finishSecondStep}
+                onCancel={() => {
+                  setSecondStep(null);
+                  setPassword('');
+                }}
+              />
+            ) : (
+              <form onSubmit={handleSubmit}>
+                <FieldGroup>
+                  <Field>
+                    <FieldLabel htmlFor="email">Email</FieldLabel>
+                    <Input
+                      id="email"
+                      type="email"
+                      placeholder="admin@example.com"
+                      value={email}
+                      onChange={(e) => setEmail(e.target.value)}
+                      required
+                      disabled={isLoading}
+                    />
+                  </Field>
+                  <Field>
+                    <FieldLabel htmlFor="password">Password</FieldLabel>
+                    <Input
+                      id="password"
+                      type="password"
+                      value={password}
+                      onChange={(e) => setPassword(e.target.value)}
+                      required
+                      disabled={isLoading}
+                    />
+                  </Field>
+                  {error && (
+                    <FieldError>{error}</FieldError>
+                  )}
+                  <Field>
+                    <Button type="submit" className="w-full" disabled={isLoading}>
+                      {isLoading ? 'Signing in...' : 'Sign in'}
+                    </Button>
+                  </Field>
+                  {authConfig?.oidc.enabled && (
+                    <>
+                      <FieldSeparator>or</FieldSeparator>
+                      <Field>
+                        <Button variant="outline" className="w-full" disabled={isLoading} asChild>
+                          <a href={authApi.oidcLoginURL(redirect)}>
+                            Sign in with {authConfig.oidc.name}
+                          </a>
+                        </Button>
+                        {!authConfig.password_login && (
+                          <FieldDescription className="text-center">
+                            Password login is reserved for root users
+                          </FieldDescription>
+                        )}
+                      </Field>
+                    </>
+                  )}
+                </FieldGroup>
+              </form>
+            )}
           </CardContent>
         </Card>
         <FieldDescription className="px-6 text-center">
@@ -0,0 +1,125 @@
+import { useEffect, useState } from 'react';
+
+import { authApi } from '@/api';
+import { Button } from '@/components/ui/button';
+import {
+  Field,
+  FieldDescription,
+  FieldError,
+  FieldGroup,
+  FieldLabel,
+} from '@/components/ui/field';
+import { Input } from '@/components/ui/input';
+import type { TwoFactorEnrollment } from '@/types';
+
+interface TwoFactorStepProps {
+  step: 'verify' | 'enroll';
+  challenge: string;
+  onDone: () => void;
+  onCancel: () => void;
+}
+
+// TwoFactorStep is the second step of a login: a code for users with 2FA, or
+// setting up an authenticator app for users who are required to use 2FA
+export function TwoFactorStep({ step, challenge, onDone, onCancel }: TwoFactorStepProps) {
+  const [code, setCode] = useState('');
+  const [error, setError] = useState('');
+  const [isLoading, setIsLoading] = useState(false);
+  const [enrollment, setEnrollment] = useState<TwoFactorEnrollment | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+
+  useEffect(() => {
+    if (step !== 'enroll') return;
+    authApi
+      .enrollTwoFactor(challenge)
+      .then(setEnrollment)
+      .catch((err) => setError(err instanceof Error ? err.message : 'Failed to start enrollment'));
+  }, [step, challenge]);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setIsLoading(true);
+
+    try {
+      const response = await authApi.verifyTwoFactor(challenge, code);
+      if (response.recovery_codes?.length) {
+        setRecoveryCodes(response.recovery_codes);
+      } else {
+        onDone();
+      }
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Verification failed');
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <FieldGroup>
+        <FieldDescription>
+          Save these recovery codes somewhere safe. Each one logs you in once if you lose your device, and they are not shown again.
+        </FieldDescription>
+        <div className="grid grid-cols-2 gap-2 rounded-md border bg-muted/50 p-3 font-mono text-sm">
+          {recoveryCodes.map((recoveryCode) => (
+            <span key={recoveryCode}>{recoveryCode}</span>
+          ))}
+        </div>
+        <Field>
+          <Button className="w-full" onClick={onDone}>
+            I have saved the codes
+          </Button>
+        </Field>
+      </FieldGroup>
+    );
+  }
+
+  return (
+    <form onSubmit={handleSubmit}>
+      <FieldGroup>
+        {step === 'enroll' && (
+          <>
+            <FieldDescription>
+              Your account requires two-factor authentication. Scan the code with an authenticator app, then enter the code it shows.
+            </FieldDescription>
+            {enrollment && (
+              <div className="flex flex-col items-center gap-2">
+                <img src={enrollment.qr_code} alt="Authenticator QR code" className="size-44 rounded-md bg-white p-2" />
+                <code className="text-xs break-all text-center">{enrollment.secret}</code>
+              </div>
+            )}
+          </>
+        )}
+        <Field>
+          <FieldLabel htmlFor="code">
+            {step === 'enroll' ? 'Code' : 'Authentication code'}
+          </FieldLabel>
+          <Input
+            id="code"
+            autoComplete="one-time-code"
+            autoFocus
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+            required
+            disabled={isLoading}
+          />
+          {step === 'verify' && (
+            <FieldDescription>
+              Enter the code from your authenticator app, or one of your recovery codes.
+            </FieldDescription>
+          )}
+        </Field>
+        {error && <FieldError>{error}</FieldError>}
+        <Field>
+          <Button type="submit" className="w-full" disabled={isLoading}>
+            {isLoading ? 'Verifying...' : 'Verify'}
+          </Button>
+          <Button type="button" variant="ghost" className="w-full" onClick={onCancel} disabled={isLoading}>
+            Back to login
+          </Button>
+        </Field>
+      </FieldGroup>
+    </form>
+  );
+}
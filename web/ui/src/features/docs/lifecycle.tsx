@@ -439,7 +439,8 @@ $service.start(() => {
             <p className="text-xs text-muted-foreground">
               <code className="font-mono bg-muted px-1 rounded">$database</code>{' '}
               <code className="font-mono bg-muted px-1 rounded">$storage</code>{' '}
-              <code className="font-mono bg-muted px-1 rounded">$goals</code>
+              <code className="font-mono bg-muted px-1 rounded">$goals</code>{' '}
+              <code className="font-mono bg-muted px-1 rounded">$auth</code>
             </p>
           </div>
           <div className="border rounded-lg p-3">
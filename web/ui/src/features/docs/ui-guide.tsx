@@ -385,6 +385,41 @@ $hook.onModelDelete("posts", (data) => {
             </p>
           </div>
         </div>
+
+        {/* Storage hooks */}
+        <div className="border rounded-lg p-4">
+          <h3 className="font-medium font-mono mb-2">$hook.onStorage(event, pathGlob, handler)</h3>
+          <p className="text-sm text-muted-foreground mb-3">
+            React to file changes in project storage, from uploads in the file manager as well as <code className="font-mono bg-muted px-1 rounded">$storage</code> calls.
+          </p>
+          <pre className="bg-muted rounded-md p-3 text-sm overflow-x-auto">
+            <code>{`// Generate thumbnails for uploaded photos
+$hook.onStorage("write", "uploads/**/*.jpg", (e, ctx) => {
+  ctx.logger.info("Uploaded", e.path, e.size, e.mimeType);
+  $image.thumbnail(e.path, "thumbs/" + e.path, 200);
+});
+
+// Clean up derived files
+$hook.onStorage("delete", "uploads/**/*.jpg", (e, ctx) => {
+  ctx.storage.delete("thumbs/" + e.path);
+});
+
+$hook.onStorage("rename", "docs/**", (e, ctx) => {
+  ctx.logger.info("Moved", e.oldPath, "to", e.path);
+});`}</code>
+          </pre>
+          <div className="mt-3 text-sm">
+            <p className="font-medium mb-1">Event fields:</p>
+            <ul className="text-muted-foreground space-y-1">
+              <li><code className="font-mono bg-muted px-1 rounded">event</code> - "write", "delete" or "rename"</li>
+              <li><code className="font-mono bg-muted px-1 rounded">path</code>, <code className="font-mono bg-muted px-1 rounded">oldPath</code> - paths relative to the storage root</li>
+              <li><code className="font-mono bg-muted px-1 rounded">size</code>, <code className="font-mono bg-muted px-1 rounded">mimeType</code>, <code className="font-mono bg-muted px-1 rounded">isDir</code></li>
+            </ul>
+            <p className="text-muted-foreground mt-2">
+              <strong>Note:</strong> <code className="font-mono bg-muted px-1 rounded">*</code> matches within a folder, <code className="font-mono bg-muted px-1 rounded">**</code> across folders. Copies and unzips report a write per file. Handlers run one change at a time, outside the request that made the change. Changes a handler makes through <code className="font-mono bg-muted px-1 rounded">ctx.storage</code> do not trigger hooks again; files written with <code className="font-mono bg-muted px-1 rounded">$storage</code>, <code className="font-mono bg-muted px-1 rounded">$image</code>, <code className="font-mono bg-muted px-1 rounded">$draw</code> or <code className="font-mono bg-muted px-1 rounded">$http</code> do, so keep them outside the handler's pattern.
+            </p>
+          </div>
+        </div>
       </section>
     </div>
   );
@@ -1,6 +1,32 @@
-import type { GoalType } from '@/types';
+import type { GoalMetric, GoalType } from '@/types';
 
 export const GOAL_TYPES: { value: GoalType; label: string }[] = [
   { value: 'counter', label: 'Counter' },
   { value: 'daily_counter', label: 'Daily Counter' },
+  { value: 'gauge', label: 'Gauge (last value)' },
+  { value: 'sum', label: 'Sum' },
+  { value: 'avg', label: 'Average' },
+  { value: 'histogram', label: 'Histogram (percentiles)' },
 ];
+
+export function isMetricGoal(type: GoalType): boolean {
+  return type === 'gauge' || type === 'sum' || type === 'avg' || type === 'histogram';
+}
+
+// Headline value of a stat: the count of counters, the last value of gauges,
+// the sum, the average, or the median of histograms
+export function goalStatValue(type: GoalType, stat?: { value: number; metric?: GoalMetric }): number {
+  if (!stat) return 0;
+  const metric = stat.metric;
+  if (!metric) return stat.value;
+  switch (type) {
+    case 'gauge':
+      return metric.last;
+    case 'sum':
+      return metric.sum;
+    case 'histogram':
+      return metric.percentiles?.p50 ?? metric.avg;
+    default:
+      return metric.avg;
+  }
+}
@@ -52,7 +52,7 @@ import {
 } from 'date-fns';
 
 import { goalsApi } from '@/api';
-import { GOAL_TYPES } from '@/features/goals/constants';
+import { GOAL_TYPES, goalStatValue, isMetricGoal } from '@/features/goals/constants';
 import { queryKeys } from '@/lib/query-keys';
 import { formatNumber, calculateTrend } from '@/lib/format';
 import { cn } from '@/lib/utils';
@@ -322,7 +322,7 @@ export function GoalsPage() {
       const stats = statsMap.get(goal.id);
       if (stats?.dailyStats) {
         stats.dailyStats.forEach(d => {
-          rows.push(`${d.date},"${goal.name}",${d.value}`);
+          rows.push(`${d.date},"${goal.name}",${goalStatValue(goal.type, d)}`);
         });
       }
     });
@@ -696,13 +696,13 @@ function SortableGoalCard({
 
   const chartData = stats?.dailyStats?.map((d) => ({
     date: new Date(d.date).toLocaleDateString('en', { month: 'short', day: 'numeric' }),
-    value: d.value,
+    value: goalStatValue(goal.type, d),
   })) || [];
 
   const trend = stats?.dailyStats && stats.dailyStats.length >= 2
     ? calculateTrend(
-        stats.dailyStats.slice(-7).map(d => d.value),
-        stats.dailyStats.slice(-14, -7).map(d => d.value)
+        stats.dailyStats.slice(-7).map(d => goalStatValue(goal.type, d)),
+        stats.dailyStats.slice(-14, -7).map(d => goalStatValue(goal.type, d))
       )
     : null;
 
@@ -811,11 +811,11 @@ function SortableGoalCard({
               <>
                 <p className="text-3xl font-bold">{formatNumber(stats.totalValue)}</p>
                 <p className="text-lg text-muted-foreground">
-                  / {formatNumber(stats?.value ?? 0)} today
+                  / {formatNumber(goalStatValue(goal.type, stats))} today
                 </p>
               </>
             ) : (
-              <p className="text-3xl font-bold">{formatNumber(stats?.value ?? 0)}</p>
+              <p className="text-3xl font-bold">{formatNumber(goalStatValue(goal.type, stats))}</p>
             )}
           </div>
           <div className="flex items-center gap-2 mt-1">
@@ -840,7 +840,9 @@ function SortableGoalCard({
 
         <div className="rounded-md bg-muted/50 p-2">
           <code className="text-xs text-muted-foreground">
-            $goals.increment("{goal.slug}")
+            {isMetricGoal(goal.type)
+              ? `$goals.record("${goal.slug}", value)`
+              : `$goals.increment("${goal.slug}")`}
           </code>
         </div>
       </CardContent>
@@ -0,0 +1,165 @@
+import { Fragment, useState } from 'react';
+import { useQuery } from '@tanstack/react-query';
+import { ChevronDown, ChevronRight, Download } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { auditApi } from '@/api';
+import type { AuditChange, AuditFilter } from '@/types';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Badge } from '@/components/ui/badge';
+import { Input } from '@/components/ui/input';
+import { formatDateTime } from '@/lib/format';
+import { downloadBlob } from '@/lib/utils';
+
+interface AuditLogCardProps {
+  projectId?: string; // Without a project the whole server log is shown, root only
+}
+
+function formatValue(value: unknown): string {
+  if (value === undefined) {
+    return '—';
+  }
+  return typeof value === 'string' ? value : JSON.stringify(value);
+}
+
+function ChangeList({ changes }: { changes: AuditChange[] }) {
+  return (
+    <div className="space-y-1 font-mono text-xs">
+      {changes.map((change) => (
+        <div key={change.path} className="flex flex-wrap gap-2">
+          <span className="font-medium">{change.path}</span>
+          <span className="text-destructive line-through break-all">{formatValue(change.before)}</span>
+          <span className="text-green-600 break-all">{formatValue(change.after)}</span>
+        </div>
+      ))}
+    </div>
+  );
+}
+
+// AuditLogCard lists administrative actions, newest first, with their changes
+export function AuditLogCard({ projectId }: AuditLogCardProps) {
+  const [action, setAction] = useState('');
+  const [from, setFrom] = useState('');
+  const [to, setTo] = useState('');
+  const [expanded, setExpanded] = useState<string | null>(null);
+  const [exporting, setExporting] = useState(false);
+
+  // Dates are whole days, "to" includes its day
+  const filter: AuditFilter = {
+    projectId,
+    action: action.trim() || undefined,
+    from: from ? new Date(from).toISOString() : undefined,
+    to: to ? new Date(new Date(to).getTime() + 24 * 60 * 60 * 1000).toISOString() : undefined,
+  };
+
+  const { data: entries = [] } = useQuery({
+    queryKey: ['audit', filter],
+    queryFn: () => auditApi.list(filter),
+  });
+
+  const handleExport = async () => {
+    setExporting(true);
+    try {
+      const blob = await auditApi.export(filter);
+      downloadBlob(blob, `audit-${projectId ?? 'server'}.jsonl`);
+    } catch (err) {
+      toast.error(err instanceof Error ? err.message : 'Failed to export the audit log');
+    } finally {
+      setExporting(false);
+    }
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between gap-4">
+          <div>
+            <CardTitle>Audit Log</CardTitle>
+            <CardDescription>Who changed what, newest first</CardDescription>
+          </div>
+          <Button variant="outline" size="sm" onClick={handleExport} disabled={exporting}>
+            <Download className="mr-1.5 size-4" />
+            Export JSONL
+          </Button>
+        </div>
+        <div className="flex flex-wrap gap-2 pt-2">
+          <Input
+            className="max-w-56"
+            placeholder="Action, e.g. release or env.update"
+            value={action}
+            onChange={(e) => setAction(e.target.value)}
+          />
+          <Input className="max-w-40" type="date" value={from} onChange={(e) => setFrom(e.target.value)} />
+          <Input className="max-w-40" type="date" value={to} onChange={(e) => setTo(e.target.value)} />
+        </div>
+      </CardHeader>
+      <CardContent>
+        {entries.length === 0 ? (
+          <p className="text-sm text-muted-foreground">No audited actions</p>
+        ) : (
+          <div className="border rounded-lg overflow-hidden">
+            <table className="w-full text-sm">
+              <thead className="bg-muted/50">
+                <tr>
+                  <th className="w-8 p-3"></th>
+                  <th className="text-left font-medium p-3">When</th>
+                  <th className="text-left font-medium p-3">Actor</th>
+                  <th className="text-left font-medium p-3">Action</th>
+                  <th className="text-left font-medium p-3">Target</th>
+                </tr>
+              </thead>
+              <tbody>
+                {entries.map((entry) => {
+                  const hasChanges = !!entry.changes?.length;
+                  const isExpanded = expanded === entry.id;
+                  return (
+                    <Fragment key={entry.id}>
+                      <tr
+                        className={`border-t ${hasChanges ? 'cursor-pointer hover:bg-muted/30' : ''}`}
+                        onClick={() => hasChanges && setExpanded(isExpanded ? null : entry.id)}
+                      >
+                        <td className="p-3 text-muted-foreground">
+                          {hasChanges &&
+                            (isExpanded ? <ChevronDown className="size-4" /> : <ChevronRight className="size-4" />)}
+                        </td>
+                        <td className="p-3 text-muted-foreground whitespace-nowrap">
+                          {formatDateTime(entry.created_at)}
+                        </td>
+                        <td className="p-3">
+                          <div className="truncate max-w-56">{entry.actor_email}</div>
+                          <div className="text-xs text-muted-foreground font-mono">
+                            {entry.ip}
+                            {entry.token_id && ' · token'}
+                          </div>
+                        </td>
+                        <td className="p-3">
+                          <Badge variant="outline">{entry.action}</Badge>
+                        </td>
+                        <td className="p-3 truncate max-w-64">{entry.target || '—'}</td>
+                      </tr>
+                      {isExpanded && entry.changes && (
+                        <tr className="border-t bg-muted/20">
+                          <td></td>
+                          <td className="p-3" colSpan={4}>
+                            <ChangeList changes={entry.changes} />
+                          </td>
+                        </tr>
+                      )}
+                    </Fragment>
+                  );
+                })}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
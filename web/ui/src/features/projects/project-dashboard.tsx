@@ -19,6 +19,7 @@ import {
   BarChart3,
   Minus,
   ScrollText,
+  Webhook,
   Zap,
 } from 'lucide-react';
 import { toast } from 'sonner';
@@ -59,6 +60,7 @@ import { StatusBadge } from '@/components/shared/status-badge';
 import { Skeleton } from '@/components/ui/skeleton';
 import { LogsViewer } from '@/components/shared/logs-viewer';
 import { WidgetCard } from '@/components/shared/widget-card';
+import { WebhookDeliveriesCard } from './webhook-deliveries-card';
 import {
   Select,
   SelectContent,
@@ -85,7 +87,7 @@ import {
 import { RadioGroup, RadioGroupItem } from '@/components/ui/radio-group';
 import { Label } from '@/components/ui/label';
 
-type OverviewTab = 'instance' | 'logs';
+type OverviewTab = 'instance' | 'logs' | 'webhooks';
 
 const WIDGET_TYPES: { value: WidgetType; label: string; description: string }[] = [
   { value: 'goal', label: 'Goal', description: 'Display goal metrics' },
@@ -555,6 +557,13 @@ export function ProjectDashboard() {
           >
             Logs
           </EditorTab>
+          <EditorTab
+            active={activeTab === 'webhooks'}
+            onClick={() => setActiveTab('webhooks')}
+            icon={<Webhook className="size-4" />}
+          >
+            Webhooks
+          </EditorTab>
         </EditorTabs>
 
         {/* Instance Tab */}
@@ -645,6 +654,16 @@ export function ProjectDashboard() {
             />
           </Card>
         )}
+
+        {/* Webhooks Tab */}
+        {activeTab === 'webhooks' && (
+          <div className="bg-background rounded-b-xl">
+            <div className="border-b" />
+            <div className="px-0 py-4">
+              <WebhookDeliveriesCard projectId={projectId!} isRunning={isRunning} />
+            </div>
+          </div>
+        )}
       </div>
 
       {/* Widget Dialog (Add/Edit) */}
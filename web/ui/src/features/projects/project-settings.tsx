@@ -7,7 +7,7 @@ import { toast } from 'sonner';
 import { projectsApi, usersApi } from '@/api';
 import { useAuth } from '@/providers/auth-provider';
 import { useTitle } from '@/hooks';
-import type { UpdateProjectRequest } from '@/types';
+import type { ProjectRole, UpdateProjectRequest } from '@/types';
 import { Button } from '@/components/ui/button';
 import {
   Card,
@@ -38,6 +38,14 @@ import { ColorPicker } from '@/components/shared/color-picker';
 import { ConfirmDialog } from '@/components/shared/confirm-dialog';
 import { Skeleton } from '@/components/ui/skeleton';
 import { slugify, copyToClipboard } from '@/lib/utils';
+import { AuditLogCard } from './audit-log-card';
+
+const roleDescriptions: Record<ProjectRole, string> = {
+  viewer: 'Read-only access to the dashboard, logs, data and files',
+  developer: 'Edits code, models, data and files, runs debug builds',
+  operator: 'Ships releases, controls the runtime and environment',
+  admin: 'Full access, including settings and members',
+};
 
 export function ProjectSettings() {
   const { projectId } = useParams<{ projectId: string }>();
@@ -48,6 +56,7 @@ export function ProjectSettings() {
   const [name, setName] = useState('');
   const [slug, setSlug] = useState('');
   const [color, setColor] = useState<string | undefined>();
+  const [timezone, setTimezone] = useState('');
   const [hasChanges, setHasChanges] = useState(false);
 
   const [deleteDialogOpen, setDeleteDialogOpen] = useState(false);
@@ -55,6 +64,7 @@ export function ProjectSettings() {
   const [regenerateDialogOpen, setRegenerateDialogOpen] = useState(false);
   const [addMemberDialogOpen, setAddMemberDialogOpen] = useState(false);
   const [selectedUserId, setSelectedUserId] = useState('');
+  const [selectedRole, setSelectedRole] = useState<ProjectRole>('developer');
   const [showApiKey, setShowApiKey] = useState(false);
   const [apiKeyCopied, setApiKeyCopied] = useState(false);
 
@@ -66,6 +76,12 @@ export function ProjectSettings() {
 
   useTitle(project ? `Settings - ${project.name}` : null);
 
+  const { data: membersData } = useQuery({
+    queryKey: ['project-members', projectId],
+    queryFn: () => projectsApi.listMembers(projectId!),
+    enabled: !!projectId,
+  });
+
   const { data: users = [] } = useQuery({
     queryKey: ['users'],
     queryFn: usersApi.list,
@@ -78,6 +94,7 @@ export function ProjectSettings() {
       setName(project.name);
       setSlug(project.slug);
       setColor(project.color);
+      setTimezone(project.timezone || '');
     }
   });
 
@@ -86,6 +103,7 @@ export function ProjectSettings() {
     if (name !== project.name) setName(project.name);
     if (slug !== project.slug) setSlug(project.slug);
     if (color !== project.color) setColor(project.color);
+    if (timezone !== (project.timezone || '')) setTimezone(project.timezone || '');
   }
 
   const updateMutation = useMutation({
@@ -127,11 +145,13 @@ export function ProjectSettings() {
   });
 
   const addMemberMutation = useMutation({
-    mutationFn: (userId: string) => projectsApi.addMember(projectId!, userId),
+    mutationFn: (userId: string) => projectsApi.addMember(projectId!, userId, selectedRole),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ['project', projectId] });
+      queryClient.invalidateQueries({ queryKey: ['project-members', projectId] });
       setAddMemberDialogOpen(false);
       setSelectedUserId('');
+      setSelectedRole('developer');
       toast.success('Member added');
     },
     onError: (err) => {
@@ -143,6 +163,7 @@ export function ProjectSettings() {
     mutationFn: (userId: string) => projectsApi.removeMember(projectId!, userId),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ['project', projectId] });
+      queryClient.invalidateQueries({ queryKey: ['project-members', projectId] });
       toast.success('Member removed');
     },
     onError: (err) => {
@@ -150,8 +171,20 @@ export function ProjectSettings() {
     },
   });
 
+  const setMemberRoleMutation = useMutation({
+    mutationFn: ({ userId, role }: { userId: string; role: ProjectRole }) =>
+      projectsApi.setMemberRole(projectId!, userId, role),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['project-members', projectId] });
+      toast.success('Member role updated');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to update role');
+    },
+  });
+
   const handleSave = () => {
-    updateMutation.mutate({ name, slug, color });
+    updateMutation.mutate({ name, slug, color, timezone: timezone.trim() });
   };
 
   const handleFieldChange = () => {
@@ -193,10 +226,14 @@ export function ProjectSettings() {
   }
 
   const isOwner = project.owner_id === user?.id || user?.is_root;
+  const canManageMembers = !!membersData?.current.permissions.includes('members.manage');
+  const canManageSettings = !!membersData?.current.permissions.includes('settings.manage');
+  const canReadAudit = !!membersData?.current.permissions.includes('audit.read');
   const availableUsers = users.filter(
     (u) => u.id !== project.owner_id && !project.members.includes(u.id)
   );
-  const memberUsers = users.filter((u) => project.members.includes(u.id));
+  const members = membersData?.members ?? [];
+  const roles = Object.keys(roleDescriptions) as ProjectRole[];
 
   return (
     <div className="space-y-6 max-w-2xl">
@@ -250,6 +287,28 @@ export function ProjectSettings() {
                 }}
               />
             </Field>
+            <Field>
+              <FieldLabel htmlFor="timezone">Timezone</FieldLabel>
+              <Input
+                id="timezone"
+                value={timezone}
+                placeholder="UTC"
+                list="timezones"
+                onChange={(e) => {
+                  setTimezone(e.target.value);
+                  handleFieldChange();
+                }}
+              />
+              <datalist id="timezones">
+                {Intl.supportedValuesOf('timeZone').map((tz) => (
+                  <option key={tz} value={tz} />
+                ))}
+              </datalist>
+              <FieldDescription>
+                IANA name, e.g. Europe/Moscow. Goal days, $utils.formatDate and $schedule follow it;
+                schedules pick up changes on the next start.
+              </FieldDescription>
+            </Field>
             <LoadingButton className="w-fit" onClick={handleSave} loading={updateMutation.isPending}>
               Save Changes
             </LoadingButton>
@@ -258,16 +317,17 @@ export function ProjectSettings() {
       </Card>
 
       {/* Members */}
-      {isOwner && (
+      {membersData && (
         <Card>
           <CardHeader>
             <div className="flex items-center justify-between">
               <div>
                 <CardTitle>Members</CardTitle>
                 <CardDescription>
-                  Users who have access to this project
+                  Users who have access to this project and their roles
                 </CardDescription>
               </div>
+              {canManageMembers && (
               <Button
                 variant="outline"
                 size="sm"
@@ -277,37 +337,63 @@ export function ProjectSettings() {
                 <UserPlus className="mr-2 size-4" />
                 Add Member
               </Button>
+              )}
             </div>
           </CardHeader>
           <CardContent>
             <div className="space-y-2">
-              {memberUsers.length === 0 ? (
-                <p className="text-sm text-muted-foreground">
-                  No additional members
-                </p>
-              ) : (
-                memberUsers.map((member) => (
-                  <div
-                    key={member.id}
-                    className="flex items-center justify-between py-2"
-                  >
-                    <div>
-                      <p className="font-medium">{member.name}</p>
-                      <p className="text-sm text-muted-foreground">
-                        {member.email}
-                      </p>
-                    </div>
-                    <Button
-                      variant="ghost"
-                      size="icon"
-                      onClick={() => removeMemberMutation.mutate(member.id)}
-                      disabled={removeMemberMutation.isPending}
-                    >
-                      <X className="size-4" />
-                    </Button>
+              {members.map((member) => (
+                <div
+                  key={member.user_id}
+                  className="flex items-center justify-between gap-4 py-2"
+                >
+                  <div className="min-w-0">
+                    <p className="font-medium truncate">{member.name || member.user_id}</p>
+                    <p className="text-sm text-muted-foreground truncate">
+                      {member.email}
+                    </p>
                   </div>
-                ))
-              )}
+                  <div className="flex items-center gap-2">
+                    {member.owner ? (
+                      <span className="text-sm text-muted-foreground">Owner</span>
+                    ) : canManageMembers ? (
+                      <>
+                        <Select
+                          value={member.role}
+                          onValueChange={(role) =>
+                            setMemberRoleMutation.mutate({
+                              userId: member.user_id,
+                              role: role as ProjectRole,
+                            })
+                          }
+                          disabled={setMemberRoleMutation.isPending}
+                        >
+                          <SelectTrigger className="w-32">
+                            <SelectValue />
+                          </SelectTrigger>
+                          <SelectContent>
+                            {roles.map((role) => (
+                              <SelectItem key={role} value={role}>
+                                {role}
+                              </SelectItem>
+                            ))}
+                          </SelectContent>
+                        </Select>
+                        <Button
+                          variant="ghost"
+                          size="icon"
+                          onClick={() => removeMemberMutation.mutate(member.user_id)}
+                          disabled={removeMemberMutation.isPending}
+                        >
+                          <X className="size-4" />
+                        </Button>
+                      </>
+                    ) : (
+                      <span className="text-sm text-muted-foreground">{member.role}</span>
+                    )}
+                  </div>
+                </div>
+              ))}
             </div>
           </CardContent>
         </Card>
@@ -350,7 +436,7 @@ export function ProjectSettings() {
                 <Copy className="size-4" />
               )}
             </Button>
-            {isOwner && (
+            {canManageSettings && (
               <Button
                 variant="outline"
                 size="icon"
@@ -369,6 +455,9 @@ export function ProjectSettings() {
         </CardContent>
       </Card>
 
+      {/* Audit Log */}
+      {canReadAudit && <AuditLogCard projectId={project.id} />}
+
       {/* Danger Zone */}
       {isOwner && (
         <Card className="border-destructive/50">
@@ -473,6 +562,25 @@ export function ProjectSettings() {
               </SelectContent>
             </Select>
           </Field>
+          <Field>
+            <FieldLabel>Role</FieldLabel>
+            <Select
+              value={selectedRole}
+              onValueChange={(role) => setSelectedRole(role as ProjectRole)}
+            >
+              <SelectTrigger>
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                {roles.map((role) => (
+                  <SelectItem key={role} value={role}>
+                    {role}
+                  </SelectItem>
+                ))}
+              </SelectContent>
+            </Select>
+            <FieldDescription>{roleDescriptions[selectedRole]}</FieldDescription>
+          </Field>
           <DialogFooter>
             <Button
               variant="outline"
@@ -0,0 +1,240 @@
+import { Fragment, useState } from 'react';
+import { useMutation, useQuery, useQueryClient } from '@tanstack/react-query';
+import { ChevronDown, ChevronRight, RefreshCw, RotateCcw } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { webhooksApi } from '@/api';
+import { queryKeys } from '@/lib/query-keys';
+import type { WebhookDelivery, WebhookDeliveryStatus } from '@/types';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Badge } from '@/components/ui/badge';
+import { Input } from '@/components/ui/input';
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from '@/components/ui/select';
+import { formatDateTime } from '@/lib/format';
+
+interface WebhookDeliveriesCardProps {
+  projectId: string;
+  isRunning: boolean; // Replays need the running handler
+}
+
+const statusVariants: Record<WebhookDeliveryStatus, 'default' | 'secondary' | 'destructive' | 'outline'> = {
+  delivered: 'default',
+  failed: 'destructive',
+  rejected: 'destructive',
+  duplicate: 'secondary',
+};
+
+// formatPayload pretty-prints JSON payloads, other text is shown as is
+function formatPayload(delivery: WebhookDelivery): string {
+  if (delivery.payload_encoding === 'base64') {
+    return `(binary, base64)\n${delivery.payload ?? ''}`;
+  }
+  try {
+    return JSON.stringify(JSON.parse(delivery.payload ?? ''), null, 2);
+  } catch {
+    return delivery.payload ?? '';
+  }
+}
+
+function DeliveryDetails({ projectId, deliveryId }: { projectId: string; deliveryId: string }) {
+  const { data: delivery, isLoading } = useQuery({
+    queryKey: queryKeys.webhooks.delivery(projectId, deliveryId),
+    queryFn: () => webhooksApi.getDelivery(projectId, deliveryId),
+  });
+
+  if (isLoading || !delivery) {
+    return <p className="text-xs text-muted-foreground">Loading…</p>;
+  }
+
+  return (
+    <div className="space-y-3 text-xs">
+      {delivery.error && <p className="text-destructive font-mono break-all">{delivery.error}</p>}
+      <div className="space-y-1 font-mono">
+        {Object.entries(delivery.headers ?? {})
+          .sort(([a], [b]) => a.localeCompare(b))
+          .map(([name, value]) => (
+            <div key={name} className="flex gap-2">
+              <span className="font-medium shrink-0">{name}:</span>
+              <span className="text-muted-foreground break-all">{value}</span>
+            </div>
+          ))}
+      </div>
+      {delivery.status === 'rejected' ? (
+        <p className="text-muted-foreground">
+          The payload of {delivery.body_size} bytes did not verify and was not kept
+        </p>
+      ) : (
+        <pre className="bg-muted/50 rounded-md p-3 max-h-96 overflow-auto whitespace-pre-wrap break-all">
+          {formatPayload(delivery)}
+        </pre>
+      )}
+      {delivery.body_truncated && (
+        <p className="text-muted-foreground">
+          The payload of {delivery.body_size} bytes was truncated and cannot be replayed
+        </p>
+      )}
+    </div>
+  );
+}
+
+// WebhookDeliveriesCard lists the deliveries of the project's webhooks,
+// newest first, with their payloads and a replay action
+export function WebhookDeliveriesCard({ projectId, isRunning }: WebhookDeliveriesCardProps) {
+  const queryClient = useQueryClient();
+  const [webhook, setWebhook] = useState('');
+  const [status, setStatus] = useState<WebhookDeliveryStatus | 'all'>('all');
+  const [expanded, setExpanded] = useState<string | null>(null);
+
+  const filter = {
+    webhook: webhook.trim() || undefined,
+    status: status === 'all' ? undefined : status,
+  };
+
+  const { data: deliveries = [], refetch, isFetching } = useQuery({
+    queryKey: [...queryKeys.webhooks.deliveries(projectId), filter],
+    queryFn: () => webhooksApi.listDeliveries(projectId, filter),
+  });
+
+  const replayMutation = useMutation({
+    mutationFn: (deliveryId: string) => webhooksApi.replay(projectId, deliveryId),
+    onSuccess: (replay) => {
+      queryClient.invalidateQueries({ queryKey: queryKeys.webhooks.deliveries(projectId) });
+      if (replay.status === 'delivered') {
+        toast.success(`Replayed, the handler answered ${replay.response_status}`);
+      } else {
+        toast.error(replay.error ? `Replay failed: ${replay.error}` : `Replay failed with ${replay.response_status}`);
+      }
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to replay the delivery');
+    },
+  });
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between gap-4">
+          <div>
+            <CardTitle>Webhook Deliveries</CardTitle>
+            <CardDescription>Requests to $router.webhook routes, kept for 30 days</CardDescription>
+          </div>
+          <Button variant="outline" size="sm" onClick={() => refetch()} disabled={isFetching}>
+            <RefreshCw className="mr-1.5 size-4" />
+            Refresh
+          </Button>
+        </div>
+        <div className="flex flex-wrap gap-2 pt-2">
+          <Input
+            className="max-w-56"
+            placeholder="Webhook path, e.g. /stripe"
+            value={webhook}
+            onChange={(e) => setWebhook(e.target.value)}
+          />
+          <Select value={status} onValueChange={(v) => setStatus(v as WebhookDeliveryStatus | 'all')}>
+            <SelectTrigger className="w-40">
+              <SelectValue />
+            </SelectTrigger>
+            <SelectContent>
+              <SelectItem value="all">All statuses</SelectItem>
+              <SelectItem value="delivered">Delivered</SelectItem>
+              <SelectItem value="failed">Failed</SelectItem>
+              <SelectItem value="rejected">Rejected</SelectItem>
+              <SelectItem value="duplicate">Duplicate</SelectItem>
+            </SelectContent>
+          </Select>
+        </div>
+      </CardHeader>
+      <CardContent>
+        {deliveries.length === 0 ? (
+          <p className="text-sm text-muted-foreground">No webhook deliveries</p>
+        ) : (
+          <div className="border rounded-lg overflow-hidden">
+            <table className="w-full text-sm">
+              <thead className="bg-muted/50">
+                <tr>
+                  <th className="w-8 p-3"></th>
+                  <th className="text-left font-medium p-3">When</th>
+                  <th className="text-left font-medium p-3">Webhook</th>
+                  <th className="text-left font-medium p-3">Delivery</th>
+                  <th className="text-left font-medium p-3">Status</th>
+                  <th className="text-left font-medium p-3">Response</th>
+                  <th className="p-3"></th>
+                </tr>
+              </thead>
+              <tbody>
+                {deliveries.map((delivery) => {
+                  const isExpanded = expanded === delivery.id;
+                  return (
+                    <Fragment key={delivery.id}>
+                      <tr
+                        className="border-t cursor-pointer hover:bg-muted/30"
+                        onClick={() => setExpanded(isExpanded ? null : delivery.id)}
+                      >
+                        <td className="p-3 text-muted-foreground">
+                          {isExpanded ? <ChevronDown className="size-4" /> : <ChevronRight className="size-4" />}
+                        </td>
+                        <td className="p-3 text-muted-foreground whitespace-nowrap">
+                          {formatDateTime(delivery.created_at)}
+                        </td>
+                        <td className="p-3">
+                          <div className="font-mono truncate max-w-48">{delivery.webhook}</div>
+                          <div className="text-xs text-muted-foreground">{delivery.provider}</div>
+                        </td>
+                        <td className="p-3 font-mono text-xs truncate max-w-48">
+                          {delivery.delivery_id || '—'}
+                          {delivery.replay_of && <Badge variant="outline" className="ml-2">replay</Badge>}
+                        </td>
+                        <td className="p-3">
+                          <Badge variant={statusVariants[delivery.status]}>{delivery.status}</Badge>
+                        </td>
+                        <td className="p-3 text-muted-foreground whitespace-nowrap">
+                          {delivery.response_status} · {delivery.duration_ms} ms
+                        </td>
+                        <td className="p-3 text-right">
+                          <Button
+                            variant="ghost"
+                            size="sm"
+                            disabled={!isRunning || delivery.body_truncated || delivery.status === 'rejected' || replayMutation.isPending}
+                            title={isRunning ? 'Run the handler with this payload again' : 'Start the project to replay'}
+                            onClick={(e) => {
+                              e.stopPropagation();
+                              replayMutation.mutate(delivery.id);
+                            }}
+                          >
+                            <RotateCcw className="mr-1.5 size-4" />
+                            Replay
+                          </Button>
+                        </td>
+                      </tr>
+                      {isExpanded && (
+                        <tr className="border-t bg-muted/20">
+                          <td></td>
+                          <td className="p-3" colSpan={6}>
+                            <DeliveryDetails projectId={projectId} deliveryId={delivery.id} />
+                          </td>
+                        </tr>
+                      )}
+                    </Fragment>
+                  );
+                })}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
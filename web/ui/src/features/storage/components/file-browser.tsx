@@ -22,6 +22,9 @@ import {
   Loader2,
   CheckCircle,
   XCircle,
+  Lock,
+  LockOpen,
+  KeyRound,
 } from 'lucide-react';
 import { toast } from 'sonner';
 
@@ -212,6 +215,17 @@ export function FileBrowser({
     },
   });
 
+  const visibilityMutation = useMutation({
+    mutationFn: (item: StorageItem) => storageApi.setVisibility(projectId, item.path, !item.private),
+    onSuccess: (_, item) => {
+      queryClient.invalidateQueries({ queryKey: ['storage', projectId] });
+      toast.success(item.private ? 'Made public' : 'Made private');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to change visibility');
+    },
+  });
+
   const deleteSelectedMutation = useMutation({
     mutationFn: async () => {
       const paths = Array.from(selectedPaths);
@@ -447,6 +461,16 @@ export function FileBrowser({
     }
   };
 
+  const handleCopySignedLink = async (item: StorageItem) => {
+    try {
+      const signed = await storageApi.sign(projectId, { path: item.path, expires_in: 24 * 60 * 60 });
+      await navigator.clipboard.writeText(signed.url);
+      toast.success('Signed link copied (valid for 24 hours)');
+    } catch {
+      toast.error('Failed to create signed link');
+    }
+  };
+
   const pathSegments = currentPath ? currentPath.split('/').filter(Boolean) : [];
 
   const navigateToPath = (index: number) => {
@@ -608,6 +632,7 @@ export function FileBrowser({
                   <div className="flex items-center gap-3 flex-1 min-w-0">
                     <FileIcon item={item} />
                     <span className="truncate">{item.name}</span>
+                    {item.private && <Lock className="size-3.5 text-muted-foreground shrink-0" />}
                   </div>
                   {!item.is_dir && (
                     <div className="flex items-center gap-6 text-sm text-muted-foreground">
@@ -647,6 +672,10 @@ export function FileBrowser({
                           <Link className="mr-2 size-4" />
                           Copy link
                         </ContextMenuItem>
+                        <ContextMenuItem onClick={() => handleCopySignedLink(item)}>
+                          <KeyRound className="mr-2 size-4" />
+                          Copy signed link
+                        </ContextMenuItem>
                         <ContextMenuItem onClick={() => handleDownload(item)}>
                           <Download className="mr-2 size-4" />
                           Download
@@ -660,6 +689,14 @@ export function FileBrowser({
                       </>
                     )}
                     <ContextMenuSeparator />
+                    <ContextMenuItem onClick={() => visibilityMutation.mutate(item)}>
+                      {item.private ? (
+                        <LockOpen className="mr-2 size-4" />
+                      ) : (
+                        <Lock className="mr-2 size-4" />
+                      )}
+                      {item.private ? 'Make public' : 'Make private'}
+                    </ContextMenuItem>
                     <ContextMenuItem
                       onClick={() => {
                         setSelectedItem(item);
@@ -0,0 +1,328 @@
+import { useState } from 'react';
+import { useMutation, useQuery, useQueryClient } from '@tanstack/react-query';
+import { Check, Copy, KeyRound, Plus, Trash2 } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { projectsApi, usersApi } from '@/api';
+import type { AccessToken, ProjectPermission } from '@/types';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from '@/components/ui/dialog';
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from '@/components/ui/select';
+import { Badge } from '@/components/ui/badge';
+import { Checkbox } from '@/components/ui/checkbox';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import { LoadingButton } from '@/components/ui/loading-button';
+import { Field, FieldDescription, FieldLabel } from '@/components/ui/field';
+import { ConfirmDialog } from '@/components/shared/confirm-dialog';
+import { copyToClipboard } from '@/lib/utils';
+import { formatDate, formatRelativeTime } from '@/lib/format';
+
+const scopes: { value: ProjectPermission; label: string }[] = [
+  { value: 'view', label: 'View projects, runtime status and logs' },
+  { value: 'code.edit', label: 'Edit branches and files' },
+  { value: 'releases.manage', label: 'Create and activate releases' },
+  { value: 'runtime.control', label: 'Start, stop and restart, trigger actions' },
+  { value: 'models.manage', label: 'Manage model schemas' },
+  { value: 'data.read', label: 'Read model data' },
+  { value: 'data.write', label: 'Write model data' },
+  { value: 'env.read', label: 'Read environment variables' },
+  { value: 'env.write', label: 'Change environment variables' },
+  { value: 'storage.read', label: 'List and download files' },
+  { value: 'storage.write', label: 'Upload, change and delete files' },
+  { value: 'dashboard.manage', label: 'Manage goals, widgets, actions and alerts' },
+  { value: 'settings.manage', label: 'Change project settings' },
+  { value: 'members.manage', label: 'Manage project members' },
+  { value: 'audit.read', label: 'Read the audit log' },
+];
+
+const expirations = [
+  { value: '7', label: '7 days' },
+  { value: '30', label: '30 days' },
+  { value: '90', label: '90 days' },
+  { value: '365', label: '1 year' },
+  { value: 'never', label: 'Never' },
+];
+
+function toggle<T>(list: T[], value: T, checked: boolean): T[] {
+  return checked ? [...list, value] : list.filter((v) => v !== value);
+}
+
+export function AccessTokensCard() {
+  const queryClient = useQueryClient();
+
+  const [createOpen, setCreateOpen] = useState(false);
+  const [name, setName] = useState('');
+  const [selectedScopes, setSelectedScopes] = useState<ProjectPermission[]>(['view']);
+  const [projectIds, setProjectIds] = useState<string[]>([]);
+  const [expiration, setExpiration] = useState('30');
+  const [createdToken, setCreatedToken] = useState<string | null>(null);
+  const [copied, setCopied] = useState(false);
+  const [revokeToken, setRevokeToken] = useState<AccessToken | null>(null);
+
+  const { data: tokens = [] } = useQuery({
+    queryKey: ['access-tokens'],
+    queryFn: usersApi.listTokens,
+  });
+
+  const { data: projects = [] } = useQuery({
+    queryKey: ['projects'],
+    queryFn: projectsApi.list,
+    enabled: createOpen,
+  });
+
+  const createMutation = useMutation({
+    mutationFn: () =>
+      usersApi.createToken({
+        name,
+        scopes: selectedScopes,
+        project_ids: projectIds,
+        expires_at:
+          expiration === 'never'
+            ? undefined
+            : new Date(Date.now() + Number(expiration) * 86_400_000).toISOString(),
+      }),
+    onSuccess: (response) => {
+      queryClient.invalidateQueries({ queryKey: ['access-tokens'] });
+      setCreatedToken(response.token);
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to create token');
+    },
+  });
+
+  const revokeMutation = useMutation({
+    mutationFn: (id: string) => usersApi.revokeToken(id),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['access-tokens'] });
+      setRevokeToken(null);
+      toast.success('Token revoked');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to revoke token');
+    },
+  });
+
+  const handleCreateOpen = (open: boolean) => {
+    setCreateOpen(open);
+    if (!open) {
+      setName('');
+      setSelectedScopes(['view']);
+      setProjectIds([]);
+      setExpiration('30');
+      setCreatedToken(null);
+      setCopied(false);
+    }
+  };
+
+  const copyToken = async () => {
+    if (createdToken && (await copyToClipboard(createdToken))) {
+      setCopied(true);
+      toast.success('Token copied to clipboard');
+    }
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between">
+          <div>
+            <CardTitle>Access Tokens</CardTitle>
+            <CardDescription>
+              Tokens for scripts and CI, sent as a Bearer token to project API routes
+            </CardDescription>
+          </div>
+          <Button variant="outline" size="sm" onClick={() => setCreateOpen(true)}>
+            <Plus className="mr-2 size-4" />
+            New Token
+          </Button>
+        </div>
+      </CardHeader>
+      <CardContent>
+        {tokens.length === 0 ? (
+          <p className="text-sm text-muted-foreground">No access tokens</p>
+        ) : (
+          <div className="space-y-2">
+            {tokens.map((token) => {
+              const expired = !!token.expires_at && new Date(token.expires_at) <= new Date();
+              return (
+                <div key={token.id} className="flex items-center justify-between gap-4 py-2">
+                  <div className="min-w-0">
+                    <p className="font-medium truncate flex items-center gap-2">
+                      <KeyRound className="size-4 text-muted-foreground" />
+                      {token.name}
+                      <code className="text-xs text-muted-foreground">{token.hint}…</code>
+                      {expired && <Badge variant="destructive">Expired</Badge>}
+                    </p>
+                    <p className="text-sm text-muted-foreground truncate">
+                      {token.scopes.join(', ')}
+                      {token.project_ids?.length ? ` · ${token.project_ids.length} project(s)` : ' · all projects'}
+                    </p>
+                    <p className="text-xs text-muted-foreground">
+                      {token.last_used_at
+                        ? `Last used ${formatRelativeTime(token.last_used_at)}${token.last_used_ip ? ` from ${token.last_used_ip}` : ''}`
+                        : 'Never used'}
+                      {' · '}
+                      {token.expires_at ? `expires ${formatDate(token.expires_at)}` : 'never expires'}
+                    </p>
+                  </div>
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    title="Revoke token"
+                    onClick={() => setRevokeToken(token)}
+                  >
+                    <Trash2 className="size-4" />
+                  </Button>
+                </div>
+              );
+            })}
+          </div>
+        )}
+      </CardContent>
+
+      <Dialog open={createOpen} onOpenChange={handleCreateOpen}>
+        <DialogContent className="max-w-lg">
+          <DialogHeader>
+            <DialogTitle>{createdToken ? 'Token Created' : 'New Access Token'}</DialogTitle>
+            <DialogDescription>
+              {createdToken
+                ? 'Copy the token now, it will not be shown again.'
+                : 'The token acts as you, limited to the selected scopes and projects.'}
+            </DialogDescription>
+          </DialogHeader>
+
+          {createdToken ? (
+            <div className="flex gap-2">
+              <Input value={createdToken} readOnly className="font-mono text-xs" />
+              <Button variant="outline" size="icon" onClick={copyToken}>
+                {copied ? <Check className="size-4 text-green-500" /> : <Copy className="size-4" />}
+              </Button>
+            </div>
+          ) : (
+            <div className="space-y-4 max-h-[60vh] overflow-y-auto pr-1">
+              <Field>
+                <FieldLabel>Name</FieldLabel>
+                <Input
+                  value={name}
+                  onChange={(e) => setName(e.target.value)}
+                  placeholder="CI releases"
+                />
+              </Field>
+              <Field>
+                <FieldLabel>Expiration</FieldLabel>
+                <Select value={expiration} onValueChange={setExpiration}>
+                  <SelectTrigger>
+                    <SelectValue />
+                  </SelectTrigger>
+                  <SelectContent>
+                    {expirations.map((e) => (
+                      <SelectItem key={e.value} value={e.value}>
+                        {e.label}
+                      </SelectItem>
+                    ))}
+                  </SelectContent>
+                </Select>
+              </Field>
+              <Field>
+                <FieldLabel>Scopes</FieldLabel>
+                <div className="space-y-2">
+                  {scopes.map((scope) => (
+                    <div key={scope.value} className="flex items-center space-x-2">
+                      <Checkbox
+                        id={`scope-${scope.value}`}
+                        checked={selectedScopes.includes(scope.value)}
+                        onCheckedChange={(checked) =>
+                          setSelectedScopes(toggle(selectedScopes, scope.value, !!checked))
+                        }
+                      />
+                      <Label htmlFor={`scope-${scope.value}`} className="font-normal">
+                        <code className="text-xs">{scope.value}</code>
+                        <span className="text-muted-foreground">{scope.label}</span>
+                      </Label>
+                    </div>
+                  ))}
+                </div>
+                <FieldDescription>
+                  Your role in each project still applies
+                </FieldDescription>
+              </Field>
+              <Field>
+                <FieldLabel>Projects</FieldLabel>
+                <div className="space-y-2">
+                  {projects.map((project) => (
+                    <div key={project.id} className="flex items-center space-x-2">
+                      <Checkbox
+                        id={`project-${project.id}`}
+                        checked={projectIds.includes(project.id)}
+                        onCheckedChange={(checked) =>
+                          setProjectIds(toggle(projectIds, project.id, !!checked))
+                        }
+                      />
+                      <Label htmlFor={`project-${project.id}`} className="font-normal">
+                        {project.name}
+                      </Label>
+                    </div>
+                  ))}
+                </div>
+                <FieldDescription>
+                  Leave empty to allow all of your projects
+                </FieldDescription>
+              </Field>
+            </div>
+          )}
+
+          <DialogFooter>
+            {createdToken ? (
+              <Button onClick={() => handleCreateOpen(false)}>Done</Button>
+            ) : (
+              <>
+                <Button variant="outline" onClick={() => handleCreateOpen(false)}>
+                  Cancel
+                </Button>
+                <LoadingButton
+                  onClick={() => createMutation.mutate()}
+                  disabled={!name.trim() || selectedScopes.length === 0}
+                  loading={createMutation.isPending}
+                >
+                  Create
+                </LoadingButton>
+              </>
+            )}
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
+      <ConfirmDialog
+        open={!!revokeToken}
+        onOpenChange={(open) => !open && setRevokeToken(null)}
+        title="Revoke Token"
+        description={`Scripts using "${revokeToken?.name}" will stop working.`}
+        confirmLabel="Revoke"
+        variant="destructive"
+        onConfirm={() => revokeToken && revokeMutation.mutate(revokeToken.id)}
+        isLoading={revokeMutation.isPending}
+      />
+    </Card>
+  );
+}
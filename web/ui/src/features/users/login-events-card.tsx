@@ -0,0 +1,113 @@
+import { useState } from 'react';
+import { useMutation, useQuery } from '@tanstack/react-query';
+import { LockOpen } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { usersApi } from '@/api';
+import type { LoginEventReason } from '@/types';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Badge } from '@/components/ui/badge';
+import { Input } from '@/components/ui/input';
+import { formatRelativeTime } from '@/lib/format';
+
+const reasonLabels: Record<LoginEventReason, string> = {
+  invalid_credentials: 'Wrong password',
+  invalid_2fa_code: 'Wrong 2FA code',
+  blocked: 'Blocked user',
+  locked_out: 'Locked out',
+  auto_blocked: 'Blocked',
+};
+
+// LoginEventsCard lists failed logins and the lockouts they caused
+export function LoginEventsCard() {
+  const [search, setSearch] = useState('');
+
+  const filter = search.includes('@') ? { email: search.trim() } : { ip: search.trim() };
+  const { data: events = [] } = useQuery({
+    queryKey: ['login-events', filter],
+    queryFn: () => usersApi.loginEvents(filter),
+  });
+
+  const unlockIPMutation = useMutation({
+    mutationFn: (ip: string) => usersApi.unlockIP(ip),
+    onSuccess: () => {
+      toast.success('IP unlocked');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to unlock IP');
+    },
+  });
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between gap-4">
+          <div>
+            <CardTitle>Failed Logins</CardTitle>
+            <CardDescription>Failed logins and lockouts, newest first</CardDescription>
+          </div>
+          <Input
+            className="max-w-56"
+            placeholder="Filter by email or IP"
+            value={search}
+            onChange={(e) => setSearch(e.target.value)}
+          />
+        </div>
+      </CardHeader>
+      <CardContent>
+        {events.length === 0 ? (
+          <p className="text-sm text-muted-foreground">No failed logins</p>
+        ) : (
+          <div className="border rounded-lg overflow-hidden">
+            <table className="w-full text-sm">
+              <thead className="bg-muted/50">
+                <tr>
+                  <th className="text-left font-medium p-3">Email</th>
+                  <th className="text-left font-medium p-3">IP</th>
+                  <th className="text-left font-medium p-3">Reason</th>
+                  <th className="text-left font-medium p-3">When</th>
+                  <th className="w-12 p-3"></th>
+                </tr>
+              </thead>
+              <tbody>
+                {events.map((event) => (
+                  <tr key={event.id} className="border-t">
+                    <td className="p-3 truncate max-w-56">{event.email}</td>
+                    <td className="p-3 font-mono text-xs">{event.ip}</td>
+                    <td className="p-3">
+                      <Badge
+                        variant={event.reason === 'locked_out' || event.reason === 'auto_blocked' ? 'destructive' : 'outline'}
+                      >
+                        {reasonLabels[event.reason] ?? event.reason}
+                      </Badge>
+                    </td>
+                    <td className="p-3 text-muted-foreground">{formatRelativeTime(event.created_at)}</td>
+                    <td className="p-3">
+                      <Button
+                        variant="ghost"
+                        size="icon"
+                        className="size-8"
+                        title="Unlock IP"
+                        onClick={() => unlockIPMutation.mutate(event.ip)}
+                        disabled={unlockIPMutation.isPending}
+                      >
+                        <LockOpen className="size-4" />
+                      </Button>
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </CardContent>
+    </Card>
+  );
+}
@@ -19,6 +19,10 @@ import { LoadingButton } from '@/components/ui/loading-button';
 import { Field, FieldGroup, FieldLabel, FieldDescription, FieldError } from '@/components/ui/field';
 import { Avatar, AvatarFallback, AvatarImage } from '@/components/ui/avatar';
 import { formatDate } from '@/lib/format';
+import { SessionsCard } from './sessions-card';
+import { AccessTokensCard } from './access-tokens-card';
+import { TwoFactorCard } from './two-factor-card';
+import { SSOLinkCard } from './sso-link-card';
 
 function getInitials(name: string): string {
   return name
@@ -260,6 +264,18 @@ export function ProfilePage() {
         </CardContent>
       </Card>
 
+      {/* Two-Factor Authentication */}
+      <TwoFactorCard />
+
+      {/* Single Sign-On */}
+      <SSOLinkCard />
+
+      {/* Sessions */}
+      <SessionsCard />
+
+      {/* Access Tokens */}
+      <AccessTokensCard />
+
       {/* Account Info */}
       <Card>
         <CardHeader>
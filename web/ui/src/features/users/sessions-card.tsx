@@ -0,0 +1,118 @@
+import { useState } from 'react';
+import { useMutation, useQuery, useQueryClient } from '@tanstack/react-query';
+import { LogOut, X } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { authApi } from '@/api';
+import { useAuth } from '@/providers/auth-provider';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Badge } from '@/components/ui/badge';
+import { ConfirmDialog } from '@/components/shared/confirm-dialog';
+import { formatRelativeTime } from '@/lib/format';
+
+// describeClient shortens a user agent to the browser and platform
+function describeClient(userAgent: string): string {
+  if (!userAgent) return 'Unknown client';
+  const browser =
+    userAgent.match(/(Edg|OPR|Firefox|Chrome|Safari)\/[\d.]+/)?.[1]?.replace('Edg', 'Edge').replace('OPR', 'Opera') ??
+    userAgent.split(' ')[0];
+  const platform = userAgent.match(/\(([^;)]+)/)?.[1];
+  return platform ? `${browser} on ${platform}` : browser;
+}
+
+export function SessionsCard() {
+  const queryClient = useQueryClient();
+  const { logoutAll } = useAuth();
+  const [logoutAllOpen, setLogoutAllOpen] = useState(false);
+
+  const { data: sessions = [] } = useQuery({
+    queryKey: ['sessions'],
+    queryFn: authApi.sessions,
+  });
+
+  const revokeMutation = useMutation({
+    mutationFn: (id: string) => authApi.revokeSession(id),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['sessions'] });
+      toast.success('Session revoked');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to revoke session');
+    },
+  });
+
+  const logoutAllMutation = useMutation({
+    mutationFn: logoutAll,
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to log out');
+    },
+  });
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between">
+          <div>
+            <CardTitle>Sessions</CardTitle>
+            <CardDescription>
+              Devices signed in to your account
+            </CardDescription>
+          </div>
+          <Button variant="outline" size="sm" onClick={() => setLogoutAllOpen(true)}>
+            <LogOut className="mr-2 size-4" />
+            Log Out Everywhere
+          </Button>
+        </div>
+      </CardHeader>
+      <CardContent>
+        <div className="space-y-2">
+          {sessions.map((session) => (
+            <div key={session.id} className="flex items-center justify-between gap-4 py-2">
+              <div className="min-w-0">
+                <p className="font-medium truncate">
+                  {describeClient(session.user_agent)}
+                  {session.current && (
+                    <Badge variant="secondary" className="ml-2">
+                      This device
+                    </Badge>
+                  )}
+                </p>
+                <p className="text-sm text-muted-foreground">
+                  {session.ip || 'Unknown IP'} · active {formatRelativeTime(session.last_used_at)}
+                </p>
+              </div>
+              {!session.current && (
+                <Button
+                  variant="ghost"
+                  size="icon"
+                  title="Revoke session"
+                  onClick={() => revokeMutation.mutate(session.id)}
+                  disabled={revokeMutation.isPending}
+                >
+                  <X className="size-4" />
+                </Button>
+              )}
+            </div>
+          ))}
+        </div>
+      </CardContent>
+
+      <ConfirmDialog
+        open={logoutAllOpen}
+        onOpenChange={setLogoutAllOpen}
+        title="Log Out Everywhere"
+        description="All sessions, including this one, will be ended. You will need to sign in again."
+        confirmLabel="Log Out"
+        onConfirm={() => logoutAllMutation.mutate()}
+        isLoading={logoutAllMutation.isPending}
+      />
+    </Card>
+  );
+}
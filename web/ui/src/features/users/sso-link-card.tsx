@@ -0,0 +1,53 @@
+import { useMutation, useQuery } from '@tanstack/react-query';
+import { Link2 } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { authApi } from '@/api';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { LoadingButton } from '@/components/ui/loading-button';
+
+// SSOLinkCard links the account to an identity at the single sign-on
+// provider. Accounts with a password are never linked by email alone.
+export function SSOLinkCard() {
+  const { data: authConfig } = useQuery({
+    queryKey: ['auth-config'],
+    queryFn: authApi.config,
+  });
+
+  const linkMutation = useMutation({
+    mutationFn: () => authApi.oidcLink('/profile'),
+    onSuccess: ({ url }) => {
+      window.location.href = url;
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to start single sign-on');
+    },
+  });
+
+  if (!authConfig?.oidc.enabled) {
+    return null;
+  }
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>Single Sign-On</CardTitle>
+        <CardDescription>
+          Link this account to your {authConfig.oidc.name} identity to log in with it
+        </CardDescription>
+      </CardHeader>
+      <CardContent>
+        <LoadingButton variant="outline" loading={linkMutation.isPending} onClick={() => linkMutation.mutate()}>
+          <Link2 className="mr-2 size-4" />
+          Link {authConfig.oidc.name}
+        </LoadingButton>
+      </CardContent>
+    </Card>
+  );
+}
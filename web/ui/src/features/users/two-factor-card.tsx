@@ -0,0 +1,207 @@
+import { useState } from 'react';
+import { useMutation, useQuery, useQueryClient } from '@tanstack/react-query';
+import { ShieldCheck } from 'lucide-react';
+import { toast } from 'sonner';
+
+import { usersApi } from '@/api';
+import type { TwoFactorEnrollment } from '@/types';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from '@/components/ui/dialog';
+import { Badge } from '@/components/ui/badge';
+import { Input } from '@/components/ui/input';
+import { LoadingButton } from '@/components/ui/loading-button';
+import { Field, FieldDescription, FieldLabel } from '@/components/ui/field';
+import { formatDate } from '@/lib/format';
+
+// Dialogs of the card: setting up 2FA, or an action confirmed with a code
+type TwoFactorDialog = 'enroll' | 'disable' | 'recovery' | null;
+
+export function TwoFactorCard() {
+  const queryClient = useQueryClient();
+  const [dialog, setDialog] = useState<TwoFactorDialog>(null);
+  const [enrollment, setEnrollment] = useState<TwoFactorEnrollment | null>(null);
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+
+  const { data: status } = useQuery({
+    queryKey: ['two-factor'],
+    queryFn: usersApi.twoFactorStatus,
+  });
+
+  const closeDialog = () => {
+    setDialog(null);
+    setEnrollment(null);
+    setCode('');
+    setRecoveryCodes([]);
+  };
+
+  const onError = (err: unknown) => {
+    toast.error(err instanceof Error ? err.message : 'Two-factor authentication failed');
+  };
+
+  const enrollMutation = useMutation({
+    mutationFn: usersApi.enrollTwoFactor,
+    onSuccess: (data) => {
+      setEnrollment(data);
+      setDialog('enroll');
+    },
+    onError,
+  });
+
+  const confirmMutation = useMutation({
+    mutationFn: () =>
+      dialog === 'recovery' ? usersApi.regenerateRecoveryCodes(code) : usersApi.confirmTwoFactor(code),
+    onSuccess: (data) => {
+      queryClient.invalidateQueries({ queryKey: ['two-factor'] });
+      setRecoveryCodes(data.recovery_codes);
+      setCode('');
+    },
+    onError,
+  });
+
+  const disableMutation = useMutation({
+    mutationFn: () => usersApi.disableTwoFactor(code),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['two-factor'] });
+      closeDialog();
+      toast.success('Two-factor authentication disabled');
+    },
+    onError,
+  });
+
+  const submit = () => (dialog === 'disable' ? disableMutation.mutate() : confirmMutation.mutate());
+
+  const titles = {
+    enroll: 'Set Up Two-Factor Authentication',
+    disable: 'Disable Two-Factor Authentication',
+    recovery: 'New Recovery Codes',
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center justify-between">
+          <div>
+            <CardTitle className="flex items-center gap-2">
+              Two-Factor Authentication
+              {status?.enabled ? (
+                <Badge variant="default">On</Badge>
+              ) : (
+                <Badge variant="outline">Off</Badge>
+              )}
+            </CardTitle>
+            <CardDescription>
+              {status?.enabled && status.enabled_at
+                ? `Enabled ${formatDate(status.enabled_at)}, ${status.recovery_codes_left} recovery codes left`
+                : status?.required
+                  ? 'Required for your account, you will be asked to set it up on your next login'
+                  : 'Ask for a code from an authenticator app when logging in'}
+            </CardDescription>
+          </div>
+          {status?.enabled ? (
+            <div className="flex gap-2">
+              <Button variant="outline" size="sm" onClick={() => setDialog('recovery')}>
+                Recovery Codes
+              </Button>
+              {!status.required && (
+                <Button variant="outline" size="sm" onClick={() => setDialog('disable')}>
+                  Disable
+                </Button>
+              )}
+            </div>
+          ) : (
+            <LoadingButton size="sm" onClick={() => enrollMutation.mutate()} loading={enrollMutation.isPending}>
+              <ShieldCheck className="mr-2 size-4" />
+              Enable
+            </LoadingButton>
+          )}
+        </div>
+      </CardHeader>
+      {status?.enabled && status.recovery_codes_left < 3 && (
+        <CardContent>
+          <p className="text-sm text-destructive">
+            You are running out of recovery codes, generate new ones.
+          </p>
+        </CardContent>
+      )}
+
+      <Dialog open={dialog !== null} onOpenChange={(open) => !open && closeDialog()}>
+        <DialogContent className="max-w-md">
+          <DialogHeader>
+            <DialogTitle>{dialog && titles[dialog]}</DialogTitle>
+            <DialogDescription>
+              {recoveryCodes.length > 0
+                ? 'Save these recovery codes somewhere safe. Each one logs you in once if you lose your device, and they are not shown again.'
+                : dialog === 'enroll'
+                  ? 'Scan the code with an authenticator app, then enter the code it shows.'
+                  : 'Enter a code from your authenticator app or a recovery code.'}
+            </DialogDescription>
+          </DialogHeader>
+
+          {recoveryCodes.length > 0 ? (
+            <div className="grid grid-cols-2 gap-2 rounded-md border bg-muted/50 p-3 font-mono text-sm">
+              {recoveryCodes.map((recoveryCode) => (
+                <span key={recoveryCode}>{recoveryCode}</span>
+              ))}
+            </div>
+          ) : (
+            <div className="space-y-4">
+              {dialog === 'enroll' && enrollment && (
+                <div className="flex flex-col items-center gap-2">
+                  <img src={enrollment.qr_code} alt="Authenticator QR code" className="size-44 rounded-md bg-white p-2" />
+                  <code className="text-xs break-all text-center">{enrollment.secret}</code>
+                </div>
+              )}
+              <Field>
+                <FieldLabel>Code</FieldLabel>
+                <Input
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  autoComplete="one-time-code"
+                  onKeyDown={(e) => e.key === 'Enter' && code && submit()}
+                />
+                {dialog === 'recovery' && (
+                  <FieldDescription>Your current recovery codes stop working</FieldDescription>
+                )}
+              </Field>
+            </div>
+          )}
+
+          <DialogFooter>
+            {recoveryCodes.length > 0 ? (
+              <Button onClick={closeDialog}>I have saved the codes</Button>
+            ) : (
+              <>
+                <Button variant="outline" onClick={closeDialog}>
+                  Cancel
+                </Button>
+                <LoadingButton
+                  onClick={submit}
+                  disabled={!code.trim()}
+                  loading={confirmMutation.isPending || disableMutation.isPending}
+                  variant={dialog === 'disable' ? 'destructive' : 'default'}
+                >
+                  {dialog === 'disable' ? 'Disable' : 'Verify'}
+                </LoadingButton>
+              </>
+            )}
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </Card>
+  );
+}
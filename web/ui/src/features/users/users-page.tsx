@@ -12,6 +12,9 @@ import {
   Mail,
   Shield,
   Activity,
+  KeyRound,
+  LockOpen,
+  Link2,
 } from 'lucide-react';
 import { toast } from 'sonner';
 
@@ -53,6 +56,8 @@ import { PageHeader } from '@/components/shared/page-header';
 import { Skeleton } from '@/components/ui/skeleton';
 import { Badge } from '@/components/ui/badge';
 import { Avatar, AvatarFallback, AvatarImage } from '@/components/ui/avatar';
+import { LoginEventsCard } from './login-events-card';
+import { AuditLogCard } from '@/features/projects/audit-log-card';
 
 export function UsersPage() {
   useTitle('Users');
@@ -68,6 +73,7 @@ export function UsersPage() {
   const [createProjects, setCreateProjects] = useState(false);
   const [manageUsers, setManageUsers] = useState(false);
   const [projectAccess, setProjectAccess] = useState<string[]>([]);
+  const [require2FA, setRequire2FA] = useState(false);
 
   const { data: users = [], isLoading: usersLoading } = useQuery({
     queryKey: queryKeys.users.all,
@@ -140,6 +146,38 @@ export function UsersPage() {
     },
   });
 
+  const unlockMutation = useMutation({
+    mutationFn: (userId: string) => usersApi.unlock(userId),
+    onSuccess: () => {
+      toast.success('Login unlocked');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to unlock login');
+    },
+  });
+
+  const resetTwoFactorMutation = useMutation({
+    mutationFn: (userId: string) => usersApi.resetTwoFactor(userId),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: queryKeys.users.all });
+      toast.success('Two-factor authentication reset');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to reset two-factor authentication');
+    },
+  });
+
+  const allowSSOLinkMutation = useMutation({
+    mutationFn: (userId: string) => usersApi.allowSSOLink(userId),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: queryKeys.users.all });
+      toast.success('The next SSO login with this email will link the account');
+    },
+    onError: (err) => {
+      toast.error(err instanceof Error ? err.message : 'Failed to allow SSO link');
+    },
+  });
+
   const resetForm = () => {
     setEmail('');
     setPassword('');
@@ -147,6 +185,7 @@ export function UsersPage() {
     setCreateProjects(false);
     setManageUsers(false);
     setProjectAccess([]);
+    setRequire2FA(false);
   };
 
   const handleCreate = () => {
@@ -163,6 +202,7 @@ export function UsersPage() {
     setCreateProjects(user.permissions.create_projects);
     setManageUsers(user.permissions.manage_users);
     setProjectAccess(user.permissions.project_access || []);
+    setRequire2FA(user.two_factor?.required ?? false);
     formDialog.openEdit(user);
   };
 
@@ -170,6 +210,7 @@ export function UsersPage() {
     updateMutation.mutate({
       name,
       permissions: { create_projects: createProjects, manage_users: manageUsers, project_access: projectAccess },
+      require_2fa: require2FA,
     });
   };
 
@@ -271,6 +312,9 @@ export function UsersPage() {
                               {user.permissions.project_access.length} projects
                             </Badge>
                           )}
+                          {user.two_factor?.enabled && (
+                            <Badge variant="secondary">2FA</Badge>
+                          )}
                         </div>
                       </td>
                       <td className="p-3">
@@ -304,6 +348,22 @@ export function UsersPage() {
                                   Block
                                 </DropdownMenuItem>
                               )}
+                              <DropdownMenuItem onClick={() => unlockMutation.mutate(user.id)}>
+                                <LockOpen className="mr-2 size-4" />
+                                Unlock Login
+                              </DropdownMenuItem>
+                              {user.two_factor?.enabled && (
+                                <DropdownMenuItem onClick={() => resetTwoFactorMutation.mutate(user.id)}>
+                                  <KeyRound className="mr-2 size-4" />
+                                  Reset 2FA
+                                </DropdownMenuItem>
+                              )}
+                              {!user.sso_link_allowed && (
+                                <DropdownMenuItem onClick={() => allowSSOLinkMutation.mutate(user.id)}>
+                                  <Link2 className="mr-2 size-4" />
+                                  Allow SSO Link
+                                </DropdownMenuItem>
+                              )}
                               <DropdownMenuSeparator />
                               <DropdownMenuItem
                                 className="text-destructive"
@@ -325,6 +385,10 @@ export function UsersPage() {
         </Card>
       )}
 
+      <LoginEventsCard />
+
+      {currentUser?.is_root && <AuditLogCard />}
+
       <Dialog open={formDialog.isOpen} onOpenChange={(open) => !open && formDialog.close()}>
         <DialogContent className="max-w-2xl">
           <DialogHeader>
@@ -388,6 +452,12 @@ export function UsersPage() {
                   <Switch checked={manageUsers} onCheckedChange={setManageUsers} />
                   <span className="text-sm">Can manage users</span>
                 </div>
+                {formDialog.mode === 'edit' && (
+                  <div className="flex items-center gap-2">
+                    <Switch checked={require2FA} onCheckedChange={setRequire2FA} />
+                    <span className="text-sm">Require two-factor authentication</span>
+                  </div>
+                )}
               </div>
             </Field>
             <Field>
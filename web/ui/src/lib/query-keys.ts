@@ -70,4 +70,11 @@ export const queryKeys = {
     all: (projectId: string) => ['actions', projectId] as const,
     states: (projectId: string) => ['action-states', projectId] as const,
   },
+
+  // Webhooks
+  webhooks: {
+    deliveries: (projectId: string) => ['webhook-deliveries', projectId] as const,
+    delivery: (projectId: string, deliveryId: string) =>
+      ['webhook-delivery', projectId, deliveryId] as const,
+  },
 } as const;
@@ -1,8 +1,8 @@
 import { config } from '@/lib/config';
-import { getToken } from '@/api/client';
-import type { ActionRuntimeState } from '@/types';
+import { getFreshToken } from '@/api/client';
+import type { ActionRuntimeState, AlertEvent, QuotaWarning } from '@/types';
 
-export type EventType = 'monitor' | 'log' | 'running' | 'goals' | 'actions' | 'ui_request' | 'time';
+export type EventType = 'monitor' | 'log' | 'running' | 'goals' | 'actions' | 'ui_request' | 'time' | 'quota' | 'alert';
 
 export interface ServerTime {
   timestamp: number;
@@ -70,6 +70,8 @@ export interface WSEventHandlers {
   onGoals?: (projectId: string, data: unknown) => void;
   onActions?: (projectId: string, data: ActionRuntimeState[]) => void;
   onUIRequest?: (projectId: string, data: UIRequestData) => void;
+  onQuota?: (projectId: string, data: QuotaWarning) => void;
+  onAlert?: (projectId: string, data: AlertEvent) => void;
   onTime?: (data: ServerTime) => void;
   onConnect?: () => void;
   onDisconnect?: () => void;
@@ -101,13 +103,16 @@ class WebSocketClient {
     return wsUrl;
   }
 
-  connect(): void {
+  async connect(): Promise<void> {
     // Prevent multiple connections
     if (this.ws?.readyState === WebSocket.OPEN || this.ws?.readyState === WebSocket.CONNECTING) {
       return;
     }
 
-    const token = getToken();
+    const token = await getFreshToken();
+    if (this.ws?.readyState === WebSocket.OPEN || this.ws?.readyState === WebSocket.CONNECTING) {
+      return;
+    }
     if (!token) {
       console.warn('WebSocket: No auth token, skipping connection');
       return;
@@ -229,6 +234,12 @@ class WebSocketClient {
           case 'ui_request':
             this.handlers.onUIRequest?.(event.projectId, event.event.data as UIRequestData);
             break;
+          case 'quota':
+            this.handlers.onQuota?.(event.projectId, event.event.data as QuotaWarning);
+            break;
+          case 'alert':
+            this.handlers.onAlert?.(event.projectId, event.event.data as AlertEvent);
+            break;
         }
       }
     } catch (error) {
@@ -1,14 +1,16 @@
 /* eslint-disable react-refresh/only-export-components */
 import { createContext, useContext, useEffect, useState, useCallback } from 'react';
-import type { User } from '@/types';
+import type { LoginResponse, User } from '@/types';
 import { authApi, getToken, removeToken } from '@/api';
 
 interface AuthContextType {
   user: User | null;
   isLoading: boolean;
   isAuthenticated: boolean;
-  login: (email: string, password: string) => Promise<void>;
+  login: (email: string, password: string) => Promise<LoginResponse>;
+  loginWithSSO: (code: string) => Promise<LoginResponse>;
   logout: () => Promise<void>;
+  logoutAll: () => Promise<void>;
   refresh: () => Promise<void>;
 }
 
@@ -38,9 +40,17 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     }
   }, [refresh]);
 
+  // Logins that need a second step return its challenge and no user yet
   const login = async (email: string, password: string) => {
     const response = await authApi.login({ email, password });
-    setUser(response.user);
+    setUser(response.user ?? null);
+    return response;
+  };
+
+  const loginWithSSO = async (code: string) => {
+    const response = await authApi.oidcExchange(code);
+    setUser(response.user ?? null);
+    return response;
   };
 
   const logout = async () => {
@@ -48,6 +58,11 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     setUser(null);
   };
 
+  const logoutAll = async () => {
+    await authApi.logoutAll();
+    setUser(null);
+  };
+
   return (
     <AuthContext.Provider
       value={{
@@ -55,7 +70,9 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         isLoading,
         isAuthenticated: !!user,
         login,
+        loginWithSSO,
         logout,
+        logoutAll,
         refresh,
       }}
     >
@@ -1,6 +1,7 @@
 import { useEffect } from 'react';
 import { toast } from 'sonner';
 import { wsClient, type UIRequestData, type UIRequestOptions, type UIFormUpdateOptions } from '@/lib/websocket';
+import type { AlertEvent, QuotaWarning } from '@/types';
 import { useUIDialogStore } from '@/stores/ui-dialog-store';
 import { UIDialog } from '@/components/shared/ui-dialog';
 
@@ -40,8 +41,22 @@ export function UIDialogProvider({ children }: { children: React.ReactNode }) {
       addDialog(projectId, data);
     };
 
+    // Quota warnings of subscribed projects
+    const handleQuota = (_projectId: string, data: QuotaWarning) => {
+      const toastFn = data.exceeded ? toast.error : toast.warning;
+      toastFn(data.message);
+    };
+
+    // Alert rules with the toast channel
+    const handleAlert = (_projectId: string, data: AlertEvent) => {
+      const toastFn = data.status === 'firing' ? toast.error : toast.success;
+      toastFn(data.message);
+    };
+
     wsClient.setHandlers({
       onUIRequest: handleUIRequest,
+      onQuota: handleQuota,
+      onAlert: handleAlert,
     });
 
     return () => {
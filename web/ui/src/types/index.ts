@@ -7,6 +7,12 @@ export interface User {
   is_root: boolean;
   is_blocked: boolean;
   permissions: UserPermissions;
+  two_factor: {
+    enabled: boolean;
+    required: boolean; // Set by an admin
+    enabled_at?: string;
+  };
+  sso_link_allowed?: boolean; // The next SSO login with the email links the account
   created_at: string;
   updated_at: string;
 }
@@ -35,6 +41,7 @@ export interface UpdateUserRequest {
     manage_users: boolean;
     project_access: string[];
   };
+  require_2fa?: boolean;
 }
 
 export interface UpdateMeRequest {
@@ -56,15 +63,61 @@ export interface Project {
   api_key: string;
   owner_id: string;
   members: string[];
+  member_roles?: Record<string, ProjectRole>;
   auto_start?: boolean;
   active_release?: string;
   runningSource?: string; // "release:<version>" or "debug:<branch>"
+  log_retention?: LogRetention;
+  private_paths?: string[] | null;
+  versioned_paths?: StorageVersioning[] | null;
+  quota?: ProjectQuota;
+  timezone?: string; // IANA name, empty means UTC
   created_at: string;
   updated_at: string;
 }
 
 export type ProjectStatus = 'running' | 'stopped';
 
+// Project roles, from least to most privileged
+export type ProjectRole = 'viewer' | 'developer' | 'operator' | 'admin';
+
+export type ProjectPermission =
+  | 'view'
+  | 'code.edit'
+  | 'releases.manage'
+  | 'runtime.control'
+  | 'models.manage'
+  | 'data.read'
+  | 'data.write'
+  | 'env.read'
+  | 'env.write'
+  | 'storage.read'
+  | 'storage.write'
+  | 'dashboard.manage'
+  | 'settings.manage'
+  | 'members.manage'
+  | 'audit.read';
+
+export interface ProjectMember {
+  user_id: string;
+  email: string;
+  name: string;
+  avatar: string;
+  role: ProjectRole;
+  owner: boolean;
+}
+
+export interface ProjectAccess {
+  role: ProjectRole;
+  permissions: ProjectPermission[];
+}
+
+export interface ProjectMembersResponse {
+  members: ProjectMember[];
+  roles: Record<ProjectRole, ProjectPermission[]>;
+  current: ProjectAccess;
+}
+
 export interface CreateProjectRequest {
   name: string;
   slug: string;
@@ -75,6 +128,9 @@ export interface UpdateProjectRequest {
   name?: string;
   slug?: string;
   color?: string;
+  auto_start?: boolean;
+  log_retention?: LogRetention;
+  timezone?: string;
 }
 
 // Pipeline types
@@ -119,6 +175,52 @@ export interface ResetBranchRequest {
   target_version: string;
 }
 
+export interface GitRemote {
+  url: string;
+  username?: string;
+  password?: string;
+}
+
+export interface GitPushRequest extends GitRemote {
+  branch_name: string;
+  message?: string;
+  include_releases?: boolean;
+}
+
+export interface GitPullRequest extends GitRemote {
+  branch_name: string;
+  remote_branch?: string;
+  include_releases?: boolean;
+}
+
+export interface GitSyncResult {
+  branch: string;
+  commit: string;
+  changed: boolean;
+  files: number;
+  tags: string[];
+  skipped: string[];
+  messages?: string[];
+}
+
+export interface ExportProjectRequest {
+  include_data?: boolean;
+  exclude_files?: boolean;
+  passphrase?: string;
+}
+
+export interface ImportProjectRequest {
+  file: File;
+  name?: string;
+  slug?: string;
+  passphrase?: string;
+}
+
+export interface ImportProjectResult {
+  project: Project;
+  warnings: string[];
+}
+
 export interface CreatePipelineFileRequest {
   name: string;
 }
@@ -175,11 +277,12 @@ export interface Goal {
   gridSpan: number;
   showTotal: boolean;
   order: number;
+  buckets?: number[];
   createdAt: string;
   updatedAt: string;
 }
 
-export type GoalType = 'counter' | 'daily_counter';
+export type GoalType = 'counter' | 'daily_counter' | 'gauge' | 'sum' | 'avg' | 'histogram';
 
 // Widget types
 export type WidgetType =
@@ -235,6 +338,7 @@ export interface CreateGoalRequest {
   projectAccess?: string[];
   gridSpan?: number;
   showTotal?: boolean;
+  buckets?: number[];
 }
 
 export interface UpdateGoalRequest {
@@ -251,12 +355,29 @@ export interface GoalStats {
   goalID: string;
   value: number;
   totalValue?: number;
+  metric?: GoalMetric;
   dailyStats?: DailyGoalStat[];
 }
 
 export interface DailyGoalStat {
   date: string;
+  hour?: number;
+  labels?: Record<string, string>;
   value: number;
+  metric?: GoalMetric;
+}
+
+// Sample aggregates of gauge, sum, avg and histogram goals
+export interface GoalMetric {
+  count: number;
+  sum: number;
+  min: number;
+  max: number;
+  avg: number;
+  last: number;
+  last_at: string;
+  buckets?: Record<string, number>;
+  percentiles?: { p50: number; p90: number; p95: number; p99: number };
 }
 
 // Environment types
@@ -426,6 +547,50 @@ export interface StorageItem {
   url?: string;
   download_url?: string;
   thumb_url?: string;
+  private?: boolean; // Served only through signed URLs
+}
+
+export interface SignStorageUrlRequest {
+  path: string;
+  expires_in?: number; // Seconds, defaults to one hour
+  download?: boolean;
+}
+
+export interface SignedStorageUrl {
+  url: string;
+  expires_at: string;
+}
+
+export interface StorageVersioning {
+  path: string;
+  max_versions: number; // 0 = unlimited
+  max_age_days: number; // 0 = never expire
+}
+
+export interface SetStorageVersioningRequest extends StorageVersioning {
+  enabled: boolean;
+}
+
+export type StorageVersionReason = 'overwrite' | 'append' | 'delete' | 'restore';
+
+export interface StorageVersion {
+  id: string;
+  hash: string;
+  size: number;
+  user: string;
+  reason: StorageVersionReason;
+  created_at: string;
+}
+
+export interface StorageVersionList {
+  path: string;
+  versioning: StorageVersioning | null;
+  versions: StorageVersion[];
+}
+
+export interface StorageVersionPurge {
+  versions: number;
+  freed_bytes: number;
 }
 
 export interface CreateDirRequest {
@@ -488,6 +653,157 @@ export interface LogEntry {
   timestamp: string;
   level: 'debug' | 'info' | 'warn' | 'error';
   message: string;
+  source?: string;
+  request_id?: string;
+  fields?: Record<string, unknown>;
+}
+
+export interface LogQuery {
+  level?: string;
+  min_level?: string;
+  from?: string;
+  to?: string;
+  q?: string;
+  source?: string;
+  request_id?: string;
+  fields?: Record<string, string>;
+  limit?: number;
+  file?: string; // Historical log file name, latest if omitted
+}
+
+export interface LogFile {
+  name: string;
+  size: number;
+  modified_at: string;
+  compressed: boolean;
+  active: boolean;
+}
+
+// null inherits the server default, 0 disables the limit
+export interface LogRetention {
+  max_file_size_mb: number | null;
+  max_total_size_mb: number | null;
+  max_age_hours: number | null;
+}
+
+// Quota types (0 = inherit the server default, which may be unlimited)
+export type QuotaResource = 'storage' | 'data' | 'documents' | 'logs';
+
+export interface ProjectQuota {
+  storage_mb: number;
+  data_mb: number;
+  documents: number;
+  log_mb: number;
+}
+
+export interface QuotaUsage {
+  resource: QuotaResource;
+  used: number;
+  limit: number;
+  percent: number;
+  warning: boolean;
+}
+
+export interface ProjectQuotaStatus {
+  quota: ProjectQuota;
+  warn_percent: number;
+  usage: QuotaUsage[];
+}
+
+export interface QuotaWarning {
+  project_id: string;
+  resource: QuotaResource;
+  used: number;
+  limit: number;
+  percent: number;
+  exceeded: boolean;
+  message: string;
+}
+
+// Alert types
+export type AlertSource = 'goal' | 'runtime';
+export type AlertMetric = 'memory_mb' | 'cpu_percent' | 'storage_mb' | 'database_mb' | 'requests_per_min';
+export type AlertOperator = '>' | '>=' | '<' | '<=' | '==' | '!=';
+export type AlertChannelType = 'email' | 'webhook' | 'toast';
+export type AlertStatus = 'firing' | 'resolved';
+
+export interface AlertChannel {
+  type: AlertChannelType;
+  target?: string;
+  secret?: string;
+}
+
+export interface AlertState {
+  firing: boolean;
+  pending_since?: string;
+  last_value?: number;
+  last_checked?: string;
+  fired_at?: string;
+  resolved_at?: string;
+}
+
+export interface AlertRule {
+  id: string;
+  project_id: string;
+  name: string;
+  source: AlertSource;
+  goal_id?: string;
+  labels?: Record<string, string>;
+  metric?: AlertMetric;
+  operator: AlertOperator;
+  threshold: number;
+  for_seconds: number;
+  check_after?: string;
+  channels: AlertChannel[];
+  enabled: boolean;
+  silenced_until?: string;
+  state: AlertState;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateAlertRuleRequest {
+  name: string;
+  source: AlertSource;
+  goal_id?: string;
+  labels?: Record<string, string>;
+  metric?: AlertMetric;
+  operator: AlertOperator;
+  threshold: number;
+  for_seconds?: number;
+  check_after?: string;
+  channels?: AlertChannel[];
+  enabled?: boolean;
+}
+
+export interface UpdateAlertRuleRequest {
+  name?: string;
+  labels?: Record<string, string>;
+  operator?: AlertOperator;
+  threshold?: number;
+  for_seconds?: number;
+  check_after?: string;
+  channels?: AlertChannel[];
+  enabled?: boolean;
+}
+
+export interface SilenceAlertRequest {
+  minutes?: number;
+  until?: string;
+}
+
+export interface AlertEvent {
+  id: string;
+  rule_id: string;
+  project_id: string;
+  rule_name: string;
+  status: AlertStatus;
+  value: number;
+  message: string;
+  silenced: boolean;
+  notified: AlertChannelType[];
+  errors?: string[];
+  created_at: string;
 }
 
 // Action types
@@ -533,9 +849,150 @@ export interface LoginRequest {
   password: string;
 }
 
+// AuthConfig tells the login page which ways to log in are offered
+export interface AuthConfig {
+  password_login: boolean; // False when only root users may log in with a password
+  oidc: {
+    enabled: boolean;
+    name: string; // Label of the login button
+  };
+}
+
+// LoginResponse holds the tokens of a login, or only two_factor and
+// challenge when the login needs a second step
 export interface LoginResponse {
+  token?: string; // Short-lived access token
+  expires_at?: string;
+  refresh_token?: string; // Single use, exchanged at /auth/refresh
+  user?: User;
+  two_factor?: 'verify' | 'enroll';
+  challenge?: string;
+  recovery_codes?: string[]; // Shown once, when 2FA was enrolled during the login
+}
+
+export interface TwoFactorStatus {
+  enabled: boolean;
+  required: boolean; // By an admin or the server policy
+  recovery_codes_left: number;
+  enabled_at?: string;
+}
+
+export type LoginEventReason = 'invalid_credentials' | 'invalid_2fa_code' | 'blocked' | 'locked_out' | 'auto_blocked';
+
+// LoginEvent is a failed login, or a lockout it caused
+export interface LoginEvent {
+  id: string;
+  email: string;
+  user_id?: string;
+  ip: string;
+  user_agent: string;
+  reason: LoginEventReason;
+  created_at: string;
+}
+
+// AuditChange is a field changed by an audited action, values are JSON
+export interface AuditChange {
+  path: string;
+  before?: unknown;
+  after?: unknown;
+}
+
+// AuditEntry records an administrative action
+export interface AuditEntry {
+  id: string;
+  actor_id: string;
+  actor_email: string;
+  token_id?: string;
+  ip: string;
+  project_id?: string;
+  action: string; // "<target>.<verb>", e.g. "release.activate"
+  target: string;
+  changes?: AuditChange[];
+  created_at: string;
+}
+
+export interface AuditFilter {
+  projectId?: string;
+  actorId?: string;
+  action?: string; // An action, or a target like "release" for all its actions
+  from?: string; // RFC 3339
+  to?: string;
+  limit?: number;
+}
+
+export type WebhookDeliveryStatus = 'delivered' | 'failed' | 'rejected' | 'duplicate';
+
+// WebhookDelivery is an inbound request to a $router.webhook route
+export interface WebhookDelivery {
+  id: string;
+  project_id: string;
+  webhook: string; // Route path the webhook was registered with
+  provider: string;
+  delivery_id?: string;
+  method: string;
+  path: string;
+  headers: Record<string, string>; // Secrets are redacted
+  body_size: number;
+  body_truncated?: boolean; // Too large to keep whole, cannot be replayed
+  status: WebhookDeliveryStatus;
+  error?: string;
+  response_status: number;
+  duration_ms: number;
+  replay_of?: string;
+  created_at: string;
+  // Only on a single delivery
+  payload?: string;
+  payload_encoding?: 'utf8' | 'base64';
+}
+
+export interface WebhookDeliveryFilter {
+  webhook?: string;
+  status?: WebhookDeliveryStatus;
+  limit?: number;
+}
+
+export interface TwoFactorEnrollment {
+  secret: string;
+  uri: string; // otpauth:// URI
+  qr_code: string; // PNG data URL
+}
+
+// Personal access token, its secret is only returned on creation
+export interface AccessToken {
+  id: string;
+  user_id: string;
+  name: string;
+  hint: string;
+  scopes: ProjectPermission[];
+  project_ids: string[] | null; // Empty allows every project of the user
+  expires_at?: string;
+  last_used_at?: string;
+  last_used_ip?: string;
+  created_at: string;
+}
+
+export interface CreateAccessTokenRequest {
+  name: string;
+  scopes: ProjectPermission[];
+  project_ids?: string[];
+  expires_at?: string;
+}
+
+export interface CreateAccessTokenResponse {
   token: string;
-  user: User;
+  access_token: AccessToken;
+}
+
+// Server-side login session
+export interface Session {
+  id: string;
+  user_id: string;
+  user_agent: string;
+  ip: string;
+  created_at: string;
+  last_used_at: string;
+  expires_at: string;
+  current: boolean;
 }
 
 // API response types